@@ -13,6 +13,8 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 	"sync"
 	"time"
 
@@ -40,6 +42,25 @@ type RaftJoinRequest struct {
 	NonVoter         bool   `json:"non_voter"`
 }
 
+// RaftSnapshotPartialRestoreInput selects the storage restored by
+// RaftSnapshotPartialRestore. If MountUUID is set, Prefix is relative to the
+// storage of the mount.
+type RaftSnapshotPartialRestoreInput struct {
+	MountUUID string
+	Prefix    string
+	DryRun    bool
+}
+
+// RaftSnapshotPartialRestoreOutput lists the storage entries restored, or that
+// would be restored in a dry run, by RaftSnapshotPartialRestore.
+type RaftSnapshotPartialRestoreOutput struct {
+	Prefix    string   `mapstructure:"prefix"`
+	DryRun    bool     `mapstructure:"dry_run"`
+	Created   []string `mapstructure:"created"`
+	Updated   []string `mapstructure:"updated"`
+	Unchanged int      `mapstructure:"unchanged"`
+}
+
 // AutopilotConfig is used for querying/setting the Autopilot configuration.
 type AutopilotConfig struct {
 	CleanupDeadServers             bool          `json:"cleanup_dead_servers" mapstructure:"cleanup_dead_servers"`
@@ -314,6 +335,54 @@ func (c *Sys) RaftSnapshotRestoreWithContext(ctx context.Context, snapReader io.
 	return nil
 }
 
+// RaftSnapshotPartialRestore wraps RaftSnapshotPartialRestoreWithContext using context.Background.
+func (c *Sys) RaftSnapshotPartialRestore(snapReader io.Reader, input *RaftSnapshotPartialRestoreInput) (*RaftSnapshotPartialRestoreOutput, error) {
+	return c.RaftSnapshotPartialRestoreWithContext(context.Background(), snapReader, input)
+}
+
+// RaftSnapshotPartialRestoreWithContext reads the snapshot from the io.Reader
+// and restores only the storage entries under the selected mount or prefix,
+// leaving the rest of the cluster state untouched.
+func (c *Sys) RaftSnapshotPartialRestoreWithContext(ctx context.Context, snapReader io.Reader, input *RaftSnapshotPartialRestoreInput) (*RaftSnapshotPartialRestoreOutput, error) {
+	if input == nil {
+		return nil, errors.New("input is required")
+	}
+
+	r := c.c.NewRequest(http.MethodPost, "/v1/sys/storage/raft/snapshot-partial-restore")
+	r.Body = snapReader
+
+	// The body holds the snapshot so the parameters go in the query string
+	params := url.Values{}
+	if input.MountUUID != "" {
+		params.Set("mount_uuid", input.MountUUID)
+	}
+	if input.Prefix != "" {
+		params.Set("prefix", input.Prefix)
+	}
+	params.Set("dry_run", strconv.FormatBool(input.DryRun))
+	r.URL.RawQuery = params.Encode()
+
+	resp, err := c.c.httpRequestWithContext(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	secret, err := ParseSecret(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("data from server response is empty")
+	}
+
+	var result RaftSnapshotPartialRestoreOutput
+	if err := mapstructure.WeakDecode(secret.Data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
 // RaftAutopilotState wraps RaftAutopilotStateWithContext using context.Background.
 func (c *Sys) RaftAutopilotState() (*AutopilotState, error) {
 	return c.RaftAutopilotStateWithContext(context.Background())
@@ -114,6 +114,15 @@ type SealStatusResponse struct {
 	HCPLinkStatus     string   `json:"hcp_link_status,omitempty"`
 	HCPLinkResourceID string   `json:"hcp_link_resource_ID,omitempty"`
 	Warnings          []string `json:"warnings,omitempty"`
+
+	// Seals is the health of each seal when several seals are configured
+	Seals []SealBackendStatus `json:"seals,omitempty"`
+}
+
+type SealBackendStatus struct {
+	Name           string `json:"name"`
+	Healthy        bool   `json:"healthy"`
+	UnhealthySince string `json:"unhealthy_since,omitempty"`
 }
 
 type UnsealOpts struct {
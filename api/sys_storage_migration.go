@@ -0,0 +1,145 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// StorageMigrationStartInput is used as input to the StorageMigrationStart
+// function.
+type StorageMigrationStartInput struct {
+	DestinationType   string                 `json:"destination_type"`
+	DestinationConfig map[string]interface{} `json:"destination_config,omitempty"`
+	MaxParallel       int                    `json:"max_parallel,omitempty"`
+}
+
+// StorageMigrationStatus is the status of an online storage migration.
+type StorageMigrationStatus struct {
+	State                string    `mapstructure:"state"`
+	DestinationType      string    `mapstructure:"destination_type"`
+	StartTime            time.Time `mapstructure:"start_time"`
+	CutoverTime          time.Time `mapstructure:"cutover_time"`
+	KeysCopied           uint64    `mapstructure:"keys_copied"`
+	KeysRemoved          uint64    `mapstructure:"keys_removed"`
+	KeysVerified         uint64    `mapstructure:"keys_verified"`
+	KeysMismatched       uint64    `mapstructure:"keys_mismatched"`
+	MismatchedKeys       []string  `mapstructure:"mismatched_keys"`
+	SecondaryWriteErrors uint64    `mapstructure:"secondary_write_errors"`
+	Error                string    `mapstructure:"error"`
+}
+
+// StorageMigrationStart wraps StorageMigrationStartWithContext using
+// context.Background.
+func (c *Sys) StorageMigrationStart(input *StorageMigrationStartInput) error {
+	return c.StorageMigrationStartWithContext(context.Background(), input)
+}
+
+// StorageMigrationStartWithContext starts an online migration of the data of
+// the cluster to another storage backend.
+func (c *Sys) StorageMigrationStartWithContext(ctx context.Context, input *StorageMigrationStartInput) error {
+	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
+	defer cancelFunc()
+
+	r := c.c.NewRequest(http.MethodPost, "/v1/sys/storage/migration")
+	if err := r.SetJSONBody(input); err != nil {
+		return err
+	}
+
+	resp, err := c.c.rawRequestWithContext(ctx, r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
+
+// StorageMigrationStatus wraps StorageMigrationStatusWithContext using
+// context.Background.
+func (c *Sys) StorageMigrationStatus() (*StorageMigrationStatus, error) {
+	return c.StorageMigrationStatusWithContext(context.Background())
+}
+
+// StorageMigrationStatusWithContext returns the progress of the online
+// storage migration.
+func (c *Sys) StorageMigrationStatusWithContext(ctx context.Context) (*StorageMigrationStatus, error) {
+	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
+	defer cancelFunc()
+
+	r := c.c.NewRequest(http.MethodGet, "/v1/sys/storage/migration")
+
+	resp, err := c.c.rawRequestWithContext(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	secret, err := ParseSecret(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("data from server response is empty")
+	}
+
+	var result StorageMigrationStatus
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeHookFunc(time.RFC3339),
+		WeaklyTypedInput: true,
+		Result:           &result,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(secret.Data); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// StorageMigrationCutover wraps StorageMigrationCutoverWithContext using
+// context.Background.
+func (c *Sys) StorageMigrationCutover() error {
+	return c.StorageMigrationCutoverWithContext(context.Background())
+}
+
+// StorageMigrationCutoverWithContext makes the destination of a verified
+// storage migration the primary storage of the active node.
+func (c *Sys) StorageMigrationCutoverWithContext(ctx context.Context) error {
+	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
+	defer cancelFunc()
+
+	r := c.c.NewRequest(http.MethodPost, "/v1/sys/storage/migration/cutover")
+
+	resp, err := c.c.rawRequestWithContext(ctx, r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
+
+// StorageMigrationAbort wraps StorageMigrationAbortWithContext using
+// context.Background.
+func (c *Sys) StorageMigrationAbort() error {
+	return c.StorageMigrationAbortWithContext(context.Background())
+}
+
+// StorageMigrationAbortWithContext stops the online storage migration before
+// its cutover.
+func (c *Sys) StorageMigrationAbortWithContext(ctx context.Context) error {
+	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
+	defer cancelFunc()
+
+	r := c.c.NewRequest(http.MethodDelete, "/v1/sys/storage/migration")
+
+	resp, err := c.c.rawRequestWithContext(ctx, r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
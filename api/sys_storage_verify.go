@@ -0,0 +1,106 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// StorageVerifyIssue is an inconsistency found in storage by a verification.
+type StorageVerifyIssue struct {
+	Check    string `mapstructure:"check"`
+	Kind     string `mapstructure:"kind"`
+	Key      string `mapstructure:"key"`
+	Detail   string `mapstructure:"detail"`
+	Repaired bool   `mapstructure:"repaired"`
+}
+
+// StorageVerifyStatus is the status of a storage verification.
+type StorageVerifyStatus struct {
+	State           string                `mapstructure:"state"`
+	Repair          bool                  `mapstructure:"repair"`
+	RepairSkipped   bool                  `mapstructure:"repair_skipped"`
+	StartTime       time.Time             `mapstructure:"start_time"`
+	EndTime         time.Time             `mapstructure:"end_time"`
+	Check           string                `mapstructure:"check"`
+	EntriesScanned  uint64                `mapstructure:"entries_scanned"`
+	TermCounts      map[string]uint64     `mapstructure:"term_counts"`
+	IssueCounts     map[string]uint64     `mapstructure:"issue_counts"`
+	Issues          []*StorageVerifyIssue `mapstructure:"issues"`
+	IssuesTruncated bool                  `mapstructure:"issues_truncated"`
+	Repaired        uint64                `mapstructure:"repaired"`
+	Error           string                `mapstructure:"error"`
+}
+
+// StorageVerifyStart wraps StorageVerifyStartWithContext using
+// context.Background.
+func (c *Sys) StorageVerifyStart(repair bool) error {
+	return c.StorageVerifyStartWithContext(context.Background(), repair)
+}
+
+// StorageVerifyStartWithContext starts a verification of the data in storage.
+// When repair is set, the index entries that can be fixed are repaired.
+func (c *Sys) StorageVerifyStartWithContext(ctx context.Context, repair bool) error {
+	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
+	defer cancelFunc()
+
+	r := c.c.NewRequest(http.MethodPost, "/v1/sys/storage/verify")
+	if err := r.SetJSONBody(map[string]interface{}{"repair": repair}); err != nil {
+		return err
+	}
+
+	resp, err := c.c.rawRequestWithContext(ctx, r)
+	if err == nil {
+		defer resp.Body.Close()
+	}
+	return err
+}
+
+// StorageVerifyStatus wraps StorageVerifyStatusWithContext using
+// context.Background.
+func (c *Sys) StorageVerifyStatus() (*StorageVerifyStatus, error) {
+	return c.StorageVerifyStatusWithContext(context.Background())
+}
+
+// StorageVerifyStatusWithContext returns the progress and the findings of the
+// last storage verification.
+func (c *Sys) StorageVerifyStatusWithContext(ctx context.Context) (*StorageVerifyStatus, error) {
+	ctx, cancelFunc := c.c.withConfiguredTimeout(ctx)
+	defer cancelFunc()
+
+	r := c.c.NewRequest(http.MethodGet, "/v1/sys/storage/verify")
+
+	resp, err := c.c.rawRequestWithContext(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	secret, err := ParseSecret(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("data from server response is empty")
+	}
+
+	var result StorageVerifyStatus
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeHookFunc(time.RFC3339),
+		WeaklyTypedInput: true,
+		Result:           &result,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(secret.Data); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -103,6 +103,7 @@ func Backend(conf *logical.BackendConfig) *databaseBackend {
 			SealWrapStorage: []string{
 				"config/*",
 				"static-role/*",
+				"library/*",
 			},
 		},
 		Paths: framework.PathAppend(
@@ -111,15 +112,21 @@ func Backend(conf *logical.BackendConfig) *databaseBackend {
 				pathConfigurePluginConnection(&b),
 				pathResetConnection(&b),
 				pathReloadPlugin(&b),
+				pathListLibrary(&b),
+				pathLibrary(&b),
+				pathLibraryStatus(&b),
+				pathLibraryCheckOut(&b),
 			},
 			pathListRoles(&b),
 			pathRoles(&b),
 			pathCredsCreate(&b),
 			pathRotateRootCredentials(&b),
+			pathLibraryCheckIn(&b),
 		),
 
 		Secrets: []*framework.Secret{
 			secretCreds(&b),
+			secretLibrary(&b),
 		},
 		Clean:             b.clean,
 		Invalidate:        b.invalidate,
@@ -181,6 +188,10 @@ type databaseBackend struct {
 	// issues with the priority queue.
 	roleLocks []*locksutil.LockEntry
 
+	// libraryLock serializes writes and deletes of library sets, as checking
+	// that an account belongs to only one set reads every other set.
+	libraryLock sync.Mutex
+
 	// the running gauge collection process
 	gaugeCollectionProcess     *metricsutil.GaugeCollectionProcess
 	gaugeCollectionProcessStop sync.Once
@@ -256,7 +256,7 @@ func (b *databaseBackend) pathStaticCredsRead() framework.OperationFunc {
 		}
 
 		respData := map[string]interface{}{
-			"username":            role.StaticAccount.Username,
+			"username":            role.StaticAccount.CurrentUsername(),
 			"ttl":                 role.StaticAccount.CredentialTTL().Seconds(),
 			"last_vault_rotation": role.StaticAccount.LastVaultRotation,
 		}
@@ -277,6 +277,22 @@ func (b *databaseBackend) pathStaticCredsRead() framework.OperationFunc {
 			respData["rsa_private_key"] = string(role.StaticAccount.PrivateKey)
 		}
 
+		// Dual-account roles also return the credential of the previously
+		// active account until its grace period ends.
+		if role.StaticAccount.IsDualAccount() && role.StaticAccount.PreviousUsername != "" {
+			validUntil := role.StaticAccount.PreviousCredentialValidUntil()
+			if ttl := time.Until(validUntil).Round(time.Second); ttl > 0 {
+				respData["previous_username"] = role.StaticAccount.PreviousUsername
+				respData["previous_ttl"] = ttl.Seconds()
+				switch role.CredentialType {
+				case v5.CredentialTypePassword:
+					respData["previous_password"] = role.StaticAccount.PreviousPassword
+				case v5.CredentialTypeRSAPrivateKey:
+					respData["previous_rsa_private_key"] = string(role.StaticAccount.PreviousPrivateKey)
+				}
+			}
+		}
+
 		return &logical.Response{
 			Data: respData,
 		}, nil
@@ -0,0 +1,501 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const databaseLibraryPath = "library/"
+
+// librarySet is a pool of pre-existing database accounts that can be checked
+// out by one borrower at a time. Vault never creates or drops these accounts;
+// it only rotates their passwords on check-out and check-in.
+type librarySet struct {
+	DBName                    string        `json:"db_name"`
+	ServiceAccountNames       []string      `json:"service_account_names"`
+	TTL                       time.Duration `json:"ttl"`
+	MaxTTL                    time.Duration `json:"max_ttl"`
+	DisableCheckInEnforcement bool          `json:"disable_check_in_enforcement"`
+	MaxCheckOutsPerEntity     int           `json:"max_check_outs_per_entity"`
+	RotationStatements        []string      `json:"rotation_statements"`
+
+	// Accounts holds the credential and check-out state of each service
+	// account, keyed by account name.
+	Accounts map[string]*libraryAccount `json:"accounts"`
+}
+
+// libraryAccount is the state of a single service account of a library set.
+type libraryAccount struct {
+	Password          string    `json:"password"`
+	LastVaultRotation time.Time `json:"last_vault_rotation"`
+
+	// CheckOutID identifies the current check-out and is kept in the lease's
+	// internal data, so a lease revoked after its account was checked in and
+	// out again does not return the account from under the new borrower.
+	CheckOutID       string    `json:"check_out_id,omitempty"`
+	BorrowerEntityID string    `json:"borrower_entity_id,omitempty"`
+	BorrowerAccessor string    `json:"borrower_accessor,omitempty"`
+	CheckedOutAt     time.Time `json:"checked_out_at,omitempty"`
+}
+
+// IsAvailable returns true if the account is not checked out.
+func (a *libraryAccount) IsAvailable() bool {
+	return a == nil || a.CheckOutID == ""
+}
+
+// borrowedBy returns true if the account is checked out by the requester,
+// identified by its entity or, failing that, by its token accessor.
+func (a *libraryAccount) borrowedBy(req *logical.Request) bool {
+	if a.IsAvailable() {
+		return false
+	}
+	if a.BorrowerEntityID != "" {
+		return a.BorrowerEntityID == req.EntityID
+	}
+	return a.BorrowerAccessor == req.ClientTokenAccessor
+}
+
+func pathListLibrary(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/?$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixDatabase,
+			OperationVerb:   "list",
+			OperationSuffix: "library-sets",
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathLibraryList,
+		},
+
+		HelpSynopsis:    pathLibraryHelpSyn,
+		HelpDescription: pathLibraryHelpDesc,
+	}
+}
+
+func pathLibrary(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/" + framework.GenericNameRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixDatabase,
+			OperationSuffix: "library-set",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the library set.",
+				Required:    true,
+			},
+			"db_name": {
+				Type: framework.TypeString,
+				Description: `Name of the database connection the service accounts belong
+to. The name of the library set must be in the allowed_roles of the connection.`,
+			},
+			"service_account_names": {
+				Type: framework.TypeCommaStringSlice,
+				Description: `The existing database accounts that make up the set. An
+account can only belong to one set.`,
+			},
+			"ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Default lease duration of a check-out.",
+			},
+			"max_ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Maximum lease duration of a check-out.",
+			},
+			"disable_check_in_enforcement": {
+				Type: framework.TypeBool,
+				Description: `If true, any client allowed to call check-in can check in
+any account of the set, not only the ones it checked out.`,
+			},
+			"max_check_outs_per_entity": {
+				Type: framework.TypeInt,
+				Description: `Maximum number of accounts of the set a single entity can
+have checked out at once. Zero means no limit.`,
+			},
+			"rotation_statements": {
+				Type: framework.TypeStringSlice,
+				Description: `Specifies the database statements to be executed to
+rotate the password of a service account on check-out and check-in.`,
+			},
+		},
+
+		ExistenceCheck: b.pathLibraryExistenceCheck,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathLibraryRead,
+			logical.CreateOperation: b.pathLibraryCreateUpdate,
+			logical.UpdateOperation: b.pathLibraryCreateUpdate,
+			logical.DeleteOperation: b.pathLibraryDelete,
+		},
+
+		HelpSynopsis:    pathLibraryHelpSyn,
+		HelpDescription: pathLibraryHelpDesc,
+	}
+}
+
+func pathLibraryStatus(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/" + framework.GenericNameRegex("name") + "/status$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixDatabase,
+			OperationVerb:   "read",
+			OperationSuffix: "library-set-status",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the library set.",
+				Required:    true,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathLibraryStatusRead,
+		},
+
+		HelpSynopsis:    pathLibraryStatusHelpSyn,
+		HelpDescription: pathLibraryStatusHelpDesc,
+	}
+}
+
+func (b *databaseBackend) librarySet(ctx context.Context, s logical.Storage, name string) (*librarySet, error) {
+	entry, err := s.Get(ctx, databaseLibraryPath+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var set librarySet
+	if err := entry.DecodeJSON(&set); err != nil {
+		return nil, err
+	}
+	if set.Accounts == nil {
+		set.Accounts = make(map[string]*libraryAccount)
+	}
+	return &set, nil
+}
+
+func storeLibrarySet(ctx context.Context, s logical.Storage, name string, set *librarySet) error {
+	entry, err := logical.StorageEntryJSON(databaseLibraryPath+name, set)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// lockLibrarySet takes the exclusive lock of a library set. The role locks
+// are shared with static roles, so the key is prefixed to keep a set and a
+// static role of the same name apart.
+func (b *databaseBackend) lockLibrarySet(name string) func() {
+	lock := locksutil.LockForKey(b.roleLocks, databaseLibraryPath+name)
+	lock.Lock()
+	return lock.Unlock
+}
+
+func (b *databaseBackend) pathLibraryExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+	set, err := b.librarySet(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return false, err
+	}
+	return set != nil, nil
+}
+
+func (b *databaseBackend) pathLibraryList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	sets, err := req.Storage.List(ctx, databaseLibraryPath)
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(sets), nil
+}
+
+func (b *databaseBackend) pathLibraryRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	set, err := b.librarySet(ctx, req.Storage, data.Get("name").(string))
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"db_name":                      set.DBName,
+			"service_account_names":        set.ServiceAccountNames,
+			"ttl":                          set.TTL.Seconds(),
+			"max_ttl":                      set.MaxTTL.Seconds(),
+			"disable_check_in_enforcement": set.DisableCheckInEnforcement,
+			"max_check_outs_per_entity":    set.MaxCheckOutsPerEntity,
+			"rotation_statements":          set.RotationStatements,
+		},
+	}, nil
+}
+
+func (b *databaseBackend) pathLibraryCreateUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	if name == "" {
+		return logical.ErrorResponse("empty library set name attribute given"), nil
+	}
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	unlock := b.lockLibrarySet(name)
+	defer unlock()
+
+	set, err := b.librarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		if req.Operation == logical.UpdateOperation {
+			return logical.ErrorResponse("library set %q does not exist", name), nil
+		}
+		set = &librarySet{
+			Accounts: make(map[string]*libraryAccount),
+		}
+	}
+
+	if dbNameRaw, ok := data.GetOk("db_name"); ok {
+		dbName := dbNameRaw.(string)
+		if set.DBName != "" && dbName != set.DBName {
+			return logical.ErrorResponse("cannot update the db_name of a library set"), nil
+		}
+		set.DBName = dbName
+	}
+	if set.DBName == "" {
+		return logical.ErrorResponse("db_name is required"), nil
+	}
+	dbConfig, err := b.DatabaseConfig(ctx, req.Storage, set.DBName)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	// Check-outs rotate passwords through the connection the same way static
+	// roles do, so the set has to be an allowed role of the connection.
+	if !strutil.StrListContains(dbConfig.AllowedRoles, "*") && !strutil.StrListContainsGlob(dbConfig.AllowedRoles, name) {
+		return logical.ErrorResponse("%q is not an allowed role of database connection %q", name, set.DBName), nil
+	}
+
+	if namesRaw, ok := data.GetOk("service_account_names"); ok {
+		names := strutil.RemoveDuplicates(namesRaw.([]string), false)
+
+		// Accounts that are checked out can not be removed from the set, as
+		// their borrowers still hold the credential.
+		for _, existing := range set.ServiceAccountNames {
+			if !strutil.StrListContains(names, existing) && !set.Accounts[existing].IsAvailable() {
+				return logical.ErrorResponse("cannot remove account %q from the set while it is checked out", existing), nil
+			}
+		}
+
+		owners, err := b.libraryAccountOwners(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+		for _, account := range names {
+			if owner, ok := owners[account]; ok && owner != name {
+				return logical.ErrorResponse("account %q already belongs to library set %q", account, owner), nil
+			}
+		}
+
+		accounts := make(map[string]*libraryAccount, len(names))
+		for _, account := range names {
+			if state, ok := set.Accounts[account]; ok {
+				accounts[account] = state
+			} else {
+				accounts[account] = &libraryAccount{}
+			}
+		}
+		set.ServiceAccountNames = names
+		set.Accounts = accounts
+	}
+	if len(set.ServiceAccountNames) == 0 {
+		return logical.ErrorResponse("at least one service account name is required"), nil
+	}
+
+	if ttlRaw, ok := data.GetOk("ttl"); ok {
+		set.TTL = time.Duration(ttlRaw.(int)) * time.Second
+	}
+	if maxTTLRaw, ok := data.GetOk("max_ttl"); ok {
+		set.MaxTTL = time.Duration(maxTTLRaw.(int)) * time.Second
+	}
+	if set.TTL < 0 || set.MaxTTL < 0 {
+		return logical.ErrorResponse("ttl and max_ttl must not be negative"), nil
+	}
+	if set.MaxTTL != 0 && set.TTL > set.MaxTTL {
+		return logical.ErrorResponse("ttl cannot be greater than max_ttl"), nil
+	}
+
+	if disableRaw, ok := data.GetOk("disable_check_in_enforcement"); ok {
+		set.DisableCheckInEnforcement = disableRaw.(bool)
+	}
+	if maxRaw, ok := data.GetOk("max_check_outs_per_entity"); ok {
+		set.MaxCheckOutsPerEntity = maxRaw.(int)
+	}
+	if set.MaxCheckOutsPerEntity < 0 {
+		return logical.ErrorResponse("max_check_outs_per_entity must not be negative"), nil
+	}
+	if stmtsRaw, ok := data.GetOk("rotation_statements"); ok {
+		set.RotationStatements = stmtsRaw.([]string)
+	}
+
+	if err := storeLibrarySet(ctx, req.Storage, name, set); err != nil {
+		return nil, err
+	}
+	b.dbEvent(ctx, "library-write", req.Path, name, true)
+	return nil, nil
+}
+
+func (b *databaseBackend) pathLibraryDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	b.libraryLock.Lock()
+	defer b.libraryLock.Unlock()
+
+	unlock := b.lockLibrarySet(name)
+	defer unlock()
+
+	set, err := b.librarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return nil, nil
+	}
+
+	for _, account := range set.ServiceAccountNames {
+		if !set.Accounts[account].IsAvailable() {
+			return logical.ErrorResponse("cannot delete library set %q while account %q is checked out", name, account), nil
+		}
+	}
+
+	if err := req.Storage.Delete(ctx, databaseLibraryPath+name); err != nil {
+		return nil, err
+	}
+	b.dbEvent(ctx, "library-delete", req.Path, name, true)
+	return nil, nil
+}
+
+func (b *databaseBackend) pathLibraryStatusRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	set, err := b.librarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return logical.ErrorResponse("library set %q does not exist", name), nil
+	}
+
+	status := make(map[string]interface{}, len(set.ServiceAccountNames))
+	for _, account := range set.ServiceAccountNames {
+		state := set.Accounts[account]
+		accountStatus := map[string]interface{}{
+			"available": state.IsAvailable(),
+		}
+		if !state.LastVaultRotation.IsZero() {
+			accountStatus["last_vault_rotation"] = state.LastVaultRotation.Format(time.RFC3339)
+		}
+		if !state.IsAvailable() {
+			accountStatus["borrower_entity_id"] = state.BorrowerEntityID
+			accountStatus["borrower_accessor"] = state.BorrowerAccessor
+			accountStatus["checked_out_at"] = state.CheckedOutAt.Format(time.RFC3339)
+		}
+		status[account] = accountStatus
+	}
+
+	return &logical.Response{
+		Data: status,
+	}, nil
+}
+
+// libraryAccountOwners returns the name of the library set each service
+// account belongs to. Callers must hold libraryLock, so no other set changes
+// its accounts while the result is used.
+func (b *databaseBackend) libraryAccountOwners(ctx context.Context, s logical.Storage) (map[string]string, error) {
+	names, err := s.List(ctx, databaseLibraryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]string)
+	for _, name := range names {
+		set, err := b.librarySet(ctx, s, name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read library set %q: %w", name, err)
+		}
+		if set == nil {
+			continue
+		}
+		for _, account := range set.ServiceAccountNames {
+			owners[account] = name
+		}
+	}
+	return owners, nil
+}
+
+// sortedAccounts returns the account names of the set in a stable order, so
+// check-outs hand out accounts predictably.
+func (s *librarySet) sortedAccounts() []string {
+	names := make([]string, len(s.ServiceAccountNames))
+	copy(names, s.ServiceAccountNames)
+	sort.Strings(names)
+	return names
+}
+
+// checkOutsByEntity returns the number of accounts of the set checked out by
+// the given entity.
+func (s *librarySet) checkOutsByEntity(entityID string) int {
+	var count int
+	for _, state := range s.Accounts {
+		if !state.IsAvailable() && state.BorrowerEntityID == entityID {
+			count++
+		}
+	}
+	return count
+}
+
+const pathLibraryHelpSyn = `
+Manage library sets of existing database accounts that can be checked out.
+`
+
+const pathLibraryHelpDesc = `
+A library set is a pool of existing database accounts, such as shared service
+accounts, that Vault lends out one borrower at a time. Vault never creates or
+drops the accounts. It rotates the password of an account when it is checked
+out through 'library/<name>/check-out', and again when it is checked in
+through 'library/<name>/check-in' or its lease is revoked, so a returned
+credential stops working.
+
+Rotations go through the connection named by 'db_name', so the name of the
+set must be listed in the 'allowed_roles' of that connection.
+
+'max_check_outs_per_entity' limits how many accounts of the set a single
+identity entity can hold at once.
+`
+
+const pathLibraryStatusHelpSyn = `
+Report which accounts of a library set are available or checked out.
+`
+
+const pathLibraryStatusHelpDesc = `
+Returns, for each account of the library set, whether it is available. For
+checked out accounts the borrower's entity ID and token accessor are also
+returned.
+`
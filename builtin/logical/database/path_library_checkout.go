@@ -0,0 +1,479 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	"github.com/hashicorp/go-uuid"
+	v4 "github.com/hashicorp/vault/sdk/database/dbplugin"
+	v5 "github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const SecretLibraryType = "library"
+
+func pathLibraryCheckOut(b *databaseBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "library/" + framework.GenericNameRegex("name") + "/check-out$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixDatabase,
+			OperationVerb:   "check-out",
+			OperationSuffix: "library-account",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the library set.",
+				Required:    true,
+			},
+			"ttl": {
+				Type: framework.TypeDurationSecond,
+				Description: `The lease duration of the check-out. It can not exceed the
+ttl of the set.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathLibraryCheckOut,
+		},
+
+		HelpSynopsis:    pathLibraryCheckOutHelpSyn,
+		HelpDescription: pathLibraryCheckOutHelpDesc,
+	}
+}
+
+func pathLibraryCheckIn(b *databaseBackend) []*framework.Path {
+	fields := map[string]*framework.FieldSchema{
+		"name": {
+			Type:        framework.TypeString,
+			Description: "Name of the library set.",
+			Required:    true,
+		},
+		"service_account_names": {
+			Type: framework.TypeCommaStringSlice,
+			Description: `The accounts to check in. Defaults to every account of the
+set checked out by the requester.`,
+		},
+	}
+
+	return []*framework.Path{
+		{
+			Pattern: "library/" + framework.GenericNameRegex("name") + "/check-in$",
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixDatabase,
+				OperationVerb:   "check-in",
+				OperationSuffix: "library-accounts",
+			},
+
+			Fields: fields,
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: b.pathLibraryCheckIn(true),
+			},
+
+			HelpSynopsis:    pathLibraryCheckInHelpSyn,
+			HelpDescription: pathLibraryCheckInHelpDesc,
+		},
+		{
+			Pattern: "library/manage/" + framework.GenericNameRegex("name") + "/check-in$",
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixDatabase,
+				OperationVerb:   "force-check-in",
+				OperationSuffix: "library-accounts",
+			},
+
+			Fields: fields,
+
+			Callbacks: map[logical.Operation]framework.OperationFunc{
+				logical.UpdateOperation: b.pathLibraryCheckIn(false),
+			},
+
+			HelpSynopsis:    pathLibraryManageCheckInHelpSyn,
+			HelpDescription: pathLibraryManageCheckInHelpDesc,
+		},
+	}
+}
+
+func secretLibrary(b *databaseBackend) *framework.Secret {
+	return &framework.Secret{
+		Type:   SecretLibraryType,
+		Fields: map[string]*framework.FieldSchema{},
+
+		Renew:  b.secretLibraryRenew,
+		Revoke: b.secretLibraryRevoke,
+	}
+}
+
+func (b *databaseBackend) pathLibraryCheckOut(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	unlock := b.lockLibrarySet(name)
+	defer unlock()
+
+	set, err := b.librarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return logical.ErrorResponse("library set %q does not exist", name), nil
+	}
+
+	if set.MaxCheckOutsPerEntity > 0 && req.EntityID != "" && set.checkOutsByEntity(req.EntityID) >= set.MaxCheckOutsPerEntity {
+		return logical.ErrorResponse("entity has reached the limit of %d checked out accounts for library set %q", set.MaxCheckOutsPerEntity, name), nil
+	}
+
+	ttl := set.TTL
+	if requested := time.Duration(data.Get("ttl").(int)) * time.Second; requested > 0 && (ttl == 0 || requested < ttl) {
+		ttl = requested
+	}
+
+	var account string
+	for _, candidate := range set.sortedAccounts() {
+		if set.Accounts[candidate].IsAvailable() {
+			account = candidate
+			break
+		}
+	}
+	if account == "" {
+		return logical.ErrorResponse("no accounts of library set %q are available for check-out", name), nil
+	}
+
+	// The password is rotated before it is handed out, so no earlier borrower
+	// can still be using it.
+	state := set.Accounts[account]
+	walID, err := b.rotateLibraryAccount(ctx, req.Storage, name, set, account, state, "")
+	if err != nil {
+		return nil, err
+	}
+
+	checkOutID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	state.CheckOutID = checkOutID
+	state.BorrowerEntityID = req.EntityID
+	state.BorrowerAccessor = req.ClientTokenAccessor
+	state.CheckedOutAt = time.Now()
+
+	if err := storeLibrarySet(ctx, req.Storage, name, set); err != nil {
+		return nil, err
+	}
+	b.deleteLibraryWALs(ctx, req.Storage, walID)
+	b.dbEvent(ctx, "library-check-out", req.Path, name, true, "service_account_name", account)
+
+	respData := map[string]interface{}{
+		"service_account_name": account,
+		"password":             state.Password,
+	}
+	internal := map[string]interface{}{
+		"set_name":             name,
+		"service_account_name": account,
+		"check_out_id":         checkOutID,
+	}
+	resp := b.Secret(SecretLibraryType).Response(respData, internal)
+	resp.Secret.TTL = ttl
+	resp.Secret.MaxTTL = set.MaxTTL
+	return resp, nil
+}
+
+func (b *databaseBackend) pathLibraryCheckIn(enforce bool) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		name := data.Get("name").(string)
+
+		unlock := b.lockLibrarySet(name)
+		defer unlock()
+
+		set, err := b.librarySet(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if set == nil {
+			return logical.ErrorResponse("library set %q does not exist", name), nil
+		}
+		enforce = enforce && !set.DisableCheckInEnforcement
+
+		accounts := data.Get("service_account_names").([]string)
+		if len(accounts) == 0 {
+			for _, account := range set.sortedAccounts() {
+				if set.Accounts[account].borrowedBy(req) {
+					accounts = append(accounts, account)
+				}
+			}
+			if len(accounts) == 0 {
+				return logical.ErrorResponse("no accounts of library set %q are checked out by the requester", name), nil
+			}
+		}
+
+		var toCheckIn []string
+		for _, account := range accounts {
+			if !strutil.StrListContains(set.ServiceAccountNames, account) {
+				return logical.ErrorResponse("account %q does not belong to library set %q", account, name), nil
+			}
+			state := set.Accounts[account]
+			if state.IsAvailable() {
+				continue
+			}
+			if enforce && !state.borrowedBy(req) {
+				return logical.ErrorResponse("account %q is not checked out by the requester", account), nil
+			}
+			toCheckIn = append(toCheckIn, account)
+		}
+
+		checkIns := []string{}
+		var walIDs []string
+		var checkInErr error
+		for _, account := range toCheckIn {
+			walID, err := b.checkInLibraryAccount(ctx, req.Storage, name, set, account)
+			if err != nil {
+				checkInErr = err
+				break
+			}
+			checkIns = append(checkIns, account)
+			walIDs = append(walIDs, walID)
+		}
+
+		// Persist the accounts that were checked in even if a later one
+		// failed, as their passwords have already been rotated.
+		if len(checkIns) > 0 {
+			if err := storeLibrarySet(ctx, req.Storage, name, set); err != nil {
+				return nil, err
+			}
+			b.deleteLibraryWALs(ctx, req.Storage, walIDs...)
+			b.dbEvent(ctx, "library-check-in", req.Path, name, true)
+		}
+		if checkInErr != nil {
+			return nil, checkInErr
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"check_ins": checkIns,
+			},
+		}, nil
+	}
+}
+
+func (b *databaseBackend) secretLibraryRenew(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	name, account, checkOutID, err := libraryInternalData(req.Secret.InternalData)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := b.librarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return nil, fmt.Errorf("error during renew: could not find library set %q", name)
+	}
+	if state := set.Accounts[account]; state == nil || state.CheckOutID != checkOutID {
+		return nil, fmt.Errorf("error during renew: account %q is no longer checked out by this lease", account)
+	}
+
+	resp := &logical.Response{Secret: req.Secret}
+	resp.Secret.TTL = set.TTL
+	resp.Secret.MaxTTL = set.MaxTTL
+	return resp, nil
+}
+
+func (b *databaseBackend) secretLibraryRevoke(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	name, account, checkOutID, err := libraryInternalData(req.Secret.InternalData)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := b.lockLibrarySet(name)
+	defer unlock()
+
+	set, err := b.librarySet(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if set == nil {
+		return nil, nil
+	}
+
+	// The account was already checked in, and possibly checked out again by
+	// someone else, so there is nothing left to revoke.
+	if state := set.Accounts[account]; state == nil || state.CheckOutID != checkOutID {
+		return nil, nil
+	}
+
+	walID, err := b.checkInLibraryAccount(ctx, req.Storage, name, set, account)
+	if err != nil {
+		return nil, err
+	}
+	if err := storeLibrarySet(ctx, req.Storage, name, set); err != nil {
+		return nil, err
+	}
+	b.deleteLibraryWALs(ctx, req.Storage, walID)
+	b.dbEvent(ctx, "library-check-in", "", name, true, "service_account_name", account)
+	return nil, nil
+}
+
+// checkInLibraryAccount rotates the password of a checked out account, so
+// the borrower's copy stops working, and marks the account available. The
+// caller is responsible for storing the set, then deleting the returned WAL.
+func (b *databaseBackend) checkInLibraryAccount(ctx context.Context, s logical.Storage, setName string, set *librarySet, account string) (string, error) {
+	state := set.Accounts[account]
+	walID, err := b.rotateLibraryAccount(ctx, s, setName, set, account, state, "")
+	if err != nil {
+		return "", err
+	}
+
+	state.CheckOutID = ""
+	state.BorrowerEntityID = ""
+	state.BorrowerAccessor = ""
+	state.CheckedOutAt = time.Time{}
+	return walID, nil
+}
+
+// rotateLibraryAccount sets a new password for a library account through the
+// same code path as static role rotations. The account is presented to
+// setStaticAccount as a transient static role that is not stored, and the
+// new password is copied back into the account state. If walID is set, the
+// password of that WAL is set again rather than a new one.
+//
+// The WAL of the rotation is returned, and must only be deleted once the set
+// is stored, so the password is not lost if storing it fails.
+func (b *databaseBackend) rotateLibraryAccount(ctx context.Context, s logical.Storage, setName string, set *librarySet, account string, state *libraryAccount, walID string) (string, error) {
+	role := &roleEntry{
+		DBName: set.DBName,
+		Statements: v4.Statements{
+			Rotation: set.RotationStatements,
+		},
+		CredentialType: v5.CredentialTypePassword,
+		StaticAccount: &staticAccount{
+			Username:          account,
+			Password:          state.Password,
+			LastVaultRotation: state.LastVaultRotation,
+		},
+	}
+
+	output, err := b.setStaticAccount(ctx, s, &setStaticAccountInput{
+		RoleName:       setName,
+		Role:           role,
+		WALID:          walID,
+		LibraryAccount: true,
+	})
+	if err != nil {
+		// The new password was not set in the database, and the next
+		// check-out or check-in sets another one anyway. A WAL that is being
+		// recovered is kept, as its password may already be set.
+		if walID == "" && output != nil && output.WALID != "" {
+			b.deleteLibraryWALs(ctx, s, output.WALID)
+		}
+		return "", fmt.Errorf("unable to rotate password of library account %q: %w", account, err)
+	}
+
+	state.Password = role.StaticAccount.Password
+	state.LastVaultRotation = role.StaticAccount.LastVaultRotation
+	return output.WALID, nil
+}
+
+// deleteLibraryWALs deletes the WALs of library account rotations whose new
+// password is stored. Leftover WALs are recovered on the next initialization.
+func (b *databaseBackend) deleteLibraryWALs(ctx context.Context, s logical.Storage, walIDs ...string) {
+	for _, walID := range walIDs {
+		if err := framework.DeleteWAL(ctx, s, walID); err != nil {
+			b.Logger().Warn("unable to delete WAL", "error", err, "WAL ID", walID)
+		}
+	}
+}
+
+// recoverLibraryWAL handles the WAL of a library account rotation that was
+// interrupted before the new password was stored with the set. The password
+// may or may not have been set in the database, so it is set again and
+// stored. WALs of sets or accounts that no longer exist, or that are older
+// than the stored password, are deleted.
+func (b *databaseBackend) recoverLibraryWAL(ctx context.Context, s logical.Storage, walEntry *setCredentialsWAL) {
+	log := b.Logger().With("set", walEntry.RoleName, "account", walEntry.Username, "WAL ID", walEntry.walID)
+
+	unlock := b.lockLibrarySet(walEntry.RoleName)
+	defer unlock()
+
+	set, err := b.librarySet(ctx, s, walEntry.RoleName)
+	if err != nil {
+		log.Warn("unable to read library set", "error", err)
+		return
+	}
+	if set == nil || !strutil.StrListContains(set.ServiceAccountNames, walEntry.Username) {
+		log.Debug("deleting WAL of missing library set or account")
+		b.deleteLibraryWALs(ctx, s, walEntry.walID)
+		return
+	}
+
+	state := set.Accounts[walEntry.Username]
+	if state == nil {
+		state = &libraryAccount{}
+		set.Accounts[walEntry.Username] = state
+	}
+	if walEntry.LastVaultRotation.Before(state.LastVaultRotation) {
+		log.Debug("deleting outdated WAL")
+		b.deleteLibraryWALs(ctx, s, walEntry.walID)
+		return
+	}
+
+	log.Info("found WAL for library account")
+	walID, err := b.rotateLibraryAccount(ctx, s, walEntry.RoleName, set, walEntry.Username, state, walEntry.walID)
+	if err != nil {
+		log.Warn("unable to recover library account rotation", "error", err)
+		return
+	}
+	if err := storeLibrarySet(ctx, s, walEntry.RoleName, set); err != nil {
+		log.Warn("unable to store library set", "error", err)
+		return
+	}
+	b.deleteLibraryWALs(ctx, s, walID)
+}
+
+func libraryInternalData(internal map[string]interface{}) (name, account, checkOutID string, err error) {
+	name, _ = internal["set_name"].(string)
+	account, _ = internal["service_account_name"].(string)
+	checkOutID, _ = internal["check_out_id"].(string)
+	if name == "" || account == "" || checkOutID == "" {
+		return "", "", "", errors.New("secret is missing library internal data")
+	}
+	return name, account, checkOutID, nil
+}
+
+const pathLibraryCheckOutHelpSyn = `
+Check out an available account of a library set.
+`
+
+const pathLibraryCheckOutHelpDesc = `
+Rotates the password of the first available account of the library set and
+returns it with a lease. The account stays checked out until it is checked
+in or the lease is revoked or expires, at which point its password is rotated
+again.
+`
+
+const pathLibraryCheckInHelpSyn = `
+Check in accounts of a library set checked out by the requester.
+`
+
+const pathLibraryCheckInHelpDesc = `
+Rotates the passwords of the given accounts and makes them available again.
+Unless 'disable_check_in_enforcement' is set on the library set, only the
+entity or token that checked out an account can check it in.
+`
+
+const pathLibraryManageCheckInHelpSyn = `
+Check in any accounts of a library set, regardless of who checked them out.
+`
+
+const pathLibraryManageCheckInHelpDesc = `
+Operator endpoint that rotates the passwords of the given accounts and makes
+them available again without enforcing that the requester checked them out.
+`
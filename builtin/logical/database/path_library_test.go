@@ -0,0 +1,312 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	v5 "github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBackend_Library_CheckOutCheckIn(t *testing.T) {
+	ctx := context.Background()
+	b, storage, mockDB := getBackend(t)
+	defer b.Cleanup(ctx)
+	configureDBMount(t, storage)
+
+	// Every check-out and check-in rotates the password of exactly the
+	// account involved.
+	expectUpdateUser := func(username string) {
+		mockDB.On("UpdateUser", mock.Anything, mock.MatchedBy(func(req v5.UpdateUserRequest) bool {
+			return req.Username == username
+		})).Return(v5.UpdateUserResponse{}, nil).Once()
+	}
+	request := func(op logical.Operation, path, entityID string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			EntityID:  entityID,
+			Data:      data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := request(logical.CreateOperation, "library/svc", "", map[string]interface{}{
+		"db_name":                   mockv5,
+		"service_account_names":     "svc-a,svc-b",
+		"ttl":                       "1h",
+		"max_ttl":                   "2h",
+		"max_check_outs_per_entity": 1,
+	})
+	if resp != nil && resp.IsError() {
+		t.Fatal(resp)
+	}
+
+	// An account can only belong to one set.
+	resp = request(logical.CreateOperation, "library/other", "", map[string]interface{}{
+		"db_name":               mockv5,
+		"service_account_names": "svc-b",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for an account shared between sets, got %v", resp)
+	}
+
+	expectUpdateUser("svc-a")
+	resp = request(logical.UpdateOperation, "library/svc/check-out", "entity-1", nil)
+	if resp == nil || resp.IsError() || resp.Secret == nil {
+		t.Fatalf("unexpected check-out response: %v", resp)
+	}
+	if resp.Data["service_account_name"] != "svc-a" || resp.Data["password"] == "" {
+		t.Fatalf("unexpected check-out data: %v", resp.Data)
+	}
+	secret := resp.Secret
+
+	// The entity has reached its limit of one account.
+	resp = request(logical.UpdateOperation, "library/svc/check-out", "entity-1", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected per-entity limit error, got %v", resp)
+	}
+
+	expectUpdateUser("svc-b")
+	resp = request(logical.UpdateOperation, "library/svc/check-out", "entity-2", nil)
+	if resp == nil || resp.IsError() || resp.Data["service_account_name"] != "svc-b" {
+		t.Fatalf("unexpected check-out response: %v", resp)
+	}
+
+	resp = request(logical.UpdateOperation, "library/svc/check-out", "entity-3", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected no accounts to be available, got %v", resp)
+	}
+
+	resp = request(logical.ReadOperation, "library/svc/status", "", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("unexpected status response: %v", resp)
+	}
+	status := resp.Data["svc-a"].(map[string]interface{})
+	if status["available"] != false || status["borrower_entity_id"] != "entity-1" {
+		t.Fatalf("unexpected status of svc-a: %v", status)
+	}
+
+	// Only the borrower can check in an account.
+	resp = request(logical.UpdateOperation, "library/svc/check-in", "entity-2", map[string]interface{}{
+		"service_account_names": "svc-a",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected check-in enforcement error, got %v", resp)
+	}
+
+	expectUpdateUser("svc-b")
+	resp = request(logical.UpdateOperation, "library/svc/check-in", "entity-2", nil)
+	if resp == nil || resp.IsError() {
+		t.Fatalf("unexpected check-in response: %v", resp)
+	}
+	if checkIns := resp.Data["check_ins"].([]string); len(checkIns) != 1 || checkIns[0] != "svc-b" {
+		t.Fatalf("unexpected check-ins: %v", checkIns)
+	}
+
+	// A set can not be deleted while accounts are checked out.
+	resp = request(logical.DeleteOperation, "library/svc", "", nil)
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error deleting a set with checked out accounts, got %v", resp)
+	}
+
+	// Revoking the lease checks the account back in.
+	expectUpdateUser("svc-a")
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   storage,
+		Secret:    secret,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatal(resp, err)
+	}
+
+	resp = request(logical.ReadOperation, "library/svc/status", "", nil)
+	for _, account := range []string{"svc-a", "svc-b"} {
+		if status := resp.Data[account].(map[string]interface{}); status["available"] != true {
+			t.Fatalf("expected %s to be available, got %v", account, status)
+		}
+	}
+
+	// Revoking the lease again is a no-op, as the account was checked in.
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   storage,
+		Secret:    secret,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatal(resp, err)
+	}
+
+	resp = request(logical.DeleteOperation, "library/svc", "", nil)
+	if resp != nil && resp.IsError() {
+		t.Fatal(resp)
+	}
+	mockDB.AssertNumberOfCalls(t, "UpdateUser", 4)
+}
+
+func TestBackend_Library_Write(t *testing.T) {
+	ctx := context.Background()
+	b, storage, _ := getBackend(t)
+	defer b.Cleanup(ctx)
+
+	entry, err := logical.StorageEntryJSON("config/"+mockv5, &DatabaseConfig{
+		AllowedRoles: []string{"svc-*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(ctx, entry); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, accounts string) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      "library/" + name,
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"db_name":               mockv5,
+				"service_account_names": accounts,
+			},
+		})
+	}
+
+	// The set has to be an allowed role of the connection.
+	resp, err := write("reporting", "svc-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for a set that is not an allowed role, got %v", resp)
+	}
+
+	// Sets written concurrently can not claim the same account.
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	created := make(chan string, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			resp, err := write(name, "svc-shared")
+			switch {
+			case err != nil:
+				errs <- err
+			case resp == nil || !resp.IsError():
+				created <- name
+			}
+		}(fmt.Sprintf("svc-%d", i))
+	}
+	wg.Wait()
+	close(errs)
+	close(created)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if len(created) != 1 {
+		t.Fatalf("expected exactly one set to claim the account, got %d", len(created))
+	}
+}
+
+func TestBackend_Library_WALRecovery(t *testing.T) {
+	ctx := context.Background()
+	b, storage, mockDB := getBackend(t)
+	defer b.Cleanup(ctx)
+	configureDBMount(t, storage)
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "library/svc",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"db_name":               mockv5,
+			"service_account_names": "svc-a,svc-b",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatal(resp, err)
+	}
+
+	// A successful check-out leaves no WAL behind.
+	mockDB.On("UpdateUser", mock.Anything, mock.MatchedBy(func(req v5.UpdateUserRequest) bool {
+		return req.Username == "svc-a"
+	})).Return(v5.UpdateUserResponse{}, nil).Once()
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "library/svc/check-out",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatal(resp, err)
+	}
+	walIDs, err := framework.ListWAL(ctx, storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(walIDs) != 0 {
+		t.Fatalf("expected no WALs after check-out, got %v", walIDs)
+	}
+
+	set, err := b.librarySet(ctx, storage, "svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkedOut := set.Accounts["svc-a"]
+
+	// An interrupted rotation of svc-b is rolled forward, while the WALs of an
+	// outdated rotation of svc-a and of an account that is not in the set are
+	// deleted.
+	for _, wal := range []*setCredentialsWAL{
+		{NewPassword: "recovered", RoleName: "svc", Username: "svc-b"},
+		{NewPassword: "outdated", RoleName: "svc", Username: "svc-a", LastVaultRotation: checkedOut.LastVaultRotation.Add(-time.Minute)},
+		{NewPassword: "unknown", RoleName: "svc", Username: "svc-c"},
+	} {
+		if _, err := framework.PutWAL(ctx, storage, libraryWALKey, wal); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mockDB.On("UpdateUser", mock.Anything, mock.MatchedBy(func(req v5.UpdateUserRequest) bool {
+		return req.Username == "svc-b" && req.Password != nil && req.Password.NewPassword == "recovered"
+	})).Return(v5.UpdateUserResponse{}, nil).Once()
+
+	walMap, err := b.loadStaticWALs(ctx, storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(walMap) != 0 {
+		t.Fatalf("expected library WALs not to be loaded as static WALs, got %v", walMap)
+	}
+
+	walIDs, err = framework.ListWAL(ctx, storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(walIDs) != 0 {
+		t.Fatalf("expected library WALs to be deleted, got %v", walIDs)
+	}
+
+	set, err = b.librarySet(ctx, storage, "svc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Accounts["svc-b"].Password != "recovered" || set.Accounts["svc-b"].LastVaultRotation.IsZero() {
+		t.Fatalf("expected the password of svc-b to be recovered, got %v", set.Accounts["svc-b"])
+	}
+	if set.Accounts["svc-a"].Password != checkedOut.Password || set.Accounts["svc-a"].CheckOutID != checkedOut.CheckOutID {
+		t.Fatalf("expected svc-a to be unchanged, got %v", set.Accounts["svc-a"])
+	}
+	mockDB.AssertNumberOfCalls(t, "UpdateUser", 2)
+}
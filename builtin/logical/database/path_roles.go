@@ -209,6 +209,19 @@ func staticFields() map[string]*framework.FieldSchema {
 			Description: `The window of time in which rotations are allowed to
 	occur starting from a given "rotation_schedule". Requires "rotation_schedule"
 	to be specified`,
+		},
+		"alternate_username": {
+			Type: framework.TypeString,
+			Description: `Name of a second static user account for Vault to
+	manage. When set, each rotation alternates between "username" and this
+	account, so the credential of the previous account stays valid while
+	applications move to the new one.`,
+		},
+		"grace_period": {
+			Type: framework.TypeDurationSecond,
+			Description: `The length of time after a rotation during which the
+	credential of the previous account is still returned. Requires
+	"alternate_username". Defaults to returning it until the next rotation.`,
 		},
 		"rotation_statements": {
 			Type: framework.TypeStringSlice,
@@ -307,6 +320,11 @@ func (b *databaseBackend) pathStaticRoleRead(ctx context.Context, req *logical.R
 	if role.StaticAccount != nil {
 		data["username"] = role.StaticAccount.Username
 		data["rotation_statements"] = role.Statements.Rotation
+		if role.StaticAccount.IsDualAccount() {
+			data["alternate_username"] = role.StaticAccount.AlternateUsername
+			data["active_username"] = role.StaticAccount.CurrentUsername()
+			data["grace_period"] = role.StaticAccount.GracePeriod.Seconds()
+		}
 		if !role.StaticAccount.LastVaultRotation.IsZero() {
 			data["last_vault_rotation"] = role.StaticAccount.LastVaultRotation
 		}
@@ -562,6 +580,28 @@ func (b *databaseBackend) pathStaticRoleCreateUpdate(ctx context.Context, req *l
 	}
 	role.StaticAccount.Username = username
 
+	if alternateUsernameRaw, ok := data.GetOk("alternate_username"); ok {
+		alternateUsername := alternateUsernameRaw.(string)
+		if role.StaticAccount.AlternateUsername != "" && role.StaticAccount.AlternateUsername != alternateUsername {
+			return logical.ErrorResponse("cannot update static account alternate_username"), nil
+		}
+		if alternateUsername == username {
+			return logical.ErrorResponse("alternate_username must differ from username"), nil
+		}
+		role.StaticAccount.AlternateUsername = alternateUsername
+	}
+
+	if gracePeriodRaw, ok := data.GetOk("grace_period"); ok {
+		if !role.StaticAccount.IsDualAccount() {
+			return logical.ErrorResponse("grace_period is only valid with alternate_username"), nil
+		}
+		gracePeriod := time.Duration(gracePeriodRaw.(int)) * time.Second
+		if gracePeriod < 0 {
+			return logical.ErrorResponse("grace_period must not be negative"), nil
+		}
+		role.StaticAccount.GracePeriod = gracePeriod
+	}
+
 	rotationPeriodSecondsRaw, rotationPeriodOk := data.GetOk("rotation_period")
 	rotationScheduleRaw, rotationScheduleOk := data.GetOk("rotation_schedule")
 	rotationWindowSecondsRaw, rotationWindowOk := data.GetOk("rotation_window")
@@ -615,6 +655,12 @@ func (b *databaseBackend) pathStaticRoleCreateUpdate(ctx context.Context, req *l
 		role.StaticAccount.RotationPeriod = 0
 	}
 
+	if role.StaticAccount.UsesRotationPeriod() && role.StaticAccount.GracePeriod > role.StaticAccount.RotationPeriod {
+		// The previous account is rotated again at the next rotation, so its
+		// credential can not be valid for longer than the rotation period.
+		return logical.ErrorResponse("grace_period must not be greater than rotation_period"), nil
+	}
+
 	if rotationStmtsRaw, ok := data.GetOk("rotation_statements"); ok {
 		role.Statements.Rotation = rotationStmtsRaw.([]string)
 	} else if req.Operation == logical.CreateOperation {
@@ -826,6 +872,65 @@ type staticAccount struct {
 	// RevokeUser is a boolean flag to indicate if Vault should revoke the
 	// database user when the role is deleted
 	RevokeUserOnDelete bool `json:"revoke_user_on_delete"`
+
+	// AlternateUsername is the second account of a dual-account static role.
+	// When set, rotations alternate between Username and AlternateUsername,
+	// and Password or PrivateKey hold the credential of the active account.
+	AlternateUsername string `json:"alternate_username,omitempty"`
+
+	// ActiveUsername is the account whose credential is currently returned
+	// for a dual-account static role. Empty means Username.
+	ActiveUsername string `json:"active_username,omitempty"`
+
+	// PreviousUsername, PreviousPassword and PreviousPrivateKey hold the
+	// credential of the account that was active before the last rotation of
+	// a dual-account static role.
+	PreviousUsername   string `json:"previous_username,omitempty"`
+	PreviousPassword   string `json:"previous_password,omitempty"`
+	PreviousPrivateKey []byte `json:"previous_private_key,omitempty"`
+
+	// GracePeriod is the length of time after a rotation during which the
+	// previous credential of a dual-account static role is returned. Zero
+	// means until the next rotation.
+	GracePeriod time.Duration `json:"grace_period,omitempty"`
+}
+
+// IsDualAccount returns true if the static account alternates rotations
+// between two database users.
+func (s *staticAccount) IsDualAccount() bool {
+	return s.AlternateUsername != ""
+}
+
+// CurrentUsername returns the name of the account whose credential is
+// currently returned for the static account.
+func (s *staticAccount) CurrentUsername() string {
+	if s.IsDualAccount() && s.ActiveUsername != "" {
+		return s.ActiveUsername
+	}
+	return s.Username
+}
+
+// RotationUsername returns the name of the account whose credential is set
+// by the next rotation. For dual-account static roles this is the account
+// that is not currently active, except for the very first rotation which
+// sets the credential of Username.
+func (s *staticAccount) RotationUsername() string {
+	if !s.IsDualAccount() || s.LastVaultRotation.IsZero() {
+		return s.Username
+	}
+	if s.CurrentUsername() == s.Username {
+		return s.AlternateUsername
+	}
+	return s.Username
+}
+
+// PreviousCredentialValidUntil returns the end of the grace period of the
+// previous credential of a dual-account static role.
+func (s *staticAccount) PreviousCredentialValidUntil() time.Time {
+	if s.GracePeriod == 0 {
+		return s.NextRotationTime()
+	}
+	return s.LastVaultRotation.Add(s.GracePeriod)
 }
 
 // NextRotationTime calculates the next rotation for period and schedule-based
@@ -887,9 +992,10 @@ func (s *staticAccount) ShouldRotate(priority int64, t time.Time) bool {
 
 // SetNextVaultRotation
 func (s *staticAccount) SetNextVaultRotation(t time.Time) {
-	if s.UsesRotationPeriod() {
+	switch {
+	case s.UsesRotationPeriod():
 		s.NextVaultRotation = t.Add(s.RotationPeriod)
-	} else {
+	case s.UsesRotationSchedule():
 		s.NextVaultRotation = s.Schedule.Next(t)
 	}
 }
@@ -27,6 +27,9 @@ const (
 
 	// WAL storage key used for static account rotations
 	staticWALKey = "staticRotationKey"
+
+	// WAL storage key used for library account rotations
+	libraryWALKey = "libraryRotationKey"
 )
 
 // populateQueue loads the priority queue with existing static accounts. This
@@ -308,12 +311,18 @@ func (b *databaseBackend) rotateCredential(ctx context.Context, s logical.Storag
 // findStaticWAL loads a WAL entry by ID. If found, only return the WAL if it
 // is of type staticWALKey, otherwise return nil
 func (b *databaseBackend) findStaticWAL(ctx context.Context, s logical.Storage, id string) (*setCredentialsWAL, error) {
+	return b.findCredentialsWAL(ctx, s, id, staticWALKey)
+}
+
+// findCredentialsWAL loads a WAL entry by ID. If found, only return the WAL if
+// it is of the given kind, otherwise return nil
+func (b *databaseBackend) findCredentialsWAL(ctx context.Context, s logical.Storage, id, kind string) (*setCredentialsWAL, error) {
 	wal, err := framework.GetWAL(ctx, s, id)
 	if err != nil {
 		return nil, err
 	}
 
-	if wal == nil || wal.Kind != staticWALKey {
+	if wal == nil || wal.Kind != kind {
 		return nil, nil
 	}
 
@@ -338,6 +347,13 @@ type setStaticAccountInput struct {
 	RoleName string
 	Role     *roleEntry
 	WALID    string
+
+	// LibraryAccount is set when rotating the account of a library set, which
+	// is presented as a transient static role named after the set. The role is
+	// not stored and the WAL, of kind libraryWALKey, is not deleted: the caller
+	// stores the new password with the set, then deletes the WAL returned in
+	// the output. No static-creds events are sent for it.
+	LibraryAccount bool
 }
 
 type setStaticAccountOutput struct {
@@ -367,6 +383,9 @@ func (b *databaseBackend) setStaticAccount(ctx context.Context, s logical.Storag
 	}
 	modified := false
 	defer func() {
+		if input.LibraryAccount {
+			return
+		}
 		if err == nil {
 			b.dbEvent(ctx, "static-creds-create", "", input.RoleName, modified)
 		} else {
@@ -376,6 +395,10 @@ func (b *databaseBackend) setStaticAccount(ctx context.Context, s logical.Storag
 
 	// Re-use WAL ID if present, otherwise PUT a new WAL
 	output := &setStaticAccountOutput{WALID: input.WALID}
+	walKind := staticWALKey
+	if input.LibraryAccount {
+		walKind = libraryWALKey
+	}
 
 	dbConfig, err := b.DatabaseConfig(ctx, s, input.Role.DBName)
 	if err != nil {
@@ -406,8 +429,16 @@ func (b *databaseBackend) setStaticAccount(ctx context.Context, s logical.Storag
 	dbi.RLock()
 	defer dbi.RUnlock()
 
+	// Dual-account roles set the credential of the inactive account, and keep
+	// the credential of the active one around as the previous credential.
+	rotationUsername := input.Role.StaticAccount.RotationUsername()
+	prevUsername := input.Role.StaticAccount.CurrentUsername()
+	prevPassword := input.Role.StaticAccount.Password
+	prevPrivateKey := input.Role.StaticAccount.PrivateKey
+	hadCredential := !input.Role.StaticAccount.LastVaultRotation.IsZero()
+
 	updateReq := v5.UpdateUserRequest{
-		Username: input.Role.StaticAccount.Username,
+		Username: rotationUsername,
 	}
 	statements := v5.Statements{
 		Commands: input.Role.Statements.Rotation,
@@ -417,7 +448,7 @@ func (b *databaseBackend) setStaticAccount(ctx context.Context, s logical.Storag
 	// a WAL item or processing the rotation queue with an item that has a WAL
 	// associated with it
 	if output.WALID != "" {
-		wal, err := b.findStaticWAL(ctx, s, output.WALID)
+		wal, err := b.findCredentialsWAL(ctx, s, output.WALID, walKind)
 		if err != nil {
 			return output, fmt.Errorf("error retrieving WAL entry: %w", err)
 		}
@@ -458,7 +489,7 @@ func (b *databaseBackend) setStaticAccount(ctx context.Context, s logical.Storag
 	if output.WALID == "" {
 		walEntry := &setCredentialsWAL{
 			RoleName:          input.RoleName,
-			Username:          input.Role.StaticAccount.Username,
+			Username:          rotationUsername,
 			LastVaultRotation: input.Role.StaticAccount.LastVaultRotation,
 		}
 
@@ -515,7 +546,7 @@ func (b *databaseBackend) setStaticAccount(ctx context.Context, s logical.Storag
 			input.Role.StaticAccount.PrivateKey = private
 		}
 
-		output.WALID, err = framework.PutWAL(ctx, s, staticWALKey, walEntry)
+		output.WALID, err = framework.PutWAL(ctx, s, walKind, walEntry)
 		if err != nil {
 			return output, fmt.Errorf("error writing WAL entry: %w", err)
 		}
@@ -529,6 +560,15 @@ func (b *databaseBackend) setStaticAccount(ctx context.Context, s logical.Storag
 	}
 	modified = true
 
+	if input.Role.StaticAccount.IsDualAccount() {
+		input.Role.StaticAccount.ActiveUsername = rotationUsername
+		if hadCredential && prevUsername != rotationUsername {
+			input.Role.StaticAccount.PreviousUsername = prevUsername
+			input.Role.StaticAccount.PreviousPassword = prevPassword
+			input.Role.StaticAccount.PreviousPrivateKey = prevPrivateKey
+		}
+	}
+
 	// Store updated role information
 	// lvr is the known LastVaultRotation
 	lvr := time.Now()
@@ -536,6 +576,12 @@ func (b *databaseBackend) setStaticAccount(ctx context.Context, s logical.Storag
 	input.Role.StaticAccount.SetNextVaultRotation(lvr)
 	output.RotationTime = lvr
 
+	// The WAL of a library account is only deleted by the caller once the new
+	// password is stored with the set
+	if input.LibraryAccount {
+		return output, nil
+	}
+
 	entry, err := logical.StorageEntryJSON(databaseStaticRolePath+input.RoleName, input.Role)
 	if err != nil {
 		return output, err
@@ -618,7 +664,8 @@ func (b *databaseBackend) initQueue(ctx context.Context, conf *logical.BackendCo
 }
 
 // loadStaticWALs reads WAL entries and returns a map of roles and their
-// setCredentialsWAL, if found.
+// setCredentialsWAL, if found. WAL entries of library account rotations are
+// recovered on the way.
 func (b *databaseBackend) loadStaticWALs(ctx context.Context, s logical.Storage) (map[string]*setCredentialsWAL, error) {
 	keys, err := framework.ListWAL(ctx, s)
 	if err != nil {
@@ -638,6 +685,16 @@ func (b *databaseBackend) loadStaticWALs(ctx context.Context, s logical.Storage)
 			continue
 		}
 		if walEntry == nil {
+			// Library account rotations have their own kind of WAL, as their
+			// role name is the name of a library set rather than a static role.
+			libraryWAL, err := b.findCredentialsWAL(ctx, s, walID, libraryWALKey)
+			if err != nil {
+				b.Logger().Error("error loading library WAL", "id", walID, "error", err)
+				continue
+			}
+			if libraryWAL != nil {
+				b.recoverLibraryWAL(ctx, s, libraryWAL)
+			}
 			continue
 		}
 
@@ -1806,3 +1806,120 @@ func (d *TestSchedule) ValidateRotationWindow(s int) error {
 	}
 	return nil
 }
+
+func TestBackend_StaticRole_Rotation_DualAccount(t *testing.T) {
+	ctx := context.Background()
+	b, storage, mockDB := getBackend(t)
+	defer b.Cleanup(ctx)
+	configureDBMount(t, storage)
+
+	// UpdateUser calls for any other username than the expected one fail the
+	// test, as the mock has no matching expectation for them.
+	expectUpdateUser := func(username string) {
+		mockDB.On("UpdateUser", mock.Anything, mock.MatchedBy(func(req v5.UpdateUserRequest) bool {
+			return req.Username == username
+		})).Return(v5.UpdateUserResponse{}, nil).Once()
+	}
+	rotate := func(username string) {
+		t.Helper()
+		expectUpdateUser(username)
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "rotate-role/hashicorp",
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatal(resp, err)
+		}
+	}
+	readCreds := func() map[string]interface{} {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "static-creds/hashicorp",
+			Storage:   storage,
+		})
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatal(resp, err)
+		}
+		return resp.Data
+	}
+
+	// Creating the role sets the credential of the primary account only.
+	expectUpdateUser("blue")
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "static-roles/hashicorp",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"username":           "blue",
+			"alternate_username": "green",
+			"grace_period":       "1h",
+			"db_name":            mockv5,
+			"rotation_period":    "86400s",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatal(resp, err)
+	}
+
+	creds := readCreds()
+	if creds["username"] != "blue" {
+		t.Fatalf("expected active username blue, got %v", creds["username"])
+	}
+	if _, ok := creds["previous_username"]; ok {
+		t.Fatalf("expected no previous credential before the first rotation, got %v", creds)
+	}
+	bluePassword := creds["password"]
+
+	// Each rotation moves to the other account and keeps the previous one.
+	rotate("green")
+
+	creds = readCreds()
+	if creds["username"] != "green" || creds["previous_username"] != "blue" {
+		t.Fatalf("unexpected usernames after first rotation: %v", creds)
+	}
+	if creds["previous_password"] != bluePassword || creds["password"] == bluePassword {
+		t.Fatalf("unexpected passwords after first rotation: %v", creds)
+	}
+	if ttl := creds["previous_ttl"].(float64); ttl <= 0 || ttl > 3600 {
+		t.Fatalf("unexpected previous_ttl %v", ttl)
+	}
+	greenPassword := creds["password"]
+
+	rotate("blue")
+
+	creds = readCreds()
+	if creds["username"] != "blue" || creds["previous_username"] != "green" || creds["previous_password"] != greenPassword {
+		t.Fatalf("unexpected credentials after second rotation: %v", creds)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "static-roles/hashicorp",
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatal(resp, err)
+	}
+	if resp.Data["alternate_username"] != "green" || resp.Data["active_username"] != "blue" || resp.Data["grace_period"] != float64(3600) {
+		t.Fatalf("unexpected role data: %v", resp.Data)
+	}
+
+	// The grace period can not outlast the rotation period, and the
+	// alternate account can not be changed.
+	for _, data := range []map[string]interface{}{
+		{"username": "blue", "grace_period": "172800s"},
+		{"username": "blue", "alternate_username": "red"},
+	} {
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "static-roles/hashicorp",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error response for %v, got %v, %v", data, resp, err)
+		}
+	}
+}
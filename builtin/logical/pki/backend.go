@@ -5,6 +5,7 @@ package pki
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -804,3 +805,28 @@ func (b *backend) initializeStoredCertificateCounts(ctx context.Context) error {
 	certCounter.InitializeCountsFromStorage(entries, revokedEntries)
 	return nil
 }
+
+// pkiEvent sends an event of the operation on the given path, so that the
+// consumers of the responses of the mount, such as the static secret cache of
+// Vault Proxy, can refresh them.
+func (b *backend) pkiEvent(ctx context.Context, operation string, path string) {
+	err := logical.SendEvent(ctx, b, fmt.Sprintf("pki/%s", operation),
+		logical.EventMetadataModified, "true",
+		logical.EventMetadataOperation, operation,
+		"path", path,
+	)
+	if err != nil && !errors.Is(err, framework.ErrNoEvents) {
+		b.Logger().Error("Error sending event", "error", err)
+	}
+}
+
+// withEvent sends an event of the operation once the handler succeeds.
+func (b *backend) withEvent(operation string, handler framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		resp, err := handler(ctx, req, data)
+		if err == nil && !resp.IsError() {
+			b.pkiEvent(ctx, operation, req.Path)
+		}
+		return resp, err
+	}
+}
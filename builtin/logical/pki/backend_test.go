@@ -7165,3 +7165,43 @@ var (
 	edCAKey   string
 	edCACert  string
 )
+
+// TestBackend_Events tests that the changes to the issuers, roles and CRLs of
+// the mount send events, and that failed requests do not.
+func TestBackend_Events(t *testing.T) {
+	t.Parallel()
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	eventSender := logical.NewMockEventSender()
+	config.EventsSender = eventSender
+	b := Backend(config)
+	require.NoError(t, b.Setup(context.Background(), config))
+	b.pkiStorageVersion.Store(1)
+	s := config.StorageView
+
+	_, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "example.com",
+	})
+	require.NoError(t, err)
+	_, err = CBWrite(b, s, "roles/example", map[string]interface{}{
+		"allowed_domains": "example.com",
+	})
+	require.NoError(t, err)
+	_, err = CBWrite(b, s, "roles/example", map[string]interface{}{
+		"key_type": "fly",
+	})
+	require.Error(t, err)
+	_, err = CBRead(b, s, "crl/rotate")
+	require.NoError(t, err)
+	_, err = CBDelete(b, s, "roles/example")
+	require.NoError(t, err)
+
+	var types []string
+	for _, event := range eventSender.Events {
+		types = append(types, string(event.Type))
+	}
+	require.Equal(t, []string{"pki/root-generate", "pki/role-write", "pki/crl-rotate", "pki/role-delete"}, types)
+	metadata := eventSender.Events[1].Event.Metadata.AsMap()
+	require.Equal(t, "roles/example", metadata["path"])
+	require.Equal(t, "true", metadata[logical.EventMetadataModified])
+}
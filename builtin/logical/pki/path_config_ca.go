@@ -32,7 +32,7 @@ secret key and certificate.`,
 
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathImportIssuers,
+				Callback: b.withEvent("issuer-import", b.pathImportIssuers),
 				Responses: map[int][]framework.Response{
 					http.StatusOK: {{
 						Description: "OK",
@@ -132,7 +132,7 @@ func pathConfigIssuers(b *backend) *framework.Path {
 				},
 			},
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathCAIssuersWrite,
+				Callback: b.withEvent("config-issuers-write", b.pathCAIssuersWrite),
 				DisplayAttrs: &framework.DisplayAttributes{
 					OperationVerb:   "configure",
 					OperationSuffix: "issuers",
@@ -183,7 +183,7 @@ func pathReplaceRoot(b *backend) *framework.Path {
 
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathCAIssuersWrite,
+				Callback: b.withEvent("config-issuers-write", b.pathCAIssuersWrite),
 				Responses: map[int][]framework.Response{
 					http.StatusOK: {{
 						Description: "OK",
@@ -324,7 +324,7 @@ func pathConfigKeys(b *backend) *framework.Path {
 
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathKeyDefaultWrite,
+				Callback: b.withEvent("config-keys-write", b.pathKeyDefaultWrite),
 				DisplayAttrs: &framework.DisplayAttributes{
 					OperationVerb:   "configure",
 					OperationSuffix: "keys",
@@ -192,7 +192,7 @@ existing CRL and OCSP paths will return the unified CRL instead of a response ba
 				},
 			},
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathCRLWrite,
+				Callback: b.withEvent("config-crl-write", b.pathCRLWrite),
 				DisplayAttrs: &framework.DisplayAttributes{
 					OperationVerb:   "configure",
 					OperationSuffix: "crl",
@@ -59,7 +59,7 @@ to be set on all PR secondary clusters.`,
 					OperationVerb:   "configure",
 					OperationSuffix: "urls",
 				},
-				Callback: b.pathWriteURL,
+				Callback: b.withEvent("config-urls-write", b.pathWriteURL),
 				Responses: map[int][]framework.Response{
 					http.StatusOK: {{
 						Description: "OK",
@@ -308,7 +308,7 @@ to be set on all PR secondary clusters.`,
 				Responses: updateIssuerSchema,
 			},
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback:  b.pathUpdateIssuer,
+				Callback:  b.withEvent("issuer-write", b.pathUpdateIssuer),
 				Responses: updateIssuerSchema,
 
 				// Read more about why these flags are set in backend.go.
@@ -316,7 +316,7 @@ to be set on all PR secondary clusters.`,
 				ForwardPerformanceSecondary: true,
 			},
 			logical.DeleteOperation: &framework.PathOperation{
-				Callback: b.pathDeleteIssuer,
+				Callback: b.withEvent("issuer-delete", b.pathDeleteIssuer),
 				Responses: map[int][]framework.Response{
 					http.StatusNoContent: {{
 						Description: "No Content",
@@ -327,7 +327,7 @@ to be set on all PR secondary clusters.`,
 				ForwardPerformanceSecondary: true,
 			},
 			logical.PatchOperation: &framework.PathOperation{
-				Callback:  b.pathPatchIssuer,
+				Callback:  b.withEvent("issuer-patch", b.pathPatchIssuer),
 				Responses: updateIssuerSchema,
 				// Read more about why these flags are set in backend.go.
 				ForwardPerformanceStandby:   true,
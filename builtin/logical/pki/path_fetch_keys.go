@@ -171,7 +171,7 @@ func buildPathKey(b *backend, pattern string, displayAttrs *framework.DisplayAtt
 				ForwardPerformanceSecondary: false,
 			},
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathUpdateKeyHandler,
+				Callback: b.withEvent("key-write", b.pathUpdateKeyHandler),
 				Responses: map[int][]framework.Response{
 					http.StatusNoContent: {{
 						Description: "OK",
@@ -198,7 +198,7 @@ func buildPathKey(b *backend, pattern string, displayAttrs *framework.DisplayAtt
 				ForwardPerformanceSecondary: true,
 			},
 			logical.DeleteOperation: &framework.PathOperation{
-				Callback: b.pathDeleteKeyHandler,
+				Callback: b.withEvent("key-delete", b.pathDeleteKeyHandler),
 				Responses: map[int][]framework.Response{
 					http.StatusNoContent: {{
 						Description: "No Content",
@@ -48,7 +48,7 @@ appended to the bundle.`,
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathImportIssuers,
+				Callback: b.withEvent("issuer-import", b.pathImportIssuers),
 				Responses: map[int][]framework.Response{
 					http.StatusOK: {{
 						Description: "OK",
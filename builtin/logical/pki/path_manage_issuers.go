@@ -50,7 +50,7 @@ func buildPathGenerateRoot(b *backend, pattern string, displayAttrs *framework.D
 
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathCAGenerateRoot,
+				Callback: b.withEvent("root-generate", b.pathCAGenerateRoot),
 				Responses: map[int][]framework.Response{
 					http.StatusOK: {{
 						Description: "OK",
@@ -224,7 +224,7 @@ secret-key (optional) and certificates.`,
 
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathImportIssuers,
+				Callback: b.withEvent("issuer-import", b.pathImportIssuers),
 				Responses: map[int][]framework.Response{
 					http.StatusOK: {{
 						Description: "OK",
@@ -527,7 +527,7 @@ func pathRevokeIssuer(b *backend) *framework.Path {
 
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathRevokeIssuer,
+				Callback: b.withEvent("issuer-revoke", b.pathRevokeIssuer),
 				Responses: map[int][]framework.Response{
 					http.StatusOK: {{
 						Description: "OK",
@@ -91,7 +91,7 @@ signed by an issuer in this mount.`,
 
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.metricsWrap("revoke", noRole, b.pathRevokeWrite),
+				Callback: b.withEvent("revoke", b.metricsWrap("revoke", noRole, b.pathRevokeWrite)),
 				// This should never be forwarded. See backend.go for more information.
 				// If this needs to write, the entire request will be forwarded to the
 				// active node of the current performance cluster, but we don't want to
@@ -156,7 +156,7 @@ be in PEM format.`,
 
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.metricsWrap("revoke", noRole, b.pathRevokeWrite),
+				Callback: b.withEvent("revoke", b.metricsWrap("revoke", noRole, b.pathRevokeWrite)),
 				// This should never be forwarded. See backend.go for more information.
 				// If this needs to write, the entire request will be forwarded to the
 				// active node of the current performance cluster, but we don't want to
@@ -203,7 +203,7 @@ func pathRotateCRL(b *backend) *framework.Path {
 
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ReadOperation: &framework.PathOperation{
-				Callback: b.pathRotateCRLRead,
+				Callback: b.withEvent("crl-rotate", b.pathRotateCRLRead),
 				// See backend.go; we will read a lot of data prior to calling write,
 				// so this request should be forwarded when it is first seen, not
 				// when it is ready to write.
@@ -240,7 +240,7 @@ func pathRotateDeltaCRL(b *backend) *framework.Path {
 
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ReadOperation: &framework.PathOperation{
-				Callback: b.pathRotateDeltaCRLRead,
+				Callback: b.withEvent("crl-rotate-delta", b.pathRotateDeltaCRLRead),
 				// See backend.go; we will read a lot of data prior to calling write,
 				// so this request should be forwarded when it is first seen, not
 				// when it is ready to write.
@@ -820,7 +820,7 @@ serviced by this role.`,
 				},
 			},
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathRoleCreate,
+				Callback: b.withEvent("role-write", b.pathRoleCreate),
 				Responses: map[int][]framework.Response{
 					http.StatusOK: {{
 						Description: "OK",
@@ -832,7 +832,7 @@ serviced by this role.`,
 				ForwardPerformanceSecondary: true,
 			},
 			logical.DeleteOperation: &framework.PathOperation{
-				Callback: b.pathRoleDelete,
+				Callback: b.withEvent("role-delete", b.pathRoleDelete),
 				Responses: map[int][]framework.Response{
 					http.StatusNoContent: {{
 						Description: "No Content",
@@ -843,7 +843,7 @@ serviced by this role.`,
 				ForwardPerformanceSecondary: true,
 			},
 			logical.PatchOperation: &framework.PathOperation{
-				Callback: b.pathRolePatch,
+				Callback: b.withEvent("role-patch", b.pathRolePatch),
 				Responses: map[int][]framework.Response{
 					http.StatusOK: {{
 						Description: "OK",
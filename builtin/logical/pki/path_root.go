@@ -55,7 +55,7 @@ func pathDeleteRoot(b *backend) *framework.Path {
 
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.DeleteOperation: &framework.PathOperation{
-				Callback: b.pathCADeleteRoot,
+				Callback: b.withEvent("root-delete", b.pathCADeleteRoot),
 				Responses: map[int][]framework.Response{
 					http.StatusOK: {{
 						Description: "OK",
@@ -47,6 +47,7 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 
 			LocalStorage: []string{
 				"otp/",
+				certsStoragePrefix,
 			},
 
 			SealWrapStorage: []string{
@@ -68,6 +69,10 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			pathIssue(&b),
 			pathFetchPublicKey(&b),
 			pathCleanupKeys(&b),
+			pathListCerts(&b),
+			pathFetchCert(&b),
+			pathSearchCerts(&b),
+			pathTidyCerts(&b),
 		},
 
 		Secrets: []*framework.Secret{
@@ -2517,6 +2517,107 @@ func TestBackend_CleanupDynamicHostKeys(t *testing.T) {
 	require.Contains(t, resp.Data["message"], "0 of 0")
 }
 
+func TestBackend_StoreCertificates(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Backend(config)
+	require.NoError(t, err)
+	require.NoError(t, b.Setup(context.Background(), config))
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:   op,
+			Path:        path,
+			Data:        data,
+			Storage:     config.StorageView,
+			EntityID:    "test-entity",
+			DisplayName: "test-user",
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "unexpected error response: %v", resp)
+		return resp
+	}
+
+	request(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"public_key":  testCAPublicKey,
+		"private_key": testCAPrivateKey,
+	})
+	request(logical.UpdateOperation, "roles/recorded", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+		"store_certificates":      true,
+	})
+	request(logical.UpdateOperation, "roles/unrecorded", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+	})
+
+	resp := request(logical.ReadOperation, "roles/recorded", nil)
+	require.Equal(t, true, resp.Data["store_certificates"])
+
+	// Certificates issued under a role without store_certificates are not
+	// recorded.
+	request(logical.UpdateOperation, "sign/unrecorded", map[string]interface{}{
+		"public_key":       testCAPublicKey,
+		"valid_principals": "root",
+	})
+	resp = request(logical.ListOperation, "certs/", nil)
+	require.Empty(t, resp.Data["keys"])
+
+	rootResp := request(logical.UpdateOperation, "sign/recorded", map[string]interface{}{
+		"public_key":       testCAPublicKey,
+		"valid_principals": "root,admin",
+	})
+	rootSerial := rootResp.Data["serial_number"].(string)
+	issueResp := request(logical.UpdateOperation, "issue/recorded", map[string]interface{}{
+		"key_type":         "ed25519",
+		"valid_principals": "deploy",
+		"ttl":              "1s",
+	})
+	deploySerial := issueResp.Data["serial_number"].(string)
+
+	resp = request(logical.ListOperation, "certs/", nil)
+	require.ElementsMatch(t, []string{rootSerial, deploySerial}, resp.Data["keys"])
+
+	resp = request(logical.ReadOperation, "cert/"+rootSerial, nil)
+	require.Equal(t, rootSerial, resp.Data["serial_number"])
+	require.ElementsMatch(t, []string{"root", "admin"}, resp.Data["valid_principals"])
+	require.Equal(t, "recorded", resp.Data["role"])
+	require.Equal(t, "test-entity", resp.Data["entity_id"])
+	require.Equal(t, "user", resp.Data["cert_type"])
+	require.Equal(t, rootResp.Data["signed_key"], resp.Data["signed_key"])
+
+	resp = request(logical.UpdateOperation, "certs/search", map[string]interface{}{
+		"principal": "root",
+	})
+	require.Equal(t, []string{rootSerial}, resp.Data["keys"])
+
+	resp = request(logical.UpdateOperation, "certs/search", map[string]interface{}{
+		"entity_id":    "test-entity",
+		"issued_after": time.Now().Add(-time.Hour).Format(time.RFC3339),
+	})
+	require.ElementsMatch(t, []string{rootSerial, deploySerial}, resp.Data["keys"])
+
+	resp = request(logical.UpdateOperation, "certs/search", map[string]interface{}{
+		"role": "unrecorded",
+	})
+	require.Empty(t, resp.Data["keys"])
+
+	// Only the expired certificate is removed by tidy.
+	time.Sleep(2 * time.Second)
+	resp = request(logical.UpdateOperation, "tidy/certs", map[string]interface{}{
+		"safety_buffer": "0s",
+	})
+	require.Equal(t, 1, resp.Data["removed_count"])
+
+	resp = request(logical.ListOperation, "certs/", nil)
+	require.Equal(t, []string{rootSerial}, resp.Data["keys"])
+}
+
 type pathAuthCheckerFunc func(t *testing.T, client *api.Client, path string, token string)
 
 func isPermDenied(err error) bool {
@@ -2768,6 +2869,10 @@ func TestProperAuthing(t *testing.T) {
 		"roles/":             shouldBeAuthed,
 		"sign/test-ca":       shouldBeAuthed,
 		"tidy/dynamic-keys":  shouldBeAuthed,
+		"tidy/certs":         shouldBeAuthed,
+		"certs/":             shouldBeAuthed,
+		"certs/search":       shouldBeAuthed,
+		"cert/1a2b":          shouldBeAuthed,
 		"verify":             shouldBeUnauthedWriteOnly,
 	}
 	for path, checkerType := range paths {
@@ -2806,6 +2911,9 @@ func TestProperAuthing(t *testing.T) {
 		if strings.Contains(raw_path, "{role}") && strings.Contains(raw_path, "creds") {
 			raw_path = strings.ReplaceAll(raw_path, "{role}", "test-otp")
 		}
+		if strings.Contains(raw_path, "{serial}") {
+			raw_path = strings.ReplaceAll(raw_path, "{serial}", "1a2b")
+		}
 
 		handler, present := paths[raw_path]
 		if !present {
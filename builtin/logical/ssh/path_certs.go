@@ -0,0 +1,332 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+const certsStoragePrefix = "certs/"
+
+// issuedCertEntry records the metadata of a certificate signed by this
+// mount. It is only persisted when the issuing role has store_certificates
+// set, and exists so operators can answer after the fact who was issued
+// which principals and when.
+type issuedCertEntry struct {
+	SerialNumber         string    `json:"serial_number"`
+	KeyID                string    `json:"key_id"`
+	CertType             string    `json:"cert_type"`
+	ValidPrincipals      []string  `json:"valid_principals"`
+	ValidAfter           time.Time `json:"valid_after"`
+	ValidBefore          time.Time `json:"valid_before"`
+	IssuedAt             time.Time `json:"issued_at"`
+	Role                 string    `json:"role"`
+	EntityID             string    `json:"entity_id"`
+	DisplayName          string    `json:"display_name"`
+	PublicKeyFingerprint string    `json:"public_key_fingerprint"`
+	SignedKey            string    `json:"signed_key"`
+}
+
+func (e *issuedCertEntry) toResponseData() map[string]interface{} {
+	return map[string]interface{}{
+		"serial_number":          e.SerialNumber,
+		"key_id":                 e.KeyID,
+		"cert_type":              e.CertType,
+		"valid_principals":       e.ValidPrincipals,
+		"valid_after":            e.ValidAfter.Format(time.RFC3339),
+		"valid_before":           e.ValidBefore.Format(time.RFC3339),
+		"issued_at":              e.IssuedAt.Format(time.RFC3339),
+		"role":                   e.Role,
+		"entity_id":              e.EntityID,
+		"display_name":           e.DisplayName,
+		"public_key_fingerprint": e.PublicKeyFingerprint,
+		"signed_key":             e.SignedKey,
+	}
+}
+
+func pathListCerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "certs/?$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationSuffix: "certificates",
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathCertList,
+		},
+
+		HelpSynopsis:    pathCertsHelpSyn,
+		HelpDescription: pathCertsHelpDesc,
+	}
+}
+
+func pathFetchCert(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `cert/(?P<serial>[0-9A-Fa-f-:]+)`,
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationSuffix: "certificate",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"serial": {
+				Type:        framework.TypeString,
+				Description: `Serial number of the certificate, in hexadecimal as returned by the sign and issue endpoints.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathCertRead,
+		},
+
+		HelpSynopsis:    pathCertsHelpSyn,
+		HelpDescription: pathCertsHelpDesc,
+	}
+}
+
+func pathSearchCerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "certs/search",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationVerb:   "search",
+			OperationSuffix: "certificates",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"principal": {
+				Type:        framework.TypeString,
+				Description: `Only return certificates that were issued for this principal.`,
+			},
+			"key_id": {
+				Type:        framework.TypeString,
+				Description: `Only return certificates with this key ID.`,
+			},
+			"role": {
+				Type:        framework.TypeString,
+				Description: `Only return certificates issued by this role.`,
+			},
+			"entity_id": {
+				Type:        framework.TypeString,
+				Description: `Only return certificates requested by this identity entity.`,
+			},
+			"cert_type": {
+				Type:          framework.TypeString,
+				Description:   `Only return certificates of this type; either "user" or "host".`,
+				AllowedValues: []interface{}{"", "user", "host"},
+			},
+			"issued_after": {
+				Type:        framework.TypeTime,
+				Description: `Only return certificates issued at or after this time, as an RFC3339 timestamp or Unix epoch seconds.`,
+			},
+			"issued_before": {
+				Type:        framework.TypeTime,
+				Description: `Only return certificates issued before this time, as an RFC3339 timestamp or Unix epoch seconds.`,
+			},
+			"valid_at": {
+				Type:        framework.TypeTime,
+				Description: `Only return certificates whose validity period includes this time, as an RFC3339 timestamp or Unix epoch seconds.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCertSearch,
+		},
+
+		HelpSynopsis:    pathCertsSearchHelpSyn,
+		HelpDescription: pathCertsSearchHelpDesc,
+	}
+}
+
+// normalizeSerial converts a user-supplied serial number into the storage
+// form, which is the lower case hexadecimal encoding without separators or
+// leading zeros, matching the serial_number returned on signing.
+func normalizeSerial(serial string) (string, error) {
+	serial = strings.NewReplacer(":", "", "-", "").Replace(serial)
+	parsed, err := strconv.ParseUint(serial, 16, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid serial number %q", serial)
+	}
+	return strconv.FormatUint(parsed, 16), nil
+}
+
+// storeIssuedCert persists the metadata of a freshly signed certificate.
+func (b *backend) storeIssuedCert(ctx context.Context, req *logical.Request, roleName string, cert *ssh.Certificate, signedKey string) error {
+	certType := "user"
+	if cert.CertType == ssh.HostCert {
+		certType = "host"
+	}
+
+	entry := &issuedCertEntry{
+		SerialNumber:         strconv.FormatUint(cert.Serial, 16),
+		KeyID:                cert.KeyId,
+		CertType:             certType,
+		ValidPrincipals:      cert.ValidPrincipals,
+		ValidAfter:           time.Unix(int64(cert.ValidAfter), 0).UTC(),
+		ValidBefore:          time.Unix(int64(cert.ValidBefore), 0).UTC(),
+		IssuedAt:             time.Now().UTC(),
+		Role:                 roleName,
+		EntityID:             req.EntityID,
+		DisplayName:          req.DisplayName,
+		PublicKeyFingerprint: ssh.FingerprintSHA256(cert.Key),
+		SignedKey:            signedKey,
+	}
+
+	storageEntry, err := logical.StorageEntryJSON(certsStoragePrefix+entry.SerialNumber, entry)
+	if err != nil {
+		return err
+	}
+	return req.Storage.Put(ctx, storageEntry)
+}
+
+func getIssuedCert(ctx context.Context, s logical.Storage, serial string) (*issuedCertEntry, error) {
+	entry, err := s.Get(ctx, certsStoragePrefix+serial)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result issuedCertEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (b *backend) pathCertList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	entries, err := req.Storage.List(ctx, certsStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+	return logical.ListResponse(entries), nil
+}
+
+func (b *backend) pathCertRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	serial, err := normalizeSerial(d.Get("serial").(string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	entry, err := getIssuedCert(ctx, req.Storage, serial)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: entry.toResponseData(),
+	}, nil
+}
+
+func (b *backend) pathCertSearch(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	principal := d.Get("principal").(string)
+	keyID := d.Get("key_id").(string)
+	roleName := d.Get("role").(string)
+	entityID := d.Get("entity_id").(string)
+	certType := d.Get("cert_type").(string)
+
+	var issuedAfter, issuedBefore, validAt time.Time
+	if raw, ok := d.GetOk("issued_after"); ok {
+		issuedAfter = raw.(time.Time)
+	}
+	if raw, ok := d.GetOk("issued_before"); ok {
+		issuedBefore = raw.(time.Time)
+	}
+	if raw, ok := d.GetOk("valid_at"); ok {
+		validAt = raw.(time.Time)
+	}
+
+	serials, err := req.Storage.List(ctx, certsStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	keyInfo := map[string]interface{}{}
+	for _, serial := range serials {
+		entry, err := getIssuedCert(ctx, req.Storage, serial)
+		if err != nil {
+			return nil, fmt.Errorf("error reading certificate %q: %w", serial, err)
+		}
+		if entry == nil {
+			continue
+		}
+
+		switch {
+		case principal != "" && !strutil.StrListContains(entry.ValidPrincipals, principal):
+			continue
+		case keyID != "" && entry.KeyID != keyID:
+			continue
+		case roleName != "" && entry.Role != roleName:
+			continue
+		case entityID != "" && entry.EntityID != entityID:
+			continue
+		case certType != "" && entry.CertType != certType:
+			continue
+		case !issuedAfter.IsZero() && entry.IssuedAt.Before(issuedAfter):
+			continue
+		case !issuedBefore.IsZero() && !entry.IssuedAt.Before(issuedBefore):
+			continue
+		case !validAt.IsZero() && (validAt.Before(entry.ValidAfter) || validAt.After(entry.ValidBefore)):
+			continue
+		}
+
+		matches = append(matches, serial)
+		keyInfo[serial] = map[string]interface{}{
+			"key_id":           entry.KeyID,
+			"cert_type":        entry.CertType,
+			"valid_principals": entry.ValidPrincipals,
+			"role":             entry.Role,
+			"entity_id":        entry.EntityID,
+			"issued_at":        entry.IssuedAt.Format(time.RFC3339),
+			"valid_before":     entry.ValidBefore.Format(time.RFC3339),
+		}
+	}
+	sort.Strings(matches)
+
+	return logical.ListResponseWithInfo(matches, keyInfo), nil
+}
+
+const pathCertsHelpSyn = `
+List and read the metadata of certificates issued by this backend.
+`
+
+const pathCertsHelpDesc = `
+When a role has 'store_certificates' enabled, every certificate signed or
+issued under it is recorded by serial number, along with its key ID,
+principals, validity period and the identity that requested it. This path
+lists the recorded serial numbers, and 'cert/<serial>' returns the stored
+metadata of a single certificate.
+
+Records are kept until they are removed by 'tidy/certs'.
+`
+
+const pathCertsSearchHelpSyn = `
+Search the recorded certificates by principal, key ID, role, entity or time.
+`
+
+const pathCertsSearchHelpDesc = `
+This path returns the serial numbers of recorded certificates matching all
+of the supplied filters, along with a summary of each certificate. Omitted
+filters match every certificate. Only certificates issued under roles with
+'store_certificates' enabled are recorded.
+`
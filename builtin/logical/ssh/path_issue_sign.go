@@ -136,6 +136,12 @@ func (b *backend) pathSignIssueCertificateHelper(ctx context.Context, req *logic
 		return nil, errors.New("error marshaling signed certificate")
 	}
 
+	if role.StoreCertificates {
+		if err := b.storeIssuedCert(ctx, req, data.Get("role").(string), certificate, string(signedSSHCertificate)); err != nil {
+			return nil, fmt.Errorf("failed to store certificate record: %w", err)
+		}
+	}
+
 	response := &logical.Response{
 		Data: map[string]interface{}{
 			"serial_number": strconv.FormatUint(certificate.Serial, 16),
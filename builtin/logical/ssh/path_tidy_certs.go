@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const defaultCertSafetyBuffer = 72 * time.Hour
+
+func pathTidyCerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "tidy/certs",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationVerb:   "tidy",
+			OperationSuffix: "certificates",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"safety_buffer": {
+				Type: framework.TypeDurationSecond,
+				Description: `The amount of time that must pass after a certificate
+expires before its record is removed. Defaults to 72 hours.`,
+				Default: int(defaultCertSafetyBuffer / time.Second),
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathTidyCerts,
+		},
+
+		HelpSynopsis:    pathTidyCertsHelpSyn,
+		HelpDescription: pathTidyCertsHelpDesc,
+	}
+}
+
+func (b *backend) pathTidyCerts(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	safetyBuffer := time.Duration(d.Get("safety_buffer").(int)) * time.Second
+	if safetyBuffer < 0 {
+		return logical.ErrorResponse("safety_buffer must not be negative"), nil
+	}
+
+	serials, err := req.Storage.List(ctx, certsStoragePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list certificates for tidying: %w", err)
+	}
+
+	cutoff := time.Now().Add(-safetyBuffer)
+	var removed int
+	for _, serial := range serials {
+		entry, err := getIssuedCert(ctx, req.Storage, serial)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read certificate %q: %w", serial, err)
+		}
+		if entry != nil && entry.ValidBefore.After(cutoff) {
+			continue
+		}
+
+		if err := req.Storage.Delete(ctx, certsStoragePrefix+serial); err != nil {
+			return nil, fmt.Errorf("unable to delete certificate %q: %w", serial, err)
+		}
+		removed++
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"message":        fmt.Sprintf("Removed %v of %v certificate records.", removed, len(serials)),
+			"removed_count":  removed,
+			"retained_count": len(serials) - removed,
+		},
+	}, nil
+}
+
+const pathTidyCertsHelpSyn = `
+Remove the records of certificates that expired longer ago than the safety buffer.
+`
+
+const pathTidyCertsHelpDesc = `
+This endpoint walks the certificate records kept for roles with
+'store_certificates' enabled and deletes every record whose certificate
+expired more than 'safety_buffer' ago. Certificates that are still valid are
+never removed.
+`
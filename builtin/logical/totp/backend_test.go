@@ -15,9 +15,11 @@ import (
 
 	"github.com/hashicorp/vault/helper/namespace"
 	logicaltest "github.com/hashicorp/vault/helper/testhelpers/logical"
+	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/mitchellh/mapstructure"
 	otplib "github.com/pquerna/otp"
+	hotplib "github.com/pquerna/otp/hotp"
 	totplib "github.com/pquerna/otp/totp"
 )
 
@@ -1037,6 +1039,181 @@ func TestBackend_generatedKeyExportedFalse(t *testing.T) {
 	})
 }
 
+func TestBackend_hotpValidateAndReplay(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, _ := createKey()
+	testHOTPRequest(t, b, config.StorageView, logical.UpdateOperation, "keys/test", map[string]interface{}{
+		"type":       "hotp",
+		"key":        key,
+		"counter":    5,
+		"look_ahead": 3,
+	})
+
+	resp := testHOTPRequest(t, b, config.StorageView, logical.ReadOperation, "keys/test", nil)
+	if resp.Data["type"] != "hotp" || resp.Data["counter"] != uint64(5) || resp.Data["look_ahead"] != uint(3) {
+		t.Fatalf("bad key data: %#v", resp.Data)
+	}
+
+	// A code past the look-ahead window is rejected and does not move the
+	// counter.
+	testHOTPValidate(t, b, config.StorageView, "test", testHOTPCode(t, key, 9), false)
+
+	// A code within the window is accepted exactly once, and earlier codes
+	// are rejected afterwards.
+	testHOTPValidate(t, b, config.StorageView, "test", testHOTPCode(t, key, 7), true)
+	testHOTPValidate(t, b, config.StorageView, "test", testHOTPCode(t, key, 7), false)
+	testHOTPValidate(t, b, config.StorageView, "test", testHOTPCode(t, key, 6), false)
+	testHOTPValidate(t, b, config.StorageView, "test", testHOTPCode(t, key, 8), true)
+
+	// Generating a code consumes the current counter value.
+	resp = testHOTPRequest(t, b, config.StorageView, logical.ReadOperation, "code/test", nil)
+	if resp.Data["code"] != testHOTPCode(t, key, 9) {
+		t.Fatalf("expected code for counter 9, got %v", resp.Data["code"])
+	}
+	testHOTPValidate(t, b, config.StorageView, "test", testHOTPCode(t, key, 9), false)
+	testHOTPValidate(t, b, config.StorageView, "test", testHOTPCode(t, key, 10), true)
+}
+
+// TestBackend_hotpReadCodePerfStandby tests that generating an HOTP code on a
+// performance standby is forwarded to the active node, which stores the
+// advanced counter.
+func TestBackend_hotpReadCodePerfStandby(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = &logical.StaticSystemView{
+		ReplicationStateVal: consts.ReplicationPerformanceStandby,
+	}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, _ := createKey()
+	testHOTPRequest(t, b, config.StorageView, logical.UpdateOperation, "keys/test", map[string]interface{}{
+		"type": "hotp",
+		"key":  key,
+	})
+
+	_, err = b.HandleRequest(namespace.RootContext(nil), &logical.Request{
+		Path:      "code/test",
+		Operation: logical.ReadOperation,
+		Storage:   config.StorageView,
+	})
+	if err != logical.ErrReadOnly {
+		t.Fatalf("expected %v, got %v", logical.ErrReadOnly, err)
+	}
+
+	// The counter did not move
+	resp := testHOTPRequest(t, b, config.StorageView, logical.ReadOperation, "keys/test", nil)
+	if resp.Data["counter"] != uint64(0) {
+		t.Fatalf("expected counter 0, got %v", resp.Data["counter"])
+	}
+}
+
+func TestBackend_hotpResync(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, _ := createKey()
+	testHOTPRequest(t, b, config.StorageView, logical.UpdateOperation, "keys/test", map[string]interface{}{
+		"url": "otpauth://hotp/Vault:test@email.com?secret=" + key + "&counter=10",
+	})
+
+	resp := testHOTPRequest(t, b, config.StorageView, logical.ReadOperation, "keys/test", nil)
+	if resp.Data["type"] != "hotp" || resp.Data["counter"] != uint64(10) || resp.Data["issuer"] != "Vault" {
+		t.Fatalf("bad key data: %#v", resp.Data)
+	}
+
+	// The token drifted beyond the default look-ahead window.
+	testHOTPValidate(t, b, config.StorageView, "test", testHOTPCode(t, key, 60), false)
+
+	// The window is bounded.
+	resp, err = b.HandleRequest(namespace.RootContext(nil), &logical.Request{
+		Path:      "keys/test/resync",
+		Operation: logical.UpdateOperation,
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"code":      testHOTPCode(t, key, 60),
+			"next_code": testHOTPCode(t, key, 61),
+			"window":    maxHOTPWindow + 1,
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response, got resp: %#v err: %v", resp, err)
+	}
+
+	// Codes that are not consecutive are rejected.
+	resp, err = b.HandleRequest(namespace.RootContext(nil), &logical.Request{
+		Path:      "keys/test/resync",
+		Operation: logical.UpdateOperation,
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"code":      testHOTPCode(t, key, 60),
+			"next_code": testHOTPCode(t, key, 62),
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response, got resp: %#v err: %v", resp, err)
+	}
+
+	resp = testHOTPRequest(t, b, config.StorageView, logical.UpdateOperation, "keys/test/resync", map[string]interface{}{
+		"code":      testHOTPCode(t, key, 60),
+		"next_code": testHOTPCode(t, key, 61),
+	})
+	if resp.Data["counter"] != uint64(62) {
+		t.Fatalf("expected counter 62, got %v", resp.Data["counter"])
+	}
+
+	testHOTPValidate(t, b, config.StorageView, "test", testHOTPCode(t, key, 61), false)
+	testHOTPValidate(t, b, config.StorageView, "test", testHOTPCode(t, key, 62), true)
+}
+
+func testHOTPCode(t *testing.T, key string, counter uint64) string {
+	t.Helper()
+	code, err := hotplib.GenerateCodeCustom(key, counter, hotplib.ValidateOpts{
+		Digits:    otplib.DigitsSix,
+		Algorithm: otplib.AlgorithmSHA1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func testHOTPRequest(t *testing.T, b logical.Backend, s logical.Storage, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+	t.Helper()
+	resp, err := b.HandleRequest(namespace.RootContext(nil), &logical.Request{
+		Path:      path,
+		Operation: op,
+		Storage:   s,
+		Data:      data,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: %s %s\nresp: %#v\nerr: %v", op, path, resp, err)
+	}
+	return resp
+}
+
+func testHOTPValidate(t *testing.T, b logical.Backend, s logical.Storage, name, code string, valid bool) {
+	t.Helper()
+	resp := testHOTPRequest(t, b, s, logical.UpdateOperation, "code/"+name, map[string]interface{}{
+		"code": code,
+	})
+	if resp.Data["valid"] != valid {
+		t.Fatalf("expected valid to be %t for code %s, got %v", valid, code, resp.Data["valid"])
+	}
+}
+
 func testAccStepCreateKey(t *testing.T, name string, keyData map[string]interface{}, expectFail bool) logicaltest.TestStep {
 	return logicaltest.TestStep{
 		Operation: logical.UpdateOperation,
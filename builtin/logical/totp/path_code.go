@@ -9,8 +9,11 @@ import (
 	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	otplib "github.com/pquerna/otp"
+	hotplib "github.com/pquerna/otp/hotp"
 	totplib "github.com/pquerna/otp/totp"
 )
 
@@ -30,7 +33,7 @@ func pathCode(b *backend) *framework.Path {
 			},
 			"code": {
 				Type:        framework.TypeString,
-				Description: "TOTP code to be validated.",
+				Description: "TOTP or HOTP code to be validated.",
 			},
 		},
 
@@ -57,6 +60,10 @@ func pathCode(b *backend) *framework.Path {
 func (b *backend) pathReadCode(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
 
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
 	// Get the key
 	key, err := b.Key(ctx, req.Storage, name)
 	if err != nil {
@@ -66,6 +73,10 @@ func (b *backend) pathReadCode(ctx context.Context, req *logical.Request, data *
 		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
 	}
 
+	if key.keyType() == keyTypeHOTP {
+		return b.readHOTPCode(ctx, req, name, key)
+	}
+
 	// Generate password using totp library
 	totpToken, err := totplib.GenerateCodeCustom(key.Key, time.Now(), totplib.ValidateOpts{
 		Period:    key.Period,
@@ -93,6 +104,10 @@ func (b *backend) pathValidateCode(ctx context.Context, req *logical.Request, da
 		return logical.ErrorResponse("the code value is required"), nil
 	}
 
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
 	// Get the key's stored values
 	key, err := b.Key(ctx, req.Storage, name)
 	if err != nil {
@@ -102,6 +117,10 @@ func (b *backend) pathValidateCode(ctx context.Context, req *logical.Request, da
 		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
 	}
 
+	if key.keyType() == keyTypeHOTP {
+		return b.validateHOTPCode(ctx, req, name, key, code)
+	}
+
 	usedName := fmt.Sprintf("%s_%s", name, code)
 
 	_, ok := b.usedCodes.Get(usedName)
@@ -136,11 +155,93 @@ func (b *backend) pathValidateCode(ctx context.Context, req *logical.Request, da
 	}, nil
 }
 
+// readHOTPCode returns the code for the current counter of an HOTP key and
+// advances the counter, so that every generated code is only valid once. The
+// caller must hold the key lock.
+func (b *backend) readHOTPCode(ctx context.Context, req *logical.Request, name string, key *keyEntry) (*logical.Response, error) {
+	// Performance standbys cannot store the new counter, forward the request
+	// to the active node
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		return nil, logical.ErrReadOnly
+	}
+
+	hotpToken, err := hotplib.GenerateCodeCustom(key.Key, key.Counter, key.hotpOpts())
+	if err != nil {
+		return nil, err
+	}
+
+	key.Counter++
+	if err := b.putKey(ctx, req.Storage, name, key); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"code": hotpToken,
+		},
+	}, nil
+}
+
+// validateHOTPCode checks the code against the stored counter and the
+// following look_ahead counter values. On a match the counter moves past the
+// matched value, which rejects any replay of this or an earlier code. The
+// caller must hold the key lock.
+func (b *backend) validateHOTPCode(ctx context.Context, req *logical.Request, name string, key *keyEntry, code string) (*logical.Response, error) {
+	matched, ok, err := key.findHOTPCounter(key.Counter, uint64(key.LookAhead), code)
+	if err != nil {
+		return logical.ErrorResponse("an error occurred while validating the code"), err
+	}
+
+	if ok {
+		key.Counter = matched + 1
+		if err := b.putKey(ctx, req.Storage, name, key); err != nil {
+			return nil, err
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"valid": ok,
+		},
+	}, nil
+}
+
+func (k *keyEntry) hotpOpts() hotplib.ValidateOpts {
+	return hotplib.ValidateOpts{
+		Digits:    k.Digits,
+		Algorithm: k.Algorithm,
+	}
+}
+
+// findHOTPCounter returns the first counter value in [start, start+window]
+// for which the key generates the given code.
+func (k *keyEntry) findHOTPCounter(start, window uint64, code string) (uint64, bool, error) {
+	for counter := start; counter <= start+window; counter++ {
+		valid, err := hotplib.ValidateCustom(code, counter, k.Key, k.hotpOpts())
+		if err == otplib.ErrValidateInputInvalidLength {
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		if valid {
+			return counter, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
 const pathCodeHelpSyn = `
-Request time-based one-time use password or validate a password for a certain key .
+Request a one-time use password or validate a password for a certain key.
 `
 
 const pathCodeHelpDesc = `
-This path generates and validates time-based one-time use passwords for a certain key. 
+This path generates and validates one-time use passwords for a certain key.
 
+For TOTP keys, codes are derived from the current time. For HOTP keys, codes
+are derived from the key's counter: generating a code advances the counter,
+and validating a code accepts the stored counter and up to 'look_ahead'
+following values, advancing the counter past the matched value so that a
+code can never be used twice.
 `
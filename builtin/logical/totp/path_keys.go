@@ -15,11 +15,22 @@ import (
 	"strings"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	otplib "github.com/pquerna/otp"
+	hotplib "github.com/pquerna/otp/hotp"
 	totplib "github.com/pquerna/otp/totp"
 )
 
+const (
+	keyTypeTOTP = "totp"
+	keyTypeHOTP = "hotp"
+
+	// maxHOTPWindow bounds the number of counter values searched for an HOTP
+	// code, by validation and resync alike.
+	maxHOTPWindow = 1000
+)
+
 func pathListKeys(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "keys/?$",
@@ -53,6 +64,13 @@ func pathKeys(b *backend) *framework.Path {
 				Description: "Name of the key.",
 			},
 
+			"type": {
+				Type:          framework.TypeString,
+				Default:       keyTypeTOTP,
+				Description:   `The type of one-time password generated by the key; either "totp" for time-based or "hotp" for counter-based codes. If a url is passed, defaults to the type encoded in the url.`,
+				AllowedValues: []interface{}{keyTypeTOTP, keyTypeHOTP},
+			},
+
 			"generate": {
 				Type:        framework.TypeBool,
 				Default:     false,
@@ -110,6 +128,18 @@ func pathKeys(b *backend) *framework.Path {
 				Description: `The number of delay periods that are allowed when validating a TOTP token. This value can either be 0 or 1. Only used if generate is true.`,
 			},
 
+			"counter": {
+				Type:        framework.TypeInt,
+				Default:     0,
+				Description: `The initial counter value of an HOTP key. Only used if type is hotp.`,
+			},
+
+			"look_ahead": {
+				Type:        framework.TypeInt,
+				Default:     10,
+				Description: `The number of counter values past the stored counter that are accepted when validating an HOTP code, at most 1000. Only used if type is hotp.`,
+			},
+
 			"qr_size": {
 				Type:        framework.TypeInt,
 				Default:     200,
@@ -166,7 +196,13 @@ func (b *backend) Key(ctx context.Context, s logical.Storage, n string) (*keyEnt
 }
 
 func (b *backend) pathKeyDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete(ctx, "key/"+data.Get("name").(string))
+	name := data.Get("name").(string)
+
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	err := req.Storage.Delete(ctx, "key/"+name)
 	if err != nil {
 		return nil, err
 	}
@@ -187,15 +223,24 @@ func (b *backend) pathKeyRead(ctx context.Context, req *logical.Request, data *f
 	algorithm := key.Algorithm.String()
 
 	// Return values of key
-	return &logical.Response{
+	resp := &logical.Response{
 		Data: map[string]interface{}{
+			"type":         key.keyType(),
 			"issuer":       key.Issuer,
 			"account_name": key.AccountName,
-			"period":       key.Period,
 			"algorithm":    algorithm,
 			"digits":       key.Digits,
 		},
-	}, nil
+	}
+
+	if key.keyType() == keyTypeHOTP {
+		resp.Data["counter"] = key.Counter
+		resp.Data["look_ahead"] = key.LookAhead
+	} else {
+		resp.Data["period"] = key.Period
+	}
+
+	return resp, nil
 }
 
 func (b *backend) pathKeyList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
@@ -209,6 +254,8 @@ func (b *backend) pathKeyList(ctx context.Context, req *logical.Request, d *fram
 
 func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
+	keyType := data.Get("type").(string)
+	_, keyTypeSet := data.GetOk("type")
 	generate := data.Get("generate").(bool)
 	exported := data.Get("exported").(bool)
 	keyString := data.Get("key").(string)
@@ -221,6 +268,8 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 	qrSize := data.Get("qr_size").(int)
 	keySize := data.Get("key_size").(int)
 	inputURL := data.Get("url").(string)
+	counter := data.Get("counter").(int)
+	lookAhead := data.Get("look_ahead").(int)
 
 	if generate {
 		if keyString != "" {
@@ -239,6 +288,15 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 			return logical.ErrorResponse("an error occurred while parsing url string"), err
 		}
 
+		// Read key type; the url type wins unless one was passed explicitly
+		switch urlObject.Host {
+		case keyTypeTOTP, keyTypeHOTP:
+			if keyTypeSet && keyType != urlObject.Host {
+				return logical.ErrorResponse(fmt.Sprintf("the type value %q does not match the url type %q", keyType, urlObject.Host)), nil
+			}
+			keyType = urlObject.Host
+		}
+
 		// Set up query object
 		urlQuery := urlObject.Query()
 		path := strings.TrimPrefix(urlObject.Path, "/")
@@ -289,6 +347,22 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		if algorithmQuery != "" {
 			algorithm = algorithmQuery
 		}
+
+		// Read counter
+		counterQuery := urlQuery.Get("counter")
+		if counterQuery != "" && keyType == keyTypeHOTP {
+			counterInt, err := strconv.Atoi(counterQuery)
+			if err != nil {
+				return logical.ErrorResponse("an error occurred while parsing counter value in url"), err
+			}
+			counter = counterInt
+		}
+	}
+
+	switch keyType {
+	case keyTypeTOTP, keyTypeHOTP:
+	default:
+		return logical.ErrorResponse("the type value must be totp or hotp"), nil
 	}
 
 	// Translate digits and algorithm to a format the totp library understands
@@ -335,6 +409,17 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		return logical.ErrorResponse("the key_size value must be greater than zero"), nil
 	}
 
+	if counter < 0 {
+		return logical.ErrorResponse("the counter value must be greater than or equal to zero"), nil
+	}
+
+	if lookAhead < 0 {
+		return logical.ErrorResponse("the look_ahead value must be greater than or equal to zero"), nil
+	}
+	if lookAhead > maxHOTPWindow {
+		return logical.ErrorResponse(fmt.Sprintf("the look_ahead value must be less than or equal to %d", maxHOTPWindow)), nil
+	}
+
 	// Period, Skew and Key Size need to be unsigned ints
 	uintPeriod := uint(period)
 	uintSkew := uint(skew)
@@ -354,15 +439,28 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		}
 
 		// Generate a new key
-		keyObject, err := totplib.Generate(totplib.GenerateOpts{
-			Issuer:      issuer,
-			AccountName: accountName,
-			Period:      uintPeriod,
-			Digits:      keyDigits,
-			Algorithm:   keyAlgorithm,
-			SecretSize:  uintKeySize,
-			Rand:        b.GetRandomReader(),
-		})
+		var keyObject *otplib.Key
+		var err error
+		if keyType == keyTypeHOTP {
+			keyObject, err = generateHOTPKey(hotplib.GenerateOpts{
+				Issuer:      issuer,
+				AccountName: accountName,
+				Digits:      keyDigits,
+				Algorithm:   keyAlgorithm,
+				SecretSize:  uintKeySize,
+				Rand:        b.GetRandomReader(),
+			}, uint64(counter))
+		} else {
+			keyObject, err = totplib.Generate(totplib.GenerateOpts{
+				Issuer:      issuer,
+				AccountName: accountName,
+				Period:      uintPeriod,
+				Digits:      keyDigits,
+				Algorithm:   keyAlgorithm,
+				SecretSize:  uintKeySize,
+				Rand:        b.GetRandomReader(),
+			})
+		}
 		if err != nil {
 			return logical.ErrorResponse("an error occurred while generating a key"), err
 		}
@@ -415,8 +513,13 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		}
 	}
 
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
 	// Store it
-	entry, err := logical.StorageEntryJSON("key/"+name, &keyEntry{
+	key := &keyEntry{
+		Type:        keyType,
 		Key:         keyString,
 		Issuer:      issuer,
 		AccountName: accountName,
@@ -424,18 +527,47 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		Algorithm:   keyAlgorithm,
 		Digits:      keyDigits,
 		Skew:        uintSkew,
-	})
+	}
+	if keyType == keyTypeHOTP {
+		key.Counter = uint64(counter)
+		key.LookAhead = uint(lookAhead)
+	}
+	if err := b.putKey(ctx, req.Storage, name, key); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *backend) putKey(ctx context.Context, s logical.Storage, name string, key *keyEntry) error {
+	entry, err := logical.StorageEntryJSON("key/"+name, key)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// generateHOTPKey generates a new HOTP key, adding the initial counter to
+// its url as authenticator apps require it for HOTP keys.
+func generateHOTPKey(opts hotplib.GenerateOpts, counter uint64) (*otplib.Key, error) {
+	keyObject, err := hotplib.Generate(opts)
 	if err != nil {
 		return nil, err
 	}
-	if err := req.Storage.Put(ctx, entry); err != nil {
+
+	keyURL, err := url.Parse(keyObject.String())
+	if err != nil {
 		return nil, err
 	}
+	query := keyURL.Query()
+	query.Set("counter", strconv.FormatUint(counter, 10))
+	keyURL.RawQuery = query.Encode()
 
-	return response, nil
+	return otplib.NewKeyFromURL(keyURL.String())
 }
 
 type keyEntry struct {
+	Type        string           `json:"type,omitempty" mapstructure:"type" structs:"type"`
 	Key         string           `json:"key" mapstructure:"key" structs:"key"`
 	Issuer      string           `json:"issuer" mapstructure:"issuer" structs:"issuer"`
 	AccountName string           `json:"account_name" mapstructure:"account_name" structs:"account_name"`
@@ -443,6 +575,17 @@ type keyEntry struct {
 	Algorithm   otplib.Algorithm `json:"algorithm" mapstructure:"algorithm" structs:"algorithm"`
 	Digits      otplib.Digits    `json:"digits" mapstructure:"digits" structs:"digits"`
 	Skew        uint             `json:"skew" mapstructure:"skew" structs:"skew"`
+	Counter     uint64           `json:"counter,omitempty" mapstructure:"counter" structs:"counter"`
+	LookAhead   uint             `json:"look_ahead,omitempty" mapstructure:"look_ahead" structs:"look_ahead"`
+}
+
+// keyType returns the type of the key; keys stored before HOTP support was
+// added have no type and are always TOTP keys.
+func (k *keyEntry) keyType() string {
+	if k.Type == "" {
+		return keyTypeTOTP
+	}
+	return k.Type
 }
 
 const pathKeyHelpSyn = `
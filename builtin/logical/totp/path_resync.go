@@ -0,0 +1,134 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package totp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathResync(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameWithAtRegex("name") + "/resync",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTOTP,
+			OperationVerb:   "resync",
+			OperationSuffix: "key",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key.",
+			},
+			"code": {
+				Type:        framework.TypeString,
+				Description: "The first of two consecutive HOTP codes produced by the token.",
+			},
+			"next_code": {
+				Type:        framework.TypeString,
+				Description: "The HOTP code produced by the token immediately after code.",
+			},
+			"window": {
+				Type:        framework.TypeInt,
+				Default:     100,
+				Description: "The number of counter values past the stored counter that are searched for the two codes. At most 1000.",
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathResyncKey,
+			},
+		},
+
+		HelpSynopsis:    pathResyncHelpSyn,
+		HelpDescription: pathResyncHelpDesc,
+	}
+}
+
+func (b *backend) pathResyncKey(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	code := data.Get("code").(string)
+	nextCode := data.Get("next_code").(string)
+	window := data.Get("window").(int)
+
+	if code == "" || nextCode == "" {
+		return logical.ErrorResponse("the code and next_code values are required"), nil
+	}
+	if window < 0 {
+		return logical.ErrorResponse("the window value must be greater than or equal to zero"), nil
+	}
+	if window > maxHOTPWindow {
+		return logical.ErrorResponse(fmt.Sprintf("the window value must be less than or equal to %d", maxHOTPWindow)), nil
+	}
+
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	key, err := b.Key(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
+	}
+	if key.keyType() != keyTypeHOTP {
+		return logical.ErrorResponse("only hotp keys can be resynchronized"), nil
+	}
+
+	// Search for the first code, and only accept a match when the second
+	// code follows it directly. The counter never moves backwards, so codes
+	// that were already used can not be replayed through a resync.
+	start := key.Counter
+	end := key.Counter + uint64(window)
+	for start <= end {
+		matched, ok, err := key.findHOTPCounter(start, end-start, code)
+		if err != nil {
+			return logical.ErrorResponse("an error occurred while validating the code"), err
+		}
+		if !ok {
+			break
+		}
+
+		_, nextOk, err := key.findHOTPCounter(matched+1, 0, nextCode)
+		if err != nil {
+			return logical.ErrorResponse("an error occurred while validating the code"), err
+		}
+		if nextOk {
+			key.Counter = matched + 2
+			if err := b.putKey(ctx, req.Storage, name, key); err != nil {
+				return nil, err
+			}
+
+			return &logical.Response{
+				Data: map[string]interface{}{
+					"counter": key.Counter,
+				},
+			}, nil
+		}
+
+		start = matched + 1
+	}
+
+	return logical.ErrorResponse("the codes could not be matched to consecutive counter values within the window"), nil
+}
+
+const pathResyncHelpSyn = `
+Resynchronize the counter of an HOTP key with a token.
+`
+
+const pathResyncHelpDesc = `
+HOTP tokens advance their counter every time a code is produced, so a token
+which generated many codes that were never validated can drift past the
+look-ahead window of the key. This path takes two consecutive codes from the
+token, searches the next 'window' counter values for them, and on a match
+moves the stored counter past the second code.
+`
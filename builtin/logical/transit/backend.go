@@ -5,6 +5,7 @@ package transit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -288,8 +289,35 @@ func (b *backend) rotateIfRequired(ctx context.Context, req *logical.Request, ke
 		if b.Logger().IsDebug() {
 			b.Logger().Debug("automatically rotating key", "key", key)
 		}
-		return p.Rotate(ctx, req.Storage, b.GetRandomReader())
-
+		if err := p.Rotate(ctx, req.Storage, b.GetRandomReader()); err != nil {
+			return err
+		}
+		b.transitEvent(ctx, "rotate", "keys/"+key)
 	}
 	return nil
 }
+
+// transitEvent sends an event of the operation on the given path, so that the
+// consumers of the responses of the mount, such as the static secret cache of
+// Vault Proxy, can refresh them.
+func (b *backend) transitEvent(ctx context.Context, operation string, path string) {
+	err := logical.SendEvent(ctx, b, fmt.Sprintf("transit/%s", operation),
+		logical.EventMetadataModified, "true",
+		logical.EventMetadataOperation, operation,
+		"path", path,
+	)
+	if err != nil && !errors.Is(err, framework.ErrNoEvents) {
+		b.Logger().Error("Error sending event", "error", err)
+	}
+}
+
+// withEvent sends an event of the operation once the handler succeeds.
+func (b *backend) withEvent(operation string, handler framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		resp, err := handler(ctx, req, data)
+		if err == nil && !resp.IsError() {
+			b.transitEvent(ctx, operation, req.Path)
+		}
+		return resp, err
+	}
+}
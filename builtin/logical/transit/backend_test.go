@@ -2303,3 +2303,33 @@ func testTransit_ExportPublicKeyImported(t *testing.T, keyType string) {
 		}
 	}
 }
+
+// TestTransit_Events tests that the changes to the keys of the mount send
+// events.
+func TestTransit_Events(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	eventSender := logical.NewMockEventSender()
+	config.EventsSender = eventSender
+	b, _ := Backend(context.Background(), config)
+	require.NoError(t, b.Backend.Setup(context.Background(), config))
+
+	for _, req := range []*logical.Request{
+		{Operation: logical.UpdateOperation, Path: "keys/test"},
+		{Operation: logical.UpdateOperation, Path: "keys/test/rotate"},
+		{Operation: logical.UpdateOperation, Path: "keys/test/config", Data: map[string]interface{}{"deletion_allowed": true}},
+		{Operation: logical.DeleteOperation, Path: "keys/test"},
+	} {
+		req.Storage = config.StorageView
+		resp, err := b.HandleRequest(context.Background(), req)
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), "%v", resp)
+	}
+
+	var types []string
+	for _, event := range eventSender.Events {
+		types = append(types, string(event.Type))
+	}
+	require.Equal(t, []string{"transit/key-write", "transit/rotate", "transit/key-config-write", "transit/key-delete"}, types)
+	require.Equal(t, "keys/test/rotate", eventSender.Events[1].Event.Metadata.AsMap()["path"])
+}
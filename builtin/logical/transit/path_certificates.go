@@ -86,7 +86,7 @@ by one or more concatenated PEM blocks and ordered starting from the end-entity
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathImportCertChainWrite,
+				Callback: b.withEvent("certificate-import", b.pathImportCertChainWrite),
 			},
 		},
 		HelpSynopsis:    pathImportCertChainHelpSyn,
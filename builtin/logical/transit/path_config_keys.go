@@ -39,7 +39,7 @@ keys on the encrypt endpoint.`,
 
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathConfigKeysWrite,
+				Callback: b.withEvent("config-keys-write", b.pathConfigKeysWrite),
 				DisplayAttrs: &framework.DisplayAttributes{
 					OperationVerb:   "configure",
 					OperationSuffix: "keys",
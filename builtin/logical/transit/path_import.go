@@ -105,7 +105,7 @@ key.`,
 			},
 		},
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.UpdateOperation: b.pathImportWrite,
+			logical.UpdateOperation: b.withEvent("import", b.pathImportWrite),
 		},
 		HelpSynopsis:    pathImportWriteSyn,
 		HelpDescription: pathImportWriteDesc,
@@ -149,7 +149,7 @@ a private key is specified and the 'Latest' key is missing a private key.`,
 			},
 		},
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.UpdateOperation: b.pathImportVersionWrite,
+			logical.UpdateOperation: b.withEvent("import-version", b.pathImportVersionWrite),
 		},
 		HelpSynopsis:    pathImportVersionWriteSyn,
 		HelpDescription: pathImportVersionWriteDesc,
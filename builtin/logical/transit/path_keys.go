@@ -134,13 +134,13 @@ key.`,
 
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.UpdateOperation: &framework.PathOperation{
-				Callback: b.pathPolicyWrite,
+				Callback: b.withEvent("key-write", b.pathPolicyWrite),
 				DisplayAttrs: &framework.DisplayAttributes{
 					OperationVerb: "create",
 				},
 			},
 			logical.DeleteOperation: &framework.PathOperation{
-				Callback: b.pathPolicyDelete,
+				Callback: b.withEvent("key-delete", b.pathPolicyDelete),
 				DisplayAttrs: &framework.DisplayAttributes{
 					OperationVerb: "delete",
 				},
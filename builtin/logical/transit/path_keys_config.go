@@ -68,7 +68,7 @@ disables automatic rotation for the key.`,
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.UpdateOperation: b.pathKeysConfigWrite,
+			logical.UpdateOperation: b.withEvent("key-config-write", b.pathKeysConfigWrite),
 		},
 
 		HelpSynopsis:    pathKeysConfigHelpSyn,
@@ -39,7 +39,7 @@ func (b *backend) pathRestore() *framework.Path {
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.UpdateOperation: b.pathRestoreUpdate,
+			logical.UpdateOperation: b.withEvent("restore", b.pathRestoreUpdate),
 		},
 
 		HelpSynopsis:    pathRestoreHelpSyn,
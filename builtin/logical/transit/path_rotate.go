@@ -37,7 +37,7 @@ func (b *backend) pathRotate() *framework.Path {
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.UpdateOperation: b.pathRotateWrite,
+			logical.UpdateOperation: b.withEvent("rotate", b.pathRotateWrite),
 		},
 
 		HelpSynopsis:    pathRotateHelpSyn,
@@ -38,7 +38,7 @@ allowed to be set when either 'min_encryption_version' or
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.UpdateOperation: b.pathTrimUpdate(),
+			logical.UpdateOperation: b.withEvent("trim", b.pathTrimUpdate()),
 		},
 
 		HelpSynopsis:    pathTrimHelpSyn,
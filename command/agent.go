@@ -33,9 +33,12 @@ import (
 	"github.com/hashicorp/vault/command/agentproxyshared"
 	"github.com/hashicorp/vault/command/agentproxyshared/auth"
 	"github.com/hashicorp/vault/command/agentproxyshared/cache"
+	"github.com/hashicorp/vault/command/agentproxyshared/failover"
+	"github.com/hashicorp/vault/command/agentproxyshared/health"
 	"github.com/hashicorp/vault/command/agentproxyshared/sink"
 	"github.com/hashicorp/vault/command/agentproxyshared/sink/file"
 	"github.com/hashicorp/vault/command/agentproxyshared/sink/inmem"
+	"github.com/hashicorp/vault/command/agentproxyshared/sink/socket"
 	"github.com/hashicorp/vault/command/agentproxyshared/winsvc"
 	"github.com/hashicorp/vault/helper/logging"
 	"github.com/hashicorp/vault/helper/metricsutil"
@@ -309,6 +312,32 @@ func (c *AgentCommand) Run(args []string) int {
 	}
 	c.metricsHelper = metricsutil.NewMetricsHelper(inmemMetrics, prometheusEnabled)
 
+	// Fail over between the Vault servers when more than one is configured
+	var addressSelector *failover.AddressSelector
+	var failoverFunc cache.FailoverFunc
+	if config.Vault != nil && len(config.Vault.Addresses) > 0 {
+		addressSelector, err = failover.NewAddressSelector(&failover.AddressSelectorConfig{
+			Logger:              c.logger.Named("failover"),
+			Client:              client,
+			Addresses:           append([]string{config.Vault.Address}, config.Vault.Addresses...),
+			HealthCheckInterval: config.Vault.HealthCheckInterval,
+			MetricsSignifier:    "agent",
+		})
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error creating Vault address selector: %v", err))
+			return 1
+		}
+		addressSelector.Check(ctx)
+		config.Vault.Address = addressSelector.Address()
+		if err := addressSelector.AddClient(client); err != nil {
+			c.UI.Error(fmt.Sprintf("Error setting Vault address: %v", err))
+			return 1
+		}
+		failoverFunc = addressSelector.Failover
+		info["vault addresses"] = strings.Join(addressSelector.Addresses(), ", ")
+		infoKeys = append(infoKeys, "vault addresses")
+	}
+
 	var templateNamespace string
 	// This indicates whether the namespace for the client has been set by environment variable.
 	// If it has, we don't touch it
@@ -319,6 +348,7 @@ func (c *AgentCommand) Run(args []string) int {
 	}
 
 	var method auth.AuthMethod
+	var fallbackMethods []*auth.FallbackAuthMethod
 	var sinks []*sink.SinkConfig
 	if config.AutoAuth != nil {
 		// Note: This will only set namespace header to the value in config.AutoAuth.Method.Namespace
@@ -346,6 +376,13 @@ func (c *AgentCommand) Run(args []string) int {
 			sinkClient.SetDisableKeepAlives(true)
 		}
 
+		if addressSelector != nil {
+			if err := addressSelector.AddClient(sinkClient); err != nil {
+				c.UI.Error(fmt.Sprintf("Error setting Vault address for file sink: %v", err))
+				return 1
+			}
+		}
+
 		for _, sc := range config.AutoAuth.Sinks {
 			switch sc.Type {
 			case "file":
@@ -366,6 +403,29 @@ func (c *AgentCommand) Run(args []string) int {
 				}
 				config.Sink = s
 				sinks = append(sinks, config)
+			case "socket", "http":
+				config := &sink.SinkConfig{
+					Logger:    c.logger.Named("sink." + sc.Type),
+					Config:    sc.Config,
+					Client:    sinkClient,
+					WrapTTL:   sc.WrapTTL,
+					DHType:    sc.DHType,
+					DeriveKey: sc.DeriveKey,
+					DHPath:    sc.DHPath,
+					AAD:       sc.AAD,
+				}
+				newSink := socket.NewSocketSink
+				if sc.Type == "http" {
+					newSink = socket.NewHTTPSink
+				}
+				s, err := newSink(config)
+				if err != nil {
+					c.UI.Error(fmt.Errorf("error creating %s sink: %w", sc.Type, err).Error())
+					return 1
+				}
+				defer s.(io.Closer).Close()
+				config.Sink = s
+				sinks = append(sinks, config)
 			default:
 				c.UI.Error(fmt.Sprintf("Unknown sink type %q", sc.Type))
 				return 1
@@ -382,6 +442,30 @@ func (c *AgentCommand) Run(args []string) int {
 			c.UI.Error(fmt.Sprintf("Error creating %s auth method: %v", config.AutoAuth.Method.Type, err))
 			return 1
 		}
+
+		for _, fm := range config.AutoAuth.FallbackMethods {
+			authConfig := &auth.AuthConfig{
+				Logger:    c.logger.Named(fmt.Sprintf("auth.%s", fm.Type)),
+				MountPath: fm.MountPath,
+				Config:    fm.Config,
+			}
+			fallbackMethod, err := agentproxyshared.GetAutoAuthMethodFromConfig(fm.Type, authConfig, config.Vault.Address)
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error creating %s auth method: %v", fm.Type, err))
+				return 1
+			}
+			fallbackNamespace := fm.Namespace
+			if namespaceSetByEnvironmentVariable {
+				fallbackNamespace = ""
+			}
+			fallbackMethods = append(fallbackMethods, &auth.FallbackAuthMethod{
+				Name:       fm.MountPath,
+				Method:     fallbackMethod,
+				Namespace:  fallbackNamespace,
+				MinBackoff: fm.MinBackoff,
+				MaxBackoff: fm.MaxBackoff,
+			})
+		}
 	}
 
 	// We do this after auto-auth has been configured, because we don't want to
@@ -495,6 +579,13 @@ func (c *AgentCommand) Run(args []string) int {
 		proxyClient.SetDisableKeepAlives(true)
 	}
 
+	if addressSelector != nil {
+		if err := addressSelector.AddClient(proxyClient); err != nil {
+			c.UI.Error(fmt.Sprintf("Error setting Vault address for proxying: %v", err))
+			return 1
+		}
+	}
+
 	apiProxyLogger := c.logger.Named("apiproxy")
 
 	// The API proxy to be used, if listeners are configured
@@ -505,6 +596,7 @@ func (c *AgentCommand) Run(args []string) int {
 		WhenInconsistentAction:  whenInconsistent,
 		UserAgentStringFunction: useragent.AgentProxyStringWithProxiedUserAgent,
 		UserAgentString:         useragent.AgentProxyString(),
+		Failover:                failoverFunc,
 	})
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error creating API proxy: %v", err))
@@ -524,6 +616,8 @@ func (c *AgentCommand) Run(args []string) int {
 			Logger:              cacheLogger.Named("leasecache"),
 			CacheDynamicSecrets: true,
 			UserAgentToUse:      useragent.ProxyAPIProxyString(),
+			Failover:            failoverFunc,
+			MetricsSignifier:    "agent",
 		})
 		if err != nil {
 			c.UI.Error(fmt.Sprintf("Error creating lease cache: %v", err))
@@ -532,7 +626,7 @@ func (c *AgentCommand) Run(args []string) int {
 
 		// Configure persistent storage and add to LeaseCache
 		if config.Cache.Persist != nil {
-			deferFunc, oldToken, err := agentproxyshared.AddPersistentStorageToLeaseCache(ctx, leaseCache, config.Cache.Persist, cacheLogger)
+			deferFunc, oldToken, err := agentproxyshared.AddPersistentStorageToLeaseCache(ctx, leaseCache, config.Cache.Persist, c.client, cacheLogger)
 			if err != nil {
 				c.UI.Error(fmt.Sprintf("Error creating persistent cache: %v", err))
 				return 1
@@ -544,10 +638,15 @@ func (c *AgentCommand) Run(args []string) int {
 		}
 	}
 
+	healthReporter := health.NewReporter(c.logger.Named("health"))
+	if leaseCache != nil {
+		healthReporter.Register("cache", leaseCache)
+	}
+
 	var listeners []net.Listener
 
 	// If there are templates, add an in-process listener
-	if len(config.Templates) > 0 || len(config.EnvTemplates) > 0 {
+	if len(config.Templates) > 0 || config.HasEnvTemplates() {
 		config.Listeners = append(config.Listeners, &configutil.Listener{Type: listenerutil.BufConnType})
 	}
 
@@ -604,9 +703,9 @@ func (c *AgentCommand) Run(args []string) int {
 
 		var muxHandler http.Handler
 		if leaseCache != nil {
-			muxHandler = cache.ProxyHandler(ctx, apiProxyLogger, leaseCache, inmemSink, proxyVaultToken)
+			muxHandler = cache.ProxyHandler(ctx, apiProxyLogger, leaseCache, inmemSink, proxyVaultToken, "agent")
 		} else {
-			muxHandler = cache.ProxyHandler(ctx, apiProxyLogger, apiProxy, inmemSink, proxyVaultToken)
+			muxHandler = cache.ProxyHandler(ctx, apiProxyLogger, apiProxy, inmemSink, proxyVaultToken, "agent")
 		}
 
 		// Parse 'require_request_header' listener config option, and wrap
@@ -620,6 +719,7 @@ func (c *AgentCommand) Run(args []string) int {
 		quitEnabled := lnConfig.AgentAPI != nil && lnConfig.AgentAPI.EnableQuit
 
 		mux.Handle(consts.AgentPathMetrics, c.handleMetrics())
+		mux.Handle(consts.AgentPathHealth, healthReporter.Handler())
 		if "metrics_only" != lnConfig.Role {
 			mux.Handle(consts.AgentPathCacheClear, leaseCache.HandleCacheClear(ctx))
 			mux.Handle(consts.AgentPathQuit, c.handleQuit(quitEnabled))
@@ -710,10 +810,24 @@ func (c *AgentCommand) Run(args []string) int {
 		}
 	}, func(error) {})
 
+	g.Add(func() error {
+		return healthReporter.Run(ctx)
+	}, func(error) {
+		cancelFunc()
+	})
+
+	if addressSelector != nil {
+		g.Add(func() error {
+			return addressSelector.Run(ctx)
+		}, func(error) {
+			cancelFunc()
+		})
+	}
+
 	// Start auto-auth and sink servers
 	if method != nil {
 		enableTemplateTokenCh := len(config.Templates) > 0
-		enableEnvTemplateTokenCh := len(config.EnvTemplates) > 0
+		enableEnvTemplateTokenCh := config.HasEnvTemplates()
 
 		// Auth Handler is going to set its own retry values, so we want to
 		// work on a copy of the client to not affect other subsystems.
@@ -736,6 +850,13 @@ func (c *AgentCommand) Run(args []string) int {
 			ahClient.SetDisableKeepAlives(true)
 		}
 
+		if addressSelector != nil {
+			if err := addressSelector.AddClient(ahClient); err != nil {
+				c.UI.Error(fmt.Sprintf("Error setting Vault address for auth handler: %v", err))
+				return 1
+			}
+		}
+
 		ah := auth.NewAuthHandler(&auth.AuthHandlerConfig{
 			Logger:                       c.logger.Named("auth.handler"),
 			Client:                       ahClient,
@@ -747,6 +868,7 @@ func (c *AgentCommand) Run(args []string) int {
 			EnableExecTokenCh:            enableEnvTemplateTokenCh,
 			Token:                        previousToken,
 			ExitOnError:                  config.AutoAuth.Method.ExitOnError,
+			FallbackMethods:              fallbackMethods,
 			UserAgent:                    useragent.AgentAutoAuthString(),
 			MetricsSignifier:             "agent",
 		})
@@ -757,13 +879,22 @@ func (c *AgentCommand) Run(args []string) int {
 			ExitAfterAuth: config.ExitAfterAuth,
 		})
 
+		// Templates are rendered through the cache when it is enabled, which
+		// already follows the selected Vault server
+		var templateAddressCh <-chan string
+		if addressSelector != nil && config.Cache == nil {
+			templateAddressCh = addressSelector.AddressCh()
+		}
+
 		ts := template.NewServer(&template.ServerConfig{
 			Logger:        c.logger.Named("template.server"),
+			Client:        c.client,
 			LogLevel:      c.logger.GetLevel(),
 			LogWriter:     c.logWriter,
 			AgentConfig:   c.config,
 			Namespace:     templateNamespace,
 			ExitAfterAuth: config.ExitAfterAuth,
+			AddressCh:     templateAddressCh,
 		})
 
 		es, err := exec.NewServer(&exec.ServerConfig{
@@ -778,6 +909,14 @@ func (c *AgentCommand) Run(args []string) int {
 			return 1
 		}
 
+		healthReporter.Register("auth", ah)
+		if len(config.Templates) > 0 {
+			healthReporter.Register("templates", ts)
+		}
+		if config.Exec != nil || len(config.Execs) > 0 {
+			healthReporter.Register("exec", es)
+		}
+
 		g.Add(func() error {
 			return ah.Run(ctx, method)
 		}, func(error) {
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -51,6 +52,15 @@ type Config struct {
 	DisableKeepAlivesAutoAuth   bool                       `hcl:"-"`
 	Exec                        *ExecConfig                `hcl:"exec,optional"`
 	EnvTemplates                []*ctconfig.TemplateConfig `hcl:"env_template,optional"`
+
+	// Execs are the named exec blocks, each supervising its own child
+	// process with its own env templates. They are started in order and
+	// stopped in the reverse order.
+	Execs []*ExecConfig `hcl:"-"`
+
+	// Projections are the named projection blocks. Their templates are also
+	// part of Templates, rendered to the staging directory of the projection.
+	Projections []*ProjectionConfig `hcl:"-"`
 }
 
 const (
@@ -93,6 +103,12 @@ type Vault struct {
 	TLSServerName    string      `hcl:"tls_server_name"`
 	Namespace        string      `hcl:"namespace"`
 	Retry            *Retry      `hcl:"retry"`
+
+	// Addresses are the addresses of the Vault servers to fail over between.
+	// The healthiest of them, as reported by sys/health, is used.
+	Addresses              []string      `hcl:"addresses"`
+	HealthCheckInterval    time.Duration `hcl:"-"`
+	HealthCheckIntervalRaw interface{}   `hcl:"health_check_interval"`
 }
 
 // transportDialer is an interface that allows passing a custom dialer function
@@ -128,7 +144,10 @@ type Cache struct {
 // AutoAuth is the configured authentication method and sinks
 type AutoAuth struct {
 	Method *Method `hcl:"-"`
-	Sinks  []*Sink `hcl:"sinks"`
+	// FallbackMethods are used, in order, when the methods before them fail
+	// to authenticate. They are the method blocks after the first one.
+	FallbackMethods []*Method `hcl:"-"`
+	Sinks           []*Sink   `hcl:"sinks"`
 
 	// NOTE: This is unsupported outside of testing and may disappear at any
 	// time.
@@ -170,6 +189,12 @@ type TemplateConfig struct {
 	StaticSecretRenderInt    time.Duration `hcl:"-"`
 	MaxConnectionsPerHostRaw interface{}   `hcl:"max_connections_per_host"`
 	MaxConnectionsPerHost    int           `hcl:"-"`
+
+	// SnapshotFile is where the rendered templates are persisted, encrypted
+	// with the key of SnapshotKeyFile, so that they can be rendered from it
+	// when Vault is unreachable at startup.
+	SnapshotFile    string `hcl:"snapshot_file"`
+	SnapshotKeyFile string `hcl:"snapshot_key_file"`
 }
 
 type ExecConfig struct {
@@ -178,6 +203,71 @@ type ExecConfig struct {
 	RestartStopSignal      os.Signal `hcl:"-" mapstructure:"restart_stop_signal"`
 	ChildProcessStdout     string    `mapstructure:"child_process_stdout"`
 	ChildProcessStderr     string    `mapstructure:"child_process_stderr"`
+
+	// ReloadSignal is sent to the child process when the secrets change and
+	// RestartOnSecretChanges is "signal".
+	ReloadSignal os.Signal `hcl:"-" mapstructure:"reload_signal"`
+
+	// HealthCheckCommand is run every HealthCheckInterval while the child
+	// process runs. The child process is restarted after the command failed
+	// HealthCheckFailureThreshold times in a row.
+	HealthCheckCommand          []string      `mapstructure:"health_check_command"`
+	HealthCheckInterval         time.Duration `mapstructure:"health_check_interval"`
+	HealthCheckFailureThreshold int           `mapstructure:"health_check_failure_threshold"`
+
+	// Name and EnvTemplates are only set on named exec blocks, which hold
+	// their own env templates.
+	Name         string                     `mapstructure:"-"`
+	EnvTemplates []*ctconfig.TemplateConfig `mapstructure:"-"`
+}
+
+const (
+	// DefaultExecHealthCheckInterval is the default interval of the health
+	// checks of the exec child processes.
+	DefaultExecHealthCheckInterval = 10 * time.Second
+
+	// DefaultExecHealthCheckFailureThreshold is the default number of failed
+	// health checks in a row after which an exec child process is restarted.
+	DefaultExecHealthCheckFailureThreshold = 3
+)
+
+// ProjectionConfig renders a group of templates into a directory that is
+// updated atomically, like the projected volumes of Kubernetes: each version
+// of the files is written to a new timestamped directory, and the "..data"
+// symlink is then swapped to point to it. The files of the projection are
+// symlinks through "..data", so that readers never observe a partially
+// updated set of files.
+type ProjectionConfig struct {
+	Name      string      `mapstructure:"-"`
+	Directory string      `mapstructure:"directory"`
+	User      string      `mapstructure:"user"`
+	Group     string      `mapstructure:"group"`
+	DirPerms  os.FileMode `mapstructure:"dir_perms"`
+	FilePerms os.FileMode `mapstructure:"file_perms"`
+
+	// RetainPrevious is the number of previous versions kept besides the
+	// current one, for rollback.
+	RetainPrevious int `mapstructure:"retain_previous"`
+
+	// Templates are rendered to StagingDir. The base name of their
+	// destination is the name of the file in the projection.
+	Templates []*ctconfig.TemplateConfig `mapstructure:"-"`
+}
+
+const (
+	// ProjectionStagingDir is the directory of a projection the templates
+	// are rendered to before the files are published.
+	ProjectionStagingDir = "..staging"
+
+	DefaultProjectionDirPerms       os.FileMode = 0o755
+	DefaultProjectionFilePerms      os.FileMode = 0o644
+	DefaultProjectionRetainPrevious             = 1
+)
+
+// StagingDir returns the directory the templates of the projection are
+// rendered to.
+func (p *ProjectionConfig) StagingDir() string {
+	return filepath.Join(p.Directory, ProjectionStagingDir)
 }
 
 func NewConfig() *Config {
@@ -282,6 +372,11 @@ func (c *Config) Merge(c2 *Config) *Config {
 		result.Exec = c2.Exec
 	}
 
+	result.Execs = c.Execs
+	if c2.Execs != nil {
+		result.Execs = c2.Execs
+	}
+
 	for _, envTmpl := range c.EnvTemplates {
 		result.EnvTemplates = append(result.EnvTemplates, envTmpl)
 	}
@@ -290,6 +385,9 @@ func (c *Config) Merge(c2 *Config) *Config {
 		result.EnvTemplates = append(result.EnvTemplates, envTmpl)
 	}
 
+	result.Projections = append(result.Projections, c.Projections...)
+	result.Projections = append(result.Projections, c2.Projections...)
+
 	return result
 }
 
@@ -318,7 +416,7 @@ func (c *Config) ValidateConfig() error {
 	}
 
 	if c.Cache != nil {
-		if len(c.Listeners) < 1 && len(c.Templates) < 1 && len(c.EnvTemplates) < 1 {
+		if len(c.Listeners) < 1 && len(c.Templates) < 1 && !c.HasEnvTemplates() {
 			return fmt.Errorf("enabling the cache requires at least 1 template or 1 listener to be defined")
 		}
 
@@ -351,7 +449,7 @@ func (c *Config) ValidateConfig() error {
 		if len(c.AutoAuth.Sinks) == 0 &&
 			(c.APIProxy == nil || !c.APIProxy.UseAutoAuthToken) &&
 			len(c.Templates) == 0 &&
-			len(c.EnvTemplates) == 0 {
+			!c.HasEnvTemplates() {
 			return fmt.Errorf("auto_auth requires at least one sink or at least one template or api_proxy.use_auto_auth_token=true")
 		}
 	}
@@ -360,21 +458,88 @@ func (c *Config) ValidateConfig() error {
 		return fmt.Errorf("no auto_auth, cache, or listener block found in config")
 	}
 
+	if c.TemplateConfig != nil && (c.TemplateConfig.SnapshotFile == "") != (c.TemplateConfig.SnapshotKeyFile == "") {
+		return fmt.Errorf("template_config.snapshot_file and template_config.snapshot_key_file must be specified together")
+	}
+
+	if err := c.validateProjections(); err != nil {
+		return err
+	}
+
 	return c.validateEnvTemplateConfig()
 }
 
+func (c *Config) validateProjections() error {
+	names := make(map[string]struct{})
+	directories := make(map[string]struct{})
+	for _, p := range c.Projections {
+		field := fmt.Sprintf("projection[%s]", p.Name)
+		if _, ok := names[p.Name]; ok {
+			return fmt.Errorf("duplicate 'projection' block name: %q", p.Name)
+		}
+		names[p.Name] = struct{}{}
+
+		if p.Directory == "" {
+			return fmt.Errorf("'%s' requires a non-empty 'directory' field", field)
+		}
+		directory := filepath.Clean(p.Directory)
+		if _, ok := directories[directory]; ok {
+			return fmt.Errorf("'%s.directory' is already used by another projection: %q", field, p.Directory)
+		}
+		directories[directory] = struct{}{}
+
+		if p.RetainPrevious < 0 {
+			return fmt.Errorf("'%s.retain_previous' must not be negative", field)
+		}
+
+		if len(p.Templates) == 0 {
+			return fmt.Errorf("'%s' must specify at least one 'template' element", field)
+		}
+		files := make(map[string]struct{})
+		for _, tmpl := range p.Templates {
+			file := filepath.Base(*tmpl.Destination)
+			if _, ok := files[file]; ok {
+				return fmt.Errorf("'%s': duplicate template destination: %q", field, file)
+			}
+			files[file] = struct{}{}
+		}
+	}
+
+	return nil
+}
+
+// HasEnvTemplates returns true if env templates are configured, either at the
+// top level or in named exec blocks.
+func (c *Config) HasEnvTemplates() bool {
+	if len(c.EnvTemplates) > 0 {
+		return true
+	}
+	for _, e := range c.Execs {
+		if len(e.EnvTemplates) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Config) validateEnvTemplateConfig() error {
 	// if we are not in env-template mode, exit early
-	if c.Exec == nil && len(c.EnvTemplates) == 0 {
+	if c.Exec == nil && len(c.Execs) == 0 && len(c.EnvTemplates) == 0 {
 		return nil
 	}
 
-	if c.Exec == nil {
-		return fmt.Errorf("a top-level 'exec' element must be specified with 'env_template' entries")
-	}
+	if len(c.Execs) > 0 {
+		if len(c.EnvTemplates) > 0 {
+			return fmt.Errorf("top-level 'env_template' entries cannot be specified with named 'exec' elements, specify them in the 'exec' elements instead")
+		}
+	} else {
+		if c.Exec == nil {
+			return fmt.Errorf("a top-level 'exec' element must be specified with 'env_template' entries")
+		}
 
-	if len(c.EnvTemplates) == 0 {
-		return fmt.Errorf("must specify at least one 'env_template' element with a top-level 'exec' element")
+		if len(c.EnvTemplates) == 0 {
+			return fmt.Errorf("must specify at least one 'env_template' element with a top-level 'exec' element")
+		}
 	}
 
 	if c.APIProxy != nil {
@@ -385,17 +550,56 @@ func (c *Config) validateEnvTemplateConfig() error {
 		return fmt.Errorf("'template' cannot be specified with 'env_template' entries")
 	}
 
-	if len(c.Exec.Command) == 0 {
-		return fmt.Errorf("'exec' requires a non-empty 'command' field")
+	if c.Exec != nil {
+		if err := validateExecConfig("exec", c.Exec); err != nil {
+			return err
+		}
+		return validateEnvTemplates(c.EnvTemplates)
+	}
+
+	for _, e := range c.Execs {
+		field := fmt.Sprintf("exec[%s]", e.Name)
+		if err := validateExecConfig(field, e); err != nil {
+			return err
+		}
+		if len(e.EnvTemplates) == 0 {
+			return fmt.Errorf("'%s' must specify at least one 'env_template' element", field)
+		}
+		if err := validateEnvTemplates(e.EnvTemplates); err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+	}
+
+	return nil
+}
+
+// validateExecConfig validates the exec block referred to as field in the
+// errors.
+func validateExecConfig(field string, e *ExecConfig) error {
+	if len(e.Command) == 0 {
+		return fmt.Errorf("'%s' requires a non-empty 'command' field", field)
+	}
+
+	if !slices.Contains([]string{"always", "never", "signal"}, e.RestartOnSecretChanges) {
+		return fmt.Errorf("'%s.restart_on_secret_changes' unexpected value: %q", field, e.RestartOnSecretChanges)
 	}
 
-	if !slices.Contains([]string{"always", "never"}, c.Exec.RestartOnSecretChanges) {
-		return fmt.Errorf("'exec.restart_on_secret_changes' unexpected value: %q", c.Exec.RestartOnSecretChanges)
+	if e.HealthCheckInterval < 0 {
+		return fmt.Errorf("'%s.health_check_interval' must not be negative", field)
 	}
 
+	if e.HealthCheckFailureThreshold < 0 {
+		return fmt.Errorf("'%s.health_check_failure_threshold' must not be negative", field)
+	}
+
+	return nil
+}
+
+// validateEnvTemplates validates the env templates of an exec block.
+func validateEnvTemplates(templates []*ctconfig.TemplateConfig) error {
 	uniqueKeys := make(map[string]struct{})
 
-	for _, template := range c.EnvTemplates {
+	for _, template := range templates {
 		// Required:
 		//   - the key (environment variable name)
 		//   - either "contents" or "source"
@@ -645,6 +849,10 @@ func LoadConfigFile(path string) (*Config, error) {
 		return nil, fmt.Errorf("error parsing 'template': %w", err)
 	}
 
+	if err := parseProjections(result, list); err != nil {
+		return nil, fmt.Errorf("error parsing 'projection': %w", err)
+	}
+
 	if err := parseExec(result, list); err != nil {
 		return nil, fmt.Errorf("error parsing 'exec': %w", err)
 	}
@@ -752,6 +960,27 @@ func parseVault(result *Config, list *ast.ObjectList) error {
 		}
 	}
 
+	if v.HealthCheckIntervalRaw != nil {
+		v.HealthCheckInterval, err = parseutil.ParseDurationSecond(v.HealthCheckIntervalRaw)
+		if err != nil {
+			return fmt.Errorf("error parsing 'health_check_interval': %w", err)
+		}
+		v.HealthCheckIntervalRaw = nil
+	}
+
+	for _, addr := range v.Addresses {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return fmt.Errorf("error parsing 'addresses': %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid address %q in 'addresses': scheme must be http or https", addr)
+		}
+	}
+	if v.Address == "" && len(v.Addresses) > 0 {
+		v.Address = v.Addresses[0]
+	}
+
 	result.Vault = &v
 
 	subs, ok := item.Val.(*ast.ObjectType)
@@ -970,22 +1199,6 @@ func parseAutoAuth(result *Config, list *ast.ObjectList) error {
 		}
 	}
 
-	if result.AutoAuth.Method.MaxBackoffRaw != nil {
-		var err error
-		if result.AutoAuth.Method.MaxBackoff, err = parseutil.ParseDurationSecond(result.AutoAuth.Method.MaxBackoffRaw); err != nil {
-			return err
-		}
-		result.AutoAuth.Method.MaxBackoffRaw = nil
-	}
-
-	if result.AutoAuth.Method.MinBackoffRaw != nil {
-		var err error
-		if result.AutoAuth.Method.MinBackoff, err = parseutil.ParseDurationSecond(result.AutoAuth.Method.MinBackoffRaw); err != nil {
-			return err
-		}
-		result.AutoAuth.Method.MinBackoffRaw = nil
-	}
-
 	return nil
 }
 
@@ -993,16 +1206,37 @@ func parseMethod(result *Config, list *ast.ObjectList) error {
 	name := "method"
 
 	methodList := list.Filter(name)
-	if len(methodList.Items) != 1 {
-		return fmt.Errorf("one and only one %q block is required", name)
+	if len(methodList.Items) < 1 {
+		return fmt.Errorf("at least one %q block is required", name)
 	}
 
-	// Get our item
-	item := methodList.Items[0]
+	// The first method is the primary one, the others are fallbacks in the
+	// order they are defined
+	for i, item := range methodList.Items {
+		m, err := parseMethodItem(item)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			result.AutoAuth.Method = m
+			continue
+		}
+
+		if m.WrapTTL > 0 {
+			return fmt.Errorf("method.%s: 'wrap_ttl' can only be set on the first method", m.Type)
+		}
+		if m.ExitOnError {
+			return fmt.Errorf("method.%s: 'exit_on_err' can only be set on the first method", m.Type)
+		}
+		result.AutoAuth.FallbackMethods = append(result.AutoAuth.FallbackMethods, m)
+	}
+	return nil
+}
 
+func parseMethodItem(item *ast.ObjectItem) (*Method, error) {
 	var m Method
 	if err := hcl.DecodeObject(&m, item.Val); err != nil {
-		return err
+		return nil, err
 	}
 
 	if m.Type == "" {
@@ -1010,7 +1244,7 @@ func parseMethod(result *Config, list *ast.ObjectList) error {
 			m.Type = strings.ToLower(item.Keys[0].Token.Value().(string))
 		}
 		if m.Type == "" {
-			return errors.New("method type must be specified")
+			return nil, errors.New("method type must be specified")
 		}
 	}
 
@@ -1024,16 +1258,31 @@ func parseMethod(result *Config, list *ast.ObjectList) error {
 	if m.WrapTTLRaw != nil {
 		var err error
 		if m.WrapTTL, err = parseutil.ParseDurationSecond(m.WrapTTLRaw); err != nil {
-			return err
+			return nil, err
 		}
 		m.WrapTTLRaw = nil
 	}
 
+	if m.MaxBackoffRaw != nil {
+		var err error
+		if m.MaxBackoff, err = parseutil.ParseDurationSecond(m.MaxBackoffRaw); err != nil {
+			return nil, err
+		}
+		m.MaxBackoffRaw = nil
+	}
+
+	if m.MinBackoffRaw != nil {
+		var err error
+		if m.MinBackoff, err = parseutil.ParseDurationSecond(m.MinBackoffRaw); err != nil {
+			return nil, err
+		}
+		m.MinBackoffRaw = nil
+	}
+
 	// Canonicalize namespace path if provided
 	m.Namespace = namespace.Canonicalize(m.Namespace)
 
-	result.AutoAuth.Method = &m
-	return nil
+	return &m, nil
 }
 
 func parseSinks(result *Config, list *ast.ObjectList) error {
@@ -1149,11 +1398,18 @@ func parseTemplateConfig(result *Config, list *ast.ObjectList) error {
 }
 
 func parseTemplates(result *Config, list *ast.ObjectList) error {
-	name := "template"
+	tcs, err := parseTemplateList(list.Filter("template"))
+	if err != nil {
+		return err
+	}
+	result.Templates = tcs
+	return nil
+}
 
-	templateList := list.Filter(name)
+// parseTemplateList parses the given template blocks.
+func parseTemplateList(templateList *ast.ObjectList) ([]*ctconfig.TemplateConfig, error) {
 	if len(templateList.Items) < 1 {
-		return nil
+		return nil, nil
 	}
 
 	var tcs []*ctconfig.TemplateConfig
@@ -1161,13 +1417,13 @@ func parseTemplates(result *Config, list *ast.ObjectList) error {
 	for _, item := range templateList.Items {
 		var shadow interface{}
 		if err := hcl.DecodeObject(&shadow, item.Val); err != nil {
-			return fmt.Errorf("error decoding config: %s", err)
+			return nil, fmt.Errorf("error decoding config: %s", err)
 		}
 
 		// Convert to a map and flatten the keys we want to flatten
 		parsed, ok := shadow.(map[string]interface{})
 		if !ok {
-			return errors.New("error converting config")
+			return nil, errors.New("error converting config")
 		}
 
 		// flatten the wait or exec fields. The initial "wait" or "exec" value, if given, is a
@@ -1204,17 +1460,121 @@ func parseTemplates(result *Config, list *ast.ObjectList) error {
 			Result:      &tc,
 		})
 		if err != nil {
-			return errors.New("mapstructure decoder creation failed")
+			return nil, errors.New("mapstructure decoder creation failed")
 		}
 		if err := decoder.Decode(parsed); err != nil {
-			return err
+			return nil, err
 		}
 		tcs = append(tcs, &tc)
 	}
-	result.Templates = tcs
+	return tcs, nil
+}
+
+func parseProjections(result *Config, list *ast.ObjectList) error {
+	name := "projection"
+
+	projectionList := list.Filter(name)
+	if len(projectionList.Items) == 0 {
+		return nil
+	}
+
+	for _, item := range projectionList.Items {
+		if len(item.Keys) != 1 {
+			return fmt.Errorf("expected one %q block name, got %d", name, len(item.Keys))
+		}
+
+		projection, err := parseProjectionItem(item)
+		if err != nil {
+			return err
+		}
+		// hcl parses this with extra quotes if quoted in config file
+		projection.Name = strings.Trim(item.Keys[0].Token.Text, `"`)
+
+		result.Projections = append(result.Projections, projection)
+		result.Templates = append(result.Templates, projection.Templates...)
+	}
+
 	return nil
 }
 
+// parseProjectionItem parses a projection block, along with its templates,
+// whose destinations are moved to the staging directory of the projection.
+func parseProjectionItem(item *ast.ObjectItem) (*ProjectionConfig, error) {
+	obj, ok := item.Val.(*ast.ObjectType)
+	if !ok {
+		return nil, errors.New("error converting config")
+	}
+
+	// The templates are parsed separately from the other fields
+	var fields []*ast.ObjectItem
+	for _, i := range obj.List.Items {
+		if len(i.Keys) > 0 && i.Keys[0].Token.Text == "template" {
+			continue
+		}
+		fields = append(fields, i)
+	}
+
+	var shadow interface{}
+	if err := hcl.DecodeObject(&shadow, &ast.ObjectType{List: &ast.ObjectList{Items: fields}}); err != nil {
+		return nil, fmt.Errorf("error decoding config: %s", err)
+	}
+
+	parsed, ok := shadow.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("error converting config")
+	}
+
+	var projection ProjectionConfig
+	var md mapstructure.Metadata
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			ctconfig.StringToFileModeFunc(),
+		),
+		ErrorUnused: true,
+		Metadata:    &md,
+		Result:      &projection,
+	})
+	if err != nil {
+		return nil, errors.New("mapstructure decoder creation failed")
+	}
+	if err := decoder.Decode(parsed); err != nil {
+		return nil, err
+	}
+
+	if projection.DirPerms == 0 {
+		projection.DirPerms = DefaultProjectionDirPerms
+	}
+	if projection.FilePerms == 0 {
+		projection.FilePerms = DefaultProjectionFilePerms
+	}
+	if _, ok := parsed["retain_previous"]; !ok {
+		projection.RetainPrevious = DefaultProjectionRetainPrevious
+	}
+
+	projection.Templates, err = parseTemplateList(obj.List.Filter("template"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing 'template': %w", err)
+	}
+
+	for _, tmpl := range projection.Templates {
+		// The destination of the templates is the name of their file in
+		// the projection
+		if tmpl.Destination == nil || *tmpl.Destination == "" {
+			return nil, errors.New("'template' elements of projections require a 'destination' file name")
+		}
+		file := *tmpl.Destination
+		if file != filepath.Base(file) || strings.HasPrefix(file, "..") || file == "." {
+			return nil, fmt.Errorf("'template' destination must be a file name not starting with \"..\", got %q", file)
+		}
+		if tmpl.Command != nil || tmpl.Exec != nil {
+			return nil, fmt.Errorf("'template' %q: commands are not supported in projections", file)
+		}
+		tmpl.Destination = pointerutil.StringPtr(filepath.Join(projection.StagingDir(), file))
+	}
+
+	return &projection, nil
+}
+
 func parseExec(result *Config, list *ast.ObjectList) error {
 	name := "exec"
 
@@ -1223,19 +1583,70 @@ func parseExec(result *Config, list *ast.ObjectList) error {
 		return nil
 	}
 
-	if len(execList.Items) > 1 {
-		return fmt.Errorf("at most one %q block is allowed", name)
+	// result.Exec may have been decoded along with the rest of the config
+	result.Exec = nil
+
+	names := make(map[string]struct{})
+	for _, item := range execList.Items {
+		execConfig, err := parseExecItem(item)
+		if err != nil {
+			return err
+		}
+
+		switch len(item.Keys) {
+		case 0:
+			if result.Exec != nil {
+				return fmt.Errorf("at most one unnamed %q block is allowed", name)
+			}
+			if len(execConfig.EnvTemplates) > 0 {
+				return fmt.Errorf("'env_template' elements are only allowed in named %q blocks", name)
+			}
+			result.Exec = execConfig
+		case 1:
+			// hcl parses this with extra quotes if quoted in config file
+			execConfig.Name = strings.Trim(item.Keys[0].Token.Text, `"`)
+			if _, ok := names[execConfig.Name]; ok {
+				return fmt.Errorf("duplicate %q block name: %q", name, execConfig.Name)
+			}
+			names[execConfig.Name] = struct{}{}
+			result.Execs = append(result.Execs, execConfig)
+		default:
+			return fmt.Errorf("expected at most one %q block name, got %d", name, len(item.Keys))
+		}
+	}
+
+	if result.Exec != nil && len(result.Execs) > 0 {
+		return fmt.Errorf("an unnamed %q block cannot be specified with named %q blocks", name, name)
+	}
+
+	return nil
+}
+
+// parseExecItem parses an exec block, along with the env templates it holds
+// when it is a named block.
+func parseExecItem(item *ast.ObjectItem) (*ExecConfig, error) {
+	obj, ok := item.Val.(*ast.ObjectType)
+	if !ok {
+		return nil, errors.New("error converting config")
+	}
+
+	// The env templates are parsed separately from the other fields
+	var fields []*ast.ObjectItem
+	for _, i := range obj.List.Items {
+		if len(i.Keys) > 0 && i.Keys[0].Token.Text == "env_template" {
+			continue
+		}
+		fields = append(fields, i)
 	}
 
-	item := execList.Items[0]
 	var shadow interface{}
-	if err := hcl.DecodeObject(&shadow, item.Val); err != nil {
-		return fmt.Errorf("error decoding config: %s", err)
+	if err := hcl.DecodeObject(&shadow, &ast.ObjectType{List: &ast.ObjectList{Items: fields}}); err != nil {
+		return nil, fmt.Errorf("error decoding config: %s", err)
 	}
 
 	parsed, ok := shadow.(map[string]interface{})
 	if !ok {
-		return errors.New("error converting config")
+		return nil, errors.New("error converting config")
 	}
 
 	var execConfig ExecConfig
@@ -1253,10 +1664,10 @@ func parseExec(result *Config, list *ast.ObjectList) error {
 		Result:      &execConfig,
 	})
 	if err != nil {
-		return errors.New("mapstructure decoder creation failed")
+		return nil, errors.New("mapstructure decoder creation failed")
 	}
 	if err := decoder.Decode(parsed); err != nil {
-		return err
+		return nil, err
 	}
 
 	// if the user does not specify a restart signal, default to SIGTERM
@@ -1268,17 +1679,44 @@ func parseExec(result *Config, list *ast.ObjectList) error {
 		execConfig.RestartOnSecretChanges = "always"
 	}
 
-	result.Exec = &execConfig
-	return nil
+	// if the user does not specify a reload signal, default to SIGHUP
+	if execConfig.RestartOnSecretChanges == "signal" && execConfig.ReloadSignal == nil {
+		execConfig.ReloadSignal = syscall.SIGHUP
+	}
+
+	if len(execConfig.HealthCheckCommand) > 0 {
+		if execConfig.HealthCheckInterval == 0 {
+			execConfig.HealthCheckInterval = DefaultExecHealthCheckInterval
+		}
+		if execConfig.HealthCheckFailureThreshold == 0 {
+			execConfig.HealthCheckFailureThreshold = DefaultExecHealthCheckFailureThreshold
+		}
+	}
+
+	execConfig.EnvTemplates, err = parseEnvTemplateList(obj.List.Filter("env_template"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing 'env_template': %w", err)
+	}
+
+	return &execConfig, nil
 }
 
 func parseEnvTemplates(result *Config, list *ast.ObjectList) error {
 	name := "env_template"
 
-	envTemplateList := list.Filter(name)
+	envTemplates, err := parseEnvTemplateList(list.Filter(name))
+	if err != nil {
+		return err
+	}
+
+	result.EnvTemplates = envTemplates
+	return nil
+}
 
+// parseEnvTemplateList parses the given env_template blocks.
+func parseEnvTemplateList(envTemplateList *ast.ObjectList) ([]*ctconfig.TemplateConfig, error) {
 	if len(envTemplateList.Items) < 1 {
-		return nil
+		return nil, nil
 	}
 
 	envTemplates := make([]*ctconfig.TemplateConfig, 0, len(envTemplateList.Items))
@@ -1286,13 +1724,13 @@ func parseEnvTemplates(result *Config, list *ast.ObjectList) error {
 	for _, item := range envTemplateList.Items {
 		var shadow interface{}
 		if err := hcl.DecodeObject(&shadow, item.Val); err != nil {
-			return fmt.Errorf("error decoding config: %s", err)
+			return nil, fmt.Errorf("error decoding config: %s", err)
 		}
 
 		// Convert to a map and flatten the keys we want to flatten
 		parsed, ok := shadow.(map[string]any)
 		if !ok {
-			return errors.New("error converting config")
+			return nil, errors.New("error converting config")
 		}
 
 		var templateConfig ctconfig.TemplateConfig
@@ -1310,15 +1748,15 @@ func parseEnvTemplates(result *Config, list *ast.ObjectList) error {
 			Result:      &templateConfig,
 		})
 		if err != nil {
-			return errors.New("mapstructure decoder creation failed")
+			return nil, errors.New("mapstructure decoder creation failed")
 		}
 		if err := decoder.Decode(parsed); err != nil {
-			return err
+			return nil, err
 		}
 
 		// parse the keys in the item for the environment variable name
 		if numberOfKeys := len(item.Keys); numberOfKeys != 1 {
-			return fmt.Errorf("expected one and only one environment variable name, got %d", numberOfKeys)
+			return nil, fmt.Errorf("expected one and only one environment variable name, got %d", numberOfKeys)
 		}
 
 		// hcl parses this with extra quotes if quoted in config file
@@ -1329,6 +1767,5 @@ func parseEnvTemplates(result *Config, list *ast.ObjectList) error {
 		envTemplates = append(envTemplates, &templateConfig)
 	}
 
-	result.EnvTemplates = envTemplates
-	return nil
+	return envTemplates, nil
 }
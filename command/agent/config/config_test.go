@@ -722,6 +722,108 @@ func TestLoadConfigFile_Bad_AutoAuth_Nosinks_Nocache_Notemplates(t *testing.T) {
 	}
 }
 
+func TestLoadConfigFile_Method_Fallback(t *testing.T) {
+	config, err := LoadConfigFile("./test-fixtures/config-method-fallback.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		SharedConfig: &configutil.SharedConfig{
+			PidFile: "./pidfile",
+		},
+		AutoAuth: &AutoAuth{
+			Method: &Method{
+				Type:       "aws",
+				MountPath:  "auth/aws",
+				MaxBackoff: 2 * time.Minute,
+				Config: map[string]interface{}{
+					"role": "foobar",
+				},
+			},
+			FallbackMethods: []*Method{
+				{
+					Type:       "cert",
+					MountPath:  "auth/cert-fallback",
+					Namespace:  "ns1/",
+					MinBackoff: 2 * time.Second,
+					MaxBackoff: 30 * time.Second,
+					Config: map[string]interface{}{
+						"name": "web",
+					},
+				},
+				{
+					Type:      "approle",
+					MountPath: "auth/approle",
+					Config: map[string]interface{}{
+						"role_id_file_path":   "/tmp/role-id",
+						"secret_id_file_path": "/tmp/secret-id",
+					},
+				},
+			},
+			Sinks: []*Sink{
+				{
+					Type: "file",
+					Config: map[string]interface{}{
+						"path": "/tmp/file-foo",
+					},
+				},
+			},
+		},
+		TemplateConfig: &TemplateConfig{
+			MaxConnectionsPerHost: DefaultTemplateConfigMaxConnsPerHost,
+		},
+	}
+
+	config.Prune()
+	if diff := deep.Equal(config, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestLoadConfigFile_VaultAddresses(t *testing.T) {
+	config, err := LoadConfigFile("./test-fixtures/config-vault-addresses.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		SharedConfig: &configutil.SharedConfig{
+			PidFile: "./pidfile",
+		},
+		Vault: &Vault{
+			Address:             "https://vault-1:8200",
+			Addresses:           []string{"https://vault-1:8200", "https://vault-2:8200"},
+			HealthCheckInterval: 30 * time.Second,
+			Retry: &Retry{
+				NumRetries: 12,
+			},
+		},
+		TemplateConfig: &TemplateConfig{
+			MaxConnectionsPerHost: DefaultTemplateConfigMaxConnsPerHost,
+		},
+	}
+
+	config.Prune()
+	if diff := deep.Equal(config, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestLoadConfigFile_Bad_VaultAddresses(t *testing.T) {
+	_, err := LoadConfigFile("./test-fixtures/bad-config-vault-addresses.hcl")
+	if err == nil {
+		t.Fatal("LoadConfigFile should return an error for an address that is not http or https")
+	}
+}
+
+func TestLoadConfigFile_Bad_AutoAuth_Fallback_Method_Wrapping(t *testing.T) {
+	_, err := LoadConfigFile("./test-fixtures/bad-config-method-fallback-wrapping.hcl")
+	if err == nil {
+		t.Fatalf("LoadConfigFile should return an error for this config")
+	}
+}
+
 func TestLoadConfigFile_Bad_AutoAuth_Both_Wrapping_Types(t *testing.T) {
 	_, err := LoadConfigFile("./test-fixtures/bad-config-method-wrapping-and-sink-wrapping.hcl")
 	if err == nil {
@@ -1029,6 +1131,25 @@ func TestLoadConfigFile_AgentCache_Persist(t *testing.T) {
 	}
 }
 
+func TestLoadConfigFile_AgentCache_PersistFile(t *testing.T) {
+	config, err := LoadConfigFile("./test-fixtures/config-cache-persist-file.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &agentproxyshared.PersistConfig{
+		Type:             "file",
+		Path:             "/var/lib/vault-agent/cache",
+		TransitMountPath: "agent-transit",
+		TransitKeyName:   "agent-cache",
+		TransitTokenFile: "/etc/vault-agent/transit-token",
+	}
+
+	if diff := deep.Equal(config.Cache.Persist, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
 func TestLoadConfigFile_AgentCache_PersistMissingType(t *testing.T) {
 	_, err := LoadConfigFile("./test-fixtures/config-cache-persist-empty-type.hcl")
 	if err == nil || os.IsNotExist(err) {
@@ -1062,6 +1183,14 @@ func TestLoadConfigFile_TemplateConfig(t *testing.T) {
 				MaxConnectionsPerHost: 10,
 			},
 		},
+		"snapshot": {
+			"./test-fixtures/config-template_config-snapshot.hcl",
+			TemplateConfig{
+				MaxConnectionsPerHost: 10,
+				SnapshotFile:          "/var/lib/vault-agent/templates.snapshot",
+				SnapshotKeyFile:       "/etc/vault-agent/snapshot.key",
+			},
+		},
 	}
 
 	for name, tc := range testCases {
@@ -2447,3 +2576,130 @@ func TestLoadConfigFile_Bad_EnvTemplates_DisalowedFields(t *testing.T) {
 		t.Fatal("expected an error from ValidateConfig: disallowed fields specified in env_template")
 	}
 }
+
+// TestLoadConfigFile_Exec_Named tests loading named exec blocks holding their
+// own env templates.
+func TestLoadConfigFile_Exec_Named(t *testing.T) {
+	cfg, err := LoadConfigFile("./test-fixtures/config-exec-named.hcl")
+	if err != nil {
+		t.Fatalf("error loading config file: %s", err)
+	}
+
+	if err := cfg.ValidateConfig(); err != nil {
+		t.Fatalf("validation error: %s", err)
+	}
+
+	if cfg.Exec != nil {
+		t.Fatalf("expected no unnamed exec block, got %#v", cfg.Exec)
+	}
+
+	expected := []*ExecConfig{
+		{
+			Name:                   "db-proxy",
+			Command:                []string{"./db-proxy"},
+			RestartOnSecretChanges: "always",
+			RestartStopSignal:      syscall.SIGTERM,
+			EnvTemplates: []*ctconfig.TemplateConfig{{
+				Contents:                 pointerutil.StringPtr(`{{ with secret "secret/data/db" }}{{ .Data.data.password }}{{ end }}`),
+				MapToEnvironmentVariable: pointerutil.StringPtr("DB_PASSWORD"),
+			}},
+		},
+		{
+			Name:                        "web",
+			Command:                     []string{"./web", "--port", "8080"},
+			RestartOnSecretChanges:      "signal",
+			RestartStopSignal:           syscall.SIGTERM,
+			ReloadSignal:                syscall.SIGHUP,
+			HealthCheckCommand:          []string{"curl", "-f", "http://localhost:8080/health"},
+			HealthCheckInterval:         30 * time.Second,
+			HealthCheckFailureThreshold: DefaultExecHealthCheckFailureThreshold,
+			ChildProcessStdout:          "/var/log/web.log",
+			EnvTemplates: []*ctconfig.TemplateConfig{{
+				Contents:                 pointerutil.StringPtr(`{{ with secret "secret/data/web" }}{{ .Data.data.api_key }}{{ end }}`),
+				MapToEnvironmentVariable: pointerutil.StringPtr("API_KEY"),
+			}, {
+				Contents:                 pointerutil.StringPtr(`{{ with secret "secret/data/web-db" }}{{ .Data.data.password }}{{ end }}`),
+				ErrMissingKey:            pointerutil.BoolPtr(true),
+				MapToEnvironmentVariable: pointerutil.StringPtr("DB_PASSWORD"),
+			}},
+		},
+	}
+
+	if diff := deep.Equal(cfg.Execs, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+// TestLoadConfigFile_Bad_Exec_NamedWithEnvTemplates ensures that ValidateConfig
+// errors when top-level "env_template" stanzas are specified with named "exec"
+// stanzas
+func TestLoadConfigFile_Bad_Exec_NamedWithEnvTemplates(t *testing.T) {
+	config, err := LoadConfigFile("./test-fixtures/bad-config-exec-named-with-env-templates.hcl")
+	if err != nil {
+		t.Fatalf("error loading config file: %s", err)
+	}
+
+	if err := config.ValidateConfig(); err == nil {
+		t.Fatal("expected an error from ValidateConfig: top-level env_template is not compatible with named exec stanzas")
+	}
+}
+
+// TestLoadConfigFile_Projection tests loading projection blocks, whose
+// templates are rendered to the staging directory of the projection.
+func TestLoadConfigFile_Projection(t *testing.T) {
+	cfg, err := LoadConfigFile("./test-fixtures/config-projection.hcl")
+	if err != nil {
+		t.Fatalf("error loading config file: %s", err)
+	}
+
+	if err := cfg.ValidateConfig(); err != nil {
+		t.Fatalf("validation error: %s", err)
+	}
+
+	username := &ctconfig.TemplateConfig{
+		Contents:    pointerutil.StringPtr(`{{ with secret "secret/data/db" }}{{ .Data.data.username }}{{ end }}`),
+		Destination: pointerutil.StringPtr("/var/run/secrets/db/..staging/username"),
+	}
+	password := &ctconfig.TemplateConfig{
+		Contents:    pointerutil.StringPtr(`{{ with secret "secret/data/db" }}{{ .Data.data.password }}{{ end }}`),
+		Destination: pointerutil.StringPtr("/var/run/secrets/db/..staging/password"),
+	}
+
+	expected := []*ProjectionConfig{
+		{
+			Name:           "db",
+			Directory:      "/var/run/secrets/db",
+			User:           "1000",
+			Group:          "1000",
+			DirPerms:       0o750,
+			FilePerms:      0o640,
+			RetainPrevious: 2,
+			Templates:      []*ctconfig.TemplateConfig{username, password},
+		},
+	}
+	if diff := deep.Equal(cfg.Projections, expected); diff != nil {
+		t.Fatal(diff)
+	}
+
+	// The templates of the projection are rendered along with the others
+	expectedTemplates := []*ctconfig.TemplateConfig{
+		{
+			Contents:    pointerutil.StringPtr(`{{ with secret "secret/data/app" }}{{ .Data.data.api_key }}{{ end }}`),
+			Destination: pointerutil.StringPtr("/etc/app/api_key"),
+		},
+		username,
+		password,
+	}
+	if diff := deep.Equal(cfg.Templates, expectedTemplates); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+// TestLoadConfigFile_Bad_Projection_Destination ensures that the templates of
+// projections cannot be rendered outside of the projection directory
+func TestLoadConfigFile_Bad_Projection_Destination(t *testing.T) {
+	_, err := LoadConfigFile("./test-fixtures/bad-config-projection-destination.hcl")
+	if err == nil {
+		t.Fatal("expected an error loading a projection template with a path as destination")
+	}
+}
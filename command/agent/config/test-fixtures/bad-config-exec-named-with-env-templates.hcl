@@ -0,0 +1,30 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: BUSL-1.1
+
+auto_auth {
+
+  method {
+    type = "token_file"
+
+    config {
+      token_file_path = "/home/username/.vault-token"
+    }
+  }
+}
+
+vault {
+  address = "http://localhost:8200"
+}
+
+# Error: top-level env_template entries cannot be used with named exec blocks
+env_template "API_KEY" {
+  contents = "{{ with secret \"secret/data/web\" }}{{ .Data.data.api_key }}{{ end }}"
+}
+
+exec "web" {
+  command = ["./web"]
+
+  env_template "DB_PASSWORD" {
+    contents = "{{ with secret \"secret/data/db\" }}{{ .Data.data.password }}{{ end }}"
+  }
+}
@@ -0,0 +1,22 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: BUSL-1.1
+
+auto_auth {
+
+  method {
+    type = "token_file"
+
+    config {
+      token_file_path = "/home/username/.vault-token"
+    }
+  }
+}
+
+projection "db" {
+  directory = "/var/run/secrets/db"
+
+  template {
+    contents    = "{{ with secret \"secret/data/db\" }}{{ .Data.data.password }}{{ end }}"
+    destination = "/etc/db/password"
+  }
+}
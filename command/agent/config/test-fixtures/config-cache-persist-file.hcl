@@ -0,0 +1,18 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: BUSL-1.1
+
+pid_file = "./pidfile"
+
+cache {
+    persist "file" {
+        path = "/var/lib/vault-agent/cache"
+        transit_mount_path = "agent-transit"
+        transit_key_name = "agent-cache"
+        transit_token_file = "/etc/vault-agent/transit-token"
+    }
+}
+
+listener "tcp" {
+    address = "127.0.0.1:8300"
+    tls_disable = true
+}
@@ -0,0 +1,42 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: BUSL-1.1
+
+auto_auth {
+
+  method {
+    type = "token_file"
+
+    config {
+      token_file_path = "/home/username/.vault-token"
+    }
+  }
+}
+
+vault {
+  address = "http://localhost:8200"
+}
+
+exec "db-proxy" {
+  command = ["./db-proxy"]
+
+  env_template "DB_PASSWORD" {
+    contents = "{{ with secret \"secret/data/db\" }}{{ .Data.data.password }}{{ end }}"
+  }
+}
+
+exec "web" {
+  command                        = ["./web", "--port", "8080"]
+  restart_on_secret_changes      = "signal"
+  health_check_command           = ["curl", "-f", "http://localhost:8080/health"]
+  health_check_interval          = "30s"
+  child_process_stdout           = "/var/log/web.log"
+
+  env_template "API_KEY" {
+    contents = "{{ with secret \"secret/data/web\" }}{{ .Data.data.api_key }}{{ end }}"
+  }
+
+  env_template "DB_PASSWORD" {
+    contents             = "{{ with secret \"secret/data/web-db\" }}{{ .Data.data.password }}{{ end }}"
+    error_on_missing_key = true
+  }
+}
@@ -0,0 +1,39 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: BUSL-1.1
+
+pid_file = "./pidfile"
+
+auto_auth {
+	method {
+		type = "aws"
+		config = {
+			role = "foobar"
+		}
+		max_backoff = "2m"
+	}
+
+	method "cert" {
+		mount_path = "auth/cert-fallback/"
+		namespace = "ns1/"
+		min_backoff = "2s"
+		max_backoff = "30s"
+		config = {
+			name = "web"
+		}
+	}
+
+	method {
+		type = "approle"
+		config = {
+			role_id_file_path = "/tmp/role-id"
+			secret_id_file_path = "/tmp/secret-id"
+		}
+	}
+
+	sink {
+		type = "file"
+		config = {
+			path = "/tmp/file-foo"
+		}
+	}
+}
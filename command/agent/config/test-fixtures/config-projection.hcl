@@ -0,0 +1,41 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: BUSL-1.1
+
+auto_auth {
+
+  method {
+    type = "token_file"
+
+    config {
+      token_file_path = "/home/username/.vault-token"
+    }
+  }
+}
+
+vault {
+  address = "http://localhost:8200"
+}
+
+template {
+  contents    = "{{ with secret \"secret/data/app\" }}{{ .Data.data.api_key }}{{ end }}"
+  destination = "/etc/app/api_key"
+}
+
+projection "db" {
+  directory       = "/var/run/secrets/db"
+  user            = "1000"
+  group           = "1000"
+  dir_perms       = "0750"
+  file_perms      = "0640"
+  retain_previous = 2
+
+  template {
+    contents    = "{{ with secret \"secret/data/db\" }}{{ .Data.data.username }}{{ end }}"
+    destination = "username"
+  }
+
+  template {
+    contents    = "{{ with secret \"secret/data/db\" }}{{ .Data.data.password }}{{ end }}"
+    destination = "password"
+  }
+}
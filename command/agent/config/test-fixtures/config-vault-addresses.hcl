@@ -0,0 +1,9 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: BUSL-1.1
+
+pid_file = "./pidfile"
+
+vault {
+	addresses = ["https://vault-1:8200", "https://vault-2:8200"]
+	health_check_interval = "30s"
+}
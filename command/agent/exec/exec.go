@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	osexec "os/exec"
 	"sort"
 	"sync"
 	"time"
@@ -50,11 +51,28 @@ type ServerConfig struct {
 	LogWriter io.Writer
 }
 
+// Server supervises the child processes of the exec blocks. The child
+// processes are started in the order of the exec blocks, and stopped in the
+// reverse order.
 type Server struct {
 	// config holds the ServerConfig used to create it. It's passed along in other
 	// methods
 	config *ServerConfig
 
+	logger hclog.Logger
+
+	processes []*process
+}
+
+// process is the child process of an exec block, along with the
+// consul-template runner rendering its environment variables.
+type process struct {
+	// name is the name of the exec block, empty for the unnamed block
+	name         string
+	execConfig   *config.ExecConfig
+	envTemplates []*ctconfig.TemplateConfig
+	logger       hclog.Logger
+
 	// runner is the consul-template runner
 	runner *manager.Runner
 
@@ -64,20 +82,33 @@ type Server struct {
 	// NOTE: each template may have more than one TemplateConfig, so the numbers may not match up
 	numberOfTemplates int
 
-	logger hclog.Logger
-
 	childProcess       *child.Child
 	childProcessState  childProcessState
 	childProcessLock   sync.Mutex
 	childProcessStdout io.WriteCloser
 	childProcessStderr io.WriteCloser
 
+	// starts and healthCheckFailures are reported in the health status
+	starts              uint64
+	healthCheckFailures int
+
 	// exit channel of the child process
 	childProcessExitCh chan int
 
 	// lastRenderedEnvVars is the cached value of all environment variables
-	// rendered by the templating engine; it is used for detecting changes
+	// rendered by the templating engine; it is used for detecting changes.
+	// It is only written by run, with the childProcessLock held, as the
+	// health check reads it to restart the child process.
 	lastRenderedEnvVars []string
+
+	// previous is the process of the previous exec block, whose child process
+	// must have started before this one is started
+	previous *process
+
+	// startedCh is closed once the child process first started and, when it
+	// is health checked, passed its first health check
+	startedCh   chan struct{}
+	startedOnce sync.Once
 }
 
 type ProcessExitError struct {
@@ -89,82 +120,184 @@ func (e *ProcessExitError) Error() string {
 }
 
 func NewServer(cfg *ServerConfig) (*Server, error) {
+	server := Server{
+		logger: cfg.Logger,
+		config: cfg,
+	}
+
+	execConfigs := cfg.AgentConfig.Execs
+	if cfg.AgentConfig.Exec != nil {
+		execConfigs = []*config.ExecConfig{cfg.AgentConfig.Exec}
+	}
+
+	for _, execConfig := range execConfigs {
+		envTemplates := execConfig.EnvTemplates
+		logger := cfg.Logger
+		if execConfig.Name == "" {
+			envTemplates = cfg.AgentConfig.EnvTemplates
+		} else {
+			logger = logger.With("exec", execConfig.Name)
+		}
+
+		p, err := newProcess(execConfig, envTemplates, logger)
+		if err != nil {
+			server.close()
+			return nil, err
+		}
+		if len(server.processes) > 0 {
+			p.previous = server.processes[len(server.processes)-1]
+		}
+		server.processes = append(server.processes, p)
+	}
+
+	return &server, nil
+}
+
+func newProcess(execConfig *config.ExecConfig, envTemplates []*ctconfig.TemplateConfig, logger hclog.Logger) (*process, error) {
 	var err error
 
-	childProcessStdout := os.Stdout
-	childProcessStderr := os.Stderr
+	var childProcessStdout io.WriteCloser = os.Stdout
+	var childProcessStderr io.WriteCloser = os.Stderr
 
-	if cfg.AgentConfig.Exec != nil {
-		if cfg.AgentConfig.Exec.ChildProcessStdout != "" {
-			childProcessStdout, err = os.OpenFile(cfg.AgentConfig.Exec.ChildProcessStdout, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
-			if err != nil {
-				return nil, fmt.Errorf("could not open %q, %w", cfg.AgentConfig.Exec.ChildProcessStdout, err)
-			}
+	if execConfig.ChildProcessStdout != "" {
+		childProcessStdout, err = os.OpenFile(execConfig.ChildProcessStdout, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("could not open %q, %w", execConfig.ChildProcessStdout, err)
 		}
+	}
 
-		if cfg.AgentConfig.Exec.ChildProcessStderr != "" {
-			childProcessStderr, err = os.OpenFile(cfg.AgentConfig.Exec.ChildProcessStderr, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
-			if err != nil {
-				return nil, fmt.Errorf("could not open %q, %w", cfg.AgentConfig.Exec.ChildProcessStdout, err)
+	if execConfig.ChildProcessStderr != "" {
+		childProcessStderr, err = os.OpenFile(execConfig.ChildProcessStderr, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			if childProcessStdout != os.Stdout {
+				_ = childProcessStdout.Close()
 			}
+			return nil, fmt.Errorf("could not open %q, %w", execConfig.ChildProcessStderr, err)
 		}
 	}
 
-	server := Server{
-		logger:             cfg.Logger,
-		config:             cfg,
+	return &process{
+		name:               execConfig.Name,
+		execConfig:         execConfig,
+		envTemplates:       envTemplates,
+		logger:             logger,
 		childProcessState:  childProcessStateNotStarted,
 		childProcessExitCh: make(chan int),
 		childProcessStdout: childProcessStdout,
 		childProcessStderr: childProcessStderr,
-	}
-
-	return &server, nil
+		startedCh:          make(chan struct{}),
+	}, nil
 }
 
 func (s *Server) Run(ctx context.Context, incomingVaultToken chan string) error {
-	latestToken := new(string)
 	s.logger.Info("starting exec server")
 	defer func() {
 		s.logger.Info("exec server stopped")
 	}()
 
-	if len(s.config.AgentConfig.EnvTemplates) == 0 || s.config.AgentConfig.Exec == nil {
+	if len(s.processes) == 0 || !s.config.AgentConfig.HasEnvTemplates() {
 		s.logger.Info("no env templates or exec config, exiting")
 		<-ctx.Done()
 		return nil
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Each process renders its own templates, so each of them gets the
+	// tokens
+	tokenChs := make([]chan string, len(s.processes))
+	errCh := make(chan error, len(s.processes))
+	var wg sync.WaitGroup
+	for i, p := range s.processes {
+		tokenChs[i] = make(chan string, 1)
+		wg.Add(1)
+		go func(p *process, tokenCh chan string) {
+			defer wg.Done()
+			err := p.run(ctx, s.config, tokenCh)
+			if err != nil && p.name != "" {
+				err = fmt.Errorf("exec %q: %w", p.name, err)
+			}
+			errCh <- err
+		}(p, tokenChs[i])
+	}
+
+	var err error
+LOOP:
+	for {
+		select {
+		case <-ctx.Done():
+			break LOOP
+
+		case token := <-incomingVaultToken:
+			for _, tokenCh := range tokenChs {
+				// only the latest token matters
+				select {
+				case <-tokenCh:
+				default:
+				}
+				tokenCh <- token
+			}
+
+		case err = <-errCh:
+			if err != nil {
+				break LOOP
+			}
+		}
+	}
+
+	cancel()
+	wg.Wait()
+
+	// stop the child processes in the reverse order they were started in
+	for i := len(s.processes) - 1; i >= 0; i-- {
+		s.processes[i].stop()
+	}
+
+	return err
+}
+
+// run renders the templates of the process, and (re)starts its child process
+// as they change, until the context is done. The child process is left
+// running when the context is done.
+func (p *process) run(ctx context.Context, serverConfig *ServerConfig, incomingVaultToken chan string) error {
+	latestToken := new(string)
+
 	managerConfig := ctmanager.ManagerConfig{
-		AgentConfig: s.config.AgentConfig,
-		Namespace:   s.config.Namespace,
-		LogLevel:    s.config.LogLevel,
-		LogWriter:   s.config.LogWriter,
+		AgentConfig: serverConfig.AgentConfig,
+		Namespace:   serverConfig.Namespace,
+		LogLevel:    serverConfig.LogLevel,
+		LogWriter:   serverConfig.LogWriter,
 	}
 
-	runnerConfig, err := ctmanager.NewConfig(managerConfig, s.config.AgentConfig.EnvTemplates)
+	runnerConfig, err := ctmanager.NewConfig(managerConfig, p.envTemplates)
 	if err != nil {
 		return fmt.Errorf("template server failed to generate runner config: %w", err)
 	}
 
 	// We leave this in "dry" mode, as there are no files to render;
 	// we will get the environment variables rendered contents from the incoming events
-	s.runner, err = manager.NewRunner(runnerConfig, true)
+	p.runner, err = manager.NewRunner(runnerConfig, true)
 	if err != nil {
 		return fmt.Errorf("template server failed to create: %w", err)
 	}
 
 	// prevent the templates from being rendered to stdout in "dry" mode
-	s.runner.SetOutStream(io.Discard)
+	p.runner.SetOutStream(io.Discard)
 
-	s.numberOfTemplates = len(s.runner.TemplateConfigMapping())
+	p.numberOfTemplates = len(p.runner.TemplateConfigMapping())
 
 	// We receive multiple events every staticSecretRenderInterval
-	// from <-s.runner.TemplateRenderedCh(), one for each secret. Only the last
+	// from <-p.runner.TemplateRenderedCh(), one for each secret. Only the last
 	// event in a batch will contain the latest set of all secrets and the
 	// corresponding environment variables. This timer will fire after 2 seconds
 	// unless an event comes in which resets the timer back to 2 seconds.
 	var debounceTimer *time.Timer
+	defer func() {
+		if debounceTimer != nil {
+			debounceTimer.Stop()
+		}
+	}()
 
 	// capture the errors related to restarting the child process
 	restartChildProcessErrCh := make(chan error)
@@ -172,21 +305,14 @@ func (s *Server) Run(ctx context.Context, incomingVaultToken chan string) error
 	for {
 		select {
 		case <-ctx.Done():
-			s.runner.Stop()
-			s.childProcessLock.Lock()
-			if s.childProcess != nil {
-				s.childProcess.Stop()
-			}
-			s.childProcessState = childProcessStateStopped
-			s.close()
-			s.childProcessLock.Unlock()
+			p.runner.Stop()
 			return nil
 
 		case token := <-incomingVaultToken:
 			if token != *latestToken {
-				s.logger.Info("exec server received new token")
+				p.logger.Info("exec server received new token")
 
-				s.runner.Stop()
+				p.runner.Stop()
 				*latestToken = token
 				newTokenConfig := ctconfig.Config{
 					Vault: &ctconfig.VaultConfig{
@@ -197,41 +323,41 @@ func (s *Server) Run(ctx context.Context, incomingVaultToken chan string) error
 
 				// got a new auth token, merge it in with the existing config
 				runnerConfig = runnerConfig.Merge(&newTokenConfig)
-				s.runner, err = manager.NewRunner(runnerConfig, true)
+				p.runner, err = manager.NewRunner(runnerConfig, true)
 				if err != nil {
-					s.logger.Error("template server failed with new Vault token", "error", err)
+					p.logger.Error("template server failed with new Vault token", "error", err)
 					continue
 				}
 
 				// prevent the templates from being rendered to stdout in "dry" mode
-				s.runner.SetOutStream(io.Discard)
+				p.runner.SetOutStream(io.Discard)
 
-				go s.runner.Start()
+				go p.runner.Start()
 			}
 
-		case err := <-s.runner.ErrCh:
-			s.logger.Error("template server error", "error", err.Error())
-			s.runner.StopImmediately()
+		case err := <-p.runner.ErrCh:
+			p.logger.Error("template server error", "error", err.Error())
+			p.runner.StopImmediately()
 
 			// Return after stopping the runner if exit on retry failure was specified
-			if s.config.AgentConfig.TemplateConfig != nil && s.config.AgentConfig.TemplateConfig.ExitOnRetryFailure {
+			if serverConfig.AgentConfig.TemplateConfig != nil && serverConfig.AgentConfig.TemplateConfig.ExitOnRetryFailure {
 				return fmt.Errorf("template server: %w", err)
 			}
 
-			s.runner, err = manager.NewRunner(runnerConfig, true)
+			p.runner, err = manager.NewRunner(runnerConfig, true)
 			if err != nil {
 				return fmt.Errorf("template server failed to create: %w", err)
 			}
-			go s.runner.Start()
+			go p.runner.Start()
 
-		case <-s.runner.TemplateRenderedCh():
+		case <-p.runner.TemplateRenderedCh():
 			// A template has been rendered, figure out what to do
-			s.logger.Trace("template rendered")
-			events := s.runner.RenderEvents()
+			p.logger.Trace("template rendered")
+			events := p.runner.RenderEvents()
 
 			// This checks if we've finished rendering the initial set of templates,
-			// for every consecutive re-render len(events) should equal s.numberOfTemplates
-			if len(events) < s.numberOfTemplates {
+			// for every consecutive re-render len(events) should equal p.numberOfTemplates
+			if len(events) < p.numberOfTemplates {
 				// Not all templates have been rendered yet
 				continue
 			}
@@ -258,24 +384,29 @@ func (s *Server) Run(ctx context.Context, incomingVaultToken chan string) error
 			// sort the environment variables for a deterministic output and easy comparison
 			sort.Strings(renderedEnvVars)
 
-			s.logger.Trace("done rendering templates")
+			p.logger.Trace("done rendering templates")
 
 			// don't restart the process unless a change is detected
-			if slices.Equal(s.lastRenderedEnvVars, renderedEnvVars) {
+			if slices.Equal(p.lastRenderedEnvVars, renderedEnvVars) {
 				continue
 			}
 
-			s.lastRenderedEnvVars = renderedEnvVars
+			p.childProcessLock.Lock()
+			p.lastRenderedEnvVars = renderedEnvVars
+			p.childProcessLock.Unlock()
 
-			s.logger.Debug("detected a change in the environment variables: restarting the child process")
+			p.logger.Debug("detected a change in the environment variables: restarting the child process")
 
 			// if a timer exists, stop it
 			if debounceTimer != nil {
 				debounceTimer.Stop()
 			}
 			debounceTimer = time.AfterFunc(2*time.Second, func() {
-				if err := s.restartChildProcess(renderedEnvVars); err != nil {
-					restartChildProcessErrCh <- fmt.Errorf("unable to restart the child process: %w", err)
+				if err := p.restartChildProcess(ctx, renderedEnvVars); err != nil {
+					select {
+					case restartChildProcessErrCh <- fmt.Errorf("unable to restart the child process: %w", err):
+					case <-ctx.Done():
+					}
 				}
 			})
 
@@ -283,66 +414,100 @@ func (s *Server) Run(ctx context.Context, incomingVaultToken chan string) error
 			// catch the error from restarting
 			return err
 
-		case exitCode := <-s.childProcessExitCh:
+		case exitCode := <-p.childProcessExitCh:
 			// process exited on its own
 			return &ProcessExitError{ExitCode: exitCode}
 		}
 	}
 }
 
-func (s *Server) restartChildProcess(newEnvVars []string) error {
-	s.childProcessLock.Lock()
-	defer s.childProcessLock.Unlock()
+// restartChildProcess applies the restart policy of the process after its
+// environment variables changed, starting the child process if it is not
+// running yet.
+func (p *process) restartChildProcess(ctx context.Context, newEnvVars []string) error {
+	// The child processes are started in order
+	if p.previous != nil {
+		select {
+		case <-p.previous.startedCh:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	p.childProcessLock.Lock()
+	defer p.childProcessLock.Unlock()
+
+	if ctx.Err() != nil {
+		return nil
+	}
 
-	switch s.config.AgentConfig.Exec.RestartOnSecretChanges {
+	switch p.execConfig.RestartOnSecretChanges {
 	case "always":
-		if s.childProcessState == childProcessStateRunning {
+		if p.childProcessState == childProcessStateRunning {
 			// process is running, need to kill it first
-			s.logger.Info("stopping process", "process_id", s.childProcess.Pid())
-			s.childProcessState = childProcessStateRestarting
-			s.childProcess.Stop()
+			p.logger.Info("stopping process", "process_id", p.childProcess.Pid())
+			p.childProcessState = childProcessStateRestarting
+			p.childProcess.Stop()
 		}
 	case "never":
-		if s.childProcessState == childProcessStateRunning {
-			s.logger.Info("detected update, but not restarting process", "process_id", s.childProcess.Pid())
+		if p.childProcessState == childProcessStateRunning {
+			p.logger.Info("detected update, but not restarting process", "process_id", p.childProcess.Pid())
+			return nil
+		}
+	case "signal":
+		if p.childProcessState == childProcessStateRunning {
+			// the new environment variables are used the next time the
+			// process is restarted
+			p.logger.Info("detected update, signaling process", "process_id", p.childProcess.Pid(), "signal", p.execConfig.ReloadSignal)
+			if err := p.childProcess.Signal(p.execConfig.ReloadSignal); err != nil {
+				return fmt.Errorf("unable to signal the child process: %w", err)
+			}
 			return nil
 		}
 	default:
-		return fmt.Errorf("invalid value for restart-on-secret-changes: %q", s.config.AgentConfig.Exec.RestartOnSecretChanges)
+		return fmt.Errorf("invalid value for restart-on-secret-changes: %q", p.execConfig.RestartOnSecretChanges)
 	}
 
-	args, subshell, err := child.CommandPrep(s.config.AgentConfig.Exec.Command)
+	return p.startChildProcess(ctx, newEnvVars)
+}
+
+// startChildProcess starts the child process with the given environment
+// variables. It must be called with the childProcessLock held.
+func (p *process) startChildProcess(ctx context.Context, envVars []string) error {
+	args, subshell, err := child.CommandPrep(p.execConfig.Command)
 	if err != nil {
 		return fmt.Errorf("unable to parse command: %w", err)
 	}
 
 	childInput := &child.NewInput{
 		Stdin:        os.Stdin,
-		Stdout:       s.childProcessStdout,
-		Stderr:       s.childProcessStderr,
+		Stdout:       p.childProcessStdout,
+		Stderr:       p.childProcessStderr,
 		Command:      args[0],
 		Args:         args[1:],
 		Timeout:      0, // let it run forever
-		Env:          append(os.Environ(), newEnvVars...),
+		Env:          append(os.Environ(), envVars...),
 		ReloadSignal: nil, // can't reload w/ new env vars
-		KillSignal:   s.config.AgentConfig.Exec.RestartStopSignal,
+		KillSignal:   p.execConfig.RestartStopSignal,
 		KillTimeout:  30 * time.Second,
 		Splay:        0,
 		Setpgid:      subshell,
-		Logger:       s.logger.StandardLogger(nil),
+		Logger:       p.logger.StandardLogger(nil),
 	}
 
 	proc, err := child.New(childInput)
 	if err != nil {
 		return err
 	}
-	s.childProcess = proc
+	p.childProcess = proc
 
-	if err := s.childProcess.Start(); err != nil {
+	if err := p.childProcess.Start(); err != nil {
 		return fmt.Errorf("error starting the child process: %w", err)
 	}
 
-	s.childProcessState = childProcessStateRunning
+	p.childProcessState = childProcessStateRunning
+	p.starts++
+	p.healthCheckFailures = 0
 
 	// Listen if the child process exits and bubble it up to the main loop.
 	//
@@ -353,25 +518,146 @@ func (s *Server) restartChildProcess(newEnvVars []string) error {
 		case exitCode, ok := <-proc.ExitCh():
 			// ignore ExitCh channel closures caused by our restarts
 			if ok {
-				s.childProcessExitCh <- exitCode
+				select {
+				case p.childProcessExitCh <- exitCode:
+				case <-ctx.Done():
+				}
 			}
 		}
 	}()
 
+	if len(p.execConfig.HealthCheckCommand) > 0 {
+		go p.healthCheck(ctx, proc, envVars)
+	} else {
+		p.started()
+	}
+
 	return nil
 }
 
+// healthCheck runs the health check command while the given child process
+// runs, and restarts it once the command failed too many times in a row.
+func (p *process) healthCheck(ctx context.Context, proc *child.Child, envVars []string) {
+	interval := p.execConfig.HealthCheckInterval
+	if interval <= 0 {
+		interval = config.DefaultExecHealthCheckInterval
+	}
+	threshold := p.execConfig.HealthCheckFailureThreshold
+	if threshold <= 0 {
+		threshold = config.DefaultExecHealthCheckFailureThreshold
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var failures int
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		p.childProcessLock.Lock()
+		current := p.childProcess == proc && p.childProcessState == childProcessStateRunning
+		p.childProcessLock.Unlock()
+		if !current {
+			return
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		cmd := osexec.CommandContext(checkCtx, p.execConfig.HealthCheckCommand[0], p.execConfig.HealthCheckCommand[1:]...)
+		cmd.Env = append(os.Environ(), envVars...)
+		err := cmd.Run()
+		cancel()
+
+		if err == nil {
+			failures = 0
+			p.setHealthCheckFailures(proc, failures)
+			p.started()
+			continue
+		}
+
+		failures++
+		p.setHealthCheckFailures(proc, failures)
+		p.logger.Warn("child process health check failed", "process_id", proc.Pid(), "failures", failures, "error", err)
+		if failures < threshold {
+			continue
+		}
+
+		p.childProcessLock.Lock()
+		if ctx.Err() == nil && p.childProcess == proc && p.childProcessState == childProcessStateRunning {
+			p.logger.Info("restarting unhealthy process", "process_id", proc.Pid())
+			p.childProcessState = childProcessStateRestarting
+			proc.Stop()
+			if err := p.startChildProcess(ctx, p.lastStartedEnvVars(envVars)); err != nil {
+				p.logger.Error("unable to restart the unhealthy child process", "error", err)
+			}
+		}
+		p.childProcessLock.Unlock()
+		return
+	}
+}
+
+// setHealthCheckFailures records the number of consecutive failed health
+// checks of the given child process, if it is still the current one.
+func (p *process) setHealthCheckFailures(proc *child.Child, failures int) {
+	p.childProcessLock.Lock()
+	defer p.childProcessLock.Unlock()
+	if p.childProcess == proc {
+		p.healthCheckFailures = failures
+	}
+}
+
+// lastStartedEnvVars returns the environment variables to restart the child
+// process with: the latest rendered ones, unless the restart policy keeps the
+// ones the child process was started with. It must be called with the
+// childProcessLock held.
+func (p *process) lastStartedEnvVars(envVars []string) []string {
+	if p.execConfig.RestartOnSecretChanges == "never" {
+		return envVars
+	}
+	return p.lastRenderedEnvVars
+}
+
+// started marks the child process as started, letting the child process of
+// the next exec block start.
+func (p *process) started() {
+	p.startedOnce.Do(func() {
+		close(p.startedCh)
+	})
+}
+
+// stop stops the child process, if it is running. The output files of the
+// child process are left open, Close closes them.
+func (p *process) stop() {
+	p.childProcessLock.Lock()
+	defer p.childProcessLock.Unlock()
+	if p.childProcess != nil && p.childProcessState != childProcessStateStopped {
+		p.childProcess.Stop()
+	}
+	p.childProcessState = childProcessStateStopped
+}
+
 func (s *Server) Close() {
-	s.childProcessLock.Lock()
-	defer s.childProcessLock.Unlock()
-	s.close()
+	for _, p := range s.processes {
+		p.childProcessLock.Lock()
+		p.close()
+		p.childProcessLock.Unlock()
+	}
 }
 
 func (s *Server) close() {
-	if s.childProcessStdout != os.Stdout {
-		_ = s.childProcessStdout.Close()
+	for _, p := range s.processes {
+		p.close()
+	}
+}
+
+func (p *process) close() {
+	if p.childProcessStdout != os.Stdout {
+		_ = p.childProcessStdout.Close()
 	}
-	if s.childProcessStderr != os.Stderr {
-		_ = s.childProcessStderr.Close()
+	if p.childProcessStderr != os.Stderr {
+		_ = p.childProcessStderr.Close()
 	}
 }
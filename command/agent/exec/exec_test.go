@@ -578,3 +578,118 @@ func findOpenPort(t *testing.T) int {
 	}
 	return port
 }
+
+// TestExecServer_Named tests that the child processes of named exec blocks
+// are all started, each with the environment variables of its own templates.
+func TestExecServer_Named(t *testing.T) {
+	goBinary, err := exec.LookPath("go")
+	if err != nil {
+		t.Fatalf("could not find go binary on path: %s", err)
+	}
+
+	testAppBinary := filepath.Join(os.TempDir(), "test-app-named")
+
+	if err := exec.Command(goBinary, "build", "-o", testAppBinary, "./test-app").Run(); err != nil {
+		t.Fatalf("could not build the test application: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := os.Remove(testAppBinary); err != nil {
+			t.Fatalf("could not remove %q test application: %s", testAppBinary, err)
+		}
+	})
+
+	fakeVault := fakeVaultServer(t)
+	defer fakeVault.Close()
+
+	ctx, cancelContextFunc := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelContextFunc()
+
+	ports := map[string]int{
+		"first":  findOpenPort(t),
+		"second": findOpenPort(t),
+	}
+	envVars := map[string]string{
+		"first":  "FIRST_USER",
+		"second": "SECOND_USER",
+	}
+
+	var execs []*config.ExecConfig
+	for _, name := range []string{"first", "second"} {
+		execs = append(execs, &config.ExecConfig{
+			Name:                   name,
+			RestartOnSecretChanges: "always",
+			RestartStopSignal:      syscall.SIGTERM,
+			Command:                []string{testAppBinary, "--port", strconv.Itoa(ports[name]), "--stop-after", "30s"},
+			EnvTemplates: []*ctconfig.TemplateConfig{{
+				Contents:                 pointerutil.StringPtr(`{{ with secret "kv/my-app/creds" }}{{ .Data.data.user }}{{ end }}`),
+				MapToEnvironmentVariable: pointerutil.StringPtr(envVars[name]),
+			}},
+		})
+	}
+
+	execServer, err := NewServer(&ServerConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace),
+		AgentConfig: &config.Config{
+			Vault: &config.Vault{
+				Address: fakeVault.URL,
+				Retry: &config.Retry{
+					NumRetries: 3,
+				},
+			},
+			Execs: execs,
+			TemplateConfig: &config.TemplateConfig{
+				ExitOnRetryFailure: true,
+			},
+		},
+		LogLevel:  hclog.Trace,
+		LogWriter: hclog.DefaultOutput,
+	})
+	if err != nil {
+		t.Fatalf("could not create exec server: %q", err)
+	}
+	defer execServer.Close()
+
+	execServerErrCh := make(chan error, 1)
+	execServerTokenCh := make(chan string, 1)
+	go func() {
+		execServerErrCh <- execServer.Run(ctx, execServerTokenCh)
+	}()
+	execServerTokenCh <- "my-token"
+
+	for name, port := range ports {
+		resp, err := retryablehttp.Get(fmt.Sprintf("http://localhost:%d", port))
+		if err != nil {
+			t.Fatalf("error making request to the %q test app: %s", name, err)
+		}
+
+		var response struct {
+			EnvironmentVariables map[string]string `json:"environment_variables"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("unable to parse response from the %q test app: %s", name, err)
+		}
+
+		for otherName, envVar := range envVars {
+			value, ok := response.EnvironmentVariables[envVar]
+			if otherName == name && value != "app-user" {
+				t.Fatalf("expected the %q test app to have %s=app-user, got %q", name, envVar, value)
+			}
+			if otherName != name && ok {
+				t.Fatalf("expected the %q test app not to have %s", name, envVar)
+			}
+		}
+	}
+
+	cancelContextFunc()
+	if err := <-execServerErrCh; err != nil {
+		t.Fatalf("exec server did not expect an error, got: %v", err)
+	}
+
+	for name, port := range ports {
+		if _, err := http.Head(fmt.Sprintf("http://localhost:%d", port)); err == nil {
+			t.Fatalf("the %q test app is still alive after a shutdown", name)
+		}
+	}
+}
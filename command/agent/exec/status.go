@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package exec
+
+import (
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/command/agentproxyshared/health"
+)
+
+// Status is the health status of the child process of an exec block.
+type Status struct {
+	// Name is the name of the exec block, empty for the unnamed block
+	Name  string `json:"name,omitempty"`
+	State string `json:"state"`
+	PID   int    `json:"pid,omitempty"`
+	// Starts is the number of times the child process was started
+	Starts              uint64 `json:"starts"`
+	HealthCheckFailures int    `json:"health_check_failures,omitempty"`
+}
+
+func (p *process) status() *Status {
+	p.childProcessLock.Lock()
+	defer p.childProcessLock.Unlock()
+
+	status := &Status{
+		Name:                p.name,
+		State:               p.childProcessState.String(),
+		Starts:              p.starts,
+		HealthCheckFailures: p.healthCheckFailures,
+	}
+	if p.childProcess != nil && p.childProcessState == childProcessStateRunning {
+		status.PID = p.childProcess.Pid()
+	}
+	return status
+}
+
+var _ health.Component = (*Server)(nil)
+
+// HealthStatus implements health.Component. The exec server is healthy while
+// all of its child processes are running.
+func (s *Server) HealthStatus() (interface{}, bool) {
+	statuses := make([]*Status, 0, len(s.processes))
+	healthy := true
+	for _, p := range s.processes {
+		status := p.status()
+		statuses = append(statuses, status)
+		if status.State != childProcessStateRunning.String() {
+			healthy = false
+		}
+	}
+	return statuses, healthy
+}
+
+// EmitMetrics implements health.MetricsEmitter, reporting which child
+// processes are running.
+func (s *Server) EmitMetrics() {
+	for _, p := range s.processes {
+		status := p.status()
+		var running float32
+		if status.State == childProcessStateRunning.String() {
+			running = 1
+		}
+		metrics.SetGaugeWithLabels([]string{"agent", "exec", "running"}, running, []metrics.Label{{Name: "name", Value: status.Name}})
+	}
+}
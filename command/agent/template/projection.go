@@ -0,0 +1,355 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package template
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul-template/manager"
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/hashicorp/vault/command/agent/config"
+)
+
+const (
+	// projectionDataDir is the symlink to the current version of the files
+	// of a projection.
+	projectionDataDir = "..data"
+
+	// projectionVersionLayout is the layout of the names of the version
+	// directories. The names have a fixed width, so they sort by creation
+	// time.
+	projectionVersionLayout = "..2006_01_02_15_04_05.000000000"
+)
+
+// projection publishes the templates of a projection block. The templates
+// are rendered by the runner to the staging directory, and a new version of
+// the files is published each time they change.
+type projection struct {
+	config *config.ProjectionConfig
+	logger hclog.Logger
+
+	// files maps the destinations of the templates, in the staging
+	// directory, to the names of the files in the projection
+	files map[string]string
+
+	uid int
+	gid int
+
+	// lastVersion is the time of the latest version directory. Each new
+	// version is created after it, so versions published within the
+	// resolution of the clock, or after it went backwards, keep their order.
+	lastVersion time.Time
+}
+
+func newProjection(cfg *config.ProjectionConfig, logger hclog.Logger) (*projection, error) {
+	p := &projection{
+		config: cfg,
+		logger: logger,
+		files:  make(map[string]string, len(cfg.Templates)),
+		uid:    -1,
+		gid:    -1,
+	}
+	for _, tmpl := range cfg.Templates {
+		p.files[*tmpl.Destination] = filepath.Base(*tmpl.Destination)
+	}
+
+	if cfg.User != "" {
+		uid, err := lookupUser(cfg.User)
+		if err != nil {
+			return nil, err
+		}
+		p.uid = uid
+	}
+	if cfg.Group != "" {
+		gid, err := lookupGroup(cfg.Group)
+		if err != nil {
+			return nil, err
+		}
+		p.gid = gid
+	}
+
+	if err := os.MkdirAll(cfg.Directory, cfg.DirPerms); err != nil {
+		return nil, fmt.Errorf("unable to create projection directory: %w", err)
+	}
+	if err := p.chown(cfg.Directory); err != nil {
+		return nil, err
+	}
+	// The templates may be rendered with looser permissions than the
+	// published files, so the staging directory is only readable by Agent
+	if err := os.MkdirAll(cfg.StagingDir(), 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create projection staging directory: %w", err)
+	}
+	if err := os.Chmod(cfg.StagingDir(), 0o700); err != nil {
+		return nil, err
+	}
+
+	versions, err := p.versions()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list projection versions: %w", err)
+	}
+	if len(versions) > 0 {
+		// The name was parsed when it was listed
+		p.lastVersion, _ = time.Parse(projectionVersionLayout, versions[len(versions)-1])
+	}
+
+	return p, nil
+}
+
+func lookupUser(name string) (int, error) {
+	if uid, err := strconv.Atoi(name); err == nil {
+		return uid, nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+func lookupGroup(name string) (int, error) {
+	if gid, err := strconv.Atoi(name); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+func (p *projection) chown(path string) error {
+	if p.uid == -1 && p.gid == -1 {
+		return nil
+	}
+	if err := os.Lchown(path, p.uid, p.gid); err != nil {
+		return fmt.Errorf("unable to change the ownership of %s: %w", path, err)
+	}
+	return nil
+}
+
+// update publishes a new version of the files when all the templates of the
+// projection have been rendered, and their contents differ from the current
+// version. It returns whether a new version was published.
+func (p *projection) update(events map[string]*manager.RenderEvent) (bool, error) {
+	rendered := 0
+	for _, event := range events {
+		if event.LastWouldRender.IsZero() {
+			continue
+		}
+		for _, tmpl := range event.TemplateConfigs {
+			if tmpl.Destination == nil {
+				continue
+			}
+			if _, ok := p.files[*tmpl.Destination]; ok {
+				rendered++
+			}
+		}
+	}
+	if rendered < len(p.files) {
+		// Not all the templates of the projection have been rendered yet
+		return false, nil
+	}
+
+	return p.publishStaged()
+}
+
+// publishStaged publishes a new version of the files from the staging
+// directory, when their contents differ from the current version. It returns
+// whether a new version was published.
+func (p *projection) publishStaged() (bool, error) {
+	contents := make(map[string][]byte, len(p.files))
+	for destination, file := range p.files {
+		b, err := os.ReadFile(destination)
+		if err != nil {
+			return false, fmt.Errorf("unable to read rendered template: %w", err)
+		}
+		contents[file] = b
+	}
+
+	if p.isCurrent(contents) {
+		// Linking the files may have failed when the version was published
+		return false, p.linkFiles(contents)
+	}
+
+	if err := p.publish(contents); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isCurrent returns whether the current version holds the given contents.
+func (p *projection) isCurrent(contents map[string][]byte) bool {
+	dataDir := filepath.Join(p.config.Directory, projectionDataDir)
+	for file, b := range contents {
+		current, err := os.ReadFile(filepath.Join(dataDir, file))
+		if err != nil || !bytes.Equal(current, b) {
+			return false
+		}
+	}
+	return true
+}
+
+// publish writes the given contents to a new version directory, and swaps
+// the data symlink to point to it.
+func (p *projection) publish(contents map[string][]byte) error {
+	dir := p.config.Directory
+
+	version := time.Now().UTC()
+	if !version.After(p.lastVersion) {
+		version = p.lastVersion.Add(time.Nanosecond)
+	}
+	versionDir := filepath.Join(dir, version.Format(projectionVersionLayout))
+	if err := os.Mkdir(versionDir, p.config.DirPerms); err != nil {
+		return fmt.Errorf("unable to create version directory: %w", err)
+	}
+	p.lastVersion = version
+	// The version directory is removed unless it is published
+	published := false
+	defer func() {
+		if !published {
+			os.RemoveAll(versionDir)
+		}
+	}()
+
+	for file, b := range contents {
+		path := filepath.Join(versionDir, file)
+		if err := os.WriteFile(path, b, p.config.FilePerms); err != nil {
+			return fmt.Errorf("unable to write %s: %w", file, err)
+		}
+		// Apply the permissions regardless of the umask
+		if err := os.Chmod(path, p.config.FilePerms); err != nil {
+			return err
+		}
+		if err := p.chown(path); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(versionDir, p.config.DirPerms); err != nil {
+		return err
+	}
+	if err := p.chown(versionDir); err != nil {
+		return err
+	}
+
+	// Renaming a symlink over the data symlink swaps it atomically
+	tmpDataDir := filepath.Join(dir, projectionDataDir+"_tmp")
+	if err := os.Remove(tmpDataDir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Symlink(filepath.Base(versionDir), tmpDataDir); err != nil {
+		return fmt.Errorf("unable to create data symlink: %w", err)
+	}
+	if err := p.chown(tmpDataDir); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpDataDir, filepath.Join(dir, projectionDataDir)); err != nil {
+		return fmt.Errorf("unable to swap data symlink: %w", err)
+	}
+	published = true
+
+	if err := p.linkFiles(contents); err != nil {
+		return err
+	}
+
+	p.logger.Info("published projection", "projection", p.config.Name, "version", filepath.Base(versionDir))
+
+	return p.removeVersions(filepath.Base(versionDir))
+}
+
+// linkFiles creates the symlinks of the files to their path in the data
+// directory.
+func (p *projection) linkFiles(contents map[string][]byte) error {
+	for file := range contents {
+		if err := p.linkFile(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkFile creates the symlink of a file to its path in the data directory.
+func (p *projection) linkFile(file string) error {
+	path := filepath.Join(p.config.Directory, file)
+	target := filepath.Join(projectionDataDir, file)
+
+	fi, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	case fi.Mode()&os.ModeSymlink == 0:
+		return fmt.Errorf("unable to link %s, a file already exists at %s", file, path)
+	default:
+		if existing, err := os.Readlink(path); err == nil && existing == target {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Symlink(target, path); err != nil {
+		return fmt.Errorf("unable to link %s: %w", file, err)
+	}
+	return p.chown(path)
+}
+
+// removeVersions removes the version directories older than the retained
+// ones.
+func (p *projection) removeVersions(current string) error {
+	versions, err := p.versions()
+	if err != nil {
+		return err
+	}
+
+	var previous []string
+	for _, v := range versions {
+		if v != current {
+			previous = append(previous, v)
+		}
+	}
+	if len(previous) <= p.config.RetainPrevious {
+		return nil
+	}
+
+	for _, v := range previous[:len(previous)-p.config.RetainPrevious] {
+		if err := os.RemoveAll(filepath.Join(p.config.Directory, v)); err != nil {
+			return fmt.Errorf("unable to remove version %s: %w", v, err)
+		}
+	}
+	return nil
+}
+
+// versions returns the version directories of the projection, from the
+// oldest to the newest.
+func (p *projection) versions() ([]string, error) {
+	entries, err := os.ReadDir(p.config.Directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || !strings.HasPrefix(name, "..") || name == config.ProjectionStagingDir {
+			continue
+		}
+		if _, err := time.Parse(projectionVersionLayout, name); err != nil {
+			continue
+		}
+		versions = append(versions, name)
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
@@ -0,0 +1,194 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ctconfig "github.com/hashicorp/consul-template/config"
+	"github.com/hashicorp/consul-template/manager"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/sdk/helper/pointerutil"
+	"github.com/stretchr/testify/require"
+)
+
+func testProjection(t *testing.T, retainPrevious int, files ...string) (*projection, map[string]*manager.RenderEvent) {
+	t.Helper()
+
+	cfg := &config.ProjectionConfig{
+		Name:           "test",
+		Directory:      filepath.Join(t.TempDir(), "secrets"),
+		DirPerms:       config.DefaultProjectionDirPerms,
+		FilePerms:      0o640,
+		RetainPrevious: retainPrevious,
+	}
+	events := make(map[string]*manager.RenderEvent)
+	for _, file := range files {
+		tmpl := &ctconfig.TemplateConfig{
+			Destination: pointerutil.StringPtr(filepath.Join(cfg.StagingDir(), file)),
+		}
+		cfg.Templates = append(cfg.Templates, tmpl)
+		events[file] = &manager.RenderEvent{
+			TemplateConfigs: []*ctconfig.TemplateConfig{tmpl},
+		}
+	}
+
+	p, err := newProjection(cfg, hclog.NewNullLogger())
+	require.NoError(t, err)
+	return p, events
+}
+
+// render emulates the rendering of a template of the projection.
+func render(t *testing.T, p *projection, events map[string]*manager.RenderEvent, file, contents string) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(filepath.Join(p.config.StagingDir(), file), []byte(contents), 0o644))
+	events[file].LastWouldRender = time.Now()
+}
+
+func requireProjected(t *testing.T, p *projection, file, contents string) {
+	t.Helper()
+
+	path := filepath.Join(p.config.Directory, file)
+	target, err := os.Readlink(path)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(projectionDataDir, file), target)
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, contents, string(b))
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, p.config.FilePerms, fi.Mode().Perm())
+}
+
+// TestProjection_Update tests that the files of a projection are published
+// once all of its templates are rendered, and only when they change.
+func TestProjection_Update(t *testing.T) {
+	p, events := testProjection(t, 1, "username", "password")
+
+	render(t, p, events, "username", "user")
+	published, err := p.update(events)
+	require.NoError(t, err)
+	require.False(t, published, "expected no version before all the templates are rendered")
+	_, err = os.Lstat(filepath.Join(p.config.Directory, projectionDataDir))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	render(t, p, events, "password", "pass")
+	published, err = p.update(events)
+	require.NoError(t, err)
+	require.True(t, published)
+	requireProjected(t, p, "username", "user")
+	requireProjected(t, p, "password", "pass")
+
+	// The same contents are not published again
+	published, err = p.update(events)
+	require.NoError(t, err)
+	require.False(t, published)
+
+	versions, err := p.versions()
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	first := versions[0]
+
+	render(t, p, events, "password", "pass2")
+	published, err = p.update(events)
+	require.NoError(t, err)
+	require.True(t, published)
+	requireProjected(t, p, "username", "user")
+	requireProjected(t, p, "password", "pass2")
+
+	// The previous version is retained for rollback
+	versions, err = p.versions()
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.Equal(t, first, versions[0])
+	b, err := os.ReadFile(filepath.Join(p.config.Directory, first, "password"))
+	require.NoError(t, err)
+	require.Equal(t, "pass", string(b))
+
+	render(t, p, events, "password", "pass3")
+	_, err = p.update(events)
+	require.NoError(t, err)
+	versions, err = p.versions()
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.NotContains(t, versions, first)
+	requireProjected(t, p, "password", "pass3")
+}
+
+// TestProjection_NoRetainPrevious tests that only the current version is kept
+// when no previous version is retained.
+func TestProjection_NoRetainPrevious(t *testing.T) {
+	p, events := testProjection(t, 0, "token")
+
+	for _, contents := range []string{"a", "b", "c"} {
+		render(t, p, events, "token", contents)
+		published, err := p.update(events)
+		require.NoError(t, err)
+		require.True(t, published)
+		requireProjected(t, p, "token", contents)
+	}
+
+	versions, err := p.versions()
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+}
+
+// TestProjection_VersionOrder tests that new versions sort after the existing
+// ones, even when the clock is behind the latest version, so the previous
+// versions removed are always the oldest.
+func TestProjection_VersionOrder(t *testing.T) {
+	p, events := testProjection(t, 1, "token")
+
+	render(t, p, events, "token", "a")
+	_, err := p.update(events)
+	require.NoError(t, err)
+
+	// A version published by an earlier run of Agent, whose clock was ahead
+	future := time.Now().UTC().Add(time.Hour)
+	require.NoError(t, os.Mkdir(filepath.Join(p.config.Directory, future.Format(projectionVersionLayout)), 0o755))
+	p, err = newProjection(p.config, hclog.NewNullLogger())
+	require.NoError(t, err)
+	require.Equal(t, future, p.lastVersion)
+
+	for _, contents := range []string{"b", "c"} {
+		render(t, p, events, "token", contents)
+		_, err = p.update(events)
+		require.NoError(t, err)
+		requireProjected(t, p, "token", contents)
+	}
+
+	versions, err := p.versions()
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	current, err := os.Readlink(filepath.Join(p.config.Directory, projectionDataDir))
+	require.NoError(t, err)
+	require.Equal(t, versions[1], current)
+	b, err := os.ReadFile(filepath.Join(p.config.Directory, versions[0], "token"))
+	require.NoError(t, err)
+	require.Equal(t, "b", string(b))
+}
+
+// TestProjection_ExistingFile tests that a file in the projection directory is
+// not replaced by the symlink of a template.
+func TestProjection_ExistingFile(t *testing.T) {
+	p, events := testProjection(t, 1, "token")
+	require.NoError(t, os.WriteFile(filepath.Join(p.config.Directory, "token"), []byte("existing"), 0o644))
+
+	render(t, p, events, "token", "token")
+	_, err := p.update(events)
+	require.Error(t, err)
+
+	// The file is linked once it is removed
+	require.NoError(t, os.Remove(filepath.Join(p.config.Directory, "token")))
+	_, err = p.update(events)
+	require.NoError(t, err)
+	requireProjected(t, p, "token", "token")
+}
@@ -0,0 +1,267 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package template
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/consul-template/child"
+	ctconfig "github.com/hashicorp/consul-template/config"
+	wrapping "github.com/hashicorp/go-kms-wrapping/v2"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agentproxyshared/cache/keymanager"
+)
+
+// defaultSnapshotHealthCheckTimeout is how long to wait for Vault to respond
+// at startup before rendering the templates from the snapshot.
+const defaultSnapshotHealthCheckTimeout = 10 * time.Second
+
+// snapshot persists the rendered templates, encrypted, so that they can be
+// rendered when Vault is unreachable. The encryption key is stored in the
+// snapshot file, encrypted with the key of the snapshot key file.
+type snapshot struct {
+	path      string
+	protector keymanager.KeyProtector
+	km        *keymanager.ProtectedKeyManager
+
+	// persisted holds the contents of the templates in the snapshot file,
+	// keyed by destination, so that unchanged contents are not persisted
+	// again
+	persisted map[string][]byte
+}
+
+// snapshotFile is the format of the snapshot file.
+type snapshotFile struct {
+	RetrievalToken []byte `json:"retrieval_token"`
+	Blob           []byte `json:"blob"`
+}
+
+// snapshotData is the encrypted contents of the snapshot file.
+type snapshotData struct {
+	Created   time.Time         `json:"created"`
+	Templates map[string][]byte `json:"templates"`
+}
+
+func newSnapshot(ctx context.Context, path, keyFile string) (*snapshot, error) {
+	protector, err := keymanager.NewKeyFileProtector(ctx, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshot{
+		path:      path,
+		protector: protector,
+	}, nil
+}
+
+// load returns the data of the snapshot file, or nil if there is none.
+func (s *snapshot) load(ctx context.Context) (*snapshotData, error) {
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f snapshotFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("unable to decode snapshot: %w", err)
+	}
+
+	km, err := keymanager.NewProtectedKeyManager(ctx, s.protector, f.RetrievalToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var blob wrapping.BlobInfo
+	if err := proto.Unmarshal(f.Blob, &blob); err != nil {
+		return nil, fmt.Errorf("unable to decode snapshot: %w", err)
+	}
+	plaintext, err := km.Wrapper().Decrypt(ctx, &blob)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt snapshot: %w", err)
+	}
+
+	var data snapshotData
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return nil, fmt.Errorf("unable to decode snapshot: %w", err)
+	}
+
+	s.km = km
+	s.persisted = data.Templates
+	return &data, nil
+}
+
+// save persists the given contents of the templates, keyed by destination,
+// unless they are already persisted.
+func (s *snapshot) save(ctx context.Context, templates map[string][]byte) error {
+	if s.isPersisted(templates) {
+		return nil
+	}
+
+	if s.km == nil {
+		km, err := keymanager.NewProtectedKeyManager(ctx, s.protector, nil)
+		if err != nil {
+			return err
+		}
+		s.km = km
+	}
+
+	plaintext, err := json.Marshal(&snapshotData{
+		Created:   time.Now().UTC(),
+		Templates: templates,
+	})
+	if err != nil {
+		return err
+	}
+	blobInfo, err := s.km.Wrapper().Encrypt(ctx, plaintext)
+	if err != nil {
+		return fmt.Errorf("unable to encrypt snapshot: %w", err)
+	}
+	blob, err := proto.Marshal(blobInfo)
+	if err != nil {
+		return err
+	}
+	retrievalToken, err := s.km.RetrievalToken(ctx)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(&snapshotFile{
+		RetrievalToken: retrievalToken,
+		Blob:           blob,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that the snapshot is replaced
+	// atomically
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("unable to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("unable to write snapshot: %w", err)
+	}
+
+	s.persisted = templates
+	return nil
+}
+
+func (s *snapshot) isPersisted(templates map[string][]byte) bool {
+	if len(templates) != len(s.persisted) {
+		return false
+	}
+	for destination, contents := range templates {
+		persisted, ok := s.persisted[destination]
+		if !ok || !bytes.Equal(persisted, contents) {
+			return false
+		}
+	}
+	return true
+}
+
+// readTemplates returns the rendered contents of the given templates, keyed
+// by destination.
+func readTemplates(templates []*ctconfig.TemplateConfig) (map[string][]byte, error) {
+	contents := make(map[string][]byte, len(templates))
+	for _, tmpl := range templates {
+		if tmpl.Destination == nil {
+			continue
+		}
+		b, err := os.ReadFile(*tmpl.Destination)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read rendered template: %w", err)
+		}
+		contents[*tmpl.Destination] = b
+	}
+	return contents, nil
+}
+
+// writeTemplates writes the contents of the given templates from the
+// snapshot, and returns the templates written.
+func writeTemplates(templates []*ctconfig.TemplateConfig, data *snapshotData) ([]*ctconfig.TemplateConfig, error) {
+	var written []*ctconfig.TemplateConfig
+	for _, tmpl := range templates {
+		if tmpl.Destination == nil {
+			continue
+		}
+		contents, ok := data.Templates[*tmpl.Destination]
+		if !ok {
+			continue
+		}
+
+		perms := os.FileMode(0o644)
+		if tmpl.Perms != nil && *tmpl.Perms != 0 {
+			perms = *tmpl.Perms
+		}
+		if err := os.MkdirAll(filepath.Dir(*tmpl.Destination), 0o755); err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(*tmpl.Destination, contents, perms); err != nil {
+			return written, fmt.Errorf("unable to write template from snapshot: %w", err)
+		}
+		written = append(written, tmpl)
+	}
+	return written, nil
+}
+
+// runTemplateCommand runs the command of a template written from the
+// snapshot, as consul-template does once it renders the template, and waits
+// for it to exit.
+func runTemplateCommand(tmpl *ctconfig.TemplateConfig, logger *log.Logger) error {
+	// Finalize a copy to merge the deprecated command into exec
+	tmpl = tmpl.Copy()
+	tmpl.Finalize()
+	if tmpl.Exec.Command.Empty() {
+		return nil
+	}
+
+	args, subshell, err := child.CommandPrep(tmpl.Exec.Command)
+	if err != nil {
+		return fmt.Errorf("unable to parse command: %w", err)
+	}
+	proc, err := child.New(&child.NewInput{
+		Stdin:       os.Stdin,
+		Stdout:      os.Stdout,
+		Stderr:      os.Stderr,
+		Command:     args[0],
+		Args:        args[1:],
+		Env:         tmpl.Exec.Env.Env(),
+		Timeout:     ctconfig.TimeDurationVal(tmpl.Exec.Timeout),
+		KillSignal:  ctconfig.SignalVal(tmpl.Exec.KillSignal),
+		KillTimeout: ctconfig.TimeDurationVal(tmpl.Exec.KillTimeout),
+		Setpgid:     subshell,
+		Logger:      logger,
+	})
+	if err != nil {
+		return err
+	}
+	return proc.Start()
+}
+
+// vaultReachable returns whether Vault responds to health checks, and is
+// unsealed.
+func vaultReachable(ctx context.Context, client *api.Client) bool {
+	ctx, cancel := context.WithTimeout(ctx, defaultSnapshotHealthCheckTimeout)
+	defer cancel()
+
+	health, err := client.Sys().HealthWithContext(ctx)
+	return err == nil && health != nil && health.Initialized && !health.Sealed
+}
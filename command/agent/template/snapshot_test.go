@@ -0,0 +1,165 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package template
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ctconfig "github.com/hashicorp/consul-template/config"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+	"github.com/hashicorp/vault/sdk/helper/pointerutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSnapshot_SaveLoad tests that a snapshot is restored with the same key
+// file, and only with it.
+func TestSnapshot_SaveLoad(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "templates.snapshot")
+	keyFile := filepath.Join(dir, "snapshot.key")
+
+	snap, err := newSnapshot(ctx, path, keyFile)
+	require.NoError(t, err)
+
+	data, err := snap.load(ctx)
+	require.NoError(t, err)
+	require.Nil(t, data, "expected no snapshot before it is saved")
+
+	templates := map[string][]byte{
+		"/etc/app/username": []byte("user"),
+		"/etc/app/password": []byte("pass"),
+	}
+	require.NoError(t, snap.save(ctx, templates))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "pass", "expected the snapshot to be encrypted")
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	snap, err = newSnapshot(ctx, path, keyFile)
+	require.NoError(t, err)
+	data, err = snap.load(ctx)
+	require.NoError(t, err)
+	require.Equal(t, templates, data.Templates)
+	require.False(t, data.Created.IsZero())
+
+	// Unchanged contents are not persisted again
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, snap.save(ctx, templates))
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	templates["/etc/app/password"] = []byte("pass2")
+	require.NoError(t, snap.save(ctx, templates))
+	data, err = snap.load(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "pass2", string(data.Templates["/etc/app/password"]))
+
+	// The snapshot cannot be decrypted with another key file
+	snap, err = newSnapshot(ctx, path, filepath.Join(dir, "other.key"))
+	require.NoError(t, err)
+	_, err = snap.load(ctx)
+	require.Error(t, err)
+}
+
+// TestServerRun_Snapshot tests that the templates are rendered from the
+// snapshot when Vault is unreachable at startup.
+func TestServerRun_Snapshot(t *testing.T) {
+	ts := createHttpTestServer()
+	defer ts.Close()
+
+	dir := t.TempDir()
+	destination := filepath.Join(dir, "render.json")
+	templateConfig := &config.TemplateConfig{
+		SnapshotFile:    filepath.Join(dir, "templates.snapshot"),
+		SnapshotKeyFile: filepath.Join(dir, "snapshot.key"),
+	}
+	// The command of the template touches the marker file
+	marker := filepath.Join(dir, "command-ran")
+	templates := []*ctconfig.TemplateConfig{{
+		Contents:    pointerutil.StringPtr(templateContents),
+		Destination: pointerutil.StringPtr(destination),
+		Exec: &ctconfig.ExecConfig{
+			Command: []string{"touch", marker},
+		},
+	}}
+
+	newServer := func(address string) *Server {
+		client, err := api.NewClient(&api.Config{Address: address})
+		require.NoError(t, err)
+		return NewServer(&ServerConfig{
+			Logger: logging.NewVaultLogger(hclog.Trace),
+			Client: client,
+			AgentConfig: &config.Config{
+				Vault: &config.Vault{
+					Address: address,
+					Retry: &config.Retry{
+						NumRetries: 3,
+					},
+				},
+				TemplateConfig: templateConfig,
+			},
+			LogLevel:      hclog.Trace,
+			LogWriter:     hclog.DefaultOutput,
+			ExitAfterAuth: true,
+		})
+	}
+
+	// Render the templates from Vault, which saves the snapshot
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	server := newServer(ts.URL)
+	templateTokenCh := make(chan string, 1)
+	templateTokenCh <- "test"
+	require.NoError(t, server.Run(ctx, templateTokenCh, templates))
+	rendered, err := os.ReadFile(destination)
+	require.NoError(t, err)
+	_, healthy := server.HealthStatus()
+	require.True(t, healthy)
+	require.FileExists(t, templateConfig.SnapshotFile)
+
+	require.FileExists(t, marker)
+
+	// Vault is unreachable, and no token is received
+	require.NoError(t, os.Remove(destination))
+	require.NoError(t, os.Remove(marker))
+	unreachable := createHttpTestServer()
+	unreachable.Close()
+
+	ctx, cancel = context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	server = newServer(unreachable.URL)
+	go func() {
+		errCh <- server.Run(ctx, make(chan string), templates)
+	}()
+
+	require.Eventually(t, func() bool {
+		b, err := os.ReadFile(destination)
+		return err == nil && string(b) == string(rendered)
+	}, 15*time.Second, 100*time.Millisecond)
+
+	// The command runs once the template is written
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(marker)
+		return err == nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	status, healthy := server.HealthStatus()
+	require.False(t, healthy, "expected the templates rendered from the snapshot to be unhealthy")
+	require.True(t, status.(*Status).Snapshot.Stale)
+	require.NotNil(t, status.(*Status).Snapshot.Created)
+
+	cancel()
+	require.NoError(t, <-errCh)
+}
@@ -0,0 +1,237 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package template
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	ctconfig "github.com/hashicorp/consul-template/config"
+	"github.com/hashicorp/consul-template/manager"
+	"github.com/hashicorp/vault/command/agentproxyshared/health"
+)
+
+// Status is the health status of the template server.
+type Status struct {
+	Templates []*TemplateStatus `json:"templates"`
+	// Errors is the number of errors of the runner, which are not tied to a
+	// single template
+	Errors        uint64     `json:"errors"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+
+	Snapshot *SnapshotStatus `json:"snapshot,omitempty"`
+}
+
+// SnapshotStatus is the status of the snapshot of the templates.
+type SnapshotStatus struct {
+	// Stale is whether the templates were rendered from the snapshot, and
+	// not yet rendered from Vault since
+	Stale bool `json:"stale"`
+	// Created is the time the snapshot the templates were rendered from was
+	// taken
+	Created *time.Time `json:"created,omitempty"`
+}
+
+// TemplateStatus is the status of a single template.
+type TemplateStatus struct {
+	Destination   string     `json:"destination"`
+	Renders       uint64     `json:"renders"`
+	LastRendered  *time.Time `json:"last_rendered,omitempty"`
+	Errors        uint64     `json:"errors"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+}
+
+// templateStatus holds what the server knows of the rendering of a template.
+type templateStatus struct {
+	destination string
+	// configs are the configurations sharing the template, which the errors
+	// of the runner are matched against
+	configs      []*ctconfig.TemplateConfig
+	renders      uint64
+	lastRendered time.Time
+
+	// rendered is whether the template was rendered from Vault, even if the
+	// rendered contents were already on disk, and lastWouldRender the last
+	// time it was
+	rendered        bool
+	lastWouldRender time.Time
+
+	errors        uint64
+	lastError     error
+	lastErrorTime time.Time
+}
+
+func (t *templateStatus) recordError(err error, at time.Time) {
+	t.errors++
+	t.lastError = err
+	t.lastErrorTime = at
+	metrics.IncrCounterWithLabels([]string{"agent", "template", "error"}, 1, []metrics.Label{{Name: "destination", Value: t.destination}})
+}
+
+// matchesError returns whether an error of the runner is about the template.
+// consul-template names the source and destination of the template in the
+// errors rendering it, and only its source in the errors executing it.
+func (t *templateStatus) matchesError(err error) bool {
+	msg := err.Error()
+	for _, ctmpl := range t.configs {
+		if strings.Contains(msg, ctmpl.Display()) {
+			return true
+		}
+		if source := ctconfig.StringVal(ctmpl.Source); source != "" && strings.HasPrefix(msg, source+": ") {
+			return true
+		}
+	}
+	return false
+}
+
+// recordRenders counts the templates rendered since the given events were
+// last seen.
+func (ts *Server) recordRenders(events map[string]*manager.RenderEvent) {
+	ts.statusLock.Lock()
+	defer ts.statusLock.Unlock()
+
+	for id, event := range events {
+		status, ok := ts.templateStatus[id]
+		if !ok {
+			continue
+		}
+		if !event.LastWouldRender.IsZero() {
+			status.rendered = true
+			status.lastWouldRender = event.LastWouldRender
+		}
+		// Errors of templates that are not fatal are only reported in their
+		// render events
+		if event.Error != nil && event.Error != status.lastError {
+			at := event.UpdatedAt
+			if at.IsZero() {
+				at = time.Now()
+			}
+			status.recordError(event.Error, at)
+		}
+		if event.LastDidRender.IsZero() || !event.LastDidRender.After(status.lastRendered) {
+			continue
+		}
+		status.renders++
+		status.lastRendered = event.LastDidRender
+		metrics.IncrCounterWithLabels([]string{"agent", "template", "render"}, 1, []metrics.Label{{Name: "destination", Value: status.destination}})
+	}
+}
+
+// recordSnapshotRender records that the templates were rendered from a
+// snapshot taken at the given time.
+func (ts *Server) recordSnapshotRender(created time.Time) {
+	ts.statusLock.Lock()
+	defer ts.statusLock.Unlock()
+
+	ts.snapshotStale = true
+	ts.snapshotCreated = created
+}
+
+// recordLiveRender records that all the templates were rendered from Vault,
+// so that they are no longer stale.
+func (ts *Server) recordLiveRender() {
+	ts.statusLock.Lock()
+	defer ts.statusLock.Unlock()
+
+	ts.snapshotStale = false
+}
+
+// recordError records an error of the runner, against the template it is
+// about if any.
+func (ts *Server) recordError(err error) {
+	ts.statusLock.Lock()
+	defer ts.statusLock.Unlock()
+
+	for _, status := range ts.templateStatus {
+		if status.matchesError(err) {
+			status.recordError(err, time.Now())
+			return
+		}
+	}
+
+	ts.errors++
+	ts.lastError = err
+	ts.lastErrorTime = time.Now()
+	metrics.IncrCounter([]string{"agent", "template", "error"}, 1)
+}
+
+var _ health.Component = (*Server)(nil)
+
+// HealthStatus implements health.Component. The template server is healthy
+// once all of its templates have rendered, as long as no error happened
+// since their last render.
+func (ts *Server) HealthStatus() (interface{}, bool) {
+	ts.statusLock.RLock()
+	defer ts.statusLock.RUnlock()
+
+	status := &Status{
+		Templates:     make([]*TemplateStatus, 0, len(ts.templateStatus)),
+		Errors:        ts.errors,
+		LastErrorTime: health.TimePtr(ts.lastErrorTime),
+	}
+	if ts.lastError != nil {
+		status.LastError = ts.lastError.Error()
+	}
+	if ts.snapshotEnabled {
+		status.Snapshot = &SnapshotStatus{
+			Stale:   ts.snapshotStale,
+			Created: health.TimePtr(ts.snapshotCreated),
+		}
+	}
+
+	healthy := !ts.snapshotStale
+	var lastRendered time.Time
+	for _, t := range ts.templateStatus {
+		templateStatus := &TemplateStatus{
+			Destination:   t.destination,
+			Renders:       t.renders,
+			LastRendered:  health.TimePtr(t.lastRendered),
+			Errors:        t.errors,
+			LastErrorTime: health.TimePtr(t.lastErrorTime),
+		}
+		if t.lastError != nil {
+			templateStatus.LastError = t.lastError.Error()
+		}
+		status.Templates = append(status.Templates, templateStatus)
+
+		if !t.rendered || t.lastErrorTime.After(t.lastWouldRender) {
+			healthy = false
+		}
+		if t.lastWouldRender.After(lastRendered) {
+			lastRendered = t.lastWouldRender
+		}
+	}
+	sort.Slice(status.Templates, func(i, j int) bool {
+		return status.Templates[i].Destination < status.Templates[j].Destination
+	})
+
+	if ts.lastErrorTime.After(lastRendered) {
+		healthy = false
+	}
+	return status, healthy
+}
+
+var _ health.MetricsEmitter = (*Server)(nil)
+
+// EmitMetrics implements health.MetricsEmitter, reporting whether the
+// templates were rendered from a snapshot, and its age.
+func (ts *Server) EmitMetrics() {
+	ts.statusLock.RLock()
+	defer ts.statusLock.RUnlock()
+
+	if !ts.snapshotEnabled {
+		return
+	}
+
+	var stale float32
+	if ts.snapshotStale {
+		stale = 1
+		metrics.SetGauge([]string{"agent", "template", "snapshot", "age"}, float32(time.Since(ts.snapshotCreated).Seconds()))
+	}
+	metrics.SetGauge([]string{"agent", "template", "snapshot", "stale"}, stale)
+}
@@ -13,6 +13,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
+	"time"
 
 	"go.uber.org/atomic"
 
@@ -20,6 +22,7 @@ import (
 	"github.com/hashicorp/consul-template/manager"
 	"github.com/hashicorp/go-hclog"
 
+	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/command/agent/config"
 	"github.com/hashicorp/vault/command/agent/internal/ctmanager"
 	"github.com/hashicorp/vault/helper/useragent"
@@ -30,12 +33,19 @@ import (
 // Server
 type ServerConfig struct {
 	Logger hclog.Logger
-	// Client        *api.Client
+	// Client is used to check whether Vault is reachable at startup, when the
+	// templates are rendered from a snapshot otherwise
+	Client      *api.Client
 	AgentConfig *config.Config
 
 	ExitAfterAuth bool
 	Namespace     string
 
+	// AddressCh receives the address of the Vault server to render the
+	// templates from when it changes, e.g. when failing over to another
+	// server. The runner is restarted against the new address.
+	AddressCh <-chan string
+
 	// LogLevel is needed to set the internal Consul Template Runner's log level
 	// to match the log level of Vault Agent. The internal Runner creates it's own
 	// logger and can't be set externally or copied from the Template Server.
@@ -69,6 +79,20 @@ type Server struct {
 
 	logger        hclog.Logger
 	exitAfterAuth bool
+
+	// statusLock protects the rendering status of the templates, keyed by
+	// their consul-template ID, and the errors of the runner
+	statusLock     sync.RWMutex
+	templateStatus map[string]*templateStatus
+	errors         uint64
+	lastError      error
+	lastErrorTime  time.Time
+
+	// snapshotStale is whether the templates were rendered from the snapshot
+	// taken at snapshotCreated, and not yet rendered from Vault since
+	snapshotEnabled bool
+	snapshotStale   bool
+	snapshotCreated time.Time
 }
 
 // NewServer returns a new configured server
@@ -145,6 +169,45 @@ func (ts *Server) Run(ctx context.Context, incoming chan string, templates []*ct
 	}
 	ts.lookupMap = lookupMap
 
+	ts.statusLock.Lock()
+	ts.templateStatus = make(map[string]*templateStatus, len(lookupMap))
+	for id, ctmpls := range lookupMap {
+		var destination string
+		if len(ctmpls) > 0 && ctmpls[0].Destination != nil {
+			destination = *ctmpls[0].Destination
+		}
+		ts.templateStatus[id] = &templateStatus{destination: destination, configs: ctmpls}
+	}
+	ts.statusLock.Unlock()
+
+	projections := make([]*projection, 0, len(ts.config.AgentConfig.Projections))
+	for _, cfg := range ts.config.AgentConfig.Projections {
+		p, err := newProjection(cfg, ts.logger)
+		if err != nil {
+			return fmt.Errorf("template server failed to set up projection %q: %w", cfg.Name, err)
+		}
+		projections = append(projections, p)
+	}
+
+	var snap *snapshot
+	if tc := ts.config.AgentConfig.TemplateConfig; tc != nil && tc.SnapshotFile != "" {
+		snap, err = newSnapshot(ctx, tc.SnapshotFile, tc.SnapshotKeyFile)
+		if err != nil {
+			return fmt.Errorf("template server failed to set up snapshot: %w", err)
+		}
+		ts.statusLock.Lock()
+		ts.snapshotEnabled = true
+		ts.statusLock.Unlock()
+
+		data, err := snap.load(ctx)
+		if err != nil {
+			return fmt.Errorf("template server failed to load snapshot: %w", err)
+		}
+		if data != nil && ts.config.Client != nil && !vaultReachable(ctx, ts.config.Client) {
+			ts.renderSnapshot(data, templates, projections)
+		}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -183,8 +246,30 @@ func (ts *Server) Run(ctx context.Context, incoming chan string, templates []*ct
 				go ts.runner.Start()
 			}
 
+		case addr := <-ts.config.AddressCh:
+			ts.logger.Info("template server received new Vault address", "address", addr)
+			runnerConfig = runnerConfig.Merge(&ctconfig.Config{
+				Vault: &ctconfig.VaultConfig{
+					Address: pointerutil.StringPtr(addr),
+				},
+			})
+
+			// The runner is only started once a token has been received
+			if !ts.runnerStarted.Load() {
+				continue
+			}
+			ts.runner.Stop()
+			var runnerErr error
+			ts.runner, runnerErr = manager.NewRunner(runnerConfig, false)
+			if runnerErr != nil {
+				ts.logger.Error("template server failed with new Vault address", "error", runnerErr)
+				continue
+			}
+			go ts.runner.Start()
+
 		case err := <-ts.runner.ErrCh:
 			ts.logger.Error("template server error", "error", err.Error())
+			ts.recordError(err)
 			ts.runner.StopImmediately()
 
 			// Return after stopping the runner if exit on retry failure was
@@ -202,20 +287,27 @@ func (ts *Server) Run(ctx context.Context, incoming chan string, templates []*ct
 		case <-ts.runner.TemplateRenderedCh():
 			// A template has been rendered, figure out what to do
 			events := ts.runner.RenderEvents()
+			ts.recordRenders(events)
+
+			// Publish the projections whose templates have all been
+			// rendered, before possibly exiting after auth
+			for _, p := range projections {
+				if _, err := p.update(events); err != nil {
+					ts.logger.Error("failed to publish projection", "projection", p.config.Name, "error", err)
+					ts.recordError(err)
+				}
+			}
 
 			// events are keyed by template ID, and can be matched up to the id's from
 			// the lookupMap
-			if len(events) < len(ts.lookupMap) {
-				// Not all templates have been rendered yet
-				continue
-			}
-
-			// assume the renders are finished, until we find otherwise
-			doneRendering := true
-			for _, event := range events {
-				// This template hasn't been rendered
-				if event.LastWouldRender.IsZero() {
-					doneRendering = false
+			doneRendering := allRendered(events, ts.lookupMap)
+			if doneRendering {
+				ts.recordLiveRender()
+				if snap != nil {
+					if err := ts.saveSnapshot(ctx, snap, templates); err != nil {
+						ts.logger.Error("failed to save template snapshot", "error", err)
+						ts.recordError(err)
+					}
 				}
 			}
 
@@ -230,6 +322,61 @@ func (ts *Server) Run(ctx context.Context, incoming chan string, templates []*ct
 	}
 }
 
+// allRendered returns whether all the templates have been rendered.
+func allRendered(events map[string]*manager.RenderEvent, lookupMap map[string][]*ctconfig.TemplateConfig) bool {
+	if len(events) < len(lookupMap) {
+		return false
+	}
+	for _, event := range events {
+		if event.LastWouldRender.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// renderSnapshot renders the templates from the snapshot, as Vault is
+// unreachable, runs their commands, and publishes the projections.
+func (ts *Server) renderSnapshot(data *snapshotData, templates []*ctconfig.TemplateConfig, projections []*projection) {
+	ts.logger.Warn("Vault is unreachable, rendering templates from snapshot", "created", data.Created)
+
+	written, err := writeTemplates(templates, data)
+	if err != nil {
+		ts.logger.Error("failed to render templates from snapshot", "error", err)
+		ts.recordError(err)
+		return
+	}
+	if len(written) < len(templates) {
+		ts.logger.Warn("snapshot is missing templates", "rendered", len(written), "templates", len(templates))
+	}
+	ts.recordSnapshotRender(data.Created)
+
+	// Run the commands of the templates written, in sequence, as
+	// consul-template does when it renders them
+	for _, tmpl := range written {
+		if err := runTemplateCommand(tmpl, ts.logger.StandardLogger(nil)); err != nil {
+			ts.logger.Error("failed to run template command from snapshot", "destination", *tmpl.Destination, "error", err)
+			ts.recordError(err)
+		}
+	}
+
+	for _, p := range projections {
+		if _, err := p.publishStaged(); err != nil {
+			ts.logger.Error("failed to publish projection from snapshot", "projection", p.config.Name, "error", err)
+			ts.recordError(err)
+		}
+	}
+}
+
+// saveSnapshot persists the rendered templates to the snapshot.
+func (ts *Server) saveSnapshot(ctx context.Context, snap *snapshot, templates []*ctconfig.TemplateConfig) error {
+	contents, err := readTemplates(templates)
+	if err != nil {
+		return err
+	}
+	return snap.save(ctx, contents)
+}
+
 func (ts *Server) Stop() {
 	if ts.stopped.CAS(false, true) {
 		close(ts.DoneCh)
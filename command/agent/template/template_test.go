@@ -582,3 +582,34 @@ var templateContentsWithSprigFunctions = `
 }
 {{ end }}
 `
+
+// TestServer_RecordError tests that the errors of the runner are counted
+// against the template they are about, and against the runner otherwise.
+func TestServer_RecordError(t *testing.T) {
+	configs := []*ctconfig.TemplateConfig{
+		{Source: pointerutil.StringPtr("/tmp/foo.tmpl"), Destination: pointerutil.StringPtr("/tmp/foo")},
+		{Source: pointerutil.StringPtr("/tmp/bar.tmpl"), Destination: pointerutil.StringPtr("/tmp/bar")},
+	}
+	ts := &Server{
+		templateStatus: map[string]*templateStatus{
+			"foo": {destination: "/tmp/foo", configs: configs[:1], rendered: true, lastWouldRender: time.Now()},
+			"bar": {destination: "/tmp/bar", configs: configs[1:], rendered: true, lastWouldRender: time.Now()},
+		},
+	}
+
+	ts.recordError(fmt.Errorf("error rendering %s: permission denied", configs[0].Display()))
+	ts.recordError(fmt.Errorf("/tmp/foo.tmpl: execute: missing key"))
+	ts.recordError(fmt.Errorf("vault.read(secret/baz): permission denied"))
+
+	status, healthy := ts.HealthStatus()
+	require.False(t, healthy)
+	s := status.(*Status)
+	require.Equal(t, uint64(1), s.Errors)
+	require.Equal(t, "vault.read(secret/baz): permission denied", s.LastError)
+	require.Len(t, s.Templates, 2)
+	require.Equal(t, "/tmp/bar", s.Templates[0].Destination)
+	require.Zero(t, s.Templates[0].Errors)
+	require.Equal(t, "/tmp/foo", s.Templates[1].Destination)
+	require.Equal(t, uint64(2), s.Templates[1].Errors)
+	require.Equal(t, "/tmp/foo.tmpl: execute: missing key", s.Templates[1].LastError)
+}
@@ -11,6 +11,7 @@ import (
 	"math"
 	"math/rand"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -70,6 +71,10 @@ type AuthHandler struct {
 	enableTemplateTokenCh        bool
 	enableExecTokenCh            bool
 	exitOnError                  bool
+	fallbackMethods              []*FallbackAuthMethod
+
+	statusLock sync.RWMutex
+	status     authStatus
 }
 
 type AuthHandlerConfig struct {
@@ -89,6 +94,24 @@ type AuthHandlerConfig struct {
 	EnableTemplateTokenCh        bool
 	EnableExecTokenCh            bool
 	ExitOnError                  bool
+	// FallbackMethods are used, in order, when the auth method given to Run
+	// and the fallback methods before them fail to authenticate.
+	FallbackMethods []*FallbackAuthMethod
+}
+
+// FallbackAuthMethod is an auth method used when the auth methods before it
+// fail to authenticate. Each method backs off on its own, so a method that
+// failed is only tried again once its backoff elapses, and the handler
+// switches back to the first method once it can authenticate again.
+type FallbackAuthMethod struct {
+	// Name identifies the method in the logs
+	Name   string
+	Method AuthMethod
+	// Namespace is the namespace to authenticate in, if it differs from the
+	// namespace of the client of the handler
+	Namespace  string
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
 }
 
 func NewAuthHandler(conf *AuthHandlerConfig) *AuthHandler {
@@ -111,6 +134,7 @@ func NewAuthHandler(conf *AuthHandlerConfig) *AuthHandler {
 		exitOnError:                  conf.ExitOnError,
 		userAgent:                    conf.UserAgent,
 		metricsSignifier:             conf.MetricsSignifier,
+		fallbackMethods:              conf.FallbackMethods,
 	}
 
 	return ah
@@ -128,6 +152,138 @@ func backoffSleep(ctx context.Context, backoff *autoAuthBackoff) bool {
 	return true
 }
 
+// authMethodState tracks the health of an auth method used by the handler.
+type authMethodState struct {
+	name    string
+	method  AuthMethod
+	client  *api.Client
+	backoff *autoAuthBackoff
+
+	// retryAt is when the method can be used again after failing
+	retryAt time.Time
+	// exhausted is set once the method failed more times than its backoff
+	// allows
+	exhausted bool
+}
+
+func (m *authMethodState) available(now time.Time) bool {
+	return !m.exhausted && !now.Before(m.retryAt)
+}
+
+func (m *authMethodState) succeeded() {
+	m.backoff.backoff.Reset()
+	m.retryAt = time.Time{}
+}
+
+// newAuthMethodStates returns the states of the primary auth method and of
+// the fallback methods, in order.
+func (ah *AuthHandler) newAuthMethodStates(am AuthMethod) ([]*authMethodState, error) {
+	methods := []*authMethodState{
+		{
+			name:    "primary",
+			method:  am,
+			client:  ah.client,
+			backoff: newAutoAuthBackoff(ah.minBackoff, ah.maxBackoff, ah.exitOnError),
+		},
+	}
+	for _, fm := range ah.fallbackMethods {
+		client := ah.client
+		if client != nil && fm.Namespace != "" {
+			var err error
+			client, err = ah.client.CloneWithHeaders()
+			if err != nil {
+				return nil, fmt.Errorf("auth handler: error cloning client for fallback auth method %q: %w", fm.Name, err)
+			}
+			client.SetNamespace(fm.Namespace)
+		}
+		methods = append(methods, &authMethodState{
+			name:    fm.Name,
+			method:  fm.Method,
+			client:  client,
+			backoff: newAutoAuthBackoff(fm.MinBackoff, fm.MaxBackoff, ah.exitOnError),
+		})
+	}
+	return methods, nil
+}
+
+// nextAuthMethod returns the first auth method that is not backing off, or
+// nil if there is none.
+func nextAuthMethod(methods []*authMethodState) *authMethodState {
+	now := time.Now()
+	for _, m := range methods {
+		if m.available(now) {
+			return m
+		}
+	}
+	return nil
+}
+
+// authMethodFailed backs off the auth method that failed. If another method
+// is available, it returns right away so that the method is used next.
+// Otherwise it sleeps until the first method is available again. It returns
+// false if no method is left to retry.
+func (ah *AuthHandler) authMethodFailed(ctx context.Context, methods []*authMethodState, m *authMethodState) bool {
+	nextSleep, err := m.backoff.backoff.Next()
+	if err != nil {
+		m.exhausted = true
+	} else {
+		m.retryAt = time.Now().Add(nextSleep)
+	}
+
+	if nextAuthMethod(methods) != nil {
+		return true
+	}
+
+	var retryAt time.Time
+	for _, m := range methods {
+		if !m.exhausted && (retryAt.IsZero() || m.retryAt.Before(retryAt)) {
+			retryAt = m.retryAt
+		}
+	}
+	if retryAt.IsZero() {
+		return false
+	}
+	select {
+	case <-time.After(time.Until(retryAt)):
+	case <-ctx.Done():
+	}
+	return true
+}
+
+// primaryRetryCh returns a channel that fires when the primary auth method
+// can be retried while a fallback method is in use.
+func primaryRetryCh(methods []*authMethodState, current *authMethodState) <-chan time.Time {
+	primary := methods[0]
+	if current == primary || primary.exhausted {
+		return nil
+	}
+	return time.After(time.Until(primary.retryAt))
+}
+
+// watchNewCreds forwards the new credentials notifications of an auth method
+// to credCh if re-authenticating on new credentials is enabled, and drops
+// them otherwise.
+func (ah *AuthHandler) watchNewCreds(ctx context.Context, methodCredCh chan struct{}, credCh chan struct{}) {
+	if methodCredCh == nil {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-methodCredCh:
+		}
+		if !ah.enableReauthOnNewCredentials {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case credCh <- struct{}{}:
+		}
+	}
+}
+
 func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 	if am == nil {
 		return errors.New("auth handler: nil auth method")
@@ -142,37 +298,27 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 	if ah.minBackoff > ah.maxBackoff {
 		return errors.New("auth handler: min_backoff cannot be greater than max_backoff")
 	}
-	backoffCfg := newAutoAuthBackoff(ah.minBackoff, ah.maxBackoff, ah.exitOnError)
+	for _, fm := range ah.fallbackMethods {
+		if fm.Method == nil {
+			return fmt.Errorf("auth handler: nil fallback auth method %q", fm.Name)
+		}
+		if fm.MinBackoff > 0 && fm.MaxBackoff > 0 && fm.MinBackoff > fm.MaxBackoff {
+			return fmt.Errorf("auth handler: min_backoff cannot be greater than max_backoff for fallback auth method %q", fm.Name)
+		}
+	}
 
 	ah.logger.Info("starting auth handler")
 	defer func() {
 		am.Shutdown()
+		for _, fm := range ah.fallbackMethods {
+			fm.Method.Shutdown()
+		}
 		close(ah.OutputCh)
 		close(ah.TemplateTokenCh)
 		close(ah.ExecTokenCh)
 		ah.logger.Info("auth handler stopped")
 	}()
 
-	credCh := am.NewCreds()
-	if !ah.enableReauthOnNewCredentials {
-		realCredCh := credCh
-		credCh = nil
-		if realCredCh != nil {
-			go func() {
-				for {
-					select {
-					case <-ctx.Done():
-						return
-					case <-realCredCh:
-					}
-				}
-			}()
-		}
-	}
-	if credCh == nil {
-		credCh = make(chan struct{})
-	}
-
 	if ah.client != nil {
 		headers := ah.client.Headers()
 		if headers == nil {
@@ -182,9 +328,24 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		ah.client.SetHeaders(headers)
 	}
 
+	methods, err := ah.newAuthMethodStates(am)
+	if err != nil {
+		return err
+	}
+
+	credCh := make(chan struct{})
+	for _, m := range methods {
+		go ah.watchNewCreds(ctx, m.method.NewCreds(), credCh)
+	}
+
 	var watcher *api.LifetimeWatcher
+	var current *authMethodState
 	first := true
 
+	// A preloaded token that fails to be looked up is not a failure of the
+	// auth methods, so it backs off on its own
+	preloadedBackoff := newAutoAuthBackoff(ah.minBackoff, ah.maxBackoff, ah.exitOnError)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -193,6 +354,16 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		default:
 		}
 
+		m := nextAuthMethod(methods)
+		if m == nil {
+			return errors.New("auth handler: no auth method left to authenticate with")
+		}
+		if current != nil && m != current && m != methods[0] {
+			ah.logger.Warn("falling back to auth method", "method", m.name)
+			metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "fallback"}, 1)
+		}
+		current = m
+
 		var clientToUse *api.Client
 		var err error
 		var path string
@@ -200,21 +371,21 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		var header http.Header
 		var isTokenFileMethod bool
 
-		switch am.(type) {
+		switch m.method.(type) {
 		case AuthMethodWithClient:
-			clientToUse, err = am.(AuthMethodWithClient).AuthClient(ah.client)
+			clientToUse, err = m.method.(AuthMethodWithClient).AuthClient(m.client)
 			if err != nil {
-				ah.logger.Error("error creating client for authentication call", "error", err, "backoff", backoffCfg)
+				ah.logger.Error("error creating client for authentication call", "error", err, "backoff", m.backoff)
 				metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
-				if backoffSleep(ctx, backoffCfg) {
+				if ah.authMethodFailed(ctx, methods, m) {
 					continue
 				}
 
 				return err
 			}
 		default:
-			clientToUse = ah.client
+			clientToUse = m.client
 		}
 
 		// Disable retry on the client to ensure our backoffOrQuit function is
@@ -231,10 +402,10 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 
 			secret, err = clientToUse.Auth().Token().LookupSelfWithContext(ctx)
 			if err != nil {
-				ah.logger.Error("could not look up token", "err", err, "backoff", backoffCfg)
+				ah.logger.Error("could not look up token", "err", err, "backoff", preloadedBackoff)
 				metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
-				if backoffSleep(ctx, backoffCfg) {
+				if backoffSleep(ctx, preloadedBackoff) {
 					continue
 				}
 				return err
@@ -249,12 +420,12 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		} else {
 			ah.logger.Info("authenticating")
 
-			path, header, data, err = am.Authenticate(ctx, ah.client)
+			path, header, data, err = m.method.Authenticate(ctx, m.client)
 			if err != nil {
-				ah.logger.Error("error getting path or data from method", "error", err, "backoff", backoffCfg)
+				ah.logger.Error("error getting path or data from method", "error", err, "backoff", m.backoff)
 				metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
-				if backoffSleep(ctx, backoffCfg) {
+				if ah.authMethodFailed(ctx, methods, m) {
 					continue
 				}
 				return err
@@ -264,10 +435,10 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		if ah.wrapTTL > 0 {
 			wrapClient, err := clientToUse.CloneWithHeaders()
 			if err != nil {
-				ah.logger.Error("error creating client for wrapped call", "error", err, "backoff", backoffCfg)
+				ah.logger.Error("error creating client for wrapped call", "error", err, "backoff", m.backoff)
 				metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
-				if backoffSleep(ctx, backoffCfg) {
+				if ah.authMethodFailed(ctx, methods, m) {
 					continue
 				}
 				return err
@@ -302,10 +473,10 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 
 			// Check errors/sanity
 			if err != nil {
-				ah.logger.Error("error authenticating", "error", err, "backoff", backoffCfg)
+				ah.logger.Error("error authenticating", "error", err, "backoff", m.backoff)
 				metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
-				if backoffSleep(ctx, backoffCfg) {
+				if ah.authMethodFailed(ctx, methods, m) {
 					continue
 				}
 				return err
@@ -317,34 +488,35 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		switch {
 		case ah.wrapTTL > 0:
 			if secret.WrapInfo == nil {
-				ah.logger.Error("authentication returned nil wrap info", "backoff", backoffCfg)
+				ah.logger.Error("authentication returned nil wrap info", "backoff", m.backoff)
 				metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
-				if backoffSleep(ctx, backoffCfg) {
+				if ah.authMethodFailed(ctx, methods, m) {
 					continue
 				}
 				return err
 			}
 			if secret.WrapInfo.Token == "" {
-				ah.logger.Error("authentication returned empty wrapped client token", "backoff", backoffCfg)
+				ah.logger.Error("authentication returned empty wrapped client token", "backoff", m.backoff)
 				metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
-				if backoffSleep(ctx, backoffCfg) {
+				if ah.authMethodFailed(ctx, methods, m) {
 					continue
 				}
 				return err
 			}
 			wrappedResp, err := jsonutil.EncodeJSON(secret.WrapInfo)
 			if err != nil {
-				ah.logger.Error("failed to encode wrapinfo", "error", err, "backoff", backoffCfg)
+				ah.logger.Error("failed to encode wrapinfo", "error", err, "backoff", m.backoff)
 				metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
-				if backoffSleep(ctx, backoffCfg) {
+				if ah.authMethodFailed(ctx, methods, m) {
 					continue
 				}
 				return err
 			}
 			ah.logger.Info("authentication successful, sending wrapped token to sinks and pausing")
+			ah.recordWrappedLogin()
 			ah.OutputCh <- string(wrappedResp)
 			if ah.enableTemplateTokenCh {
 				ah.TemplateTokenCh <- string(wrappedResp)
@@ -353,8 +525,8 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 				ah.ExecTokenCh <- string(wrappedResp)
 			}
 
-			am.CredSuccess()
-			backoffCfg.backoff.Reset()
+			m.method.CredSuccess()
+			m.succeeded()
 
 			select {
 			case <-ctx.Done():
@@ -364,6 +536,10 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 			case <-credCh:
 				ah.logger.Info("auth method found new credentials, re-authenticating")
 				continue
+
+			case <-primaryRetryCh(methods, m):
+				ah.logger.Info("retrying the primary auth method")
+				continue
 			}
 
 		default:
@@ -375,20 +551,20 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 				// We still check the response of the request to ensure the token is valid
 				// i.e. if the token is invalid, we will fail in the authentication step
 				if secret == nil || secret.Data == nil {
-					ah.logger.Error("token file validation failed, token may be invalid", "backoff", backoffCfg)
+					ah.logger.Error("token file validation failed, token may be invalid", "backoff", m.backoff)
 					metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
-					if backoffSleep(ctx, backoffCfg) {
+					if ah.authMethodFailed(ctx, methods, m) {
 						continue
 					}
 					return err
 				}
 				token, ok := secret.Data["id"].(string)
 				if !ok || token == "" {
-					ah.logger.Error("token file validation returned empty client token", "backoff", backoffCfg)
+					ah.logger.Error("token file validation returned empty client token", "backoff", m.backoff)
 					metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
-					if backoffSleep(ctx, backoffCfg) {
+					if ah.authMethodFailed(ctx, methods, m) {
 						continue
 					}
 					return err
@@ -417,19 +593,19 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 				}
 			} else {
 				if secret == nil || secret.Auth == nil {
-					ah.logger.Error("authentication returned nil auth info", "backoff", backoffCfg)
+					ah.logger.Error("authentication returned nil auth info", "backoff", m.backoff)
 					metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
-					if backoffSleep(ctx, backoffCfg) {
+					if ah.authMethodFailed(ctx, methods, m) {
 						continue
 					}
 					return err
 				}
 				if secret.Auth.ClientToken == "" {
-					ah.logger.Error("authentication returned empty client token", "backoff", backoffCfg)
+					ah.logger.Error("authentication returned empty client token", "backoff", m.backoff)
 					metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
-					if backoffSleep(ctx, backoffCfg) {
+					if ah.authMethodFailed(ctx, methods, m) {
 						continue
 					}
 					return err
@@ -446,8 +622,8 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 				}
 			}
 
-			am.CredSuccess()
-			backoffCfg.backoff.Reset()
+			m.method.CredSuccess()
+			m.succeeded()
 		}
 
 		if watcher != nil {
@@ -458,16 +634,17 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 			Secret: secret,
 		})
 		if err != nil {
-			ah.logger.Error("error creating lifetime watcher", "error", err, "backoff", backoffCfg)
+			ah.logger.Error("error creating lifetime watcher", "error", err, "backoff", m.backoff)
 			metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
-			if backoffSleep(ctx, backoffCfg) {
+			if ah.authMethodFailed(ctx, methods, m) {
 				continue
 			}
 			return err
 		}
 
 		metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "success"}, 1)
+		ah.recordLogin(secret.Auth.LeaseDuration)
 		// We don't want to trigger the renewal process for the root token
 		if isRootToken(leaseDuration, isTokenFileMethod, secret) {
 			ah.logger.Info("not starting token renewal process, as token is root token")
@@ -487,7 +664,7 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 			case err := <-watcher.DoneCh():
 				ah.logger.Info("lifetime watcher done channel triggered, re-authenticating")
 				if err != nil {
-					ah.logger.Error("error renewing token", "error", err, "backoff", backoffCfg)
+					ah.logger.Error("error renewing token", "error", err, "backoff", m.backoff)
 					metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "failure"}, 1)
 
 					// Add some exponential backoff so that if auth is successful
@@ -497,7 +674,7 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 					// auth, we reset the backoff. Still, some backoff is important, and
 					// ensuring we follow the normal flow is important:
 					// auth -> try to renew
-					if !backoffSleep(ctx, backoffCfg) {
+					if !backoffSleep(ctx, m.backoff) {
 						// We're at max retries. Return an error.
 						return fmt.Errorf("exceeded max retries failing to renew auth token")
 					}
@@ -512,13 +689,20 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 
 				break LifetimeWatcherLoop
 
-			case <-watcher.RenewCh():
+			case renewal := <-watcher.RenewCh():
 				metrics.IncrCounter([]string{ah.metricsSignifier, "auth", "success"}, 1)
 				ah.logger.Info("renewed auth token")
+				if renewal != nil && renewal.Secret != nil && renewal.Secret.Auth != nil {
+					ah.recordRenewal(renewal.Secret.Auth.LeaseDuration)
+				}
 
 			case <-credCh:
 				ah.logger.Info("auth method found new credentials, re-authenticating")
 				break LifetimeWatcherLoop
+
+			case <-primaryRetryCh(methods, m):
+				ah.logger.Info("retrying the primary auth method")
+				break LifetimeWatcherLoop
 			}
 		}
 	}
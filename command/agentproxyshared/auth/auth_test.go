@@ -5,7 +5,9 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -108,6 +110,258 @@ consumption:
 	}
 }
 
+// TestAuthHandler_WrappedHealthStatus tests that auto-auth stays healthy
+// after delivering a wrapped token, once the wrapping TTL has passed.
+func TestAuthHandler_WrappedHealthStatus(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		CredentialBackends: map[string]logical.Factory{
+			"userpass": userpass.Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	client := cluster.Cores[0].Client
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	ah := NewAuthHandler(&AuthHandlerConfig{
+		Logger:  logging.NewVaultLogger(hclog.Trace).Named("auth.handler"),
+		Client:  client,
+		WrapTTL: time.Second,
+	})
+
+	am := newUserpassTestMethod(t, client)
+	go ah.Run(ctx, am)
+
+	select {
+	case <-ah.OutputCh:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the wrapped token")
+	}
+	time.Sleep(2 * time.Second)
+
+	status, healthy := ah.HealthStatus()
+	if !healthy {
+		t.Fatalf("expected auto-auth to be healthy, got %#v", status)
+	}
+	if s := status.(*Status); !s.Authenticated || !s.Wrapped || s.TokenTTL != nil {
+		t.Fatalf("unexpected status %#v", s)
+	}
+}
+
+// toggleableUserpassTestMethod logs in as a userpass user, unless it is set
+// to fail.
+type toggleableUserpassTestMethod struct {
+	username string
+	fail     atomic.Bool
+}
+
+func (u *toggleableUserpassTestMethod) Authenticate(_ context.Context, client *api.Client) (string, http.Header, map[string]interface{}, error) {
+	if u.fail.Load() {
+		return "", nil, nil, errors.New("auth method unavailable")
+	}
+	return "auth/userpass/login/" + u.username, nil, map[string]interface{}{
+		"password": "bar",
+	}, nil
+}
+
+func (u *toggleableUserpassTestMethod) NewCreds() chan struct{} {
+	return nil
+}
+
+func (u *toggleableUserpassTestMethod) CredSuccess() {
+}
+
+func (u *toggleableUserpassTestMethod) Shutdown() {
+}
+
+func TestAuthHandler_FallbackMethods(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		CredentialBackends: map[string]logical.Factory{
+			"userpass": userpass.Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	client := cluster.Cores[0].Client
+
+	if err := client.Sys().EnableAuthWithOptions("userpass", &api.EnableAuthOptions{Type: "userpass"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, username := range []string{"primary", "fallback"} {
+		if _, err := client.Logical().Write("auth/userpass/users/"+username, map[string]interface{}{
+			"password": "bar",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	primary := &toggleableUserpassTestMethod{username: "primary"}
+	primary.fail.Store(true)
+	fallback := &toggleableUserpassTestMethod{username: "fallback"}
+
+	ahClient, err := client.CloneWithHeaders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ah := NewAuthHandler(&AuthHandlerConfig{
+		Logger:     logging.NewVaultLogger(hclog.Trace).Named("auth.handler"),
+		Client:     ahClient,
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: 200 * time.Millisecond,
+		FallbackMethods: []*FallbackAuthMethod{
+			{
+				Name:   "fallback",
+				Method: fallback,
+			},
+		},
+	})
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ah.Run(ctx, primary)
+	}()
+
+	// tokenUser returns the user the next token was issued to
+	tokenUser := func() string {
+		t.Helper()
+		select {
+		case token := <-ah.OutputCh:
+			lookupClient, err := client.Clone()
+			if err != nil {
+				t.Fatal(err)
+			}
+			lookupClient.SetToken(token)
+			secret, err := lookupClient.Auth().Token().LookupSelf()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return secret.Data["meta"].(map[string]interface{})["username"].(string)
+		case err := <-errCh:
+			t.Fatalf("auth handler stopped: %v", err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for a token")
+		}
+		return ""
+	}
+
+	// The fallback method is used while the primary one fails
+	if user := tokenUser(); user != "fallback" {
+		t.Fatalf("expected a token from the fallback method, got one from %q", user)
+	}
+
+	// The handler switches back once the primary method recovers
+	primary.fail.Store(false)
+	deadline := time.Now().Add(10 * time.Second)
+	for tokenUser() != "primary" {
+		if time.Now().After(deadline) {
+			t.Fatal("auth handler did not switch back to the primary method")
+		}
+	}
+
+	cancelFunc()
+	for range ah.OutputCh {
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestAuthHandler_PreloadedTokenBackoff tests that a preloaded token that
+// fails to be looked up does not back off the primary method, so the primary
+// method is used next rather than the fallback method.
+func TestAuthHandler_PreloadedTokenBackoff(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		CredentialBackends: map[string]logical.Factory{
+			"userpass": userpass.Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	client := cluster.Cores[0].Client
+
+	if err := client.Sys().EnableAuthWithOptions("userpass", &api.EnableAuthOptions{Type: "userpass"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, username := range []string{"primary", "fallback"} {
+		if _, err := client.Logical().Write("auth/userpass/users/"+username, map[string]interface{}{
+			"password": "bar",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ahClient, err := client.CloneWithHeaders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ah := NewAuthHandler(&AuthHandlerConfig{
+		Logger:     logging.NewVaultLogger(hclog.Trace).Named("auth.handler"),
+		Client:     ahClient,
+		Token:      "not-a-token",
+		MinBackoff: time.Second,
+		MaxBackoff: 2 * time.Second,
+		FallbackMethods: []*FallbackAuthMethod{
+			{
+				Name:   "fallback",
+				Method: &toggleableUserpassTestMethod{username: "fallback"},
+			},
+		},
+	})
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ah.Run(ctx, &toggleableUserpassTestMethod{username: "primary"})
+	}()
+
+	select {
+	case token := <-ah.OutputCh:
+		lookupClient, err := client.Clone()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lookupClient.SetToken(token)
+		secret, err := lookupClient.Auth().Token().LookupSelf()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user := secret.Data["meta"].(map[string]interface{})["username"]; user != "primary" {
+			t.Fatalf("expected a token from the primary method, got one from %q", user)
+		}
+	case err := <-errCh:
+		t.Fatalf("auth handler stopped: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for a token")
+	}
+
+	cancelFunc()
+	for range ah.OutputCh {
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestAgentBackoff(t *testing.T) {
 	max := 1024 * time.Second
 	backoff := newAutoAuthBackoff(defaultMinBackoff, max, false)
@@ -0,0 +1,115 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package auth
+
+import (
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/command/agentproxyshared/health"
+)
+
+// Status is the health status of auto-auth.
+type Status struct {
+	Authenticated       bool       `json:"authenticated"`
+	LastSuccessfulLogin *time.Time `json:"last_successful_login,omitempty"`
+	LastRenewal         *time.Time `json:"last_renewal,omitempty"`
+	// TokenTTL is the number of seconds until the token expires, or nil when
+	// the token does not expire or is wrapped
+	TokenTTL *int64 `json:"token_ttl,omitempty"`
+	// Wrapped is set when a wrapped token was delivered, after which
+	// auto-auth pauses and no longer tracks the token
+	Wrapped bool `json:"wrapped,omitempty"`
+}
+
+// authStatus holds what the handler knows of its token.
+type authStatus struct {
+	lastLogin   time.Time
+	lastRenewal time.Time
+	// tokenExpiry is zero for tokens that do not expire and wrapped tokens
+	tokenExpiry time.Time
+	wrapped     bool
+}
+
+// recordLogin records a successful login returning a token with the given TTL
+// in seconds, zero meaning that the token does not expire.
+func (ah *AuthHandler) recordLogin(ttl int) {
+	ah.statusLock.Lock()
+	defer ah.statusLock.Unlock()
+	now := time.Now()
+	ah.status.lastLogin = now
+	ah.status.lastRenewal = time.Time{}
+	ah.status.tokenExpiry = expiry(now, ttl)
+	ah.status.wrapped = false
+}
+
+// recordWrappedLogin records a successful login returning a wrapped token.
+// Its expiry is not recorded, as the wrapping TTL only bounds the time left
+// to unwrap it, and auto-auth stays healthy while it pauses.
+func (ah *AuthHandler) recordWrappedLogin() {
+	ah.statusLock.Lock()
+	defer ah.statusLock.Unlock()
+	ah.status.lastLogin = time.Now()
+	ah.status.lastRenewal = time.Time{}
+	ah.status.tokenExpiry = time.Time{}
+	ah.status.wrapped = true
+}
+
+// recordRenewal records a successful renewal extending the TTL of the token
+// to the given number of seconds.
+func (ah *AuthHandler) recordRenewal(ttl int) {
+	ah.statusLock.Lock()
+	defer ah.statusLock.Unlock()
+	now := time.Now()
+	ah.status.lastRenewal = now
+	ah.status.tokenExpiry = expiry(now, ttl)
+}
+
+func expiry(now time.Time, ttl int) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(time.Duration(ttl) * time.Second)
+}
+
+var _ health.Component = (*AuthHandler)(nil)
+
+// HealthStatus implements health.Component. Auto-auth is healthy once it has
+// authenticated, as long as the token has not expired.
+func (ah *AuthHandler) HealthStatus() (interface{}, bool) {
+	ah.statusLock.RLock()
+	defer ah.statusLock.RUnlock()
+
+	status := &Status{
+		Authenticated:       !ah.status.lastLogin.IsZero(),
+		LastSuccessfulLogin: health.TimePtr(ah.status.lastLogin),
+		LastRenewal:         health.TimePtr(ah.status.lastRenewal),
+		Wrapped:             ah.status.wrapped,
+	}
+	if status.Authenticated && !ah.status.tokenExpiry.IsZero() {
+		ttl := int64(time.Until(ah.status.tokenExpiry).Seconds())
+		if ttl <= 0 {
+			ttl = 0
+			status.Authenticated = false
+		}
+		status.TokenTTL = &ttl
+	}
+	return status, status.Authenticated
+}
+
+// EmitMetrics implements health.MetricsEmitter, reporting the remaining TTL
+// of the token.
+func (ah *AuthHandler) EmitMetrics() {
+	ah.statusLock.RLock()
+	defer ah.statusLock.RUnlock()
+
+	if ah.status.lastLogin.IsZero() || ah.status.tokenExpiry.IsZero() {
+		return
+	}
+	ttl := time.Until(ah.status.tokenExpiry).Seconds()
+	if ttl < 0 {
+		ttl = 0
+	}
+	metrics.SetGauge([]string{ah.metricsSignifier, "auth", "token_ttl"}, float32(ttl))
+}
@@ -5,7 +5,9 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	gohttp "net/http"
 	"sync"
 
@@ -48,6 +50,7 @@ type APIProxy struct {
 	// (i.e. client.Namespace()) to avoid repeated calls and lock usage.
 	clientNamespace            string
 	prependConfiguredNamespace bool
+	failover                   FailoverFunc
 }
 
 var _ Proxier = &APIProxy{}
@@ -66,6 +69,27 @@ type APIProxyConfig struct {
 	// PrependConfiguredNamespace configures whether the client's namespace
 	// should be prepended to proxied requests
 	PrependConfiguredNamespace bool
+	// Failover, if set, is used to retry the requests that could not be sent
+	// to Vault against another Vault server. Requests that may have reached
+	// Vault are only retried if they are idempotent.
+	Failover FailoverFunc
+}
+
+// FailoverFunc is called when a request could not be sent to the Vault server
+// at the given address. It returns the address of the Vault server to retry
+// the request against, or false if there is none.
+type FailoverFunc func(ctx context.Context, failedAddress string) (string, bool)
+
+// canFailover returns true if a request that failed with the given error can
+// be retried against another Vault server without risking applying it twice,
+// which is the case if it was never sent or if it is idempotent.
+func canFailover(method string, err error) bool {
+	switch method {
+	case gohttp.MethodGet, gohttp.MethodHead, gohttp.MethodOptions, "LIST":
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
 }
 
 func NewAPIProxy(config *APIProxyConfig) (Proxier, error) {
@@ -81,6 +105,7 @@ func NewAPIProxy(config *APIProxyConfig) (Proxier, error) {
 		userAgentStringFunction:    config.UserAgentStringFunction,
 		prependConfiguredNamespace: config.PrependConfiguredNamespace,
 		clientNamespace:            namespace.Canonicalize(config.Client.Namespace()),
+		failover:                   config.Failover,
 	}, nil
 }
 
@@ -160,6 +185,21 @@ func (ap *APIProxy) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 	ap.logger.Info("forwarding request to Vault", "method", req.Request.Method, "path", req.Request.URL.Path)
 
 	resp, err := client.RawRequestWithContext(ctx, fwReq)
+	if resp == nil && err != nil && ap.failover != nil && ctx.Err() == nil {
+		// The other servers are checked either way, so the next requests are
+		// sent to a healthy one
+		if addr, ok := ap.failover(ctx, client.Address()); ok && canFailover(fwReq.Method, err) {
+			ap.logger.Warn("failed to forward request to Vault, retrying against another Vault server", "address", addr, "error", err)
+			if err := client.SetAddress(addr); err != nil {
+				return nil, err
+			}
+			retryReq := client.NewRequest(fwReq.Method, req.Request.URL.Path)
+			retryReq.BodyBytes = fwReq.BodyBytes
+			retryReq.Params = fwReq.Params
+			retryReq.Headers = fwReq.Headers
+			resp, err = client.RawRequestWithContext(ctx, retryReq)
+		}
+	}
 	if resp == nil && err != nil {
 		// We don't want to cache nil responses, so we simply return the error
 		return nil, err
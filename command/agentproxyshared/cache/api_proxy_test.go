@@ -22,6 +22,7 @@ import (
 	"github.com/hashicorp/vault/sdk/helper/logging"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/hashicorp/vault/vault"
+	"github.com/stretchr/testify/require"
 )
 
 const policyAdmin = `
@@ -68,6 +69,125 @@ func TestAPIProxy(t *testing.T) {
 	}
 }
 
+// TestAPIProxy_Failover tests that a request that could not be sent to Vault
+// is retried against the address returned by the failover function.
+func TestAPIProxy_Failover(t *testing.T) {
+	core, _, _ := vault.TestCoreUnsealed(t)
+	ln, addr := vaulthttp.TestServer(t, core)
+	defer ln.Close()
+
+	// An address that nothing listens on
+	deadLn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	deadAddr := "http://" + deadLn.Addr().String()
+	deadLn.Close()
+
+	client, err := api.NewClient(api.DefaultConfig())
+	require.NoError(t, err)
+	require.NoError(t, client.SetAddress(deadAddr))
+	client.SetMaxRetries(0)
+
+	var failed []string
+	proxier, err := NewAPIProxy(&APIProxyConfig{
+		Client:                  client,
+		Logger:                  logging.NewVaultLogger(hclog.Trace),
+		UserAgentStringFunction: useragent.ProxyStringWithProxiedUserAgent,
+		UserAgentString:         useragent.ProxyAPIProxyString(),
+		Failover: func(ctx context.Context, failedAddress string) (string, bool) {
+			failed = append(failed, failedAddress)
+			return addr, true
+		},
+	})
+	require.NoError(t, err)
+
+	r := client.NewRequest("GET", "/v1/sys/health")
+	req, err := r.ToHTTP()
+	require.NoError(t, err)
+
+	resp, err := proxier.Send(namespace.RootContext(nil), &SendRequest{
+		Request: req,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{deadAddr}, failed)
+
+	var result api.HealthResponse
+	require.NoError(t, jsonutil.DecodeJSONFromReader(resp.Response.Body, &result))
+	require.True(t, result.Initialized)
+	require.False(t, result.Sealed)
+}
+
+// TestAPIProxy_FailoverNonIdempotent tests that a request that is not
+// idempotent is only retried against another Vault server if it could not be
+// sent at all.
+func TestAPIProxy_FailoverNonIdempotent(t *testing.T) {
+	core, _, token := vault.TestCoreUnsealed(t)
+	ln, addr := vaulthttp.TestServer(t, core)
+	defer ln.Close()
+
+	// An address that nothing listens on
+	deadLn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	deadAddr := "http://" + deadLn.Addr().String()
+	deadLn.Close()
+
+	// An address that drops the connections once the request is sent
+	dropLn, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer dropLn.Close()
+	go func() {
+		for {
+			conn, err := dropLn.Accept()
+			if err != nil {
+				return
+			}
+			conn.Read(make([]byte, 1024))
+			conn.Close()
+		}
+	}()
+	dropAddr := "http://" + dropLn.Addr().String()
+
+	send := func(address string) (*SendResponse, []string, error) {
+		client, err := api.NewClient(api.DefaultConfig())
+		require.NoError(t, err)
+		require.NoError(t, client.SetAddress(address))
+		client.SetMaxRetries(0)
+		client.SetToken(token)
+
+		var failed []string
+		proxier, err := NewAPIProxy(&APIProxyConfig{
+			Client:                  client,
+			Logger:                  logging.NewVaultLogger(hclog.Trace),
+			UserAgentStringFunction: useragent.ProxyStringWithProxiedUserAgent,
+			UserAgentString:         useragent.ProxyAPIProxyString(),
+			Failover: func(ctx context.Context, failedAddress string) (string, bool) {
+				failed = append(failed, failedAddress)
+				return addr, true
+			},
+		})
+		require.NoError(t, err)
+
+		r := client.NewRequest("POST", "/v1/auth/token/create")
+		req, err := r.ToHTTP()
+		require.NoError(t, err)
+		resp, err := proxier.Send(namespace.RootContext(nil), &SendRequest{
+			Token:   token,
+			Request: req,
+		})
+		return resp, failed, err
+	}
+
+	// The request could not be sent, so it is retried
+	resp, failed, err := send(deadAddr)
+	require.NoError(t, err)
+	require.Equal(t, []string{deadAddr}, failed)
+	require.Equal(t, 200, resp.Response.StatusCode)
+
+	// The request may have been applied, so it is not retried
+	_, failed, err = send(dropAddr)
+	require.Error(t, err)
+	require.Equal(t, []string{dropAddr}, failed)
+}
+
 func TestAPIProxyNoCache(t *testing.T) {
 	cleanup, client, _, _ := setupClusterAndAgentNoCache(namespace.RootContext(nil), t, nil)
 	defer cleanup()
@@ -285,9 +405,9 @@ func setupClusterAndAgentCommon(ctx context.Context, t *testing.T, coreConfig *v
 
 		mux.Handle("/agent/v1/cache-clear", leaseCache.HandleCacheClear(ctx))
 
-		mux.Handle("/", ProxyHandler(ctx, cacheLogger, leaseCache, nil, true))
+		mux.Handle("/", ProxyHandler(ctx, cacheLogger, leaseCache, nil, true, "agent"))
 	} else {
-		mux.Handle("/", ProxyHandler(ctx, apiProxyLogger, apiProxy, nil, true))
+		mux.Handle("/", ProxyHandler(ctx, apiProxyLogger, apiProxy, nil, true, "agent"))
 	}
 
 	server := &http.Server{
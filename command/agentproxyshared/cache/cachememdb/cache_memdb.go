@@ -23,6 +23,10 @@ var ErrCacheItemNotFound = errors.New("cache item not found")
 // CacheMemDB is the underlying cache database for storing indexes.
 type CacheMemDB struct {
 	db *atomic.Value
+
+	// lru bounds the memory used by the indexes of a given type, if a
+	// memory limit is set
+	lru *lruTracker
 }
 
 // New creates a new instance of CacheMemDB.
@@ -149,6 +153,26 @@ func newDB() (*memdb.MemDB, error) {
 	return db, nil
 }
 
+// SetMemoryLimit bounds the memory used by the responses of the indexes of
+// the type of the given limit, by evicting the least recently used ones. It
+// must be called before the cache is used.
+func (c *CacheMemDB) SetMemoryLimit(limit *MemoryLimit) {
+	if limit == nil || limit.Limit == 0 {
+		c.lru = nil
+		return
+	}
+	c.lru = newLRUTracker(limit)
+}
+
+// MemoryUsage returns the total size, in bytes, of the responses of the
+// indexes the memory limit applies to, or 0 if no memory limit is set.
+func (c *CacheMemDB) MemoryUsage() uint64 {
+	if c.lru == nil {
+		return 0
+	}
+	return c.lru.usage()
+}
+
 // Get returns the index based on the indexer and the index values provided.
 // If the capabilities index isn't present, it will return nil, ErrCacheItemNotFound
 func (c *CacheMemDB) Get(indexName string, indexValues ...interface{}) (*Index, error) {
@@ -172,6 +196,10 @@ func (c *CacheMemDB) Get(indexName string, indexValues ...interface{}) (*Index,
 		return nil, errors.New("unable to parse index value from the cache")
 	}
 
+	if c.lru != nil && c.lru.applies(index) {
+		c.lru.touch(index.ID)
+	}
+
 	return index, nil
 }
 
@@ -188,8 +216,42 @@ func (c *CacheMemDB) Set(index *Index) error {
 		return fmt.Errorf("unable to insert index into cache: %v", err)
 	}
 
+	var evictedIDs []string
+	var evicted []*Index
+	lru := c.lru
+	tracked := lru != nil && lru.applies(index)
+	if tracked {
+		evictedIDs = lru.evictions(index)
+		for _, id := range evictedIDs {
+			raw, err := txn.First(tableNameIndexer, IndexNameID, id)
+			if err != nil {
+				return fmt.Errorf("unable to fetch index on cache eviction: %v", err)
+			}
+			if raw == nil {
+				continue
+			}
+			if err := txn.Delete(tableNameIndexer, raw); err != nil {
+				return fmt.Errorf("unable to delete index from cache: %v", err)
+			}
+			evicted = append(evicted, raw.(*Index))
+		}
+	}
+
 	txn.Commit()
 
+	// The memory limit only accounts for the index once it is in the cache
+	if tracked {
+		lru.set(index)
+		for _, id := range evictedIDs {
+			lru.remove(id)
+		}
+		if lru.limit.OnEvict != nil {
+			for _, index := range evicted {
+				lru.limit.OnEvict(index)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -312,6 +374,10 @@ func (c *CacheMemDB) Evict(indexName string, indexValues ...interface{}) error {
 
 	txn.Commit()
 
+	if c.lru != nil {
+		c.lru.remove(index.ID)
+	}
+
 	return nil
 }
 
@@ -324,5 +390,9 @@ func (c *CacheMemDB) Flush() error {
 
 	c.db.Store(newDB)
 
+	if c.lru != nil {
+		c.lru.reset()
+	}
+
 	return nil
 }
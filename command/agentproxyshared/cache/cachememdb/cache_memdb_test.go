@@ -484,3 +484,74 @@ func TestCacheMemDB_SetCapabilitiesIndex(t *testing.T) {
 	})
 	require.Nil(t, err)
 }
+
+// TestCacheMemDB_MemoryLimit tests that the least recently used indexes of
+// the type of the memory limit are evicted once the limit is exceeded.
+func TestCacheMemDB_MemoryLimit(t *testing.T) {
+	cache, err := New()
+	require.NoError(t, err)
+
+	var evicted []string
+	cache.SetMemoryLimit(&MemoryLimit{
+		Limit: 20,
+		Type:  "static-secret",
+		OnEvict: func(index *Index) {
+			evicted = append(evicted, index.ID)
+		},
+	})
+
+	set := func(id, indexType string, size int) {
+		t.Helper()
+		require.NoError(t, cache.Set(&Index{
+			ID:          id,
+			Namespace:   "root/",
+			RequestPath: id,
+			Type:        indexType,
+			Response:    make([]byte, size),
+		}))
+	}
+
+	set("a", "static-secret", 8)
+	set("b", "static-secret", 8)
+	// Other types of indexes are not limited
+	set("dynamic", "dynamic-secret", 100)
+	require.Equal(t, uint64(16), cache.MemoryUsage())
+
+	// a is used, so b is the least recently used index
+	_, err = cache.Get(IndexNameID, "a")
+	require.NoError(t, err)
+	set("c", "static-secret", 8)
+	require.Equal(t, []string{"b"}, evicted)
+	require.Equal(t, uint64(16), cache.MemoryUsage())
+
+	_, err = cache.Get(IndexNameID, "b")
+	require.ErrorIs(t, err, ErrCacheItemNotFound)
+	for _, id := range []string{"a", "c", "dynamic"} {
+		_, err = cache.Get(IndexNameID, id)
+		require.NoError(t, err)
+	}
+
+	// Updating an index accounts for its new size
+	set("c", "static-secret", 16)
+	require.Equal(t, []string{"b", "a"}, evicted)
+	require.Equal(t, uint64(16), cache.MemoryUsage())
+
+	// An index larger than the limit is kept, as it was just used
+	set("d", "static-secret", 30)
+	require.Equal(t, []string{"b", "a", "c"}, evicted)
+	require.Equal(t, uint64(30), cache.MemoryUsage())
+
+	// An index that fails to be inserted is not accounted for, and evicts
+	// nothing
+	require.Error(t, cache.Set(&Index{
+		Type:     "static-secret",
+		Response: make([]byte, 8),
+	}))
+	require.Equal(t, []string{"b", "a", "c"}, evicted)
+	require.Equal(t, uint64(30), cache.MemoryUsage())
+
+	require.NoError(t, cache.Evict(IndexNameID, "d"))
+	require.Equal(t, uint64(0), cache.MemoryUsage())
+	_, err = cache.Get(IndexNameID, "dynamic")
+	require.NoError(t, err)
+}
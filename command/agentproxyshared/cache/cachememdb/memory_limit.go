@@ -0,0 +1,135 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cachememdb
+
+import (
+	"container/list"
+	"sync"
+)
+
+// MemoryLimit bounds the memory used by the responses of the indexes of a
+// given type. Once the limit is exceeded, the least recently used indexes of
+// that type are evicted from the cache.
+type MemoryLimit struct {
+	// Limit is the maximum total size, in bytes, of the responses of the
+	// indexes of the given type.
+	Limit uint64
+
+	// Type is the type of the indexes the limit applies to.
+	Type string
+
+	// OnEvict, if set, is called with each index evicted to stay within the
+	// limit.
+	OnEvict func(*Index)
+}
+
+// lruTracker keeps track of the size and recency of use of the indexes a
+// memory limit applies to.
+type lruTracker struct {
+	limit *MemoryLimit
+
+	l        sync.Mutex
+	size     uint64
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+type lruEntry struct {
+	id   string
+	size uint64
+}
+
+func newLRUTracker(limit *MemoryLimit) *lruTracker {
+	return &lruTracker{
+		limit:    limit,
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+func (t *lruTracker) applies(index *Index) bool {
+	return index.Type == t.limit.Type
+}
+
+// evictions returns the identifiers of the least recently used indexes to
+// evict to stay within the limit once the given index is set with its current
+// size. The given index is never evicted. The tracker is not modified, so the
+// evictions can be applied once the cache is updated.
+func (t *lruTracker) evictions(index *Index) []string {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	size := t.size + uint64(len(index.Response))
+	if elem, ok := t.elements[index.ID]; ok {
+		size -= elem.Value.(*lruEntry).size
+	}
+
+	var evicted []string
+	for elem := t.order.Back(); elem != nil && size > t.limit.Limit; elem = elem.Prev() {
+		entry := elem.Value.(*lruEntry)
+		if entry.id == index.ID {
+			continue
+		}
+		size -= entry.size
+		evicted = append(evicted, entry.id)
+	}
+	return evicted
+}
+
+// set records the use of the given index with its current size.
+func (t *lruTracker) set(index *Index) {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	size := uint64(len(index.Response))
+	if elem, ok := t.elements[index.ID]; ok {
+		entry := elem.Value.(*lruEntry)
+		t.size -= entry.size
+		entry.size = size
+		t.order.MoveToFront(elem)
+	} else {
+		t.elements[index.ID] = t.order.PushFront(&lruEntry{id: index.ID, size: size})
+	}
+	t.size += size
+}
+
+// touch records the use of the index with the given identifier.
+func (t *lruTracker) touch(id string) {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if elem, ok := t.elements[id]; ok {
+		t.order.MoveToFront(elem)
+	}
+}
+
+// remove stops tracking the index with the given identifier.
+func (t *lruTracker) remove(id string) {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if elem, ok := t.elements[id]; ok {
+		t.size -= elem.Value.(*lruEntry).size
+		t.order.Remove(elem)
+		delete(t.elements, id)
+	}
+}
+
+// reset stops tracking all the indexes.
+func (t *lruTracker) reset() {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	t.size = 0
+	t.order.Init()
+	t.elements = make(map[string]*list.Element)
+}
+
+// usage returns the total size of the tracked indexes.
+func (t *lruTracker) usage() uint64 {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.size
+}
@@ -23,7 +23,7 @@ import (
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
-func ProxyHandler(ctx context.Context, logger hclog.Logger, proxier Proxier, inmemSink sink.Sink, proxyVaultToken bool) http.Handler {
+func ProxyHandler(ctx context.Context, logger hclog.Logger, proxier Proxier, inmemSink sink.Sink, proxyVaultToken bool, metricsSignifier string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		logger.Info("received request", "method", r.Method, "path", r.URL.Path)
 
@@ -62,9 +62,9 @@ func ProxyHandler(ctx context.Context, logger hclog.Logger, proxier Proxier, inm
 				copyHeader(w.Header(), resp.Response.Header)
 				w.WriteHeader(resp.Response.StatusCode)
 				io.Copy(w, resp.Response.Body)
-				metrics.IncrCounter([]string{"agent", "proxy", "client_error"}, 1)
+				metrics.IncrCounter([]string{metricsSignifier, "proxy", "client_error"}, 1)
 			} else {
-				metrics.IncrCounter([]string{"agent", "proxy", "error"}, 1)
+				metrics.IncrCounter([]string{metricsSignifier, "proxy", "error"}, 1)
 				logical.RespondError(w, http.StatusInternalServerError, fmt.Errorf("failed to get the response: %w", err))
 			}
 			return
@@ -78,12 +78,12 @@ func ProxyHandler(ctx context.Context, logger hclog.Logger, proxier Proxier, inm
 
 		defer resp.Response.Body.Close()
 
-		metrics.IncrCounter([]string{"agent", "proxy", "success"}, 1)
+		metrics.IncrCounter([]string{metricsSignifier, "proxy", "success"}, 1)
 		if resp.CacheMeta != nil {
 			if resp.CacheMeta.Hit {
-				metrics.IncrCounter([]string{"agent", "cache", "hit"}, 1)
+				metrics.IncrCounter([]string{metricsSignifier, "cache", "hit"}, 1)
 			} else {
-				metrics.IncrCounter([]string{"agent", "cache", "miss"}, 1)
+				metrics.IncrCounter([]string{metricsSignifier, "cache", "miss"}, 1)
 			}
 		}
 
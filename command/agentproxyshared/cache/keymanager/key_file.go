@@ -0,0 +1,109 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package keymanager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	wrapping "github.com/hashicorp/go-kms-wrapping/v2"
+	"github.com/hashicorp/go-kms-wrapping/wrappers/aead/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+var _ KeyProtector = (*KeyFileProtector)(nil)
+
+// KeyFileProtector encrypts keys with a key read from a local file.
+type KeyFileProtector struct {
+	wrapper *aead.Wrapper
+}
+
+// NewKeyFileProtector returns a new instance of the key file protector. The
+// file holds a base64 encoded 32 byte key; when it does not exist, a key is
+// generated and written to it, readable only by the current user.
+func NewKeyFileProtector(ctx context.Context, path string) (*KeyFileProtector, error) {
+	if path == "" {
+		return nil, errors.New("key file path not specified")
+	}
+
+	key, err := readKeyFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err = createKeyFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	wrapper := aead.NewWrapper()
+	if _, err := wrapper.SetConfig(ctx, wrapping.WithConfigMap(map[string]string{"key_id": KeyID})); err != nil {
+		return nil, err
+	}
+	if err := wrapper.SetAesGcmKeyBytes(key); err != nil {
+		return nil, err
+	}
+
+	return &KeyFileProtector{
+		wrapper: wrapper,
+	}, nil
+}
+
+func readKeyFile(path string) ([]byte, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(contents)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode key file %s: %w", path, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid key size in key file %s, should be 32, got %d", path, len(key))
+	}
+	return key, nil
+}
+
+func createKeyFile(path string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create key file: %w", err)
+	}
+	if _, err := f.WriteString(base64.StdEncoding.EncodeToString(key)); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("unable to write key file %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("unable to close key file %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// EncryptKey encrypts the given key with the key of the key file.
+func (p *KeyFileProtector) EncryptKey(ctx context.Context, key []byte) ([]byte, error) {
+	blob, err := p.wrapper.Encrypt(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(blob)
+}
+
+// DecryptKey decrypts a key encrypted by EncryptKey.
+func (p *KeyFileProtector) DecryptKey(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	var blob wrapping.BlobInfo
+	if err := proto.Unmarshal(ciphertext, &blob); err != nil {
+		return nil, err
+	}
+	return p.wrapper.Decrypt(ctx, &blob)
+}
@@ -0,0 +1,73 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package keymanager
+
+import (
+	"context"
+	"fmt"
+
+	wrapping "github.com/hashicorp/go-kms-wrapping/v2"
+)
+
+var _ KeyManager = (*ProtectedKeyManager)(nil)
+
+// KeyProtector encrypts the encryption key of a ProtectedKeyManager, so that
+// the key can be stored alongside the data it encrypts.
+type KeyProtector interface {
+	// EncryptKey returns the encrypted form of the given key.
+	EncryptKey(ctx context.Context, key []byte) ([]byte, error)
+	// DecryptKey returns the key encrypted by EncryptKey.
+	DecryptKey(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// ProtectedKeyManager is a key manager whose retrieval token is the
+// encryption key encrypted by a KeyProtector, so that the key cannot be
+// recovered from the retrieval token alone.
+type ProtectedKeyManager struct {
+	passthrough *PassthroughKeyManager
+	protector   KeyProtector
+}
+
+// NewProtectedKeyManager returns a new instance of the protected key manager.
+// If a retrieval token is provided, the encryption key is decrypted from it,
+// otherwise one will be generated.
+func NewProtectedKeyManager(ctx context.Context, protector KeyProtector, retrievalToken []byte) (*ProtectedKeyManager, error) {
+	if protector == nil {
+		return nil, fmt.Errorf("nil key protector")
+	}
+
+	var key []byte
+	if len(retrievalToken) > 0 {
+		var err error
+		key, err = protector.DecryptKey(ctx, retrievalToken)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt the encryption key: %w", err)
+		}
+	}
+
+	passthrough, err := NewPassthroughKeyManager(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProtectedKeyManager{
+		passthrough: passthrough,
+		protector:   protector,
+	}, nil
+}
+
+// Wrapper returns the manager's wrapper for key operations.
+func (m *ProtectedKeyManager) Wrapper() wrapping.Wrapper {
+	return m.passthrough.Wrapper()
+}
+
+// RetrievalToken returns the encryption key, encrypted by the key protector.
+func (m *ProtectedKeyManager) RetrievalToken(ctx context.Context) ([]byte, error) {
+	key, err := m.passthrough.RetrievalToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.protector.EncryptKey(ctx, key)
+}
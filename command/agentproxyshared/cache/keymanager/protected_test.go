@@ -0,0 +1,59 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package keymanager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyManager_ProtectedKeyManager_KeyFile(t *testing.T) {
+	ctx := context.Background()
+	keyFile := filepath.Join(t.TempDir(), "cache.key")
+
+	// The key file is created on first use
+	protector, err := NewKeyFileProtector(ctx, keyFile)
+	require.NoError(t, err)
+	fi, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	m, err := NewProtectedKeyManager(ctx, protector, nil)
+	require.NoError(t, err)
+	require.NotNil(t, m.Wrapper())
+
+	key, err := m.passthrough.RetrievalToken(ctx)
+	require.NoError(t, err)
+	token, err := m.RetrievalToken(ctx)
+	require.NoError(t, err)
+	require.NotContains(t, string(token), string(key))
+
+	// The same key is recovered from the retrieval token with the existing
+	// key file
+	protector, err = NewKeyFileProtector(ctx, keyFile)
+	require.NoError(t, err)
+	m, err = NewProtectedKeyManager(ctx, protector, token)
+	require.NoError(t, err)
+	restoredKey, err := m.passthrough.RetrievalToken(ctx)
+	require.NoError(t, err)
+	require.Equal(t, key, restoredKey)
+
+	// but not with another key file
+	protector, err = NewKeyFileProtector(ctx, filepath.Join(t.TempDir(), "other.key"))
+	require.NoError(t, err)
+	_, err = NewProtectedKeyManager(ctx, protector, token)
+	require.Error(t, err)
+}
+
+func TestKeyManager_KeyFileProtector_InvalidKeyFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "cache.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("Zm9vYmFy"), 0o600))
+
+	_, err := NewKeyFileProtector(context.Background(), keyFile)
+	require.ErrorContains(t, err, "invalid key size")
+}
@@ -0,0 +1,80 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package keymanager
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+var _ KeyProtector = (*TransitProtector)(nil)
+
+// TransitProtector encrypts keys with a key of a Vault transit secrets engine.
+type TransitProtector struct {
+	client    *api.Client
+	mountPath string
+	keyName   string
+}
+
+// NewTransitProtector returns a new instance of the transit protector, using
+// the given client to encrypt and decrypt keys with the named transit key.
+func NewTransitProtector(client *api.Client, mountPath, keyName string) (*TransitProtector, error) {
+	if client == nil {
+		return nil, errors.New("nil API client")
+	}
+	if keyName == "" {
+		return nil, errors.New("transit key name not specified")
+	}
+	mountPath = strings.Trim(mountPath, "/")
+	if mountPath == "" {
+		mountPath = "transit"
+	}
+
+	return &TransitProtector{
+		client:    client,
+		mountPath: mountPath,
+		keyName:   keyName,
+	}, nil
+}
+
+// EncryptKey encrypts the given key with the transit key.
+func (p *TransitProtector) EncryptKey(ctx context.Context, key []byte) ([]byte, error) {
+	secret, err := p.client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/encrypt/%s", p.mountPath, p.keyName), map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("empty response from transit encrypt")
+	}
+	ciphertext, ok := secret.Data["ciphertext"].(string)
+	if !ok || ciphertext == "" {
+		return nil, errors.New("no ciphertext in the response from transit encrypt")
+	}
+	return []byte(ciphertext), nil
+}
+
+// DecryptKey decrypts a key encrypted by EncryptKey.
+func (p *TransitProtector) DecryptKey(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	secret, err := p.client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/decrypt/%s", p.mountPath, p.keyName), map[string]interface{}{
+		"ciphertext": string(ciphertext),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("empty response from transit decrypt")
+	}
+	plaintext, ok := secret.Data["plaintext"].(string)
+	if !ok {
+		return nil, errors.New("no plaintext in the response from transit decrypt")
+	}
+	return base64.StdEncoding.DecodeString(plaintext)
+}
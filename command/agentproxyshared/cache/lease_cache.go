@@ -14,6 +14,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -21,6 +22,7 @@ import (
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/go-secure-stdlib/base62"
+	"github.com/hashicorp/go-secure-stdlib/strutil"
 	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/command/agentproxyshared/cache/cacheboltdb"
 	"github.com/hashicorp/vault/command/agentproxyshared/cache/cachememdb"
@@ -106,6 +108,10 @@ type LeaseCache struct {
 	// cache static secrets, as well as dynamic secrets.
 	cacheStaticSecrets bool
 
+	// staticSecretMountTypes is the set of the types of the mounts whose
+	// static secrets are cached
+	staticSecretMountTypes map[string]struct{}
+
 	// cacheDynamicSecrets is used to determine if the cache should
 	// cache dynamic secrets
 	cacheDynamicSecrets bool
@@ -113,6 +119,17 @@ type LeaseCache struct {
 	// capabilityManager is used when static secrets are enabled to
 	// manage the capabilities of cached tokens.
 	capabilityManager *StaticSecretCapabilityManager
+
+	// failover is used to retry renewals against another Vault server
+	failover FailoverFunc
+
+	metricsSignifier string
+
+	// hits, misses and evictions count the requests answered from the
+	// cache, the requests forwarded to Vault and the evicted cache entries
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
 }
 
 // LeaseCacheConfig is the configuration for initializing a new
@@ -126,8 +143,31 @@ type LeaseCacheConfig struct {
 	Storage             *cacheboltdb.BoltStorage
 	CacheStaticSecrets  bool
 	CacheDynamicSecrets bool
+	Failover            FailoverFunc
+
+	// StaticSecretMountTypes are the types of the mounts whose static
+	// secrets are cached. Defaults to DefaultStaticSecretMountTypes.
+	StaticSecretMountTypes []string
+
+	// StaticSecretMemoryLimit is the maximum total size, in bytes, of the
+	// cached static secrets. The least recently used static secrets are
+	// evicted once it is exceeded. Zero means no limit.
+	StaticSecretMemoryLimit uint64
+
+	// MetricsSignifier is the first argument given to the metrics, signifying
+	// the name of the application, e.g. "agent" or "proxy".
+	MetricsSignifier string
 }
 
+// DefaultStaticSecretMountTypes are the types of the mounts whose static
+// secrets are cached by default.
+var DefaultStaticSecretMountTypes = []string{"kv"}
+
+// StaticSecretMountTypes are the types of the mounts whose static secrets
+// can be cached. Only the responses without a lease are cached as static
+// secrets.
+var StaticSecretMountTypes = []string{"kv", "database", "pki", "transit"}
+
 type inflightRequest struct {
 	// ch is closed by the request that ends up processing the set of
 	// parallel request
@@ -171,7 +211,19 @@ func NewLeaseCache(conf *LeaseCacheConfig) (*LeaseCache, error) {
 	// Create a base context for the lease cache layer
 	baseCtxInfo := cachememdb.NewContextInfo(conf.BaseContext)
 
-	return &LeaseCache{
+	mountTypes := conf.StaticSecretMountTypes
+	if len(mountTypes) == 0 {
+		mountTypes = DefaultStaticSecretMountTypes
+	}
+	staticSecretMountTypes := make(map[string]struct{}, len(mountTypes))
+	for _, mountType := range mountTypes {
+		if !strutil.StrListContains(StaticSecretMountTypes, mountType) {
+			return nil, fmt.Errorf("unsupported static secret mount type %q", mountType)
+		}
+		staticSecretMountTypes[mountType] = struct{}{}
+	}
+
+	c := &LeaseCache{
 		client:              conf.Client,
 		proxier:             conf.Proxier,
 		logger:              conf.Logger,
@@ -184,7 +236,52 @@ func NewLeaseCache(conf *LeaseCacheConfig) (*LeaseCache, error) {
 		ps:                  conf.Storage,
 		cacheStaticSecrets:  conf.CacheStaticSecrets,
 		cacheDynamicSecrets: conf.CacheDynamicSecrets,
-	}, nil
+		failover:            conf.Failover,
+		metricsSignifier:    conf.MetricsSignifier,
+
+		staticSecretMountTypes: staticSecretMountTypes,
+	}
+
+	db.SetMemoryLimit(&cachememdb.MemoryLimit{
+		Limit:   conf.StaticSecretMemoryLimit,
+		Type:    cacheboltdb.StaticSecretType,
+		OnEvict: c.evictStaticSecretOverMemoryLimit,
+	})
+
+	return c, nil
+}
+
+// evictStaticSecretOverMemoryLimit removes a static secret evicted from the
+// cachememdb to stay within the memory limit from the persistent cache.
+func (c *LeaseCache) evictStaticSecretOverMemoryLimit(index *cachememdb.Index) {
+	c.logger.Debug("evicted least recently used static secret from the cache to stay within the memory limit", "path", index.RequestPath)
+	c.recordEviction()
+
+	if c.ps != nil {
+		if err := c.ps.Delete(index.ID, index.Type); err != nil {
+			c.logger.Error("failed to delete evicted static secret from persistent storage", "path", index.RequestPath, "error", err)
+		}
+	}
+}
+
+// StaticSecretMountTypes returns the types of the mounts whose static
+// secrets are cached.
+func (c *LeaseCache) StaticSecretMountTypes() []string {
+	mountTypes := make([]string, 0, len(c.staticSecretMountTypes))
+	for mountType := range c.staticSecretMountTypes {
+		mountTypes = append(mountTypes, mountType)
+	}
+	sort.Strings(mountTypes)
+	return mountTypes
+}
+
+// isStaticSecret returns whether the given secret, read from Vault, is
+// cached as a static secret.
+func (c *LeaseCache) isStaticSecret(secret *api.Secret) bool {
+	if _, ok := c.staticSecretMountTypes[secret.MountType]; !ok {
+		return false
+	}
+	return secret.LeaseID == ""
 }
 
 // SetCapabilityManager is a setter for CapabilityManager. If set, will manage capabilities
@@ -401,6 +498,7 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 	}
 	if cachedResp != nil {
 		c.logger.Debug("returning cached dynamic secret response", "path", req.Request.URL.Path)
+		c.hits.Inc()
 		return cachedResp, nil
 	}
 
@@ -412,11 +510,13 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 		}
 		if cachedResp != nil {
 			c.logger.Debug("returning cached static secret response", "id", staticSecretCacheId, "path", req.Request.URL.Path)
+			c.hits.Inc()
 			return cachedResp, nil
 		}
 	}
 
 	c.logger.Debug("forwarding request from cache", "method", req.Request.Method, "path", req.Request.URL.Path)
+	c.misses.Inc()
 
 	// Pass the request down and get a response
 	resp, err := c.proxier.Send(ctx, req)
@@ -468,10 +568,10 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 		return resp, nil
 	}
 
-	// There shouldn't be a situation where secret.MountType == "kv" and
+	// There shouldn't be a situation where the secret is static and
 	// staticSecretCacheId == "", but just in case.
 	// We restrict this to GETs as those are all we want to cache.
-	if c.cacheStaticSecrets && secret.MountType == "kv" &&
+	if c.cacheStaticSecrets && c.isStaticSecret(secret) &&
 		staticSecretCacheId != "" && req.Request.Method == http.MethodGet {
 		index.Type = cacheboltdb.StaticSecretType
 		index.ID = staticSecretCacheId
@@ -771,9 +871,10 @@ func (c *LeaseCache) startRenewing(ctx context.Context, index *cachememdb.Index,
 	headers.Set("User-Agent", c.userAgentToUse)
 	client.SetHeaders(headers)
 
-	watcher, err := client.NewLifetimeWatcher(&api.LifetimeWatcherInput{
+	watcherInput := &api.LifetimeWatcherInput{
 		Secret: secret,
-	})
+	}
+	watcher, err := client.NewLifetimeWatcher(watcherInput)
 	if err != nil {
 		c.logger.Error("failed to create secret lifetime watcher", "error", err)
 		return
@@ -781,7 +882,9 @@ func (c *LeaseCache) startRenewing(ctx context.Context, index *cachememdb.Index,
 
 	c.logger.Debug("initiating renewal", "method", req.Request.Method, "path", req.Request.URL.Path)
 	go watcher.Start()
-	defer watcher.Stop()
+	defer func() {
+		watcher.Stop()
+	}()
 
 	for {
 		select {
@@ -793,6 +896,25 @@ func (c *LeaseCache) startRenewing(ctx context.Context, index *cachememdb.Index,
 			return
 		case err := <-watcher.DoneCh():
 			// This case covers renewal completion and renewal errors
+			if err != nil && c.failover != nil && ctx.Err() == nil {
+				// Keep the secret cached if it can be renewed by another
+				// Vault server
+				if addr, ok := c.failover(ctx, client.Address()); ok {
+					c.logger.Warn("failed to renew secret, retrying against another Vault server", "address", addr, "error", err)
+					watcher.Stop()
+					if err := client.SetAddress(addr); err != nil {
+						c.logger.Error("failed to set the address of the lifetime watcher", "error", err)
+						return
+					}
+					watcher, err = client.NewLifetimeWatcher(watcherInput)
+					if err != nil {
+						c.logger.Error("failed to create secret lifetime watcher", "error", err)
+						return
+					}
+					go watcher.Start()
+					continue
+				}
+			}
 			if err != nil {
 				c.logger.Error("failed to renew secret", "error", err)
 				return
@@ -1008,6 +1130,7 @@ func (c *LeaseCache) handleCacheClear(ctx context.Context, in *cacheClearInput)
 				if err != nil {
 					return err
 				}
+				c.recordEviction()
 			} else {
 				if index.RenewCtxInfo != nil {
 					if index.RenewCtxInfo.CancelFunc != nil {
@@ -1345,6 +1468,7 @@ func (c *LeaseCache) Evict(index *cachememdb.Index) error {
 	if err := c.db.Evict(cachememdb.IndexNameID, index.ID); err != nil {
 		return err
 	}
+	c.recordEviction()
 
 	if c.ps != nil {
 		if err := c.ps.Delete(index.ID, index.Type); err != nil {
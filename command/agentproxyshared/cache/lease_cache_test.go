@@ -482,6 +482,30 @@ func TestLeaseCache_SendCacheable(t *testing.T) {
 	}
 }
 
+// TestLeaseCache_Stats tests that the hits and misses of the cache are counted
+func TestLeaseCache_Stats(t *testing.T) {
+	responses := []*SendResponse{
+		newTestSendResponse(http.StatusCreated, `{"auth": {"client_token": "testtoken", "renewable": true}}`),
+	}
+
+	lc := testNewLeaseCache(t, responses)
+	require.NoError(t, lc.RegisterAutoAuthToken("autoauthtoken"))
+
+	for i := 0; i < 2; i++ {
+		_, err := lc.Send(context.Background(), &SendRequest{
+			Token:   "autoauthtoken",
+			Request: httptest.NewRequest("GET", "http://example.com/v1/sample/api", strings.NewReader(`{"value": "input"}`)),
+		})
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, &CacheStats{Hits: 1, Misses: 1}, lc.Stats())
+
+	status, healthy := lc.HealthStatus()
+	require.True(t, healthy)
+	require.Equal(t, lc.Stats(), status)
+}
+
 // TestLeaseCache_StoreCacheableStaticSecret tests that cacheStaticSecret works
 // as expected, creating the two expected cache entries, and also ensures
 // that we can evict the cache entry with the cache clear API afterwards.
@@ -1674,3 +1698,104 @@ func TestLeaseCacheRestore_expired(t *testing.T) {
 	assert.Equal(t, "autoauthtoken", afterDB[0].Token)
 	assert.Equal(t, cacheboltdb.TokenType, afterDB[0].Type)
 }
+
+// TestLeaseCache_StaticSecretMountTypes tests that only the responses without
+// a lease of the configured mount types are cached as static secrets.
+func TestLeaseCache_StaticSecretMountTypes(t *testing.T) {
+	client, err := api.NewClient(api.DefaultConfig())
+	require.NoError(t, err)
+
+	_, err = NewLeaseCache(&LeaseCacheConfig{
+		Client:                 client,
+		BaseContext:            context.Background(),
+		Proxier:                NewMockProxier(nil),
+		Logger:                 logging.NewVaultLogger(hclog.Trace).Named("cache.leasecache"),
+		CacheStaticSecrets:     true,
+		UserAgentToUse:         "test",
+		StaticSecretMountTypes: []string{"kv", "cubbyhole"},
+	})
+	require.Error(t, err)
+
+	lc, err := NewLeaseCache(&LeaseCacheConfig{
+		Client:      client,
+		BaseContext: context.Background(),
+		Proxier: NewMockProxier([]*SendResponse{
+			newTestSendResponse(http.StatusOK, `{"data": {"password": "pass"}, "mount_type": "database"}`),
+			newTestSendResponse(http.StatusOK, `{"data": {"password": "pass"}, "mount_type": "database", "lease_id": "database/creds/app/abc", "lease_duration": 60}`),
+			newTestSendResponse(http.StatusOK, `{"data": {"certificate": "cert"}, "mount_type": "pki"}`),
+		}),
+		Logger:                 logging.NewVaultLogger(hclog.Trace).Named("cache.leasecache"),
+		CacheStaticSecrets:     true,
+		UserAgentToUse:         "test",
+		StaticSecretMountTypes: []string{"kv", "database"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"database", "kv"}, lc.StaticSecretMountTypes())
+
+	for _, tc := range []struct {
+		path   string
+		cached bool
+	}{
+		{"/v1/database/static-creds/app", true},
+		{"/v1/database/creds/app", false},
+		{"/v1/pki/cert/ca", false},
+	} {
+		req := &SendRequest{
+			Token:   "token",
+			Request: httptest.NewRequest(http.MethodGet, tc.path, nil),
+		}
+		_, err := lc.Send(context.Background(), req)
+		require.NoError(t, err)
+
+		_, err = lc.db.Get(cachememdb.IndexNameID, computeStaticSecretCacheIndex(req))
+		if tc.cached {
+			require.NoError(t, err, tc.path)
+		} else {
+			require.ErrorIs(t, err, cachememdb.ErrCacheItemNotFound, tc.path)
+		}
+	}
+}
+
+// TestLeaseCache_StaticSecretMemoryLimit tests that the least recently used
+// static secrets are evicted once the memory limit is exceeded.
+func TestLeaseCache_StaticSecretMemoryLimit(t *testing.T) {
+	client, err := api.NewClient(api.DefaultConfig())
+	require.NoError(t, err)
+
+	response := `{"data": {"foo": "bar"}, "mount_type": "kv"}`
+	lc, err := NewLeaseCache(&LeaseCacheConfig{
+		Client:      client,
+		BaseContext: context.Background(),
+		Proxier: NewMockProxier([]*SendResponse{
+			newTestSendResponse(http.StatusOK, response),
+			newTestSendResponse(http.StatusOK, response),
+		}),
+		Logger:             logging.NewVaultLogger(hclog.Trace).Named("cache.leasecache"),
+		CacheStaticSecrets: true,
+		UserAgentToUse:     "test",
+		// Only one serialized response fits
+		StaticSecretMemoryLimit: 300,
+	})
+	require.NoError(t, err)
+
+	var requests []*SendRequest
+	for _, path := range []string{"/v1/secret/foo", "/v1/secret/bar"} {
+		req := &SendRequest{
+			Token:   "token",
+			Request: httptest.NewRequest(http.MethodGet, path, nil),
+		}
+		_, err := lc.Send(context.Background(), req)
+		require.NoError(t, err)
+		requests = append(requests, req)
+	}
+
+	_, err = lc.db.Get(cachememdb.IndexNameID, computeStaticSecretCacheIndex(requests[0]))
+	require.ErrorIs(t, err, cachememdb.ErrCacheItemNotFound)
+	_, err = lc.db.Get(cachememdb.IndexNameID, computeStaticSecretCacheIndex(requests[1]))
+	require.NoError(t, err)
+
+	stats := lc.Stats()
+	require.Equal(t, uint64(1), stats.Evictions)
+	require.NotZero(t, stats.StaticSecretMemory)
+	require.LessOrEqual(t, stats.StaticSecretMemory, uint64(300))
+}
@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-secure-stdlib/parseutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault"
+)
+
+// RequestPolicy is a local ACL, written in the syntax of Vault policies,
+// that the requests must satisfy to be proxied. It only checks the paths and
+// capabilities of the requests: the parameter constraints, the wrapping TTL
+// constraints, and the sudo capability of the policy are not enforced.
+type RequestPolicy struct {
+	acl *vault.ACL
+
+	// prependNamespace is the namespace the API proxy puts in front of the
+	// namespace of the requests before forwarding them, if any
+	prependNamespace string
+}
+
+// NewRequestPolicy parses the given policy rules. prependNamespace is the
+// configured namespace of the API proxy when prepend_configured_namespace is
+// set, so that the requests are checked against the paths Vault serves.
+func NewRequestPolicy(rules string, prependNamespace string) (*RequestPolicy, error) {
+	policy, err := vault.ParseACLPolicy(namespace.RootNamespace, rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse request policy: %w", err)
+	}
+
+	acl, err := vault.NewACL(namespace.RootContext(context.Background()), []*vault.Policy{policy})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request policy: %w", err)
+	}
+
+	return &RequestPolicy{
+		acl:              acl,
+		prependNamespace: namespace.Canonicalize(prependNamespace),
+	}, nil
+}
+
+// Allowed returns whether the given request is allowed by the policy.
+func (p *RequestPolicy) Allowed(r *http.Request) bool {
+	path, ok := strings.CutPrefix(r.URL.Path, "/v1/")
+	if !ok {
+		return false
+	}
+
+	// The paths of the policy are relative to the root namespace, the
+	// namespace of the request is resolved as the API proxy forwards it
+	ns := namespace.Canonicalize(r.Header.Get(consts.NamespaceHeaderName))
+	if p.prependNamespace != "" {
+		ns = namespace.Canonicalize(p.prependNamespace + ns)
+	}
+	path = ns + path
+
+	// Only the capabilities are checked, as the parameters of the request
+	// are not parsed
+	capabilities := p.acl.AllowOperation(namespace.RootContext(r.Context()), &logical.Request{
+		Path: path,
+	}, true).CapabilitiesBitmap
+	has := func(capability uint32) bool {
+		return capabilities&capability > 0
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if list, _ := parseutil.ParseBool(r.URL.Query().Get("list")); list {
+			return has(vault.ListCapabilityInt)
+		}
+		return has(vault.ReadCapabilityInt)
+	case "LIST":
+		return has(vault.ListCapabilityInt)
+	case http.MethodPost, http.MethodPut:
+		// Whether Vault handles the request as a creation or an update
+		// depends on the existence of the path, which is unknown here
+		return has(vault.CreateCapabilityInt) || has(vault.UpdateCapabilityInt)
+	case http.MethodPatch:
+		return has(vault.PatchCapabilityInt)
+	case http.MethodDelete:
+		return has(vault.DeleteCapabilityInt)
+	default:
+		return false
+	}
+}
+
+// RequestPolicyHandler only passes the requests allowed by the given policy
+// to the next handler. The denied requests are logged, counted, and
+// responded to with a 403 status code.
+func RequestPolicyHandler(logger hclog.Logger, policy *RequestPolicy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !policy.Allowed(r) {
+			logger.Warn("request denied by request policy", "method", r.Method, "path", r.URL.Path, "namespace", r.Header.Get(consts.NamespaceHeaderName))
+			metrics.IncrCounter([]string{"proxy", "request_policy", "denied"}, 1)
+			logical.RespondError(w, http.StatusForbidden, logical.ErrPermissionDenied)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
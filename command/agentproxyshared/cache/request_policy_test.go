@@ -0,0 +1,150 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/stretchr/testify/require"
+)
+
+const testRequestPolicy = `
+path "secret/data/app/*" {
+  capabilities = ["read", "list"]
+}
+
+path "secret/data/app/admin" {
+  capabilities = ["deny"]
+}
+
+path "database/creds/+" {
+  capabilities = ["read"]
+}
+
+path "transit/encrypt/app" {
+  capabilities = ["update"]
+}
+
+path "ns1/kv/*" {
+  capabilities = ["create", "delete"]
+}
+`
+
+// TestRequestPolicy_Allowed tests that requests are checked against the paths
+// and capabilities of the request policy.
+func TestRequestPolicy_Allowed(t *testing.T) {
+	policy, err := NewRequestPolicy(testRequestPolicy, "")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		method    string
+		path      string
+		namespace string
+		allowed   bool
+	}{
+		{"read", http.MethodGet, "/v1/secret/data/app/config", "", true},
+		{"list", "LIST", "/v1/secret/data/app/", "", true},
+		{"list query", http.MethodGet, "/v1/secret/data/app/?list=true", "", true},
+		{"write", http.MethodPost, "/v1/secret/data/app/config", "", false},
+		{"deny", http.MethodGet, "/v1/secret/data/app/admin", "", false},
+		{"segment wildcard", http.MethodGet, "/v1/database/creds/app", "", true},
+		{"segment wildcard nested", http.MethodGet, "/v1/database/creds/app/other", "", false},
+		{"update", http.MethodPost, "/v1/transit/encrypt/app", "", true},
+		{"update put", http.MethodPut, "/v1/transit/encrypt/app", "", true},
+		{"create", http.MethodPost, "/v1/ns1/kv/foo", "", true},
+		{"namespace header", http.MethodPost, "/v1/kv/foo", "ns1", true},
+		{"namespace header delete", http.MethodDelete, "/v1/kv/foo", "ns1/", true},
+		{"namespace header read", http.MethodGet, "/v1/kv/foo", "ns1", false},
+		{"other namespace", http.MethodPost, "/v1/kv/foo", "ns2", false},
+		{"unlisted path", http.MethodGet, "/v1/sys/mounts", "", false},
+		{"not an API path", http.MethodGet, "/ui/", "", false},
+		{"unknown method", http.MethodOptions, "/v1/secret/data/app/config", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.namespace != "" {
+				r.Header.Set(consts.NamespaceHeaderName, tc.namespace)
+			}
+			require.Equal(t, tc.allowed, policy.Allowed(r))
+		})
+	}
+}
+
+// TestRequestPolicy_PrependNamespace tests that the requests are checked
+// against the paths they are forwarded to when the API proxy prepends its
+// configured namespace.
+func TestRequestPolicy_PrependNamespace(t *testing.T) {
+	policy, err := NewRequestPolicy(`
+path "secret/*" {
+  capabilities = ["read"]
+}
+
+path "ns1/*" {
+  capabilities = ["deny"]
+}
+
+path "ns1/ns2/kv/*" {
+  capabilities = ["read"]
+}
+`, "ns1")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		path      string
+		namespace string
+		allowed   bool
+	}{
+		{"prepended namespace", "/v1/secret/foo", "", false},
+		{"prepended child namespace", "/v1/kv/foo", "ns2", true},
+		{"prepended child namespace denied", "/v1/secret/foo", "ns2/", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.namespace != "" {
+				r.Header.Set(consts.NamespaceHeaderName, tc.namespace)
+			}
+			require.Equal(t, tc.allowed, policy.Allowed(r))
+		})
+	}
+}
+
+// TestRequestPolicyHandler tests that the requests denied by the request
+// policy are not passed to the next handler.
+func TestRequestPolicyHandler(t *testing.T) {
+	policy, err := NewRequestPolicy(testRequestPolicy, "")
+	require.NoError(t, err)
+
+	var proxied int
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied++
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := RequestPolicyHandler(hclog.NewNullLogger(), policy, next)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/secret/data/app/config", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, 1, proxied)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/secret/data/app/config", nil))
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "permission denied")
+	require.Equal(t, 1, proxied)
+}
+
+func TestNewRequestPolicy_Invalid(t *testing.T) {
+	_, err := NewRequestPolicy(`path "secret/*" { capabilities = ["fly"] }`, "")
+	require.Error(t, err)
+}
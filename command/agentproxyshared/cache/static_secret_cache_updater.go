@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
@@ -19,6 +21,7 @@ import (
 	"github.com/hashicorp/vault/command/agentproxyshared/cache/cacheboltdb"
 	"github.com/hashicorp/vault/command/agentproxyshared/cache/cachememdb"
 	"github.com/hashicorp/vault/command/agentproxyshared/sink"
+	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/useragent"
 	"golang.org/x/exp/maps"
 	"nhooyr.io/websocket"
@@ -54,13 +57,26 @@ import (
 //  "time": "2023-09-12T15:19:49.394915-07:00"
 //}
 
+// staticSecretEventTypes are the event types subscribed to for the static
+// secrets of each type of mount.
+var staticSecretEventTypes = map[string]string{
+	"kv":       "kv*",
+	"database": "database/*",
+	"pki":      "pki/*",
+	"transit":  "transit/*",
+}
+
 // StaticSecretCacheUpdater is a struct that utilizes
 // the event system to keep the static secret cache up to date.
 type StaticSecretCacheUpdater struct {
-	client     *api.Client
-	leaseCache *LeaseCache
-	logger     hclog.Logger
-	tokenSink  sink.Sink
+	client       *api.Client
+	leaseCache   *LeaseCache
+	logger       hclog.Logger
+	tokenSink    sink.Sink
+	prewarmPaths []string
+
+	statusLock sync.RWMutex
+	status     updaterStatus
 }
 
 // StaticSecretCacheUpdaterConfig is the configuration for initializing a new
@@ -73,6 +89,10 @@ type StaticSecretCacheUpdaterConfig struct {
 	// token from auto-auth in it, to be used in event system
 	// connections.
 	TokenSink sink.Sink
+	// PrewarmPaths are the paths of the static secrets to cache at startup,
+	// with the auto-auth token. Paths ending with a slash are prefixes, whose
+	// secrets are listed recursively.
+	PrewarmPaths []string
 }
 
 // NewStaticSecretCacheUpdater creates a new instance of a StaticSecretCacheUpdater.
@@ -98,10 +118,11 @@ func NewStaticSecretCacheUpdater(conf *StaticSecretCacheUpdaterConfig) (*StaticS
 	}
 
 	return &StaticSecretCacheUpdater{
-		client:     conf.Client,
-		leaseCache: conf.LeaseCache,
-		logger:     conf.Logger,
-		tokenSink:  conf.TokenSink,
+		client:       conf.Client,
+		leaseCache:   conf.LeaseCache,
+		logger:       conf.Logger,
+		tokenSink:    conf.TokenSink,
+		prewarmPaths: conf.PrewarmPaths,
 	}, nil
 }
 
@@ -113,67 +134,168 @@ func NewStaticSecretCacheUpdater(conf *StaticSecretCacheUpdaterConfig) (*StaticS
 func (updater *StaticSecretCacheUpdater) streamStaticSecretEvents(ctx context.Context) error {
 	// First, ensure our token is up-to-date:
 	updater.client.SetToken(updater.tokenSink.(sink.SinkReader).Token())
-	conn, err := updater.openWebSocketConnection(ctx)
-	if err != nil {
-		return err
+
+	// We open a connection per event type, so that the auto-auth token only
+	// needs to be able to subscribe to the events of the mount types whose
+	// static secrets are cached.
+	var conns []*websocket.Conn
+	defer func() {
+		for _, conn := range conns {
+			conn.Close(websocket.StatusNormalClosure, "")
+		}
+	}()
+	for _, mountType := range updater.leaseCache.StaticSecretMountTypes() {
+		conn, err := updater.openWebSocketConnection(ctx, staticSecretEventTypes[mountType])
+		if err != nil {
+			return err
+		}
+		conns = append(conns, conn)
 	}
-	defer conn.Close(websocket.StatusNormalClosure, "")
 
-	err = updater.preEventStreamUpdate(ctx)
+	err := updater.preEventStreamUpdate(ctx)
 	if err != nil {
 		return fmt.Errorf("error when performing pre-event stream secret update: %w", err)
 	}
 
+	updater.setConnected(true, nil)
+	defer updater.setConnected(false, nil)
+
+	readCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	messages := make(chan []byte)
+	readErrs := make(chan error, len(conns))
+	for _, conn := range conns {
+		go func(conn *websocket.Conn) {
+			for {
+				_, message, err := conn.Read(readCtx)
+				if err != nil {
+					readErrs <- err
+					return
+				}
+				select {
+				case messages <- message:
+				case <-readCtx.Done():
+					return
+				}
+			}
+		}(conn)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
-		default:
-			_, message, err := conn.Read(ctx)
-			if err != nil {
-				// The caller of this function should make the decision on if to retry. If it does, then
-				// the websocket connection will be retried, and we will check for missed events.
-				return fmt.Errorf("error when attempting to read from event stream, reopening websocket: %w", err)
+		case err := <-readErrs:
+			if ctx.Err() != nil {
+				return nil
 			}
+			// The caller of this function should make the decision on if to retry. If it does, then
+			// the websocket connection will be retried, and we will check for missed events.
+			return fmt.Errorf("error when attempting to read from event stream, reopening websocket: %w", err)
+		case message := <-messages:
 			updater.logger.Trace("received event", "message", string(message))
-			messageMap := make(map[string]interface{})
-			err = json.Unmarshal(message, &messageMap)
+			paths, sent, err := staticSecretEventPaths(message)
 			if err != nil {
-				return fmt.Errorf("error when unmarshaling event, message: %s\nerror: %w", string(message), err)
-			}
-			data, ok := messageMap["data"].(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("unexpected event format when decoding 'data' element, message: %s\nerror: %w", string(message), err)
-			}
-			event, ok := data["event"].(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("unexpected event format when decoding 'event' element, message: %s\nerror: %w", string(message), err)
+				return err
 			}
-			metadata, ok := event["metadata"].(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("unexpected event format when decoding 'metadata' element, message: %s\nerror: %w", string(message), err)
+			if len(paths) == 0 {
+				// This is an event we're not interested in, ignore it and
+				// carry on.
+				continue
 			}
-			modified, ok := metadata["modified"].(string)
-			if ok && modified == "true" {
-				path, ok := metadata["path"].(string)
-				if !ok {
-					return fmt.Errorf("unexpected event format when decoding 'path' element, message: %s\nerror: %w", string(message), err)
+			for _, path := range paths {
+				if strings.HasSuffix(path, "/") {
+					err = updater.updateStaticSecretsWithPrefix(ctx, path)
+				} else {
+					err = updater.updateStaticSecret(ctx, path)
 				}
-				err := updater.updateStaticSecret(ctx, path)
 				if err != nil {
 					// While we are kind of 'missing' an event this way, re-calling this function will
 					// result in the secret remaining up to date.
 					return fmt.Errorf("error updating static secret: path: %q, message: %s error: %w", path, message, err)
 				}
-			} else {
-				// This is an event we're not interested in, ignore it and
-				// carry on.
-				continue
 			}
+			updater.recordUpdate(sent)
 		}
 	}
+}
 
-	return nil
+// staticSecretEventPaths returns the paths of the cached static secrets to
+// update following the given event, and the time the event was sent. A path
+// ending with a slash is a prefix, whose cached static secrets are all
+// updated.
+func staticSecretEventPaths(message []byte) ([]string, time.Time, error) {
+	messageMap := make(map[string]interface{})
+	err := json.Unmarshal(message, &messageMap)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("error when unmarshaling event, message: %s\nerror: %w", string(message), err)
+	}
+	data, ok := messageMap["data"].(map[string]interface{})
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("unexpected event format when decoding 'data' element, message: %s", string(message))
+	}
+	event, ok := data["event"].(map[string]interface{})
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("unexpected event format when decoding 'event' element, message: %s", string(message))
+	}
+	metadata, ok := event["metadata"].(map[string]interface{})
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("unexpected event format when decoding 'metadata' element, message: %s", string(message))
+	}
+	if modified, _ := metadata["modified"].(string); modified != "true" {
+		return nil, time.Time{}, nil
+	}
+
+	// The paths of the events are relative to their namespace, whereas the
+	// cached static secrets are indexed by their full path
+	ns, _ := data["namespace"].(string)
+	ns = namespace.Canonicalize(ns)
+	var mountPath string
+	if pluginInfo, ok := data["plugin_info"].(map[string]interface{}); ok {
+		mountPath, _ = pluginInfo["mount_path"].(string)
+	}
+	eventType, _ := data["event_type"].(string)
+
+	var paths []string
+	switch {
+	case strings.HasPrefix(eventType, "kv"):
+		path, ok := metadata["path"].(string)
+		if !ok {
+			return nil, time.Time{}, fmt.Errorf("unexpected event format when decoding 'path' element, message: %s", string(message))
+		}
+		paths = append(paths, ns+path)
+		// KV version 2 metadata events invalidate the data of the secret
+		if dataPath, ok := metadata["data_path"].(string); ok && dataPath != path {
+			paths = append(paths, ns+dataPath)
+		}
+	case strings.HasPrefix(eventType, "database/"):
+		if mountPath == "" {
+			return nil, time.Time{}, fmt.Errorf("unexpected event format when decoding 'mount_path' element, message: %s", string(message))
+		}
+		// The rotation of the credentials of a static role is sent with the
+		// name of the role, and no path for automatic rotations, which the
+		// event bus turns into the mount path
+		if name, ok := metadata["name"].(string); ok && name != "" {
+			paths = append(paths, ns+mountPath+"static-creds/"+name)
+		}
+		if path, ok := metadata["path"].(string); ok && path != "" && path != strings.TrimSuffix(mountPath, "/") {
+			paths = append(paths, ns+path)
+		}
+	case strings.HasPrefix(eventType, "pki/"), strings.HasPrefix(eventType, "transit/"):
+		if mountPath == "" {
+			return nil, time.Time{}, fmt.Errorf("unexpected event format when decoding 'mount_path' element, message: %s", string(message))
+		}
+		// The events of these mounts do not map to the paths of the
+		// responses they affect, so all of those of the mount are updated
+		paths = append(paths, ns+mountPath)
+	}
+
+	// The time of the event is when the secret was updated in Vault
+	var sent time.Time
+	if eventTime, ok := messageMap["time"].(string); ok {
+		sent, _ = time.Parse(time.RFC3339Nano, eventTime)
+	}
+	return paths, sent, nil
 }
 
 // preEventStreamUpdate is called after successful connection to the event system but before
@@ -205,6 +327,26 @@ func (updater *StaticSecretCacheUpdater) preEventStreamUpdate(ctx context.Contex
 	return errs.ErrorOrNil()
 }
 
+// updateStaticSecretsWithPrefix updates all the cached static secrets whose
+// path starts with the given prefix.
+func (updater *StaticSecretCacheUpdater) updateStaticSecretsWithPrefix(ctx context.Context, prefix string) error {
+	indexes, err := updater.leaseCache.db.GetByPrefix(cachememdb.IndexNameID)
+	if err != nil {
+		return err
+	}
+
+	var errs *multierror.Error
+	for _, index := range indexes {
+		if index.Type != cacheboltdb.StaticSecretType || !strings.HasPrefix(index.RequestPath, prefix) {
+			continue
+		}
+		if err := updater.updateStaticSecret(ctx, index.RequestPath); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
 // updateStaticSecret checks for updates for a static secret on the path given,
 // and updates the cache if appropriate
 func (updater *StaticSecretCacheUpdater) updateStaticSecret(ctx context.Context, path string) error {
@@ -302,9 +444,88 @@ func (updater *StaticSecretCacheUpdater) updateStaticSecret(ctx context.Context,
 	return nil
 }
 
+// prewarm caches the static secrets at the prewarm paths, with the
+// auto-auth token.
+func (updater *StaticSecretCacheUpdater) prewarm(ctx context.Context) error {
+	updater.logger.Debug("starting prewarm of static secrets")
+
+	var errs *multierror.Error
+	for _, path := range updater.prewarmPaths {
+		if err := updater.prewarmPath(ctx, strings.TrimPrefix(path, "/")); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("error prewarming %q: %w", path, err))
+		}
+	}
+
+	updater.logger.Debug("finished prewarm of static secrets")
+
+	return errs.ErrorOrNil()
+}
+
+// prewarmPath caches the static secret at the given path, or the static
+// secrets under it, recursively, if it ends with a slash.
+func (updater *StaticSecretCacheUpdater) prewarmPath(ctx context.Context, path string) error {
+	token := updater.tokenSink.(sink.SinkReader).Token()
+
+	if !strings.HasSuffix(path, "/") {
+		// The request goes through the lease cache, so that the secret is
+		// cached just like if it was requested through the proxy
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/"+path, nil)
+		if err != nil {
+			return err
+		}
+		request.Header.Set(api.AuthHeaderName, token)
+		if ns := updater.client.Namespace(); ns != "" {
+			request.Header.Set(api.NamespaceHeaderName, ns)
+		}
+		_, err = updater.leaseCache.Send(ctx, &SendRequest{
+			Token:   token,
+			Request: request,
+		})
+		return err
+	}
+
+	client, err := updater.client.Clone()
+	if err != nil {
+		return err
+	}
+	client.SetToken(token)
+
+	// The secrets of KV version 2 mounts are listed under their metadata
+	// path, rather than their data path
+	var secret *api.Secret
+	if metadataPath := strings.Replace(path, "/data/", "/metadata/", 1); metadataPath != path {
+		secret, err = client.Logical().ListWithContext(ctx, metadataPath)
+	}
+	if secret == nil {
+		secret, err = client.Logical().ListWithContext(ctx, path)
+	}
+	if err != nil {
+		return err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil
+	}
+
+	keys, ok := secret.Data["keys"].([]interface{})
+	if !ok {
+		return nil
+	}
+	var errs *multierror.Error
+	for _, key := range keys {
+		key, ok := key.(string)
+		if !ok {
+			continue
+		}
+		if err := updater.prewarmPath(ctx, path+key); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
 // openWebSocketConnection opens a websocket connection to the event system for
-// the events that the static secret cache updater is interested in.
-func (updater *StaticSecretCacheUpdater) openWebSocketConnection(ctx context.Context) (*websocket.Conn, error) {
+// the events of the given type, in all the namespaces.
+func (updater *StaticSecretCacheUpdater) openWebSocketConnection(ctx context.Context, eventType string) (*websocket.Conn, error) {
 	// We parse this into a URL object to get the specific host and scheme
 	// information without nasty string parsing.
 	vaultURL, err := url.Parse(updater.client.Address())
@@ -319,12 +540,15 @@ func (updater *StaticSecretCacheUpdater) openWebSocketConnection(ctx context.Con
 	}
 
 	webSocketURL := url.URL{
-		Path:   "/v1/sys/events/subscribe/kv*",
+		Path:   "/v1/sys/events/subscribe/" + eventType,
 		Host:   vaultHost,
 		Scheme: scheme,
 	}
 	query := webSocketURL.Query()
 	query.Set("json", "true")
+	// The static secrets of other namespaces are cached when requested with
+	// a namespace, so we subscribe to the events of all the namespaces
+	query.Set("namespaces", "*")
 	webSocketURL.RawQuery = query.Encode()
 
 	updater.client.AddHeader(api.AuthHeaderName, updater.client.Token())
@@ -399,6 +623,12 @@ tokenLoop:
 		}
 	}
 
+	if len(updater.prewarmPaths) > 0 {
+		if err := updater.prewarm(ctx); err != nil {
+			updater.logger.Error("error occurred during prewarm of static secrets", "err", err)
+		}
+	}
+
 	shouldBackoff := false
 	for {
 		select {
@@ -414,6 +644,7 @@ tokenLoop:
 			err := updater.streamStaticSecretEvents(ctx)
 			if err != nil {
 				updater.logger.Error("error occurred during streaming static secret cache update events", "err", err)
+				updater.setConnected(false, err)
 				shouldBackoff = true
 				continue
 			}
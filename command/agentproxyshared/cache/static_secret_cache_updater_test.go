@@ -5,6 +5,7 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"testing"
@@ -18,6 +19,7 @@ import (
 	"github.com/hashicorp/vault/command/agentproxyshared/sink"
 	"github.com/hashicorp/vault/helper/constants"
 	"github.com/hashicorp/vault/helper/testhelpers/minimal"
+	"github.com/hashicorp/vault/helper/useragent"
 	vaulthttp "github.com/hashicorp/vault/http"
 	"github.com/hashicorp/vault/sdk/helper/logging"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -146,7 +148,7 @@ func TestOpenWebSocketConnection(t *testing.T) {
 	updater := testNewStaticSecretCacheUpdater(t, client)
 	updater.tokenSink.WriteToken(client.Token())
 
-	conn, err := updater.openWebSocketConnection(context.Background())
+	conn, err := updater.openWebSocketConnection(context.Background(), "kv*")
 	if constants.IsEnterprise {
 		require.NoError(t, err)
 		require.NotNil(t, conn)
@@ -173,7 +175,7 @@ func TestOpenWebSocketConnectionReceivesEventsDefaultMount(t *testing.T) {
 
 	updater := testNewStaticSecretCacheUpdater(t, client)
 
-	conn, err := updater.openWebSocketConnection(context.Background())
+	conn, err := updater.openWebSocketConnection(context.Background(), "kv*")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -232,7 +234,7 @@ func TestOpenWebSocketConnectionReceivesEventsKVV1(t *testing.T) {
 
 	updater := testNewStaticSecretCacheUpdater(t, client)
 
-	conn, err := updater.openWebSocketConnection(context.Background())
+	conn, err := updater.openWebSocketConnection(context.Background(), "kv*")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -297,7 +299,7 @@ func TestOpenWebSocketConnectionReceivesEventsKVV2(t *testing.T) {
 
 	updater := testNewStaticSecretCacheUpdater(t, client)
 
-	conn, err := updater.openWebSocketConnection(context.Background())
+	conn, err := updater.openWebSocketConnection(context.Background(), "kv*")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -372,7 +374,7 @@ func TestOpenWebSocketConnectionTestServer(t *testing.T) {
 	client.SetToken(rootToken)
 	updater := testNewStaticSecretCacheUpdater(t, client)
 
-	conn, err := updater.openWebSocketConnection(context.Background())
+	conn, err := updater.openWebSocketConnection(context.Background(), "kv*")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -732,3 +734,216 @@ func TestPreEventStreamUpdateErrorUpdating(t *testing.T) {
 	_, err = leaseCache.db.Get(cachememdb.IndexNameID, indexId)
 	require.Equal(t, cachememdb.ErrCacheItemNotFound, err)
 }
+
+// TestStaticSecretEventPaths tests that the paths of the cached static
+// secrets to update are derived from the events of each mount type.
+func TestStaticSecretEventPaths(t *testing.T) {
+	t.Parallel()
+
+	event := func(eventType, ns, mountPath string, metadata map[string]interface{}) []byte {
+		t.Helper()
+		b, err := json.Marshal(map[string]interface{}{
+			"time": "2023-09-12T15:19:49.394915-07:00",
+			"data": map[string]interface{}{
+				"namespace":  ns,
+				"event_type": eventType,
+				"event": map[string]interface{}{
+					"metadata": metadata,
+				},
+				"plugin_info": map[string]interface{}{
+					"mount_path": mountPath,
+				},
+			},
+		})
+		require.NoError(t, err)
+		return b
+	}
+
+	testCases := []struct {
+		name    string
+		message []byte
+		paths   []string
+	}{
+		{
+			name: "kv-v2 data write",
+			message: event("kv-v2/data-write", "", "secret/", map[string]interface{}{
+				"modified": "true", "path": "secret/data/foo", "data_path": "secret/data/foo",
+			}),
+			paths: []string{"secret/data/foo"},
+		},
+		{
+			name: "kv-v2 metadata delete",
+			message: event("kv-v2/metadata-delete", "", "secret/", map[string]interface{}{
+				"modified": "true", "path": "secret/metadata/foo", "data_path": "secret/data/foo",
+			}),
+			paths: []string{"secret/metadata/foo", "secret/data/foo"},
+		},
+		{
+			name: "kv namespace",
+			message: event("kv-v1/write", "ns1/ns2", "secret/", map[string]interface{}{
+				"modified": "true", "path": "secret/foo",
+			}),
+			paths: []string{"ns1/ns2/secret/foo"},
+		},
+		{
+			name: "database rotation",
+			message: event("database/rotate", "", "database/", map[string]interface{}{
+				"modified": "true", "operation": "rotate", "path": "database", "name": "app",
+			}),
+			paths: []string{"database/static-creds/app"},
+		},
+		{
+			name: "database role write",
+			message: event("database/static-role-update", "ns1/", "db/", map[string]interface{}{
+				"modified": "true", "operation": "static-role-update", "path": "db/static-roles/app", "name": "app",
+			}),
+			paths: []string{"ns1/db/static-creds/app", "ns1/db/static-roles/app"},
+		},
+		{
+			name: "pki",
+			message: event("pki/role-write", "", "pki/", map[string]interface{}{
+				"modified": "true", "operation": "role-write", "path": "pki/roles/example",
+			}),
+			paths: []string{"pki/"},
+		},
+		{
+			name: "transit",
+			message: event("transit/rotate", "ns1/", "transit/", map[string]interface{}{
+				"modified": "true", "operation": "rotate", "path": "transit/keys/key/rotate",
+			}),
+			paths: []string{"ns1/transit/"},
+		},
+		{
+			name: "not modified",
+			message: event("database/rotate-fail", "", "database/", map[string]interface{}{
+				"modified": "false", "name": "app",
+			}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			paths, sent, err := staticSecretEventPaths(tc.message)
+			require.NoError(t, err)
+			require.Equal(t, tc.paths, paths)
+			if len(tc.paths) > 0 {
+				require.False(t, sent.IsZero())
+			}
+		})
+	}
+
+	_, _, err := staticSecretEventPaths([]byte(`{"data": {}}`))
+	require.Error(t, err)
+}
+
+// TestUpdateStaticSecretsWithPrefix tests that updateStaticSecretsWithPrefix
+// only updates the cached static secrets under the prefix.
+func TestUpdateStaticSecretsWithPrefix(t *testing.T) {
+	t.Parallel()
+	cluster := vault.NewTestCluster(t, nil, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	client := cluster.Cores[0].Client
+
+	updater := testNewStaticSecretCacheUpdater(t, client)
+	leaseCache := updater.leaseCache
+
+	err := client.Sys().Mount("other", &api.MountInput{
+		Type: "kv",
+	})
+	require.NoError(t, err)
+
+	initialTime := time.Now().UTC()
+	for _, path := range []string{"secret/foo", "secret/bar", "other/foo"} {
+		_, err := client.Logical().WriteWithContext(context.Background(), path, map[string]interface{}{"foo": "bar"})
+		require.NoError(t, err)
+		err = leaseCache.db.Set(&cachememdb.Index{
+			Namespace:   "root/",
+			RequestPath: path,
+			LastRenewed: initialTime,
+			ID:          hashStaticSecretIndex(path),
+			Tokens:      map[string]struct{}{client.Token(): {}},
+			Response:    []byte{},
+			Type:        cacheboltdb.StaticSecretType,
+		})
+		require.NoError(t, err)
+	}
+
+	err = updater.updateStaticSecretsWithPrefix(context.Background(), "secret/")
+	require.NoError(t, err)
+
+	for path, updated := range map[string]bool{"secret/foo": true, "secret/bar": true, "other/foo": false} {
+		index, err := leaseCache.db.Get(cachememdb.IndexNameID, hashStaticSecretIndex(path))
+		require.NoError(t, err)
+		require.Equal(t, updated, initialTime.Before(index.LastRenewed), path)
+	}
+}
+
+// TestPrewarm tests that the static secrets at the prewarm paths, and under
+// the prewarm prefixes, are cached.
+func TestPrewarm(t *testing.T) {
+	t.Parallel()
+	cluster := vault.NewTestCluster(t, &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"kv": kv.VersionedKVFactory,
+		},
+	}, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	client := cluster.Cores[0].Client
+
+	err := client.Sys().Mount("secret-v2", &api.MountInput{
+		Type: "kv-v2",
+	})
+	require.NoError(t, err)
+	for _, path := range []string{"app/a", "app/sub/b", "other/c", "other/d"} {
+		_, err = client.KVv2("secret-v2").Put(context.Background(), path, map[string]interface{}{"foo": "bar"})
+		require.NoError(t, err)
+	}
+
+	apiProxy, err := NewAPIProxy(&APIProxyConfig{
+		Client:                  client,
+		Logger:                  logging.NewVaultLogger(hclog.Trace).Named("cache.apiproxy"),
+		UserAgentStringFunction: useragent.ProxyStringWithProxiedUserAgent,
+		UserAgentString:         useragent.ProxyAPIProxyString(),
+	})
+	require.NoError(t, err)
+	leaseCache, err := NewLeaseCache(&LeaseCacheConfig{
+		Client:             client,
+		BaseContext:        context.Background(),
+		Proxier:            apiProxy,
+		Logger:             logging.NewVaultLogger(hclog.Trace).Named("cache.leasecache"),
+		CacheStaticSecrets: true,
+		UserAgentToUse:     "test",
+	})
+	require.NoError(t, err)
+
+	tokenSink := newMockSink(t)
+	tokenSink.WriteToken(client.Token())
+	updater, err := NewStaticSecretCacheUpdater(&StaticSecretCacheUpdaterConfig{
+		Client:       client,
+		LeaseCache:   leaseCache,
+		Logger:       logging.NewVaultLogger(hclog.Trace).Named("cache.updater"),
+		TokenSink:    tokenSink,
+		PrewarmPaths: []string{"secret-v2/data/app/", "/secret-v2/data/other/d"},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, updater.prewarm(context.Background()))
+
+	for path, cached := range map[string]bool{
+		"secret-v2/data/app/a":     true,
+		"secret-v2/data/app/sub/b": true,
+		"secret-v2/data/other/c":   false,
+		"secret-v2/data/other/d":   true,
+	} {
+		index, err := leaseCache.db.Get(cachememdb.IndexNameID, hashStaticSecretIndex(path))
+		if !cached {
+			require.ErrorIs(t, err, cachememdb.ErrCacheItemNotFound, path)
+			continue
+		}
+		require.NoError(t, err, path)
+		require.Equal(t, cacheboltdb.StaticSecretType, index.Type)
+		require.Contains(t, index.Tokens, client.Token())
+	}
+}
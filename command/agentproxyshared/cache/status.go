@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package cache
+
+import (
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/command/agentproxyshared/health"
+)
+
+// CacheStats are the statistics of the lease cache.
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	// StaticSecretMemory is the total size, in bytes, of the cached static
+	// secrets, when a memory limit is set
+	StaticSecretMemory uint64 `json:"static_secret_memory,omitempty"`
+}
+
+// Stats returns the statistics of the lease cache.
+func (c *LeaseCache) Stats() *CacheStats {
+	return &CacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+
+		StaticSecretMemory: c.db.MemoryUsage(),
+	}
+}
+
+func (c *LeaseCache) recordEviction() {
+	c.evictions.Inc()
+	metrics.IncrCounter([]string{c.metricsSignifier, "cache", "eviction"}, 1)
+}
+
+var _ health.Component = (*LeaseCache)(nil)
+
+// HealthStatus implements health.Component. The lease cache is always
+// healthy.
+func (c *LeaseCache) HealthStatus() (interface{}, bool) {
+	return c.Stats(), true
+}
+
+// StaticSecretCacheUpdaterStatus is the health status of the static secret
+// cache updater.
+type StaticSecretCacheUpdaterStatus struct {
+	// Connected is whether the updater is connected to the event stream
+	Connected bool       `json:"connected"`
+	LastEvent *time.Time `json:"last_event,omitempty"`
+	// LastUpdateLag is the number of seconds between the last update of a
+	// secret in Vault and the update of the cache
+	LastUpdateLag *float64 `json:"last_update_lag,omitempty"`
+	LastError     string   `json:"last_error,omitempty"`
+}
+
+// updaterStatus holds the state of the event stream of the updater.
+type updaterStatus struct {
+	connected     bool
+	lastEvent     time.Time
+	lastUpdateLag time.Duration
+	lastError     error
+}
+
+func (updater *StaticSecretCacheUpdater) setConnected(connected bool, err error) {
+	updater.statusLock.Lock()
+	defer updater.statusLock.Unlock()
+	updater.status.connected = connected
+	if err != nil {
+		updater.status.lastError = err
+	}
+}
+
+// recordUpdate records the update of the cache following an event that was
+// sent at the given time, or at an unknown time if it is zero.
+func (updater *StaticSecretCacheUpdater) recordUpdate(sent time.Time) {
+	now := time.Now()
+
+	updater.statusLock.Lock()
+	defer updater.statusLock.Unlock()
+	updater.status.lastEvent = now
+	if sent.IsZero() {
+		return
+	}
+	updater.status.lastUpdateLag = now.Sub(sent)
+	metrics.MeasureSince([]string{updater.leaseCache.metricsSignifier, "cache", "static_secret", "update_lag"}, sent)
+}
+
+var _ health.Component = (*StaticSecretCacheUpdater)(nil)
+
+// HealthStatus implements health.Component. The updater is healthy while it
+// is connected to the event stream.
+func (updater *StaticSecretCacheUpdater) HealthStatus() (interface{}, bool) {
+	updater.statusLock.RLock()
+	defer updater.statusLock.RUnlock()
+
+	status := &StaticSecretCacheUpdaterStatus{
+		Connected: updater.status.connected,
+		LastEvent: health.TimePtr(updater.status.lastEvent),
+	}
+	if !updater.status.lastEvent.IsZero() && updater.status.lastUpdateLag > 0 {
+		lag := updater.status.lastUpdateLag.Seconds()
+		status.LastUpdateLag = &lag
+	}
+	if updater.status.lastError != nil {
+		status.LastError = updater.status.lastError.Error()
+	}
+	return status, status.Connected
+}
@@ -0,0 +1,322 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+// Package failover selects which of several Vault servers Agent and Proxy
+// send their requests to. The servers are health checked through sys/health
+// and the requests are routed to the healthiest of them, failing over to
+// another server when the selected one becomes unhealthy.
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	// DefaultHealthCheckInterval is how often the servers are health checked
+	// when no interval is configured.
+	DefaultHealthCheckInterval = 10 * time.Second
+
+	// healthCheckTimeout bounds the health check of a single server.
+	healthCheckTimeout = 5 * time.Second
+
+	// rankUnhealthy is the rank of the servers requests cannot be sent to.
+	rankUnhealthy = math.MaxInt
+)
+
+// AddressSelectorConfig is the configuration of an AddressSelector.
+type AddressSelectorConfig struct {
+	Logger hclog.Logger
+
+	// Client is used to health check the servers. It is cloned for each of
+	// them and is not modified.
+	Client *api.Client
+
+	// Addresses are the addresses of the servers, in order of preference.
+	Addresses []string
+
+	// HealthCheckInterval is how often the servers are health checked.
+	HealthCheckInterval time.Duration
+
+	// MetricsSignifier is the first argument given to the metrics, signifying
+	// the name of the application, e.g. "agent" or "proxy".
+	MetricsSignifier string
+}
+
+// AddressSelector health checks a set of Vault servers and points the clients
+// registered with it to the healthiest of them.
+//
+// Active nodes are preferred over performance standbys, which are preferred
+// over standbys. Between servers of the same kind, the one that answered its
+// health check the fastest, and so is likely the least loaded, is selected.
+// The selected server is kept as long as it is healthy and no better kind of
+// server is available, so that requests are not moved around between servers
+// of similar load.
+type AddressSelector struct {
+	logger           hclog.Logger
+	client           *api.Client
+	addresses        []string
+	interval         time.Duration
+	metricsSignifier string
+
+	// checkLock serializes the health checks
+	checkLock sync.Mutex
+	checked   bool
+
+	l         sync.RWMutex
+	selected  string
+	clients   []*api.Client
+	addressCh chan string
+}
+
+// NewAddressSelector returns an AddressSelector for the given addresses. The
+// first address is selected until the servers are first health checked, at
+// which point the healthiest of them is selected.
+func NewAddressSelector(conf *AddressSelectorConfig) (*AddressSelector, error) {
+	if conf.Client == nil {
+		return nil, errors.New("nil API client")
+	}
+
+	var addresses []string
+	seen := make(map[string]struct{}, len(conf.Addresses))
+	for _, addr := range conf.Addresses {
+		if addr == "" {
+			continue
+		}
+		// Use the same form of the addresses as api.Client.Address
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Vault address %q: %w", addr, err)
+		}
+		addr = u.String()
+		if _, ok := seen[addr]; ok {
+			continue
+		}
+		seen[addr] = struct{}{}
+		addresses = append(addresses, addr)
+	}
+	if len(addresses) == 0 {
+		return nil, errors.New("no Vault addresses")
+	}
+
+	interval := conf.HealthCheckInterval
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+
+	logger := conf.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+
+	return &AddressSelector{
+		logger:           logger,
+		client:           conf.Client,
+		addresses:        addresses,
+		interval:         interval,
+		metricsSignifier: conf.MetricsSignifier,
+		selected:         addresses[0],
+		addressCh:        make(chan string, 1),
+	}, nil
+}
+
+// Address returns the address of the selected server.
+func (s *AddressSelector) Address() string {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	return s.selected
+}
+
+// Addresses returns the addresses of all the servers.
+func (s *AddressSelector) Addresses() []string {
+	return append([]string(nil), s.addresses...)
+}
+
+// AddressCh returns a channel that receives the newly selected address each
+// time a different server is selected. Only the latest address is kept when
+// it is not read in time.
+func (s *AddressSelector) AddressCh() <-chan string {
+	return s.addressCh
+}
+
+// AddClient points the given client to the selected server, and keeps it
+// pointed to the selected server from then on. Clones made from the client
+// afterwards use the address the client had when they were made.
+func (s *AddressSelector) AddClient(client *api.Client) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if err := client.SetAddress(s.selected); err != nil {
+		return err
+	}
+	s.clients = append(s.clients, client)
+	return nil
+}
+
+// Run health checks the servers until the context is done.
+func (s *AddressSelector) Run(ctx context.Context) error {
+	s.logger.Info("starting Vault address health checks", "addresses", s.addresses, "interval", s.interval)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.Check(ctx)
+		}
+	}
+}
+
+// Failover health checks the servers right away after a request to the given
+// address failed. It returns the address to retry the request against, and
+// false if a different server could not be selected.
+func (s *AddressSelector) Failover(ctx context.Context, failed string) (string, bool) {
+	// Another request may have failed over already
+	if addr := s.Address(); addr != failed {
+		return addr, true
+	}
+	addr := s.Check(ctx)
+	if addr == failed {
+		return "", false
+	}
+	return addr, true
+}
+
+type healthCheck struct {
+	address string
+	rank    int
+	latency time.Duration
+	err     error
+}
+
+// Check health checks the servers, selects the healthiest of them and
+// returns its address.
+func (s *AddressSelector) Check(ctx context.Context) string {
+	s.checkLock.Lock()
+	defer s.checkLock.Unlock()
+
+	checks := make([]*healthCheck, len(s.addresses))
+	var wg sync.WaitGroup
+	for i, addr := range s.addresses {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			checks[i] = s.checkAddress(ctx, addr)
+		}(i, addr)
+	}
+	wg.Wait()
+
+	current := s.Address()
+	var best, selected *healthCheck
+	for _, check := range checks {
+		if check.address == current {
+			selected = check
+		}
+		if check.rank == rankUnhealthy {
+			s.logger.Debug("Vault server is unhealthy", "address", check.address, "error", check.err)
+			continue
+		}
+		if best == nil || check.rank < best.rank || (check.rank == best.rank && check.latency < best.latency) {
+			best = check
+		}
+	}
+
+	switch {
+	case best == nil:
+		s.logger.Warn("no healthy Vault server, keeping the selected address", "address", current)
+	case selected.rank == rankUnhealthy:
+		s.logger.Warn("selected Vault server is unhealthy, failing over", "from", current, "to", best.address, "error", selected.err)
+		s.selectAddress(best.address)
+		metrics.IncrCounter([]string{s.metricsSignifier, "vault", "failover"}, 1)
+	case !s.checked && best.address != current:
+		s.logger.Info("selecting the healthiest Vault server", "address", best.address)
+		s.selectAddress(best.address)
+	case best.rank < selected.rank:
+		s.logger.Info("switching to a preferred Vault server", "from", current, "to", best.address)
+		s.selectAddress(best.address)
+	}
+	s.checked = true
+
+	selectedAddr := s.Address()
+	for _, addr := range s.addresses {
+		var value float32
+		if addr == selectedAddr {
+			value = 1
+		}
+		metrics.SetGaugeWithLabels([]string{s.metricsSignifier, "vault", "address"}, value, []metrics.Label{{Name: "address", Value: addr}})
+	}
+	return selectedAddr
+}
+
+// checkAddress health checks the server at the given address and ranks it.
+func (s *AddressSelector) checkAddress(ctx context.Context, addr string) *healthCheck {
+	check := &healthCheck{
+		address: addr,
+		rank:    rankUnhealthy,
+	}
+
+	client, err := s.client.Clone()
+	if err != nil {
+		check.err = err
+		return check
+	}
+	if err := client.SetAddress(addr); err != nil {
+		check.err = err
+		return check
+	}
+	client.SetMaxRetries(0)
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	health, err := client.Sys().HealthWithContext(ctx)
+	check.latency = time.Since(start)
+	switch {
+	case err != nil:
+		check.err = err
+	case !health.Initialized:
+		check.err = errors.New("not initialized")
+	case health.Sealed:
+		check.err = errors.New("sealed")
+	case health.ReplicationDRMode == "secondary":
+		check.err = errors.New("DR secondary")
+	case !health.Standby:
+		check.rank = 0
+	case health.PerformanceStandby:
+		check.rank = 1
+	default:
+		check.rank = 2
+	}
+	return check
+}
+
+func (s *AddressSelector) selectAddress(addr string) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	s.selected = addr
+	for _, client := range s.clients {
+		if err := client.SetAddress(addr); err != nil {
+			s.logger.Error("failed to set the Vault address of a client", "address", addr, "error", err)
+		}
+	}
+
+	// Keep only the latest address for slow readers
+	select {
+	case <-s.addressCh:
+	default:
+	}
+	s.addressCh <- addr
+}
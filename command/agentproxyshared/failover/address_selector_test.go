@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package failover
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer is a fake Vault server answering health checks with the set
+// health response.
+type testServer struct {
+	*httptest.Server
+
+	l      sync.Mutex
+	health *api.HealthResponse
+}
+
+func newTestServer(t *testing.T, health *api.HealthResponse) *testServer {
+	t.Helper()
+	s := &testServer{health: health}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.l.Lock()
+		defer s.l.Unlock()
+		if r.URL.Path != "/v1/sys/health" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(s.health)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) setHealth(health *api.HealthResponse) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.health = health
+}
+
+func testClient(t *testing.T) *api.Client {
+	t.Helper()
+	client, err := api.NewClient(api.DefaultConfig())
+	require.NoError(t, err)
+	return client
+}
+
+var (
+	healthActive      = &api.HealthResponse{Initialized: true}
+	healthPerfStandby = &api.HealthResponse{Initialized: true, Standby: true, PerformanceStandby: true}
+	healthStandby     = &api.HealthResponse{Initialized: true, Standby: true}
+	healthSealed      = &api.HealthResponse{Initialized: true, Sealed: true}
+)
+
+// TestAddressSelector_Check tests that the healthiest server is selected, and
+// that the clients follow the selected server.
+func TestAddressSelector_Check(t *testing.T) {
+	standby := newTestServer(t, healthStandby)
+	perfStandby := newTestServer(t, healthPerfStandby)
+	active := newTestServer(t, healthActive)
+
+	s, err := NewAddressSelector(&AddressSelectorConfig{
+		Client:    testClient(t),
+		Addresses: []string{standby.URL, perfStandby.URL, active.URL, standby.URL},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{standby.URL, perfStandby.URL, active.URL}, s.Addresses())
+	require.Equal(t, standby.URL, s.Address())
+
+	client := testClient(t)
+	require.NoError(t, s.AddClient(client))
+	require.Equal(t, standby.URL, client.Address())
+
+	ctx := context.Background()
+	require.Equal(t, active.URL, s.Check(ctx))
+	require.Equal(t, active.URL, client.Address())
+	require.Equal(t, active.URL, <-s.AddressCh())
+
+	// Fail over to the next best server when the active one is sealed
+	active.setHealth(healthSealed)
+	require.Equal(t, perfStandby.URL, s.Check(ctx))
+	require.Equal(t, perfStandby.URL, client.Address())
+
+	// Switch back once the active server is healthy again
+	active.setHealth(healthActive)
+	require.Equal(t, active.URL, s.Check(ctx))
+	require.Equal(t, active.URL, client.Address())
+
+	// Keep the selected server when none is healthy
+	active.Close()
+	standby.setHealth(healthSealed)
+	perfStandby.setHealth(healthSealed)
+	require.Equal(t, active.URL, s.Check(ctx))
+}
+
+// TestAddressSelector_Failover tests that a failed request is retried against
+// another server only when one is healthy.
+func TestAddressSelector_Failover(t *testing.T) {
+	first := newTestServer(t, healthActive)
+	second := newTestServer(t, healthStandby)
+
+	s, err := NewAddressSelector(&AddressSelectorConfig{
+		Client:    testClient(t),
+		Addresses: []string{first.URL, second.URL},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.Equal(t, first.URL, s.Check(ctx))
+
+	_, ok := s.Failover(ctx, first.URL)
+	require.False(t, ok)
+
+	first.Close()
+	addr, ok := s.Failover(ctx, first.URL)
+	require.True(t, ok)
+	require.Equal(t, second.URL, addr)
+
+	// Requests that failed against the previous server are retried against
+	// the selected one without checking again
+	second.Close()
+	addr, ok = s.Failover(ctx, first.URL)
+	require.True(t, ok)
+	require.Equal(t, second.URL, addr)
+}
@@ -0,0 +1,151 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+// Package health reports the health of the subsystems of Agent and Proxy,
+// such as auto-auth or the cache, over HTTP and through periodic metrics.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// DefaultMetricsInterval is how often the components emit their metrics.
+const DefaultMetricsInterval = 10 * time.Second
+
+// Component is a subsystem whose health is reported.
+type Component interface {
+	// HealthStatus returns the status of the subsystem, which is encoded as
+	// JSON, and whether the subsystem is healthy.
+	HealthStatus() (interface{}, bool)
+}
+
+// MetricsEmitter is implemented by the components that report metrics, such
+// as gauges, which must be emitted periodically rather than as events happen.
+type MetricsEmitter interface {
+	EmitMetrics()
+}
+
+// Reporter reports the health of the registered components.
+type Reporter struct {
+	logger   hclog.Logger
+	interval time.Duration
+
+	l          sync.RWMutex
+	components map[string]Component
+}
+
+// Response is the body of the responses of the health endpoint.
+type Response struct {
+	Healthy    bool                   `json:"healthy"`
+	Components map[string]interface{} `json:"components"`
+	Unhealthy  []string               `json:"unhealthy,omitempty"`
+}
+
+// NewReporter returns a Reporter without any component.
+func NewReporter(logger hclog.Logger) *Reporter {
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	return &Reporter{
+		logger:     logger,
+		interval:   DefaultMetricsInterval,
+		components: make(map[string]Component),
+	}
+}
+
+// Register adds a component to the reported ones under the given name,
+// replacing any component registered under the same name.
+func (r *Reporter) Register(name string, c Component) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.components[name] = c
+}
+
+// Report returns the status of all the components. Agent or Proxy is only
+// healthy when all of its components are.
+func (r *Reporter) Report() *Response {
+	r.l.RLock()
+	defer r.l.RUnlock()
+
+	resp := &Response{
+		Healthy:    true,
+		Components: make(map[string]interface{}, len(r.components)),
+	}
+	for name, c := range r.components {
+		status, healthy := c.HealthStatus()
+		resp.Components[name] = status
+		if !healthy {
+			resp.Healthy = false
+			resp.Unhealthy = append(resp.Unhealthy, name)
+		}
+	}
+	sort.Strings(resp.Unhealthy)
+	return resp
+}
+
+// Handler returns the handler of the health endpoint. It responds with a 200
+// status code when healthy, and a 503 status code otherwise.
+func (r *Reporter) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			logical.RespondError(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+
+		resp := r.Report()
+		status := http.StatusOK
+		if !resp.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			r.logger.Error("failed to encode the health response", "error", err)
+		}
+	})
+}
+
+// Run emits the metrics of the components periodically until the context is
+// done.
+func (r *Reporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			r.emitMetrics()
+		}
+	}
+}
+
+func (r *Reporter) emitMetrics() {
+	r.l.RLock()
+	defer r.l.RUnlock()
+
+	for _, c := range r.components {
+		if emitter, ok := c.(MetricsEmitter); ok {
+			emitter.EmitMetrics()
+		}
+	}
+}
+
+// TimePtr returns a pointer to the given time, or nil for the zero time, so
+// that unset times are omitted from the responses.
+func TimePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testComponent struct {
+	status  interface{}
+	healthy bool
+}
+
+func (c *testComponent) HealthStatus() (interface{}, bool) {
+	return c.status, c.healthy
+}
+
+func TestReporter_Handler(t *testing.T) {
+	r := NewReporter(nil)
+	auth := &testComponent{status: map[string]interface{}{"authenticated": true}, healthy: true}
+	r.Register("auth", auth)
+	r.Register("cache", &testComponent{status: map[string]interface{}{"hits": 1}, healthy: true})
+
+	get := func() (int, *Response) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/agent/v1/health", nil))
+		var resp Response
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, &resp
+	}
+
+	code, resp := get()
+	if code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if !resp.Healthy || len(resp.Unhealthy) != 0 {
+		t.Fatalf("expected a healthy response, got %#v", resp)
+	}
+	if len(resp.Components) != 2 {
+		t.Fatalf("expected 2 components, got %#v", resp.Components)
+	}
+
+	auth.healthy = false
+	code, resp = get()
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, code)
+	}
+	if resp.Healthy || len(resp.Unhealthy) != 1 || resp.Unhealthy[0] != "auth" {
+		t.Fatalf("expected auth to be unhealthy, got %#v", resp)
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/agent/v1/health", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
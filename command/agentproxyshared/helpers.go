@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/command/agentproxyshared/auth"
 	"github.com/hashicorp/vault/command/agentproxyshared/auth/alicloud"
 	"github.com/hashicorp/vault/command/agentproxyshared/auth/approle"
@@ -77,11 +78,23 @@ type PersistConfig struct {
 	KeepAfterImport         bool   `hcl:"keep_after_import"`
 	ExitOnErr               bool   `hcl:"exit_on_err"`
 	ServiceAccountTokenFile string `hcl:"service_account_token_file"`
+
+	// KeyFile is the path of the file holding the key protecting the
+	// encryption key of the "file" persistent cache
+	KeyFile string `hcl:"key_file"`
+
+	// TransitMountPath, TransitKeyName and TransitTokenFile configure the
+	// transit key protecting the encryption key of the "file" persistent
+	// cache
+	TransitMountPath string `hcl:"transit_mount_path"`
+	TransitKeyName   string `hcl:"transit_key_name"`
+	TransitTokenFile string `hcl:"transit_token_file"`
 }
 
 // AddPersistentStorageToLeaseCache adds persistence to a lease cache, based on a given PersistConfig
 // Returns a close function to be deferred and the old token, if found, or an error
-func AddPersistentStorageToLeaseCache(ctx context.Context, leaseCache *cache.LeaseCache, persistConfig *PersistConfig, logger log.Logger) (func() error, string, error) {
+// The client is only used by the "file" type to protect the encryption key with a transit key.
+func AddPersistentStorageToLeaseCache(ctx context.Context, leaseCache *cache.LeaseCache, persistConfig *PersistConfig, client *api.Client, logger log.Logger) (func() error, string, error) {
 	if persistConfig == nil {
 		return nil, "", errors.New("persist config was nil")
 	}
@@ -103,6 +116,10 @@ func AddPersistentStorageToLeaseCache(ctx context.Context, leaseCache *cache.Lea
 			}
 			return nil, "", fmt.Errorf("failed to read service account token from %s: %w", tokenFileName, err)
 		}
+	case "file":
+		if (persistConfig.KeyFile == "") == (persistConfig.TransitKeyName == "") {
+			return nil, "", errors.New("exactly one of key_file and transit_key_name must be specified for the file persistent cache")
+		}
 	default:
 		return nil, "", fmt.Errorf("persistent key protection type %q not supported", persistConfig.Type)
 	}
@@ -133,7 +150,7 @@ func AddPersistentStorageToLeaseCache(ctx context.Context, leaseCache *cache.Lea
 			return nil, "", fmt.Errorf("failed to close persistent cache file after getting retrieval token: %w", err)
 		}
 
-		km, err := keymanager.NewPassthroughKeyManager(ctx, token)
+		km, err := newKeyManager(ctx, persistConfig, client, token)
 		if err != nil {
 			return nil, "", fmt.Errorf("failed to configure persistence encryption for cache: %w", err)
 		}
@@ -179,8 +196,9 @@ func AddPersistentStorageToLeaseCache(ctx context.Context, leaseCache *cache.Lea
 		}
 
 		// If keep_after_import true, set persistent storage layer in
-		// leaseCache, else remove db file
-		if persistConfig.KeepAfterImport {
+		// leaseCache, else remove db file. The file persistent cache is
+		// meant to survive restarts, so it is always kept.
+		if persistConfig.KeepAfterImport || persistConfig.Type == "file" {
 			leaseCache.SetPersistentStorage(ps)
 			return ps.Close, previousToken, nil
 		} else {
@@ -197,7 +215,7 @@ func AddPersistentStorageToLeaseCache(ctx context.Context, leaseCache *cache.Lea
 			return nil, previousToken, nil
 		}
 	} else {
-		km, err := keymanager.NewPassthroughKeyManager(ctx, nil)
+		km, err := newKeyManager(ctx, persistConfig, client, nil)
 		if err != nil {
 			return nil, "", fmt.Errorf("failed to configure persistence encryption for cache: %w", err)
 		}
@@ -226,6 +244,53 @@ func AddPersistentStorageToLeaseCache(ctx context.Context, leaseCache *cache.Lea
 	}
 }
 
+// newKeyManager returns the key manager for the encryption key of the
+// persistent cache, based on its type. The key is recovered from the
+// retrieval token if one is given, and generated otherwise.
+func newKeyManager(ctx context.Context, persistConfig *PersistConfig, client *api.Client, retrievalToken []byte) (keymanager.KeyManager, error) {
+	if persistConfig.Type != "file" {
+		return keymanager.NewPassthroughKeyManager(ctx, retrievalToken)
+	}
+
+	var protector keymanager.KeyProtector
+	var err error
+	if persistConfig.KeyFile != "" {
+		protector, err = keymanager.NewKeyFileProtector(ctx, persistConfig.KeyFile)
+	} else {
+		protector, err = newTransitProtector(persistConfig, client)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return keymanager.NewProtectedKeyManager(ctx, protector, retrievalToken)
+}
+
+// newTransitProtector returns a transit protector authenticating with the
+// token of the transit token file, if any, or else with the token of the client.
+func newTransitProtector(persistConfig *PersistConfig, client *api.Client) (*keymanager.TransitProtector, error) {
+	if client == nil {
+		return nil, errors.New("no Vault client available for the transit key")
+	}
+	client, err := client.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	if persistConfig.TransitTokenFile != "" {
+		token, err := os.ReadFile(persistConfig.TransitTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read transit token from %s: %w", persistConfig.TransitTokenFile, err)
+		}
+		client.SetToken(strings.TrimSpace(string(token)))
+	}
+	if client.Token() == "" {
+		return nil, errors.New("transit_token_file must be specified when no Vault token is available")
+	}
+
+	return keymanager.NewTransitProtector(client, persistConfig.TransitMountPath, persistConfig.TransitKeyName)
+}
+
 // getServiceAccountJWT attempts to read the service account JWT from the specified token file path.
 // Defaults to using the Kubernetes default service account file path if token file path is empty.
 func getServiceAccountJWT(tokenFile string) (string, error) {
@@ -6,6 +6,7 @@ package agentproxyshared
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
 	hclog "github.com/hashicorp/go-hclog"
@@ -75,7 +76,7 @@ func Test_AddPersistentStorageToLeaseCache(t *testing.T) {
 		t.Fatal("persistent storage was available before ours was added")
 	}
 
-	deferFunc, token, err := AddPersistentStorageToLeaseCache(context.Background(), leaseCache, persistConfig, logging.NewVaultLogger(hclog.Info))
+	deferFunc, token, err := AddPersistentStorageToLeaseCache(context.Background(), leaseCache, persistConfig, nil, logging.NewVaultLogger(hclog.Info))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -92,3 +93,62 @@ func Test_AddPersistentStorageToLeaseCache(t *testing.T) {
 		t.Fatal("expected deferFunc to not be nil")
 	}
 }
+
+// Test_AddPersistentStorageToLeaseCache_File tests that the file persistent
+// cache protected by a key file is restored after a restart
+func Test_AddPersistentStorageToLeaseCache_File(t *testing.T) {
+	persistConfig := &PersistConfig{
+		Type:    "file",
+		Path:    t.TempDir(),
+		KeyFile: filepath.Join(t.TempDir(), "cache.key"),
+	}
+	logger := logging.NewVaultLogger(hclog.Info)
+
+	leaseCache := testNewLeaseCache(t, nil)
+	deferFunc, token, err := AddPersistentStorageToLeaseCache(context.Background(), leaseCache, persistConfig, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		t.Fatal("expected token to be empty")
+	}
+	if err := leaseCache.RegisterAutoAuthToken("s.token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := deferFunc(); err != nil {
+		t.Fatal(err)
+	}
+
+	leaseCache = testNewLeaseCache(t, nil)
+	deferFunc, token, err = AddPersistentStorageToLeaseCache(context.Background(), leaseCache, persistConfig, nil, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "s.token" {
+		t.Fatalf("expected the previous token to be restored, got %q", token)
+	}
+	if leaseCache.PersistentStorage() == nil {
+		t.Fatal("expected the persistent storage to be kept")
+	}
+	if err := deferFunc(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cache cannot be restored without the key file
+	persistConfig.KeyFile = filepath.Join(t.TempDir(), "other.key")
+	if _, _, err := AddPersistentStorageToLeaseCache(context.Background(), testNewLeaseCache(t, nil), persistConfig, nil, logger); err == nil {
+		t.Fatal("expected an error restoring the cache with another key file")
+	}
+}
+
+func Test_AddPersistentStorageToLeaseCache_FileKeyProtection(t *testing.T) {
+	persistConfig := &PersistConfig{
+		Type: "file",
+		Path: t.TempDir(),
+	}
+
+	_, _, err := AddPersistentStorageToLeaseCache(context.Background(), testNewLeaseCache(t, nil), persistConfig, nil, logging.NewVaultLogger(hclog.Info))
+	if err == nil {
+		t.Fatal("expected an error without key_file or transit_key_name")
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+//go:build !linux
+
+package socket
+
+import (
+	"errors"
+	"net"
+)
+
+const peerCredentialsSupported = false
+
+func peerCredentials(net.Conn) (uint32, uint32, error) {
+	return 0, 0, errors.New("peer credentials are not supported on this platform")
+}
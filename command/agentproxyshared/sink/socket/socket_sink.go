@@ -0,0 +1,362 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+// Package socket implements sinks that serve the token over HTTP, either on a
+// Unix domain socket or on a loopback TCP address, so that the token never
+// touches the filesystem.
+package socket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-secure-stdlib/parseutil"
+	"github.com/hashicorp/vault/command/agentproxyshared/sink"
+	"go.uber.org/atomic"
+)
+
+// shutdownTimeout bounds how long Close waits for the requests being served.
+const shutdownTimeout = 5 * time.Second
+
+// socketSink is a Sink implementation that serves the latest token over HTTP
+type socketSink struct {
+	logger   hclog.Logger
+	token    *atomic.String
+	listener net.Listener
+	server   *http.Server
+
+	// path is the path of the Unix domain socket, empty for TCP listeners
+	path string
+
+	// allowedUIDs and allowedGIDs restrict which peers of the Unix domain
+	// socket are served the token; no restriction applies when both are empty.
+	// Only the primary group of a peer is known, so its supplementary groups
+	// are not matched against allowedGIDs.
+	allowedUIDs map[uint32]struct{}
+	allowedGIDs map[uint32]struct{}
+}
+
+// NewSocketSink creates a new sink serving the token on a Unix domain socket
+// with the given configuration
+func NewSocketSink(conf *sink.SinkConfig) (sink.Sink, error) {
+	if conf.Logger == nil {
+		return nil, errors.New("nil logger provided")
+	}
+
+	conf.Logger.Info("creating socket sink")
+
+	s := &socketSink{
+		logger: conf.Logger,
+		token:  atomic.NewString(""),
+	}
+
+	pathRaw, ok := conf.Config["path"]
+	if !ok {
+		return nil, errors.New("'path' not specified for socket sink")
+	}
+	path, ok := pathRaw.(string)
+	if !ok || path == "" {
+		return nil, errors.New("could not parse 'path' as string")
+	}
+	s.path = path
+
+	mode := os.FileMode(0o600)
+	if modeRaw, ok := conf.Config["mode"]; ok {
+		s.logger.Debug("verifying override for default socket sink mode")
+		m, typeOK := modeRaw.(int)
+		if !typeOK {
+			return nil, errors.New("could not parse 'mode' as integer")
+		}
+		if m < 0 || os.FileMode(m)&^fs.ModePerm != 0 {
+			return nil, errors.New("'mode' must only contain permission bits")
+		}
+		mode = os.FileMode(m)
+	}
+
+	var err error
+	if s.allowedUIDs, err = parseIDs(conf.Config, "allowed_uids"); err != nil {
+		return nil, err
+	}
+	if s.allowedGIDs, err = parseIDs(conf.Config, "allowed_gids"); err != nil {
+		return nil, err
+	}
+	if (len(s.allowedUIDs) > 0 || len(s.allowedGIDs) > 0) && !peerCredentialsSupported {
+		return nil, errors.New("'allowed_uids' and 'allowed_gids' are not supported on this platform")
+	}
+
+	// Remove a socket left behind by a previous run, but nothing else
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%q exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("error removing stale socket %q: %w", path, err)
+		}
+	}
+
+	listener, err := listenUnix(path, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	s.serve(listener)
+
+	s.logger.Info("socket sink configured", "path", path, "mode", mode)
+
+	return s, nil
+}
+
+// listenUnix listens on a Unix domain socket at the given path with the given
+// mode. The socket is created in a private directory next to the path and only
+// renamed into place once its mode is set, so that no other user can connect
+// to it in between.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".vault-socket-sink-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating a private directory for %q: %w", path, err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, "sock")
+	listener, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %q: %w", path, err)
+	}
+	// The socket is renamed, and removed from its final path by Close
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("error setting the mode of %q: %w", path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("error moving the socket to %q: %w", path, err)
+	}
+	return listener, nil
+}
+
+// NewHTTPSink creates a new sink serving the token on a loopback TCP address
+// with the given configuration. The peers cannot be checked, so the token
+// must be response-wrapped or encrypted.
+func NewHTTPSink(conf *sink.SinkConfig) (sink.Sink, error) {
+	if conf.Logger == nil {
+		return nil, errors.New("nil logger provided")
+	}
+
+	conf.Logger.Info("creating http sink")
+
+	s := &socketSink{
+		logger: conf.Logger,
+		token:  atomic.NewString(""),
+	}
+
+	addressRaw, ok := conf.Config["address"]
+	if !ok {
+		return nil, errors.New("'address' not specified for http sink")
+	}
+	address, ok := addressRaw.(string)
+	if !ok {
+		return nil, errors.New("could not parse 'address' as string")
+	}
+
+	for _, key := range []string{"allowed_uids", "allowed_gids"} {
+		if _, ok := conf.Config[key]; ok {
+			return nil, fmt.Errorf("'%s' is only supported by the socket sink", key)
+		}
+	}
+
+	// Any local process can connect to a TCP listener, so the token must be
+	// response-wrapped or encrypted
+	if conf.WrapTTL == 0 && conf.DHType == "" {
+		return nil, errors.New("'wrap_ttl' or 'dh_type' must be set for the http sink")
+	}
+
+	if err := validateLoopback(address); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %q: %w", address, err)
+	}
+
+	s.serve(listener)
+
+	s.logger.Info("http sink configured", "address", listener.Addr().String())
+
+	return s, nil
+}
+
+// validateLoopback returns an error unless the host of the given address
+// only resolves to loopback addresses.
+func validateLoopback(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid 'address' %q: %w", address, err)
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else if strings.EqualFold(host, "localhost") {
+		if ips, err = net.LookupIP(host); err != nil {
+			return fmt.Errorf("error resolving %q: %w", host, err)
+		}
+	}
+	if len(ips) == 0 {
+		return fmt.Errorf("'address' %q must be a loopback address", address)
+	}
+	for _, ip := range ips {
+		if !ip.IsLoopback() {
+			return fmt.Errorf("'address' %q must be a loopback address", address)
+		}
+	}
+	return nil
+}
+
+// parseIDs parses the list of user or group IDs under the given key.
+func parseIDs(config map[string]interface{}, key string) (map[uint32]struct{}, error) {
+	raw, ok := config[key]
+	if !ok {
+		return nil, nil
+	}
+	ids, err := parseutil.ParseIntSlice(raw)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse '%s': %w", key, err)
+	}
+	result := make(map[uint32]struct{}, len(ids))
+	for _, id := range ids {
+		if id < 0 || id > int64(^uint32(0)) {
+			return nil, fmt.Errorf("invalid ID %d in '%s'", id, key)
+		}
+		result[uint32(id)] = struct{}{}
+	}
+	return result, nil
+}
+
+func (s *socketSink) serve(listener net.Listener) {
+	s.listener = listener
+	s.server = &http.Server{
+		Handler:           http.HandlerFunc(s.handleToken),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		IdleTimeout:       5 * time.Minute,
+		ErrorLog:          s.logger.StandardLogger(nil),
+		ConnContext:       s.connContext,
+	}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("error serving token", "error", err)
+		}
+	}()
+}
+
+type peerKey struct{}
+
+// peer holds the credentials of the process connected to the Unix domain
+// socket.
+type peer struct {
+	uid, gid uint32
+	err      error
+}
+
+// connContext records the credentials of the peer of Unix domain socket
+// connections when the served peers are restricted.
+func (s *socketSink) connContext(ctx context.Context, conn net.Conn) context.Context {
+	if len(s.allowedUIDs) == 0 && len(s.allowedGIDs) == 0 {
+		return ctx
+	}
+	uid, gid, err := peerCredentials(conn)
+	return context.WithValue(ctx, peerKey{}, &peer{uid: uid, gid: gid, err: err})
+}
+
+// authorized returns whether the peer the request came from may read the
+// token.
+func (s *socketSink) authorized(r *http.Request) bool {
+	if len(s.allowedUIDs) == 0 && len(s.allowedGIDs) == 0 {
+		return true
+	}
+	p, ok := r.Context().Value(peerKey{}).(*peer)
+	if !ok {
+		return false
+	}
+	if p.err != nil {
+		s.logger.Warn("error reading the credentials of the socket peer", "error", p.err)
+		return false
+	}
+	if _, ok := s.allowedUIDs[p.uid]; ok {
+		return true
+	}
+	if _, ok := s.allowedGIDs[p.gid]; ok {
+		return true
+	}
+	s.logger.Warn("refusing to serve the token to an unauthorized peer", "uid", p.uid, "gid", p.gid)
+	return false
+}
+
+func (s *socketSink) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	token := s.token.Load()
+	if token == "" {
+		http.Error(w, "no token available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write([]byte(token))
+}
+
+// WriteToken implements the Sink interface and stores the token to serve.
+// A blank token is ignored.
+func (s *socketSink) WriteToken(token string) error {
+	if token == "" {
+		return nil
+	}
+	s.token.Store(token)
+	s.logger.Info("token updated")
+	return nil
+}
+
+// Token implements the SinkReader interface.
+func (s *socketSink) Token() string {
+	return s.token.Load()
+}
+
+// Addr returns the address the token is served on.
+func (s *socketSink) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops serving the token, and removes the Unix domain socket.
+func (s *socketSink) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := s.server.Shutdown(ctx)
+	if s.path != "" {
+		if rmErr := os.Remove(s.path); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) && err == nil {
+			err = rmErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,200 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package socket
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agentproxyshared/sink"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+	"github.com/stretchr/testify/require"
+)
+
+// testGet requests the token from the sink and returns the status code and
+// the body of the response.
+func testGet(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func testUnixClient(path string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+}
+
+func TestSocketSink(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agent.sock")
+
+	s, err := NewSocketSink(&sink.SinkConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace),
+		Config: map[string]interface{}{
+			"path": path,
+		},
+	})
+	require.NoError(t, err)
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), fi.Mode().Perm())
+
+	// The private directory the socket was created in is removed
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	client := testUnixClient(path)
+
+	code, _ := testGet(t, client, "http://socket/")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+
+	require.NoError(t, s.WriteToken("s.token"))
+	code, body := testGet(t, client, "http://socket/")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "s.token", body)
+
+	resp, err := client.Post("http://socket/", "text/plain", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	require.NoError(t, s.(*socketSink).Close())
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestSocketSink_PeerCredentials(t *testing.T) {
+	if !peerCredentialsSupported {
+		t.Skip("peer credentials are not supported on this platform")
+	}
+
+	testCases := map[string]struct {
+		config   map[string]interface{}
+		expected int
+	}{
+		"allowed_uid": {
+			config:   map[string]interface{}{"allowed_uids": []interface{}{os.Getuid()}},
+			expected: http.StatusOK,
+		},
+		"allowed_gid": {
+			config:   map[string]interface{}{"allowed_uids": []interface{}{os.Getuid() + 1}, "allowed_gids": []interface{}{os.Getgid()}},
+			expected: http.StatusOK,
+		},
+		"denied": {
+			config:   map[string]interface{}{"allowed_uids": []interface{}{os.Getuid() + 1}, "allowed_gids": []interface{}{os.Getgid() + 1}},
+			expected: http.StatusForbidden,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "agent.sock")
+			tc.config["path"] = path
+
+			s, err := NewSocketSink(&sink.SinkConfig{
+				Logger: logging.NewVaultLogger(hclog.Trace),
+				Config: tc.config,
+			})
+			require.NoError(t, err)
+			defer s.(*socketSink).Close()
+			require.NoError(t, s.WriteToken("s.token"))
+
+			code, _ := testGet(t, testUnixClient(path), "http://socket/")
+			require.Equal(t, tc.expected, code)
+		})
+	}
+}
+
+func TestSocketSink_NotASocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	_, err := NewSocketSink(&sink.SinkConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace),
+		Config: map[string]interface{}{
+			"path": path,
+		},
+	})
+	require.ErrorContains(t, err, "not a socket")
+}
+
+func TestHTTPSink(t *testing.T) {
+	s, err := NewHTTPSink(&sink.SinkConfig{
+		Logger:  logging.NewVaultLogger(hclog.Trace),
+		WrapTTL: time.Minute,
+		Config: map[string]interface{}{
+			"address": "127.0.0.1:0",
+		},
+	})
+	require.NoError(t, err)
+	defer s.(*socketSink).Close()
+
+	require.NoError(t, s.WriteToken("s.token"))
+	code, body := testGet(t, http.DefaultClient, "http://"+s.(*socketSink).Addr().String())
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "s.token", body)
+}
+
+func TestHTTPSink_Loopback(t *testing.T) {
+	for _, address := range []string{"0.0.0.0:8201", "10.0.0.1:8201", "example.com:8201", "127.0.0.1"} {
+		_, err := NewHTTPSink(&sink.SinkConfig{
+			Logger:  logging.NewVaultLogger(hclog.Trace),
+			WrapTTL: time.Minute,
+			Config: map[string]interface{}{
+				"address": address,
+			},
+		})
+		require.Error(t, err, address)
+	}
+
+	_, err := NewHTTPSink(&sink.SinkConfig{
+		Logger:  logging.NewVaultLogger(hclog.Trace),
+		WrapTTL: time.Minute,
+		Config: map[string]interface{}{
+			"address":      "127.0.0.1:0",
+			"allowed_uids": []interface{}{0},
+		},
+	})
+	require.ErrorContains(t, err, "only supported by the socket sink")
+}
+
+func TestHTTPSink_RequiresWrappingOrEncryption(t *testing.T) {
+	_, err := NewHTTPSink(&sink.SinkConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace),
+		Config: map[string]interface{}{
+			"address": "127.0.0.1:0",
+		},
+	})
+	require.ErrorContains(t, err, "'wrap_ttl' or 'dh_type' must be set")
+
+	s, err := NewHTTPSink(&sink.SinkConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace),
+		DHType: "curve25519",
+		DHPath: "/tmp/dh-pub",
+		Config: map[string]interface{}{
+			"address": "127.0.0.1:0",
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.(*socketSink).Close())
+}
@@ -393,6 +393,7 @@ func TestPredict_Plugins(t *testing.T) {
 				"redshift-database-plugin",
 				"saml",
 				"snowflake-database-plugin",
+				"sqltemplate-database-plugin",
 				"ssh",
 				"terraform",
 				"totp",
@@ -497,6 +497,11 @@ func initCommands(ui, serverCmdUi cli.Ui, runOpts *RunOptions) map[string]cli.Co
 				BaseCommand: getBaseCommand(),
 			}, nil
 		},
+		"operator raft snapshot diff": func() (cli.Command, error) {
+			return &OperatorRaftSnapshotDiffCommand{
+				BaseCommand: getBaseCommand(),
+			}, nil
+		},
 		"operator raft snapshot inspect": func() (cli.Command, error) {
 			return &OperatorRaftSnapshotInspectCommand{
 				BaseCommand: getBaseCommand(),
@@ -507,6 +512,11 @@ func initCommands(ui, serverCmdUi cli.Ui, runOpts *RunOptions) map[string]cli.Co
 				BaseCommand: getBaseCommand(),
 			}, nil
 		},
+		"operator raft snapshot restore-partial": func() (cli.Command, error) {
+			return &OperatorRaftSnapshotRestorePartialCommand{
+				BaseCommand: getBaseCommand(),
+			}, nil
+		},
 		"operator raft snapshot save": func() (cli.Command, error) {
 			return &OperatorRaftSnapshotSaveCommand{
 				BaseCommand: getBaseCommand(),
@@ -532,6 +542,17 @@ func initCommands(ui, serverCmdUi cli.Ui, runOpts *RunOptions) map[string]cli.Co
 				BaseCommand: getBaseCommand(),
 			}, nil
 		},
+		"operator storage": func() (cli.Command, error) {
+			return &OperatorStorageCommand{
+				BaseCommand: getBaseCommand(),
+			}, nil
+		},
+		"operator storage verify": func() (cli.Command, error) {
+			return &OperatorStorageVerifyCommand{
+				BaseCommand: getBaseCommand(),
+				ShutdownCh:  MakeShutdownCh(),
+			}, nil
+		},
 		"operator usage": func() (cli.Command, error) {
 			return &OperatorUsageCommand{
 				BaseCommand: getBaseCommand(),
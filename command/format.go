@@ -348,6 +348,17 @@ func (t TableFormatter) OutputSealStatusStruct(ui cli.Ui, secret *api.Secret, da
 		out = append(out, fmt.Sprintf("Seal Migration in Progress | %t", status.Migration))
 	}
 
+	for _, seal := range status.Seals {
+		health := "healthy"
+		if !seal.Healthy {
+			health = "unhealthy"
+			if seal.UnhealthySince != "" {
+				health = fmt.Sprintf("unhealthy since %s", seal.UnhealthySince)
+			}
+		}
+		out = append(out, fmt.Sprintf("Seal %s | %s", seal.Name, health))
+	}
+
 	out = append(out, fmt.Sprintf("Version | %s", status.Version))
 	out = append(out, fmt.Sprintf("Build Date | %s", status.BuildDate))
 	out = append(out, fmt.Sprintf("Storage Type | %s", status.StorageType))
@@ -31,6 +31,10 @@ Usage: vault operator raft snapshot <subcommand> [options] [args]
 
       $ vault operator raft snapshot restore raft.snap
 
+  Restores only the storage of a mount from the provided snapshot:
+
+      $ vault operator raft snapshot restore-partial -mount-uuid=<uuid> raft.snap
+
   Saves a snapshot of the current state of the Raft cluster into a file:
 
       $ vault operator raft snapshot save raft.snap
@@ -39,6 +43,10 @@ Usage: vault operator raft snapshot <subcommand> [options] [args]
 
       $ vault operator raft snapshot inspect raft.snap
 
+  Compares the mounts, policies, leases and entities of two snapshots:
+
+      $ vault operator raft snapshot diff -unseal-key-file=keys.txt monday.snap tuesday.snap
+
   Please see the individual subcommand help for detailed usage information.
 `
 
@@ -0,0 +1,189 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-secure-stdlib/password"
+	"github.com/hashicorp/vault/command/server"
+	"github.com/hashicorp/vault/internalshared/configutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/sdk/physical/inmem"
+	"github.com/hashicorp/vault/shamir"
+	"github.com/hashicorp/vault/vault"
+	vaultseal "github.com/hashicorp/vault/vault/seal"
+	"github.com/mattn/go-isatty"
+	"github.com/posener/complete"
+)
+
+// snapshotDecryptFlags are the flags of the snapshot commands that decrypt
+// the storage entries of snapshots offline.
+type snapshotDecryptFlags struct {
+	unsealKeyFile string
+	sealConfig    string
+
+	testStdin io.Reader // for tests
+}
+
+func (d *snapshotDecryptFlags) addFlags(f *FlagSet) {
+	f.StringVar(&StringVar{
+		Name:       "unseal-key-file",
+		Target:     &d.unsealKeyFile,
+		Completion: complete.PredictFiles("*"),
+		Usage: "Path to a file with the unseal key shares, hex or base64 encoded, " +
+			"of a cluster using Shamir, one per line. At least as many shares as " +
+			"the unseal threshold decrypt the storage entries of the snapshot. " +
+			"If the value is \"-\", the shares are read from stdin, and prompted " +
+			"for without echo when stdin is a terminal.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "seal-config",
+		Target:     &d.sealConfig,
+		Completion: complete.PredictFiles("*.hcl"),
+		Usage: "Path to a server configuration file with the seal stanza of a " +
+			"cluster using auto-unseal, used to decrypt the storage entries of " +
+			"the snapshot. Recovery keys cannot decrypt a snapshot.",
+	})
+}
+
+func (d *snapshotDecryptFlags) enabled() bool {
+	return d.unsealKeyFile != "" || d.sealConfig != ""
+}
+
+// readUnsealKeys returns the unseal key shares of the -unseal-key-file flag.
+// The shares are never taken as arguments, which would leave them in the
+// process list and the shell history.
+func (d *snapshotDecryptFlags) readUnsealKeys() ([]string, error) {
+	if d.unsealKeyFile == "" {
+		return nil, nil
+	}
+
+	var r io.Reader
+	switch {
+	case d.unsealKeyFile != "-":
+		f, err := os.Open(d.unsealKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error opening unseal key file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	case d.testStdin != nil:
+		r = d.testStdin
+	case isatty.IsTerminal(os.Stdin.Fd()):
+		// Prompt for the shares until an empty one is entered
+		var keys []string
+		for {
+			fmt.Fprintf(os.Stderr, "Unseal Key %d (will be hidden, empty to finish): ", len(keys)+1)
+			value, err := password.Read(os.Stdin)
+			fmt.Fprintf(os.Stderr, "\n")
+			if err != nil {
+				return nil, fmt.Errorf("error reading unseal key: %w", err)
+			}
+			value = strings.TrimSpace(value)
+			if value == "" {
+				return keys, nil
+			}
+			keys = append(keys, value)
+		}
+	default:
+		r = os.Stdin
+	}
+
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			keys = append(keys, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading unseal keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no unseal keys were provided")
+	}
+	return keys, nil
+}
+
+func (d *snapshotDecryptFlags) unsealOptions(logger hclog.Logger) (*vault.SnapshotUnsealOptions, error) {
+	unsealKeys, err := d.readUnsealKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &vault.SnapshotUnsealOptions{}
+	for _, encoded := range unsealKeys {
+		// Shares are one byte longer than the key they split
+		key, err := hex.DecodeString(encoded)
+		if err != nil || len(key) < 16 || len(key) > 32+shamir.ShareOverhead {
+			key, err = base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, errors.New("unseal keys must be valid hex or base64 strings")
+			}
+		}
+		opts.UnsealKeys = append(opts.UnsealKeys, key)
+	}
+
+	if d.sealConfig == "" {
+		return opts, nil
+	}
+
+	config, err := server.LoadConfig(d.sealConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error loading seal configuration: %w", err)
+	}
+	for _, configSeal := range config.Seals {
+		if configSeal.Disabled {
+			continue
+		}
+		var infoKeys []string
+		info := map[string]string{}
+		wrapper, err := configutil.ConfigureWrapper(configSeal, &infoKeys, &info, logger.Named("seal"))
+		if err != nil {
+			return nil, fmt.Errorf("error configuring seal: %w", err)
+		}
+		if wrapper == nil {
+			return nil, errors.New("shamir seals are decrypted with -unseal-key-file")
+		}
+		opts.SealAccess, err = vaultseal.NewAccessFromWrapper(logger, wrapper, configSeal.Type)
+		if err != nil {
+			return nil, err
+		}
+		return opts, nil
+	}
+	return nil, fmt.Errorf("no enabled seal found in %q", d.sealConfig)
+}
+
+// decryptSnapshot loads the storage entries of the snapshot file in memory and
+// returns them decrypted through the barrier of the cluster that took it.
+func decryptSnapshot(logger hclog.Logger, path string, envelope *snapshotEnvelopeOpenFlags, opts *vault.SnapshotUnsealOptions) (logical.Storage, error) {
+	entries, err := inmem.NewInmem(nil, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := envelope.open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening snapshot file: %w", err)
+	}
+	defer f.Close()
+
+	reader := &OperatorRaftSnapshotInspectCommand{entries: entries}
+	if _, _, err := reader.Read(logger, f); err != nil {
+		return nil, fmt.Errorf("error reading snapshot: %w", err)
+	}
+
+	return vault.UnsealSnapshotStorage(context.Background(), logger, entries, opts)
+}
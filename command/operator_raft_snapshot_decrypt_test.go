@@ -0,0 +1,35 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotDecryptFlags_ReadUnsealKeys(t *testing.T) {
+	t.Parallel()
+
+	keyFile := filepath.Join(t.TempDir(), "keys.txt")
+	require.NoError(t, os.WriteFile(keyFile, []byte("key1\n\n  key2  \n"), 0o600))
+
+	keys, err := (&snapshotDecryptFlags{unsealKeyFile: keyFile}).readUnsealKeys()
+	require.NoError(t, err)
+	require.Equal(t, []string{"key1", "key2"}, keys)
+
+	keys, err = (&snapshotDecryptFlags{unsealKeyFile: "-", testStdin: strings.NewReader("key1\nkey2\n")}).readUnsealKeys()
+	require.NoError(t, err)
+	require.Equal(t, []string{"key1", "key2"}, keys)
+
+	_, err = (&snapshotDecryptFlags{unsealKeyFile: "-", testStdin: strings.NewReader("\n")}).readUnsealKeys()
+	require.ErrorContains(t, err, "no unseal keys")
+
+	keys, err = (&snapshotDecryptFlags{sealConfig: "seal.hcl"}).readUnsealKeys()
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
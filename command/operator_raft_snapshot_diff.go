@@ -0,0 +1,139 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/cli"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/vault"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*OperatorRaftSnapshotDiffCommand)(nil)
+	_ cli.CommandAutocomplete = (*OperatorRaftSnapshotDiffCommand)(nil)
+)
+
+type OperatorRaftSnapshotDiffCommand struct {
+	*BaseCommand
+	decrypt  snapshotDecryptFlags
+	envelope snapshotEnvelopeOpenFlags
+}
+
+func (c *OperatorRaftSnapshotDiffCommand) Synopsis() string {
+	return "Compares two raft snapshots"
+}
+
+func (c *OperatorRaftSnapshotDiffCommand) Help() string {
+	helpText := `
+Usage: vault operator raft snapshot diff [options] <from_snapshot_file> <to_snapshot_file>
+
+  Decrypts two snapshot files taken by the same cluster and lists the mounts,
+  policies, leases and entities that changed between them. The snapshots are
+  read offline, the unseal keys or the seal configuration of the cluster are
+  required to decrypt them.
+
+      $ vault operator raft snapshot diff -unseal-key-file=keys.txt monday.snap tuesday.snap
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorRaftSnapshotDiffCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetOutputFormat)
+	f := set.NewFlagSet("Command Options")
+
+	c.decrypt.addFlags(f)
+	c.envelope.addFlags(f, c.Client)
+
+	return set
+}
+
+func (c *OperatorRaftSnapshotDiffCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
+
+func (c *OperatorRaftSnapshotDiffCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *OperatorRaftSnapshotDiffCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) != 2 {
+		c.UI.Error(fmt.Sprintf("Incorrect arguments (expected 2, got %d)", len(args)))
+		return 1
+	}
+
+	if !c.decrypt.enabled() {
+		c.UI.Error("One of -unseal-key-file or -seal-config is required")
+		return 1
+	}
+
+	logger := hclog.New(nil)
+	opts, err := c.decrypt.unsealOptions(logger)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	from, err := decryptSnapshot(logger, args[0], &c.envelope, opts)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error decrypting %q: %s", args[0], err))
+		return 1
+	}
+	to, err := decryptSnapshot(logger, args[1], &c.envelope, opts)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error decrypting %q: %s", args[1], err))
+		return 1
+	}
+
+	diff, err := vault.DiffSnapshotStorage(context.Background(), from, to)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error comparing snapshots: %s", err))
+		return 1
+	}
+
+	if Format(c.UI) != "table" {
+		return OutputData(c.UI, diff)
+	}
+
+	sections := []struct {
+		name    string
+		changes []*vault.SnapshotChange
+	}{
+		{"Mounts", diff.Mounts},
+		{"Policies", diff.Policies},
+		{"Leases", diff.Leases},
+		{"Entities", diff.Entities},
+	}
+	for i, section := range sections {
+		if i > 0 {
+			c.UI.Output("")
+		}
+		c.UI.Output(section.name)
+		if len(section.changes) == 0 {
+			c.UI.Output("No changes")
+			continue
+		}
+		out := []string{"Change | Namespace | Name | ID"}
+		for _, change := range section.changes {
+			out = append(out, fmt.Sprintf("%s | %s | %s | %s", change.Change, change.Namespace, change.Name, change.ID))
+		}
+		c.UI.Output(tableOutput(out, nil))
+	}
+
+	return 0
+}
@@ -0,0 +1,385 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/pgpkeys"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/posener/complete"
+)
+
+// snapshotEnvelopeSealFlags are the flags of the commands that wrap the
+// snapshots they save in an encrypted and signed envelope.
+type snapshotEnvelopeSealFlags struct {
+	transitKey     string
+	encryptionKeys []string
+	pgpKeys        []string
+	signingKey     string
+
+	client func() (*api.Client, error)
+}
+
+func (e *snapshotEnvelopeSealFlags) addFlags(f *FlagSet, client func() (*api.Client, error)) {
+	e.client = client
+
+	f.StringVar(&StringVar{
+		Name:       "transit-key",
+		Target:     &e.transitKey,
+		Completion: complete.PredictAnything,
+		Usage: "Transit key, as <mount>/<key name>, that generates the data key " +
+			"of the envelope through its datakey endpoint. The snapshot is " +
+			"wrapped in an envelope that this key can decrypt, and the transit " +
+			"key does not need to be exportable.",
+	})
+
+	f.StringSliceVar(&StringSliceVar{
+		Name:       "encryption-key",
+		Target:     &e.encryptionKeys,
+		Completion: complete.PredictFiles("*"),
+		Usage: "Path to a file with a base64 encoded 256 bits AES key. The " +
+			"snapshot is wrapped in an envelope that this key can decrypt. This " +
+			"can be specified multiple times.",
+	})
+
+	f.VarFlag(&VarFlag{
+		Name:       "pgp-keys",
+		Value:      (*pgpkeys.PubKeyFilesFlag)(&e.pgpKeys),
+		Completion: complete.PredictAnything,
+		Usage: "Comma-separated list of paths to files on disk containing " +
+			"public PGP keys OR a comma-separated list of Keybase usernames using " +
+			"the format \"keybase:<username>\". The snapshot is wrapped in an " +
+			"envelope that any of these keys can decrypt.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "signing-key",
+		Target:     &e.signingKey,
+		Completion: complete.PredictFiles("*.pem"),
+		Usage: "Path to a PEM encoded ed25519 private key used to sign the " +
+			"envelope. Required with -transit-key, -encryption-key or -pgp-keys.",
+	})
+}
+
+func (e *snapshotEnvelopeSealFlags) enabled() bool {
+	return e.transitKey != "" || len(e.encryptionKeys) > 0 || len(e.pgpKeys) > 0 || e.signingKey != ""
+}
+
+func (e *snapshotEnvelopeSealFlags) options() (*raft.SnapshotEnvelopeSealOptions, error) {
+	if e.transitKey == "" && len(e.encryptionKeys) == 0 && len(e.pgpKeys) == 0 {
+		return nil, errors.New("one of -transit-key, -encryption-key or -pgp-keys is required to wrap the snapshot")
+	}
+	if e.signingKey == "" {
+		return nil, errors.New("-signing-key is required to wrap the snapshot")
+	}
+
+	opts := &raft.SnapshotEnvelopeSealOptions{
+		PGPKeys: e.pgpKeys,
+	}
+	if e.transitKey != "" {
+		dataKey, err := e.transitDataKey()
+		if err != nil {
+			return nil, err
+		}
+		opts.TransitDataKey = dataKey
+	}
+	for _, path := range e.encryptionKeys {
+		key, err := readSnapshotEncryptionKey(path)
+		if err != nil {
+			return nil, err
+		}
+		opts.EncryptionKeys = append(opts.EncryptionKeys, key)
+	}
+
+	block, err := readPEMFile(e.signingKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing signing key: %w", err)
+	}
+	var ok bool
+	if opts.SigningKey, ok = key.(ed25519.PrivateKey); !ok {
+		return nil, errors.New("signing key must be an ed25519 key")
+	}
+	return opts, nil
+}
+
+// transitDataKey generates a data key with the datakey endpoint of the
+// transit key.
+func (e *snapshotEnvelopeSealFlags) transitDataKey() (*raft.SnapshotTransitDataKey, error) {
+	mount, name, err := raft.SplitSnapshotTransitKey(e.transitKey)
+	if err != nil {
+		return nil, err
+	}
+	client, err := e.client()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.Logical().Write(mount+"/datakey/plaintext/"+name, map[string]interface{}{
+		"bits": 256,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error generating a data key with transit key %q: %w", e.transitKey, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("no data key returned by transit key %q", e.transitKey)
+	}
+	plaintext, _ := secret.Data["plaintext"].(string)
+	ciphertext, _ := secret.Data["ciphertext"].(string)
+	key, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil || ciphertext == "" {
+		return nil, fmt.Errorf("invalid data key returned by transit key %q", e.transitKey)
+	}
+	return &raft.SnapshotTransitDataKey{
+		KeyID:      strings.Trim(e.transitKey, "/"),
+		Plaintext:  key,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// snapshotEnvelopeOpenFlags are the flags of the commands that read the
+// snapshots wrapped in an envelope.
+type snapshotEnvelopeOpenFlags struct {
+	transitKey    string
+	decryptionKey string
+	pgpPrivateKey string
+	verifyKey     string
+
+	client func() (*api.Client, error)
+}
+
+func (e *snapshotEnvelopeOpenFlags) addFlags(f *FlagSet, client func() (*api.Client, error)) {
+	e.client = client
+
+	f.StringVar(&StringVar{
+		Name:       "transit-key",
+		Target:     &e.transitKey,
+		Completion: complete.PredictAnything,
+		Usage: "Transit key, as <mount>/<key name>, that generated the data key " +
+			"of the snapshot envelope. The data key is decrypted by Vault.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "decryption-key",
+		Target:     &e.decryptionKey,
+		Completion: complete.PredictFiles("*"),
+		Usage: "Path to a file with the base64 encoded AES key the snapshot " +
+			"envelope was encrypted with.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "pgp-private-key",
+		Target:     &e.pgpPrivateKey,
+		Completion: complete.PredictFiles("*"),
+		Usage: "Path to a file with an unencrypted PGP private key, armored or " +
+			"binary, the snapshot envelope was encrypted for.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "verify-key",
+		Target:     &e.verifyKey,
+		Completion: complete.PredictFiles("*.pem"),
+		Usage: "Path to a PEM encoded ed25519 public key the signature of the " +
+			"snapshot envelope is verified with. The signature is verified " +
+			"before the snapshot is read.",
+	})
+}
+
+func (e *snapshotEnvelopeOpenFlags) enabled() bool {
+	return e.transitKey != "" || e.decryptionKey != "" || e.pgpPrivateKey != "" || e.verifyKey != ""
+}
+
+func (e *snapshotEnvelopeOpenFlags) options() (*raft.SnapshotEnvelopeOpenOptions, error) {
+	if e.verifyKey == "" {
+		return nil, errors.New("-verify-key is required to open a snapshot envelope")
+	}
+	if e.transitKey == "" && e.decryptionKey == "" && e.pgpPrivateKey == "" {
+		return nil, errors.New("one of -transit-key, -decryption-key or -pgp-private-key is required to open a snapshot envelope")
+	}
+
+	opts := &raft.SnapshotEnvelopeOpenOptions{}
+	if e.transitKey != "" {
+		mount, name, err := raft.SplitSnapshotTransitKey(e.transitKey)
+		if err != nil {
+			return nil, err
+		}
+		opts.TransitKeyID = mount + "/" + name
+		opts.TransitDecrypt = func(ciphertext string) ([]byte, error) {
+			return e.transitDecrypt(mount, name, ciphertext)
+		}
+	}
+	if e.decryptionKey != "" {
+		key, err := readSnapshotEncryptionKey(e.decryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		opts.EncryptionKey = key
+	}
+	if e.pgpPrivateKey != "" {
+		key, err := readPGPPrivateKey(e.pgpPrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		opts.PGPPrivateKey = key
+	}
+
+	block, err := readPEMFile(e.verifyKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing verification key: %w", err)
+	}
+	var ok bool
+	if opts.VerifyKey, ok = key.(ed25519.PublicKey); !ok {
+		return nil, errors.New("verification key must be an ed25519 key")
+	}
+	return opts, nil
+}
+
+// transitDecrypt decrypts the data key of an envelope with the transit key.
+func (e *snapshotEnvelopeOpenFlags) transitDecrypt(mount, name, ciphertext string) ([]byte, error) {
+	client, err := e.client()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.Logical().Write(mount+"/decrypt/"+name, map[string]interface{}{
+		"ciphertext": ciphertext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("no plaintext returned by the transit key")
+	}
+	plaintext, _ := secret.Data["plaintext"].(string)
+	return base64.StdEncoding.DecodeString(plaintext)
+}
+
+// open opens the snapshot file. Snapshots wrapped in an envelope have their
+// signature verified, then are decrypted to a temporary file that is removed
+// when the returned reader is closed.
+func (e *snapshotEnvelopeOpenFlags) open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !raft.IsSnapshotEnvelope(bufio.NewReader(f)) {
+		if e.enabled() {
+			f.Close()
+			return nil, errors.New("snapshot file is not wrapped in an envelope")
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+	defer f.Close()
+
+	opts, err := e.options()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := raft.VerifySnapshotEnvelope(f, opts.VerifyKey); err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp("", "vault-snapshot")
+	if err != nil {
+		return nil, err
+	}
+	snap := &tempSnapshotFile{File: tmp}
+	if _, err := raft.OpenSnapshotEnvelope(tmp, f, opts); err != nil {
+		snap.Close()
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		snap.Close()
+		return nil, err
+	}
+	return snap, nil
+}
+
+// tempSnapshotFile is a decrypted snapshot removed once read.
+type tempSnapshotFile struct {
+	*os.File
+}
+
+func (t *tempSnapshotFile) Close() error {
+	err := t.File.Close()
+	os.Remove(t.Name())
+	return err
+}
+
+func readSnapshotEncryptionKey(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading encryption key: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key in %q is not base64 encoded: %w", path, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key in %q must be 256 bits long", path)
+	}
+	return key, nil
+}
+
+func readPEMFile(path string) (*pem.Block, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in %q", path)
+	}
+	return block, nil
+}
+
+// readPGPPrivateKey returns the private key in the file base64 encoded, as
+// expected by pgpkeys.DecryptBytes.
+func readPGPPrivateKey(path string) (string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading PGP private key: %w", err)
+	}
+	if block, err := armor.Decode(bytes.NewReader(raw)); err == nil {
+		raw, err = io.ReadAll(block.Body)
+		if err != nil {
+			return "", fmt.Errorf("error decoding PGP private key: %w", err)
+		}
+		return base64.StdEncoding.EncodeToString(raw), nil
+	}
+	if _, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw))); err == nil {
+		return strings.TrimSpace(string(raw)), nil
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
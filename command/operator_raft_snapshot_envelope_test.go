@@ -0,0 +1,101 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/pgpkeys"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotEnvelopeFlags(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+		return path
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	key := make([]byte, 32)
+	_, err = rand.Read(key)
+	require.NoError(t, err)
+
+	seal := &snapshotEnvelopeSealFlags{
+		encryptionKeys: []string{writeFile("key", []byte(base64.StdEncoding.EncodeToString(key)+"\n"))},
+		pgpKeys:        []string{pgpkeys.TestPubKey1},
+		signingKey:     writeFile("sign.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})),
+	}
+	opts, err := seal.options()
+	require.NoError(t, err)
+
+	snap := []byte("snapshot contents")
+	envelope := new(bytes.Buffer)
+	require.NoError(t, raft.WriteSnapshotEnvelope(envelope, bytes.NewReader(snap), opts))
+	envelopePath := writeFile("envelope.snap", envelope.Bytes())
+	plainPath := writeFile("plain.snap", snap)
+	verifyKey := writeFile("sign.pub.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+
+	readAll := func(t *testing.T, flags *snapshotEnvelopeOpenFlags, path string) ([]byte, error) {
+		t.Helper()
+		r, err := flags.open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+
+	t.Run("plain", func(t *testing.T) {
+		got, err := readAll(t, &snapshotEnvelopeOpenFlags{}, plainPath)
+		require.NoError(t, err)
+		require.Equal(t, snap, got)
+
+		_, err = readAll(t, &snapshotEnvelopeOpenFlags{verifyKey: verifyKey}, plainPath)
+		require.Error(t, err)
+	})
+
+	t.Run("encryption key", func(t *testing.T) {
+		got, err := readAll(t, &snapshotEnvelopeOpenFlags{
+			decryptionKey: seal.encryptionKeys[0],
+			verifyKey:     verifyKey,
+		}, envelopePath)
+		require.NoError(t, err)
+		require.Equal(t, snap, got)
+	})
+
+	t.Run("pgp key", func(t *testing.T) {
+		got, err := readAll(t, &snapshotEnvelopeOpenFlags{
+			pgpPrivateKey: writeFile("pgp.key", []byte(pgpkeys.TestPrivKey1)),
+			verifyKey:     verifyKey,
+		}, envelopePath)
+		require.NoError(t, err)
+		require.Equal(t, snap, got)
+	})
+
+	t.Run("missing keys", func(t *testing.T) {
+		_, err := readAll(t, &snapshotEnvelopeOpenFlags{}, envelopePath)
+		require.Error(t, err)
+
+		_, err = readAll(t, &snapshotEnvelopeOpenFlags{decryptionKey: seal.encryptionKeys[0]}, envelopePath)
+		require.Error(t, err)
+	})
+}
@@ -8,13 +8,13 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"hash"
 	"io"
 	"math"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -24,7 +24,11 @@ import (
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/raft"
 	protoio "github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/sdk/physical"
+	"github.com/hashicorp/vault/sdk/physical/inmem"
 	"github.com/hashicorp/vault/sdk/plugin/pb"
+	"github.com/hashicorp/vault/vault"
 	"github.com/posener/complete"
 )
 
@@ -35,9 +39,14 @@ var (
 
 type OperatorRaftSnapshotInspectCommand struct {
 	*BaseCommand
-	details bool
-	depth   int
-	filter  string
+	details  bool
+	depth    int
+	filter   string
+	decrypt  snapshotDecryptFlags
+	envelope snapshotEnvelopeOpenFlags
+
+	// entries receives the storage entries of the snapshot when set
+	entries physical.Backend
 }
 
 func (c *OperatorRaftSnapshotInspectCommand) Synopsis() string {
@@ -52,6 +61,11 @@ func (c *OperatorRaftSnapshotInspectCommand) Help() string {
 	
 	$ vault operator raft snapshot inspect raft.snap
 	
+	Decrypts the storage entries of a snapshot taken by a cluster using Shamir
+	and maps them back to mounts, namespaces and object types.
+	
+	$ vault operator raft snapshot inspect -unseal-key-file=keys.txt raft.snap
+	
 	` + c.Flags().Help()
 
 	return strings.TrimSpace(helpText)
@@ -82,6 +96,9 @@ func (c *OperatorRaftSnapshotInspectCommand) Flags() *FlagSets {
 		Usage:   "Can only be used with -details. Limits the key breakdown using this prefix filter.",
 	})
 
+	c.decrypt.addFlags(f)
+	c.envelope.addFlags(f, c.Client)
+
 	return set
 }
 
@@ -98,6 +115,7 @@ type OutputFormat struct {
 	StatsKV      []typeStats
 	TotalCountKV int
 	TotalSizeKV  int
+	Keys         []*vault.SnapshotKeyInfo `json:",omitempty"`
 }
 
 // SnapshotInfo is used for passing snapshot stat
@@ -152,13 +170,27 @@ func (c *OperatorRaftSnapshotInspectCommand) Run(args []string) int {
 	}
 
 	// Open the file.
-	f, err := os.Open(file)
+	f, err := c.envelope.open(file)
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error opening snapshot file: %s", err))
 		return 1
 	}
 	defer f.Close()
 
+	var unsealOpts *vault.SnapshotUnsealOptions
+	if c.decrypt.enabled() {
+		unsealOpts, err = c.decrypt.unsealOptions(hclog.New(nil))
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+		c.entries, err = inmem.NewInmem(nil, hclog.New(nil))
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+	}
+
 	// Extract metadata and snapshot info from snapshot file
 	var info *SnapshotInfo
 	var meta *raft.SnapshotMeta
@@ -191,6 +223,14 @@ func (c *OperatorRaftSnapshotInspectCommand) Run(args []string) int {
 		TotalSizeKV:  info.TotalSizeKV,
 	}
 
+	if unsealOpts != nil {
+		data.Keys, err = c.decryptedKeys(unsealOpts)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error decrypting snapshot: %s", err))
+			return 1
+		}
+	}
+
 	if Format(c.UI) != "table" {
 		return OutputData(c.UI, data)
 	}
@@ -206,6 +246,33 @@ func (c *OperatorRaftSnapshotInspectCommand) Run(args []string) int {
 	return 0
 }
 
+// decryptedKeys maps the keys of the snapshot entries matching the filter to
+// the objects they belong to.
+func (c *OperatorRaftSnapshotInspectCommand) decryptedKeys(opts *vault.SnapshotUnsealOptions) ([]*vault.SnapshotKeyInfo, error) {
+	ctx := context.Background()
+	storage, err := vault.UnsealSnapshotStorage(ctx, hclog.New(nil), c.entries, opts)
+	if err != nil {
+		return nil, err
+	}
+	index, err := vault.NewSnapshotIndex(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := logical.CollectKeys(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*vault.SnapshotKeyInfo{}
+	for _, key := range keys {
+		if strings.HasPrefix(key, c.filter) {
+			result = append(result, index.Lookup(key))
+		}
+	}
+	return result, nil
+}
+
 func (c *OperatorRaftSnapshotInspectCommand) kvEnhance(val *pb.StorageEntry, info *SnapshotInfo, read int) {
 	if !c.details {
 		return
@@ -261,6 +328,12 @@ func (c *OperatorRaftSnapshotInspectCommand) parseState(r io.Reader) (SnapshotIn
 		}
 		size := protoReader.GetLastReadSize()
 		c.kvEnhance(s, &info, size)
+
+		if c.entries != nil {
+			if err := c.entries.Put(context.Background(), &physical.Entry{Key: s.Key, Value: s.Value}); err != nil {
+				return info, err
+			}
+		}
 	}
 
 	return info, nil
@@ -326,6 +399,17 @@ func formatTable(info *OutputFormat) (string, error) {
 		fmt.Fprintf(tw, "\n Total Size\t\t%s", ByteSize(uint64(info.TotalSizeKV)))
 	}
 
+	if info.Keys != nil {
+		fmt.Fprintf(tw, "\n")
+		fmt.Fprintln(tw, "\n Key\tNamespace\tMount\tType\tName")
+		fmt.Fprintf(tw, " %s\t%s\t%s\t%s\t%s", "----", "----", "----", "----", "----")
+
+		for _, k := range info.Keys {
+			fmt.Fprintf(tw, "\n %s\t%s\t%s\t%s\t%s", k.Key, k.Namespace, k.Mount, k.Type, k.Name)
+		}
+		fmt.Fprintf(tw, "\n")
+	}
+
 	if err := tw.Flush(); err != nil {
 		return b.String(), err
 	}
@@ -5,7 +5,6 @@ package command
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/hashicorp/cli"
@@ -19,6 +18,7 @@ var (
 
 type OperatorRaftSnapshotRestoreCommand struct {
 	flagForce bool
+	envelope  snapshotEnvelopeOpenFlags
 	*BaseCommand
 }
 
@@ -34,6 +34,10 @@ Usage: vault operator raft snapshot restore <snapshot_file>
 
 	  $ vault operator raft snapshot restore raft.snap
 
+  Restore a snapshot wrapped in an envelope, verifying its signature first:
+
+	  $ vault operator raft snapshot restore -pgp-private-key=ops.key -verify-key=sign.pub.pem raft.snap
+
 ` + c.Flags().Help()
 
 	return strings.TrimSpace(helpText)
@@ -51,6 +55,8 @@ func (c *OperatorRaftSnapshotRestoreCommand) Flags() *FlagSets {
 		Usage:   "This bypasses checks ensuring the Autounseal or shamir keys are consistent with the snapshot data.",
 	})
 
+	c.envelope.addFlags(f, c.Client)
+
 	return set
 }
 
@@ -86,9 +92,9 @@ func (c *OperatorRaftSnapshotRestoreCommand) Run(args []string) int {
 		return 1
 	}
 
-	snapReader, err := os.Open(snapFile)
+	snapReader, err := c.envelope.open(snapFile)
 	if err != nil {
-		c.UI.Error(fmt.Sprintf("Error opening policy file: %s", err))
+		c.UI.Error(fmt.Sprintf("Error opening snapshot file: %s", err))
 		return 2
 	}
 	defer snapReader.Close()
@@ -10,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/hashicorp/cli"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/physical/raft"
 	"github.com/posener/complete"
 )
 
@@ -20,6 +22,7 @@ var (
 
 type OperatorRaftSnapshotSaveCommand struct {
 	*BaseCommand
+	envelope snapshotEnvelopeSealFlags
 }
 
 func (c *OperatorRaftSnapshotSaveCommand) Synopsis() string {
@@ -34,6 +37,10 @@ Usage: vault operator raft snapshot save <snapshot_file>
 
 	  $ vault operator raft snapshot save raft.snap
 
+  Wrap the snapshot in an envelope encrypted with a transit data key and signed:
+
+	  $ vault operator raft snapshot save -transit-key=transit/snapshots -signing-key=sign.pem raft.snap
+
 ` + c.Flags().Help()
 
 	return strings.TrimSpace(helpText)
@@ -42,6 +49,10 @@ Usage: vault operator raft snapshot save <snapshot_file>
 func (c *OperatorRaftSnapshotSaveCommand) Flags() *FlagSets {
 	set := c.flagSet(FlagSetHTTP | FlagSetOutputFormat)
 
+	f := set.NewFlagSet("Command Options")
+
+	c.envelope.addFlags(f, c.Client)
+
 	return set
 }
 
@@ -77,6 +88,16 @@ func (c *OperatorRaftSnapshotSaveCommand) Run(args []string) int {
 		return 1
 	}
 
+	var envelopeOpts *raft.SnapshotEnvelopeSealOptions
+	if c.envelope.enabled() {
+		var err error
+		envelopeOpts, err = c.envelope.options()
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+	}
+
 	w := &lazyOpenWriter{
 		openFunc: func() (io.WriteCloser, error) {
 			return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
@@ -90,7 +111,11 @@ func (c *OperatorRaftSnapshotSaveCommand) Run(args []string) int {
 		return 2
 	}
 
-	err = client.Sys().RaftSnapshot(w)
+	if envelopeOpts != nil {
+		err = c.saveEnvelope(client, w, envelopeOpts)
+	} else {
+		err = client.Sys().RaftSnapshot(w)
+	}
 	if err != nil {
 		w.Close()
 		c.UI.Error(fmt.Sprintf("Error taking the snapshot: %s", err))
@@ -105,6 +130,25 @@ func (c *OperatorRaftSnapshotSaveCommand) Run(args []string) int {
 	return 0
 }
 
+// saveEnvelope buffers the snapshot in a temporary file before wrapping it, so
+// that nothing is written to the output file if taking the snapshot fails.
+func (c *OperatorRaftSnapshotSaveCommand) saveEnvelope(client *api.Client, w io.Writer, opts *raft.SnapshotEnvelopeSealOptions) error {
+	tmp, err := os.CreateTemp("", "vault-snapshot")
+	if err != nil {
+		return err
+	}
+	snap := &tempSnapshotFile{File: tmp}
+	defer snap.Close()
+
+	if err := client.Sys().RaftSnapshot(tmp); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return raft.WriteSnapshotEnvelope(w, tmp, opts)
+}
+
 type lazyOpenWriter struct {
 	openFunc func() (io.WriteCloser, error)
 	writer   io.WriteCloser
@@ -0,0 +1,42 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"strings"
+
+	"github.com/hashicorp/cli"
+)
+
+var _ cli.Command = (*OperatorStorageCommand)(nil)
+
+type OperatorStorageCommand struct {
+	*BaseCommand
+}
+
+func (c *OperatorStorageCommand) Synopsis() string {
+	return "Inspect the data in Vault's storage backend"
+}
+
+func (c *OperatorStorageCommand) Help() string {
+	helpText := `
+Usage: vault operator storage <subcommand> [options] [args]
+
+  This command groups subcommands for operators inspecting the data Vault keeps
+  in its storage backend.
+
+  Verifies that every entry can be decrypted and that the indexes are
+  consistent:
+
+      $ vault operator storage verify
+
+  Please see the individual subcommand help for detailed usage information.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorStorageCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
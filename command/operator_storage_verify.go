@@ -0,0 +1,231 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/cli"
+	"github.com/hashicorp/vault/api"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*OperatorStorageVerifyCommand)(nil)
+	_ cli.CommandAutocomplete = (*OperatorStorageVerifyCommand)(nil)
+)
+
+type OperatorStorageVerifyCommand struct {
+	*BaseCommand
+
+	ShutdownCh chan struct{}
+
+	flagRepair       bool
+	flagStatus       bool
+	flagPollInterval time.Duration
+}
+
+func (c *OperatorStorageVerifyCommand) Synopsis() string {
+	return "Verifies the integrity of the data in storage"
+}
+
+func (c *OperatorStorageVerifyCommand) Help() string {
+	helpText := `
+Usage: vault operator storage verify [options]
+
+  Makes the active node decrypt every entry in storage and check that the mount
+  tables, the token and lease indexes and the identity storage buckets are
+  consistent with each other, then prints the issues found. The verification
+  runs on the server; this command waits for it to finish.
+
+  Verify the data in storage:
+
+      $ vault operator storage verify
+
+  Verify the data and repair the index entries that can be fixed:
+
+      $ vault operator storage verify -repair
+
+  Print the report of the last verification without starting a new one:
+
+      $ vault operator storage verify -status
+
+  This command requires a sudo token.
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorStorageVerifyCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP | FlagSetOutputFormat)
+
+	f := set.NewFlagSet("Command Options")
+
+	f.BoolVar(&BoolVar{
+		Name:    "repair",
+		Target:  &c.flagRepair,
+		Default: false,
+		Usage: "Remove index entries that refer to missing tokens or leases and " +
+			"recreate missing index entries. Nothing is repaired if any entry " +
+			"fails to decrypt.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "status",
+		Target:  &c.flagStatus,
+		Default: false,
+		Usage:   "Print the report of the last verification instead of starting a new one.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:       "poll-interval",
+		Target:     &c.flagPollInterval,
+		Default:    2 * time.Second,
+		Completion: complete.PredictAnything,
+		Usage:      "Interval at which the progress of the verification is checked.",
+	})
+
+	return set
+}
+
+func (c *OperatorStorageVerifyCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *OperatorStorageVerifyCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *OperatorStorageVerifyCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) > 0 {
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 0, got %d)", len(args)))
+		return 1
+	}
+	if c.flagStatus && c.flagRepair {
+		c.UI.Error("The -status and -repair flags cannot be used together")
+		return 1
+	}
+	if c.flagPollInterval <= 0 {
+		c.UI.Error("The -poll-interval flag must be positive")
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	if !c.flagStatus {
+		if err := client.Sys().StorageVerifyStart(c.flagRepair); err != nil {
+			c.UI.Error(fmt.Sprintf("Error starting storage verification: %s", err))
+			return 2
+		}
+	}
+
+	var status *api.StorageVerifyStatus
+	for {
+		status, err = client.Sys().StorageVerifyStatus()
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error reading storage verification status: %s", err))
+			return 2
+		}
+		if c.flagStatus || status.State != "running" {
+			break
+		}
+
+		select {
+		case <-c.ShutdownCh:
+			c.UI.Warn("Stopped waiting; the verification is still running on the server")
+			return 1
+		case <-time.After(c.flagPollInterval):
+		}
+	}
+
+	if Format(c.UI) != "table" {
+		if ret := OutputData(c.UI, status); ret != 0 {
+			return ret
+		}
+	} else {
+		c.outputStatus(status)
+	}
+
+	if status.State == "failed" {
+		return 2
+	}
+	var issues uint64
+	for _, count := range status.IssueCounts {
+		issues += count
+	}
+	if issues > status.Repaired {
+		return 2
+	}
+	return 0
+}
+
+func (c *OperatorStorageVerifyCommand) outputStatus(status *api.StorageVerifyStatus) {
+	out := []string{
+		"Key | Value",
+		fmt.Sprintf("State | %s", status.State),
+		fmt.Sprintf("Repair | %t", status.Repair),
+	}
+	if !status.StartTime.IsZero() {
+		out = append(out, fmt.Sprintf("Start Time | %s", status.StartTime.Format(time.RFC3339)))
+	}
+	if !status.EndTime.IsZero() {
+		out = append(out, fmt.Sprintf("End Time | %s", status.EndTime.Format(time.RFC3339)))
+	}
+	out = append(out, fmt.Sprintf("Entries Scanned | %d", status.EntriesScanned))
+
+	terms := make([]string, 0, len(status.TermCounts))
+	for term, count := range status.TermCounts {
+		terms = append(terms, fmt.Sprintf("%s: %d", term, count))
+	}
+	sort.Strings(terms)
+	out = append(out, fmt.Sprintf("Entries per Term | %s", strings.Join(terms, ", ")))
+
+	kinds := make([]string, 0, len(status.IssueCounts))
+	for kind, count := range status.IssueCounts {
+		kinds = append(kinds, fmt.Sprintf("%s: %d", kind, count))
+	}
+	sort.Strings(kinds)
+	out = append(out, fmt.Sprintf("Issues | %s", strings.Join(kinds, ", ")))
+	if status.Repair {
+		out = append(out, fmt.Sprintf("Repaired | %d", status.Repaired))
+	}
+	if status.Error != "" {
+		out = append(out, fmt.Sprintf("Error | %s", status.Error))
+	}
+	c.UI.Output(tableOutput(out, nil))
+
+	if status.RepairSkipped {
+		c.UI.Warn("\nSome entries could not be decrypted, so nothing was repaired.")
+	}
+
+	if len(status.Issues) == 0 {
+		return
+	}
+
+	c.UI.Output("")
+	issues := []string{"Check | Kind | Key | Repaired | Detail"}
+	for _, issue := range status.Issues {
+		issues = append(issues, fmt.Sprintf("%s | %s | %s | %t | %s", issue.Check, issue.Kind, issue.Key, issue.Repaired, issue.Detail))
+	}
+	c.UI.Output(tableOutput(issues, nil))
+	if status.IssuesTruncated {
+		c.UI.Warn("\nOnly the first issues are listed; see the issue counts for the totals.")
+	}
+}
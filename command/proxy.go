@@ -29,9 +29,12 @@ import (
 	"github.com/hashicorp/vault/command/agentproxyshared"
 	"github.com/hashicorp/vault/command/agentproxyshared/auth"
 	"github.com/hashicorp/vault/command/agentproxyshared/cache"
+	"github.com/hashicorp/vault/command/agentproxyshared/failover"
+	"github.com/hashicorp/vault/command/agentproxyshared/health"
 	"github.com/hashicorp/vault/command/agentproxyshared/sink"
 	"github.com/hashicorp/vault/command/agentproxyshared/sink/file"
 	"github.com/hashicorp/vault/command/agentproxyshared/sink/inmem"
+	"github.com/hashicorp/vault/command/agentproxyshared/sink/socket"
 	"github.com/hashicorp/vault/command/agentproxyshared/winsvc"
 	proxyConfig "github.com/hashicorp/vault/command/proxy/config"
 	"github.com/hashicorp/vault/helper/logging"
@@ -284,6 +287,32 @@ func (c *ProxyCommand) Run(args []string) int {
 	}
 	c.metricsHelper = metricsutil.NewMetricsHelper(inmemMetrics, prometheusEnabled)
 
+	// Fail over between the Vault servers when more than one is configured
+	var addressSelector *failover.AddressSelector
+	var failoverFunc cache.FailoverFunc
+	if config.Vault != nil && len(config.Vault.Addresses) > 0 {
+		addressSelector, err = failover.NewAddressSelector(&failover.AddressSelectorConfig{
+			Logger:              c.logger.Named("failover"),
+			Client:              client,
+			Addresses:           append([]string{config.Vault.Address}, config.Vault.Addresses...),
+			HealthCheckInterval: config.Vault.HealthCheckInterval,
+			MetricsSignifier:    "proxy",
+		})
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error creating Vault address selector: %v", err))
+			return 1
+		}
+		addressSelector.Check(context.Background())
+		config.Vault.Address = addressSelector.Address()
+		if err := addressSelector.AddClient(client); err != nil {
+			c.UI.Error(fmt.Sprintf("Error setting Vault address: %v", err))
+			return 1
+		}
+		failoverFunc = addressSelector.Failover
+		info["vault addresses"] = strings.Join(addressSelector.Addresses(), ", ")
+		infoKeys = append(infoKeys, "vault addresses")
+	}
+
 	// This indicates whether the namespace for the client has been set by environment variable.
 	// If it has, we don't touch it
 	namespaceSetByEnvironmentVariable := client.Namespace() != ""
@@ -293,6 +322,7 @@ func (c *ProxyCommand) Run(args []string) int {
 	}
 
 	var method auth.AuthMethod
+	var fallbackMethods []*auth.FallbackAuthMethod
 	var sinks []*sink.SinkConfig
 	if config.AutoAuth != nil {
 		// Note: This will only set namespace header to the value in config.AutoAuth.Method.Namespace
@@ -316,6 +346,13 @@ func (c *ProxyCommand) Run(args []string) int {
 			sinkClient.SetDisableKeepAlives(true)
 		}
 
+		if addressSelector != nil {
+			if err := addressSelector.AddClient(sinkClient); err != nil {
+				c.UI.Error(fmt.Sprintf("Error setting Vault address for file sink: %v", err))
+				return 1
+			}
+		}
+
 		for _, sc := range config.AutoAuth.Sinks {
 			switch sc.Type {
 			case "file":
@@ -336,6 +373,29 @@ func (c *ProxyCommand) Run(args []string) int {
 				}
 				config.Sink = s
 				sinks = append(sinks, config)
+			case "socket", "http":
+				config := &sink.SinkConfig{
+					Logger:    c.logger.Named("sink." + sc.Type),
+					Config:    sc.Config,
+					Client:    sinkClient,
+					WrapTTL:   sc.WrapTTL,
+					DHType:    sc.DHType,
+					DeriveKey: sc.DeriveKey,
+					DHPath:    sc.DHPath,
+					AAD:       sc.AAD,
+				}
+				newSink := socket.NewSocketSink
+				if sc.Type == "http" {
+					newSink = socket.NewHTTPSink
+				}
+				s, err := newSink(config)
+				if err != nil {
+					c.UI.Error(fmt.Errorf("error creating %s sink: %w", sc.Type, err).Error())
+					return 1
+				}
+				defer s.(io.Closer).Close()
+				config.Sink = s
+				sinks = append(sinks, config)
 			default:
 				c.UI.Error(fmt.Sprintf("Unknown sink type %q", sc.Type))
 				return 1
@@ -352,6 +412,30 @@ func (c *ProxyCommand) Run(args []string) int {
 			c.UI.Error(fmt.Sprintf("Error creating %s auth method: %v", config.AutoAuth.Method.Type, err))
 			return 1
 		}
+
+		for _, fm := range config.AutoAuth.FallbackMethods {
+			authConfig := &auth.AuthConfig{
+				Logger:    c.logger.Named(fmt.Sprintf("auth.%s", fm.Type)),
+				MountPath: fm.MountPath,
+				Config:    fm.Config,
+			}
+			fallbackMethod, err := agentproxyshared.GetAutoAuthMethodFromConfig(fm.Type, authConfig, config.Vault.Address)
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error creating %s auth method: %v", fm.Type, err))
+				return 1
+			}
+			fallbackNamespace := fm.Namespace
+			if namespaceSetByEnvironmentVariable {
+				fallbackNamespace = ""
+			}
+			fallbackMethods = append(fallbackMethods, &auth.FallbackAuthMethod{
+				Name:       fm.MountPath,
+				Method:     fallbackMethod,
+				Namespace:  fallbackNamespace,
+				MinBackoff: fm.MinBackoff,
+				MaxBackoff: fm.MaxBackoff,
+			})
+		}
 	}
 
 	// We do this after auto-auth has been configured, because we don't want to
@@ -428,8 +512,23 @@ func (c *ProxyCommand) Run(args []string) int {
 		proxyClient.SetDisableKeepAlives(true)
 	}
 
+	if addressSelector != nil {
+		if err := addressSelector.AddClient(proxyClient); err != nil {
+			c.UI.Error(fmt.Sprintf("Error setting Vault address for proxying: %v", err))
+			return 1
+		}
+	}
+
 	apiProxyLogger := c.logger.Named("apiproxy")
 
+	// The request policies check the paths with the namespace the API proxy
+	// prepends, if any
+	prependConfiguredNamespace := config.APIProxy != nil && config.APIProxy.PrependConfiguredNamespace
+	var prependNamespace string
+	if prependConfiguredNamespace {
+		prependNamespace = proxyClient.Namespace()
+	}
+
 	// The API proxy to be used, if listeners are configured
 	apiProxy, err := cache.NewAPIProxy(&cache.APIProxyConfig{
 		Client:                     proxyClient,
@@ -438,7 +537,8 @@ func (c *ProxyCommand) Run(args []string) int {
 		WhenInconsistentAction:     whenInconsistent,
 		UserAgentStringFunction:    useragent.ProxyStringWithProxiedUserAgent,
 		UserAgentString:            useragent.ProxyAPIProxyString(),
-		PrependConfiguredNamespace: config.APIProxy != nil && config.APIProxy.PrependConfiguredNamespace,
+		PrependConfiguredNamespace: prependConfiguredNamespace,
+		Failover:                   failoverFunc,
 	})
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error creating API proxy: %v", err))
@@ -468,6 +568,11 @@ func (c *ProxyCommand) Run(args []string) int {
 			// dynamic secrets are configured as default-on to preserve backwards compatibility
 			CacheDynamicSecrets: !config.Cache.DisableCachingDynamicSecrets,
 			UserAgentToUse:      useragent.AgentProxyString(),
+			Failover:            failoverFunc,
+
+			StaticSecretMountTypes:  config.Cache.StaticSecretMountTypes,
+			StaticSecretMemoryLimit: config.Cache.StaticSecretMemoryLimit,
+			MetricsSignifier:        "proxy",
 		})
 		if err != nil {
 			c.UI.Error(fmt.Sprintf("Error creating lease cache: %v", err))
@@ -478,7 +583,7 @@ func (c *ProxyCommand) Run(args []string) int {
 
 		// Configure persistent storage and add to LeaseCache
 		if config.Cache.Persist != nil {
-			deferFunc, oldToken, err := agentproxyshared.AddPersistentStorageToLeaseCache(ctx, leaseCache, config.Cache.Persist, cacheLogger)
+			deferFunc, oldToken, err := agentproxyshared.AddPersistentStorageToLeaseCache(ctx, leaseCache, config.Cache.Persist, c.client, cacheLogger)
 			if err != nil {
 				c.UI.Error(fmt.Sprintf("Error creating persistent cache: %v", err))
 				return 1
@@ -505,10 +610,11 @@ func (c *ProxyCommand) Run(args []string) int {
 			})
 
 			updater, err = cache.NewStaticSecretCacheUpdater(&cache.StaticSecretCacheUpdaterConfig{
-				Client:     client,
-				LeaseCache: leaseCache,
-				Logger:     staticSecretCacheUpdaterLogger,
-				TokenSink:  inmemSink,
+				Client:       client,
+				LeaseCache:   leaseCache,
+				Logger:       staticSecretCacheUpdaterLogger,
+				TokenSink:    inmemSink,
+				PrewarmPaths: config.Cache.StaticSecretPrewarmPaths,
 			})
 			if err != nil {
 				c.UI.Error(fmt.Sprintf("Error creating static secret cache updater: %v", err))
@@ -529,6 +635,14 @@ func (c *ProxyCommand) Run(args []string) int {
 		}
 	}
 
+	healthReporter := health.NewReporter(c.logger.Named("health"))
+	if leaseCache != nil {
+		healthReporter.Register("cache", leaseCache)
+	}
+	if updater != nil {
+		healthReporter.Register("static_secret_cache_updater", updater)
+	}
+
 	var listeners []net.Listener
 
 	// Ensure we've added all the reload funcs for TLS before anyone triggers a reload.
@@ -584,9 +698,21 @@ func (c *ProxyCommand) Run(args []string) int {
 
 		var muxHandler http.Handler
 		if leaseCache != nil {
-			muxHandler = cache.ProxyHandler(ctx, apiProxyLogger, leaseCache, inmemSink, proxyVaultToken)
+			muxHandler = cache.ProxyHandler(ctx, apiProxyLogger, leaseCache, inmemSink, proxyVaultToken, "proxy")
 		} else {
-			muxHandler = cache.ProxyHandler(ctx, apiProxyLogger, apiProxy, inmemSink, proxyVaultToken)
+			muxHandler = cache.ProxyHandler(ctx, apiProxyLogger, apiProxy, inmemSink, proxyVaultToken, "proxy")
+		}
+
+		// Only proxy the requests allowed by the request policy of the
+		// listener, if any
+		requestPolicy, err := loadRequestPolicy(lnConfig.ProxyAPI, prependNamespace)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error loading request policy of listener %d: %v", i+1, err))
+			c.tlsReloadFuncsLock.Unlock()
+			return 1
+		}
+		if requestPolicy != nil {
+			muxHandler = cache.RequestPolicyHandler(apiProxyLogger.Named("requestpolicy"), requestPolicy, muxHandler)
 		}
 
 		// Parse 'require_request_header' listener config option, and wrap
@@ -600,6 +726,7 @@ func (c *ProxyCommand) Run(args []string) int {
 		quitEnabled := lnConfig.ProxyAPI != nil && lnConfig.ProxyAPI.EnableQuit
 
 		mux.Handle(consts.ProxyPathMetrics, c.handleMetrics())
+		mux.Handle(consts.ProxyPathHealth, healthReporter.Handler())
 		if "metrics_only" != lnConfig.Role {
 			mux.Handle(consts.ProxyPathCacheClear, leaseCache.HandleCacheClear(ctx))
 			mux.Handle(consts.ProxyPathQuit, c.handleQuit(quitEnabled))
@@ -690,6 +817,20 @@ func (c *ProxyCommand) Run(args []string) int {
 		}
 	}, func(error) {})
 
+	g.Add(func() error {
+		return healthReporter.Run(ctx)
+	}, func(error) {
+		cancelFunc()
+	})
+
+	if addressSelector != nil {
+		g.Add(func() error {
+			return addressSelector.Run(ctx)
+		}, func(error) {
+			cancelFunc()
+		})
+	}
+
 	// Start auto-auth and sink servers
 	if method != nil {
 		// Auth Handler is going to set its own retry values, so we want to
@@ -713,6 +854,13 @@ func (c *ProxyCommand) Run(args []string) int {
 			ahClient.SetDisableKeepAlives(true)
 		}
 
+		if addressSelector != nil {
+			if err := addressSelector.AddClient(ahClient); err != nil {
+				c.UI.Error(fmt.Sprintf("Error setting Vault address for auth handler: %v", err))
+				return 1
+			}
+		}
+
 		ah := auth.NewAuthHandler(&auth.AuthHandlerConfig{
 			Logger:                       c.logger.Named("auth.handler"),
 			Client:                       ahClient,
@@ -722,9 +870,11 @@ func (c *ProxyCommand) Run(args []string) int {
 			EnableReauthOnNewCredentials: config.AutoAuth.EnableReauthOnNewCredentials,
 			Token:                        previousToken,
 			ExitOnError:                  config.AutoAuth.Method.ExitOnError,
+			FallbackMethods:              fallbackMethods,
 			UserAgent:                    useragent.ProxyAutoAuthString(),
 			MetricsSignifier:             "proxy",
 		})
+		healthReporter.Register("auth", ah)
 
 		ss := sink.NewSinkServer(&sink.SinkServerConfig{
 			Logger:        c.logger.Named("sink.server"),
@@ -987,6 +1137,29 @@ func (c *ProxyCommand) removePidFile(pidPath string) error {
 	return os.Remove(pidPath)
 }
 
+// loadRequestPolicy returns the request policy configured in the proxy_api
+// block of a listener, or nil if there is none. prependNamespace is the
+// namespace the API proxy prepends to the namespace of the requests.
+func loadRequestPolicy(proxyAPI *configutil.ProxyAPI, prependNamespace string) (*cache.RequestPolicy, error) {
+	if proxyAPI == nil {
+		return nil, nil
+	}
+
+	rules := proxyAPI.RequestPolicy
+	if proxyAPI.RequestPolicyFile != "" {
+		b, err := os.ReadFile(proxyAPI.RequestPolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = string(b)
+	}
+	if rules == "" {
+		return nil, nil
+	}
+
+	return cache.NewRequestPolicy(rules, prependNamespace)
+}
+
 func (c *ProxyCommand) handleMetrics() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -79,6 +80,12 @@ type Vault struct {
 	TLSServerName    string      `hcl:"tls_server_name"`
 	Namespace        string      `hcl:"namespace"`
 	Retry            *Retry      `hcl:"retry"`
+
+	// Addresses are the addresses of the Vault servers to fail over between.
+	// The healthiest of them, as reported by sys/health, is used.
+	Addresses              []string      `hcl:"addresses"`
+	HealthCheckInterval    time.Duration `hcl:"-"`
+	HealthCheckIntervalRaw interface{}   `hcl:"health_check_interval"`
 }
 
 // transportDialer is an interface that allows passing a custom dialer function
@@ -109,12 +116,19 @@ type Cache struct {
 	DisableCachingDynamicSecrets                  bool                            `hcl:"disable_caching_dynamic_secrets"`
 	StaticSecretTokenCapabilityRefreshIntervalRaw interface{}                     `hcl:"static_secret_token_capability_refresh_interval"`
 	StaticSecretTokenCapabilityRefreshInterval    time.Duration                   `hcl:"-"`
+	StaticSecretMountTypes                        []string                        `hcl:"static_secret_mount_types"`
+	StaticSecretPrewarmPaths                      []string                        `hcl:"static_secret_prewarm_paths"`
+	StaticSecretMemoryLimitRaw                    interface{}                     `hcl:"static_secret_memory_limit"`
+	StaticSecretMemoryLimit                       uint64                          `hcl:"-"`
 }
 
 // AutoAuth is the configured authentication method and sinks
 type AutoAuth struct {
 	Method *Method `hcl:"-"`
-	Sinks  []*Sink `hcl:"sinks"`
+	// FallbackMethods are used, in order, when the methods before them fail
+	// to authenticate. They are the method blocks after the first one.
+	FallbackMethods []*Method `hcl:"-"`
+	Sinks           []*Sink   `hcl:"sinks"`
 
 	// NOTE: This is unsupported outside of testing and may disappear at any
 	// time.
@@ -263,6 +277,11 @@ func (c *Config) ValidateConfig() error {
 		return fmt.Errorf("cache.cache_static_secrets=true requires an auto-auth block configured, to use the token to connect with Vault's event system")
 	}
 
+	if c.Cache != nil && !c.Cache.CacheStaticSecrets &&
+		(len(c.Cache.StaticSecretMountTypes) > 0 || len(c.Cache.StaticSecretPrewarmPaths) > 0 || c.Cache.StaticSecretMemoryLimit > 0) {
+		return fmt.Errorf("cache.static_secret_mount_types, cache.static_secret_prewarm_paths and cache.static_secret_memory_limit require cache.cache_static_secrets=true")
+	}
+
 	if c.Cache != nil && !c.Cache.CacheStaticSecrets && c.Cache.DisableCachingDynamicSecrets {
 		return fmt.Errorf("to enable the cache, the cache must be configured to either cache static secrets or dynamic secrets")
 	}
@@ -271,6 +290,12 @@ func (c *Config) ValidateConfig() error {
 		return fmt.Errorf("no auto_auth, cache, or listener block found in config")
 	}
 
+	for _, l := range c.Listeners {
+		if l.ProxyAPI != nil && l.ProxyAPI.RequestPolicy != "" && l.ProxyAPI.RequestPolicyFile != "" {
+			return fmt.Errorf("at most one of proxy_api.request_policy and proxy_api.request_policy_file can be specified for listener %q", l.Address)
+		}
+	}
+
 	return nil
 }
 
@@ -516,6 +541,27 @@ func parseVault(result *Config, list *ast.ObjectList) error {
 		}
 	}
 
+	if v.HealthCheckIntervalRaw != nil {
+		v.HealthCheckInterval, err = parseutil.ParseDurationSecond(v.HealthCheckIntervalRaw)
+		if err != nil {
+			return fmt.Errorf("error parsing 'health_check_interval': %w", err)
+		}
+		v.HealthCheckIntervalRaw = nil
+	}
+
+	for _, addr := range v.Addresses {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return fmt.Errorf("error parsing 'addresses': %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid address %q in 'addresses': scheme must be http or https", addr)
+		}
+	}
+	if v.Address == "" && len(v.Addresses) > 0 {
+		v.Address = v.Addresses[0]
+	}
+
 	result.Vault = &v
 
 	subs, ok := item.Val.(*ast.ObjectType)
@@ -638,6 +684,14 @@ func parseCache(result *Config, list *ast.ObjectList) error {
 		result.Cache.StaticSecretTokenCapabilityRefreshIntervalRaw = nil
 	}
 
+	if result.Cache.StaticSecretMemoryLimitRaw != nil {
+		var err error
+		if result.Cache.StaticSecretMemoryLimit, err = parseutil.ParseCapacityString(result.Cache.StaticSecretMemoryLimitRaw); err != nil {
+			return fmt.Errorf("error parsing static_secret_memory_limit, must be provided as a capacity string: %w", err)
+		}
+		result.Cache.StaticSecretMemoryLimitRaw = nil
+	}
+
 	return nil
 }
 
@@ -723,22 +777,6 @@ func parseAutoAuth(result *Config, list *ast.ObjectList) error {
 		}
 	}
 
-	if result.AutoAuth.Method.MaxBackoffRaw != nil {
-		var err error
-		if result.AutoAuth.Method.MaxBackoff, err = parseutil.ParseDurationSecond(result.AutoAuth.Method.MaxBackoffRaw); err != nil {
-			return err
-		}
-		result.AutoAuth.Method.MaxBackoffRaw = nil
-	}
-
-	if result.AutoAuth.Method.MinBackoffRaw != nil {
-		var err error
-		if result.AutoAuth.Method.MinBackoff, err = parseutil.ParseDurationSecond(result.AutoAuth.Method.MinBackoffRaw); err != nil {
-			return err
-		}
-		result.AutoAuth.Method.MinBackoffRaw = nil
-	}
-
 	return nil
 }
 
@@ -746,16 +784,37 @@ func parseMethod(result *Config, list *ast.ObjectList) error {
 	name := "method"
 
 	methodList := list.Filter(name)
-	if len(methodList.Items) != 1 {
-		return fmt.Errorf("one and only one %q block is required", name)
+	if len(methodList.Items) < 1 {
+		return fmt.Errorf("at least one %q block is required", name)
 	}
 
-	// Get our item
-	item := methodList.Items[0]
+	// The first method is the primary one, the others are fallbacks in the
+	// order they are defined
+	for i, item := range methodList.Items {
+		m, err := parseMethodItem(item)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			result.AutoAuth.Method = m
+			continue
+		}
 
+		if m.WrapTTL > 0 {
+			return fmt.Errorf("method.%s: 'wrap_ttl' can only be set on the first method", m.Type)
+		}
+		if m.ExitOnError {
+			return fmt.Errorf("method.%s: 'exit_on_err' can only be set on the first method", m.Type)
+		}
+		result.AutoAuth.FallbackMethods = append(result.AutoAuth.FallbackMethods, m)
+	}
+	return nil
+}
+
+func parseMethodItem(item *ast.ObjectItem) (*Method, error) {
 	var m Method
 	if err := hcl.DecodeObject(&m, item.Val); err != nil {
-		return err
+		return nil, err
 	}
 
 	if m.Type == "" {
@@ -763,7 +822,7 @@ func parseMethod(result *Config, list *ast.ObjectList) error {
 			m.Type = strings.ToLower(item.Keys[0].Token.Value().(string))
 		}
 		if m.Type == "" {
-			return errors.New("method type must be specified")
+			return nil, errors.New("method type must be specified")
 		}
 	}
 
@@ -777,16 +836,31 @@ func parseMethod(result *Config, list *ast.ObjectList) error {
 	if m.WrapTTLRaw != nil {
 		var err error
 		if m.WrapTTL, err = parseutil.ParseDurationSecond(m.WrapTTLRaw); err != nil {
-			return err
+			return nil, err
 		}
 		m.WrapTTLRaw = nil
 	}
 
+	if m.MaxBackoffRaw != nil {
+		var err error
+		if m.MaxBackoff, err = parseutil.ParseDurationSecond(m.MaxBackoffRaw); err != nil {
+			return nil, err
+		}
+		m.MaxBackoffRaw = nil
+	}
+
+	if m.MinBackoffRaw != nil {
+		var err error
+		if m.MinBackoff, err = parseutil.ParseDurationSecond(m.MinBackoffRaw); err != nil {
+			return nil, err
+		}
+		m.MinBackoffRaw = nil
+	}
+
 	// Canonicalize namespace path if provided
 	m.Namespace = namespace.Canonicalize(m.Namespace)
 
-	result.AutoAuth.Method = &m
-	return nil
+	return &m, nil
 }
 
 func parseSinks(result *Config, list *ast.ObjectList) error {
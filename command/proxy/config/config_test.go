@@ -203,3 +203,140 @@ func TestLoadConfigFile_ProxyCacheStaticSecrets(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+// TestLoadConfigFile_ProxyCacheStaticSecretOptions tests loading a config
+// file with the mount types, prewarm paths, and memory limit of static secret
+// caching.
+func TestLoadConfigFile_ProxyCacheStaticSecretOptions(t *testing.T) {
+	config, err := LoadConfigFile("./test-fixtures/config-cache-static-secret-cache-options.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.ValidateConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Cache{
+		CacheStaticSecrets:                         true,
+		StaticSecretTokenCapabilityRefreshInterval: 1 * time.Hour,
+		StaticSecretMountTypes:                     []string{"kv", "database"},
+		StaticSecretPrewarmPaths:                   []string{"secret/data/app/", "database/static-creds/app"},
+		StaticSecretMemoryLimit:                    64 * 1024 * 1024,
+	}
+	if diff := deep.Equal(config.Cache, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+// TestLoadConfigFile_Bad_ProxyCacheStaticSecretOptions tests that the options
+// of static secret caching require static secret caching to be enabled.
+func TestLoadConfigFile_Bad_ProxyCacheStaticSecretOptions(t *testing.T) {
+	config, err := LoadConfigFile("./test-fixtures/bad-config-cache-static-secret-options.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := config.ValidateConfig(); err == nil {
+		t.Fatal("expected error, as the static secret options require static secret caching")
+	}
+}
+
+// TestLoadConfigFile_AutoAuthFallback tests loading a config file with
+// several auto-auth methods, the ones after the first being fallbacks.
+func TestLoadConfigFile_AutoAuthFallback(t *testing.T) {
+	config, err := LoadConfigFile("./test-fixtures/config-auto-auth-fallback.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &AutoAuth{
+		Method: &Method{
+			Type:      "aws",
+			MountPath: "auth/aws",
+			Config: map[string]interface{}{
+				"role": "foobar",
+			},
+		},
+		FallbackMethods: []*Method{
+			{
+				Type:       "approle",
+				MountPath:  "auth/approle",
+				MaxBackoff: 30 * time.Second,
+				Config: map[string]interface{}{
+					"role_id_file_path":   "/tmp/role-id",
+					"secret_id_file_path": "/tmp/secret-id",
+				},
+			},
+		},
+	}
+
+	config.Prune()
+	if diff := deep.Equal(config.AutoAuth, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestLoadConfigFile_VaultAddresses(t *testing.T) {
+	config, err := LoadConfigFile("./test-fixtures/config-vault-addresses.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Vault{
+		Address:             "https://vault-1:8200",
+		Addresses:           []string{"https://vault-1:8200", "https://vault-2:8200"},
+		HealthCheckInterval: 30 * time.Second,
+		Retry: &Retry{
+			NumRetries: 12,
+		},
+	}
+
+	config.Prune()
+	if diff := deep.Equal(config.Vault, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+// TestLoadConfigFile_ListenerRequestPolicy tests loading the request policies
+// of listeners.
+func TestLoadConfigFile_ListenerRequestPolicy(t *testing.T) {
+	cfg, err := LoadConfigFile("./test-fixtures/config-listener-request-policy.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.ValidateConfig(); err != nil {
+		t.Fatalf("validation error: %s", err)
+	}
+
+	if len(cfg.Listeners) != 2 {
+		t.Fatalf("expected 2 listeners, got %d", len(cfg.Listeners))
+	}
+
+	expected := &configutil.ProxyAPI{
+		RequestPolicyFile: "/etc/vault-proxy/request-policy.hcl",
+	}
+	if diff := deep.Equal(cfg.Listeners[0].ProxyAPI, expected); diff != nil {
+		t.Fatal(diff)
+	}
+
+	expected = &configutil.ProxyAPI{
+		RequestPolicy: "path \"secret/data/app/*\" {\n  capabilities = [\"read\"]\n}\n",
+	}
+	if diff := deep.Equal(cfg.Listeners[1].ProxyAPI, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+// TestLoadConfigFile_Bad_ListenerRequestPolicy tests that a listener cannot
+// specify both an inline request policy and a request policy file.
+func TestLoadConfigFile_Bad_ListenerRequestPolicy(t *testing.T) {
+	cfg, err := LoadConfigFile("./test-fixtures/bad-config-listener-request-policy.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.ValidateConfig(); err == nil {
+		t.Fatal("expected an error with both request_policy and request_policy_file")
+	}
+}
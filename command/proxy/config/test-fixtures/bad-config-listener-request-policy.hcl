@@ -0,0 +1,16 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: BUSL-1.1
+
+listener "tcp" {
+  address     = "127.0.0.1:8300"
+  tls_disable = true
+
+  proxy_api {
+    request_policy      = "path \"secret/*\" { capabilities = [\"read\"] }"
+    request_policy_file = "/etc/vault-proxy/request-policy.hcl"
+  }
+}
+
+vault {
+  address = "http://127.0.0.1:1111"
+}
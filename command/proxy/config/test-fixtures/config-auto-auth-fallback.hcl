@@ -0,0 +1,22 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: BUSL-1.1
+
+pid_file = "./pidfile"
+
+auto_auth {
+	method {
+		type = "aws"
+		config = {
+			role = "foobar"
+		}
+	}
+
+	method {
+		type = "approle"
+		max_backoff = "30s"
+		config = {
+			role_id_file_path = "/tmp/role-id"
+			secret_id_file_path = "/tmp/secret-id"
+		}
+	}
+}
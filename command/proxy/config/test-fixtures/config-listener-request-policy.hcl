@@ -0,0 +1,27 @@
+# Copyright (c) HashiCorp, Inc.
+# SPDX-License-Identifier: BUSL-1.1
+
+listener "tcp" {
+  address     = "127.0.0.1:8300"
+  tls_disable = true
+
+  proxy_api {
+    request_policy_file = "/etc/vault-proxy/request-policy.hcl"
+  }
+}
+
+listener "unix" {
+  address = "/var/run/vault-proxy.sock"
+
+  proxy_api {
+    request_policy = <<EOT
+path "secret/data/app/*" {
+  capabilities = ["read"]
+}
+EOT
+  }
+}
+
+vault {
+  address = "http://127.0.0.1:1111"
+}
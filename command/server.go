@@ -3097,6 +3097,7 @@ func createCoreConfig(c *ServerCommand, config *server.Config, backend physical.
 		Physical:                       backend,
 		RedirectAddr:                   config.Storage.RedirectAddr,
 		StorageType:                    config.Storage.Type,
+		PhysicalBackends:               c.PhysicalBackends,
 		HAPhysical:                     nil,
 		ServiceRegistration:            configSR,
 		Seal:                           barrierSeal,
@@ -3124,6 +3125,7 @@ func createCoreConfig(c *ServerCommand, config *server.Config, backend physical.
 		EnableRaw:                      config.EnableRawEndpoint,
 		EnableIntrospection:            config.EnableIntrospectionEndpoint,
 		DisableSealWrap:                config.DisableSealWrap,
+		EnableMultiseal:                config.IsMultisealEnabled(),
 		DisablePerformanceStandby:      config.DisablePerformanceStandby,
 		DisableIndexing:                config.DisableIndexing,
 		AllLoggers:                     c.allLoggers,
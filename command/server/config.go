@@ -93,6 +93,9 @@ type Config struct {
 	DisableSealWrap    bool        `hcl:"-"`
 	DisableSealWrapRaw interface{} `hcl:"disable_sealwrap,alias:DisableSealWrap"`
 
+	EnableMultiseal    bool        `hcl:"-"`
+	EnableMultisealRaw interface{} `hcl:"enable_multiseal"`
+
 	DisableIndexing    bool        `hcl:"-"`
 	DisableIndexingRaw interface{} `hcl:"disable_indexing,alias:DisableIndexing"`
 
@@ -390,6 +393,11 @@ func (c *Config) Merge(c2 *Config) *Config {
 		result.DisableSealWrap = c2.DisableSealWrap
 	}
 
+	result.EnableMultiseal = c.EnableMultiseal
+	if c2.EnableMultiseal {
+		result.EnableMultiseal = c2.EnableMultiseal
+	}
+
 	result.DisableIndexing = c.DisableIndexing
 	if c2.DisableIndexing {
 		result.DisableIndexing = c2.DisableIndexing
@@ -678,6 +686,12 @@ func ParseConfig(d, source string) (*Config, error) {
 		}
 	}
 
+	if result.EnableMultisealRaw != nil {
+		if result.EnableMultiseal, err = parseutil.ParseBool(result.EnableMultisealRaw); err != nil {
+			return nil, err
+		}
+	}
+
 	if result.DisableIndexingRaw != nil {
 		if result.DisableIndexing, err = parseutil.ParseBool(result.DisableIndexingRaw); err != nil {
 			return nil, err
@@ -1138,6 +1152,8 @@ func (c *Config) Sanitized() map[string]interface{} {
 
 		"disable_sealwrap": c.DisableSealWrap,
 
+		"enable_multiseal": c.EnableMultiseal,
+
 		"disable_indexing": c.DisableIndexing,
 
 		"enable_response_header_hostname": c.EnableResponseHeaderHostname,
@@ -5,6 +5,8 @@
 
 package server
 
+// IsMultisealEnabled returns whether several seals can be enabled at the same
+// time, in which case values are wrapped with all of them.
 func (c *Config) IsMultisealEnabled() bool {
-	return false
+	return c.EnableMultiseal
 }
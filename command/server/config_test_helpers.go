@@ -775,6 +775,7 @@ func testConfig_Sanitized(t *testing.T) {
 		"plugin_file_permissions":             0,
 		"disable_printable_check":             false,
 		"disable_sealwrap":                    true,
+		"enable_multiseal":                    false,
 		"raw_storage_endpoint":                true,
 		"introspection_endpoint":              false,
 		"disable_sentinel_trace":              true,
@@ -32,6 +32,10 @@ func (c *Config) checkSealConfig() error {
 		return nil
 	}
 
+	if c.IsMultisealEnabled() {
+		return c.checkMultisealConfig()
+	}
+
 	if len(c.Seals) > 2 {
 		return fmt.Errorf("seals: at most 2 seals can be provided: received %d", len(c.Seals))
 	}
@@ -53,3 +57,32 @@ func (c *Config) checkSealConfig() error {
 
 	return nil
 }
+
+// checkMultisealConfig validates the seals when several of them may be
+// enabled at the same time. The seals are tried in order of priority, so each
+// one must have a distinct priority.
+func (c *Config) checkMultisealConfig() error {
+	enabledSeals := 0
+	priorities := make(map[int]string, len(c.Seals))
+	for _, seal := range c.Seals {
+		if !seal.Disabled {
+			enabledSeals++
+		}
+		if len(c.Seals) == 1 {
+			continue
+		}
+		if seal.Priority == 0 {
+			return fmt.Errorf("seals: seal %q must have a priority when multiple seals are configured", seal.Name)
+		}
+		if other, ok := priorities[seal.Priority]; ok {
+			return fmt.Errorf("seals: seals %q and %q have the same priority %d", other, seal.Name, seal.Priority)
+		}
+		priorities[seal.Priority] = seal.Name
+	}
+
+	if len(c.Seals) > 1 && enabledSeals == 0 {
+		return errors.New("seals: seals provided but all are disabled")
+	}
+
+	return nil
+}
@@ -73,6 +73,62 @@ func TestCheckSealConfig(t *testing.T) {
 			}}},
 			expectError: true,
 		},
+		{
+			name: "multiseal-two-seals-enabled",
+			config: Config{EnableMultiseal: true, SharedConfig: &configutil.SharedConfig{Seals: []*configutil.KMS{
+				{
+					Name:     "transit",
+					Priority: 1,
+				},
+				{
+					Name:     "pkcs11",
+					Priority: 2,
+				},
+			}}},
+		},
+		{
+			name: "multiseal-missing-priority",
+			config: Config{EnableMultiseal: true, SharedConfig: &configutil.SharedConfig{Seals: []*configutil.KMS{
+				{
+					Name:     "transit",
+					Priority: 1,
+				},
+				{
+					Name: "pkcs11",
+				},
+			}}},
+			expectError: true,
+		},
+		{
+			name: "multiseal-duplicate-priority",
+			config: Config{EnableMultiseal: true, SharedConfig: &configutil.SharedConfig{Seals: []*configutil.KMS{
+				{
+					Name:     "transit",
+					Priority: 1,
+				},
+				{
+					Name:     "pkcs11",
+					Priority: 1,
+				},
+			}}},
+			expectError: true,
+		},
+		{
+			name: "multiseal-all-disabled",
+			config: Config{EnableMultiseal: true, SharedConfig: &configutil.SharedConfig{Seals: []*configutil.KMS{
+				{
+					Name:     "transit",
+					Priority: 1,
+					Disabled: true,
+				},
+				{
+					Name:     "pkcs11",
+					Priority: 2,
+					Disabled: true,
+				},
+			}}},
+			expectError: true,
+		},
 	}
 
 	for _, tt := range testCases {
@@ -55,6 +55,9 @@ import (
 	dbMysql "github.com/hashicorp/vault/plugins/database/mysql"
 	dbPostgres "github.com/hashicorp/vault/plugins/database/postgresql"
 	dbRedshift "github.com/hashicorp/vault/plugins/database/redshift"
+	dbSQLTemplate "github.com/hashicorp/vault/plugins/database/sqltemplate"
+	_ "github.com/hashicorp/vault/plugins/database/sqltemplate/mysql"
+	_ "github.com/hashicorp/vault/plugins/database/sqltemplate/postgres"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -148,6 +151,7 @@ func newRegistry() *registry {
 			"redis-database-plugin":             {Factory: dbRedis.New},
 			"redis-elasticache-database-plugin": {Factory: dbRedisElastiCache.New},
 			"snowflake-database-plugin":         {Factory: dbSnowflake.New},
+			"sqltemplate-database-plugin":       {Factory: dbSQLTemplate.New},
 		},
 		logicalBackends: map[string]logicalBackend{
 			"ad": {
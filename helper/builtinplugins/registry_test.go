@@ -105,7 +105,7 @@ func Test_RegistryKeyCounts(t *testing.T) {
 		{
 			name:       "number of database plugins",
 			pluginType: consts.PluginTypeDatabase,
-			want:       17,
+			want:       18,
 		},
 		{
 			name:       "number of secrets plugins",
@@ -0,0 +1,10 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+//go:build testonly
+
+package builtinplugins
+
+// The SQLite driver of the sqltemplate database plugin is only meant for local
+// development and testing, so it is kept out of release builds.
+import _ "github.com/hashicorp/vault/plugins/database/sqltemplate/sqlite"
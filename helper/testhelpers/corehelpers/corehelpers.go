@@ -177,6 +177,7 @@ func (m *mockBuiltinRegistry) Keys(pluginType consts.PluginType) []string {
 			"redshift-database-plugin",
 			"redis-database-plugin",
 			"snowflake-database-plugin",
+			"sqltemplate-database-plugin",
 		}
 	case consts.PluginTypeCredential:
 		return []string{
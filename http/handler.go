@@ -121,6 +121,7 @@ func init() {
 	alwaysRedirectPaths.AddPaths([]string{
 		"sys/storage/raft/snapshot",
 		"sys/storage/raft/snapshot-force",
+		"sys/storage/raft/snapshot-partial-restore",
 		"!sys/storage/raft/snapshot-auto/config",
 	})
 	websocketPaths.AddPaths(websocketRawPaths)
@@ -949,6 +950,12 @@ func request(core *vault.Core, w http.ResponseWriter, rawReq *http.Request, r *l
 
 	resp, err := core.HandleRequest(rawReq.Context(), r)
 
+	if state := r.ResponseState(); state != nil {
+		if header := core.EncodeIndexState(state); header != "" {
+			w.Header().Set(VaultIndexHeaderName, header)
+		}
+	}
+
 	// Do the limiter measurement
 	if err != nil {
 		lsnr.OnDropped()
@@ -116,6 +116,12 @@ func buildLogicalRequestNoAuth(perfStandby bool, ra *vault.RouterAccess, w http.
 			path == "sys/storage/raft/snapshot" || path == "sys/storage/raft/snapshot-force" {
 			passHTTPReq = true
 			origBody = r.Body
+		} else if path == "sys/storage/raft/snapshot-partial-restore" {
+			// The body is the snapshot, so the parameters are passed in the
+			// query string.
+			passHTTPReq = true
+			origBody = r.Body
+			data = parseQuery(r.URL.Query())
 		} else {
 			// Sample the first bytes to determine whether this should be parsed as
 			// a form or as JSON. The amount to look ahead (512 bytes) is arbitrary
@@ -396,6 +402,13 @@ func handleLogicalInternal(core *vault.Core, injectDataIntoTopLevel bool, noForw
 			if origBody != nil {
 				r.Body = origBody
 			}
+			// Performance standbys handle requests without looking up the
+			// active node, which is what sets up the connection to forward
+			// requests to it
+			if _, _, _, err := core.Leader(); err != nil {
+				respondError(w, http.StatusInternalServerError, err)
+				return
+			}
 			forwardRequest(core, w, r)
 			return
 		case !ok:
@@ -151,6 +151,7 @@ func TestSysConfigState_Sanitized(t *testing.T) {
 				"disable_performance_standby":         false,
 				"disable_printable_check":             false,
 				"disable_sealwrap":                    false,
+				"enable_multiseal":                    false,
 				"experiments":                         nil,
 				"raw_storage_endpoint":                false,
 				"detect_deadlocks":                    "",
@@ -157,6 +157,12 @@ type AgentAPI struct {
 // ProxyAPI allows users to select which parts of the Vault Proxy API they want enabled.
 type ProxyAPI struct {
 	EnableQuit bool `hcl:"enable_quit"`
+
+	// RequestPolicy and RequestPolicyFile hold a policy, in the syntax of
+	// Vault policies, that the requests must satisfy to be proxied through
+	// the listener.
+	RequestPolicy     string `hcl:"request_policy"`
+	RequestPolicyFile string `hcl:"request_policy_file"`
 }
 
 func (l *Listener) GoString() string {
@@ -31,11 +31,16 @@ import (
 
 // Verify MySQLBackend satisfies the correct interfaces
 var (
-	_ physical.Backend   = (*MySQLBackend)(nil)
-	_ physical.HABackend = (*MySQLBackend)(nil)
-	_ physical.Lock      = (*MySQLHALock)(nil)
+	_ physical.Backend       = (*MySQLBackend)(nil)
+	_ physical.HABackend     = (*MySQLBackend)(nil)
+	_ physical.Lock          = (*MySQLHALock)(nil)
+	_ physical.Transactional = (*MySQLBackend)(nil)
 )
 
+// mysqlLockMonitorInterval is how often a held lock is checked to still be
+// owned by the connection that acquired it.
+var mysqlLockMonitorInterval = 5 * time.Second
+
 // Unreserved tls key
 // Reserved values are "true", "false", "skip-verify"
 const mysqlTLSKey = "default"
@@ -191,7 +196,6 @@ func NewMySQLBackend(conf map[string]string, logger log.Logger) (physical.Backen
 	// Only prepare ha-related statements if we need them
 	if haEnabled {
 		statements["get_lock"] = "SELECT current_leader FROM " + dbLockTable + " WHERE node_job = ?"
-		statements["used_lock"] = "SELECT IS_USED_LOCK(?)"
 	}
 
 	for name, query := range statements {
@@ -369,7 +373,7 @@ func (m *MySQLBackend) Put(ctx context.Context, entry *physical.Entry) error {
 	m.permitPool.Acquire()
 	defer m.permitPool.Release()
 
-	_, err := m.statements["put"].Exec(entry.Key, entry.Value)
+	_, err := m.statements["put"].ExecContext(ctx, entry.Key, entry.Value)
 	if err != nil {
 		return err
 	}
@@ -384,7 +388,7 @@ func (m *MySQLBackend) Get(ctx context.Context, key string) (*physical.Entry, er
 	defer m.permitPool.Release()
 
 	var result []byte
-	err := m.statements["get"].QueryRow(key).Scan(&result)
+	err := m.statements["get"].QueryRowContext(ctx, key).Scan(&result)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -406,7 +410,7 @@ func (m *MySQLBackend) Delete(ctx context.Context, key string) error {
 	m.permitPool.Acquire()
 	defer m.permitPool.Release()
 
-	_, err := m.statements["delete"].Exec(key)
+	_, err := m.statements["delete"].ExecContext(ctx, key)
 	if err != nil {
 		return err
 	}
@@ -423,10 +427,11 @@ func (m *MySQLBackend) List(ctx context.Context, prefix string) ([]string, error
 
 	// Add the % wildcard to the prefix to do the prefix search
 	likePrefix := prefix + "%"
-	rows, err := m.statements["list"].Query(likePrefix)
+	rows, err := m.statements["list"].QueryContext(ctx, likePrefix)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute statement: %w", err)
 	}
+	defer rows.Close()
 
 	var keys []string
 	for rows.Next() {
@@ -445,11 +450,72 @@ func (m *MySQLBackend) List(ctx context.Context, prefix string) ([]string, error
 			keys = strutil.AppendIfMissing(keys, string(key[:i+1]))
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
 
 	sort.Strings(keys)
 	return keys, nil
 }
 
+// Transaction is used to run multiple entries via a single database
+// transaction. Either all of the entries are applied or none of them are.
+func (m *MySQLBackend) Transaction(ctx context.Context, txns []*physical.TxnEntry) error {
+	defer metrics.MeasureSince([]string{"mysql", "transaction"}, time.Now())
+	if len(txns) == 0 {
+		return nil
+	}
+
+	m.permitPool.Acquire()
+	defer m.permitPool.Release()
+
+	tx, err := m.client.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := m.transaction(ctx, tx, txns); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			m.logger.Error("failed to roll back transaction", "error", rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *MySQLBackend) transaction(ctx context.Context, tx *sql.Tx, txns []*physical.TxnEntry) error {
+	getStmt := tx.StmtContext(ctx, m.statements["get"])
+	putStmt := tx.StmtContext(ctx, m.statements["put"])
+	deleteStmt := tx.StmtContext(ctx, m.statements["delete"])
+
+	for _, op := range txns {
+		switch op.Operation {
+		case physical.GetOperation:
+			var result []byte
+			err := getStmt.QueryRowContext(ctx, op.Entry.Key).Scan(&result)
+			switch {
+			case err == sql.ErrNoRows:
+			case err != nil:
+				return err
+			default:
+				op.Entry.Value = result
+			}
+		case physical.PutOperation:
+			if _, err := putStmt.ExecContext(ctx, op.Entry.Key, op.Entry.Value); err != nil {
+				return err
+			}
+		case physical.DeleteOperation:
+			if _, err := deleteStmt.ExecContext(ctx, op.Entry.Key); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%q is not a supported transaction operation", op.Operation)
+		}
+	}
+	return nil
+}
+
 // LockWith is used for mutual exclusion based on the given key.
 func (m *MySQLBackend) LockWith(key, value string) (physical.Lock, error) {
 	l := &MySQLHALock{
@@ -475,6 +541,7 @@ type MySQLHALock struct {
 	held      bool
 	localLock sync.Mutex
 	leaderCh  chan struct{}
+	monitorCh chan struct{}
 	stopCh    <-chan struct{}
 	lock      *MySQLLock
 }
@@ -486,74 +553,81 @@ func (i *MySQLHALock) Lock(stopCh <-chan struct{}) (<-chan struct{}, error) {
 		return nil, fmt.Errorf("lock already held")
 	}
 
-	// Attempt an async acquisition
+	lock, err := NewMySQLLock(i.in, i.logger, i.key, i.value)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attempt an async acquisition. Cancelling the context aborts the
+	// pending GET_LOCK so that the connection is not left waiting forever.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	didLock := make(chan struct{})
 	failLock := make(chan error, 1)
-	releaseCh := make(chan bool, 1)
-	go i.attemptLock(i.key, i.value, didLock, failLock, releaseCh)
+	go func() {
+		if err := lock.LockContext(ctx); err != nil {
+			failLock <- err
+			return
+		}
+		close(didLock)
+	}()
 
 	// Wait for lock acquisition, failure, or shutdown
 	select {
 	case <-didLock:
-		releaseCh <- false
 	case err := <-failLock:
+		lock.Unlock()
 		return nil, err
 	case <-stopCh:
-		releaseCh <- true
+		cancel()
+		// The acquisition may have completed right before it was cancelled,
+		// in which case the lock must still be released.
+		select {
+		case <-didLock:
+		case <-failLock:
+		}
+		lock.Unlock()
 		return nil, nil
 	}
 
 	// Create the leader channel
+	i.lock = lock
 	i.held = true
 	i.leaderCh = make(chan struct{})
+	i.monitorCh = make(chan struct{})
 
-	go i.monitorLock(i.leaderCh)
+	go i.monitorLock(lock, i.leaderCh, i.monitorCh)
 
 	i.stopCh = stopCh
 
 	return i.leaderCh, nil
 }
 
-func (i *MySQLHALock) attemptLock(key, value string, didLock chan struct{}, failLock chan error, releaseCh chan bool) {
-	lock, err := NewMySQLLock(i.in, i.logger, key, value)
-	if err != nil {
-		failLock <- err
-		return
-	}
-
-	// Set node value
-	i.lock = lock
-
-	err = lock.Lock()
-	if err != nil {
-		failLock <- err
-		return
-	}
-
-	// Signal that lock is held
-	close(didLock)
-
-	// Handle an early abort
-	release := <-releaseCh
-	if release {
-		lock.Unlock()
-	}
-}
+// monitorLock periodically verifies that the lock is still held by the
+// connection that acquired it, closing leaderCh as soon as it is not. Since a
+// GET_LOCK lock is bound to its session, failing to query that connection
+// means the lock is gone as well, and leadership is given up right away rather
+// than risking two active nodes.
+func (i *MySQLHALock) monitorLock(lock *MySQLLock, leaderCh chan struct{}, stopCh chan struct{}) {
+	ticker := time.NewTicker(mysqlLockMonitorInterval)
+	defer ticker.Stop()
 
-func (i *MySQLHALock) monitorLock(leaderCh chan struct{}) {
 	for {
-		// The only way to lose this lock is if someone is
-		// logging into the DB and altering system tables or you lose a connection in
-		// which case you will lose the lock anyway.
-		err := i.hasLock(i.key)
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), mysqlLockMonitorInterval)
+		err := lock.hasLock(ctx)
+		cancel()
 		if err != nil {
-			// Somehow we lost the lock.... likely because the connection holding
-			// the lock was closed or someone was playing around with the locks in the DB.
+			i.logger.Error("lost mysql lock, giving up leadership", "key", i.key, "error", err)
 			close(leaderCh)
 			return
 		}
-
-		time.Sleep(5 * time.Second)
 	}
 }
 
@@ -564,42 +638,21 @@ func (i *MySQLHALock) Unlock() error {
 		return nil
 	}
 
+	close(i.monitorCh)
 	err := i.lock.Unlock()
 
-	if err == nil {
-		i.held = false
-		return nil
-	}
-
+	// The connection holding the lock is gone either way, so there is nothing
+	// left to retry.
+	i.held = false
+	i.lock = nil
 	return err
 }
 
-// hasLock will check if a lock is held by checking the current lock id against our known ID.
-func (i *MySQLHALock) hasLock(key string) error {
-	var result sql.NullInt64
-	err := i.in.statements["used_lock"].QueryRow(key).Scan(&result)
-	if err == sql.ErrNoRows || !result.Valid {
-		// This is not an error to us since it just means the lock isn't held
-		return nil
-	}
-
-	if err != nil {
-		return err
-	}
-
-	// IS_USED_LOCK will return the ID of the connection that created the lock.
-	if result.Int64 != GlobalLockID {
-		return ErrLockHeld
-	}
-
-	return nil
-}
-
 func (i *MySQLHALock) GetLeader() (string, error) {
 	defer metrics.MeasureSince([]string{"mysql", "lock_get"}, time.Now())
 	var result string
 	err := i.in.statements["get_lock"].QueryRow("leader").Scan(&result)
-	if err == sql.ErrNoRows {
+	if err != nil {
 		return "", err
 	}
 
@@ -618,13 +671,20 @@ func (i *MySQLHALock) Value() (bool, string, error) {
 // MySQLLock provides an easy way to grab and release mysql
 // locks using the built in GET_LOCK function. Note that these
 // locks are released when you lose connection to the server.
+// All statements are run on a single pinned connection so the
+// lock stays tied to one session for its whole lifetime.
 type MySQLLock struct {
 	parentConn *MySQLBackend
 	in         *sql.DB
+	conn       *sql.Conn
 	logger     log.Logger
 	statements map[string]*sql.Stmt
 	key        string
 	value      string
+
+	// connectionID is the id of the session holding the lock, as
+	// reported by IS_USED_LOCK when the lock was acquired.
+	connectionID int64
 }
 
 // Errors specific to trying to grab a lock in MySQL
@@ -639,13 +699,18 @@ var (
 	ErrClaimFailed = errors.New("mysql: unable to update DB with new leader information")
 	// Error to throw if between getting the lock and checking the ID of it we lost it.
 	ErrSettingGlobalID = errors.New("mysql: getting global lock id failed")
+	// ErrLockLost is returned when a lock that was acquired is no longer held by its session.
+	ErrLockLost = errors.New("mysql: lock is no longer held by this session")
 )
 
 // NewMySQLLock helper function
 func NewMySQLLock(in *MySQLBackend, l log.Logger, key, value string) (*MySQLLock, error) {
 	// Create a new MySQL connection so we can close this and have no effect on
 	// the rest of the MySQL backend and any cleanup that might need to be done.
-	conn, _ := NewMySQLClient(in.conf, in.logger)
+	conn, err := NewMySQLClient(in.conf, in.logger)
+	if err != nil {
+		return nil, err
+	}
 
 	m := &MySQLLock{
 		parentConn: in,
@@ -663,6 +728,7 @@ func NewMySQLLock(in *MySQLBackend, l log.Logger, key, value string) (*MySQLLock
 
 	for name, query := range statements {
 		if err := m.prepare(name, query); err != nil {
+			conn.Close()
 			return nil, err
 		}
 	}
@@ -682,8 +748,8 @@ func (m *MySQLLock) prepare(name, query string) error {
 
 // update the current cluster leader in the DB. This is used so
 // we can tell the servers in standby who the active leader is.
-func (i *MySQLLock) becomeLeader() error {
-	_, err := i.statements["put"].Exec("leader", i.value)
+func (i *MySQLLock) becomeLeader(ctx context.Context) error {
+	_, err := i.statements["put"].ExecContext(ctx, "leader", i.value)
 	if err != nil {
 		return err
 	}
@@ -694,11 +760,25 @@ func (i *MySQLLock) becomeLeader() error {
 // Lock will try to get a lock for an indefinite amount of time
 // based on the given key that has been requested.
 func (i *MySQLLock) Lock() error {
+	return i.LockContext(context.Background())
+}
+
+// LockContext will try to get a lock based on the given key that has been
+// requested until it succeeds or ctx is done.
+func (i *MySQLLock) LockContext(ctx context.Context) error {
 	defer metrics.MeasureSince([]string{"mysql", "get_lock"}, time.Now())
 
+	if i.conn == nil {
+		conn, err := i.in.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		i.conn = conn
+	}
+
 	// Lock timeout math.MaxInt32 instead of -1 solves compatibility issues with
 	// different MySQL flavours i.e. MariaDB
-	rows, err := i.in.Query("SELECT GET_LOCK(?, ?), IS_USED_LOCK(?)", i.key, math.MaxInt32, i.key)
+	rows, err := i.conn.QueryContext(ctx, "SELECT GET_LOCK(?, ?), IS_USED_LOCK(?)", i.key, math.MaxInt32, i.key)
 	if err != nil {
 		return err
 	}
@@ -722,7 +802,7 @@ func (i *MySQLLock) Lock() error {
 
 	// Since we have the lock alert the rest of the cluster
 	// that we are now the active leader.
-	err = i.becomeLeader()
+	err = i.becomeLeader(ctx)
 	if err != nil {
 		return ErrLockHeld
 	}
@@ -733,18 +813,47 @@ func (i *MySQLLock) Lock() error {
 		return ErrSettingGlobalID
 	}
 
+	i.connectionID = connectionID.Int64
 	GlobalLockID = connectionID.Int64
 
 	return nil
 }
 
+// hasLock checks that the lock is still held by the session that acquired
+// it. The check runs on that same session, so an error means the connection
+// and therefore the lock is gone.
+func (i *MySQLLock) hasLock(ctx context.Context) error {
+	if i.conn == nil {
+		return ErrLockLost
+	}
+
+	var result sql.NullInt64
+	err := i.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?)", i.key).Scan(&result)
+	if err != nil {
+		return err
+	}
+
+	// IS_USED_LOCK returns NULL once nobody holds the lock any longer, and
+	// the ID of the connection that created it otherwise.
+	switch {
+	case !result.Valid:
+		return ErrLockLost
+	case result.Int64 != i.connectionID:
+		return ErrLockHeld
+	}
+
+	return nil
+}
+
 // Unlock just closes the connection. This is because closing the MySQL connection
-// is a 100% reliable way to close the lock. If you just release the lock you must
-// do it from the same mysql connection_id that you originally created it from. This
-// is a huge hastle and I actually couldn't find a clean way to do this although one
-// likely does exist. Closing the connection however ensures we don't ever get into a
-// state where we try to release the lock and it hangs it is also much less code.
+// is a 100% reliable way to close the lock, and it can't hang the way releasing
+// the lock over a broken session could.
 func (i *MySQLLock) Unlock() error {
+	if i.conn != nil {
+		i.conn.Close()
+		i.conn = nil
+	}
+
 	err := i.in.Close()
 	if err != nil {
 		return ErrUnlockFailed
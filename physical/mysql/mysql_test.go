@@ -5,7 +5,10 @@ package mysql
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -18,6 +21,7 @@ import (
 	mysql "github.com/go-sql-driver/mysql"
 
 	mysqlhelper "github.com/hashicorp/vault/helper/testhelpers/mysql"
+	_ "modernc.org/sqlite"
 )
 
 func TestMySQLPlaintextCatch(t *testing.T) {
@@ -108,6 +112,73 @@ func TestMySQLBackend(t *testing.T) {
 
 	physical.ExerciseBackend(t, b)
 	physical.ExerciseBackend_ListPrefix(t, b)
+
+	mysqlBackend := b.(*MySQLBackend)
+	if _, err := mysqlBackend.client.Exec("TRUNCATE TABLE " + mysqlBackend.dbTable); err != nil {
+		t.Fatalf("Failed to truncate table: %v", err)
+	}
+	physical.ExerciseTransactionalBackend(t, b)
+}
+
+// TestMySQLBackend_Transaction runs transactions against an on-disk SQLite
+// database standing in for MySQL, with the upsert statement rewritten to
+// SQLite's syntax.
+func TestMySQLBackend_Transaction(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "vault.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Keys named "fail" are rejected so a statement error can be forced in
+	// the middle of a transaction.
+	if _, err := db.Exec("CREATE TABLE vault (vault_key TEXT PRIMARY KEY CHECK (vault_key <> 'fail'), vault_value BLOB)"); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &MySQLBackend{
+		client:     db,
+		statements: make(map[string]*sql.Stmt),
+		logger:     logging.NewVaultLogger(log.Debug),
+		permitPool: physical.NewPermitPool(physical.DefaultParallelOperations),
+	}
+	for name, query := range map[string]string{
+		"put":    "INSERT INTO vault VALUES( ?, ? ) ON CONFLICT (vault_key) DO UPDATE SET vault_value=excluded.vault_value",
+		"get":    "SELECT vault_value FROM vault WHERE vault_key = ?",
+		"delete": "DELETE FROM vault WHERE vault_key = ?",
+		"list":   "SELECT vault_key FROM vault WHERE vault_key LIKE ?",
+	} {
+		if err := b.prepare(name, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	physical.ExerciseTransactionalBackend(t, b)
+
+	// A failing statement rolls back everything before it
+	err = b.Transaction(ctx, []*physical.TxnEntry{
+		{Operation: physical.PutOperation, Entry: &physical.Entry{Key: "foo", Value: []byte("bar4")}},
+		{Operation: physical.DeleteOperation, Entry: &physical.Entry{Key: "zip"}},
+		{Operation: physical.PutOperation, Entry: &physical.Entry{Key: "fail", Value: []byte("boom")}},
+	})
+	if err == nil {
+		t.Fatal("expected transaction to fail")
+	}
+
+	for key, expected := range map[string]string{"foo": "bar3", "zip": "zap3"} {
+		entry, err := b.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry == nil || string(entry.Value) != expected {
+			t.Fatalf("expected %q to be %q after rollback, got %v", key, expected, entry)
+		}
+	}
+	if entry, err := b.Get(ctx, "fail"); err != nil || entry != nil {
+		t.Fatalf("expected no entry for failed put, got %v, %v", entry, err)
+	}
 }
 
 func TestMySQLHABackend(t *testing.T) {
@@ -37,7 +37,10 @@ const (
 )
 
 // Verify PostgreSQLBackend satisfies the correct interfaces
-var _ physical.Backend = (*PostgreSQLBackend)(nil)
+var (
+	_ physical.Backend       = (*PostgreSQLBackend)(nil)
+	_ physical.Transactional = (*PostgreSQLBackend)(nil)
+)
 
 // HA backend was implemented based on the DynamoDB backend pattern
 // With distinction using central postgres clock, hereby avoiding
@@ -74,7 +77,8 @@ type PostgreSQLLock struct {
 	identity   string
 	lock       sync.Mutex
 
-	renewTicker *time.Ticker
+	// renewStopCh is closed by Unlock to stop the renewal goroutine.
+	renewStopCh chan struct{}
 
 	// ttlSeconds is how long a lock is valid for
 	ttlSeconds int
@@ -321,6 +325,62 @@ func (m *PostgreSQLBackend) List(ctx context.Context, prefix string) ([]string,
 	return keys, nil
 }
 
+// Transaction is used to run multiple entries via a single database
+// transaction. Either all of the entries are applied or none of them are.
+func (m *PostgreSQLBackend) Transaction(ctx context.Context, txns []*physical.TxnEntry) error {
+	defer metrics.MeasureSince([]string{"postgres", "transaction"}, time.Now())
+	if len(txns) == 0 {
+		return nil
+	}
+
+	m.permitPool.Acquire()
+	defer m.permitPool.Release()
+
+	tx, err := m.client.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if err := m.transaction(ctx, tx, txns); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			m.logger.Error("failed to roll back transaction", "error", rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *PostgreSQLBackend) transaction(ctx context.Context, tx *sql.Tx, txns []*physical.TxnEntry) error {
+	for _, op := range txns {
+		parentPath, path, key := m.splitKey(op.Entry.Key)
+
+		switch op.Operation {
+		case physical.GetOperation:
+			var result []byte
+			err := tx.QueryRowContext(ctx, m.get_query, path, key).Scan(&result)
+			switch {
+			case err == sql.ErrNoRows:
+			case err != nil:
+				return err
+			default:
+				op.Entry.Value = result
+			}
+		case physical.PutOperation:
+			if _, err := tx.ExecContext(ctx, m.put_query, parentPath, path, key, op.Entry.Value); err != nil {
+				return err
+			}
+		case physical.DeleteOperation:
+			if _, err := tx.ExecContext(ctx, m.delete_query, path, key); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%q is not a supported transaction operation", op.Operation)
+		}
+	}
+	return nil
+}
+
 // LockWith is used for mutual exclusion based on the given key.
 func (p *PostgreSQLBackend) LockWith(key, value string) (physical.Lock, error) {
 	identity, err := uuid.GenerateUUID()
@@ -344,28 +404,27 @@ func (p *PostgreSQLBackend) HAEnabled() bool {
 
 // Lock tries to acquire the lock by repeatedly trying to create a record in the
 // PostgreSQL table. It will block until either the stop channel is closed or
-// the lock could be acquired successfully. The returned channel will be closed
-// once the lock in the PostgreSQL table cannot be renewed, either due to an
-// error speaking to PostgreSQL or because someone else has taken it.
+// the lock could be acquired successfully. Errors talking to PostgreSQL while
+// waiting are logged and retried, so a temporary loss of connectivity does not
+// abort the attempt. The returned channel will be closed once the lock in the
+// PostgreSQL table cannot be renewed before its TTL runs out, or because
+// someone else has taken it.
 func (l *PostgreSQLLock) Lock(stopCh <-chan struct{}) (<-chan struct{}, error) {
 	l.lock.Lock()
 	defer l.lock.Unlock()
 
 	var (
 		success = make(chan struct{})
-		errors  = make(chan error)
 		leader  = make(chan struct{})
 	)
 	// try to acquire the lock asynchronously
-	go l.tryToLock(stopCh, success, errors)
+	go l.tryToLock(stopCh, success)
 
 	select {
 	case <-success:
 		// after acquiring it successfully, we must renew the lock periodically
-		l.renewTicker = time.NewTicker(l.renewInterval)
-		go l.periodicallyRenewLock(leader)
-	case err := <-errors:
-		return nil, err
+		l.renewStopCh = make(chan struct{})
+		go l.periodicallyRenewLock(leader, l.renewStopCh)
 	case <-stopCh:
 		return nil, nil
 	}
@@ -376,16 +435,22 @@ func (l *PostgreSQLLock) Lock(stopCh <-chan struct{}) (<-chan struct{}, error) {
 // Unlock releases the lock by deleting the lock record from the
 // PostgreSQL table.
 func (l *PostgreSQLLock) Unlock() error {
+	l.lock.Lock()
+	if l.renewStopCh != nil {
+		close(l.renewStopCh)
+		l.renewStopCh = nil
+	}
+	l.lock.Unlock()
+
 	pg := l.backend
 	pg.permitPool.Acquire()
 	defer pg.permitPool.Release()
 
-	if l.renewTicker != nil {
-		l.renewTicker.Stop()
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), l.renewInterval)
+	defer cancel()
 
 	// Delete lock owned by me
-	_, err := pg.client.Exec(pg.haDeleteLockExec, l.identity, l.key)
+	_, err := pg.client.ExecContext(ctx, pg.haDeleteLockExec, l.identity, l.key)
 	return err
 }
 
@@ -395,8 +460,12 @@ func (l *PostgreSQLLock) Value() (bool, string, error) {
 	pg := l.backend
 	pg.permitPool.Acquire()
 	defer pg.permitPool.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.renewInterval)
+	defer cancel()
+
 	var result string
-	err := pg.client.QueryRow(pg.haGetLockValueQuery, l.key).Scan(&result)
+	err := pg.client.QueryRowContext(ctx, pg.haGetLockValueQuery, l.key).Scan(&result)
 
 	switch err {
 	case nil:
@@ -410,11 +479,10 @@ func (l *PostgreSQLLock) Value() (bool, string, error) {
 }
 
 // tryToLock tries to create a new item in PostgreSQL every `retryInterval`.
-// As long as the item cannot be created (because it already exists), it will
-// be retried. If the operation fails due to an error, it is sent to the errors
-// channel. When the lock could be acquired successfully, the success channel
-// is closed.
-func (l *PostgreSQLLock) tryToLock(stop <-chan struct{}, success chan struct{}, errors chan error) {
+// As long as the item cannot be created (because it already exists or because
+// PostgreSQL cannot be reached), it will be retried. When the lock could be
+// acquired successfully, the success channel is closed.
+func (l *PostgreSQLLock) tryToLock(stop <-chan struct{}, success chan struct{}) {
 	ticker := time.NewTicker(l.retryInterval)
 	defer ticker.Stop()
 
@@ -426,8 +494,7 @@ func (l *PostgreSQLLock) tryToLock(stop <-chan struct{}, success chan struct{},
 			gotlock, err := l.writeItem()
 			switch {
 			case err != nil:
-				errors <- err
-				return
+				l.backend.logger.Warn("failed to acquire lock, will retry", "key", l.key, "error", err)
 			case gotlock:
 				close(success)
 				return
@@ -436,13 +503,44 @@ func (l *PostgreSQLLock) tryToLock(stop <-chan struct{}, success chan struct{},
 	}
 }
 
-func (l *PostgreSQLLock) periodicallyRenewLock(done chan struct{}) {
-	for range l.renewTicker.C {
+// periodicallyRenewLock extends the expiry of the lock every renewInterval
+// until stop is closed. A failed renewal is retried every retryInterval for as
+// long as the lock is still guaranteed to be ours, i.e. until less than one
+// renewInterval remains of the TTL granted by the last successful renewal.
+// done is closed once the lock has been lost.
+func (l *PostgreSQLLock) periodicallyRenewLock(done chan struct{}, stop chan struct{}) {
+	ttl := time.Duration(l.ttlSeconds) * time.Second
+	lastRenewal := time.Now()
+
+	timer := time.NewTimer(l.renewInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+
+		// The new expiry is computed by the database once the statement runs,
+		// so the time before sending it is a safe lower bound.
+		attempt := time.Now()
 		gotlock, err := l.writeItem()
-		if err != nil || !gotlock {
+		switch {
+		case err == nil && gotlock:
+			lastRenewal = attempt
+			timer.Reset(l.renewInterval)
+		case err == nil:
+			l.backend.logger.Warn("lock was taken by another node", "key", l.key)
 			close(done)
-			l.renewTicker.Stop()
 			return
+		case time.Since(lastRenewal)+l.renewInterval >= ttl:
+			l.backend.logger.Error("failed to renew lock before it expired, giving up leadership", "key", l.key, "error", err)
+			close(done)
+			return
+		default:
+			l.backend.logger.Warn("failed to renew lock, will retry", "key", l.key, "error", err)
+			timer.Reset(l.retryInterval)
 		}
 	}
 }
@@ -456,9 +554,13 @@ func (l *PostgreSQLLock) writeItem() (bool, error) {
 	pg.permitPool.Acquire()
 	defer pg.permitPool.Release()
 
+	// Bound the query so a hung connection can't outlive the lock TTL
+	ctx, cancel := context.WithTimeout(context.Background(), l.renewInterval)
+	defer cancel()
+
 	// Try steal lock or update expiry on my lock
 
-	sqlResult, err := pg.client.Exec(pg.haUpsertLockIdentityExec, l.identity, l.key, l.value, l.ttlSeconds)
+	sqlResult, err := pg.client.ExecContext(ctx, pg.haUpsertLockIdentityExec, l.identity, l.key, l.value, l.ttlSeconds)
 	if err != nil {
 		return false, err
 	}
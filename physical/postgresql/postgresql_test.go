@@ -4,8 +4,11 @@
 package postgresql
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -14,6 +17,7 @@ import (
 	"github.com/hashicorp/vault/sdk/helper/logging"
 	"github.com/hashicorp/vault/sdk/physical"
 	_ "github.com/jackc/pgx/v4/stdlib"
+	_ "modernc.org/sqlite"
 )
 
 func TestPostgreSQLBackend(t *testing.T) {
@@ -80,6 +84,11 @@ func TestPostgreSQLBackend(t *testing.T) {
 	physical.ExerciseBackend(t, b1)
 	logger.Info("Running list prefix backend tests")
 	physical.ExerciseBackend_ListPrefix(t, b1)
+	if _, err := pg.client.Exec(fmt.Sprintf(" TRUNCATE TABLE %v ", pg.table)); err != nil {
+		t.Fatalf("Failed to truncate table: %v", err)
+	}
+	logger.Info("Running transactional backend tests")
+	physical.ExerciseTransactionalBackend(t, b1)
 
 	ha1, ok := b1.(physical.HABackend)
 	if !ok {
@@ -424,3 +433,193 @@ func setupDatabaseObjects(t *testing.T, logger log.Logger, pg *PostgreSQLBackend
 		t.Fatalf("Failed to create hatable: %v", err)
 	}
 }
+
+// newSQLiteTestBackend returns a backend whose client is an on-disk SQLite
+// database, which speaks enough of the PostgreSQL dialect to stand in for it
+// when exercising transactions and the HA lock without a container. The HA
+// queries are rewritten to use SQLite's date functions.
+func newSQLiteTestBackend(t *testing.T) *PostgreSQLBackend {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "vault.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	for _, stmt := range []string{
+		// Keys named "fail" are rejected so tests can force a statement error
+		// in the middle of a transaction.
+		`CREATE TABLE vault_kv_store (parent_path TEXT NOT NULL, path TEXT, key TEXT CHECK (key <> 'fail'), value BLOB, PRIMARY KEY (path, key))`,
+		`CREATE TABLE vault_ha_locks (ha_key TEXT NOT NULL PRIMARY KEY, ha_identity TEXT NOT NULL, ha_value TEXT, valid_until TEXT NOT NULL)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &PostgreSQLBackend{
+		table:  "vault_kv_store",
+		client: db,
+		put_query: "INSERT INTO vault_kv_store VALUES($1, $2, $3, $4)" +
+			" ON CONFLICT (path, key) DO " +
+			" UPDATE SET (parent_path, path, key, value) = ($1, $2, $3, $4)",
+		get_query:           "SELECT value FROM vault_kv_store WHERE path = $1 AND key = $2",
+		delete_query:        "DELETE FROM vault_kv_store WHERE path = $1 AND key = $2",
+		haGetLockValueQuery: " SELECT ha_value FROM vault_ha_locks WHERE datetime('now') <= valid_until AND ha_key = $1 ",
+		haUpsertLockIdentityExec: " INSERT INTO vault_ha_locks AS t (ha_identity, ha_key, ha_value, valid_until) VALUES ($1, $2, $3, datetime('now', '+' || $4 || ' seconds')) " +
+			" ON CONFLICT (ha_key) DO " +
+			" UPDATE SET (ha_identity, ha_key, ha_value, valid_until) = ($1, $2, $3, datetime('now', '+' || $4 || ' seconds')) " +
+			" WHERE (t.valid_until < datetime('now') AND t.ha_key = $2) OR " +
+			" (t.ha_identity = $1 AND t.ha_key = $2) ",
+		haDeleteLockExec: " DELETE FROM vault_ha_locks WHERE ha_identity=$1 AND ha_key=$2 ",
+		logger:           logging.NewVaultLogger(log.Debug),
+		permitPool:       physical.NewPermitPool(physical.DefaultParallelOperations),
+		haEnabled:        true,
+	}
+}
+
+func TestPostgreSQLBackend_Transaction(t *testing.T) {
+	ctx := context.Background()
+	b := newSQLiteTestBackend(t)
+
+	for _, entry := range []*physical.Entry{
+		{Key: "foo", Value: []byte("bar")},
+		{Key: "deleteme", Value: []byte("please")},
+	} {
+		if err := b.Put(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	txns := []*physical.TxnEntry{
+		{Operation: physical.GetOperation, Entry: &physical.Entry{Key: "foo"}},
+		{Operation: physical.PutOperation, Entry: &physical.Entry{Key: "foo", Value: []byte("bar2")}},
+		{Operation: physical.PutOperation, Entry: &physical.Entry{Key: "nested/zip", Value: []byte("zap")}},
+		{Operation: physical.DeleteOperation, Entry: &physical.Entry{Key: "deleteme"}},
+		{Operation: physical.GetOperation, Entry: &physical.Entry{Key: "nested/zip"}},
+	}
+	if err := b.Transaction(ctx, txns); err != nil {
+		t.Fatal(err)
+	}
+
+	// Get operations observe the state at their position in the transaction
+	if got := string(txns[0].Entry.Value); got != "bar" {
+		t.Fatalf("expected get to return %q, got %q", "bar", got)
+	}
+	if got := string(txns[4].Entry.Value); got != "zap" {
+		t.Fatalf("expected get to return %q, got %q", "zap", got)
+	}
+
+	assertValue := func(key, expected string) {
+		t.Helper()
+		entry, err := b.Get(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case expected == "" && entry != nil:
+			t.Fatalf("expected %q to be deleted, got %q", key, entry.Value)
+		case expected == "":
+		case entry == nil:
+			t.Fatalf("expected %q to be %q, got nil", key, expected)
+		case string(entry.Value) != expected:
+			t.Fatalf("expected %q to be %q, got %q", key, expected, entry.Value)
+		}
+	}
+	assertValue("foo", "bar2")
+	assertValue("nested/zip", "zap")
+	assertValue("deleteme", "")
+
+	// A failing statement rolls back everything before it
+	err := b.Transaction(ctx, []*physical.TxnEntry{
+		{Operation: physical.PutOperation, Entry: &physical.Entry{Key: "foo", Value: []byte("bar3")}},
+		{Operation: physical.DeleteOperation, Entry: &physical.Entry{Key: "nested/zip"}},
+		{Operation: physical.PutOperation, Entry: &physical.Entry{Key: "fail", Value: []byte("boom")}},
+	})
+	if err == nil {
+		t.Fatal("expected transaction to fail")
+	}
+	assertValue("foo", "bar2")
+	assertValue("nested/zip", "zap")
+	assertValue("fail", "")
+}
+
+// TestPostgreSQLLock_ConnectionLoss checks that the lock neither fails to be
+// acquired nor gives up leadership because of errors that are shorter than the
+// lock TTL, while still stepping down before the TTL runs out on longer ones.
+func TestPostgreSQLLock_ConnectionLoss(t *testing.T) {
+	b := newSQLiteTestBackend(t)
+
+	// Renaming the lock table away makes every lock query fail, the same as
+	// the database being unreachable.
+	setBroken := func(broken bool) {
+		t.Helper()
+		stmt := "ALTER TABLE vault_ha_locks RENAME TO vault_ha_locks_unavailable"
+		if !broken {
+			stmt = "ALTER TABLE vault_ha_locks_unavailable RENAME TO vault_ha_locks"
+		}
+		if _, err := b.client.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := b.LockWith("leader", "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := l.(*PostgreSQLLock)
+	lock.ttlSeconds = 2
+	lock.renewInterval = 200 * time.Millisecond
+	lock.retryInterval = 50 * time.Millisecond
+
+	// Acquisition keeps retrying while the database is unavailable
+	setBroken(true)
+	type lockResult struct {
+		leaderCh <-chan struct{}
+		err      error
+	}
+	resultCh := make(chan lockResult, 1)
+	go func() {
+		leaderCh, err := lock.Lock(nil)
+		resultCh <- lockResult{leaderCh, err}
+	}()
+	time.Sleep(300 * time.Millisecond)
+	setBroken(false)
+
+	var leaderCh <-chan struct{}
+	select {
+	case res := <-resultCh:
+		if res.err != nil {
+			t.Fatalf("expected lock to be acquired, got: %v", res.err)
+		}
+		leaderCh = res.leaderCh
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out acquiring lock")
+	}
+	defer lock.Unlock()
+
+	// A short outage is bridged by retrying the renewal
+	setBroken(true)
+	time.Sleep(500 * time.Millisecond)
+	setBroken(false)
+	select {
+	case <-leaderCh:
+		t.Fatal("lost leadership during a short outage")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// An outage that outlasts the TTL gives up leadership before it expires
+	setBroken(true)
+	start := time.Now()
+	select {
+	case <-leaderCh:
+		if elapsed := time.Since(start); elapsed >= 2*time.Second {
+			t.Fatalf("gave up leadership after the lock expired: %s", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected leadership to be lost")
+	}
+	setBroken(false)
+}
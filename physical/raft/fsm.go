@@ -69,6 +69,11 @@ func init() {
 
 type restoreCallback func(context.Context) error
 
+// invalidateCallback is called with the index and the storage keys modified
+// by a batch of applied logs. A nil set of keys means the whole FSM was
+// replaced, e.g. by a snapshot restore.
+type invalidateCallback func(index uint64, keys []string)
+
 type FSMEntry struct {
 	Key   string
 	Value []byte
@@ -175,6 +180,10 @@ type FSM struct {
 	// retoreCb is called after we've restored a snapshot
 	restoreCb restoreCallback
 
+	// invalidateCb is called after logs are applied to the FSM or a snapshot
+	// is restored. It must not block.
+	invalidateCb invalidateCallback
+
 	chunker *logVerificationChunkingShim
 
 	localID         string
@@ -242,6 +251,15 @@ func (r *RaftBackend) SetFSMApplyCallback(f func()) {
 	r.fsm.l.Unlock()
 }
 
+// SetFSMInvalidateCallback registers a function that is called with the index
+// and modified keys every time a batch of logs is applied to the FSM, and with
+// a nil set of keys when a snapshot is restored. Passing nil unregisters it.
+func (r *RaftBackend) SetFSMInvalidateCallback(f func(index uint64, keys []string)) {
+	r.fsm.l.Lock()
+	r.fsm.invalidateCb = f
+	r.fsm.l.Unlock()
+}
+
 func (f *FSM) openDBFile(dbPath string) error {
 	if len(dbPath) == 0 {
 		return errors.New("can not open empty filename")
@@ -732,6 +750,7 @@ func (f *FSM) ApplyBatch(logs []*raft.Log) []interface{} {
 		f.applyCallback()
 	}
 
+	var modifiedKeys []string
 	err = f.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(dataBucketName)
 		for _, commandRaw := range commands {
@@ -744,8 +763,10 @@ func (f *FSM) ApplyBatch(logs []*raft.Log) []interface{} {
 					switch op.OpType {
 					case putOp:
 						err = b.Put([]byte(op.Key), op.Value)
+						modifiedKeys = append(modifiedKeys, op.Key)
 					case deleteOp:
 						err = b.Delete([]byte(op.Key))
+						modifiedKeys = append(modifiedKeys, op.Key)
 					case getOp:
 						fsmEntry := &FSMEntry{
 							Key: op.Key,
@@ -806,6 +827,13 @@ func (f *FSM) ApplyBatch(logs []*raft.Log) []interface{} {
 	if len(logIndex) > 0 {
 		atomic.StoreUint64(f.latestTerm, lastLog.Term)
 		atomic.StoreUint64(f.latestIndex, lastLog.Index)
+
+		if f.invalidateCb != nil {
+			if modifiedKeys == nil {
+				modifiedKeys = []string{}
+			}
+			f.invalidateCb(lastLog.Index, modifiedKeys)
+		}
 	}
 
 	// If one or more configuration changes were processed, store the latest one.
@@ -969,6 +997,10 @@ func (f *FSM) Restore(r io.ReadCloser) error {
 		}
 	}
 
+	if f.invalidateCb != nil {
+		f.invalidateCb(atomic.LoadUint64(f.latestIndex), nil)
+	}
+
 	return retErr.ErrorOrNil()
 }
 
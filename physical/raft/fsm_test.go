@@ -162,3 +162,49 @@ func TestFSM_List(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestFSM_InvalidateCallback(t *testing.T) {
+	fsm, dir := getFSM(t)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	var gotIndex uint64
+	var gotKeys []string
+	fsm.invalidateCb = func(index uint64, keys []string) {
+		gotIndex = index
+		gotKeys = append(gotKeys, keys...)
+	}
+
+	command := &LogData{
+		Operations: []*LogOperation{
+			{OpType: putOp, Key: "foo", Value: []byte("bar")},
+			{OpType: getOp, Key: "foo"},
+			{OpType: deleteOp, Key: "baz"},
+		},
+	}
+	commandBytes, err := proto.Marshal(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsm.ApplyBatch([]*raft.Log{
+		{Index: 1, Term: 1, Type: raft.LogCommand, Data: commandBytes},
+		{Index: 2, Term: 1, Type: raft.LogCommand},
+	})
+
+	if gotIndex != 2 {
+		t.Fatalf("bad index: got %d expected 2", gotIndex)
+	}
+	if diff := deep.Equal([]string{"foo", "baz"}, gotKeys); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	// Re-applying an old index doesn't advance the FSM, so nothing should be
+	// invalidated.
+	gotIndex, gotKeys = 0, nil
+	fsm.ApplyBatch([]*raft.Log{
+		{Index: 1, Term: 1, Type: raft.LogCommand, Data: commandBytes},
+	})
+	if gotIndex != 0 || gotKeys != nil {
+		t.Fatalf("unexpected invalidation: index %d keys %v", gotIndex, gotKeys)
+	}
+}
@@ -97,6 +97,7 @@ var (
 	restoreOpDelayDuration             = 5 * time.Second
 	defaultMaxEntrySize                = uint64(2 * raftchunking.ChunkSize)
 	defaultRaftLogVerificationInterval = 60 * time.Second
+	defaultReadReplicaMaxStaleness     = 5 * time.Second
 	minimumRaftLogVerificationInterval = 10 * time.Second
 
 	GetInTxnDisabledError = errors.New("get operations inside transactions are disabled in raft backend")
@@ -233,6 +234,13 @@ type RaftBackend struct {
 	// replicated to and can serve reads, but do not take part in leader elections.
 	nonVoter bool
 
+	// readReplica specifies whether the node should serve read requests from
+	// its local FSM while it is a non-voter, instead of forwarding them to the
+	// active node. readReplicaMaxStaleness bounds how long ago the node may
+	// have last heard from the leader for it to keep doing so.
+	readReplica             bool
+	readReplicaMaxStaleness time.Duration
+
 	effectiveSDKVersion string
 	failGetInTxn        *uint32
 
@@ -314,6 +322,8 @@ type RaftBackendConfig struct {
 	AutopilotRedundancyZone     string
 	RaftNonVoter                bool
 	RetryJoin                   string
+	ReadReplica                 bool
+	ReadReplicaMaxStaleness     time.Duration
 }
 
 // JoinConfig returns a list of information about possible leader nodes that
@@ -586,6 +596,8 @@ func NewRaftBackend(conf map[string]string, logger log.Logger) (physical.Backend
 		autopilotUpdateInterval:     backendConfig.AutopilotUpdateInterval,
 		redundancyZone:              backendConfig.AutopilotRedundancyZone,
 		nonVoter:                    backendConfig.RaftNonVoter,
+		readReplica:                 backendConfig.ReadReplica,
+		readReplicaMaxStaleness:     backendConfig.ReadReplicaMaxStaleness,
 		upgradeVersion:              backendConfig.AutopilotUpgradeVersion,
 		failGetInTxn:                new(uint32),
 		raftLogVerifierEnabled:      backendConfig.RaftLogVerifierEnabled,
@@ -666,6 +678,29 @@ func (b *RaftBackend) NonVoter() bool {
 	return b.nonVoter
 }
 
+// ReadReplica returns whether this node is configured to serve read requests
+// locally while it is a non-voter, and the maximum staleness it tolerates.
+func (b *RaftBackend) ReadReplica() (bool, time.Duration) {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	return b.readReplica, b.readReplicaMaxStaleness
+}
+
+// LastContact returns the last time this node heard from the leader. The zero
+// time is returned if raft has not been set up yet or if the node is the
+// leader.
+func (b *RaftBackend) LastContact() time.Time {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	if b.raft == nil {
+		return time.Time{}
+	}
+
+	return b.raft.LastContact()
+}
+
 // UpgradeVersion returns the string that should be used by autopilot during automated upgrades. We return the
 // specified upgradeVersion if it's present. If it's not, we fall back to effectiveSDKVersion, which is
 // Vault's binary version (though that can be overridden for tests).
@@ -1883,6 +1918,12 @@ func (b *RaftBackend) applyLog(ctx context.Context, command *LogData) error {
 	}
 
 	if err := applyFuture.Error(); err != nil {
+		// Read replicas never become leader, so surface write attempts as
+		// read-only errors so that the request gets forwarded to the active
+		// node.
+		if b.readReplica && errors.Is(err, raft.ErrNotLeader) {
+			return logical.ErrReadOnly
+		}
 		return err
 	}
 
@@ -1937,9 +1978,28 @@ func (b *RaftBackend) applyLog(ctx context.Context, command *LogData) error {
 		}
 	}
 
+	recordIndexState(ctx, applyFuture.Index())
+
 	return nil
 }
 
+// recordIndexState raises the local index held by the request's WAL state, if
+// any, to the given raft index so that it can be returned to the client as a
+// consistency header.
+func recordIndexState(ctx context.Context, index uint64) {
+	state := logical.IndexStateFromContext(ctx)
+	if state == nil {
+		return
+	}
+
+	for {
+		current := atomic.LoadUint64(&state.LocalIndex)
+		if index <= current || atomic.CompareAndSwapUint64(&state.LocalIndex, current, index) {
+			return
+		}
+	}
+}
+
 // HAEnabled is the implementation of the HABackend interface
 func (b *RaftBackend) HAEnabled() bool { return true }
 
@@ -2285,6 +2345,32 @@ func parseRaftBackendConfig(conf map[string]string, logger log.Logger) (*RaftBac
 		return nil, fmt.Errorf("setting %s to true is only valid if at least one retry_join stanza is specified", raftNonVoterConfigKey)
 	}
 
+	if v, ok := conf["read_replica"]; ok {
+		readReplica, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse read_replica config value %q as a boolean: %w", v, err)
+		}
+
+		c.ReadReplica = readReplica
+	}
+
+	c.ReadReplicaMaxStaleness = defaultReadReplicaMaxStaleness
+	if v, ok := conf["read_replica_max_staleness"]; ok {
+		staleness, err := parseutil.ParseDurationSecond(v)
+		if err != nil {
+			return nil, fmt.Errorf("read_replica_max_staleness does not parse as a duration: %w", err)
+		}
+		if staleness <= 0 {
+			return nil, errors.New("read_replica_max_staleness must be greater than zero")
+		}
+
+		c.ReadReplicaMaxStaleness = staleness
+	}
+
+	if c.ReadReplica && !c.RaftNonVoter {
+		return nil, fmt.Errorf("read_replica requires %s to be set to true", raftNonVoterConfigKey)
+	}
+
 	c.AutopilotRedundancyZone = conf["autopilot_redundancy_zone"]
 
 	return c, nil
@@ -488,6 +488,72 @@ func TestRaft_ParseNonVoter(t *testing.T) {
 	}
 }
 
+func TestRaft_ParseReadReplica(t *testing.T) {
+	for name, tc := range map[string]struct {
+		conf              map[string]string
+		expectErr         bool
+		expectReplica     bool
+		expectMaxStalness time.Duration
+	}{
+		"unset": {
+			conf:              map[string]string{},
+			expectMaxStalness: defaultReadReplicaMaxStaleness,
+		},
+		"enabled": {
+			conf:              map[string]string{"read_replica": "true", raftNonVoterConfigKey: "true"},
+			expectReplica:     true,
+			expectMaxStalness: defaultReadReplicaMaxStaleness,
+		},
+		"custom staleness": {
+			conf:              map[string]string{"read_replica": "true", raftNonVoterConfigKey: "true", "read_replica_max_staleness": "30s"},
+			expectReplica:     true,
+			expectMaxStalness: 30 * time.Second,
+		},
+		"requires non voter": {
+			conf:      map[string]string{"read_replica": "true"},
+			expectErr: true,
+		},
+		"invalid bool": {
+			conf:      map[string]string{"read_replica": "maybe", raftNonVoterConfigKey: "true"},
+			expectErr: true,
+		},
+		"invalid staleness": {
+			conf:      map[string]string{"read_replica": "true", raftNonVoterConfigKey: "true", "read_replica_max_staleness": "-1s"},
+			expectErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conf := map[string]string{
+				"path":       t.TempDir(),
+				"node_id":    "abc123",
+				"retry_join": "not-empty",
+			}
+			for k, v := range tc.conf {
+				conf[k] = v
+			}
+
+			backend, err := NewRaftBackend(conf, hclog.NewNullLogger())
+			if tc.expectErr {
+				if err == nil {
+					t.Fatal("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			enabled, maxStaleness := backend.(*RaftBackend).ReadReplica()
+			if enabled != tc.expectReplica {
+				t.Fatalf("expected read_replica %v but got %v", tc.expectReplica, enabled)
+			}
+			if maxStaleness != tc.expectMaxStalness {
+				t.Fatalf("expected read_replica_max_staleness %s but got %s", tc.expectMaxStalness, maxStaleness)
+			}
+		})
+	}
+}
+
 func TestRaft_Backend_LargeKey(t *testing.T) {
 	t.Parallel()
 
@@ -9,18 +9,94 @@ import (
 	"context"
 	"errors"
 
+	"github.com/hashicorp/raft"
 	autopilot "github.com/hashicorp/raft-autopilot"
 )
 
-const nonVotersAllowed = false
+const (
+	nonVotersAllowed = false
+
+	// nodeTypeReadReplica is the autopilot node type given to servers that
+	// joined as non-voters. They are never promoted to voters.
+	nodeTypeReadReplica autopilot.NodeType = "read-replica"
+
+	desiredSuffrageMetaKey = "desired_suffrage"
+)
 
 func (b *RaftBackend) autopilotPromoter() autopilot.Promoter {
-	return autopilot.DefaultPromoter()
+	return &readReplicaPromoter{StablePromoter: &autopilot.StablePromoter{}}
+}
+
+// readReplicaPromoter behaves like the default autopilot promoter, except
+// that it leaves servers that asked to join as non-voters alone instead of
+// promoting them once they are stable.
+type readReplicaPromoter struct {
+	*autopilot.StablePromoter
+}
+
+func (p *readReplicaPromoter) GetNodeTypes(c *autopilot.Config, s *autopilot.State) map[raft.ServerID]autopilot.NodeType {
+	types := p.StablePromoter.GetNodeTypes(c, s)
+	for id, server := range s.Servers {
+		if isReadReplica(server) {
+			types[id] = nodeTypeReadReplica
+		}
+	}
+	return types
+}
+
+func (p *readReplicaPromoter) CalculatePromotionsAndDemotions(c *autopilot.Config, s *autopilot.State) autopilot.RaftChanges {
+	changes := p.StablePromoter.CalculatePromotionsAndDemotions(c, s)
+
+	promotions := changes.Promotions[:0]
+	for _, id := range changes.Promotions {
+		if server, ok := s.Servers[id]; ok && isReadReplica(server) {
+			continue
+		}
+		promotions = append(promotions, id)
+	}
+	changes.Promotions = promotions
+
+	return changes
 }
 
-// AddNonVotingPeer adds a new server to the raft cluster
+func isReadReplica(server *autopilot.ServerState) bool {
+	if server == nil {
+		return false
+	}
+	return server.Server.Meta[desiredSuffrageMetaKey] == "non-voter"
+}
+
+// AddNonVotingPeer adds a new server to the raft cluster as a non-voter
 func (b *RaftBackend) AddNonVotingPeer(ctx context.Context, peerID, clusterAddr string) error {
-	return errors.New("adding non voting peer is not allowed")
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	if b.disableAutopilot {
+		if b.raft == nil {
+			return errors.New("raft storage is not initialized")
+		}
+		b.logger.Trace("adding non-voting server to raft", "id", peerID, "addr", clusterAddr)
+		future := b.raft.AddNonvoter(raft.ServerID(peerID), raft.ServerAddress(clusterAddr), 0, 0)
+		return future.Error()
+	}
+
+	if b.autopilot == nil {
+		return errors.New("raft storage autopilot is not initialized")
+	}
+
+	b.logger.Trace("adding non-voting server to raft via autopilot", "id", peerID, "addr", clusterAddr)
+	return b.autopilot.AddServer(&autopilot.Server{
+		ID:          raft.ServerID(peerID),
+		Name:        peerID,
+		Address:     raft.ServerAddress(clusterAddr),
+		RaftVersion: raft.ProtocolVersionMax,
+		NodeType:    nodeTypeReadReplica,
+		Meta:        map[string]string{desiredSuffrageMetaKey: "non-voter"},
+	})
 }
 
 func autopilotToAPIServerEnterprise(_ *autopilot.Server, _ *AutopilotServer) error {
@@ -39,6 +115,9 @@ func (d *Delegate) autopilotServerExt(_ *FollowerState) interface{} {
 	return nil
 }
 
-func (d *Delegate) meta(_ *FollowerState) map[string]string {
-	return nil
+func (d *Delegate) meta(state *FollowerState) map[string]string {
+	if state == nil || state.DesiredSuffrage == "" {
+		return nil
+	}
+	return map[string]string{desiredSuffrageMetaKey: state.DesiredSuffrage}
 }
@@ -0,0 +1,56 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+//go:build !enterprise
+
+package raft
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/hashicorp/raft"
+	autopilot "github.com/hashicorp/raft-autopilot"
+)
+
+func TestRaft_ReadReplicaPromoter(t *testing.T) {
+	stable := autopilot.ServerHealth{Healthy: true, StableSince: time.Now().Add(-time.Hour)}
+	state := &autopilot.State{
+		Servers: map[raft.ServerID]*autopilot.ServerState{
+			"leader": {
+				Server: autopilot.Server{ID: "leader", IsLeader: true},
+				State:  autopilot.RaftLeader,
+				Health: stable,
+			},
+			"voter": {
+				Server: autopilot.Server{ID: "voter", Meta: map[string]string{desiredSuffrageMetaKey: "voter"}},
+				State:  autopilot.RaftNonVoter,
+				Health: stable,
+			},
+			"replica": {
+				Server: autopilot.Server{ID: "replica", Meta: map[string]string{desiredSuffrageMetaKey: "non-voter"}},
+				State:  autopilot.RaftNonVoter,
+				Health: stable,
+			},
+		},
+	}
+
+	promoter := (&RaftBackend{}).autopilotPromoter()
+
+	changes := promoter.CalculatePromotionsAndDemotions(&autopilot.Config{}, state)
+	if diff := deep.Equal([]raft.ServerID{"voter"}, changes.Promotions); len(diff) > 0 {
+		t.Fatal(diff)
+	}
+
+	types := promoter.GetNodeTypes(&autopilot.Config{}, state)
+	if types["replica"] != nodeTypeReadReplica {
+		t.Fatalf("expected replica to be a %q but got %q", nodeTypeReadReplica, types["replica"])
+	}
+	if types["voter"] != autopilot.NodeVoter {
+		t.Fatalf("expected voter to be a %q but got %q", autopilot.NodeVoter, types["voter"])
+	}
+	if promoter.IsPotentialVoter(nodeTypeReadReplica) {
+		t.Fatal("read replicas should never be potential voters")
+	}
+}
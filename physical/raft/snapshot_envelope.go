@@ -0,0 +1,484 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package raft
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/helper/pgpkeys"
+)
+
+const (
+	// snapshotEnvelopeMagic starts every snapshot envelope so that it can be
+	// told apart from a plain snapshot, which is a gzipped archive.
+	snapshotEnvelopeMagic   = "vault-snapshot-envelope\n"
+	snapshotEnvelopeVersion = 1
+
+	// snapshotEnvelopeChunkSize is the size of the plaintext of each frame,
+	// the snapshot is streamed through the envelope and never held in memory.
+	snapshotEnvelopeChunkSize = 64 * 1024
+	snapshotEnvelopeMaxHeader = 1024 * 1024
+
+	SnapshotEnvelopeRecipientKey     = "aes256-gcm96"
+	SnapshotEnvelopeRecipientPGP     = "pgp"
+	SnapshotEnvelopeRecipientTransit = "transit"
+)
+
+var (
+	ErrNotSnapshotEnvelope       = errors.New("not a snapshot envelope")
+	ErrSnapshotEnvelopeSignature = errors.New("snapshot envelope signature verification failed")
+)
+
+// SnapshotEnvelopeHeader is stored in clear at the start of an envelope. It
+// holds the data key of the envelope wrapped for each of its recipients.
+type SnapshotEnvelopeHeader struct {
+	Version      int                          `json:"version"`
+	CreatedAt    time.Time                    `json:"created_at"`
+	SigningKeyID string                       `json:"signing_key_id"`
+	Recipients   []*SnapshotEnvelopeRecipient `json:"recipients"`
+}
+
+// SnapshotEnvelopeRecipient is the data key of an envelope wrapped with an
+// encryption key, a PGP public key or a transit key. The key ID of transit
+// recipients is the "<mount>/<key name>" of the transit key, and the wrapped
+// key is the transit ciphertext.
+type SnapshotEnvelopeRecipient struct {
+	Type       string `json:"type"`
+	KeyID      string `json:"key_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// SnapshotTransitDataKey is a data key generated by the datakey endpoint of a
+// transit key, so that the transit key itself never leaves Vault.
+type SnapshotTransitDataKey struct {
+	// KeyID is the "<mount>/<key name>" of the transit key
+	KeyID      string
+	Plaintext  []byte
+	Ciphertext string
+}
+
+// SnapshotEnvelopeSealOptions are the recipients and the signing key of a new
+// envelope. At least one recipient is required.
+type SnapshotEnvelopeSealOptions struct {
+	// TransitDataKey is used as the data key of the envelope instead of a
+	// random one, and is unwrapped by its transit key.
+	TransitDataKey *SnapshotTransitDataKey
+
+	// EncryptionKeys are 256 bits AES keys.
+	EncryptionKeys [][]byte
+
+	// PGPKeys are base64 encoded PGP public keys, as returned by
+	// pgpkeys.ParsePGPKeys.
+	PGPKeys []string
+
+	SigningKey ed25519.PrivateKey
+}
+
+// SnapshotEnvelopeOpenOptions are the keys used to verify and decrypt an
+// envelope. One of EncryptionKey, PGPPrivateKey or TransitDecrypt is
+// required.
+type SnapshotEnvelopeOpenOptions struct {
+	EncryptionKey []byte
+
+	// TransitDecrypt unwraps the data key of the transit recipient with the
+	// given key ID, by decrypting its ciphertext with the transit key.
+	TransitKeyID   string
+	TransitDecrypt func(ciphertext string) ([]byte, error)
+
+	// PGPPrivateKey is a base64 encoded, unencrypted PGP private key.
+	PGPPrivateKey string
+
+	VerifyKey ed25519.PublicKey
+}
+
+// SnapshotEnvelopeKeyID returns the identifier of an encryption key or of a
+// public signing key stored in the header of envelopes.
+func SnapshotEnvelopeKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// SplitSnapshotTransitKey returns the mount path and the name of the transit
+// key identified by "<mount>/<key name>".
+func SplitSnapshotTransitKey(keyID string) (string, string, error) {
+	keyID = strings.Trim(keyID, "/")
+	i := strings.LastIndex(keyID, "/")
+	if i <= 0 || i == len(keyID)-1 {
+		return "", "", fmt.Errorf("transit key %q must be of the form <mount>/<key name>", keyID)
+	}
+	return keyID[:i], keyID[i+1:], nil
+}
+
+// IsSnapshotEnvelope reports whether the reader starts with an envelope,
+// without consuming it.
+func IsSnapshotEnvelope(r *bufio.Reader) bool {
+	magic, err := r.Peek(len(snapshotEnvelopeMagic))
+	return err == nil && string(magic) == snapshotEnvelopeMagic
+}
+
+// WriteSnapshotEnvelope encrypts the snapshot read from snap with a random
+// data key, or the transit data key if any, wraps the data key for each
+// recipient and signs the result. The
+// envelope is a header followed by AES-GCM frames and an ed25519 signature of
+// the SHA-256 of everything before it.
+func WriteSnapshotEnvelope(out io.Writer, snap io.Reader, opts *SnapshotEnvelopeSealOptions) error {
+	if opts.TransitDataKey == nil && len(opts.EncryptionKeys) == 0 && len(opts.PGPKeys) == 0 {
+		return errors.New("at least one transit key, encryption key or PGP key is required")
+	}
+	if len(opts.SigningKey) != ed25519.PrivateKeySize {
+		return errors.New("an ed25519 signing key is required")
+	}
+
+	header := &SnapshotEnvelopeHeader{
+		Version:      snapshotEnvelopeVersion,
+		CreatedAt:    time.Now().UTC(),
+		SigningKeyID: SnapshotEnvelopeKeyID(opts.SigningKey.Public().(ed25519.PublicKey)),
+	}
+
+	var dataKey []byte
+	if opts.TransitDataKey != nil {
+		if len(opts.TransitDataKey.Plaintext) != 32 {
+			return errors.New("the transit data key must be 256 bits long")
+		}
+		dataKey = opts.TransitDataKey.Plaintext
+		header.Recipients = append(header.Recipients, &SnapshotEnvelopeRecipient{
+			Type:       SnapshotEnvelopeRecipientTransit,
+			KeyID:      opts.TransitDataKey.KeyID,
+			WrappedKey: []byte(opts.TransitDataKey.Ciphertext),
+		})
+	} else {
+		dataKey = make([]byte, 32)
+		if _, err := rand.Read(dataKey); err != nil {
+			return err
+		}
+	}
+	for _, key := range opts.EncryptionKeys {
+		aead, err := snapshotEnvelopeAEAD(key)
+		if err != nil {
+			return err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		header.Recipients = append(header.Recipients, &SnapshotEnvelopeRecipient{
+			Type:       SnapshotEnvelopeRecipientKey,
+			KeyID:      SnapshotEnvelopeKeyID(key),
+			WrappedKey: aead.Seal(nonce, nonce, dataKey, nil),
+		})
+	}
+	if len(opts.PGPKeys) > 0 {
+		dataKeys := make([][]byte, len(opts.PGPKeys))
+		for i := range dataKeys {
+			dataKeys[i] = dataKey
+		}
+		fingerprints, wrapped, err := pgpkeys.EncryptShares(dataKeys, opts.PGPKeys)
+		if err != nil {
+			return err
+		}
+		for i := range wrapped {
+			header.Recipients = append(header.Recipients, &SnapshotEnvelopeRecipient{
+				Type:       SnapshotEnvelopeRecipientPGP,
+				KeyID:      fingerprints[i],
+				WrappedKey: wrapped[i],
+			})
+		}
+	}
+
+	rawHeader, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.New()
+	w := bufio.NewWriter(io.MultiWriter(out, digest))
+	if _, err := w.WriteString(snapshotEnvelopeMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(rawHeader))); err != nil {
+		return err
+	}
+	if _, err := w.Write(rawHeader); err != nil {
+		return err
+	}
+
+	aead, err := snapshotEnvelopeAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+	headerSum := sha256.Sum256(rawHeader)
+	buf := make([]byte, snapshotEnvelopeChunkSize)
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(snap, buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return fmt.Errorf("failed to read snapshot: %w", err)
+		}
+
+		frame := aead.Seal(nil, snapshotEnvelopeNonce(counter, last), buf[:n], headerSum[:])
+		var flag byte
+		if last {
+			flag = 1
+		}
+		if err := w.WriteByte(flag); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.BigEndian, uint32(len(frame))); err != nil {
+			return err
+		}
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+		if last {
+			break
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	_, err = out.Write(ed25519.Sign(opts.SigningKey, digest.Sum(nil)))
+	return err
+}
+
+// VerifySnapshotEnvelope checks the signature of the envelope read from in
+// without decrypting it, and returns its header.
+func VerifySnapshotEnvelope(in io.Reader, verifyKey ed25519.PublicKey) (*SnapshotEnvelopeHeader, error) {
+	er, err := newSnapshotEnvelopeReader(in)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		_, last, err := er.nextFrame()
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			break
+		}
+	}
+	if err := er.verify(verifyKey); err != nil {
+		return nil, err
+	}
+	return er.header, nil
+}
+
+// OpenSnapshotEnvelope decrypts the envelope read from in into out. The
+// signature is only checked once the whole envelope has been read, callers
+// must discard what was written to out if an error is returned, or call
+// VerifySnapshotEnvelope first.
+func OpenSnapshotEnvelope(out io.Writer, in io.Reader, opts *SnapshotEnvelopeOpenOptions) (*SnapshotEnvelopeHeader, error) {
+	er, err := newSnapshotEnvelopeReader(in)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := er.unwrapDataKey(opts)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := snapshotEnvelopeAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	headerSum := sha256.Sum256(er.rawHeader)
+	for counter := uint64(0); ; counter++ {
+		frame, last, err := er.nextFrame()
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := aead.Open(nil, snapshotEnvelopeNonce(counter, last), frame, headerSum[:])
+		if err != nil {
+			return nil, errors.New("failed to decrypt snapshot envelope")
+		}
+		if _, err := out.Write(plaintext); err != nil {
+			return nil, err
+		}
+		if last {
+			break
+		}
+	}
+	if err := er.verify(opts.VerifyKey); err != nil {
+		return nil, err
+	}
+	return er.header, nil
+}
+
+// snapshotEnvelopeReader reads the parts of an envelope while hashing them
+// for the signature check.
+type snapshotEnvelopeReader struct {
+	r         *bufio.Reader
+	digest    hash.Hash
+	body      io.Reader
+	header    *SnapshotEnvelopeHeader
+	rawHeader []byte
+}
+
+func newSnapshotEnvelopeReader(in io.Reader) (*snapshotEnvelopeReader, error) {
+	er := &snapshotEnvelopeReader{
+		r:      bufio.NewReader(in),
+		digest: sha256.New(),
+	}
+	if !IsSnapshotEnvelope(er.r) {
+		return nil, ErrNotSnapshotEnvelope
+	}
+	er.body = io.TeeReader(er.r, er.digest)
+
+	if _, err := io.CopyN(io.Discard, er.body, int64(len(snapshotEnvelopeMagic))); err != nil {
+		return nil, err
+	}
+	var headerLen uint32
+	if err := binary.Read(er.body, binary.BigEndian, &headerLen); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot envelope header: %w", err)
+	}
+	if headerLen > snapshotEnvelopeMaxHeader {
+		return nil, errors.New("snapshot envelope header is too large")
+	}
+	er.rawHeader = make([]byte, headerLen)
+	if _, err := io.ReadFull(er.body, er.rawHeader); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot envelope header: %w", err)
+	}
+	if err := json.Unmarshal(er.rawHeader, &er.header); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot envelope header: %w", err)
+	}
+	if er.header == nil || er.header.Version != snapshotEnvelopeVersion {
+		return nil, errors.New("unsupported snapshot envelope version")
+	}
+	return er, nil
+}
+
+func (er *snapshotEnvelopeReader) nextFrame() ([]byte, bool, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(er.body, prefix[:]); err != nil {
+		return nil, false, fmt.Errorf("failed to read snapshot envelope frame: %w", err)
+	}
+	if prefix[0] > 1 {
+		return nil, false, errors.New("invalid snapshot envelope frame")
+	}
+	frameLen := binary.BigEndian.Uint32(prefix[1:])
+	if frameLen > snapshotEnvelopeChunkSize+64 {
+		return nil, false, errors.New("snapshot envelope frame is too large")
+	}
+	frame := make([]byte, frameLen)
+	if _, err := io.ReadFull(er.body, frame); err != nil {
+		return nil, false, fmt.Errorf("failed to read snapshot envelope frame: %w", err)
+	}
+	return frame, prefix[0] == 1, nil
+}
+
+// verify reads the signature following the last frame, it must be called once
+// all the frames have been read.
+func (er *snapshotEnvelopeReader) verify(verifyKey ed25519.PublicKey) error {
+	if len(verifyKey) != ed25519.PublicKeySize {
+		return errors.New("an ed25519 verification key is required")
+	}
+	if id := SnapshotEnvelopeKeyID(verifyKey); id != er.header.SigningKeyID {
+		return fmt.Errorf("snapshot envelope was signed by key %q, not %q", er.header.SigningKeyID, id)
+	}
+
+	signature := make([]byte, ed25519.SignatureSize)
+	if _, err := io.ReadFull(er.r, signature); err != nil {
+		return fmt.Errorf("failed to read snapshot envelope signature: %w", err)
+	}
+	if _, err := er.r.ReadByte(); err != io.EOF {
+		return errors.New("unexpected data after snapshot envelope signature")
+	}
+	if !ed25519.Verify(verifyKey, er.digest.Sum(nil), signature) {
+		return ErrSnapshotEnvelopeSignature
+	}
+	return nil
+}
+
+func (er *snapshotEnvelopeReader) unwrapDataKey(opts *SnapshotEnvelopeOpenOptions) ([]byte, error) {
+	switch {
+	case len(opts.EncryptionKey) > 0:
+		keyID := SnapshotEnvelopeKeyID(opts.EncryptionKey)
+		for _, recipient := range er.header.Recipients {
+			if recipient.Type != SnapshotEnvelopeRecipientKey || recipient.KeyID != keyID {
+				continue
+			}
+			aead, err := snapshotEnvelopeAEAD(opts.EncryptionKey)
+			if err != nil {
+				return nil, err
+			}
+			if len(recipient.WrappedKey) < aead.NonceSize() {
+				return nil, errors.New("invalid wrapped data key")
+			}
+			nonce, wrapped := recipient.WrappedKey[:aead.NonceSize()], recipient.WrappedKey[aead.NonceSize():]
+			dataKey, err := aead.Open(nil, nonce, wrapped, nil)
+			if err != nil {
+				return nil, errors.New("failed to unwrap the data key of the snapshot envelope")
+			}
+			return dataKey, nil
+		}
+		return nil, fmt.Errorf("snapshot envelope is not encrypted for key %q", keyID)
+
+	case opts.TransitDecrypt != nil:
+		for _, recipient := range er.header.Recipients {
+			if recipient.Type != SnapshotEnvelopeRecipientTransit || recipient.KeyID != opts.TransitKeyID {
+				continue
+			}
+			dataKey, err := opts.TransitDecrypt(string(recipient.WrappedKey))
+			if err != nil {
+				return nil, fmt.Errorf("failed to unwrap the data key of the snapshot envelope: %w", err)
+			}
+			return dataKey, nil
+		}
+		return nil, fmt.Errorf("snapshot envelope is not encrypted for transit key %q", opts.TransitKeyID)
+
+	case opts.PGPPrivateKey != "":
+		for _, recipient := range er.header.Recipients {
+			if recipient.Type != SnapshotEnvelopeRecipientPGP {
+				continue
+			}
+			dataKey, err := pgpkeys.DecryptBytes(base64.StdEncoding.EncodeToString(recipient.WrappedKey), opts.PGPPrivateKey)
+			if err == nil {
+				return dataKey.Bytes(), nil
+			}
+		}
+		return nil, errors.New("snapshot envelope is not encrypted for the given PGP key")
+
+	default:
+		return nil, errors.New("an encryption key, a PGP private key or a transit key is required")
+	}
+}
+
+func snapshotEnvelopeAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, errors.New("encryption keys must be 256 bits long")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// snapshotEnvelopeNonce derives the nonce of a frame from its position, the
+// last frame has its own nonce space so that a truncated envelope cannot be
+// decrypted.
+func snapshotEnvelopeNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
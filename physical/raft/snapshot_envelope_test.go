@@ -0,0 +1,128 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package raft
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/pgpkeys"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotEnvelope(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	key := make([]byte, 32)
+	_, err = rand.Read(key)
+	require.NoError(t, err)
+	transitDataKey := &SnapshotTransitDataKey{
+		KeyID:      "transit/snapshots",
+		Plaintext:  make([]byte, 32),
+		Ciphertext: "vault:v1:wrapped",
+	}
+	_, err = rand.Read(transitDataKey.Plaintext)
+	require.NoError(t, err)
+
+	// Span a few frames, with a partial last one
+	snap := make([]byte, 3*snapshotEnvelopeChunkSize+123)
+	_, err = rand.Read(snap)
+	require.NoError(t, err)
+
+	envelope := new(bytes.Buffer)
+	err = WriteSnapshotEnvelope(envelope, bytes.NewReader(snap), &SnapshotEnvelopeSealOptions{
+		TransitDataKey: transitDataKey,
+		EncryptionKeys: [][]byte{key},
+		PGPKeys:        []string{pgpkeys.TestPubKey1},
+		SigningKey:     priv,
+	})
+	require.NoError(t, err)
+	require.True(t, IsSnapshotEnvelope(bufio.NewReader(bytes.NewReader(envelope.Bytes()))))
+	require.False(t, IsSnapshotEnvelope(bufio.NewReader(bytes.NewReader(snap))))
+
+	t.Run("verify", func(t *testing.T) {
+		header, err := VerifySnapshotEnvelope(bytes.NewReader(envelope.Bytes()), pub)
+		require.NoError(t, err)
+		require.Len(t, header.Recipients, 3)
+		require.Equal(t, SnapshotEnvelopeKeyID(pub), header.SigningKeyID)
+
+		_, err = VerifySnapshotEnvelope(bytes.NewReader(envelope.Bytes()), otherPub)
+		require.Error(t, err)
+
+		_, err = VerifySnapshotEnvelope(bytes.NewReader(snap), pub)
+		require.ErrorIs(t, err, ErrNotSnapshotEnvelope)
+	})
+
+	t.Run("encryption key", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		_, err := OpenSnapshotEnvelope(out, bytes.NewReader(envelope.Bytes()), &SnapshotEnvelopeOpenOptions{
+			EncryptionKey: key,
+			VerifyKey:     pub,
+		})
+		require.NoError(t, err)
+		require.Equal(t, snap, out.Bytes())
+
+		wrongKey := make([]byte, 32)
+		_, err = OpenSnapshotEnvelope(new(bytes.Buffer), bytes.NewReader(envelope.Bytes()), &SnapshotEnvelopeOpenOptions{
+			EncryptionKey: wrongKey,
+			VerifyKey:     pub,
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("transit key", func(t *testing.T) {
+		transitDecrypt := func(ciphertext string) ([]byte, error) {
+			require.Equal(t, transitDataKey.Ciphertext, ciphertext)
+			return transitDataKey.Plaintext, nil
+		}
+		out := new(bytes.Buffer)
+		_, err := OpenSnapshotEnvelope(out, bytes.NewReader(envelope.Bytes()), &SnapshotEnvelopeOpenOptions{
+			TransitKeyID:   "transit/snapshots",
+			TransitDecrypt: transitDecrypt,
+			VerifyKey:      pub,
+		})
+		require.NoError(t, err)
+		require.Equal(t, snap, out.Bytes())
+
+		_, err = OpenSnapshotEnvelope(new(bytes.Buffer), bytes.NewReader(envelope.Bytes()), &SnapshotEnvelopeOpenOptions{
+			TransitKeyID:   "transit/other",
+			TransitDecrypt: transitDecrypt,
+			VerifyKey:      pub,
+		})
+		require.ErrorContains(t, err, "not encrypted for transit key")
+	})
+
+	t.Run("pgp key", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		_, err := OpenSnapshotEnvelope(out, bytes.NewReader(envelope.Bytes()), &SnapshotEnvelopeOpenOptions{
+			PGPPrivateKey: pgpkeys.TestPrivKey1,
+			VerifyKey:     pub,
+		})
+		require.NoError(t, err)
+		require.Equal(t, snap, out.Bytes())
+
+		_, err = OpenSnapshotEnvelope(new(bytes.Buffer), bytes.NewReader(envelope.Bytes()), &SnapshotEnvelopeOpenOptions{
+			PGPPrivateKey: pgpkeys.TestPrivKey2,
+			VerifyKey:     pub,
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		tampered := bytes.Clone(envelope.Bytes())
+		tampered[len(tampered)/2] ^= 0xff
+		_, err := VerifySnapshotEnvelope(bytes.NewReader(tampered), pub)
+		require.ErrorIs(t, err, ErrSnapshotEnvelopeSignature)
+
+		truncated := envelope.Bytes()[:envelope.Len()-ed25519.SignatureSize-1]
+		_, err = VerifySnapshotEnvelope(bytes.NewReader(truncated), pub)
+		require.Error(t, err)
+	})
+}
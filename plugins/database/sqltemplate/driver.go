@@ -0,0 +1,67 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package sqltemplate
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Driver describes a database/sql driver the plugin can connect through.
+// Drivers are registered at build time by importing a package that calls
+// RegisterDriver from its init function, the same way database/sql drivers
+// register themselves.
+type Driver struct {
+	// SQLDriverName is the name the database/sql driver is registered under,
+	// as passed to sql.Open.
+	SQLDriverName string
+
+	// DisableTransactions executes the statements of an operation one after
+	// the other on the connection pool instead of in a single transaction,
+	// for databases that do not support transactions or DDL within them.
+	DisableTransactions bool
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// RegisterDriver makes a driver available to the plugin under the given name,
+// which is what the "driver" connection parameter refers to. It panics if a
+// driver is registered twice under the same name.
+func RegisterDriver(name string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if name == "" || driver.SQLDriverName == "" {
+		panic("sqltemplate: driver name and SQL driver name must be set")
+	}
+	if _, dup := drivers[name]; dup {
+		panic(fmt.Sprintf("sqltemplate: RegisterDriver called twice for driver %q", name))
+	}
+	drivers[name] = driver
+}
+
+// Drivers returns the sorted names of the registered drivers.
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupDriver(name string) (Driver, bool) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	driver, ok := drivers[name]
+	return driver, ok
+}
@@ -0,0 +1,27 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+// Package mysql registers a driver for databases speaking the MySQL wire
+// protocol, such as TiDB or MariaDB, with the sqltemplate database plugin.
+// Import it for its side effect:
+//
+//	import _ "github.com/hashicorp/vault/plugins/database/sqltemplate/mysql"
+//
+// The connection_url is a go-sql-driver/mysql data source name such as
+// "{{username}}:{{password}}@tcp(127.0.0.1:4000)/".
+package mysql
+
+import (
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/hashicorp/vault/plugins/database/sqltemplate"
+)
+
+// DriverName is the value of the "driver" connection parameter that selects
+// this driver.
+const DriverName = "mysql"
+
+func init() {
+	sqltemplate.RegisterDriver(DriverName, sqltemplate.Driver{
+		SQLDriverName: "mysql",
+	})
+}
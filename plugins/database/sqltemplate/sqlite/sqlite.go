@@ -0,0 +1,27 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+// Package sqlite registers a SQLite driver with the sqltemplate database
+// plugin. It is meant for local development and testing; import it for its
+// side effect:
+//
+//	import _ "github.com/hashicorp/vault/plugins/database/sqltemplate/sqlite"
+//
+// The connection_url is a SQLite data source name such as
+// "file:/var/lib/test.db".
+package sqlite
+
+import (
+	"github.com/hashicorp/vault/plugins/database/sqltemplate"
+	_ "modernc.org/sqlite"
+)
+
+// DriverName is the value of the "driver" connection parameter that selects
+// this driver.
+const DriverName = "sqlite"
+
+func init() {
+	sqltemplate.RegisterDriver(DriverName, sqltemplate.Driver{
+		SQLDriverName: "sqlite",
+	})
+}
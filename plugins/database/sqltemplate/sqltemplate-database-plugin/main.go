@@ -0,0 +1,29 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/hashicorp/vault/plugins/database/sqltemplate"
+	_ "github.com/hashicorp/vault/plugins/database/sqltemplate/mysql"
+	_ "github.com/hashicorp/vault/plugins/database/sqltemplate/postgres"
+	_ "github.com/hashicorp/vault/plugins/database/sqltemplate/sqlite"
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+)
+
+func main() {
+	if err := Run(); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+// Run instantiates a SQLTemplate object, and runs the RPC server for the plugin
+func Run() error {
+	dbplugin.ServeMultiplex(sqltemplate.New)
+
+	return nil
+}
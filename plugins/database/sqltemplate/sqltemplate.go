@@ -0,0 +1,293 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package sqltemplate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	dbplugin "github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/hashicorp/vault/sdk/database/helper/connutil"
+	"github.com/hashicorp/vault/sdk/database/helper/dbutil"
+	"github.com/hashicorp/vault/sdk/helper/dbtxn"
+	"github.com/hashicorp/vault/sdk/helper/template"
+	"github.com/mitchellh/mapstructure"
+)
+
+const (
+	// This is how this plugin will be reflected in middleware
+	// such as metrics.
+	middlewareTypeName = "sqltemplate"
+
+	defaultExpirationFormat = "2006-01-02 15:04:05-0700"
+	defaultUserNameTemplate = `{{ printf "v-%s-%s-%s-%s" (.DisplayName | truncate 8) (.RoleName | truncate 8) (random 20) (unix_time) | truncate 63 }}`
+)
+
+var _ dbplugin.Database = (*SQLTemplate)(nil)
+
+// New implements builtinplugins.BuiltinFactory
+func New() (interface{}, error) {
+	db := newSQLTemplate()
+	// Wrap the plugin with middleware to sanitize errors
+	dbType := dbplugin.NewDatabaseErrorSanitizerMiddleware(db, db.secretValues)
+	return dbType, nil
+}
+
+func newSQLTemplate() *SQLTemplate {
+	return &SQLTemplate{
+		SQLConnectionProducer: &connutil.SQLConnectionProducer{},
+	}
+}
+
+// SQLTemplate is a database plugin for SQL databases without a dedicated
+// plugin. It has no knowledge of any SQL dialect: every user operation runs
+// the statements of the role, or the default statements of the connection,
+// through the database/sql driver selected by the "driver" parameter.
+type SQLTemplate struct {
+	*connutil.SQLConnectionProducer
+
+	config           templateConfig
+	driver           Driver
+	usernameProducer template.StringTemplate
+}
+
+// templateConfig holds the plugin specific connection parameters.
+type templateConfig struct {
+	Driver           string `mapstructure:"driver"`
+	UsernameTemplate string `mapstructure:"username_template"`
+	ExpirationFormat string `mapstructure:"expiration_format"`
+
+	// DisableStatementSplitting executes each statement as given instead of
+	// splitting it on semicolons, for dialects whose procedural blocks
+	// contain semicolons.
+	DisableStatementSplitting bool `mapstructure:"disable_statement_splitting"`
+
+	// The default statements are used by roles that do not set their own.
+	DefaultCreationStatements   []string `mapstructure:"default_creation_statements"`
+	DefaultRevocationStatements []string `mapstructure:"default_revocation_statements"`
+	DefaultRenewStatements      []string `mapstructure:"default_renew_statements"`
+	DefaultRotationStatements   []string `mapstructure:"default_rotation_statements"`
+}
+
+func (s *SQLTemplate) secretValues() map[string]string {
+	return map[string]string{
+		s.Password: "[password]",
+	}
+}
+
+func (s *SQLTemplate) Type() (string, error) {
+	return middlewareTypeName, nil
+}
+
+// Initialize must be called on each new SQLTemplate struct before use. It
+// resolves the configured driver and then uses the
+// connutil.SQLConnectionProducer's Init function to do the rest.
+func (s *SQLTemplate) Initialize(ctx context.Context, req dbplugin.InitializeRequest) (dbplugin.InitializeResponse, error) {
+	var config templateConfig
+	if err := mapstructure.WeakDecode(req.Config, &config); err != nil {
+		return dbplugin.InitializeResponse{}, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if config.Driver == "" {
+		return dbplugin.InitializeResponse{}, fmt.Errorf("driver must be set; registered drivers: %s", strings.Join(Drivers(), ", "))
+	}
+	driver, ok := lookupDriver(config.Driver)
+	if !ok {
+		return dbplugin.InitializeResponse{}, fmt.Errorf("unknown driver %q; registered drivers: %s", config.Driver, strings.Join(Drivers(), ", "))
+	}
+	if config.ExpirationFormat == "" {
+		config.ExpirationFormat = defaultExpirationFormat
+	}
+	if config.UsernameTemplate == "" {
+		config.UsernameTemplate = defaultUserNameTemplate
+	}
+
+	up, err := template.NewTemplate(template.Template(config.UsernameTemplate))
+	if err != nil {
+		return dbplugin.InitializeResponse{}, fmt.Errorf("unable to initialize username template: %w", err)
+	}
+	if _, err := up.Generate(dbplugin.UsernameMetadata{}); err != nil {
+		return dbplugin.InitializeResponse{}, fmt.Errorf("invalid username template: %w", err)
+	}
+
+	s.SQLConnectionProducer.Type = driver.SQLDriverName
+	conf, err := s.Init(ctx, req.Config, req.VerifyConnection)
+	if err != nil {
+		return dbplugin.InitializeResponse{}, fmt.Errorf("error initializing db: %w", err)
+	}
+
+	s.config = config
+	s.driver = driver
+	s.usernameProducer = up
+
+	return dbplugin.InitializeResponse{
+		Config: conf,
+	}, nil
+}
+
+// getConnection accepts a context and returns a new pointer to a sql.DB object.
+// It's up to the caller to close the connection or handle reuse logic.
+func (s *SQLTemplate) getConnection(ctx context.Context) (*sql.DB, error) {
+	db, err := s.Connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db.(*sql.DB), nil
+}
+
+// NewUser creates a new user by running the role's creation statements, or
+// the connection's default creation statements if the role has none.
+func (s *SQLTemplate) NewUser(ctx context.Context, req dbplugin.NewUserRequest) (dbplugin.NewUserResponse, error) {
+	statements := s.statementsOrDefault(req.Statements.Commands, s.config.DefaultCreationStatements)
+	if len(statements) == 0 {
+		return dbplugin.NewUserResponse{}, dbutil.ErrEmptyCreationStatement
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	username, err := s.usernameProducer.Generate(req.UsernameConfig)
+	if err != nil {
+		return dbplugin.NewUserResponse{}, err
+	}
+
+	m := map[string]string{
+		"name":       username,
+		"username":   username,
+		"password":   req.Password,
+		"expiration": req.Expiration.Format(s.config.ExpirationFormat),
+	}
+	if err := s.executeStatements(ctx, statements, m); err != nil {
+		return dbplugin.NewUserResponse{}, err
+	}
+
+	return dbplugin.NewUserResponse{
+		Username: username,
+	}, nil
+}
+
+// UpdateUser can update the expiration or the password of a user, or both.
+// Both updates run in the same transaction unless the driver disables
+// transactions.
+func (s *SQLTemplate) UpdateUser(ctx context.Context, req dbplugin.UpdateUserRequest) (dbplugin.UpdateUserResponse, error) {
+	if req.Username == "" {
+		return dbplugin.UpdateUserResponse{}, errors.New("missing username")
+	}
+	if req.Password == nil && req.Expiration == nil {
+		return dbplugin.UpdateUserResponse{}, errors.New("no changes requested")
+	}
+
+	m := map[string]string{
+		"name":     req.Username,
+		"username": req.Username,
+	}
+	var statements []string
+
+	if req.Expiration != nil {
+		// Databases without expiring users have nothing to renew, so no
+		// renew statements is not an error.
+		statements = append(statements, s.statementsOrDefault(req.Expiration.Statements.Commands, s.config.DefaultRenewStatements)...)
+		m["expiration"] = req.Expiration.NewExpiration.Format(s.config.ExpirationFormat)
+	}
+
+	if req.Password != nil {
+		if req.Password.NewPassword == "" {
+			return dbplugin.UpdateUserResponse{}, errors.New("missing password")
+		}
+		rotation := s.statementsOrDefault(req.Password.Statements.Commands, s.config.DefaultRotationStatements)
+		if len(rotation) == 0 {
+			return dbplugin.UpdateUserResponse{}, dbutil.ErrEmptyRotationStatement
+		}
+		statements = append(statements, rotation...)
+		m["password"] = req.Password.NewPassword
+	}
+
+	if len(statements) == 0 {
+		return dbplugin.UpdateUserResponse{}, nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	return dbplugin.UpdateUserResponse{}, s.executeStatements(ctx, statements, m)
+}
+
+// DeleteUser runs the role's revocation statements, or the connection's
+// default revocation statements if the role has none.
+func (s *SQLTemplate) DeleteUser(ctx context.Context, req dbplugin.DeleteUserRequest) (dbplugin.DeleteUserResponse, error) {
+	statements := s.statementsOrDefault(req.Statements.Commands, s.config.DefaultRevocationStatements)
+	if len(statements) == 0 {
+		return dbplugin.DeleteUserResponse{}, errors.New("empty revocation statements")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	m := map[string]string{
+		"name":     req.Username,
+		"username": req.Username,
+	}
+	return dbplugin.DeleteUserResponse{}, s.executeStatements(ctx, statements, m)
+}
+
+func (s *SQLTemplate) statementsOrDefault(statements, defaults []string) []string {
+	statements = strutil.RemoveEmpty(statements)
+	if len(statements) == 0 {
+		return strutil.RemoveEmpty(defaults)
+	}
+	return statements
+}
+
+// executeStatements renders and runs the given statements, in a single
+// transaction unless the driver disables transactions.
+func (s *SQLTemplate) executeStatements(ctx context.Context, statements []string, m map[string]string) error {
+	var queries []string
+	for _, stmt := range statements {
+		if s.config.DisableStatementSplitting {
+			queries = append(queries, stmt)
+			continue
+		}
+		for _, query := range strutil.ParseArbitraryStringSlice(stmt, ";") {
+			query = strings.TrimSpace(query)
+			if len(query) == 0 {
+				continue
+			}
+			queries = append(queries, query)
+		}
+	}
+
+	db, err := s.getConnection(ctx)
+	if err != nil {
+		return err
+	}
+
+	if s.driver.DisableTransactions {
+		for _, query := range queries {
+			if err := dbtxn.ExecuteDBQueryDirect(ctx, db, m, query); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, query := range queries {
+		if err := dbtxn.ExecuteTxQueryDirect(ctx, tx, m, query); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
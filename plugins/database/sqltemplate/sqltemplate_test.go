@@ -0,0 +1,190 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package sqltemplate_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/plugins/database/sqltemplate"
+	"github.com/hashicorp/vault/plugins/database/sqltemplate/sqlite"
+	dbplugin "github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	dbtesting "github.com/hashicorp/vault/sdk/database/dbplugin/v5/testing"
+	"github.com/hashicorp/vault/sdk/database/helper/dbutil"
+)
+
+// prepareSQLite creates a SQLite database with a table that stands in for
+// the database's users, and returns its connection URL along with a handle
+// to inspect it.
+func prepareSQLite(t *testing.T) (string, *sql.DB) {
+	t.Helper()
+
+	connURL := "file:" + filepath.Join(t.TempDir(), "test.db")
+	conn, err := sql.Open("sqlite", connURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if _, err := conn.Exec(`CREATE TABLE users (name TEXT PRIMARY KEY, password TEXT, expiration TEXT);`); err != nil {
+		t.Fatal(err)
+	}
+	return connURL, conn
+}
+
+func newDatabase(t *testing.T) dbplugin.Database {
+	t.Helper()
+
+	raw, err := sqltemplate.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw.(dbplugin.Database)
+}
+
+func readUser(t *testing.T, conn *sql.DB, username string) (password, expiration string, found bool) {
+	t.Helper()
+
+	err := conn.QueryRow(`SELECT password, expiration FROM users WHERE name = ?;`, username).Scan(&password, &expiration)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return password, expiration, true
+}
+
+func TestSQLTemplate_Initialize(t *testing.T) {
+	connURL, _ := prepareSQLite(t)
+
+	db := newDatabase(t)
+	for name, config := range map[string]map[string]interface{}{
+		"missing driver": {"connection_url": connURL},
+		"unknown driver": {"connection_url": connURL, "driver": "oracle"},
+		"bad template":   {"connection_url": connURL, "driver": sqlite.DriverName, "username_template": "{{ .Unknown | nope }}"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := dbtesting.VerifyInitialize(t, db, dbplugin.InitializeRequest{Config: config, VerifyConnection: true}); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+
+	dbtesting.AssertInitialize(t, db, dbplugin.InitializeRequest{
+		Config: map[string]interface{}{
+			"connection_url": connURL,
+			"driver":         sqlite.DriverName,
+		},
+		VerifyConnection: true,
+	})
+	dbtesting.AssertClose(t, db)
+}
+
+func TestSQLTemplate_UserLifecycle(t *testing.T) {
+	connURL, conn := prepareSQLite(t)
+
+	db := newDatabase(t)
+	defer dbtesting.AssertClose(t, db)
+
+	dbtesting.AssertInitialize(t, db, dbplugin.InitializeRequest{
+		Config: map[string]interface{}{
+			"connection_url":                connURL,
+			"driver":                        sqlite.DriverName,
+			"username_template":             "{{ .RoleName }}-{{ random 8 }}",
+			"expiration_format":             time.RFC3339,
+			"default_creation_statements":   []string{`INSERT INTO users (name, password, expiration) VALUES ('{{name}}', '{{password}}', '{{expiration}}');`},
+			"default_revocation_statements": `DELETE FROM users WHERE name = '{{name}}';`,
+			"default_renew_statements":      `UPDATE users SET expiration = '{{expiration}}' WHERE name = '{{name}}';`,
+		},
+		VerifyConnection: true,
+	})
+
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	resp := dbtesting.AssertNewUser(t, db, dbplugin.NewUserRequest{
+		UsernameConfig: dbplugin.UsernameMetadata{DisplayName: "token", RoleName: "app"},
+		Password:       "first-password",
+		Expiration:     expiration,
+	})
+	username := resp.Username
+
+	password, storedExpiration, found := readUser(t, conn, username)
+	if !found || password != "first-password" || storedExpiration != expiration.Format(time.RFC3339) {
+		t.Fatalf("unexpected user after creation: %q %q %v", password, storedExpiration, found)
+	}
+
+	// Without rotation statements on the role or the connection, password
+	// changes are refused.
+	_, err := db.UpdateUser(context.Background(), dbplugin.UpdateUserRequest{
+		Username: username,
+		Password: &dbplugin.ChangePassword{NewPassword: "second-password"},
+	})
+	if !errors.Is(err, dbutil.ErrEmptyRotationStatement) {
+		t.Fatalf("expected empty rotation statement error, got %v", err)
+	}
+
+	// Role statements take precedence over the connection defaults, and
+	// both changes are applied together.
+	newExpiration := expiration.Add(time.Hour)
+	dbtesting.AssertUpdateUser(t, db, dbplugin.UpdateUserRequest{
+		Username: username,
+		Password: &dbplugin.ChangePassword{
+			NewPassword: "second-password",
+			Statements: dbplugin.Statements{
+				Commands: []string{`UPDATE users SET password = '{{password}}' WHERE name = '{{username}}';`},
+			},
+		},
+		Expiration: &dbplugin.ChangeExpiration{NewExpiration: newExpiration},
+	})
+
+	password, storedExpiration, _ = readUser(t, conn, username)
+	if password != "second-password" || storedExpiration != newExpiration.Format(time.RFC3339) {
+		t.Fatalf("unexpected user after update: %q %q", password, storedExpiration)
+	}
+
+	dbtesting.AssertDeleteUser(t, db, dbplugin.DeleteUserRequest{Username: username})
+	if _, _, found := readUser(t, conn, username); found {
+		t.Fatal("expected user to be deleted")
+	}
+}
+
+func TestSQLTemplate_TransactionRollback(t *testing.T) {
+	connURL, conn := prepareSQLite(t)
+
+	db := newDatabase(t)
+	defer dbtesting.AssertClose(t, db)
+
+	dbtesting.AssertInitialize(t, db, dbplugin.InitializeRequest{
+		Config: map[string]interface{}{
+			"connection_url": connURL,
+			"driver":         sqlite.DriverName,
+		},
+		VerifyConnection: true,
+	})
+
+	// The second statement fails, so the first must not be applied.
+	_, err := db.NewUser(context.Background(), dbplugin.NewUserRequest{
+		UsernameConfig: dbplugin.UsernameMetadata{DisplayName: "token", RoleName: "app"},
+		Password:       "password",
+		Expiration:     time.Now().Add(time.Hour),
+		Statements: dbplugin.Statements{
+			Commands: []string{`INSERT INTO users (name, password) VALUES ('{{name}}', '{{password}}'); INSERT INTO missing VALUES (1);`},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var count int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM users;`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("expected the failed creation to be rolled back, found %d users", count)
+	}
+}
@@ -13,3 +13,7 @@ const AgentPathMetrics = "/agent/v1/metrics"
 
 // AgentPathQuit is the path that the agent will use to trigger stopping it.
 const AgentPathQuit = "/agent/v1/quit"
+
+// AgentPathHealth is the path the agent will use to report the health of
+// its components.
+const AgentPathHealth = "/agent/v1/health"
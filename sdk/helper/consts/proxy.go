@@ -13,3 +13,7 @@ const ProxyPathMetrics = "/proxy/v1/metrics"
 
 // ProxyPathQuit is the path that the proxy will use to trigger stopping it.
 const ProxyPathQuit = "/proxy/v1/quit"
+
+// ProxyPathHealth is the path the proxy will use to report the health of
+// its components.
+const ProxyPathHealth = "/proxy/v1/health"
@@ -31,6 +31,14 @@ var (
 	// ErrPlaintextTooLarge is returned if a plaintext is offered for encryption
 	// that is too large to encrypt in memory
 	ErrPlaintextTooLarge = errors.New("plaintext value too large")
+
+	// ErrBarrierRewrapIncomplete is returned if keys are pruned from the
+	// keyring before a rewrap pass has completed.
+	ErrBarrierRewrapIncomplete = errors.New("barrier rewrap has not completed")
+
+	// ErrBarrierUnknownTerm is returned if an entry is encrypted under a
+	// term the keyring holds no key for.
+	ErrBarrierUnknownTerm = errors.New("no decryption key available for term")
 )
 
 const (
@@ -68,6 +76,11 @@ const (
 	// used by standbys to handle rekeys.  It also comes into play when restoring
 	// raft snapshots.
 	shamirKekPath = "core/shamir-kek"
+
+	// barrierRewrapPath is used to track the progress of re-encrypting the
+	// entries of the barrier to the active term, so that a pass interrupted
+	// by a seal or a leadership change can be resumed.
+	barrierRewrapPath = "core/barrier-rewrap"
 )
 
 // SecurityBarrier is a critical component of Vault. It is used to wrap
@@ -161,6 +174,21 @@ type SecurityBarrier interface {
 	// Check whether an automatic rotation is due
 	CheckBarrierAutoRotate(ctx context.Context) (string, error)
 
+	// Rewrap re-encrypts the entries written under older terms with the
+	// active key, resuming the last pass if it was interrupted
+	Rewrap(ctx context.Context) (*RewrapStatus, error)
+
+	// RewrapStatus returns the progress of the last rewrap pass, or nil
+	RewrapStatus(ctx context.Context) (*RewrapStatus, error)
+
+	// PruneKeys removes the terms no entry is encrypted under anymore
+	// according to the last complete rewrap pass
+	PruneKeys(ctx context.Context) ([]uint32, error)
+
+	// VerifyEntry checks that the entry under the key authenticates with
+	// the key of its term, and returns that term
+	VerifyEntry(ctx context.Context, key string) (uint32, error)
+
 	// SecurityBarrier must provide the storage APIs
 	logical.Storage
 
@@ -198,3 +226,48 @@ type KeyInfo struct {
 	InstallTime time.Time
 	Encryptions int64
 }
+
+// RewrapStatus tracks the progress of a pass re-encrypting the entries of the
+// barrier to a target term.
+type RewrapStatus struct {
+	TargetTerm uint32    `json:"target_term"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+
+	// LastKey is the last key visited. Keys are visited in lexicographical
+	// order, so a resumed pass continues after it.
+	LastKey string `json:"last_key"`
+
+	Scanned   int64 `json:"scanned"`
+	Rewrapped int64 `json:"rewrapped"`
+
+	// ReferencedTerms counts the entries left under a term older than the
+	// target term because they could not be decrypted. These terms are
+	// never pruned.
+	ReferencedTerms map[uint32]int64 `json:"referenced_terms,omitempty"`
+
+	// Error is the error that stopped the last attempt, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Complete returns whether the pass visited every entry
+func (s *RewrapStatus) Complete() bool {
+	return !s.EndTime.IsZero()
+}
+
+// PrunableTerms returns the terms of the keyring that a complete pass left
+// no entry under.
+func (s *RewrapStatus) PrunableTerms(keyring *Keyring) []uint32 {
+	if !s.Complete() {
+		return nil
+	}
+
+	var terms []uint32
+	for _, term := range keyring.Terms() {
+		if term >= s.TargetTerm || term == keyring.ActiveTerm() || s.ReferencedTerms[term] > 0 {
+			continue
+		}
+		terms = append(terms, term)
+	}
+	return terms
+}
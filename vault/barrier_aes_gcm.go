@@ -15,6 +15,7 @@ import (
 	"io"
 	"math"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -25,6 +26,7 @@ import (
 	"github.com/armon/go-metrics"
 	"github.com/hashicorp/go-secure-stdlib/strutil"
 	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/hashicorp/vault/sdk/physical"
 	"go.uber.org/atomic"
@@ -43,6 +45,14 @@ const (
 	// The keyring is persisted before the root key.
 	defaultKeyringTimeout            = 1 * time.Second
 	bestEffortKeyringTimeoutOverride = "VAULT_ENCRYPTION_COUNT_PERSIST_TIMEOUT"
+
+	// rewrapCheckpointInterval is the number of entries a rewrap pass visits
+	// between two persists of its progress.
+	rewrapCheckpointInterval = 256
+
+	// barrierRewrapRetryInterval is how long to wait before resuming a
+	// rewrap pass that failed.
+	barrierRewrapRetryInterval = time.Minute
 )
 
 // Versions of the AESGCM storage methodology
@@ -97,6 +107,11 @@ type AESGCMBarrier struct {
 	totalLocalEncryptions *atomic.Int64
 
 	bestEffortKeyringTimeout time.Duration
+
+	// rewrapLock serializes rewrap passes, and rewrapLocks guard the entries
+	// being rewrapped against concurrent writes and deletes.
+	rewrapLock  sync.Mutex
+	rewrapLocks []*locksutil.LockEntry
 }
 
 func (b *AESGCMBarrier) RotationConfig() (kc KeyRotationConfig, err error) {
@@ -139,6 +154,7 @@ func NewAESGCMBarrier(physical physical.Backend) (*AESGCMBarrier, error) {
 		RemoteEncryptions:        atomic.NewInt64(0),
 		totalLocalEncryptions:    atomic.NewInt64(0),
 		bestEffortKeyringTimeout: keyringTimeout,
+		rewrapLocks:              locksutil.CreateLocks(),
 	}
 	return b, nil
 }
@@ -826,12 +842,18 @@ func (b *AESGCMBarrier) updateRootKeyCommon(key []byte) (*Keyring, error) {
 // Put is used to insert or update an entry
 func (b *AESGCMBarrier) Put(ctx context.Context, entry *logical.StorageEntry) error {
 	defer metrics.MeasureSince([]string{"barrier", "put"}, time.Now())
+
+	// The active term is read under the entry lock so that a rewrap pass
+	// never misses an entry written with the term it replaces
+	lock := locksutil.LockForKey(b.rewrapLocks, entry.Key)
+	lock.RLock()
+	defer lock.RUnlock()
+
 	b.l.RLock()
 	if b.sealed {
 		b.l.RUnlock()
 		return ErrBarrierSealed
 	}
-
 	term := b.keyring.ActiveTerm()
 	primary, err := b.aeadForTerm(term)
 	b.l.RUnlock()
@@ -907,7 +929,7 @@ func (b *AESGCMBarrier) lockSwitchedGet(ctx context.Context, key string, getLock
 		return nil, err
 	}
 	if gcm == nil {
-		return nil, fmt.Errorf("no decryption key available for term %d", term)
+		return nil, fmt.Errorf("%w %d", ErrBarrierUnknownTerm, term)
 	}
 
 	// Decrypt the ciphertext
@@ -935,6 +957,10 @@ func (b *AESGCMBarrier) Delete(ctx context.Context, key string) error {
 		return ErrBarrierSealed
 	}
 
+	lock := locksutil.LockForKey(b.rewrapLocks, key)
+	lock.RLock()
+	defer lock.RUnlock()
+
 	return b.backend.Delete(ctx, key)
 }
 
@@ -1061,7 +1087,7 @@ func termLabel(term uint32) []metrics.Label {
 
 // decrypt is used to decrypt a value using the keyring
 func (b *AESGCMBarrier) decrypt(path string, gcm cipher.AEAD, cipher []byte) ([]byte, error) {
-	if len(cipher) < 5+gcm.NonceSize() {
+	if len(cipher) < 5+gcm.NonceSize()+gcm.Overhead() {
 		return nil, fmt.Errorf("invalid cipher length")
 	}
 	// Capture the parts
@@ -1136,7 +1162,7 @@ func (b *AESGCMBarrier) Decrypt(_ context.Context, key string, ciphertext []byte
 		return nil, err
 	}
 	if gcm == nil {
-		return nil, fmt.Errorf("no decryption key available for term %d", term)
+		return nil, fmt.Errorf("%w %d", ErrBarrierUnknownTerm, term)
 	}
 
 	// Decrypt the ciphertext
@@ -1282,3 +1308,297 @@ func (b *AESGCMBarrier) encryptions() int64 {
 	}
 	return 0
 }
+
+// Rewrap re-encrypts the entries written under a term older than the active
+// term with the active key. Progress is persisted as the pass goes, so that a
+// pass interrupted by a seal, a leadership change or an error resumes where
+// it stopped, unless the active term changed in the meantime.
+func (b *AESGCMBarrier) Rewrap(ctx context.Context) (*RewrapStatus, error) {
+	b.rewrapLock.Lock()
+	defer b.rewrapLock.Unlock()
+
+	b.l.RLock()
+	if b.sealed {
+		b.l.RUnlock()
+		return nil, ErrBarrierSealed
+	}
+	activeTerm := b.keyring.ActiveTerm()
+	terms := len(b.keyring.Terms())
+	b.l.RUnlock()
+
+	status, err := b.RewrapStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case status != nil && status.TargetTerm == activeTerm && status.Complete():
+		return status, nil
+	case status == nil || status.TargetTerm != activeTerm:
+		status = &RewrapStatus{
+			TargetTerm:      activeTerm,
+			StartTime:       time.Now().UTC(),
+			ReferencedTerms: make(map[uint32]int64),
+		}
+	}
+	if status.ReferencedTerms == nil {
+		status.ReferencedTerms = make(map[uint32]int64)
+	}
+	status.Error = ""
+
+	// Every entry is already under the active term if there is no other
+	if terms > 1 {
+		err = b.rewrapPrefix(ctx, status, "")
+	}
+	switch {
+	case err == nil:
+		status.EndTime = time.Now().UTC()
+	case ctx.Err() == nil:
+		status.Error = err.Error()
+	}
+
+	if perr := b.persistRewrapStatus(ctx, status); perr != nil && err == nil {
+		err = perr
+	}
+	return status, err
+}
+
+// RewrapStatus returns the progress of the last rewrap pass, or nil if none
+// was started.
+func (b *AESGCMBarrier) RewrapStatus(ctx context.Context) (*RewrapStatus, error) {
+	entry, err := b.Get(ctx, barrierRewrapPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rewrap status: %w", err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var status RewrapStatus
+	if err := jsonutil.DecodeJSON(entry.Value, &status); err != nil {
+		return nil, fmt.Errorf("failed to decode rewrap status: %w", err)
+	}
+	return &status, nil
+}
+
+func (b *AESGCMBarrier) persistRewrapStatus(ctx context.Context, status *RewrapStatus) error {
+	buf, err := jsonutil.EncodeJSON(status)
+	if err != nil {
+		return fmt.Errorf("failed to encode rewrap status: %w", err)
+	}
+	if err := b.Put(ctx, &logical.StorageEntry{Key: barrierRewrapPath, Value: buf}); err != nil {
+		return fmt.Errorf("failed to persist rewrap status: %w", err)
+	}
+	return nil
+}
+
+// rewrapPrefix visits the entries under the prefix in lexicographical order,
+// skipping those visited before the last checkpoint.
+func (b *AESGCMBarrier) rewrapPrefix(ctx context.Context, status *RewrapStatus, prefix string) error {
+	keys, err := b.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path := prefix + key
+		if strings.HasSuffix(key, "/") {
+			// Every key of the subtree sorts before the last one visited
+			if path < status.LastKey && !strings.HasPrefix(status.LastKey, path) {
+				continue
+			}
+			if err := b.rewrapPrefix(ctx, status, path); err != nil {
+				return err
+			}
+			continue
+		}
+		if path <= status.LastKey {
+			continue
+		}
+
+		if err := b.rewrapEntry(ctx, status, path); err != nil {
+			return err
+		}
+		status.LastKey = path
+		status.Scanned++
+
+		if status.Scanned%rewrapCheckpointInterval == 0 {
+			if err := b.persistRewrapStatus(ctx, status); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rewrapEntry re-encrypts a single entry with the target term of the pass if
+// it is encrypted under an older term.
+func (b *AESGCMBarrier) rewrapEntry(ctx context.Context, status *RewrapStatus, key string) error {
+	switch key {
+	case keyringPath, barrierInitPath:
+		// Encrypted by the root key
+		return nil
+	case rootKeyPath, barrierRewrapPath:
+		// Always written with the active term
+		return nil
+	}
+
+	lock := locksutil.LockForKey(b.rewrapLocks, key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	pe, err := b.backend.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if pe == nil || len(pe.Value) < termSize {
+		return nil
+	}
+
+	term := binary.BigEndian.Uint32(pe.Value[:termSize])
+	if term >= status.TargetTerm {
+		return nil
+	}
+
+	b.l.RLock()
+	if b.sealed {
+		b.l.RUnlock()
+		return ErrBarrierSealed
+	}
+	gcm, err := b.aeadForTerm(term)
+	if err != nil {
+		b.l.RUnlock()
+		return err
+	}
+	target, err := b.aeadForTerm(status.TargetTerm)
+	b.l.RUnlock()
+	if err != nil {
+		return err
+	}
+	if gcm == nil {
+		// Not written by the barrier
+		return nil
+	}
+	if target == nil {
+		return fmt.Errorf("no encryption key available for term %d", status.TargetTerm)
+	}
+
+	// Standbys read the upgrade entries with the keys they already have.
+	// They only need the previous term until they install the new one, so
+	// it does not have to be kept around for them.
+	if strings.HasPrefix(key, keyringUpgradePrefix) {
+		return nil
+	}
+
+	// Values that only look like barrier entries cannot be told apart from
+	// damaged ones, so their term is kept around
+	plain, err := b.decrypt(key, gcm, pe.Value)
+	if err != nil {
+		status.ReferencedTerms[term]++
+		return nil
+	}
+	defer memzero(plain)
+
+	value, err := b.encryptTracked(key, status.TargetTerm, target, plain)
+	if err != nil {
+		return err
+	}
+	if err := b.backend.Put(ctx, &physical.Entry{
+		Key:      key,
+		Value:    value,
+		SealWrap: pe.SealWrap,
+	}); err != nil {
+		return err
+	}
+
+	status.Rewrapped++
+	return nil
+}
+
+// VerifyEntry decrypts the entry stored under the key without returning it,
+// and returns the term it is encrypted under. An entry that does not exist is
+// reported under term 0 without error.
+func (b *AESGCMBarrier) VerifyEntry(ctx context.Context, key string) (uint32, error) {
+	b.l.RLock()
+	if b.sealed {
+		b.l.RUnlock()
+		return 0, ErrBarrierSealed
+	}
+	b.l.RUnlock()
+
+	pe, err := b.backend.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if pe == nil {
+		return 0, nil
+	}
+	if len(pe.Value) < termSize {
+		return 0, errors.New("invalid value")
+	}
+
+	term := binary.BigEndian.Uint32(pe.Value[:termSize])
+	b.l.RLock()
+	gcm, err := b.aeadForTerm(term)
+	b.l.RUnlock()
+	if err != nil {
+		return term, err
+	}
+	if gcm == nil {
+		return term, fmt.Errorf("%w %d", ErrBarrierUnknownTerm, term)
+	}
+
+	plain, err := b.decrypt(key, gcm, pe.Value)
+	if err != nil {
+		return term, fmt.Errorf("decryption failed: %w", err)
+	}
+	memzero(plain)
+	return term, nil
+}
+
+// PruneKeys removes the terms the last complete rewrap pass left no entry
+// under from the keyring, and returns them.
+func (b *AESGCMBarrier) PruneKeys(ctx context.Context) ([]uint32, error) {
+	status, err := b.RewrapStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil || !status.Complete() {
+		return nil, ErrBarrierRewrapIncomplete
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.sealed {
+		return nil, ErrBarrierSealed
+	}
+
+	terms := status.PrunableTerms(b.keyring)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	keyring := b.keyring
+	for _, term := range terms {
+		keyring, err = keyring.RemoveKey(term)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := b.persistKeyring(ctx, keyring); err != nil {
+		return nil, err
+	}
+	b.keyring = keyring
+
+	b.cacheLock.Lock()
+	for _, term := range terms {
+		delete(b.cache, term)
+	}
+	b.cacheLock.Unlock()
+
+	return terms, nil
+}
@@ -7,7 +7,9 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
@@ -772,3 +774,125 @@ func TestBarrier_persistKeyring_Context(t *testing.T) {
 		})
 	}
 }
+
+// termOf returns the term the physical entry at the key is encrypted under
+func termOf(t *testing.T, inm physical.Backend, key string) uint32 {
+	t.Helper()
+	pe, err := inm.Get(context.Background(), key)
+	require.NoError(t, err)
+	require.NotNil(t, pe)
+	return binary.BigEndian.Uint32(pe.Value[:termSize])
+}
+
+func TestAESGCMBarrier_Rewrap(t *testing.T) {
+	ctx := context.Background()
+	inm, b, _ := mockBarrier(t)
+
+	var keys []string
+	for i := 0; i < 2*rewrapCheckpointInterval; i++ {
+		key := fmt.Sprintf("logical/%d/entry-%d", i%7, i)
+		require.NoError(t, b.Put(ctx, &logical.StorageEntry{Key: key, Value: []byte(key)}))
+		keys = append(keys, key)
+	}
+
+	_, err := b.Rotate(ctx, rand.Reader)
+	require.NoError(t, err)
+	term, err := b.Rotate(ctx, rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, b.CreateUpgrade(ctx, term))
+
+	// A value that is not a barrier entry but starts like one of term 1
+	require.NoError(t, inm.Put(ctx, &physical.Entry{Key: "raw/value", Value: []byte{0, 0, 0, 1, 'x', 'y'}}))
+
+	_, err = b.PruneKeys(ctx)
+	require.ErrorIs(t, err, ErrBarrierRewrapIncomplete)
+
+	status, err := b.Rewrap(ctx)
+	require.NoError(t, err)
+	require.True(t, status.Complete())
+	require.Equal(t, term, status.TargetTerm)
+	require.EqualValues(t, len(keys), status.Rewrapped)
+	require.Equal(t, map[uint32]int64{1: 1}, status.ReferencedTerms)
+
+	for _, key := range keys {
+		require.Equal(t, term, termOf(t, inm, key))
+	}
+	// The upgrade entry stays readable by standbys on the previous term
+	require.Equal(t, term-1, termOf(t, inm, fmt.Sprintf("%s%d", keyringUpgradePrefix, term-1)))
+
+	// The status is persisted
+	stored, err := b.RewrapStatus(ctx)
+	require.NoError(t, err)
+	require.Equal(t, status, stored)
+
+	pruned, err := b.PruneKeys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{term - 1}, pruned)
+
+	keyring, err := b.Keyring()
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, term}, keyring.Terms())
+
+	for _, key := range keys {
+		entry, err := b.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, key, string(entry.Value))
+	}
+
+	// The pruned term stays pruned once the keyring is reloaded
+	require.NoError(t, b.ReloadKeyring(ctx))
+	keyring, err = b.Keyring()
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, term}, keyring.Terms())
+}
+
+func TestAESGCMBarrier_Rewrap_Resume(t *testing.T) {
+	ctx := context.Background()
+	inm, b, _ := mockBarrier(t)
+	barrier := b.(*AESGCMBarrier)
+
+	for _, key := range []string{"a", "b/1", "b/2", "c/1", "d"} {
+		require.NoError(t, b.Put(ctx, &logical.StorageEntry{Key: key, Value: []byte(key)}))
+	}
+	term, err := b.Rotate(ctx, rand.Reader)
+	require.NoError(t, err)
+
+	// An interrupted pass resumes after the last key it visited
+	require.NoError(t, barrier.persistRewrapStatus(ctx, &RewrapStatus{
+		TargetTerm: term,
+		StartTime:  time.Now().UTC(),
+		LastKey:    "b/1",
+		Scanned:    2,
+		Error:      "interrupted",
+	}))
+
+	status, err := b.Rewrap(ctx)
+	require.NoError(t, err)
+	require.True(t, status.Complete())
+	require.Empty(t, status.Error)
+	require.EqualValues(t, 3, status.Rewrapped)
+
+	for key, expected := range map[string]uint32{
+		"a":   term - 1,
+		"b/1": term - 1,
+		"b/2": term,
+		"c/1": term,
+		"d":   term,
+	} {
+		require.Equal(t, expected, termOf(t, inm, key), key)
+	}
+
+	// A rotation starts a new pass from the beginning
+	term, err = b.Rotate(ctx, rand.Reader)
+	require.NoError(t, err)
+	status, err = b.Rewrap(ctx)
+	require.NoError(t, err)
+	require.Equal(t, term, status.TargetTerm)
+	for _, key := range []string{"a", "b/1", "b/2", "c/1", "d"} {
+		require.Equal(t, term, termOf(t, inm, key), key)
+	}
+
+	pruned, err := b.PruneKeys(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{term - 2, term - 1}, pruned)
+}
@@ -269,6 +269,18 @@ type Core struct {
 	// physical backend is the un-trusted backend with durable data
 	physical physical.Backend
 
+	// storageMigration wraps the underlying physical backend to migrate its
+	// data to another storage backend while the node is active
+	storageMigration *storageMigrator
+
+	// storageVerify checks the consistency of the data in storage on the
+	// active node
+	storageVerify *storageVerifier
+
+	// physicalBackends are the storage backends available as the destination
+	// of an online storage migration
+	physicalBackends map[string]physical.Factory
+
 	// serviceRegistration is the ServiceRegistration network
 	serviceRegistration sr.ServiceRegistration
 
@@ -307,6 +319,14 @@ type Core struct {
 	migrationInfo     *migrationInformation
 	sealMigrationDone *uint32
 
+	// multisealEnabled allows several seals to be enabled at the same time,
+	// in which case values are wrapped with all of them
+	multisealEnabled atomic.Bool
+
+	// sealRewrap rewraps the values wrapped by the seal with all the enabled
+	// seals after the seal configuration changes
+	sealRewrap *sealRewrapper
+
 	// barrier is the security barrier wrapping the physical backend
 	barrier SecurityBarrier
 
@@ -337,6 +357,10 @@ type Core struct {
 	keepHALockOnStepDown *uint32
 	heldHALock           physical.Lock
 
+	// readReplica is set while this node is a raft non-voter serving reads
+	// from its local FSM.
+	readReplica atomic.Pointer[readReplicaState]
+
 	// shutdownDoneCh is used to notify when core.Shutdown() completes.
 	// core.Shutdown() is typically issued in a goroutine to allow Vault to
 	// release the stateLock. This channel is marked atomic to prevent race
@@ -617,6 +641,12 @@ type Core struct {
 	raftFollowerStates *raft.FollowerStates
 	// Stop channel for raft TLS rotations
 	raftTLSRotationStopCh chan struct{}
+
+	// raftAutoSnapshots runs the automated snapshot schedules on the active
+	// node when raft is the storage backend. It is swapped on post-unseal and
+	// pre-seal while request handlers read it, so it is accessed atomically.
+	raftAutoSnapshots atomic.Pointer[autoSnapshotManager]
+
 	// Stores the pending peers we are waiting to give answers
 	pendingRaftPeers *sync.Map
 
@@ -656,6 +686,11 @@ type Core struct {
 
 	autoRotateCancel context.CancelFunc
 
+	// barrierRewrapCancel stops the background pass re-encrypting the
+	// entries of the barrier to the active term
+	barrierRewrapCancel context.CancelFunc
+	barrierRewrapLock   sync.Mutex
+
 	updateLockedUserEntriesCancel context.CancelFunc
 
 	// number of workers to use for lease revocation in the expiration manager
@@ -770,6 +805,10 @@ type CoreConfig struct {
 
 	StorageType string
 
+	// PhysicalBackends are the storage backends an online storage migration
+	// can move the data to
+	PhysicalBackends map[string]physical.Factory
+
 	// May be nil, which disables HA operations
 	HAPhysical physical.HABackend
 
@@ -880,6 +919,9 @@ type CoreConfig struct {
 	// DisableSSCTokens is used to disable the use of server side consistent tokens
 	DisableSSCTokens bool
 
+	// EnableMultiseal allows several seals to be enabled at the same time
+	EnableMultiseal bool
+
 	EffectiveSDKVersion string
 
 	RollbackPeriod time.Duration
@@ -1014,6 +1056,7 @@ func CreateCore(conf *CoreConfig) (*Core, error) {
 		entCore:              entCore{},
 		devToken:             conf.DevToken,
 		physical:             conf.Physical,
+		physicalBackends:     conf.PhysicalBackends,
 		serviceRegistration:  conf.GetServiceRegistration(),
 		underlyingPhysical:   conf.Physical,
 		storageType:          conf.StorageType,
@@ -1305,6 +1348,17 @@ func NewCore(conf *CoreConfig) (*Core, error) {
 		return nil, err
 	}
 
+	// Storage verification
+	storageVerifyLogger := conf.Logger.Named("storage.verify")
+	c.allLoggers = append(c.allLoggers, storageVerifyLogger)
+	c.storageVerify = newStorageVerifier(c, storageVerifyLogger)
+
+	// Seal rewrapping
+	c.multisealEnabled.Store(conf.EnableMultiseal)
+	sealRewrapLogger := conf.Logger.Named("seal.rewrap")
+	c.allLoggers = append(c.allLoggers, sealRewrapLogger)
+	c.sealRewrap = newSealRewrapper(c, sealRewrapLogger)
+
 	err = c.adjustForSealMigration(conf.UnwrapSeal)
 	if err != nil {
 		return nil, err
@@ -1499,6 +1553,17 @@ func (c *Core) DisableSSCTokens() bool {
 	return c.disableSSCTokens
 }
 
+// IsMultisealEnabled returns whether several seals can be enabled at the same
+// time.
+func (c *Core) IsMultisealEnabled() bool {
+	return c.multisealEnabled.Load()
+}
+
+// SetMultisealEnabled is used when the seal configuration is reloaded.
+func (c *Core) SetMultisealEnabled(enabled bool) {
+	c.multisealEnabled.Store(enabled)
+}
+
 // ShutdownCoreError logs a shutdown error and shuts down the Vault core.
 func (c *Core) ShutdownCoreError(err error) {
 	c.Logger().Error("shutting down core", "error", err)
@@ -2450,6 +2515,11 @@ func (s standardUnsealStrategy) unseal(ctx context.Context, logger log.Logger, c
 		go c.autoRotateBarrierLoop(autoRotateCtx)
 	}
 
+	if c.isPrimary() {
+		// Resume any rewrap pass interrupted by a seal or a leadership change
+		c.startBarrierRewrap()
+	}
+
 	// Run setup-like functions
 	if err := runUnsealSetupFunctions(ctx, buildUnsealSetupFunctionSlice(c)); err != nil {
 		return err
@@ -2581,10 +2651,10 @@ func buildUnsealSetupFunctionSlice(c *Core) []func(context.Context) error {
 		},
 		c.setupCredentials,
 		func(ctx context.Context) error {
-			return c.setupQuotas(ctx, false)
+			return c.setupQuotas(ctx, c.perfStandby)
 		},
 		func(ctx context.Context) error {
-			return c.setupHeaderHMACKey(ctx, false)
+			return c.setupHeaderHMACKey(ctx, c.perfStandby)
 		},
 	}
 
@@ -2599,10 +2669,16 @@ func buildUnsealSetupFunctionSlice(c *Core) []func(context.Context) error {
 
 		setupFunctions = append(temp, setupFunctions...)
 		setupFunctions = append(setupFunctions, func(_ context.Context) error {
-			c.updateLockedUserEntries()
+			if !c.perfStandby {
+				c.updateLockedUserEntries()
+			}
 			return nil
 		})
 		setupFunctions = append(setupFunctions, func(_ context.Context) error {
+			// Rollbacks write to storage, so leave them to the active node
+			if c.perfStandby {
+				return nil
+			}
 			return c.startRollback()
 		})
 		setupFunctions = append(setupFunctions, func(_ context.Context) error {
@@ -2751,8 +2827,10 @@ func (c *Core) postUnseal(ctx context.Context, ctxCancelFunc context.CancelFunc,
 	// the keys used for auto unsealing ensures Vault and its data will
 	// continue to be accessible even after prior seal keys are destroyed.
 	if seal, ok := c.seal.(*autoSeal); ok {
-		if err := seal.UpgradeKeys(c.activeContext); err != nil {
-			c.logger.Warn("post-unseal upgrade seal keys failed", "error", err)
+		if !c.perfStandby {
+			if err := seal.UpgradeKeys(c.activeContext); err != nil {
+				c.logger.Warn("post-unseal upgrade seal keys failed", "error", err)
+			}
 		}
 
 		// Start a periodic but infrequent heartbeat to detect auto-seal backend outages at runtime rather than being
@@ -2886,6 +2964,8 @@ func (c *Core) preSeal() error {
 		c.autoRotateCancel = nil
 	}
 
+	c.stopBarrierRewrap()
+
 	if c.updateLockedUserEntriesCancel != nil {
 		c.updateLockedUserEntriesCancel()
 		c.updateLockedUserEntriesCancel = nil
@@ -2902,6 +2982,9 @@ func (c *Core) preSeal() error {
 		result = multierror.Append(result, fmt.Errorf("error tearing down login MFA, error: %w", err))
 	}
 
+	c.stopStorageMigration()
+	c.stopStorageVerify()
+
 	preSealPhysical(c)
 
 	c.logger.Info("pre-seal teardown complete")
@@ -3777,6 +3860,53 @@ func (c *Core) checkBarrierAutoRotate(ctx context.Context) {
 	}
 }
 
+// startBarrierRewrap starts a background pass re-encrypting the entries of the
+// barrier to the active term, replacing the pass already running if any.
+func (c *Core) startBarrierRewrap() {
+	c.barrierRewrapLock.Lock()
+	defer c.barrierRewrapLock.Unlock()
+
+	if c.barrierRewrapCancel != nil {
+		c.barrierRewrapCancel()
+	}
+	var ctx context.Context
+	ctx, c.barrierRewrapCancel = context.WithCancel(c.activeContext)
+	go c.barrierRewrapLoop(ctx)
+}
+
+func (c *Core) stopBarrierRewrap() {
+	c.barrierRewrapLock.Lock()
+	defer c.barrierRewrapLock.Unlock()
+
+	if c.barrierRewrapCancel != nil {
+		c.barrierRewrapCancel()
+		c.barrierRewrapCancel = nil
+	}
+}
+
+// barrierRewrapLoop runs a rewrap pass to completion, retrying after errors
+func (c *Core) barrierRewrapLoop(ctx context.Context) {
+	for {
+		status, err := c.barrier.Rewrap(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			if status.Rewrapped > 0 {
+				c.logger.Info("re-encrypted barrier entries with the active key", "term", status.TargetTerm, "rewrapped", status.Rewrapped)
+			}
+			return
+		}
+
+		c.logger.Error("error re-encrypting barrier entries, will retry", "error", err, "retry_in", barrierRewrapRetryInterval)
+		select {
+		case <-time.After(barrierRewrapRetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (c *Core) isPrimary() bool {
 	return !c.ReplicationState().HasState(consts.ReplicationPerformanceSecondary | consts.ReplicationDRSecondary)
 }
@@ -4522,6 +4652,13 @@ func (c *Core) setSeals(ctx context.Context, grabLock bool, barrierSeal Seal, se
 		}
 	}
 
+	// The rewrap and the health checks of the previous seals are restarted
+	// with the new ones by reloadSealsEnt
+	stopPartialSealRewrapping(c)
+	if previousSeal, ok := c.seal.(*autoSeal); ok && c.IsMultisealEnabled() {
+		previousSeal.StopHealthCheck()
+	}
+
 	c.seal = barrierSeal
 
 	return c.reloadSealsEnt(secureRandomReader, barrierSeal, c.logger, shouldRewrap)
@@ -100,7 +100,3 @@ func (c *Core) EntWaitUntilWALShipped(ctx context.Context, index uint64) bool {
 }
 
 func (c *Core) SecretsSyncLicensedActivated() bool { return false }
-
-func (c *Core) IsMultisealEnabled() bool { return false }
-
-func (c *Core) SetMultisealEnabled(_ bool) {}
@@ -10,6 +10,7 @@ import (
 	"fmt"
 
 	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/limits"
 	"github.com/hashicorp/vault/sdk/helper/license"
@@ -39,7 +40,10 @@ type LicensingConfig struct {
 }
 
 func coreInit(c *Core, conf *CoreConfig) error {
-	phys := conf.Physical
+	storageMigrationLogger := conf.Logger.Named("storage.migration")
+	c.allLoggers = append(c.allLoggers, storageMigrationLogger)
+	var phys physical.Backend
+	c.storageMigration, phys = newStorageMigrator(conf.Physical, storageMigrationLogger)
 	_, txnOK := phys.(physical.Transactional)
 	sealUnwrapperLogger := conf.Logger.Named("storage.sealunwrapper")
 	c.allLoggers = append(c.allLoggers, sealUnwrapperLogger)
@@ -141,8 +145,20 @@ func (c *Core) collectNamespaces() []*namespace.Namespace {
 	}
 }
 
+// HasWALState returns whether this node has caught up with the given state.
+// Only read replicas can lag behind the active node; they wait up to their
+// configured maximum staleness for the state to be applied.
 func (c *Core) HasWALState(required *logical.WALState, perfStandby bool) bool {
-	return true
+	replica := c.readReplica.Load()
+	if !perfStandby || replica == nil || required == nil {
+		return true
+	}
+
+	if required.ClusterID != "" && required.ClusterID != c.ClusterID() {
+		return false
+	}
+
+	return replica.waitForIndex(required.LocalIndex)
 }
 
 func (c *Core) setupReplicatedClusterPrimary(*replication.Cluster) error { return nil }
@@ -199,7 +215,30 @@ func (c *Core) ForwardToActive() string {
 	return ""
 }
 
+// MissingRequiredState returns whether any of the given X-Vault-Index header
+// values refers to a state this node has not caught up with. Invalid header
+// values are ignored.
 func (c *Core) MissingRequiredState(raw []string, perfStandby bool) bool {
+	if !perfStandby || c.readReplica.Load() == nil {
+		return false
+	}
+
+	for _, r := range raw {
+		state, err := api.ParseReplicationState(r, c.headerHMACKey())
+		if err != nil {
+			c.logger.Warn("ignoring invalid index header", "error", err)
+			continue
+		}
+
+		if !c.HasWALState(&logical.WALState{
+			ClusterID:       state.ClusterID,
+			LocalIndex:      state.LocalIndex,
+			ReplicatedIndex: state.ReplicatedIndex,
+		}, perfStandby) {
+			return true
+		}
+	}
+
 	return false
 }
 
@@ -5,6 +5,11 @@ package vault
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/command/server"
@@ -41,6 +46,22 @@ func (c *Core) headerHMACKey() []byte {
 	return key.([]byte)
 }
 
+// EncodeIndexState returns the value of the X-Vault-Index header for the
+// given state, signed with the cluster's header HMAC key. An empty string is
+// returned if the key hasn't been set up yet.
+func (c *Core) EncodeIndexState(state *logical.WALState) string {
+	key := c.headerHMACKey()
+	if state == nil || len(key) == 0 {
+		return ""
+	}
+
+	raw := fmt.Sprintf("v1:%s:%d:%d", state.ClusterID, state.LocalIndex, state.ReplicatedIndex)
+	hm := hmac.New(sha256.New, key)
+	hm.Write([]byte(raw))
+
+	return base64.StdEncoding.EncodeToString([]byte(raw + ":" + hex.EncodeToString(hm.Sum(nil))))
+}
+
 func (c *Core) setupHeaderHMACKey(ctx context.Context, isPerfStandby bool) error {
 	if c.IsPerfSecondary() || c.IsDRSecondary() || isPerfStandby {
 		return c.loadHeaderHMACKey(ctx)
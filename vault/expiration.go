@@ -408,6 +408,13 @@ func (c *Core) setupExpiration(e ExpireLeaseStrategy) error {
 	// Link the token store to this
 	c.tokenStore.SetExpirationManager(mgr)
 
+	// Leases are loaded from storage on demand and revoked by the active node,
+	// so there is nothing to restore or schedule on a performance standby.
+	if c.perfStandby {
+		atomic.StoreInt32(mgr.restoreMode, 0)
+		return nil
+	}
+
 	// Restore the existing state
 	c.logger.Info("restoring leases")
 	errorFunc := func() {
@@ -6,12 +6,19 @@ package rafttests
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -21,6 +28,7 @@ import (
 	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/api"
 	credUserpass "github.com/hashicorp/vault/builtin/credential/userpass"
+	"github.com/hashicorp/vault/builtin/logical/transit"
 	"github.com/hashicorp/vault/helper/benchhelpers"
 	"github.com/hashicorp/vault/helper/constants"
 	"github.com/hashicorp/vault/helper/namespace"
@@ -485,6 +493,258 @@ func TestRaft_SnapshotAPI(t *testing.T) {
 	}
 }
 
+func TestRaft_SnapshotAuto(t *testing.T) {
+	t.Parallel()
+	cluster, _ := raftCluster(t, nil)
+	defer cluster.Cleanup()
+
+	leaderClient := cluster.Cores[0].Client
+	dir := t.TempDir()
+
+	_, err := leaderClient.Logical().Write("sys/storage/raft/snapshot-auto/config/frequent", map[string]interface{}{
+		"interval":     "1s",
+		"retain":       2,
+		"storage_type": "local",
+		"path_prefix":  dir,
+	})
+	require.NoError(t, err)
+
+	// Invalid configurations are refused
+	_, err = leaderClient.Logical().Write("sys/storage/raft/snapshot-auto/config/invalid", map[string]interface{}{
+		"interval":     "1s",
+		"storage_type": "aws-s3",
+	})
+	require.Error(t, err)
+
+	config, err := leaderClient.Logical().Read("sys/storage/raft/snapshot-auto/config/frequent")
+	require.NoError(t, err)
+	require.Equal(t, "vault-snapshot", config.Data["file_prefix"])
+	require.Equal(t, dir, config.Data["path_prefix"])
+
+	list, err := leaderClient.Logical().List("sys/storage/raft/snapshot-auto/config")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"frequent"}, list.Data["keys"])
+
+	// Wait for more snapshots to be taken than are retained
+	var taken []string
+	corehelpers.RetryUntil(t, 30*time.Second, func() error {
+		status, err := leaderClient.Logical().Read("sys/storage/raft/snapshot-auto/status/frequent")
+		if err != nil {
+			return err
+		}
+		if status.Data["last_snapshot_error"] != "" {
+			return fmt.Errorf("snapshot failed: %v", status.Data["last_snapshot_error"])
+		}
+		url, _ := status.Data["last_snapshot_url"].(string)
+		if url == "" {
+			return errors.New("no snapshot taken yet")
+		}
+		if len(taken) == 0 || taken[len(taken)-1] != url {
+			taken = append(taken, url)
+		}
+		if len(taken) < 3 {
+			return fmt.Errorf("%d snapshots taken", len(taken))
+		}
+		return nil
+	})
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".snap") {
+			names = append(names, entry.Name())
+		}
+	}
+	require.Len(t, names, 2, "older snapshots are removed")
+
+	// The saved snapshots are valid archives
+	snap, err := os.ReadFile(filepath.Join(dir, names[len(names)-1]))
+	require.NoError(t, err)
+	rb := cluster.Cores[0].UnderlyingRawStorage.(*raft.RaftBackend)
+	_, cleanup, _, err := rb.WriteSnapshotToTemp(io.NopCloser(bytes.NewReader(snap)), nil)
+	require.NoError(t, err)
+	cleanup()
+
+	_, err = leaderClient.Logical().Delete("sys/storage/raft/snapshot-auto/config/frequent")
+	require.NoError(t, err)
+	status, err := leaderClient.Logical().Read("sys/storage/raft/snapshot-auto/status/frequent")
+	require.NoError(t, err)
+	require.Nil(t, status)
+}
+
+// TestRaft_SnapshotAuto_Envelope tests that automated snapshots are wrapped in
+// an envelope whose data key is generated by a transit key that is not
+// exportable.
+func TestRaft_SnapshotAuto_Envelope(t *testing.T) {
+	t.Parallel()
+	conf, opts := raftClusterBuilder(t, nil)
+	conf.LogicalBackends = map[string]logical.Factory{
+		"transit": transit.Factory,
+	}
+	cluster := vault.NewTestCluster(t, conf, &opts)
+	defer cluster.Cleanup()
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+
+	leaderClient := cluster.Cores[0].Client
+	dir := t.TempDir()
+
+	require.NoError(t, leaderClient.Sys().Mount("transit", &api.MountInput{Type: "transit"}))
+	_, err := leaderClient.Logical().Write("transit/keys/snapshots", nil)
+	require.NoError(t, err)
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+
+	_, err = leaderClient.Logical().Write("sys/storage/raft/snapshot-auto/config/envelope", map[string]interface{}{
+		"interval":             "1h",
+		"storage_type":         "local",
+		"path_prefix":          dir,
+		"envelope_transit_key": "transit/snapshots",
+		"envelope_signing_key": string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	})
+	require.NoError(t, err)
+
+	config, err := leaderClient.Logical().Read("sys/storage/raft/snapshot-auto/config/envelope")
+	require.NoError(t, err)
+	require.Equal(t, "transit/snapshots", config.Data["envelope_transit_key"])
+	require.NotContains(t, config.Data, "envelope_signing_key")
+
+	var url string
+	corehelpers.RetryUntil(t, 30*time.Second, func() error {
+		status, err := leaderClient.Logical().Read("sys/storage/raft/snapshot-auto/status/envelope")
+		if err != nil {
+			return err
+		}
+		if status.Data["last_snapshot_error"] != "" {
+			return fmt.Errorf("snapshot failed: %v", status.Data["last_snapshot_error"])
+		}
+		url, _ = status.Data["last_snapshot_url"].(string)
+		if url == "" {
+			return errors.New("no snapshot taken yet")
+		}
+		return nil
+	})
+
+	envelope, err := os.ReadFile(strings.TrimPrefix(url, "file://"))
+	require.NoError(t, err)
+	snap := new(bytes.Buffer)
+	_, err = raft.OpenSnapshotEnvelope(snap, bytes.NewReader(envelope), &raft.SnapshotEnvelopeOpenOptions{
+		TransitKeyID: "transit/snapshots",
+		TransitDecrypt: func(ciphertext string) ([]byte, error) {
+			secret, err := leaderClient.Logical().Write("transit/decrypt/snapshots", map[string]interface{}{
+				"ciphertext": ciphertext,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return base64.StdEncoding.DecodeString(secret.Data["plaintext"].(string))
+		},
+		VerifyKey: pub,
+	})
+	require.NoError(t, err)
+
+	rb := cluster.Cores[0].UnderlyingRawStorage.(*raft.RaftBackend)
+	_, cleanup, _, err := rb.WriteSnapshotToTemp(io.NopCloser(snap), nil)
+	require.NoError(t, err)
+	cleanup()
+}
+
+func TestRaft_SnapshotAPI_PartialRestore(t *testing.T) {
+	t.Parallel()
+	cluster, _ := raftCluster(t, nil)
+	defer cluster.Cleanup()
+
+	leaderClient := cluster.Cores[0].Client
+
+	for _, key := range []string{"a", "b"} {
+		_, err := leaderClient.Logical().Write("secret/"+key, map[string]interface{}{
+			"test": "data",
+		})
+		require.NoError(t, err)
+	}
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, leaderClient.Sys().RaftSnapshot(buf))
+	snap := buf.Bytes()
+
+	_, err := leaderClient.Logical().Delete("secret/a")
+	require.NoError(t, err)
+	_, err = leaderClient.Logical().Write("secret/b", map[string]interface{}{
+		"test": "updated",
+	})
+	require.NoError(t, err)
+	_, err = leaderClient.Logical().Write("secret/c", map[string]interface{}{
+		"test": "data",
+	})
+	require.NoError(t, err)
+
+	mounts, err := leaderClient.Sys().ListMounts()
+	require.NoError(t, err)
+	uuid := mounts["secret/"].UUID
+	require.NotEmpty(t, uuid)
+
+	// Only the storage of mounts can be partially restored, and not the one
+	// of singleton mounts
+	cubbyholeUUID := mounts["cubbyhole/"].UUID
+	require.NotEmpty(t, cubbyholeUUID)
+	for _, prefix := range []string{"core/", "sys/token/", "sys/expire/", "sys/policy/", "logical", "logical/" + cubbyholeUUID + "/"} {
+		_, err = leaderClient.Sys().RaftSnapshotPartialRestore(bytes.NewReader(snap), &api.RaftSnapshotPartialRestoreInput{
+			Prefix: prefix,
+			DryRun: true,
+		})
+		require.Error(t, err, prefix)
+	}
+
+	result, err := leaderClient.Sys().RaftSnapshotPartialRestore(bytes.NewReader(snap), &api.RaftSnapshotPartialRestoreInput{
+		Prefix: "logical/" + uuid + "/a",
+		DryRun: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"logical/" + uuid + "/a"}, result.Created)
+	require.Empty(t, result.Updated)
+
+	// A dry run only reports the changes
+	result, err = leaderClient.Sys().RaftSnapshotPartialRestore(bytes.NewReader(snap), &api.RaftSnapshotPartialRestoreInput{
+		MountUUID: uuid,
+		DryRun:    true,
+	})
+	require.NoError(t, err)
+	require.True(t, result.DryRun)
+	require.Equal(t, "logical/"+uuid+"/", result.Prefix)
+	require.Equal(t, []string{"logical/" + uuid + "/a"}, result.Created)
+	require.Equal(t, []string{"logical/" + uuid + "/b"}, result.Updated)
+	secret, err := leaderClient.Logical().Read("secret/a")
+	require.NoError(t, err)
+	require.Nil(t, secret)
+
+	result, err = leaderClient.Sys().RaftSnapshotPartialRestore(bytes.NewReader(snap), &api.RaftSnapshotPartialRestoreInput{
+		MountUUID: uuid,
+	})
+	require.NoError(t, err)
+	require.False(t, result.DryRun)
+	require.Len(t, result.Created, 1)
+	require.Len(t, result.Updated, 1)
+
+	for key, value := range map[string]string{"a": "data", "b": "data", "c": "data"} {
+		secret, err := leaderClient.Logical().Read("secret/" + key)
+		require.NoError(t, err)
+		require.NotNil(t, secret, key)
+		require.Equal(t, value, secret.Data["test"], key)
+	}
+
+	// Restoring again changes nothing
+	result, err = leaderClient.Sys().RaftSnapshotPartialRestore(bytes.NewReader(snap), &api.RaftSnapshotPartialRestoreInput{
+		MountUUID: uuid,
+	})
+	require.NoError(t, err)
+	require.Empty(t, result.Created)
+	require.Empty(t, result.Updated)
+	require.Equal(t, 2, result.Unchanged)
+}
+
 func TestRaft_SnapshotAPI_MidstreamFailure(t *testing.T) {
 	// defer goleak.VerifyNone(t)
 	t.Parallel()
@@ -461,6 +461,18 @@ func (c *Core) runStandby(doneCh, manualStepDownCh, stopCh chan struct{}) {
 			c.logger.Debug("shutting down periodic metrics")
 		})
 	}
+	if _, _, ok := c.readReplicaBackend(); ok {
+		// Serve reads from the local FSM while we're a non-voter
+		readReplicaStop := make(chan struct{})
+
+		g.Add(func() error {
+			c.runReadReplica(readReplicaStop)
+			return nil
+		}, func(error) {
+			close(readReplicaStop)
+			c.logger.Debug("shutting down read replica")
+		})
+	}
 	{
 		// Wait for leadership
 		leaderStopCh := make(chan struct{})
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/hashicorp/vault/sdk/helper/jsonutil"
@@ -173,6 +174,16 @@ func (k *Keyring) TermKey(term uint32) *Key {
 	return k.keys[term]
 }
 
+// Terms returns the installed terms in ascending order
+func (k *Keyring) Terms() []uint32 {
+	terms := make([]uint32, 0, len(k.keys))
+	for term := range k.keys {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(i, j int) bool { return terms[i] < terms[j] })
+	return terms
+}
+
 // SetRootKey is used to update the root key
 func (k *Keyring) SetRootKey(val []byte) *Keyring {
 	valCopy := make([]byte, len(val))
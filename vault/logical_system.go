@@ -120,6 +120,7 @@ func NewSystemBackend(core *Core, logger log.Logger, config *logical.BackendConf
 				"replication/dr/reindex",
 				"replication/performance/reindex",
 				"rotate",
+				"rotate/prune",
 				"config/cors",
 				"config/auditing/*",
 				"config/ui/headers/*",
@@ -131,6 +132,11 @@ func NewSystemBackend(core *Core, logger log.Logger, config *logical.BackendConf
 				"leases/revoke-force/*",
 				"leases/lookup/*",
 				"storage/raft/snapshot-auto/config/*",
+				"storage/raft/snapshot-partial-restore",
+				"storage/migration",
+				"storage/migration/*",
+				"storage/verify",
+				"sealwrap/rewrap",
 				"leases",
 				"internal/inspect/*",
 				// sys/seal and sys/step-down actually have their sudo requirement enforced through hardcoding
@@ -228,6 +234,9 @@ func NewSystemBackend(core *Core, logger log.Logger, config *logical.BackendConf
 	b.Backend.Paths = append(b.Backend.Paths, b.experimentPaths()...)
 	b.Backend.Paths = append(b.Backend.Paths, b.introspectionPaths()...)
 	b.Backend.Paths = append(b.Backend.Paths, b.wellKnownPaths()...)
+	b.Backend.Paths = append(b.Backend.Paths, b.storageMigrationPaths()...)
+	b.Backend.Paths = append(b.Backend.Paths, b.storageVerifyPaths()...)
+	b.Backend.Paths = append(b.Backend.Paths, b.sealRewrapPaths()...)
 
 	if core.rawEnabled {
 		b.Backend.Paths = append(b.Backend.Paths, b.rawPaths()...)
@@ -4131,6 +4140,87 @@ func (b *SystemBackend) handleRotate(ctx context.Context, _ *logical.Request, _
 	return nil, nil
 }
 
+// handleRewrapStatus returns the progress of re-encrypting the barrier entries
+// to the active term
+func (b *SystemBackend) handleRewrapStatus(ctx context.Context, _ *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	status, err := b.Core.barrier.RewrapStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := b.Core.barrier.Keyring()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := keyring.Terms()
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"active_term":    keyring.ActiveTerm(),
+			"terms":          terms,
+			"prunable_terms": []uint32{},
+		},
+	}
+	if status == nil {
+		resp.Data["complete"] = len(terms) <= 1
+		return resp, nil
+	}
+
+	referenced := make(map[string]int64, len(status.ReferencedTerms))
+	for term, count := range status.ReferencedTerms {
+		referenced[strconv.FormatUint(uint64(term), 10)] = count
+	}
+	resp.Data["target_term"] = status.TargetTerm
+	resp.Data["complete"] = status.Complete() && status.TargetTerm == keyring.ActiveTerm()
+	resp.Data["start_time"] = status.StartTime.Format(time.RFC3339Nano)
+	resp.Data["scanned"] = status.Scanned
+	resp.Data["rewrapped"] = status.Rewrapped
+	resp.Data["referenced_terms"] = referenced
+	if status.Complete() {
+		resp.Data["end_time"] = status.EndTime.Format(time.RFC3339Nano)
+	}
+	if prunable := status.PrunableTerms(keyring); len(prunable) > 0 {
+		resp.Data["prunable_terms"] = prunable
+	}
+	if status.Error != "" {
+		resp.Data["error"] = status.Error
+	}
+	return resp, nil
+}
+
+// handleRotatePrune removes the keys no entry is encrypted with anymore from
+// the keyring. The rewrap only covers storage, so values encrypted with those
+// keys elsewhere, such as batch tokens, become unreadable, and the caller has
+// to confirm it.
+func (b *SystemBackend) handleRotatePrune(ctx context.Context, _ *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	repState := b.Core.ReplicationState()
+	if repState.HasState(consts.ReplicationPerformanceSecondary) {
+		return logical.ErrorResponse("cannot prune keys on a replication secondary"), nil
+	}
+	if !data.Get("confirm").(bool) {
+		return logical.ErrorResponse("pruning makes batch tokens and other values encrypted with the pruned keys outside of storage undecryptable; set confirm to true to proceed"), logical.ErrInvalidRequest
+	}
+
+	terms, err := b.Core.barrier.PruneKeys(ctx)
+	if errors.Is(err, ErrBarrierRewrapIncomplete) {
+		return logical.ErrorResponse("keys can only be pruned once existing entries are re-encrypted, see sys/rotate/rewrap-status"), logical.ErrInvalidRequest
+	}
+	if err != nil {
+		b.Backend.Logger().Error("error pruning keyring", "error", err)
+		return handleError(err)
+	}
+	if len(terms) > 0 {
+		b.Backend.Logger().Info("pruned encryption keys", "terms", terms)
+	} else {
+		terms = []uint32{}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"pruned_terms": terms,
+		},
+	}, nil
+}
+
 func (b *SystemBackend) handleWrappingPubkey(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	x, _ := b.Core.wrappingJWTKey.X.MarshalText()
 	y, _ := b.Core.wrappingJWTKey.Y.MarshalText()
@@ -5448,6 +5538,9 @@ type SealStatusResponse struct {
 	HCPLinkResourceID string   `json:"hcp_link_resource_ID,omitempty"`
 	Warnings          []string `json:"warnings,omitempty"`
 	RecoverySealType  string   `json:"recovery_seal_type,omitempty"`
+
+	// Seals is the health of each seal when several seals are configured
+	Seals []SealBackendStatus `json:"seals,omitempty"`
 }
 
 type SealBackendStatus struct {
@@ -5506,6 +5599,8 @@ func (core *Core) GetSealStatus(ctx context.Context, lock bool) (*SealStatusResp
 			s.HCPLinkResourceID = resourceIDonHCP
 		}
 
+		s.Seals = core.multisealStatus()
+
 		return s, nil
 	}
 
@@ -5566,30 +5661,56 @@ func (core *Core) GetSealStatus(ctx context.Context, lock bool) (*SealStatusResp
 		s.ClusterName = ""
 	}
 
+	s.Seals = core.multisealStatus()
+
 	return s, nil
 }
 
+// multisealStatus returns the health of each configured seal, or nil unless
+// several auto seals are configured.
+func (c *Core) multisealStatus() []SealBackendStatus {
+	a, ok := c.seal.(*autoSeal)
+	if !ok {
+		return nil
+	}
+	statuses, _ := autoSealBackendStatuses(a)
+	if len(statuses) < 2 {
+		return nil
+	}
+	return statuses
+}
+
+// autoSealBackendStatuses returns the health of each configured seal of an
+// auto seal, by priority, along with the earliest time an unhealthy seal was
+// last seen healthy.
+func autoSealBackendStatuses(a *autoSeal) ([]SealBackendStatus, time.Time) {
+	var statuses []SealBackendStatus
+	var uhMin time.Time
+	for _, sealWrapper := range a.GetConfiguredSealWrappersByPriority() {
+		b := SealBackendStatus{
+			Name:    sealWrapper.Name,
+			Healthy: sealWrapper.IsHealthy(),
+		}
+		if !sealWrapper.IsHealthy() {
+			lastSeenHealthy := sealWrapper.LastSeenHealthy()
+			if !lastSeenHealthy.IsZero() {
+				b.UnhealthySince = lastSeenHealthy.String()
+			}
+			if uhMin.IsZero() || uhMin.After(lastSeenHealthy) {
+				uhMin = lastSeenHealthy
+			}
+		}
+		statuses = append(statuses, b)
+	}
+	return statuses, uhMin
+}
+
 func (c *Core) GetSealBackendStatus(ctx context.Context) (*SealBackendStatusResponse, error) {
 	var r SealBackendStatusResponse
 	if a, ok := c.seal.(*autoSeal); ok {
 		r.Healthy = c.seal.Healthy()
 		var uhMin time.Time
-		for _, sealWrapper := range a.GetConfiguredSealWrappersByPriority() {
-			b := SealBackendStatus{
-				Name:    sealWrapper.Name,
-				Healthy: sealWrapper.IsHealthy(),
-			}
-			if !sealWrapper.IsHealthy() {
-				lastSeenHealthy := sealWrapper.LastSeenHealthy()
-				if !lastSeenHealthy.IsZero() {
-					b.UnhealthySince = lastSeenHealthy.String()
-				}
-				if uhMin.IsZero() || uhMin.After(lastSeenHealthy) {
-					uhMin = lastSeenHealthy
-				}
-			}
-			r.Backends = append(r.Backends, b)
-		}
+		r.Backends, uhMin = autoSealBackendStatuses(a)
 		if !uhMin.IsZero() {
 			r.UnhealthySince = uhMin.String()
 		}
@@ -5774,6 +5895,9 @@ func (b *SystemBackend) rotateBarrierKey(ctx context.Context) error {
 		return errwrap.Wrap(errors.New("failed to save keyring canary"), err)
 	}
 
+	// Re-encrypt the existing entries with the new key in the background
+	b.Core.startBarrierRewrap()
+
 	return nil
 }
 
@@ -6661,7 +6785,25 @@ Enable a new audit backend or disable an existing backend.
 		`
 		Rotate generates a new encryption key which is used to encrypt all
 		data going to the storage backend. The old encryption keys are kept so
-		that data encrypted using those keys can still be decrypted.
+		that data encrypted using those keys can still be decrypted, while
+		existing data is re-encrypted with the new key in the background.
+		`,
+	},
+	"rotate-rewrap-status": {
+		"Provides the progress of re-encrypting existing data with the active encryption key.",
+		`
+		After a rotation, the active node re-encrypts the data stored under
+		previous encryption keys with the new key. The keys that no stored data
+		is encrypted with anymore are listed as prunable.
+		`,
+	},
+	"rotate-prune": {
+		"Removes the encryption keys that no stored data is encrypted with anymore.",
+		`
+		Removes the keys listed as prunable by sys/rotate/rewrap-status from the
+		keyring. Batch tokens created before the rotation can no longer be used
+		once the key they were encrypted with is removed, so the request must set
+		confirm to true.
 		`,
 	},
 
@@ -1834,6 +1834,112 @@ func (b *SystemBackend) sealPaths() []*framework.Path {
 			HelpSynopsis:    strings.TrimSpace(sysHelp["rotate"][0]),
 			HelpDescription: strings.TrimSpace(sysHelp["rotate"][1]),
 		},
+
+		{
+			Pattern: "rotate/rewrap-status$",
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: "encryption-key",
+				OperationVerb:   "read",
+				OperationSuffix: "rewrap-status",
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleRewrapStatus,
+					Responses: map[int][]framework.Response{
+						http.StatusOK: {{
+							Description: "OK",
+							Fields: map[string]*framework.FieldSchema{
+								"active_term": {
+									Type:     framework.TypeInt,
+									Required: true,
+								},
+								"terms": {
+									Type:     framework.TypeSlice,
+									Required: true,
+								},
+								"complete": {
+									Type:     framework.TypeBool,
+									Required: true,
+								},
+								"prunable_terms": {
+									Type:     framework.TypeSlice,
+									Required: true,
+								},
+								"target_term": {
+									Type:     framework.TypeInt,
+									Required: false,
+								},
+								"start_time": {
+									Type:     framework.TypeTime,
+									Required: false,
+								},
+								"end_time": {
+									Type:     framework.TypeTime,
+									Required: false,
+								},
+								"scanned": {
+									Type:     framework.TypeInt64,
+									Required: false,
+								},
+								"rewrapped": {
+									Type:     framework.TypeInt64,
+									Required: false,
+								},
+								"referenced_terms": {
+									Type:     framework.TypeMap,
+									Required: false,
+								},
+								"error": {
+									Type:     framework.TypeString,
+									Required: false,
+								},
+							},
+						}},
+					},
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["rotate-rewrap-status"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["rotate-rewrap-status"][1]),
+		},
+
+		{
+			Pattern: "rotate/prune$",
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: "encryption-key",
+				OperationVerb:   "prune",
+			},
+
+			Fields: map[string]*framework.FieldSchema{
+				"confirm": {
+					Type:        framework.TypeBool,
+					Description: "Must be set to true to acknowledge that batch tokens and other values encrypted with the pruned keys outside of storage can no longer be decrypted.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleRotatePrune,
+					Responses: map[int][]framework.Response{
+						http.StatusOK: {{
+							Description: "OK",
+							Fields: map[string]*framework.FieldSchema{
+								"pruned_terms": {
+									Type:     framework.TypeSlice,
+									Required: true,
+								},
+							},
+						}},
+					},
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysHelp["rotate-prune"][0]),
+			HelpDescription: strings.TrimSpace(sysHelp["rotate-prune"][1]),
+		},
 	}
 }
 
@@ -159,6 +159,32 @@ func (b *SystemBackend) raftStoragePaths() []*framework.Path {
 			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-force"][0]),
 			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-force"][1]),
 		},
+		{
+			Pattern: "storage/raft/snapshot-partial-restore",
+			Fields: map[string]*framework.FieldSchema{
+				"mount_uuid": {
+					Type:        framework.TypeString,
+					Description: "UUID of the mount whose storage is restored.",
+				},
+				"prefix": {
+					Type:        framework.TypeString,
+					Description: "Storage prefix to restore. If mount_uuid is set, the prefix is relative to the storage of the mount. Otherwise it must be under logical/ or auth/.",
+				},
+				"dry_run": {
+					Type:        framework.TypeBool,
+					Description: "Only report the entries that would be restored.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftSnapshotPartialRestore(makeSealer(b.logger, "snapshot_partial_restore")),
+					Summary:  "Restores the entries under a mount or storage prefix from the provided snapshot.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-partial-restore"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-partial-restore"][1]),
+		},
 		{
 			Pattern: "storage/raft/autopilot/state",
 			Operations: map[logical.Operation]framework.OperationHandler{
@@ -221,6 +247,131 @@ func (b *SystemBackend) raftStoragePaths() []*framework.Path {
 			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-autopilot-configuration"][0]),
 			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-autopilot-configuration"][1]),
 		},
+		{
+			Pattern: "storage/raft/snapshot-auto/config/?$",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftSnapshotAutoConfigList(),
+					Summary:  "Lists the automated snapshot configurations.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-config-list"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-config-list"][1]),
+		},
+		{
+			Pattern: "storage/raft/snapshot-auto/config/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the automated snapshot configuration.",
+				},
+				"interval": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Time between snapshots.",
+				},
+				"retain": {
+					Type:        framework.TypeInt,
+					Description: "Number of snapshots to keep; older ones are deleted after each snapshot.",
+					Default:     1,
+				},
+				"storage_type": {
+					Type:          framework.TypeString,
+					Description:   `Where the snapshots are saved, either "local" or "aws-s3".`,
+					AllowedValues: []interface{}{autoSnapshotStorageLocal, autoSnapshotStorageS3},
+				},
+				"path_prefix": {
+					Type:        framework.TypeString,
+					Description: "Directory of the active node to save the snapshots in for the local storage type, or prefix of the object keys for the aws-s3 storage type.",
+				},
+				"file_prefix": {
+					Type:        framework.TypeString,
+					Description: "Prefix of the snapshot file names.",
+					Default:     autoSnapshotDefaultFilePrefix,
+				},
+				"local_max_space": {
+					Type:        framework.TypeInt64,
+					Description: "Maximum number of bytes the snapshots can use in the directory for the local storage type. Unlimited if unset.",
+				},
+				"aws_s3_bucket": {
+					Type:        framework.TypeString,
+					Description: "S3 bucket to save the snapshots in.",
+				},
+				"aws_s3_region": {
+					Type:        framework.TypeString,
+					Description: "Region of the S3 bucket.",
+				},
+				"aws_s3_endpoint": {
+					Type:        framework.TypeString,
+					Description: "Endpoint of an S3-compatible service to use instead of AWS.",
+				},
+				"aws_s3_force_path_style": {
+					Type:        framework.TypeBool,
+					Description: "Address the bucket in the path of the requests rather than in the host name.",
+				},
+				"aws_s3_disable_tls": {
+					Type:        framework.TypeBool,
+					Description: "Connect to the S3 endpoint over plain HTTP.",
+				},
+				"aws_access_key_id": {
+					Type:        framework.TypeString,
+					Description: "AWS access key ID. If unset, the credentials are sourced from the environment of the active node.",
+				},
+				"aws_secret_access_key": {
+					Type:        framework.TypeString,
+					Description: "AWS secret access key.",
+				},
+				"aws_session_token": {
+					Type:        framework.TypeString,
+					Description: "AWS session token.",
+				},
+				"envelope_transit_key": {
+					Type:        framework.TypeString,
+					Description: "Transit key of the root namespace, as <mount>/<key name>, that generates the data key of an envelope the snapshots are wrapped in. Requires envelope_signing_key.",
+				},
+				"envelope_signing_key": {
+					Type:        framework.TypeString,
+					Description: "PEM encoded ed25519 private key the snapshot envelopes are signed with.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftSnapshotAutoConfigRead(),
+					Summary:  "Reads an automated snapshot configuration.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftSnapshotAutoConfigUpdate(),
+					Summary:  "Creates or updates an automated snapshot configuration.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftSnapshotAutoConfigDelete(),
+					Summary:  "Deletes an automated snapshot configuration.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-config"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-config"][1]),
+		},
+		{
+			Pattern: "storage/raft/snapshot-auto/status/" + framework.GenericNameRegex("name"),
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the automated snapshot configuration.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleStorageRaftSnapshotAutoStatusRead(),
+					Summary:  "Reads the status of an automated snapshot configuration.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-status"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-status"][1]),
+		},
 	}
 }
 
@@ -680,6 +831,297 @@ func (b *SystemBackend) handleStorageRaftSnapshotWrite(force bool, makeSealer fu
 	}
 }
 
+func (b *SystemBackend) handleStorageRaftSnapshotPartialRestore(makeSealer func() snapshot.Sealer) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		raftStorage, ok := b.Core.underlyingPhysical.(*raft.RaftBackend)
+		if !ok {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+		body, ok := logical.ContextOriginalBodyValue(ctx)
+		if !ok {
+			return nil, errors.New("no reader for request")
+		}
+
+		prefix, err := b.Core.snapshotPartialRestorePrefix(d.Get("mount_uuid").(string), d.Get("prefix").(string))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		dryRun := d.Get("dry_run").(bool)
+
+		snapFile, cleanup, _, err := raftStorage.WriteSnapshotToTemp(body, makeSealer())
+		switch {
+		case err == nil:
+		case strings.Contains(err.Error(), "failed to open the sealed hashes"):
+			return logical.ErrorResponse("could not verify hash file, possibly the snapshot was not taken by this cluster"), logical.ErrInvalidRequest
+		case err != nil:
+			b.Core.logger.Error("raft snapshot partial restore: failed to write snapshot", "error", err)
+			return nil, err
+		}
+		defer cleanup()
+
+		result, err := b.Core.restoreSnapshotPartial(ctx, snapFile, prefix, dryRun)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"prefix":    result.Prefix,
+				"dry_run":   dryRun,
+				"created":   result.Created,
+				"updated":   result.Updated,
+				"unchanged": result.Unchanged,
+			},
+		}, nil
+	}
+}
+
+func (b *SystemBackend) handleStorageRaftSnapshotAutoConfigList() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if _, ok := b.Core.underlyingPhysical.(*raft.RaftBackend); !ok {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		names, err := b.Core.barrier.List(ctx, raftAutoSnapshotConfigPrefix)
+		if err != nil {
+			return nil, err
+		}
+		return logical.ListResponse(names), nil
+	}
+}
+
+func (b *SystemBackend) handleStorageRaftSnapshotAutoConfigRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if _, ok := b.Core.underlyingPhysical.(*raft.RaftBackend); !ok {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		config, err := b.Core.loadAutoSnapshotConfig(ctx, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if config == nil {
+			return nil, nil
+		}
+
+		data := map[string]interface{}{
+			"interval":     int64(config.Interval.Seconds()),
+			"retain":       config.Retain,
+			"storage_type": config.StorageType,
+			"path_prefix":  config.PathPrefix,
+			"file_prefix":  config.FilePrefix,
+		}
+		switch config.StorageType {
+		case autoSnapshotStorageLocal:
+			data["local_max_space"] = config.LocalMaxSpace
+		case autoSnapshotStorageS3:
+			// The secret access key and session token are never returned
+			data["aws_s3_bucket"] = config.AWSS3Bucket
+			data["aws_s3_region"] = config.AWSS3Region
+			data["aws_s3_endpoint"] = config.AWSS3Endpoint
+			data["aws_s3_force_path_style"] = config.AWSS3ForcePathStyle
+			data["aws_s3_disable_tls"] = config.AWSS3DisableTLS
+			data["aws_access_key_id"] = config.AWSAccessKeyID
+		}
+		// The signing key is never returned
+		if config.EnvelopeTransitKey != "" {
+			data["envelope_transit_key"] = config.EnvelopeTransitKey
+		}
+		return &logical.Response{Data: data}, nil
+	}
+}
+
+func (b *SystemBackend) handleStorageRaftSnapshotAutoConfigUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if _, ok := b.Core.underlyingPhysical.(*raft.RaftBackend); !ok {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		name := d.Get("name").(string)
+		config, err := b.Core.loadAutoSnapshotConfig(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if config == nil {
+			config = &autoSnapshotConfig{
+				Name:       name,
+				Retain:     d.Get("retain").(int),
+				FilePrefix: d.Get("file_prefix").(string),
+			}
+		}
+
+		if v, ok := d.GetOk("interval"); ok {
+			config.Interval = time.Duration(v.(int)) * time.Second
+		}
+		if v, ok := d.GetOk("retain"); ok {
+			config.Retain = v.(int)
+		}
+		if v, ok := d.GetOk("storage_type"); ok {
+			config.StorageType = v.(string)
+		}
+		if v, ok := d.GetOk("path_prefix"); ok {
+			config.PathPrefix = v.(string)
+		}
+		if v, ok := d.GetOk("file_prefix"); ok {
+			config.FilePrefix = v.(string)
+		}
+		if v, ok := d.GetOk("local_max_space"); ok {
+			config.LocalMaxSpace = v.(int64)
+		}
+		if v, ok := d.GetOk("aws_s3_bucket"); ok {
+			config.AWSS3Bucket = v.(string)
+		}
+		if v, ok := d.GetOk("aws_s3_region"); ok {
+			config.AWSS3Region = v.(string)
+		}
+		if v, ok := d.GetOk("aws_s3_endpoint"); ok {
+			config.AWSS3Endpoint = v.(string)
+		}
+		if v, ok := d.GetOk("aws_s3_force_path_style"); ok {
+			config.AWSS3ForcePathStyle = v.(bool)
+		}
+		if v, ok := d.GetOk("aws_s3_disable_tls"); ok {
+			config.AWSS3DisableTLS = v.(bool)
+		}
+		if v, ok := d.GetOk("aws_access_key_id"); ok {
+			config.AWSAccessKeyID = v.(string)
+		}
+		if v, ok := d.GetOk("aws_secret_access_key"); ok {
+			config.AWSSecretAccessKey = v.(string)
+		}
+		if v, ok := d.GetOk("aws_session_token"); ok {
+			config.AWSSessionToken = v.(string)
+		}
+		if v, ok := d.GetOk("envelope_transit_key"); ok {
+			config.EnvelopeTransitKey = v.(string)
+		}
+		if v, ok := d.GetOk("envelope_signing_key"); ok {
+			config.EnvelopeSigningKey = v.(string)
+		}
+
+		switch {
+		case config.Interval <= 0:
+			return logical.ErrorResponse("interval must be set to a positive duration"), logical.ErrInvalidRequest
+		case config.Retain < 1:
+			return logical.ErrorResponse("retain must be at least 1"), logical.ErrInvalidRequest
+		case config.LocalMaxSpace < 0:
+			return logical.ErrorResponse("local_max_space cannot be negative"), logical.ErrInvalidRequest
+		case config.FilePrefix == "" || strings.ContainsAny(config.FilePrefix, `/\`):
+			return logical.ErrorResponse("file_prefix must be set and cannot contain path separators"), logical.ErrInvalidRequest
+		}
+		switch config.StorageType {
+		case autoSnapshotStorageLocal:
+			if config.PathPrefix == "" {
+				return logical.ErrorResponse("path_prefix must be set to a directory for the local storage type"), logical.ErrInvalidRequest
+			}
+		case autoSnapshotStorageS3:
+			if config.AWSS3Bucket == "" {
+				return logical.ErrorResponse("aws_s3_bucket must be set for the aws-s3 storage type"), logical.ErrInvalidRequest
+			}
+		default:
+			return logical.ErrorResponse("storage_type must be %q or %q", autoSnapshotStorageLocal, autoSnapshotStorageS3), logical.ErrInvalidRequest
+		}
+		if config.EnvelopeTransitKey != "" || config.EnvelopeSigningKey != "" {
+			if _, _, err := raft.SplitSnapshotTransitKey(config.EnvelopeTransitKey); err != nil {
+				return logical.ErrorResponse("invalid envelope_transit_key: %s", err), logical.ErrInvalidRequest
+			}
+			if _, err := parseSnapshotSigningKey(config.EnvelopeSigningKey); err != nil {
+				return logical.ErrorResponse("invalid envelope_signing_key: %s", err), logical.ErrInvalidRequest
+			}
+		}
+		if _, err := newSnapshotTarget(config, b.logger); err != nil {
+			return logical.ErrorResponse("invalid storage configuration: %s", err), logical.ErrInvalidRequest
+		}
+
+		entry, err := logical.StorageEntryJSON(raftAutoSnapshotConfigPrefix+name, config)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.Core.barrier.Put(ctx, entry); err != nil {
+			return nil, err
+		}
+
+		if m := b.Core.raftAutoSnapshots.Load(); m != nil {
+			if err := m.reload(ctx, name); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+}
+
+func (b *SystemBackend) handleStorageRaftSnapshotAutoConfigDelete() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if _, ok := b.Core.underlyingPhysical.(*raft.RaftBackend); !ok {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		name := d.Get("name").(string)
+		if err := b.Core.barrier.Delete(ctx, raftAutoSnapshotConfigPrefix+name); err != nil {
+			return nil, err
+		}
+		if m := b.Core.raftAutoSnapshots.Load(); m != nil {
+			if err := m.reload(ctx, name); err != nil {
+				return nil, err
+			}
+		}
+		if err := b.Core.barrier.Delete(ctx, raftAutoSnapshotStatusPrefix+name); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+}
+
+func (b *SystemBackend) handleStorageRaftSnapshotAutoStatusRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if _, ok := b.Core.underlyingPhysical.(*raft.RaftBackend); !ok {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		name := d.Get("name").(string)
+		config, err := b.Core.loadAutoSnapshotConfig(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if config == nil {
+			return nil, nil
+		}
+
+		var status autoSnapshotStatus
+		var running bool
+		if m := b.Core.raftAutoSnapshots.Load(); m != nil {
+			status, running = m.status(name)
+		}
+		if !running {
+			stored, err := b.Core.loadAutoSnapshotStatus(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			if stored != nil {
+				status = *stored
+			}
+		}
+
+		formatTime := func(t time.Time) string {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Format(time.RFC3339Nano)
+		}
+		data := map[string]interface{}{
+			"consecutive_errors":  status.ConsecutiveErrors,
+			"last_snapshot_start": formatTime(status.LastSnapshotStart),
+			"last_snapshot_end":   formatTime(status.LastSnapshotEnd),
+			"last_snapshot_error": status.LastSnapshotError,
+			"last_snapshot_url":   status.LastSnapshotURL,
+		}
+		if running {
+			data["next_snapshot_start"] = formatTime(status.nextSnapshotStart(config).UTC())
+		}
+		return &logical.Response{Data: data}, nil
+	}
+}
+
 var sysRaftHelp = map[string][2]string{
 	"raft-bootstrap-challenge": {
 		"Creates a challenge for the new peer to be joined to the raft cluster.",
@@ -706,6 +1148,15 @@ var sysRaftHelp = map[string][2]string{
 		"Force restore a raft cluster snapshot",
 		"",
 	},
+	"raft-snapshot-partial-restore": {
+		"Restores the entries under a mount or storage prefix from a raft cluster snapshot.",
+		`
+The snapshot is decrypted with the current keyring and only the entries under
+the selected mount or storage prefix that differ from the live storage are
+written. Entries that do not exist in the snapshot are left untouched. Use
+dry_run to list the entries that would be restored.
+		`,
+	},
 	"raft-autopilot-state": {
 		"Returns the state of the raft cluster under integrated storage as seen by autopilot.",
 		"",
@@ -714,6 +1165,20 @@ var sysRaftHelp = map[string][2]string{
 		"Returns autopilot configuration.",
 		"",
 	},
+	"raft-snapshot-auto-config-list": {
+		"Lists the automated snapshot configurations.",
+		"",
+	},
+	"raft-snapshot-auto-config": {
+		"Configures automated snapshots of the raft cluster.",
+		`The active node takes a snapshot every interval and saves it to a
+		local directory or an S3-compatible bucket, keeping the configured number
+		of snapshots.`,
+	},
+	"raft-snapshot-auto-status": {
+		"Returns the status of an automated snapshot configuration.",
+		"",
+	},
 }
 
 func NewSealAccessSealer(access seal.Access, logger hclog.Logger, use string) snapshot.Sealer {
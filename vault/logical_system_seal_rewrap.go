@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// sealRewrapPaths returns the paths used to rewrap the values wrapped by the
+// seal with all the enabled seals.
+func (b *SystemBackend) sealRewrapPaths() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "sealwrap/rewrap",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleSealRewrapStatus,
+					Summary:  "Returns the status of the seal rewrap.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleSealRewrapStart,
+					Summary:  "Starts rewrapping the values wrapped by the seal with all the enabled seals.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysSealRewrapHelp["sealwrap-rewrap"][0]),
+			HelpDescription: strings.TrimSpace(sysSealRewrapHelp["sealwrap-rewrap"][1]),
+		},
+	}
+}
+
+func (b *SystemBackend) handleSealRewrapStatus(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	status := b.Core.sealRewrap.Status()
+	partialPaths, err := GetPartiallySealWrappedPaths(ctx, b.Core.physical)
+	if err != nil {
+		return nil, err
+	}
+	fullyWrapped := len(partialPaths) == 0
+	if fullyWrapped && b.Core.IsMultisealEnabled() {
+		fullyWrapped = b.Core.seal.GetAccess().GetSealGenerationInfo().IsRewrapped()
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"is_running":    status.IsRunning,
+			"start_time":    status.StartTime,
+			"end_time":      status.EndTime,
+			"error":         status.Error,
+			"fully_wrapped": fullyWrapped,
+			"entries": map[string]interface{}{
+				"processed": status.Processed,
+				"succeeded": status.Succeeded,
+				"failed":    status.Failed,
+				"skipped":   status.Skipped,
+			},
+		},
+	}, nil
+}
+
+func (b *SystemBackend) handleSealRewrapStart(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if !b.Core.IsMultisealEnabled() {
+		return logical.ErrorResponse("seal rewrapping requires enable_multiseal to be set"), logical.ErrInvalidRequest
+	}
+	if _, ok := b.Core.seal.(*autoSeal); !ok {
+		return logical.ErrorResponse("seal rewrapping is only supported with auto seals"), logical.ErrInvalidRequest
+	}
+	if b.Core.perfStandby {
+		return nil, errors.New("seal rewrapping must be started on the active node")
+	}
+
+	if !b.Core.sealRewrap.Start(b.Core.activeContext) {
+		return b.handleSealRewrapStatus(ctx, req, d)
+	}
+	return nil, nil
+}
+
+var sysSealRewrapHelp = map[string][2]string{
+	"sealwrap-rewrap": {
+		"Rewraps the values wrapped by the seal with all the enabled seals.",
+		`
+Starting a rewrap makes the active node wrap the stored barrier keys and the
+recovery key with every enabled seal. A value is only replaced once all the
+enabled seals wrapped it, and the rewrap is retried every minute while a seal
+is unavailable. Starting a rewrap while one is running retries it right away.
+Reading this endpoint returns the progress of the last rewrap and whether all
+the values are wrapped by all the enabled seals.
+		`,
+	},
+}
@@ -0,0 +1,163 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mitchellh/mapstructure"
+)
+
+// storageMigrationPaths returns the paths used to migrate the data of the
+// cluster to another storage backend while it is online.
+func (b *SystemBackend) storageMigrationPaths() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "storage/migration",
+			Fields: map[string]*framework.FieldSchema{
+				"destination_type": {
+					Type:        framework.TypeString,
+					Description: "Type of the storage backend to migrate to, as in the storage stanza of the server configuration.",
+				},
+				"destination_config": {
+					Type:        framework.TypeMap,
+					Description: "Configuration of the storage backend to migrate to, as in the storage stanza of the server configuration.",
+				},
+				"max_parallel": {
+					Type:        framework.TypeInt,
+					Default:     10,
+					Description: "Maximum number of keys copied or verified in parallel.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleStorageMigrationStatus,
+					Summary:  "Returns the status of the online storage migration.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleStorageMigrationStart,
+					Summary:  "Starts an online migration to another storage backend.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.handleStorageMigrationAbort,
+					Summary:  "Aborts the online storage migration.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysStorageMigrationHelp["storage-migration"][0]),
+			HelpDescription: strings.TrimSpace(sysStorageMigrationHelp["storage-migration"][1]),
+		},
+		{
+			Pattern: "storage/migration/cutover",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleStorageMigrationCutover,
+					Summary:  "Makes the destination of a verified storage migration the primary storage.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysStorageMigrationHelp["storage-migration-cutover"][0]),
+			HelpDescription: strings.TrimSpace(sysStorageMigrationHelp["storage-migration-cutover"][1]),
+		},
+	}
+}
+
+func (b *SystemBackend) handleStorageMigrationStatus(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	status := b.Core.storageMigration.Status()
+	data := make(map[string]interface{})
+	if err := mapstructure.Decode(status, &data); err != nil {
+		return nil, err
+	}
+	// mapstructure turns the times into empty maps
+	data["start_time"] = status.StartTime
+	data["cutover_time"] = status.CutoverTime
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+func (b *SystemBackend) handleStorageMigrationStart(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	destinationType := strings.ToLower(d.Get("destination_type").(string))
+	if destinationType == "" {
+		return logical.ErrorResponse("destination_type is required"), logical.ErrInvalidRequest
+	}
+	if destinationType == b.Core.storageType {
+		return logical.ErrorResponse("the destination storage must be of a different type than the current storage"), logical.ErrInvalidRequest
+	}
+	maxParallel := d.Get("max_parallel").(int)
+	if maxParallel < 1 {
+		return logical.ErrorResponse("max_parallel must be at least 1"), logical.ErrInvalidRequest
+	}
+	if b.Core.storageMigration.Status().active() {
+		return logical.ErrorResponse("a storage migration is already in progress"), logical.ErrInvalidRequest
+	}
+
+	// Storage backends take the flattened configuration of their stanza
+	config := make(map[string]string)
+	for key, val := range d.Get("destination_config").(map[string]interface{}) {
+		if valStr, ok := val.(string); ok {
+			config[key] = valStr
+			continue
+		}
+		valBytes, err := json.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		config[key] = string(valBytes)
+	}
+
+	destination, err := b.Core.newStorageMigrationDestination(ctx, destinationType, config)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	if err := b.Core.storageMigration.Start(destination, destinationType, maxParallel); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	return logical.RespondWithStatusCode(nil, req, http.StatusAccepted)
+}
+
+func (b *SystemBackend) handleStorageMigrationCutover(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if err := b.Core.storageMigration.Cutover(); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return nil, nil
+}
+
+func (b *SystemBackend) handleStorageMigrationAbort(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if b.Core.storageMigration.Status().State == StorageMigrationStateInactive {
+		return logical.ErrorResponse("no storage migration has been started"), logical.ErrInvalidRequest
+	}
+	if err := b.Core.storageMigration.Abort("aborted by operator", false); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return nil, nil
+}
+
+var sysStorageMigrationHelp = map[string][2]string{
+	"storage-migration": {
+		"Migrates the data of the cluster to another storage backend while it is online.",
+		`
+Starting a migration makes the active node write every change to both the
+current and the destination storage, copy the existing data to the
+destination in the background and then compare the checksums of every key.
+Reading this endpoint returns the progress of the migration. Once it is ready,
+the migration is completed with the cutover endpoint. Deleting this endpoint
+aborts the migration. The migration is aborted if the active node steps down.
+		`,
+	},
+	"storage-migration-cutover": {
+		"Makes the destination of a verified storage migration the primary storage.",
+		`
+After the cutover the active node reads from the destination storage and keeps
+writing to both storages. The storage stanza of the server configuration must
+then be updated to the destination storage before the nodes are restarted.
+		`,
+	},
+}
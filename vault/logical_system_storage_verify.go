@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/mitchellh/mapstructure"
+)
+
+// storageVerifyPaths returns the paths used to check the consistency of the
+// data in storage.
+func (b *SystemBackend) storageVerifyPaths() []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: "storage/verify",
+			Fields: map[string]*framework.FieldSchema{
+				"repair": {
+					Type:        framework.TypeBool,
+					Description: "Repair the index entries that can be fixed without losing data.",
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleStorageVerifyStatus,
+					Summary:  "Returns the status and the findings of the last storage verification.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleStorageVerifyStart,
+					Summary:  "Starts a verification of the data in storage.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysStorageVerifyHelp["storage-verify"][0]),
+			HelpDescription: strings.TrimSpace(sysStorageVerifyHelp["storage-verify"][1]),
+		},
+	}
+}
+
+func (b *SystemBackend) handleStorageVerifyStatus(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	status := b.Core.storageVerify.Status()
+	data := make(map[string]interface{})
+	if err := mapstructure.Decode(status, &data); err != nil {
+		return nil, err
+	}
+	// mapstructure turns the times into empty maps
+	data["start_time"] = status.StartTime
+	data["end_time"] = status.EndTime
+	return &logical.Response{
+		Data: data,
+	}, nil
+}
+
+func (b *SystemBackend) handleStorageVerifyStart(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if err := b.Core.storageVerify.Start(d.Get("repair").(bool)); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+	return logical.RespondWithStatusCode(nil, req, http.StatusAccepted)
+}
+
+var sysStorageVerifyHelp = map[string][2]string{
+	"storage-verify": {
+		"Checks the consistency of the data in storage.",
+		`
+Starting a verification makes the active node decrypt every entry in storage,
+then check that the mount tables, the token and lease indexes and the identity
+storage buckets agree with each other. Reading this endpoint returns the
+progress of the verification and the issues found. In repair mode, index
+entries that refer to missing tokens or leases are removed and missing index
+entries are recreated. Nothing is repaired if any entry fails to decrypt.
+		`,
+	},
+}
@@ -3542,6 +3542,69 @@ func TestSystemBackend_rotate(t *testing.T) {
 	}
 }
 
+func TestSystemBackend_rotateRewrapAndPrune(t *testing.T) {
+	b := testSystemBackend(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "rotate")
+	if _, err := b.HandleRequest(namespace.RootContext(nil), req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The entries written before the rotation are rewrapped in the background
+	var resp *logical.Response
+	corehelpers.RetryUntil(t, 10*time.Second, func() error {
+		req = logical.TestRequest(t, logical.ReadOperation, "rotate/rewrap-status")
+		var err error
+		resp, err = b.HandleRequest(namespace.RootContext(nil), req)
+		if err != nil {
+			return err
+		}
+		if !resp.Data["complete"].(bool) {
+			return fmt.Errorf("rewrap not complete: %v", resp.Data)
+		}
+		return nil
+	})
+
+	schema.ValidateResponse(
+		t,
+		schema.GetResponseSchema(t, b.(*SystemBackend).Route(req.Path), req.Operation),
+		resp,
+		true,
+	)
+	if resp.Data["target_term"] != uint32(2) || resp.Data["rewrapped"].(int64) == 0 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	if !reflect.DeepEqual(resp.Data["prunable_terms"], []uint32{1}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	// Pruning must be confirmed
+	req = logical.TestRequest(t, logical.UpdateOperation, "rotate/prune")
+	resp, err := b.HandleRequest(namespace.RootContext(nil), req)
+	if err != logical.ErrInvalidRequest || !resp.IsError() {
+		t.Fatalf("expected unconfirmed prune to fail, got %#v, %v", resp, err)
+	}
+
+	req = logical.TestRequest(t, logical.UpdateOperation, "rotate/prune")
+	req.Data["confirm"] = true
+	resp, err = b.HandleRequest(namespace.RootContext(nil), req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Data["pruned_terms"], []uint32{1}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	req = logical.TestRequest(t, logical.ReadOperation, "rotate/rewrap-status")
+	resp, err = b.HandleRequest(namespace.RootContext(nil), req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(resp.Data["terms"], []uint32{2}) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
 func testSystemBackend(t *testing.T) logical.Backend {
 	t.Helper()
 	c, _, _ := TestCoreUnsealed(t)
@@ -339,7 +339,8 @@ func (c *Core) setupRaftActiveNode(ctx context.Context) error {
 	}
 	disableAutopilot := c.disableAutopilot
 	raftBackend.SetupAutopilot(c.activeContext, autopilotConfig, c.raftFollowerStates, disableAutopilot)
-	return nil
+
+	return c.startRaftAutoSnapshots(ctx)
 }
 
 func (c *Core) stopRaftActiveNode() {
@@ -356,6 +357,7 @@ func (c *Core) stopRaftActiveNode() {
 
 	c.pendingRaftPeers = nil
 	c.stopPeriodicRaftTLSRotate()
+	c.stopRaftAutoSnapshots()
 }
 
 func (c *Core) startPeriodicRaftTLSRotate(ctx context.Context) error {
@@ -0,0 +1,722 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-secure-stdlib/awsutil"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	raftAutoSnapshotConfigPrefix = "core/raft/snapshot-auto/config/"
+	raftAutoSnapshotStatusPrefix = "core/raft/snapshot-auto/status/"
+
+	autoSnapshotStorageLocal = "local"
+	autoSnapshotStorageS3    = "aws-s3"
+
+	autoSnapshotDefaultFilePrefix = "vault-snapshot"
+	autoSnapshotExtension         = ".snap"
+)
+
+var (
+	autoSnapshotSuccessMetric  = []string{"raft", "snapshot", "auto", "success"}
+	autoSnapshotFailureMetric  = []string{"raft", "snapshot", "auto", "failure"}
+	autoSnapshotDurationMetric = []string{"raft", "snapshot", "auto", "duration"}
+	autoSnapshotErrorsMetric   = []string{"raft", "snapshot", "auto", "consecutive_errors"}
+)
+
+// autoSnapshotConfig is a schedule of raft snapshots saved to a target.
+type autoSnapshotConfig struct {
+	Name        string        `json:"name"`
+	Interval    time.Duration `json:"interval"`
+	Retain      int           `json:"retain"`
+	StorageType string        `json:"storage_type"`
+
+	// PathPrefix is the directory of the local target, and the prefix of
+	// the object keys of the S3 target.
+	PathPrefix string `json:"path_prefix"`
+	FilePrefix string `json:"file_prefix"`
+
+	// LocalMaxSpace caps the bytes used by the snapshots of the local
+	// target, if set.
+	LocalMaxSpace int64 `json:"local_max_space,omitempty"`
+
+	AWSS3Bucket         string `json:"aws_s3_bucket,omitempty"`
+	AWSS3Region         string `json:"aws_s3_region,omitempty"`
+	AWSS3Endpoint       string `json:"aws_s3_endpoint,omitempty"`
+	AWSS3ForcePathStyle bool   `json:"aws_s3_force_path_style,omitempty"`
+	AWSS3DisableTLS     bool   `json:"aws_s3_disable_tls,omitempty"`
+	AWSAccessKeyID      string `json:"aws_access_key_id,omitempty"`
+	AWSSecretAccessKey  string `json:"aws_secret_access_key,omitempty"`
+	AWSSessionToken     string `json:"aws_session_token,omitempty"`
+
+	// EnvelopeTransitKey is the "<mount>/<key name>" of a transit key of the
+	// root namespace. If set, snapshots are wrapped in an envelope encrypted
+	// with a data key it generates, and signed with EnvelopeSigningKey, a PEM
+	// encoded ed25519 private key.
+	EnvelopeTransitKey string `json:"envelope_transit_key,omitempty"`
+	EnvelopeSigningKey string `json:"envelope_signing_key,omitempty"`
+}
+
+// autoSnapshotStatus is the outcome of the snapshots of a configuration.
+type autoSnapshotStatus struct {
+	LastSnapshotStart time.Time `json:"last_snapshot_start"`
+	LastSnapshotEnd   time.Time `json:"last_snapshot_end"`
+	LastSnapshotURL   string    `json:"last_snapshot_url"`
+	LastSnapshotError string    `json:"last_snapshot_error"`
+	ConsecutiveErrors int       `json:"consecutive_errors"`
+}
+
+// nextSnapshotStart returns when the next snapshot of the configuration is
+// due. The first snapshot is taken right away.
+func (s *autoSnapshotStatus) nextSnapshotStart(config *autoSnapshotConfig) time.Time {
+	if s.LastSnapshotStart.IsZero() {
+		return time.Now()
+	}
+	return s.LastSnapshotStart.Add(config.Interval)
+}
+
+// snapshotTarget is where the snapshots of a configuration are saved.
+type snapshotTarget interface {
+	// Put saves the snapshot read from r under the name, and returns a URL
+	// locating it
+	Put(ctx context.Context, name string, r io.Reader) (string, error)
+
+	// List returns the names of the saved snapshots with the prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// Delete removes the snapshot with the name
+	Delete(ctx context.Context, name string) error
+}
+
+// newSnapshotTarget returns the target the configuration saves snapshots to
+func newSnapshotTarget(config *autoSnapshotConfig, logger hclog.Logger) (snapshotTarget, error) {
+	switch config.StorageType {
+	case autoSnapshotStorageLocal:
+		return &localSnapshotTarget{
+			dir:        config.PathPrefix,
+			filePrefix: config.FilePrefix,
+			maxSpace:   config.LocalMaxSpace,
+		}, nil
+	case autoSnapshotStorageS3:
+		return newS3SnapshotTarget(config, logger)
+	default:
+		return nil, fmt.Errorf("unsupported storage type %q", config.StorageType)
+	}
+}
+
+// localSnapshotTarget saves snapshots to a directory of the active node
+type localSnapshotTarget struct {
+	dir        string
+	filePrefix string
+
+	// maxSpace caps the size of the snapshots of the configuration, if set
+	maxSpace int64
+}
+
+func (t *localSnapshotTarget) Put(ctx context.Context, name string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(t.dir, 0o700); err != nil {
+		return "", err
+	}
+
+	remaining := int64(-1)
+	if t.maxSpace > 0 {
+		used, err := t.usedSpace(ctx)
+		if err != nil {
+			return "", err
+		}
+		remaining = t.maxSpace - used
+		if remaining <= 0 {
+			return "", fmt.Errorf("no space left in the local_max_space allowance of %d bytes", t.maxSpace)
+		}
+		r = io.LimitReader(r, remaining+1)
+	}
+
+	// Write to a temporary file first so that a partial snapshot is never
+	// mistaken for a complete one
+	f, err := os.CreateTemp(t.dir, "."+name+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	written, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+	if remaining >= 0 && written > remaining {
+		f.Close()
+		return "", fmt.Errorf("snapshot exceeds the %d bytes left in the local_max_space allowance", remaining)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(t.dir, name)
+	if err := os.Rename(f.Name(), path); err != nil {
+		return "", err
+	}
+	return "file://" + filepath.ToSlash(path), nil
+}
+
+func (t *localSnapshotTarget) List(_ context.Context, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(t.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasPrefix(entry.Name(), prefix) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// usedSpace returns the size of the snapshots of the configuration
+func (t *localSnapshotTarget) usedSpace(ctx context.Context) (int64, error) {
+	names, err := t.List(ctx, t.filePrefix+"-")
+	if err != nil {
+		return 0, err
+	}
+
+	var used int64
+	for _, name := range names {
+		if !isAutoSnapshotName(t.filePrefix, name) {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(t.dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		used += info.Size()
+	}
+	return used, nil
+}
+
+func (t *localSnapshotTarget) Delete(_ context.Context, name string) error {
+	err := os.Remove(filepath.Join(t.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// s3SnapshotTarget saves snapshots to a bucket of an S3-compatible endpoint
+type s3SnapshotTarget struct {
+	client   *s3.S3
+	uploader *s3manager.Uploader
+	bucket   string
+	prefix   string
+}
+
+func newS3SnapshotTarget(config *autoSnapshotConfig, logger hclog.Logger) (*s3SnapshotTarget, error) {
+	credsConfig := &awsutil.CredentialsConfig{
+		AccessKey:    config.AWSAccessKeyID,
+		SecretKey:    config.AWSSecretAccessKey,
+		SessionToken: config.AWSSessionToken,
+		Logger:       logger,
+	}
+	creds, err := credsConfig.GenerateCredentialChain()
+	if err != nil {
+		return nil, err
+	}
+
+	region := config.AWSS3Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	awsConfig := &aws.Config{
+		Credentials: creds,
+		HTTPClient: &http.Client{
+			Transport: cleanhttp.DefaultPooledTransport(),
+		},
+		Region:           aws.String(region),
+		S3ForcePathStyle: aws.Bool(config.AWSS3ForcePathStyle),
+		DisableSSL:       aws.Bool(config.AWSS3DisableTLS),
+	}
+	if config.AWSS3Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.AWSS3Endpoint)
+	}
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.New(sess)
+	return &s3SnapshotTarget{
+		client:   client,
+		uploader: s3manager.NewUploaderWithClient(client),
+		bucket:   config.AWSS3Bucket,
+		prefix:   config.PathPrefix,
+	}, nil
+}
+
+func (t *s3SnapshotTarget) key(name string) string {
+	if t.prefix == "" {
+		return name
+	}
+	return strings.TrimSuffix(t.prefix, "/") + "/" + name
+}
+
+func (t *s3SnapshotTarget) Put(ctx context.Context, name string, r io.Reader) (string, error) {
+	out, err := t.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(t.bucket),
+		Key:    aws.String(t.key(name)),
+		Body:   r,
+	})
+	if err != nil {
+		return "", err
+	}
+	return out.Location, nil
+}
+
+func (t *s3SnapshotTarget) List(ctx context.Context, prefix string) ([]string, error) {
+	dir := t.key("")
+
+	var names []string
+	err := t.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(t.bucket),
+		Prefix: aws.String(dir + prefix),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, object := range page.Contents {
+			name := strings.TrimPrefix(aws.StringValue(object.Key), dir)
+			if !strings.Contains(name, "/") {
+				names = append(names, name)
+			}
+		}
+		return true
+	})
+	return names, err
+}
+
+func (t *s3SnapshotTarget) Delete(ctx context.Context, name string) error {
+	_, err := t.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(t.bucket),
+		Key:    aws.String(t.key(name)),
+	})
+	return err
+}
+
+// autoSnapshotManager runs the snapshot schedules on the active node.
+type autoSnapshotManager struct {
+	core   *Core
+	raft   *raft.RaftBackend
+	logger hclog.Logger
+
+	l       sync.Mutex
+	ctx     context.Context
+	runners map[string]*autoSnapshotRunner
+	// stopped is set once the manager is stopped, so a request that loaded
+	// the manager before then does not start new runners
+	stopped bool
+}
+
+// autoSnapshotRunner takes the snapshots of a single configuration.
+type autoSnapshotRunner struct {
+	config *autoSnapshotConfig
+	target snapshotTarget
+	cancel context.CancelFunc
+	doneCh chan struct{}
+
+	l      sync.RWMutex
+	status autoSnapshotStatus
+}
+
+// startRaftAutoSnapshots starts running the stored snapshot schedules. It is
+// a no-op unless raft is the storage backend.
+func (c *Core) startRaftAutoSnapshots(ctx context.Context) error {
+	raftBackend, ok := c.underlyingPhysical.(*raft.RaftBackend)
+	if !ok {
+		return nil
+	}
+
+	m := &autoSnapshotManager{
+		core:    c,
+		raft:    raftBackend,
+		logger:  c.logger.Named("snapshot-auto"),
+		ctx:     c.activeContext,
+		runners: make(map[string]*autoSnapshotRunner),
+	}
+	c.AddLogger(m.logger)
+
+	names, err := c.barrier.List(ctx, raftAutoSnapshotConfigPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list automated snapshot configurations: %w", err)
+	}
+	for _, name := range names {
+		if err := m.reload(ctx, name); err != nil {
+			// A configuration that cannot be loaded should not prevent the
+			// node from becoming active
+			m.logger.Error("failed to start automated snapshots", "name", name, "error", err)
+		}
+	}
+
+	c.raftAutoSnapshots.Store(m)
+	return nil
+}
+
+// stopRaftAutoSnapshots stops the snapshot schedules and waits for the
+// snapshots in progress to be aborted.
+func (c *Core) stopRaftAutoSnapshots() {
+	m := c.raftAutoSnapshots.Swap(nil)
+	if m == nil {
+		return
+	}
+
+	m.l.Lock()
+	defer m.l.Unlock()
+	m.stopped = true
+	for name, runner := range m.runners {
+		runner.stop()
+		delete(m.runners, name)
+	}
+}
+
+// reload restarts the schedule of the named configuration from storage, or
+// stops it if the configuration was deleted.
+func (m *autoSnapshotManager) reload(ctx context.Context, name string) error {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	if m.stopped {
+		return nil
+	}
+	if runner, ok := m.runners[name]; ok {
+		runner.stop()
+		delete(m.runners, name)
+	}
+
+	config, err := m.core.loadAutoSnapshotConfig(ctx, name)
+	if err != nil || config == nil {
+		return err
+	}
+	status, err := m.core.loadAutoSnapshotStatus(ctx, name)
+	if err != nil {
+		return err
+	}
+	target, err := newSnapshotTarget(config, m.logger)
+	if err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancel(m.ctx)
+	runner := &autoSnapshotRunner{
+		config: config,
+		target: target,
+		cancel: cancel,
+		doneCh: make(chan struct{}),
+	}
+	if status != nil {
+		runner.status = *status
+	}
+	m.runners[name] = runner
+
+	go m.run(runCtx, runner)
+	return nil
+}
+
+// status returns the status of the named configuration if it is running
+func (m *autoSnapshotManager) status(name string) (autoSnapshotStatus, bool) {
+	m.l.Lock()
+	runner, ok := m.runners[name]
+	m.l.Unlock()
+	if !ok {
+		return autoSnapshotStatus{}, false
+	}
+
+	runner.l.RLock()
+	defer runner.l.RUnlock()
+	return runner.status, true
+}
+
+func (r *autoSnapshotRunner) stop() {
+	r.cancel()
+	<-r.doneCh
+}
+
+func (m *autoSnapshotManager) run(ctx context.Context, r *autoSnapshotRunner) {
+	defer close(r.doneCh)
+
+	for {
+		r.l.RLock()
+		next := r.status.nextSnapshotStart(r.config)
+		r.l.RUnlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		m.takeSnapshot(ctx, r)
+	}
+}
+
+// takeSnapshot saves a snapshot to the target of the runner, removes the
+// snapshots beyond the retained count, and records the outcome.
+func (m *autoSnapshotManager) takeSnapshot(ctx context.Context, r *autoSnapshotRunner) {
+	config := r.config
+	labels := []metrics.Label{{Name: "name", Value: config.Name}}
+	start := time.Now().UTC()
+	// Snapshot names sort in the order they were taken as long as the
+	// timestamp has the same number of digits, which holds until 2286
+	name := fmt.Sprintf("%s-%d%s", config.FilePrefix, start.UnixNano(), autoSnapshotExtension)
+
+	pr, pw := io.Pipe()
+	snapErrCh := make(chan error, 1)
+	go func() {
+		err := m.writeSnapshot(ctx, pw, config)
+		pw.CloseWithError(err)
+		snapErrCh <- err
+	}()
+
+	url, err := r.target.Put(ctx, name, pr)
+	// Unblock the snapshot if the target stopped reading it
+	pr.CloseWithError(errors.New("snapshot target closed"))
+	if snapErr := <-snapErrCh; snapErr != nil && err == nil {
+		err = snapErr
+	}
+	if err == nil {
+		err = m.pruneSnapshots(ctx, r)
+	}
+	if ctx.Err() != nil {
+		// Stopped by a seal, a step down or a configuration change
+		return
+	}
+
+	metrics.MeasureSinceWithLabels(autoSnapshotDurationMetric, start, labels)
+
+	r.l.Lock()
+	r.status.LastSnapshotStart = start
+	r.status.LastSnapshotEnd = time.Now().UTC()
+	if err != nil {
+		r.status.LastSnapshotError = err.Error()
+		r.status.ConsecutiveErrors++
+		metrics.IncrCounterWithLabels(autoSnapshotFailureMetric, 1, labels)
+		m.logger.Error("failed to take automated snapshot", "name", config.Name, "error", err)
+	} else {
+		r.status.LastSnapshotURL = url
+		r.status.LastSnapshotError = ""
+		r.status.ConsecutiveErrors = 0
+		metrics.IncrCounterWithLabels(autoSnapshotSuccessMetric, 1, labels)
+		m.logger.Info("took automated snapshot", "name", config.Name, "url", url)
+	}
+	metrics.SetGaugeWithLabels(autoSnapshotErrorsMetric, float32(r.status.ConsecutiveErrors), labels)
+	status := r.status
+	r.l.Unlock()
+
+	// The status is persisted so that the schedule carries over to the next
+	// active node
+	entry, err := logical.StorageEntryJSON(raftAutoSnapshotStatusPrefix+config.Name, status)
+	if err == nil {
+		err = m.core.barrier.Put(ctx, entry)
+	}
+	if err != nil {
+		m.logger.Error("failed to persist automated snapshot status", "name", config.Name, "error", err)
+	}
+}
+
+// writeSnapshot writes a snapshot to w, wrapped in an envelope if the
+// configuration has an envelope transit key.
+func (m *autoSnapshotManager) writeSnapshot(ctx context.Context, w io.Writer, config *autoSnapshotConfig) error {
+	sealer := NewSealAccessSealer(m.core.seal.GetAccess(), m.logger, "snapshot_auto")
+	if config.EnvelopeTransitKey == "" {
+		return m.raft.Snapshot(w, sealer)
+	}
+
+	signingKey, err := parseSnapshotSigningKey(config.EnvelopeSigningKey)
+	if err != nil {
+		return err
+	}
+	dataKey, err := m.core.snapshotTransitDataKey(ctx, config.EnvelopeTransitKey)
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	snapErrCh := make(chan error, 1)
+	go func() {
+		err := m.raft.Snapshot(pw, sealer)
+		pw.CloseWithError(err)
+		snapErrCh <- err
+	}()
+	err = raft.WriteSnapshotEnvelope(w, pr, &raft.SnapshotEnvelopeSealOptions{
+		TransitDataKey: dataKey,
+		SigningKey:     signingKey,
+	})
+	pr.CloseWithError(errors.New("snapshot envelope closed"))
+	if snapErr := <-snapErrCh; snapErr != nil && err == nil {
+		err = snapErr
+	}
+	return err
+}
+
+// snapshotTransitDataKey generates the data key of a snapshot envelope with
+// the datakey endpoint of the transit key, which is never exported.
+func (c *Core) snapshotTransitDataKey(ctx context.Context, keyID string) (*raft.SnapshotTransitDataKey, error) {
+	mount, name, err := raft.SplitSnapshotTransitKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.router.Route(namespace.RootContext(ctx), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      mount + "/datakey/plaintext/" + name,
+		Data: map[string]interface{}{
+			"bits": 256,
+		},
+	})
+	if err == nil && resp != nil && resp.IsError() {
+		err = resp.Error()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate a data key with transit key %q: %w", keyID, err)
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("no data key returned by transit key %q", keyID)
+	}
+	plaintext, _ := resp.Data["plaintext"].(string)
+	ciphertext, _ := resp.Data["ciphertext"].(string)
+	key, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil || ciphertext == "" {
+		return nil, fmt.Errorf("invalid data key returned by transit key %q", keyID)
+	}
+	return &raft.SnapshotTransitDataKey{
+		KeyID:      mount + "/" + name,
+		Plaintext:  key,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// parseSnapshotSigningKey parses a PEM encoded ed25519 private key.
+func parseSnapshotSigningKey(raw string) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(raw))
+	if block == nil {
+		return nil, errors.New("no PEM block found in the signing key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing signing key: %w", err)
+	}
+	signingKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key must be an ed25519 key")
+	}
+	return signingKey, nil
+}
+
+// pruneSnapshots removes the oldest snapshots of the runner beyond its
+// retained count.
+func (m *autoSnapshotManager) pruneSnapshots(ctx context.Context, r *autoSnapshotRunner) error {
+	names, err := r.target.List(ctx, r.config.FilePrefix+"-")
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var snapshots []string
+	for _, name := range names {
+		if isAutoSnapshotName(r.config.FilePrefix, name) {
+			snapshots = append(snapshots, name)
+		}
+	}
+	if len(snapshots) <= r.config.Retain {
+		return nil
+	}
+
+	sort.Strings(snapshots)
+	for _, name := range snapshots[:len(snapshots)-r.config.Retain] {
+		if err := r.target.Delete(ctx, name); err != nil {
+			return fmt.Errorf("failed to delete snapshot %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// isAutoSnapshotName returns whether the name is the one of a snapshot taken
+// with the file prefix, rather than of a configuration whose file prefix
+// starts with it.
+func isAutoSnapshotName(filePrefix, name string) bool {
+	timestamp, ok := strings.CutPrefix(name, filePrefix+"-")
+	if !ok {
+		return false
+	}
+	timestamp, ok = strings.CutSuffix(timestamp, autoSnapshotExtension)
+	if !ok {
+		return false
+	}
+	_, err := strconv.ParseInt(timestamp, 10, 64)
+	return err == nil
+}
+
+func (c *Core) loadAutoSnapshotConfig(ctx context.Context, name string) (*autoSnapshotConfig, error) {
+	entry, err := c.barrier.Get(ctx, raftAutoSnapshotConfigPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var config autoSnapshotConfig
+	if err := entry.DecodeJSON(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (c *Core) loadAutoSnapshotStatus(ctx context.Context, name string) (*autoSnapshotStatus, error) {
+	entry, err := c.barrier.Get(ctx, raftAutoSnapshotStatusPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var status autoSnapshotStatus
+	if err := entry.DecodeJSON(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
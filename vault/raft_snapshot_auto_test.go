@@ -0,0 +1,217 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a minimal S3-compatible server standing in for MinIO, which
+// supports the requests made by the S3 snapshot target.
+type fakeS3 struct {
+	bucket string
+
+	l       sync.Mutex
+	objects map[string][]byte
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, string) {
+	t.Helper()
+	f := &fakeS3{bucket: bucket, objects: make(map[string][]byte)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+func (f *fakeS3) keys() []string {
+	f.l.Lock()
+	defer f.l.Unlock()
+
+	var keys []string
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.l.Lock()
+	defer f.l.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPut && key != "":
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		f.objects[key] = body
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodDelete && key != "":
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && key == "" && r.URL.Query().Get("list-type") == "2":
+		type content struct {
+			Key  string
+			Size int
+		}
+		result := struct {
+			XMLName     xml.Name `xml:"ListBucketResult"`
+			Name        string
+			Prefix      string
+			KeyCount    int
+			IsTruncated bool
+			Contents    []content
+		}{Name: f.bucket, Prefix: r.URL.Query().Get("prefix")}
+		for key, value := range f.objects {
+			if strings.HasPrefix(key, result.Prefix) {
+				result.Contents = append(result.Contents, content{Key: key, Size: len(value)})
+			}
+		}
+		result.KeyCount = len(result.Contents)
+		w.Header().Set("Content-Type", "application/xml")
+		xml.NewEncoder(w).Encode(result)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func testSnapshotTarget(t *testing.T, target snapshotTarget) {
+	t.Helper()
+	ctx := context.Background()
+
+	for _, name := range []string{"vault-snapshot-1.snap", "vault-snapshot-2.snap", "other-1.snap"} {
+		_, err := target.Put(ctx, name, strings.NewReader(name))
+		require.NoError(t, err)
+	}
+
+	names, err := target.List(ctx, "vault-snapshot-")
+	require.NoError(t, err)
+	sort.Strings(names)
+	require.Equal(t, []string{"vault-snapshot-1.snap", "vault-snapshot-2.snap"}, names)
+
+	require.NoError(t, target.Delete(ctx, "vault-snapshot-1.snap"))
+	names, err = target.List(ctx, "vault-snapshot-")
+	require.NoError(t, err)
+	require.Equal(t, []string{"vault-snapshot-2.snap"}, names)
+}
+
+func TestLocalSnapshotTarget(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "snapshots")
+	target, err := newSnapshotTarget(&autoSnapshotConfig{
+		StorageType: autoSnapshotStorageLocal,
+		PathPrefix:  dir,
+		FilePrefix:  autoSnapshotDefaultFilePrefix,
+	}, logger)
+	require.NoError(t, err)
+	testSnapshotTarget(t, target)
+
+	url, err := target.Put(context.Background(), "vault-snapshot-3.snap", strings.NewReader("data"))
+	require.NoError(t, err)
+	require.Equal(t, "file://"+filepath.ToSlash(filepath.Join(dir, "vault-snapshot-3.snap")), url)
+
+	// No temporary file is left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+}
+
+func TestLocalSnapshotTarget_MaxSpace(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	target, err := newSnapshotTarget(&autoSnapshotConfig{
+		StorageType:   autoSnapshotStorageLocal,
+		PathPrefix:    dir,
+		FilePrefix:    autoSnapshotDefaultFilePrefix,
+		LocalMaxSpace: 10,
+	}, logger)
+	require.NoError(t, err)
+
+	_, err = target.Put(ctx, "vault-snapshot-1.snap", strings.NewReader("123456"))
+	require.NoError(t, err)
+
+	// Only 4 bytes are left in the allowance
+	_, err = target.Put(ctx, "vault-snapshot-2.snap", strings.NewReader("123456"))
+	require.ErrorContains(t, err, "local_max_space")
+	names, err := target.List(ctx, "vault-snapshot-")
+	require.NoError(t, err)
+	require.Equal(t, []string{"vault-snapshot-1.snap"}, names)
+
+	_, err = target.Put(ctx, "vault-snapshot-2.snap", strings.NewReader("1234"))
+	require.NoError(t, err)
+}
+
+// TestAutoSnapshot_OverlappingFilePrefixes tests that the retention and the
+// space allowance of a configuration ignore the snapshots of another one whose
+// file prefix starts with its own.
+func TestAutoSnapshot_OverlappingFilePrefixes(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	newRunner := func(filePrefix string, maxSpace int64) *autoSnapshotRunner {
+		config := &autoSnapshotConfig{
+			StorageType:   autoSnapshotStorageLocal,
+			PathPrefix:    dir,
+			FilePrefix:    filePrefix,
+			LocalMaxSpace: maxSpace,
+			Retain:        1,
+		}
+		target, err := newSnapshotTarget(config, logger)
+		require.NoError(t, err)
+		return &autoSnapshotRunner{config: config, target: target}
+	}
+	snapshots := newRunner(autoSnapshotDefaultFilePrefix, 12)
+	daily := newRunner(autoSnapshotDefaultFilePrefix+"-daily", 0)
+
+	for _, name := range []string{"vault-snapshot-daily-1.snap", "vault-snapshot-daily-2.snap"} {
+		_, err := daily.target.Put(ctx, name, strings.NewReader("1234567890"))
+		require.NoError(t, err)
+	}
+	for _, name := range []string{"vault-snapshot-1.snap", "vault-snapshot-2.snap"} {
+		_, err := snapshots.target.Put(ctx, name, strings.NewReader("123456"))
+		require.NoError(t, err)
+		require.NoError(t, (&autoSnapshotManager{}).pruneSnapshots(ctx, snapshots))
+	}
+
+	names, err := snapshots.target.List(ctx, "")
+	require.NoError(t, err)
+	sort.Strings(names)
+	require.Equal(t, []string{"vault-snapshot-2.snap", "vault-snapshot-daily-1.snap", "vault-snapshot-daily-2.snap"}, names)
+}
+
+func TestS3SnapshotTarget(t *testing.T) {
+	s3, endpoint := newFakeS3(t, "snapshots")
+	target, err := newSnapshotTarget(&autoSnapshotConfig{
+		StorageType:         autoSnapshotStorageS3,
+		PathPrefix:          "raft/",
+		FilePrefix:          autoSnapshotDefaultFilePrefix,
+		AWSS3Bucket:         "snapshots",
+		AWSS3Endpoint:       endpoint,
+		AWSS3ForcePathStyle: true,
+		AWSAccessKeyID:      "access",
+		AWSSecretAccessKey:  "secret",
+	}, logger)
+	require.NoError(t, err)
+	testSnapshotTarget(t, target)
+
+	require.Equal(t, []string{"raft/other-1.snap", "raft/vault-snapshot-2.snap"}, s3.keys())
+}
@@ -0,0 +1,215 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/sdk/plugin/pb"
+)
+
+// snapshotCorePrefixes are the storage prefixes that hold the state of the
+// barrier and of the core itself. Not all of their entries are encrypted with
+// the keyring, and they can only be restored along with the rest of the
+// cluster state.
+var snapshotCorePrefixes = []string{
+	"core/",
+	"barrier/",
+}
+
+// snapshotPartialRestoreResult describes the changes made, or that would be
+// made in a dry run, by a partial snapshot restore.
+type snapshotPartialRestoreResult struct {
+	Prefix    string
+	Created   []string
+	Updated   []string
+	Unchanged int
+}
+
+// snapshotPartialRestorePrefix returns the storage prefix to restore from the
+// mount UUID and the prefix, which is relative to the storage of the mount if
+// one is given. Without a mount UUID, the prefix must be within the storage of
+// secrets and auth mounts. The rest of the storage, such as tokens, leases and
+// policies, is mirrored by in-memory state that a partial restore would not
+// update.
+func (c *Core) snapshotPartialRestorePrefix(mountUUID, prefix string) (string, error) {
+	if mountUUID == "" {
+		if prefix == "" {
+			return "", errors.New("one of mount_uuid or prefix is required")
+		}
+		if !strings.HasPrefix(prefix, backendBarrierPrefix) && !strings.HasPrefix(prefix, credentialBarrierPrefix) {
+			return "", fmt.Errorf("only storage under %q or %q can be partially restored", backendBarrierPrefix, credentialBarrierPrefix)
+		}
+		if entry := c.singletonMountOverlapping(prefix); entry != nil {
+			return "", fmt.Errorf("storage of %q mounts cannot be partially restored", entry.Type)
+		}
+		return prefix, nil
+	}
+
+	entry := c.router.MatchingMountByUUID(mountUUID)
+	if entry == nil {
+		return "", fmt.Errorf("no mount found with UUID %q", mountUUID)
+	}
+	if strutil.StrListContains(singletonMounts, entry.Type) {
+		return "", fmt.Errorf("storage of %q mounts cannot be partially restored", entry.Type)
+	}
+
+	viewPath := backendBarrierPrefix
+	if entry.Table == credentialTableType {
+		viewPath = credentialBarrierPrefix
+	}
+	return viewPath + entry.UUID + "/" + prefix, nil
+}
+
+// restoreSnapshotPartial reads the storage entries of a raft snapshot, as
+// written by RaftBackend.WriteSnapshotToTemp, and writes the ones under prefix
+// that differ from the live storage through the barrier. The values are
+// decrypted with the current keyring, so the snapshot must have been taken by
+// this cluster and none of the keys it uses may have been pruned since. When
+// dryRun is set, the changes are only reported. Entries that exist in the
+// live storage but not in the snapshot are left untouched.
+func (c *Core) restoreSnapshotPartial(ctx context.Context, snap io.Reader, prefix string, dryRun bool) (*snapshotPartialRestoreResult, error) {
+	type change struct {
+		key   string
+		value []byte
+	}
+
+	result := &snapshotPartialRestoreResult{
+		Prefix:  prefix,
+		Created: []string{},
+		Updated: []string{},
+	}
+
+	// Every value is decrypted and compared before anything is written so
+	// that a snapshot that cannot be read does not leave the storage
+	// partially restored.
+	var changes []change
+	protoReader := raft.NewDelimitedReader(snap, math.MaxInt32)
+	for {
+		entry := new(pb.StorageEntry)
+		if err := protoReader.ReadMsg(entry); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		if !strings.HasPrefix(entry.Key, prefix) {
+			continue
+		}
+
+		plaintext, err := c.barrier.Decrypt(ctx, entry.Key, entry.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt snapshot entry %q: %w", entry.Key, err)
+		}
+
+		live, err := c.barrier.Get(ctx, entry.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read entry %q: %w", entry.Key, err)
+		}
+		switch {
+		case live == nil:
+			result.Created = append(result.Created, entry.Key)
+		case !bytes.Equal(live.Value, plaintext):
+			result.Updated = append(result.Updated, entry.Key)
+		default:
+			result.Unchanged++
+			continue
+		}
+		changes = append(changes, change{key: entry.Key, value: plaintext})
+	}
+
+	if dryRun {
+		return result, nil
+	}
+
+	for _, change := range changes {
+		if err := c.barrier.Put(ctx, &logical.StorageEntry{Key: change.key, Value: change.value}); err != nil {
+			return nil, fmt.Errorf("failed to write entry %q: %w", change.key, err)
+		}
+	}
+
+	if len(changes) > 0 {
+		c.logger.Info("partially restored snapshot", "prefix", prefix, "created", len(result.Created), "updated", len(result.Updated))
+		if err := c.reloadPartiallyRestoredMounts(ctx, prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// singletonMountOverlapping returns a singleton mount, such as cubbyhole or
+// identity, whose storage overlaps prefix, or nil if there is none.
+func (c *Core) singletonMountOverlapping(prefix string) *MountEntry {
+	c.mountsLock.RLock()
+	defer c.mountsLock.RUnlock()
+	c.authLock.RLock()
+	defer c.authLock.RUnlock()
+
+	var entries []*MountEntry
+	if c.mounts != nil {
+		entries = append(entries, c.mounts.Entries...)
+	}
+	if c.auth != nil {
+		entries = append(entries, c.auth.Entries...)
+	}
+
+	for _, entry := range entries {
+		if !strutil.StrListContains(singletonMounts, entry.Type) {
+			continue
+		}
+		viewPath := backendBarrierPrefix + entry.UUID + "/"
+		if entry.Table == credentialTableType {
+			viewPath = credentialBarrierPrefix + entry.UUID + "/"
+		}
+		if strings.HasPrefix(viewPath, prefix) || strings.HasPrefix(prefix, viewPath) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// reloadPartiallyRestoredMounts reloads the backends of the mounts whose
+// storage overlaps prefix so that they drop anything they cached from the
+// entries that were restored.
+func (c *Core) reloadPartiallyRestoredMounts(ctx context.Context, prefix string) error {
+	c.mountsLock.RLock()
+	defer c.mountsLock.RUnlock()
+	c.authLock.RLock()
+	defer c.authLock.RUnlock()
+
+	var entries []*MountEntry
+	if c.mounts != nil {
+		entries = append(entries, c.mounts.Entries...)
+	}
+	if c.auth != nil {
+		entries = append(entries, c.auth.Entries...)
+	}
+
+	var errors error
+	for _, entry := range entries {
+		isAuth := entry.Table == credentialTableType
+		viewPath := backendBarrierPrefix + entry.UUID + "/"
+		if isAuth {
+			viewPath = credentialBarrierPrefix + entry.UUID + "/"
+		}
+		if !strings.HasPrefix(viewPath, prefix) && !strings.HasPrefix(prefix, viewPath) {
+			continue
+		}
+		if err := c.reloadBackendCommon(ctx, entry, isAuth); err != nil {
+			errors = multierror.Append(errors, fmt.Errorf("failed to reload mount %q: %w", entry.Path, err))
+		}
+	}
+	return errors
+}
@@ -0,0 +1,400 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault/quotas"
+)
+
+// readReplicaReloadKeys are storage keys whose modification requires the
+// replica to tear down and rebuild its in-memory state, as the active node
+// does on a leadership change.
+var readReplicaReloadKeys = map[string]struct{}{
+	coreMountConfigPath:      {},
+	coreLocalMountConfigPath: {},
+	coreAuthConfigPath:       {},
+	coreLocalAuthConfigPath:  {},
+	coreAuditConfigPath:      {},
+	coreLocalAuditConfigPath: {},
+}
+
+// readReplicaUpdate is a batch of storage keys modified by the raft log up to
+// and including index. A nil set of keys means the whole FSM was replaced.
+type readReplicaUpdate struct {
+	index uint64
+	keys  []string
+}
+
+// readReplicaState tracks a raft non-voter that serves read requests from its
+// local FSM. The FSM reports every applied batch of logs, which is queued and
+// used to invalidate the node's in-memory state; the index of the last
+// processed batch is what requests carrying an X-Vault-Index header wait on.
+type readReplicaState struct {
+	backend      *raft.RaftBackend
+	maxStaleness time.Duration
+	logger       log.Logger
+
+	l        sync.Mutex
+	pending  []readReplicaUpdate
+	notifyCh chan struct{}
+
+	indexLock      sync.Mutex
+	processedIndex uint64
+	// indexCh is closed and replaced every time processedIndex advances.
+	indexCh chan struct{}
+}
+
+func newReadReplicaState(backend *raft.RaftBackend, maxStaleness time.Duration, logger log.Logger) *readReplicaState {
+	return &readReplicaState{
+		backend:      backend,
+		maxStaleness: maxStaleness,
+		logger:       logger,
+		notifyCh:     make(chan struct{}, 1),
+		indexCh:      make(chan struct{}),
+	}
+}
+
+// enqueue is registered as the FSM invalidate callback, so it must not block.
+func (r *readReplicaState) enqueue(index uint64, keys []string) {
+	r.l.Lock()
+	r.pending = append(r.pending, readReplicaUpdate{index: index, keys: keys})
+	r.l.Unlock()
+
+	select {
+	case r.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+func (r *readReplicaState) drain() []readReplicaUpdate {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	pending := r.pending
+	r.pending = nil
+	return pending
+}
+
+func (r *readReplicaState) setProcessedIndex(index uint64) {
+	r.indexLock.Lock()
+	defer r.indexLock.Unlock()
+
+	if index <= r.processedIndex {
+		return
+	}
+	r.processedIndex = index
+	close(r.indexCh)
+	r.indexCh = make(chan struct{})
+}
+
+// waitForIndex blocks until the invalidations for the given raft index have
+// been processed, giving up after the configured maximum staleness.
+func (r *readReplicaState) waitForIndex(index uint64) bool {
+	timer := time.NewTimer(r.maxStaleness)
+	defer timer.Stop()
+
+	for {
+		r.indexLock.Lock()
+		processed, ch := r.processedIndex, r.indexCh
+		r.indexLock.Unlock()
+
+		if processed >= index {
+			return true
+		}
+
+		select {
+		case <-ch:
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+// stale reports whether the replica has not heard from the leader within the
+// configured maximum staleness, in which case it should stop serving reads.
+func (r *readReplicaState) stale() bool {
+	lastContact := r.backend.LastContact()
+	return lastContact.IsZero() || time.Since(lastContact) > r.maxStaleness
+}
+
+// readReplicaBackend returns the raft backend and its staleness bound if this
+// node is configured as a read replica and joined the cluster as a non-voter.
+func (c *Core) readReplicaBackend() (*raft.RaftBackend, time.Duration, bool) {
+	raftBackend, ok := c.underlyingPhysical.(*raft.RaftBackend)
+	if !ok {
+		return nil, 0, false
+	}
+
+	enabled, maxStaleness := raftBackend.ReadReplica()
+	if !enabled || raftBackend.DesiredSuffrage() != "non-voter" {
+		return nil, 0, false
+	}
+
+	return raftBackend, maxStaleness, true
+}
+
+// runReadReplica is a long running routine that runs on standbys configured
+// as read replicas. It sets the node up to serve read requests locally and
+// keeps its in-memory state in sync with the raft log until stopCh is closed.
+// Like the other standby actors, it only returns once stopCh is closed.
+func (c *Core) runReadReplica(stopCh chan struct{}) {
+	raftBackend, maxStaleness, ok := c.readReplicaBackend()
+	if !ok {
+		<-stopCh
+		return
+	}
+
+	logger := c.logger.Named("read-replica")
+	state := newReadReplicaState(raftBackend, maxStaleness, logger)
+
+	raftBackend.SetFSMInvalidateCallback(state.enqueue)
+	defer raftBackend.SetFSMInvalidateCallback(nil)
+
+	serving := false
+	for {
+		if !serving {
+			stopped, err := c.startReadReplica(state, stopCh)
+			if stopped {
+				return
+			}
+			if err != nil {
+				logger.Error("failed to set up read replica, forwarding all requests to the active node", "error", err)
+				select {
+				case <-stopCh:
+					return
+				case <-time.After(time.Second):
+				}
+				continue
+			}
+			serving = true
+		}
+
+		select {
+		case <-stopCh:
+			c.stopReadReplica(stopCh)
+			return
+		case <-state.notifyCh:
+			updates := state.drain()
+			if len(updates) == 0 {
+				continue
+			}
+
+			reload, stopped := c.invalidateReadReplica(state, updates, stopCh)
+			if stopped {
+				c.stopReadReplica(stopCh)
+				return
+			}
+			if reload {
+				// Our state is rebuilt from storage from scratch, so there is
+				// nothing left to invalidate until the next batch.
+				c.stopReadReplica(stopCh)
+				serving = false
+				continue
+			}
+
+			state.setProcessedIndex(updates[len(updates)-1].index)
+		}
+	}
+}
+
+// startReadReplica loads the node's in-memory state from its local FSM and
+// starts serving read requests. stopped is true if stopCh was closed while
+// waiting for the state lock.
+func (c *Core) startReadReplica(state *readReplicaState, stopCh chan struct{}) (stopped bool, err error) {
+	l := newLockGrabber(c.stateLock.Lock, c.stateLock.Unlock, stopCh)
+	go l.grab()
+	if stopped := l.lockOrStop(); stopped {
+		return true, nil
+	}
+	defer c.stateLock.Unlock()
+
+	if c.Sealed() {
+		return false, errors.New("vault is sealed")
+	}
+
+	// Everything queued so far is covered by loading state from scratch.
+	state.drain()
+	appliedIndex := state.backend.AppliedIndex()
+
+	activeCtx, activeCtxCancel := context.WithCancel(namespace.RootContext(nil))
+
+	c.perfStandby = true
+	if err := c.postUnseal(activeCtx, activeCtxCancel, readReplicaUnsealStrategy{}); err != nil {
+		c.perfStandby = false
+		return false, err
+	}
+
+	state.setProcessedIndex(appliedIndex)
+	c.readReplica.Store(state)
+	state.logger.Info("serving read requests from local storage", "applied_index", appliedIndex)
+
+	return false, nil
+}
+
+// stopReadReplica tears down the state set up by startReadReplica. As in
+// waitForLeadership, the teardown happens even if stopCh is closed, since in
+// that case the caller closing it holds the state lock and is waiting for us.
+func (c *Core) stopReadReplica(stopCh chan struct{}) {
+	l := newLockGrabber(c.stateLock.Lock, c.stateLock.Unlock, stopCh)
+	go l.grab()
+	stopped := l.lockOrStop()
+
+	c.readReplica.Store(nil)
+	if cancel := c.activeContextCancelFunc.Load().(context.CancelFunc); cancel != nil {
+		cancel()
+	}
+
+	if err := c.preSeal(); err != nil {
+		c.logger.Error("read replica teardown failed", "error", err)
+	}
+	c.perfStandby = false
+
+	if !stopped {
+		c.stateLock.Unlock()
+	}
+}
+
+// invalidateReadReplica applies a set of FSM updates to the node's in-memory
+// state. reload is true if the updates require the state to be rebuilt from
+// scratch, stopped is true if stopCh was closed while waiting for the state
+// lock.
+func (c *Core) invalidateReadReplica(state *readReplicaState, updates []readReplicaUpdate, stopCh chan struct{}) (reload, stopped bool) {
+	ctx := namespace.RootContext(c.activeContext)
+
+	var reloadCORS bool
+	for _, update := range updates {
+		if update.keys == nil {
+			state.logger.Info("storage was restored from a snapshot, reloading")
+			return true, false
+		}
+
+		for _, key := range update.keys {
+			switch {
+			case isReadReplicaReloadKey(key):
+				state.logger.Debug("core configuration changed, reloading", "key", key)
+				return true, false
+
+			case key == systemBarrierPrefix+"config/cors":
+				reloadCORS = true
+
+			case strings.HasPrefix(key, systemBarrierPrefix+policyACLSubPath):
+				c.policyStore.invalidate(ctx, strings.TrimPrefix(key, systemBarrierPrefix+policyACLSubPath), PolicyTypeACL)
+
+			case strings.HasPrefix(key, systemBarrierPrefix+policyRGPSubPath):
+				c.policyStore.invalidate(ctx, strings.TrimPrefix(key, systemBarrierPrefix+policyRGPSubPath), PolicyTypeRGP)
+
+			case strings.HasPrefix(key, systemBarrierPrefix+policyEGPSubPath):
+				c.policyStore.invalidate(ctx, strings.TrimPrefix(key, systemBarrierPrefix+policyEGPSubPath), PolicyTypeEGP)
+
+			case strings.HasPrefix(key, systemBarrierPrefix+quotas.StoragePrefix):
+				if c.quotaManager != nil {
+					c.quotaManager.Invalidate(strings.TrimPrefix(key, systemBarrierPrefix+quotas.StoragePrefix))
+				}
+
+			default:
+				c.invalidateMountKey(ctx, key)
+			}
+		}
+	}
+
+	if reloadCORS {
+		// loadCORSConfig must be called with the state lock held for writing
+		l := newLockGrabber(c.stateLock.Lock, c.stateLock.Unlock, stopCh)
+		go l.grab()
+		if stopped := l.lockOrStop(); stopped {
+			return false, true
+		}
+		err := c.loadCORSConfig(ctx)
+		c.stateLock.Unlock()
+		if err != nil {
+			state.logger.Error("failed to reload CORS config", "error", err)
+		}
+	}
+
+	return false, false
+}
+
+func isReadReplicaReloadKey(key string) bool {
+	if _, ok := readReplicaReloadKeys[key]; ok {
+		return true
+	}
+	return strings.HasPrefix(key, systemBarrierPrefix+auditedHeadersSubPath)
+}
+
+// invalidateMountKey passes a modified storage key on to the backend of the
+// mount that owns it, if any.
+func (c *Core) invalidateMountKey(ctx context.Context, key string) {
+	ns, mountPath, storagePrefix, found := c.router.MatchingAPIPrefixByStoragePath(ctx, key)
+	if !found {
+		return
+	}
+
+	nsCtx := namespace.ContextWithNamespace(ctx, ns)
+	backend := c.router.MatchingBackend(nsCtx, mountPath)
+	if backend == nil {
+		return
+	}
+
+	backend.InvalidateKey(nsCtx, strings.TrimPrefix(key, storagePrefix))
+}
+
+// checkReadReplicaRequest returns ErrPerfStandbyPleaseForward for requests a
+// read replica cannot serve from its local storage: anything that is not a
+// read, logins, and any request while the replica has lost touch with the
+// leader for longer than the configured maximum staleness.
+func (c *Core) checkReadReplicaRequest(ctx context.Context, req *logical.Request) error {
+	state := c.readReplica.Load()
+	if state == nil {
+		return nil
+	}
+
+	switch req.Operation {
+	case logical.ReadOperation, logical.ListOperation, logical.HelpOperation:
+	default:
+		return logical.ErrPerfStandbyPleaseForward
+	}
+
+	if c.isLoginRequest(ctx, req) {
+		return logical.ErrPerfStandbyPleaseForward
+	}
+
+	if state.stale() {
+		return logical.ErrPerfStandbyPleaseForward
+	}
+
+	return nil
+}
+
+// readReplicaUnsealStrategy sets up the subset of the active node's state
+// needed to serve read requests. Anything that writes to storage or runs
+// background jobs on behalf of the cluster is left to the active node.
+type readReplicaUnsealStrategy struct{}
+
+func (readReplicaUnsealStrategy) unseal(ctx context.Context, logger log.Logger, c *Core) error {
+	// The physical cache is only invalidated by writes going through it,
+	// which never happens for data replicated through the raft log.
+	c.physicalCache.SetEnabled(false)
+	c.physicalCache.Purge(ctx)
+
+	if err := runUnsealSetupFunctions(ctx, buildUnsealSetupFunctionSlice(c)); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	if err := c.setupActivityLog(ctx, &wg); err != nil {
+		return err
+	}
+
+	return nil
+}
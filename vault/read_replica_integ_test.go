@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/helper/testhelpers"
+	"github.com/hashicorp/vault/helper/testhelpers/corehelpers"
+	"github.com/hashicorp/vault/helper/testhelpers/teststorage"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReadReplica_RaftCluster tests that a raft non-voter configured as a read
+// replica serves reads from its local storage, and forwards writes and reads
+// that create a lease to the active node.
+func TestReadReplica_RaftCluster(t *testing.T) {
+	t.Parallel()
+	conf := &vault.CoreConfig{
+		DisableAutopilot: true,
+		LogicalBackends: map[string]logical.Factory{
+			"leased-kv": vault.LeasedPassthroughBackendFactory,
+		},
+	}
+	opts := vault.TestClusterOptions{
+		HandlerFunc:        vaulthttp.Handler,
+		NumCores:           2,
+		InmemClusterLayers: true,
+		Logger:             corehelpers.NewTestLogger(t).Named("cluster"),
+		PerNodePhysicalFactoryConfig: map[int]map[string]interface{}{
+			1: {
+				"retry_join":                 "not-empty",
+				"retry_join_as_non_voter":    "true",
+				"read_replica":               "true",
+				"read_replica_max_staleness": "1h",
+			},
+		},
+	}
+	teststorage.RaftBackendSetup(conf, &opts)
+	// The replica joins as a non-voter below
+	opts.SetupFunc = nil
+	cluster := vault.NewTestCluster(t, conf, &opts)
+	defer cluster.Cleanup()
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+
+	leader := cluster.Cores[0]
+	_, err := cluster.Cores[1].JoinRaftCluster(namespace.RootContext(context.Background()), []*raft.LeaderJoinInfo{
+		{
+			LeaderAPIAddr: leader.Client.Address(),
+			TLSConfig:     leader.TLSConfig(),
+		},
+	}, true)
+	require.NoError(t, err)
+	cluster.UnsealCore(t, cluster.Cores[1])
+	testhelpers.WaitForNCoresUnsealed(t, cluster, 2)
+
+	leaderClient := cluster.Cores[0].Client
+	replicaClient := cluster.Cores[1].Client
+
+	require.NoError(t, leaderClient.Sys().Mount("leased", &api.MountInput{Type: "leased-kv"}))
+	_, err = leaderClient.Logical().Write("secret/foo", map[string]interface{}{"value": "leader"})
+	require.NoError(t, err)
+	_, err = leaderClient.Logical().Write("leased/foo", map[string]interface{}{"value": "leased", "ttl": "1h"})
+	require.NoError(t, err)
+
+	// The replica serves reads once it has applied the writes
+	corehelpers.RetryUntil(t, 30*time.Second, func() error {
+		if !cluster.Cores[1].PerfStandby() {
+			return errors.New("replica is not serving reads yet")
+		}
+		secret, err := replicaClient.Logical().Read("secret/foo")
+		if err != nil {
+			return err
+		}
+		if secret == nil || secret.Data["value"] != "leader" {
+			return errors.New("write not applied on the replica yet")
+		}
+		return nil
+	})
+
+	// Writes are forwarded to the active node
+	_, err = replicaClient.Logical().Write("secret/bar", map[string]interface{}{"value": "replica"})
+	require.NoError(t, err)
+	secret, err := leaderClient.Logical().Read("secret/bar")
+	require.NoError(t, err)
+	require.Equal(t, "replica", secret.Data["value"])
+
+	// Reads creating a lease are forwarded to the active node, which stores
+	// the lease
+	secret, err = replicaClient.Logical().Read("leased/foo")
+	require.NoError(t, err)
+	require.Equal(t, "leased", secret.Data["value"])
+	require.NotEmpty(t, secret.LeaseID)
+	lease, err := leaderClient.Sys().Lookup(secret.LeaseID)
+	require.NoError(t, err)
+	require.Equal(t, secret.LeaseID, lease.Data["id"])
+
+	// With the active node sealed, reads are still served from the local
+	// storage of the replica, while writes cannot be forwarded anymore
+	cluster.Cores[0].Seal(t)
+	secret, err = replicaClient.Logical().Read("secret/foo")
+	require.NoError(t, err)
+	require.Equal(t, "leader", secret.Data["value"])
+
+	_, err = replicaClient.Logical().Write("secret/baz", map[string]interface{}{"value": "replica"})
+	require.Error(t, err)
+}
@@ -0,0 +1,85 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// TestCore_EncodeIndexState verifies that the index header emitted by the
+// server can be parsed and verified by the api package.
+func TestCore_EncodeIndexState(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+
+	state := &logical.WALState{
+		ClusterID:       c.ClusterID(),
+		LocalIndex:      42,
+		ReplicatedIndex: 7,
+	}
+	header := c.EncodeIndexState(state)
+	if header == "" {
+		t.Fatal("expected a header")
+	}
+
+	parsed, err := api.ParseReplicationState(header, c.headerHMACKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.ClusterID != state.ClusterID || parsed.LocalIndex != state.LocalIndex || parsed.ReplicatedIndex != state.ReplicatedIndex {
+		t.Fatalf("bad state: %#v", parsed)
+	}
+
+	if _, err := api.ParseReplicationState(header, []byte("wrong-key")); err == nil {
+		t.Fatal("expected HMAC verification to fail with the wrong key")
+	}
+}
+
+func TestReadReplicaState_WaitForIndex(t *testing.T) {
+	state := newReadReplicaState(nil, 2*time.Second, hclog.NewNullLogger())
+	state.setProcessedIndex(5)
+
+	if !state.waitForIndex(3) {
+		t.Fatal("expected an already processed index to be satisfied")
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		state.setProcessedIndex(8)
+		time.Sleep(50 * time.Millisecond)
+		state.setProcessedIndex(10)
+	}()
+	if !state.waitForIndex(10) {
+		t.Fatal("expected index to be satisfied once processed")
+	}
+
+	state.maxStaleness = 50 * time.Millisecond
+	if state.waitForIndex(11) {
+		t.Fatal("expected wait for an unprocessed index to time out")
+	}
+}
+
+func TestReadReplicaState_Drain(t *testing.T) {
+	state := newReadReplicaState(nil, time.Second, hclog.NewNullLogger())
+	state.enqueue(1, []string{"foo"})
+	state.enqueue(2, nil)
+
+	select {
+	case <-state.notifyCh:
+	default:
+		t.Fatal("expected a notification")
+	}
+
+	updates := state.drain()
+	if len(updates) != 2 || updates[0].index != 1 || updates[1].keys != nil {
+		t.Fatalf("bad updates: %#v", updates)
+	}
+	if len(state.drain()) != 0 {
+		t.Fatal("expected queue to be empty after draining")
+	}
+}
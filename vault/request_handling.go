@@ -495,12 +495,7 @@ func (c *Core) CheckToken(ctx context.Context, req *logical.Request, unauth bool
 		// forward this request properly to the active node.
 		if retErr.ErrorOrNil() != nil && checkErrControlGroupTokenNeedsCreated(retErr) &&
 			c.perfStandby && len(req.ClientToken) != 0 {
-			switch req.ClientTokenSource {
-			case logical.ClientTokenFromVaultHeader:
-				req.Headers[consts.AuthHeaderName] = []string{req.ClientToken}
-			case logical.ClientTokenFromAuthzHeader:
-				req.Headers["Authorization"] = append(req.Headers["Authorization"], fmt.Sprintf("Bearer %s", req.ClientToken))
-			}
+			restoreClientTokenHeader(req)
 			// We also return the appropriate error so that the caller can forward the
 			// request to the active node
 			return auth, te, logical.ErrPerfStandbyPleaseForward
@@ -607,6 +602,9 @@ func (c *Core) handleCancelableRequest(ctx context.Context, req *logical.Request
 			req.Operation == logical.PatchOperation) {
 		return logical.ErrorResponse("cannot write to a path ending in '/'"), nil
 	}
+	if err := c.checkReadReplicaRequest(ctx, req); err != nil {
+		return nil, err
+	}
 	waitGroup, err := waitForReplicationState(ctx, c, req)
 	if err != nil {
 		return nil, err
@@ -1270,6 +1268,13 @@ func (c *Core) handleRequest(ctx context.Context, req *logical.Request) (retResp
 			}
 
 			leaseID, err := registerFunc(ctx, req, resp, "")
+			if err == logical.ErrPerfStandbyPleaseForward {
+				// The authorization headers were deleted after the token
+				// check, restore them so that the forwarded request carries
+				// the client token
+				restoreClientTokenHeader(req)
+				return nil, auth, err
+			}
 			if err != nil {
 				c.logger.Error("failed to register lease", "request_path", req.Path, "error", err)
 				retErr = multierror.Append(retErr, ErrInternalError)
@@ -2696,3 +2701,18 @@ func (c *Core) checkSSCTokenInternal(ctx context.Context, token string, isPerfSt
 	// status code.
 	return "", logical.ErrMissingRequiredState
 }
+
+// restoreClientTokenHeader adds the client token back to the headers of the
+// request it was read from, so that the request can be forwarded to the
+// active node.
+func restoreClientTokenHeader(req *logical.Request) {
+	if len(req.ClientToken) == 0 || req.Headers == nil {
+		return
+	}
+	switch req.ClientTokenSource {
+	case logical.ClientTokenFromVaultHeader:
+		req.Headers[consts.AuthHeaderName] = []string{req.ClientToken}
+	case logical.ClientTokenFromAuthzHeader:
+		req.Headers["Authorization"] = append(req.Headers["Authorization"], fmt.Sprintf("Bearer %s", req.ClientToken))
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/sdk/logical"
 )
@@ -27,6 +28,17 @@ func checkErrControlGroupTokenNeedsCreated(err error) bool {
 }
 
 func shouldForward(c *Core, resp *logical.Response, err error) bool {
+	// Read replicas hand requests that turned out to need a write back to
+	// the HTTP layer, which forwards them to the active node.
+	if !c.perfStandby {
+		return false
+	}
+	if err != nil && errwrap.Contains(err, logical.ErrReadOnly.Error()) {
+		return true
+	}
+	if resp != nil && resp.IsError() && errwrap.Contains(resp.Error(), logical.ErrReadOnly.Error()) {
+		return true
+	}
 	return false
 }
 
@@ -43,11 +55,24 @@ func couldForward(c *Core) bool {
 }
 
 func forward(ctx context.Context, c *Core, req *logical.Request) (*logical.Response, error) {
-	panic("forward called in OSS Vault")
+	return nil, logical.ErrPerfStandbyPleaseForward
 }
 
 func getLeaseRegisterFunc(c *Core) (func(context.Context, *logical.Request, *logical.Response, string) (string, error), error) {
-	return c.expiration.Register, nil
+	if !c.perfStandby {
+		return c.expiration.Register, nil
+	}
+
+	// Read replicas can't persist leases. The expiration manager revokes the
+	// secret it failed to register, so the request can be safely retried on
+	// the active node.
+	return func(ctx context.Context, req *logical.Request, resp *logical.Response, loginRole string) (string, error) {
+		leaseID, err := c.expiration.Register(ctx, req, resp, loginRole)
+		if err != nil && errwrap.Contains(err, logical.ErrReadOnly.Error()) {
+			return "", logical.ErrPerfStandbyPleaseForward
+		}
+		return leaseID, err
+	}, nil
 }
 
 func getAuthRegisterFunc(c *Core) (RegisterAuthFunc, error) {
@@ -0,0 +1,352 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	"github.com/hashicorp/vault/sdk/physical"
+)
+
+const (
+	// sealPartiallyWrappedPathsPath is the path of the list of the values
+	// that are not yet wrapped by all the enabled seals. It is stored in
+	// plaintext so that it can be checked before unsealing.
+	sealPartiallyWrappedPathsPath = "core/seal-partially-wrapped-paths"
+
+	// sealRewrapRetryInterval is how long to wait before rewrapping the
+	// values again when some of them could not be wrapped by all the
+	// enabled seals, usually because a seal is unhealthy.
+	sealRewrapRetryInterval = time.Minute
+)
+
+// SealRewrapStatus is the status of the rewrapping of the values wrapped by
+// the seal.
+type SealRewrapStatus struct {
+	IsRunning bool      `json:"is_running"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Processed int       `json:"processed"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+	Skipped   int       `json:"skipped"`
+	Error     string    `json:"error"`
+}
+
+// sealRewrapper rewraps the values wrapped by the seal with all the enabled
+// seals on the active node, after the seal configuration has changed or when
+// a previous rewrap could not reach all the seals.
+type sealRewrapper struct {
+	core   *Core
+	logger hclog.Logger
+
+	l       sync.Mutex
+	status  SealRewrapStatus
+	cancel  context.CancelFunc
+	doneCh  chan struct{}
+	retryCh chan struct{}
+}
+
+func newSealRewrapper(c *Core, logger hclog.Logger) *sealRewrapper {
+	return &sealRewrapper{
+		core:   c,
+		logger: logger,
+	}
+}
+
+// Status returns the status of the current or last rewrap.
+func (r *sealRewrapper) Status() SealRewrapStatus {
+	r.l.Lock()
+	defer r.l.Unlock()
+	return r.status
+}
+
+// Start rewraps the values in the background. The rewrap is retried until all
+// the values are wrapped by all the enabled seals. If a rewrap is already
+// running, it is retried right away instead and false is returned.
+func (r *sealRewrapper) Start(ctx context.Context) bool {
+	r.l.Lock()
+	if r.status.IsRunning {
+		select {
+		case r.retryCh <- struct{}{}:
+		default:
+		}
+		r.l.Unlock()
+		return false
+	}
+	r.l.Unlock()
+
+	// Wait for the previous rewrap to return
+	r.Stop()
+
+	r.l.Lock()
+	defer r.l.Unlock()
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.doneCh = make(chan struct{})
+	r.retryCh = make(chan struct{}, 1)
+	r.status = SealRewrapStatus{
+		IsRunning: true,
+		StartTime: time.Now().UTC(),
+	}
+	go r.run(ctx, r.doneCh, r.retryCh)
+	return true
+}
+
+// Stop cancels a running rewrap and waits for it to return.
+func (r *sealRewrapper) Stop() {
+	r.l.Lock()
+	cancel, doneCh := r.cancel, r.doneCh
+	r.cancel, r.doneCh = nil, nil
+	r.l.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-doneCh
+}
+
+func (r *sealRewrapper) run(ctx context.Context, doneCh, retryCh chan struct{}) {
+	defer close(doneCh)
+
+	for {
+		err := r.rewrap(ctx)
+		if err == nil {
+			r.updateStatus(func(s *SealRewrapStatus) {
+				s.IsRunning = false
+				s.EndTime = time.Now().UTC()
+				s.Error = ""
+			})
+			r.logger.Info("seal rewrap complete")
+			return
+		}
+		if ctx.Err() != nil {
+			r.updateStatus(func(s *SealRewrapStatus) {
+				s.IsRunning = false
+				s.EndTime = time.Now().UTC()
+			})
+			return
+		}
+
+		r.logger.Warn("seal rewrap incomplete, retrying", "error", err, "retry_interval", sealRewrapRetryInterval)
+		r.updateStatus(func(s *SealRewrapStatus) {
+			s.Error = err.Error()
+		})
+		select {
+		case <-ctx.Done():
+			r.updateStatus(func(s *SealRewrapStatus) {
+				s.IsRunning = false
+				s.EndTime = time.Now().UTC()
+			})
+			return
+		case <-retryCh:
+		case <-time.After(sealRewrapRetryInterval):
+		}
+	}
+}
+
+func (r *sealRewrapper) updateStatus(f func(s *SealRewrapStatus)) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	f(&r.status)
+}
+
+// rewrap wraps the stored keys and the recovery key with all the enabled
+// seals. A value is only written back once all the enabled seals wrapped it,
+// so that the ciphertext of a seal that is temporarily unavailable is not
+// lost. The values that could not be rewrapped are recorded as partially
+// wrapped, and the seal generation is only marked as rewrapped once there are
+// none left.
+func (r *sealRewrapper) rewrap(ctx context.Context) error {
+	c := r.core
+
+	// Rekeying writes the same values
+	c.rekeyLock.Lock()
+	defer c.rekeyLock.Unlock()
+
+	d, ok := c.seal.(*autoSeal)
+	if !ok {
+		return nil
+	}
+
+	type rewrapEntry struct {
+		path   string
+		exists func() (bool, error)
+		rewrap func() error
+	}
+	entries := []rewrapEntry{
+		{
+			path: StoredBarrierKeysPath,
+			exists: func() (bool, error) {
+				pe, err := c.physical.Get(ctx, StoredBarrierKeysPath)
+				return pe != nil, err
+			},
+			rewrap: func() error {
+				keys, err := d.GetStoredKeys(ctx)
+				if err != nil {
+					return err
+				}
+				return d.SetStoredKeys(ctx, keys)
+			},
+		},
+	}
+	if d.RecoveryKeySupported() {
+		entries = append(entries, rewrapEntry{
+			path: recoveryKeyPath,
+			exists: func() (bool, error) {
+				pe, err := c.physical.Get(ctx, recoveryKeyPath)
+				return pe != nil, err
+			},
+			rewrap: func() error {
+				key, err := d.RecoveryKey(ctx)
+				if err != nil {
+					return err
+				}
+				return d.SetRecoveryKey(ctx, key)
+			},
+		})
+	}
+
+	// The entries counts are those of the last pass
+	r.updateStatus(func(s *SealRewrapStatus) {
+		s.Processed, s.Succeeded, s.Failed, s.Skipped = 0, 0, 0, 0
+	})
+
+	var partial []string
+	var errs []error
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		exists, err := entry.exists()
+		if err != nil {
+			return err
+		}
+		r.updateStatus(func(s *SealRewrapStatus) {
+			s.Processed++
+		})
+		if !exists {
+			r.updateStatus(func(s *SealRewrapStatus) {
+				s.Skipped++
+			})
+			continue
+		}
+
+		if err := entry.rewrap(); err != nil {
+			r.logger.Warn("failed to rewrap value", "path", entry.path, "error", err)
+			partial = append(partial, entry.path)
+			errs = append(errs, fmt.Errorf("%s: %w", entry.path, err))
+			r.updateStatus(func(s *SealRewrapStatus) {
+				s.Failed++
+			})
+			continue
+		}
+		r.logger.Debug("rewrapped value", "path", entry.path)
+		r.updateStatus(func(s *SealRewrapStatus) {
+			s.Succeeded++
+		})
+	}
+
+	if err := setPartiallySealWrappedPaths(ctx, c.physical, partial); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	sealGenInfo := d.GetAccess().GetSealGenerationInfo()
+	sealGenInfo.SetRewrapped(true)
+	if err := c.SetPhysicalSealGenInfo(ctx, sealGenInfo); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetPartiallySealWrappedPaths returns the paths of the values that are not
+// wrapped by all the enabled seals.
+func GetPartiallySealWrappedPaths(ctx context.Context, backend physical.Backend) ([]string, error) {
+	pe, err := backend.Get(ctx, sealPartiallyWrappedPathsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partially seal wrapped paths: %w", err)
+	}
+	if pe == nil {
+		return nil, nil
+	}
+
+	var paths []string
+	if err := jsonutil.DecodeJSON(pe.Value, &paths); err != nil {
+		return nil, fmt.Errorf("failed to decode partially seal wrapped paths: %w", err)
+	}
+	return paths, nil
+}
+
+func setPartiallySealWrappedPaths(ctx context.Context, backend physical.Backend, paths []string) error {
+	if len(paths) == 0 {
+		return backend.Delete(ctx, sealPartiallyWrappedPathsPath)
+	}
+
+	buf, err := json.Marshal(paths)
+	if err != nil {
+		return err
+	}
+	return backend.Put(ctx, &physical.Entry{
+		Key:   sealPartiallyWrappedPathsPath,
+		Value: buf,
+	})
+}
+
+// startPartialSealRewrapping starts a rewrap on the active node if the seal
+// configuration changed since the last complete rewrap, or if some values are
+// only wrapped by some of the seals.
+func startPartialSealRewrapping(c *Core) {
+	if !c.IsMultisealEnabled() {
+		return
+	}
+	if _, ok := c.seal.(*autoSeal); !ok {
+		return
+	}
+
+	sealGenInfo := c.seal.GetAccess().GetSealGenerationInfo()
+	paths, err := GetPartiallySealWrappedPaths(c.activeContext, c.physical)
+	if err != nil {
+		c.logger.Warn("cannot check for partially seal wrapped values", "error", err)
+	}
+	if sealGenInfo.IsRewrapped() && len(paths) == 0 && err == nil {
+		return
+	}
+
+	c.logger.Info("starting seal rewrap", "generation", sealGenInfo.Generation, "partially_wrapped_paths", len(paths))
+	c.sealRewrap.Start(c.activeContext)
+}
+
+func stopPartialSealRewrapping(c *Core) {
+	c.sealRewrap.Stop()
+}
+
+// reloadSealsEnt finishes setting up the seals loaded from a new
+// configuration, and rewraps the values with them when needed.
+func (c *Core) reloadSealsEnt(secureRandomReader io.Reader, sealAccess Seal, logger hclog.Logger, shouldRewrap bool) error {
+	if !c.IsMultisealEnabled() {
+		return nil
+	}
+	d, ok := sealAccess.(*autoSeal)
+	if !ok || c.Sealed() {
+		return nil
+	}
+
+	d.StartHealthCheck()
+	if shouldRewrap && !c.perfStandby && !c.standby {
+		logger.Info("rewrapping values with the reloaded seals")
+		c.sealRewrap.Start(c.activeContext)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/helper/testhelpers/corehelpers"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault/seal"
+	"github.com/stretchr/testify/require"
+)
+
+// testCoreMultiseal returns an unsealed core with an auto seal made of two
+// seals, along with the wrappers of the seals and the root token.
+func testCoreMultiseal(t *testing.T) (*Core, []*seal.ToggleableWrapper, string) {
+	t.Helper()
+	access, wrappers := seal.NewTestSeal(&seal.TestSealOpts{
+		StoredKeys:   seal.StoredKeysSupportedGeneric,
+		Name:         "multiseal",
+		WrapperCount: 2,
+	})
+	c := TestCoreWithSealAndUI(t, &CoreConfig{
+		Seal:            NewAutoSeal(access),
+		EnableMultiseal: true,
+		BuiltinRegistry: corehelpers.NewMockBuiltinRegistry(),
+	})
+	result, err := c.Initialize(context.Background(), &InitParams{
+		BarrierConfig: &SealConfig{
+			StoredShares:    1,
+			SecretShares:    1,
+			SecretThreshold: 1,
+		},
+		RecoveryConfig: &SealConfig{
+			SecretShares:    1,
+			SecretThreshold: 1,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.UnsealWithStoredKeys(context.Background()))
+	require.False(t, c.Sealed())
+	return c, wrappers, result.RootToken
+}
+
+// testSealWrappedSlots returns the number of seals that wrapped the value
+// stored at the given path.
+func testSealWrappedSlots(t *testing.T, c *Core, path string) int {
+	t.Helper()
+	pe, err := c.physical.Get(context.Background(), path)
+	require.NoError(t, err)
+	require.NotNil(t, pe)
+	swv, err := UnmarshalSealWrappedValue(pe.Value)
+	require.NoError(t, err)
+	return len(swv.GetSlots())
+}
+
+// testSealRewrap starts a rewrap and returns whether one was already running.
+func testSealRewrap(t *testing.T, c *Core, token string) bool {
+	t.Helper()
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/sealwrap/rewrap")
+	req.ClientToken = token
+	resp, err := c.HandleRequest(namespace.RootContext(nil), req)
+	require.NoError(t, err)
+	require.False(t, resp.IsError(), resp)
+	return resp != nil
+}
+
+func TestSealRewrap(t *testing.T) {
+	c, wrappers, token := testCoreMultiseal(t)
+	ctx := context.Background()
+
+	require.Equal(t, 2, testSealWrappedSlots(t, c, StoredBarrierKeysPath))
+	require.Equal(t, 2, testSealWrappedSlots(t, c, recoveryKeyPath))
+
+	// The values are not rewritten while a seal cannot wrap them
+	wrappers[1].SetEncryptError(errors.New("seal unavailable"))
+	require.False(t, testSealRewrap(t, c, token))
+	require.Eventually(t, func() bool {
+		return c.sealRewrap.Status().Failed == 2
+	}, 10*time.Second, 10*time.Millisecond)
+	status := c.sealRewrap.Status()
+	require.True(t, status.IsRunning)
+	require.NotEmpty(t, status.Error)
+
+	paths, err := GetPartiallySealWrappedPaths(ctx, c.physical)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{StoredBarrierKeysPath, recoveryKeyPath}, paths)
+	require.Equal(t, 2, testSealWrappedSlots(t, c, StoredBarrierKeysPath))
+
+	backendStatus, err := c.GetSealBackendStatus(ctx)
+	require.NoError(t, err)
+	require.False(t, backendStatus.FullyWrapped)
+
+	// Once the health check sees the seal is back, retrying the running
+	// rewrap completes it
+	wrappers[1].SetEncryptError(nil)
+	c.seal.GetAccess().GetConfiguredSealWrappersByPriority()[1].SetHealthy(true, time.Now())
+	require.True(t, testSealRewrap(t, c, token))
+	require.Eventually(t, func() bool {
+		return !c.sealRewrap.Status().IsRunning
+	}, 10*time.Second, 10*time.Millisecond)
+	status = c.sealRewrap.Status()
+	require.Empty(t, status.Error)
+	require.Equal(t, 2, status.Succeeded)
+	require.Zero(t, status.Failed)
+
+	paths, err = GetPartiallySealWrappedPaths(ctx, c.physical)
+	require.NoError(t, err)
+	require.Empty(t, paths)
+
+	sealGenInfo, err := PhysicalSealGenInfo(ctx, c.physical)
+	require.NoError(t, err)
+	require.True(t, sealGenInfo.IsRewrapped())
+
+	req := logical.TestRequest(t, logical.ReadOperation, "sys/sealwrap/rewrap")
+	req.ClientToken = token
+	resp, err := c.HandleRequest(namespace.RootContext(nil), req)
+	require.NoError(t, err)
+	require.Equal(t, false, resp.Data["is_running"])
+	require.Equal(t, true, resp.Data["fully_wrapped"])
+	require.Equal(t, 2, resp.Data["entries"].(map[string]interface{})["succeeded"])
+}
+
+func TestSealRewrap_RequiresMultiseal(t *testing.T) {
+	c, _, token := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/sealwrap/rewrap")
+	req.ClientToken = token
+	resp, err := c.HandleRequest(namespace.RootContext(nil), req)
+	require.ErrorIs(t, err, logical.ErrInvalidRequest)
+	require.True(t, resp.IsError())
+}
+
+func TestSealRewrap_UnsealWithHealthySeal(t *testing.T) {
+	c, wrappers, token := testCoreMultiseal(t)
+
+	require.NoError(t, c.Seal(token))
+	require.True(t, c.Sealed())
+
+	// Either seal is enough to unseal
+	wrappers[0].SetError(errors.New("seal unavailable"))
+	require.NoError(t, c.UnsealWithStoredKeys(context.Background()))
+	require.False(t, c.Sealed())
+}
+
+func TestSealRewrap_SealStatus(t *testing.T) {
+	c, wrappers, _ := testCoreMultiseal(t)
+
+	status, err := c.GetSealStatus(context.Background(), true)
+	require.NoError(t, err)
+	require.Equal(t, []SealBackendStatus{
+		{Name: "multiseal-1", Healthy: true},
+		{Name: "multiseal-2", Healthy: true},
+	}, status.Seals)
+
+	wrappers[1].SetError(errors.New("seal unavailable"))
+	c.seal.GetAccess().GetConfiguredSealWrappersByPriority()[1].SetHealthy(false, time.Now())
+	status, err = c.GetSealStatus(context.Background(), true)
+	require.NoError(t, err)
+	require.True(t, status.Seals[0].Healthy)
+	require.False(t, status.Seals[1].Healthy)
+
+	// A single seal does not list the seals
+	c, _, _ = TestCoreUnsealed(t)
+	status, err = c.GetSealStatus(context.Background(), true)
+	require.NoError(t, err)
+	require.Empty(t, status.Seals)
+}
@@ -0,0 +1,462 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/armon/go-radix"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	log "github.com/hashicorp/go-hclog"
+	aeadwrapper "github.com/hashicorp/go-kms-wrapping/wrappers/aead/v2"
+	"github.com/hashicorp/vault/helper/identity"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/helper/storagepacker"
+	"github.com/hashicorp/vault/sdk/helper/compressutil"
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/sdk/physical"
+	"github.com/hashicorp/vault/shamir"
+	vaultseal "github.com/hashicorp/vault/vault/seal"
+)
+
+// Object types of the storage entries of a snapshot.
+const (
+	SnapshotObjectCore           = "core"
+	SnapshotObjectPolicy         = "policy"
+	SnapshotObjectPasswordPolicy = "password-policy"
+	SnapshotObjectLease          = "lease"
+	SnapshotObjectToken          = "token"
+	SnapshotObjectEntities       = "identity-entities"
+	SnapshotObjectGroups         = "identity-groups"
+	SnapshotObjectMount          = "mount"
+	SnapshotObjectAudit          = "audit"
+	SnapshotObjectOther          = "other"
+)
+
+// Changes reported by DiffSnapshotStorage.
+const (
+	SnapshotChangeAdded    = "added"
+	SnapshotChangeRemoved  = "removed"
+	SnapshotChangeModified = "modified"
+)
+
+// SnapshotUnsealOptions holds the material used to decrypt the storage of a
+// snapshot outside of a running cluster.
+type SnapshotUnsealOptions struct {
+	// UnsealKeys are the unseal key shares of a cluster using Shamir.
+	UnsealKeys [][]byte
+
+	// SealAccess gives access to the auto-unseal mechanism of a cluster that
+	// does not use Shamir. Recovery keys cannot decrypt the root key.
+	SealAccess vaultseal.Access
+}
+
+// UnsealSnapshotStorage returns the barrier over the physical storage holding
+// the entries of a snapshot, unsealed with the root key recovered the same way
+// the cluster that took the snapshot recovers it when unsealing. The storage
+// should be a copy of the snapshot entries, such as an in-memory backend.
+func UnsealSnapshotStorage(ctx context.Context, logger log.Logger, storage physical.Backend, opts *SnapshotUnsealOptions) (logical.Storage, error) {
+	if opts == nil {
+		return nil, errors.New("no unseal options provided")
+	}
+
+	pe, err := storage.Get(ctx, barrierSealConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seal configuration: %w", err)
+	}
+	if pe == nil {
+		return nil, errors.New("snapshot has no seal configuration")
+	}
+	var config SealConfig
+	if err := jsonutil.DecodeJSON(pe.Value, &config); err != nil {
+		return nil, fmt.Errorf("failed to decode seal configuration: %w", err)
+	}
+
+	var rootKey []byte
+	switch {
+	case SealConfigTypeShamir.IsSameAs(config.Type):
+		if len(opts.UnsealKeys) < config.SecretThreshold || len(opts.UnsealKeys) == 0 {
+			return nil, fmt.Errorf("%d unseal keys are required, got %d", config.SecretThreshold, len(opts.UnsealKeys))
+		}
+
+		combinedKey := opts.UnsealKeys[0]
+		if config.SecretThreshold > 1 {
+			combinedKey, err = shamir.Combine(opts.UnsealKeys)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute combined key: %w", err)
+			}
+		}
+
+		if config.StoredShares == 0 {
+			// Legacy Shamir seals use the combined key as the root key
+			rootKey = combinedKey
+			break
+		}
+
+		access, err := vaultseal.NewAccessFromWrapper(logger, aeadwrapper.NewShamirWrapper(), SealConfigTypeShamir.String())
+		if err != nil {
+			return nil, err
+		}
+		if err := access.SetShamirSealKey(combinedKey); err != nil {
+			return nil, fmt.Errorf("failed to set up unseal key: %w", err)
+		}
+		rootKey, err = readSnapshotRootKey(ctx, storage, access)
+		if err != nil {
+			return nil, err
+		}
+
+	default:
+		if opts.SealAccess == nil {
+			return nil, fmt.Errorf("snapshot uses a %q seal, its configuration is required to decrypt it", config.Type)
+		}
+		rootKey, err = readSnapshotRootKey(ctx, storage, opts.SealAccess)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	barrier, err := NewAESGCMBarrier(storage)
+	if err != nil {
+		return nil, err
+	}
+	if err := barrier.Unseal(ctx, rootKey); err != nil {
+		return nil, fmt.Errorf("failed to unseal snapshot storage: %w", err)
+	}
+	return barrier, nil
+}
+
+func readSnapshotRootKey(ctx context.Context, storage physical.Backend, access vaultseal.Access) ([]byte, error) {
+	keys, err := readStoredKeys(ctx, storage, access)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt root key: %w", err)
+	}
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("expected exactly one stored key, got %d", len(keys))
+	}
+	return keys[0], nil
+}
+
+// SnapshotKeyInfo maps the storage path of an entry of a snapshot to the
+// object it belongs to.
+type SnapshotKeyInfo struct {
+	Key       string `json:"key"`
+	Namespace string `json:"namespace"`
+	Mount     string `json:"mount,omitempty"`
+	MountType string `json:"mount_type,omitempty"`
+	Type      string `json:"type"`
+	Name      string `json:"name,omitempty"`
+}
+
+// SnapshotIndex resolves the storage paths of a decrypted snapshot using its
+// mount tables.
+type SnapshotIndex struct {
+	mounts map[string]*MountEntry
+	views  *radix.Tree
+}
+
+// NewSnapshotIndex loads the mount tables of the decrypted snapshot storage.
+func NewSnapshotIndex(ctx context.Context, storage logical.Storage) (*SnapshotIndex, error) {
+	index := &SnapshotIndex{
+		mounts: make(map[string]*MountEntry),
+		views:  radix.New(),
+	}
+
+	for _, path := range []string{coreMountConfigPath, coreLocalMountConfigPath, coreAuthConfigPath, coreLocalAuthConfigPath} {
+		entry, err := storage.Get(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %w", path, err)
+		}
+		if entry == nil {
+			continue
+		}
+		table := new(MountTable)
+		if err := jsonutil.DecodeJSON(entry.Value, table); err != nil {
+			return nil, fmt.Errorf("failed to decode %q: %w", path, err)
+		}
+		for _, me := range table.Entries {
+			if me.Table == "" {
+				me.Table = table.Type
+			}
+			if me.NamespaceID == "" {
+				me.NamespaceID = namespace.RootNamespaceID
+			}
+			index.mounts[snapshotMountPath(me)] = me
+			index.views.Insert(me.ViewPath(), me)
+		}
+	}
+
+	return index, nil
+}
+
+// Mounts returns the mount entries keyed by their API path, with auth mounts
+// prefixed with "auth/".
+func (i *SnapshotIndex) Mounts() map[string]*MountEntry {
+	return i.mounts
+}
+
+// Lookup returns what the entry stored at key belongs to.
+func (i *SnapshotIndex) Lookup(key string) *SnapshotKeyInfo {
+	info := &SnapshotKeyInfo{
+		Key:       key,
+		Namespace: namespace.RootNamespaceID,
+		Type:      SnapshotObjectOther,
+	}
+
+	prefix, raw, ok := i.views.LongestPrefix(key)
+	if ok {
+		me := raw.(*MountEntry)
+		info.Namespace = me.NamespaceID
+		info.Mount = snapshotMountPath(me)
+		info.MountType = me.Type
+		info.Type = SnapshotObjectMount
+		key = strings.TrimPrefix(key, prefix)
+	}
+
+	switch {
+	case !ok && strings.HasPrefix(key, "core/"):
+		info.Type = SnapshotObjectCore
+	case !ok && strings.HasPrefix(key, auditBarrierPrefix):
+		info.Type = SnapshotObjectAudit
+	case info.MountType == mountTypeSystem && strings.HasPrefix(key, policyACLSubPath):
+		info.Type = SnapshotObjectPolicy
+		info.Name = strings.TrimPrefix(key, policyACLSubPath)
+	case info.MountType == mountTypeSystem && strings.HasPrefix(key, passwordPolicySubPath):
+		info.Type = SnapshotObjectPasswordPolicy
+		info.Name = strings.TrimPrefix(key, passwordPolicySubPath)
+	case info.MountType == mountTypeSystem && strings.HasPrefix(key, expirationSubPath+leaseViewPrefix):
+		info.Type = SnapshotObjectLease
+		info.Name = strings.TrimPrefix(key, expirationSubPath+leaseViewPrefix)
+	case info.MountType == mountTypeToken:
+		info.Type = SnapshotObjectToken
+	case info.MountType == mountTypeIdentity && strings.HasPrefix(key, storagepacker.StoragePackerBucketsPrefix):
+		info.Type = SnapshotObjectEntities
+	case info.MountType == mountTypeIdentity && strings.HasPrefix(key, groupBucketsPrefix):
+		info.Type = SnapshotObjectGroups
+	}
+
+	return info
+}
+
+func snapshotMountPath(me *MountEntry) string {
+	if me.Table == credentialTableType {
+		return credentialRoutePrefix + me.Path
+	}
+	return me.Path
+}
+
+// SnapshotChange is an object that differs between two snapshots.
+type SnapshotChange struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	ID        string `json:"id,omitempty"`
+	Change    string `json:"change"`
+}
+
+// SnapshotDiff lists the mounts, policies, leases and entities that differ
+// between two snapshots.
+type SnapshotDiff struct {
+	Mounts   []*SnapshotChange `json:"mounts"`
+	Policies []*SnapshotChange `json:"policies"`
+	Leases   []*SnapshotChange `json:"leases"`
+	Entities []*SnapshotChange `json:"entities"`
+}
+
+// DiffSnapshotStorage compares the decrypted storage of two snapshots. A mount
+// is modified if its configuration or any entry in its storage changed.
+func DiffSnapshotStorage(ctx context.Context, from, to logical.Storage) (*SnapshotDiff, error) {
+	fromIndex, err := NewSnapshotIndex(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	toIndex, err := NewSnapshotIndex(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+
+	fromValues, err := readSnapshotValues(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	toValues, err := readSnapshotValues(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SnapshotDiff{
+		Mounts:   []*SnapshotChange{},
+		Policies: []*SnapshotChange{},
+		Leases:   []*SnapshotChange{},
+		Entities: []*SnapshotChange{},
+	}
+
+	// Entries that differ, attributed to the object they belong to in the
+	// snapshot they exist in
+	changedMounts := make(map[string]bool)
+	var changedEntityBuckets []string
+	for _, key := range unionKeys(fromValues, toValues) {
+		fromValue, inFrom := fromValues[key]
+		toValue, inTo := toValues[key]
+		if inFrom && inTo && bytes.Equal(fromValue, toValue) {
+			continue
+		}
+
+		change := SnapshotChangeModified
+		info := toIndex.Lookup(key)
+		switch {
+		case !inFrom:
+			change = SnapshotChangeAdded
+		case !inTo:
+			change = SnapshotChangeRemoved
+			info = fromIndex.Lookup(key)
+		}
+
+		if info.Mount != "" {
+			changedMounts[info.Mount] = true
+		}
+		switch info.Type {
+		case SnapshotObjectPolicy:
+			diff.Policies = append(diff.Policies, &SnapshotChange{Namespace: info.Namespace, Name: info.Name, Change: change})
+		case SnapshotObjectLease:
+			diff.Leases = append(diff.Leases, &SnapshotChange{Namespace: info.Namespace, Name: info.Name, ID: info.Name, Change: change})
+		case SnapshotObjectEntities:
+			changedEntityBuckets = append(changedEntityBuckets, key)
+		}
+	}
+
+	for _, path := range unionKeys(fromIndex.Mounts(), toIndex.Mounts()) {
+		fromMount, inFrom := fromIndex.Mounts()[path]
+		toMount, inTo := toIndex.Mounts()[path]
+		switch {
+		case !inFrom:
+			diff.Mounts = append(diff.Mounts, &SnapshotChange{Namespace: toMount.NamespaceID, Name: path, ID: toMount.UUID, Change: SnapshotChangeAdded})
+		case !inTo:
+			diff.Mounts = append(diff.Mounts, &SnapshotChange{Namespace: fromMount.NamespaceID, Name: path, ID: fromMount.UUID, Change: SnapshotChangeRemoved})
+		default:
+			fromConfig, err := json.Marshal(fromMount)
+			if err != nil {
+				return nil, err
+			}
+			toConfig, err := json.Marshal(toMount)
+			if err != nil {
+				return nil, err
+			}
+			if changedMounts[path] || !bytes.Equal(fromConfig, toConfig) {
+				diff.Mounts = append(diff.Mounts, &SnapshotChange{Namespace: toMount.NamespaceID, Name: path, ID: toMount.UUID, Change: SnapshotChangeModified})
+			}
+		}
+	}
+
+	// Entities are packed in buckets, so the items of the buckets that
+	// changed are compared
+	for _, key := range changedEntityBuckets {
+		fromItems, err := snapshotBucketItems(fromValues[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %q: %w", key, err)
+		}
+		toItems, err := snapshotBucketItems(toValues[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %q: %w", key, err)
+		}
+
+		for _, id := range unionKeys(fromItems, toItems) {
+			fromItem, inFrom := fromItems[id]
+			toItem, inTo := toItems[id]
+			change := SnapshotChangeModified
+			item := toItem
+			switch {
+			case !inFrom:
+				change = SnapshotChangeAdded
+			case !inTo:
+				change = SnapshotChangeRemoved
+				item = fromItem
+			case proto.Equal(fromItem, toItem):
+				continue
+			}
+
+			var entity identity.Entity
+			if err := ptypes.UnmarshalAny(item.Message, &entity); err != nil {
+				return nil, fmt.Errorf("failed to decode entity %q: %w", id, err)
+			}
+			if entity.NamespaceID == "" {
+				entity.NamespaceID = namespace.RootNamespaceID
+			}
+			diff.Entities = append(diff.Entities, &SnapshotChange{Namespace: entity.NamespaceID, Name: entity.Name, ID: id, Change: change})
+		}
+	}
+	sort.Slice(diff.Entities, func(i, j int) bool {
+		return diff.Entities[i].Name < diff.Entities[j].Name
+	})
+
+	return diff, nil
+}
+
+func readSnapshotValues(ctx context.Context, storage logical.Storage) (map[string][]byte, error) {
+	keys, err := logical.CollectKeys(ctx, storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot entries: %w", err)
+	}
+
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		// The mount tables are compared through the index instead
+		if strutil.StrListContains(snapshotCorePrefixes, key[:strings.Index(key, "/")+1]) {
+			continue
+		}
+		entry, err := storage.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %w", key, err)
+		}
+		if entry != nil {
+			values[key] = entry.Value
+		}
+	}
+	return values, nil
+}
+
+func snapshotBucketItems(value []byte) (map[string]*storagepacker.Item, error) {
+	items := make(map[string]*storagepacker.Item)
+	if value == nil {
+		return items, nil
+	}
+
+	uncompressed, notCompressed, err := compressutil.Decompress(value)
+	if err != nil {
+		return nil, err
+	}
+	if notCompressed {
+		uncompressed = value
+	}
+	var bucket storagepacker.Bucket
+	if err := proto.Unmarshal(uncompressed, &bucket); err != nil {
+		return nil, err
+	}
+	for _, item := range bucket.Items {
+		items[item.ID] = item
+	}
+	return items, nil
+}
+
+// unionKeys returns the sorted keys present in either map.
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
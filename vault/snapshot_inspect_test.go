@@ -0,0 +1,150 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/sdk/physical"
+	"github.com/hashicorp/vault/sdk/physical/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+// testCopyPhysical copies the physical storage of the core, as a snapshot of
+// it would.
+func testCopyPhysical(t *testing.T, c *Core) physical.Backend {
+	t.Helper()
+	ctx := context.Background()
+
+	dst, err := inmem.NewInmem(nil, logger)
+	require.NoError(t, err)
+
+	var copyPrefix func(prefix string)
+	copyPrefix = func(prefix string) {
+		keys, err := c.physical.List(ctx, prefix)
+		require.NoError(t, err)
+		for _, key := range keys {
+			if strings.HasSuffix(key, "/") {
+				copyPrefix(prefix + key)
+				continue
+			}
+			entry, err := c.physical.Get(ctx, prefix+key)
+			require.NoError(t, err)
+			require.NoError(t, dst.Put(ctx, entry))
+		}
+	}
+	copyPrefix("")
+	return dst
+}
+
+func testSnapshotRequest(t *testing.T, c *Core, token string, op logical.Operation, path string, data map[string]interface{}) {
+	t.Helper()
+	req := logical.TestRequest(t, op, path)
+	req.ClientToken = token
+	req.Data = data
+	resp, err := c.HandleRequest(namespace.RootContext(nil), req)
+	require.NoError(t, err)
+	require.False(t, resp.IsError(), "%v", resp)
+}
+
+func TestUnsealSnapshotStorage(t *testing.T) {
+	ctx := context.Background()
+	c, keys, token := TestCoreUnsealed(t)
+
+	testSnapshotRequest(t, c, token, logical.UpdateOperation, "sys/policy/foo", map[string]interface{}{
+		"policy": `path "secret/*" { capabilities = ["read"] }`,
+	})
+	snap := testCopyPhysical(t, c)
+
+	// Not enough unseal keys
+	_, err := UnsealSnapshotStorage(ctx, logger, snap, &SnapshotUnsealOptions{UnsealKeys: keys[:1]})
+	require.Error(t, err)
+
+	storage, err := UnsealSnapshotStorage(ctx, logger, snap, &SnapshotUnsealOptions{UnsealKeys: keys})
+	require.NoError(t, err)
+
+	entry, err := storage.Get(ctx, "sys/policy/foo")
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	require.Contains(t, string(entry.Value), "secret/*")
+
+	index, err := NewSnapshotIndex(ctx, storage)
+	require.NoError(t, err)
+
+	info := index.Lookup("sys/policy/foo")
+	require.Equal(t, SnapshotObjectPolicy, info.Type)
+	require.Equal(t, "foo", info.Name)
+	require.Equal(t, "sys/", info.Mount)
+	require.Equal(t, namespace.RootNamespaceID, info.Namespace)
+
+	secret := index.Mounts()["secret/"]
+	require.NotNil(t, secret)
+	info = index.Lookup(secret.ViewPath() + "foo")
+	require.Equal(t, SnapshotObjectMount, info.Type)
+	require.Equal(t, "secret/", info.Mount)
+	require.Equal(t, "kv", info.MountType)
+
+	require.Equal(t, SnapshotObjectCore, index.Lookup(coreMountConfigPath).Type)
+	require.Equal(t, SnapshotObjectToken, index.Lookup("sys/token/id/h123").Type)
+}
+
+func TestDiffSnapshotStorage(t *testing.T) {
+	ctx := context.Background()
+	c, keys, token := TestCoreUnsealed(t)
+
+	testSnapshotRequest(t, c, token, logical.UpdateOperation, "sys/policy/foo", map[string]interface{}{
+		"policy": `path "secret/*" { capabilities = ["read"] }`,
+	})
+	testSnapshotRequest(t, c, token, logical.UpdateOperation, "identity/entity", map[string]interface{}{
+		"name": "alice",
+	})
+	testSnapshotRequest(t, c, token, logical.UpdateOperation, "sys/mounts/unused", map[string]interface{}{
+		"type": "kv",
+	})
+	from, err := UnsealSnapshotStorage(ctx, logger, testCopyPhysical(t, c), &SnapshotUnsealOptions{UnsealKeys: keys})
+	require.NoError(t, err)
+
+	testSnapshotRequest(t, c, token, logical.DeleteOperation, "sys/policy/foo", nil)
+	testSnapshotRequest(t, c, token, logical.UpdateOperation, "sys/policy/bar", map[string]interface{}{
+		"policy": `path "secret/*" { capabilities = ["list"] }`,
+	})
+	testSnapshotRequest(t, c, token, logical.UpdateOperation, "identity/entity", map[string]interface{}{
+		"name": "bob",
+	})
+	testSnapshotRequest(t, c, token, logical.DeleteOperation, "sys/mounts/unused", nil)
+	testSnapshotRequest(t, c, token, logical.UpdateOperation, "secret/foo", map[string]interface{}{
+		"value": "bar",
+	})
+	to, err := UnsealSnapshotStorage(ctx, logger, testCopyPhysical(t, c), &SnapshotUnsealOptions{UnsealKeys: keys})
+	require.NoError(t, err)
+
+	diff, err := DiffSnapshotStorage(ctx, from, to)
+	require.NoError(t, err)
+
+	changes := func(changes []*SnapshotChange) map[string]string {
+		result := make(map[string]string)
+		for _, change := range changes {
+			result[change.Name] = change.Change
+		}
+		return result
+	}
+
+	require.Equal(t, map[string]string{
+		"foo": SnapshotChangeRemoved,
+		"bar": SnapshotChangeAdded,
+	}, changes(diff.Policies))
+	require.Equal(t, map[string]string{
+		"bob": SnapshotChangeAdded,
+	}, changes(diff.Entities))
+
+	mounts := changes(diff.Mounts)
+	require.Equal(t, SnapshotChangeRemoved, mounts["unused/"])
+	require.Equal(t, SnapshotChangeModified, mounts["secret/"])
+	require.NotContains(t, mounts, "cubbyhole/")
+	require.Empty(t, diff.Leases)
+}
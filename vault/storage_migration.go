@@ -0,0 +1,602 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/physical"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	StorageMigrationStateInactive    = "inactive"
+	StorageMigrationStateBackfilling = "backfilling"
+	StorageMigrationStateVerifying   = "verifying"
+	StorageMigrationStateReady       = "ready"
+	StorageMigrationStateCutover     = "cutover"
+	StorageMigrationStateFailed      = "failed"
+	StorageMigrationStateAborted     = "aborted"
+
+	// storageMigrationMaxMismatches is the number of mismatched keys listed in
+	// the status of a migration.
+	storageMigrationMaxMismatches = 10
+)
+
+// storageMigrationSkippedKeys are owned by each backend and are never copied.
+var storageMigrationSkippedKeys = []string{
+	CoreLockPath,
+}
+
+// StorageMigrationStatus reports the progress of an online storage migration.
+type StorageMigrationStatus struct {
+	State                string    `json:"state" mapstructure:"state"`
+	DestinationType      string    `json:"destination_type" mapstructure:"destination_type"`
+	StartTime            time.Time `json:"start_time" mapstructure:"start_time"`
+	CutoverTime          time.Time `json:"cutover_time" mapstructure:"cutover_time"`
+	KeysCopied           uint64    `json:"keys_copied" mapstructure:"keys_copied"`
+	KeysRemoved          uint64    `json:"keys_removed" mapstructure:"keys_removed"`
+	KeysVerified         uint64    `json:"keys_verified" mapstructure:"keys_verified"`
+	KeysMismatched       uint64    `json:"keys_mismatched" mapstructure:"keys_mismatched"`
+	MismatchedKeys       []string  `json:"mismatched_keys" mapstructure:"mismatched_keys"`
+	SecondaryWriteErrors uint64    `json:"secondary_write_errors" mapstructure:"secondary_write_errors"`
+	Error                string    `json:"error" mapstructure:"error"`
+}
+
+func (s *StorageMigrationStatus) active() bool {
+	switch s.State {
+	case StorageMigrationStateBackfilling, StorageMigrationStateVerifying, StorageMigrationStateReady, StorageMigrationStateCutover:
+		return true
+	}
+	return false
+}
+
+// storageMigrator sits between the storage backend of the node and the
+// layers of the core above it. While a migration is running on the active
+// node, every write is applied to the primary backend, then to the secondary
+// one. The source backend is the primary until the cutover, after which the
+// destination backend is read from and the source is kept up to date in case
+// the migration has to be rolled back.
+type storageMigrator struct {
+	logger hclog.Logger
+	source physical.Backend
+
+	// l is held for reading by the storage operations and for writing when
+	// the backends are swapped. It is always taken before statusLock, as the
+	// storage operations update the status when a secondary write fails.
+	l         sync.RWMutex
+	primary   physical.Backend
+	secondary physical.Backend
+
+	// locks serialize the writes to a key with the copy of that key, so that
+	// the background copy never overwrites a newer value.
+	locks []*locksutil.LockEntry
+
+	// failed stops the writes to the destination once it could not apply one
+	failed atomic.Bool
+
+	statusLock  sync.RWMutex
+	status      *StorageMigrationStatus
+	destination physical.Backend
+	cancel      context.CancelFunc
+	doneCh      chan struct{}
+}
+
+var (
+	_ physical.Backend       = (*storageMigrator)(nil)
+	_ physical.Transactional = (*transactionalStorageMigrator)(nil)
+)
+
+type transactionalStorageMigrator struct {
+	*storageMigrator
+}
+
+// newStorageMigrator wraps the storage backend of the node, the returned
+// backend is transactional if the source backend is.
+func newStorageMigrator(source physical.Backend, logger hclog.Logger) (*storageMigrator, physical.Backend) {
+	m := &storageMigrator{
+		logger:  logger,
+		source:  source,
+		primary: source,
+		locks:   locksutil.CreateLocks(),
+		status:  &StorageMigrationStatus{State: StorageMigrationStateInactive},
+	}
+	if _, ok := source.(physical.Transactional); ok {
+		return m, &transactionalStorageMigrator{m}
+	}
+	return m, m
+}
+
+func (m *storageMigrator) Get(ctx context.Context, key string) (*physical.Entry, error) {
+	m.l.RLock()
+	defer m.l.RUnlock()
+	return m.primary.Get(ctx, key)
+}
+
+func (m *storageMigrator) List(ctx context.Context, prefix string) ([]string, error) {
+	m.l.RLock()
+	defer m.l.RUnlock()
+	return m.primary.List(ctx, prefix)
+}
+
+func (m *storageMigrator) Put(ctx context.Context, entry *physical.Entry) error {
+	m.l.RLock()
+	defer m.l.RUnlock()
+	if m.secondary == nil {
+		return m.primary.Put(ctx, entry)
+	}
+
+	lock := locksutil.LockForKey(m.locks, entry.Key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := m.primary.Put(ctx, entry); err != nil {
+		return err
+	}
+	return m.writeSecondary(func() error {
+		return m.secondary.Put(ctx, entry)
+	})
+}
+
+func (m *storageMigrator) Delete(ctx context.Context, key string) error {
+	m.l.RLock()
+	defer m.l.RUnlock()
+	if m.secondary == nil {
+		return m.primary.Delete(ctx, key)
+	}
+
+	lock := locksutil.LockForKey(m.locks, key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := m.primary.Delete(ctx, key); err != nil {
+		return err
+	}
+	return m.writeSecondary(func() error {
+		return m.secondary.Delete(ctx, key)
+	})
+}
+
+func (m *transactionalStorageMigrator) Transaction(ctx context.Context, txns []*physical.TxnEntry) error {
+	m.l.RLock()
+	defer m.l.RUnlock()
+	if m.secondary == nil {
+		return m.primary.(physical.Transactional).Transaction(ctx, txns)
+	}
+
+	keys := make([]string, 0, len(txns))
+	for _, txn := range txns {
+		keys = append(keys, txn.Entry.Key)
+	}
+	for _, lock := range locksutil.LocksForKeys(m.locks, keys) {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	if err := m.primary.(physical.Transactional).Transaction(ctx, txns); err != nil {
+		return err
+	}
+	return m.writeSecondary(func() error {
+		return m.secondary.(physical.Transactional).Transaction(ctx, txns)
+	})
+}
+
+// TransactionLimits implements physical.TransactionalLimits
+func (m *transactionalStorageMigrator) TransactionLimits() (int, int) {
+	m.l.RLock()
+	defer m.l.RUnlock()
+	if tl, ok := m.primary.(physical.TransactionalLimits); ok {
+		return tl.TransactionLimits()
+	}
+	return 0, 0
+}
+
+// writeSecondary applies a write to the secondary backend. Before the cutover
+// the migration fails on the first error, as the destination can no longer be
+// trusted, and the write succeeds. After it, the write fails, as the source
+// would otherwise silently miss it if the migration is rolled back.
+func (m *storageMigrator) writeSecondary(write func() error) error {
+	if m.failed.Load() {
+		return nil
+	}
+	err := write()
+	if err == nil {
+		return nil
+	}
+
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	m.status.SecondaryWriteErrors++
+	if m.status.State == StorageMigrationStateCutover {
+		m.logger.Error("failed to write to the source storage after the cutover", "error", err)
+		return fmt.Errorf("failed to write to the source storage after the storage migration cutover: %w", err)
+	}
+	m.logger.Error("failed to write to the destination storage, stopping the migration", "error", err)
+	m.failed.Store(true)
+	m.status.State = StorageMigrationStateFailed
+	m.status.Error = fmt.Sprintf("failed to write to the destination storage: %s", err)
+	return nil
+}
+
+// Status returns a copy of the status of the current or last migration.
+func (m *storageMigrator) Status() *StorageMigrationStatus {
+	m.statusLock.RLock()
+	defer m.statusLock.RUnlock()
+	status := *m.status
+	status.MismatchedKeys = append([]string(nil), m.status.MismatchedKeys...)
+	return &status
+}
+
+// Start begins to write to the destination backend, then copies every key of
+// the source backend in the background and verifies the result.
+func (m *storageMigrator) Start(destination physical.Backend, destinationType string, maxParallel int) error {
+	if _, ok := m.source.(physical.Transactional); ok {
+		if _, ok := destination.(physical.Transactional); !ok {
+			return errors.New("the destination storage must support transactions like the source storage")
+		}
+	}
+
+	if m.Status().active() {
+		return errors.New("a storage migration is already in progress")
+	}
+	// A failed migration may still be running
+	m.stopRun()
+
+	m.l.Lock()
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	if m.status.active() {
+		m.l.Unlock()
+		return errors.New("a storage migration is already in progress")
+	}
+
+	// The destination of a failed migration is only closed once no write can
+	// reach it anymore, and the writes only resume once they go to the new one.
+	previous := m.destination
+	m.primary, m.secondary = m.source, destination
+	m.failed.Store(false)
+	m.l.Unlock()
+	m.closeDestination(previous)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.destination = destination
+	m.cancel = cancel
+	m.doneCh = make(chan struct{})
+	m.status = &StorageMigrationStatus{
+		State:           StorageMigrationStateBackfilling,
+		DestinationType: destinationType,
+		StartTime:       time.Now().UTC(),
+	}
+
+	m.logger.Info("starting storage migration", "destination_type", destinationType)
+	go m.run(ctx, destination, maxParallel, m.doneCh)
+	return nil
+}
+
+// Cutover makes the destination backend the primary one once it has been
+// verified.
+func (m *storageMigrator) Cutover() error {
+	m.l.Lock()
+	defer m.l.Unlock()
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	if m.status.State != StorageMigrationStateReady {
+		return fmt.Errorf("storage migration is %s, not %s", m.status.State, StorageMigrationStateReady)
+	}
+
+	m.primary, m.secondary = m.destination, m.source
+
+	m.status.State = StorageMigrationStateCutover
+	m.status.CutoverTime = time.Now().UTC()
+	m.logger.Info("storage migration cut over to the destination storage")
+	return nil
+}
+
+// Abort stops the migration and the writes to the destination backend. It is
+// refused after the cutover unless force is set, which is the case when the
+// node steps down as the next active node only knows of the source backend.
+func (m *storageMigrator) Abort(reason string, force bool) error {
+	m.statusLock.Lock()
+	if m.status.State == StorageMigrationStateCutover && !force {
+		m.statusLock.Unlock()
+		return errors.New("storage migration has already been cut over")
+	}
+	m.statusLock.Unlock()
+
+	m.stopRun()
+
+	m.l.Lock()
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	m.primary, m.secondary = m.source, nil
+	m.l.Unlock()
+
+	if m.status.State == StorageMigrationStateCutover && m.status.SecondaryWriteErrors > 0 {
+		// The writes that failed on the source were reported as failed, but
+		// were applied to the destination and may have been read since.
+		m.logger.Error("storage migration rolled back to the source storage, which missed writes after the cutover", "reason", reason, "missed_writes", m.status.SecondaryWriteErrors)
+		reason = fmt.Sprintf("%s; the source storage missed %d failed writes after the cutover", reason, m.status.SecondaryWriteErrors)
+	}
+	if m.status.active() {
+		m.logger.Warn("storage migration aborted", "reason", reason)
+		m.status.State = StorageMigrationStateAborted
+		m.status.Error = reason
+	}
+	m.closeDestination(m.destination)
+	m.destination = nil
+	return nil
+}
+
+// stopRun cancels the background copy and verification and waits for them to
+// return.
+func (m *storageMigrator) stopRun() {
+	m.statusLock.Lock()
+	cancel, doneCh := m.cancel, m.doneCh
+	m.cancel, m.doneCh = nil, nil
+	m.statusLock.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-doneCh
+	}
+}
+
+// closeDestination releases the destination backend of a migration, which
+// must no longer be written to.
+func (m *storageMigrator) closeDestination(destination physical.Backend) {
+	if destination == nil {
+		return
+	}
+	if raftBackend, ok := destination.(*raft.RaftBackend); ok {
+		if err := raftBackend.TeardownCluster(nil); err != nil {
+			m.logger.Warn("failed to stop the destination raft cluster", "error", err)
+		}
+		if err := raftBackend.Close(); err != nil {
+			m.logger.Warn("failed to close the destination raft storage", "error", err)
+		}
+	}
+}
+
+func (m *storageMigrator) run(ctx context.Context, destination physical.Backend, maxParallel int, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	err := m.backfill(ctx, destination, maxParallel)
+	if err == nil {
+		m.setState(StorageMigrationStateVerifying)
+		err = m.verify(ctx, destination, maxParallel)
+	}
+
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	switch {
+	case m.status.State == StorageMigrationStateFailed || ctx.Err() != nil:
+		// Failed on a write or aborted
+		return
+	case err != nil:
+		m.status.Error = err.Error()
+	case m.status.KeysMismatched > 0:
+		m.status.Error = fmt.Sprintf("%d keys differ between the source and destination storage", m.status.KeysMismatched)
+	default:
+		m.status.State = StorageMigrationStateReady
+		m.logger.Info("storage migration verified, ready for cutover", "keys", m.status.KeysVerified)
+		return
+	}
+
+	m.logger.Error("storage migration failed", "error", m.status.Error)
+	m.status.State = StorageMigrationStateFailed
+	m.failed.Store(true)
+}
+
+// backfill copies the keys of the source backend to the destination and
+// removes the keys of the destination that are not in the source, left over
+// from a previous attempt.
+func (m *storageMigrator) backfill(ctx context.Context, destination physical.Backend, maxParallel int) error {
+	err := storageMigrationScan(ctx, m.source, maxParallel, func(ctx context.Context, key string) error {
+		return m.withKeyLock(key, func() error {
+			entry, err := m.source.Get(ctx, key)
+			if err != nil {
+				return fmt.Errorf("error reading %q from the source storage: %w", key, err)
+			}
+			if entry == nil {
+				return nil
+			}
+			if err := destination.Put(ctx, entry); err != nil {
+				return fmt.Errorf("error writing %q to the destination storage: %w", key, err)
+			}
+			m.updateStatus(func(s *StorageMigrationStatus) { s.KeysCopied++ })
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return storageMigrationScan(ctx, destination, maxParallel, func(ctx context.Context, key string) error {
+		return m.withKeyLock(key, func() error {
+			entry, err := m.source.Get(ctx, key)
+			if err != nil || entry != nil {
+				return err
+			}
+			if err := destination.Delete(ctx, key); err != nil {
+				return fmt.Errorf("error removing %q from the destination storage: %w", key, err)
+			}
+			m.updateStatus(func(s *StorageMigrationStatus) { s.KeysRemoved++ })
+			return nil
+		})
+	})
+}
+
+// verify compares the checksums of every key in both backends.
+func (m *storageMigrator) verify(ctx context.Context, destination physical.Backend, maxParallel int) error {
+	compare := func(ctx context.Context, key string) error {
+		return m.withKeyLock(key, func() error {
+			src, err := m.source.Get(ctx, key)
+			if err != nil {
+				return fmt.Errorf("error reading %q from the source storage: %w", key, err)
+			}
+			dst, err := destination.Get(ctx, key)
+			if err != nil {
+				return fmt.Errorf("error reading %q from the destination storage: %w", key, err)
+			}
+
+			m.updateStatus(func(s *StorageMigrationStatus) {
+				s.KeysVerified++
+				if storageMigrationChecksum(src) == storageMigrationChecksum(dst) {
+					return
+				}
+				s.KeysMismatched++
+				if len(s.MismatchedKeys) < storageMigrationMaxMismatches {
+					s.MismatchedKeys = append(s.MismatchedKeys, key)
+				}
+			})
+			return nil
+		})
+	}
+
+	if err := storageMigrationScan(ctx, m.source, maxParallel, compare); err != nil {
+		return err
+	}
+
+	// Only look for the keys missing from the source the second time
+	return storageMigrationScan(ctx, destination, maxParallel, func(ctx context.Context, key string) error {
+		entry, err := m.source.Get(ctx, key)
+		if err != nil || entry != nil {
+			return err
+		}
+		return compare(ctx, key)
+	})
+}
+
+func (m *storageMigrator) withKeyLock(key string, f func() error) error {
+	if m.failed.Load() {
+		return errors.New("storage migration failed")
+	}
+	lock := locksutil.LockForKey(m.locks, key)
+	lock.Lock()
+	defer lock.Unlock()
+	return f()
+}
+
+func (m *storageMigrator) setState(state string) {
+	m.updateStatus(func(s *StorageMigrationStatus) {
+		if s.State != StorageMigrationStateFailed {
+			s.State = state
+		}
+	})
+}
+
+func (m *storageMigrator) updateStatus(f func(s *StorageMigrationStatus)) {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	f(m.status)
+}
+
+func storageMigrationChecksum(entry *physical.Entry) [sha256.Size]byte {
+	if entry == nil {
+		return [sha256.Size]byte{}
+	}
+	return sha256.Sum256(append([]byte(entry.Key+"\x00"), entry.Value...))
+}
+
+// storageMigrationScan invokes cb with every key of the backend, except the
+// ones owned by the backend itself.
+func storageMigrationScan(ctx context.Context, backend physical.Backend, maxParallel int, cb func(ctx context.Context, key string) error) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(maxParallel)
+
+	prefixes := []string{""}
+	for len(prefixes) > 0 {
+		prefix := prefixes[len(prefixes)-1]
+		prefixes = prefixes[:len(prefixes)-1]
+
+		if err := ctx.Err(); err != nil {
+			eg.Wait()
+			return err
+		}
+
+		keys, err := backend.List(ctx, prefix)
+		if err != nil {
+			eg.Wait()
+			return fmt.Errorf("failed to list %q: %w", prefix, err)
+		}
+		for _, key := range keys {
+			key := prefix + key
+			switch {
+			case strings.HasSuffix(key, "/"):
+				prefixes = append(prefixes, key)
+			case !strutil.StrListContains(storageMigrationSkippedKeys, key):
+				eg.Go(func() error {
+					return cb(ctx, key)
+				})
+			}
+		}
+	}
+	return eg.Wait()
+}
+
+// newStorageMigrationDestination creates the destination backend of a
+// migration from the storage backends the server was built with. A raft
+// destination is bootstrapped as a single node cluster using the cluster
+// address of this node, as the offline migration does.
+func (c *Core) newStorageMigrationDestination(ctx context.Context, storageType string, conf map[string]string) (physical.Backend, error) {
+	factory, ok := c.physicalBackends[storageType]
+	if !ok {
+		return nil, fmt.Errorf("unknown storage type %q", storageType)
+	}
+
+	backend, err := factory(conf, c.baseLogger.Named("storage-migration."+storageType))
+	if err != nil {
+		return nil, fmt.Errorf("error initializing storage of type %s: %w", storageType, err)
+	}
+
+	raftBackend, ok := backend.(*raft.RaftBackend)
+	if !ok {
+		return backend, nil
+	}
+
+	clusterAddr, err := url.Parse(c.ClusterAddr())
+	if err != nil || clusterAddr.Host == "" {
+		raftBackend.Close()
+		return nil, errors.New("a cluster address is required to migrate to raft storage")
+	}
+	if err := raftBackend.Bootstrap([]raft.Peer{
+		{
+			ID:      raftBackend.NodeID(),
+			Address: clusterAddr.Host,
+		},
+	}); err != nil {
+		raftBackend.Close()
+		return nil, fmt.Errorf("could not bootstrap raft storage: %w", err)
+	}
+	if err := raftBackend.SetupCluster(ctx, raft.SetupOpts{
+		StartAsLeader: true,
+	}); err != nil {
+		raftBackend.Close()
+		return nil, fmt.Errorf("could not start raft storage: %w", err)
+	}
+	return raftBackend, nil
+}
+
+// stopStorageMigration aborts a running migration when the node stops being
+// the active node, the next active node does not write to the destination.
+func (c *Core) stopStorageMigration() {
+	if c.storageMigration == nil || !c.storageMigration.Status().active() {
+		return
+	}
+	if err := c.storageMigration.Abort("active node stepped down", true); err != nil {
+		c.logger.Error("failed to stop the storage migration", "error", err)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/sdk/physical"
+	"github.com/hashicorp/vault/sdk/physical/inmem"
+	"github.com/stretchr/testify/require"
+)
+
+// testStorageMigrationCore returns an unsealed core that can migrate to an
+// inmem backend, and the destination backend once it has been created.
+func testStorageMigrationCore(t *testing.T) (*Core, string, func() physical.Backend) {
+	t.Helper()
+	c, _, token := TestCoreUnsealed(t)
+
+	var destination physical.Backend
+	c.physicalBackends = map[string]physical.Factory{
+		"inmem": func(conf map[string]string, logger log.Logger) (physical.Backend, error) {
+			var err error
+			destination, err = inmem.NewInmem(conf, logger)
+			return destination, err
+		},
+	}
+	return c, token, func() physical.Backend { return destination }
+}
+
+func testStorageMigrationRequest(t *testing.T, c *Core, token string, op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+	t.Helper()
+	req := logical.TestRequest(t, op, path)
+	req.ClientToken = token
+	req.Data = data
+	resp, err := c.HandleRequest(namespace.RootContext(nil), req)
+	if err != nil && resp == nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func testStorageMigrationWaitState(t *testing.T, c *Core, state string) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		return c.storageMigration.Status().State == state
+	}, 10*time.Second, 10*time.Millisecond, "status: %#v", c.storageMigration.Status())
+}
+
+// testStorageMigrationCompare checks that both backends hold the same keys
+func testStorageMigrationCompare(t *testing.T, a, b physical.Backend) {
+	t.Helper()
+	ctx := context.Background()
+	for _, pair := range [][2]physical.Backend{{a, b}, {b, a}} {
+		err := storageMigrationScan(ctx, pair[0], 1, func(ctx context.Context, key string) error {
+			want, err := pair[0].Get(ctx, key)
+			require.NoError(t, err)
+			got, err := pair[1].Get(ctx, key)
+			require.NoError(t, err)
+			require.NotNil(t, got, key)
+			require.Equal(t, want.Value, got.Value, key)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+}
+
+func TestStorageMigration(t *testing.T) {
+	c, token, destination := testStorageMigrationCore(t)
+
+	resp := testStorageMigrationRequest(t, c, token, logical.ReadOperation, "sys/storage/migration", nil)
+	require.Equal(t, StorageMigrationStateInactive, resp.Data["state"])
+
+	testStorageMigrationRequest(t, c, token, logical.UpdateOperation, "secret/foo", map[string]interface{}{"value": "foo"})
+
+	resp = testStorageMigrationRequest(t, c, token, logical.UpdateOperation, "sys/storage/migration/cutover", nil)
+	require.True(t, resp.IsError())
+
+	resp = testStorageMigrationRequest(t, c, token, logical.UpdateOperation, "sys/storage/migration", map[string]interface{}{
+		"destination_type": "consul",
+	})
+	require.True(t, resp.IsError())
+
+	resp = testStorageMigrationRequest(t, c, token, logical.UpdateOperation, "sys/storage/migration", map[string]interface{}{
+		"destination_type": "inmem",
+	})
+	require.False(t, resp.IsError(), "%v", resp)
+	testStorageMigrationWaitState(t, c, StorageMigrationStateReady)
+
+	status := c.storageMigration.Status()
+	require.NotZero(t, status.KeysCopied)
+	require.Equal(t, status.KeysCopied, status.KeysVerified)
+	require.Zero(t, status.KeysMismatched)
+
+	resp = testStorageMigrationRequest(t, c, token, logical.UpdateOperation, "sys/storage/migration", map[string]interface{}{
+		"destination_type": "inmem",
+	})
+	require.True(t, resp.IsError())
+
+	// Writes are applied to both backends
+	testStorageMigrationRequest(t, c, token, logical.UpdateOperation, "secret/bar", map[string]interface{}{"value": "bar"})
+	testStorageMigrationRequest(t, c, token, logical.DeleteOperation, "secret/foo", nil)
+	testStorageMigrationCompare(t, c.underlyingPhysical, destination())
+
+	resp = testStorageMigrationRequest(t, c, token, logical.UpdateOperation, "sys/storage/migration/cutover", nil)
+	require.Nil(t, resp)
+	resp = testStorageMigrationRequest(t, c, token, logical.ReadOperation, "sys/storage/migration", nil)
+	require.Equal(t, StorageMigrationStateCutover, resp.Data["state"])
+
+	// The destination is now read from, and the source kept up to date
+	c.physicalCache.Purge(context.Background())
+	resp = testStorageMigrationRequest(t, c, token, logical.ReadOperation, "secret/bar", nil)
+	require.Equal(t, "bar", resp.Data["value"])
+	testStorageMigrationRequest(t, c, token, logical.UpdateOperation, "secret/baz", map[string]interface{}{"value": "baz"})
+	testStorageMigrationCompare(t, c.underlyingPhysical, destination())
+
+	resp = testStorageMigrationRequest(t, c, token, logical.DeleteOperation, "sys/storage/migration", nil)
+	require.True(t, resp.IsError())
+}
+
+func TestStorageMigration_Abort(t *testing.T) {
+	c, token, destination := testStorageMigrationCore(t)
+
+	resp := testStorageMigrationRequest(t, c, token, logical.DeleteOperation, "sys/storage/migration", nil)
+	require.True(t, resp.IsError())
+
+	resp = testStorageMigrationRequest(t, c, token, logical.UpdateOperation, "sys/storage/migration", map[string]interface{}{
+		"destination_type": "inmem",
+	})
+	require.False(t, resp.IsError(), "%v", resp)
+	testStorageMigrationWaitState(t, c, StorageMigrationStateReady)
+
+	resp = testStorageMigrationRequest(t, c, token, logical.DeleteOperation, "sys/storage/migration", nil)
+	require.Nil(t, resp)
+	require.Equal(t, StorageMigrationStateAborted, c.storageMigration.Status().State)
+
+	// Writes are no longer applied to the destination
+	testStorageMigrationRequest(t, c, token, logical.UpdateOperation, "secret/foo", map[string]interface{}{"value": "foo"})
+	keys, err := destination().List(context.Background(), "logical/")
+	require.NoError(t, err)
+	sourceKeys, err := c.underlyingPhysical.List(context.Background(), "logical/")
+	require.NoError(t, err)
+	require.NotEqual(t, len(sourceKeys), 0)
+
+	err = storageMigrationScan(context.Background(), c.underlyingPhysical, 1, func(ctx context.Context, key string) error {
+		entry, err := destination().Get(ctx, key)
+		require.NoError(t, err)
+		if entry == nil {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, keys)
+
+	// A new migration can be started
+	resp = testStorageMigrationRequest(t, c, token, logical.UpdateOperation, "sys/storage/migration", map[string]interface{}{
+		"destination_type": "inmem",
+	})
+	require.False(t, resp.IsError(), "%v", resp)
+	testStorageMigrationWaitState(t, c, StorageMigrationStateReady)
+	testStorageMigrationCompare(t, c.underlyingPhysical, destination())
+
+	// Stepping down aborts the migration
+	require.NoError(t, c.preSeal())
+	require.Equal(t, StorageMigrationStateAborted, c.storageMigration.Status().State)
+}
+
+// TestStorageMigration_SourceWriteError tests that a write the source storage
+// rejects after the cutover fails, and that rolling back reports it.
+func TestStorageMigration_SourceWriteError(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNullLogger()
+	inm, err := inmem.NewInmem(nil, logger)
+	require.NoError(t, err)
+	source := physical.NewErrorInjector(inm, 0, logger)
+	destination, err := inmem.NewInmem(nil, logger)
+	require.NoError(t, err)
+
+	m, backend := newStorageMigrator(source, logger)
+	require.NoError(t, backend.Put(ctx, &physical.Entry{Key: "foo", Value: []byte("foo")}))
+	require.NoError(t, m.Start(destination, "inmem", 1))
+	require.Eventually(t, func() bool {
+		return m.Status().State == StorageMigrationStateReady
+	}, 10*time.Second, 10*time.Millisecond, "status: %#v", m.Status())
+	require.NoError(t, m.Cutover())
+
+	source.SetErrorPercentage(100)
+	require.Error(t, backend.Put(ctx, &physical.Entry{Key: "foo", Value: []byte("bar")}))
+	require.Equal(t, uint64(1), m.Status().SecondaryWriteErrors)
+
+	require.NoError(t, m.Abort("active node stepped down", true))
+	status := m.Status()
+	require.Equal(t, StorageMigrationStateAborted, status.State)
+	require.Contains(t, status.Error, "missed 1 failed writes")
+}
+
+// TestStorageMigration_DestinationWriteError tests that writes the destination
+// storage rejects fail the migration without blocking a concurrent abort, and
+// that a new migration writes to its own destination.
+func TestStorageMigration_DestinationWriteError(t *testing.T) {
+	ctx := context.Background()
+	logger := log.NewNullLogger()
+	source, err := inmem.NewInmem(nil, logger)
+	require.NoError(t, err)
+	inm, err := inmem.NewInmem(nil, logger)
+	require.NoError(t, err)
+	destination := physical.NewErrorInjector(inm, 0, logger)
+
+	m, backend := newStorageMigrator(source, logger)
+	require.NoError(t, backend.Put(ctx, &physical.Entry{Key: "foo", Value: []byte("foo")}))
+	require.NoError(t, m.Start(destination, "inmem", 1))
+	require.Eventually(t, func() bool {
+		return m.Status().State == StorageMigrationStateReady
+	}, 10*time.Second, 10*time.Millisecond, "status: %#v", m.Status())
+
+	destination.SetErrorPercentage(100)
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		for i := 0; i < 100; i++ {
+			require.NoError(t, backend.Put(ctx, &physical.Entry{Key: "foo", Value: []byte("bar")}))
+		}
+	}()
+	require.NoError(t, m.Abort("aborted", false))
+	select {
+	case <-doneCh:
+	case <-time.After(10 * time.Second):
+		t.Fatal("writes blocked by the abort")
+	}
+
+	next, err := inmem.NewInmem(nil, logger)
+	require.NoError(t, err)
+	require.NoError(t, m.Start(next, "inmem", 1))
+	require.NoError(t, backend.Put(ctx, &physical.Entry{Key: "bar", Value: []byte("bar")}))
+	entry, err := next.Get(ctx, "bar")
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	require.Eventually(t, func() bool {
+		return m.Status().State == StorageMigrationStateReady
+	}, 10*time.Second, 10*time.Millisecond, "status: %#v", m.Status())
+	require.NoError(t, m.Abort("aborted", false))
+}
@@ -0,0 +1,943 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/helper/storagepacker"
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	StorageVerifyStateInactive = "inactive"
+	StorageVerifyStateRunning  = "running"
+	StorageVerifyStateComplete = "complete"
+	StorageVerifyStateFailed   = "failed"
+
+	// The checks run by a storage verification, in order
+	StorageVerifyCheckBarrier  = "barrier"
+	StorageVerifyCheckMounts   = "mounts"
+	StorageVerifyCheckTokens   = "tokens"
+	StorageVerifyCheckLeases   = "leases"
+	StorageVerifyCheckIdentity = "identity"
+
+	// StorageVerifyIssueDecryptionFailed is an entry that does not
+	// authenticate with the key of its term.
+	StorageVerifyIssueDecryptionFailed = "decryption_failed"
+	// StorageVerifyIssueUnknownTerm is an entry encrypted under a term
+	// missing from the keyring.
+	StorageVerifyIssueUnknownTerm = "unknown_term"
+	// StorageVerifyIssueCorrupt is an entry that decrypts but cannot be
+	// decoded.
+	StorageVerifyIssueCorrupt = "corrupt"
+	// StorageVerifyIssueOrphaned is an entry whose owner no longer exists.
+	StorageVerifyIssueOrphaned = "orphaned"
+	// StorageVerifyIssueDangling is a reference to an entry that does not
+	// exist.
+	StorageVerifyIssueDangling = "dangling"
+	// StorageVerifyIssueMissingIndex is an entry missing from an index that
+	// should contain it.
+	StorageVerifyIssueMissingIndex = "missing_index"
+	// StorageVerifyIssueMisplaced is an identity item stored in another
+	// bucket than the one it hashes to.
+	StorageVerifyIssueMisplaced = "misplaced"
+	// StorageVerifyIssueDuplicate is an identifier used more than once.
+	StorageVerifyIssueDuplicate = "duplicate"
+
+	// storageVerifyMaxIssues is the number of issues listed in the status of
+	// a verification. All of them are counted.
+	storageVerifyMaxIssues = 1000
+
+	// storageVerifyTokenIndexGracePeriod is how long a token index entry must
+	// have been seen without its token before it is reported. The token store
+	// writes the indexes of a new token before the token itself.
+	storageVerifyTokenIndexGracePeriod = 30 * time.Second
+)
+
+// storageVerifySkippedKeys are not written through the barrier, or are
+// encrypted with the root key and verified by unsealing.
+var storageVerifySkippedKeys = map[string]struct{}{
+	barrierInitPath:                  {},
+	keyringPath:                      {},
+	CoreLockPath:                     {},
+	barrierSealConfigPath:            {},
+	recoverySealConfigPlaintextPath:  {},
+	recoveryKeyPath:                  {},
+	StoredBarrierKeysPath:            {},
+	hsmStoredIVPath:                  {},
+	SealGenInfoPath:                  {},
+	sealPartiallyWrappedPathsPath:    {},
+	coreBarrierUnsealKeysBackupPath:  {},
+	coreRecoveryUnsealKeysBackupPath: {},
+}
+
+// StorageVerifyIssue is an inconsistency found in storage.
+type StorageVerifyIssue struct {
+	Check    string `json:"check" mapstructure:"check"`
+	Kind     string `json:"kind" mapstructure:"kind"`
+	Key      string `json:"key" mapstructure:"key"`
+	Detail   string `json:"detail" mapstructure:"detail"`
+	Repaired bool   `json:"repaired" mapstructure:"repaired"`
+}
+
+// StorageVerifyStatus reports the progress and the findings of a storage
+// verification.
+type StorageVerifyStatus struct {
+	State           string                `json:"state" mapstructure:"state"`
+	Repair          bool                  `json:"repair" mapstructure:"repair"`
+	RepairSkipped   bool                  `json:"repair_skipped" mapstructure:"repair_skipped"`
+	StartTime       time.Time             `json:"start_time" mapstructure:"start_time"`
+	EndTime         time.Time             `json:"end_time" mapstructure:"end_time"`
+	Check           string                `json:"check" mapstructure:"check"`
+	EntriesScanned  uint64                `json:"entries_scanned" mapstructure:"entries_scanned"`
+	TermCounts      map[string]uint64     `json:"term_counts" mapstructure:"term_counts"`
+	IssueCounts     map[string]uint64     `json:"issue_counts" mapstructure:"issue_counts"`
+	Issues          []*StorageVerifyIssue `json:"issues" mapstructure:"issues"`
+	IssuesTruncated bool                  `json:"issues_truncated" mapstructure:"issues_truncated"`
+	Repaired        uint64                `json:"repaired" mapstructure:"repaired"`
+	Error           string                `json:"error" mapstructure:"error"`
+}
+
+func (s *StorageVerifyStatus) clone() *StorageVerifyStatus {
+	status := *s
+	status.TermCounts = make(map[string]uint64, len(s.TermCounts))
+	for k, v := range s.TermCounts {
+		status.TermCounts[k] = v
+	}
+	status.IssueCounts = make(map[string]uint64, len(s.IssueCounts))
+	for k, v := range s.IssueCounts {
+		status.IssueCounts[k] = v
+	}
+	status.Issues = make([]*StorageVerifyIssue, 0, len(s.Issues))
+	for _, issue := range s.Issues {
+		i := *issue
+		status.Issues = append(status.Issues, &i)
+	}
+	return &status
+}
+
+// storageVerifier runs storage verifications on the active node, one at a
+// time, and keeps the status of the last one.
+type storageVerifier struct {
+	core   *Core
+	logger hclog.Logger
+
+	// tokenIndexGracePeriod is storageVerifyTokenIndexGracePeriod, outside of
+	// tests
+	tokenIndexGracePeriod time.Duration
+
+	l      sync.Mutex
+	status *StorageVerifyStatus
+	cancel context.CancelFunc
+	doneCh chan struct{}
+}
+
+func newStorageVerifier(c *Core, logger hclog.Logger) *storageVerifier {
+	return &storageVerifier{
+		core:                  c,
+		logger:                logger,
+		tokenIndexGracePeriod: storageVerifyTokenIndexGracePeriod,
+		status:                &StorageVerifyStatus{State: StorageVerifyStateInactive},
+	}
+}
+
+// Status returns a copy of the status of the last verification.
+func (v *storageVerifier) Status() *StorageVerifyStatus {
+	v.l.Lock()
+	defer v.l.Unlock()
+	return v.status.clone()
+}
+
+// Start begins a verification in the background. With repair set, the
+// inconsistencies that can be fixed without losing data are fixed as they
+// are found.
+func (v *storageVerifier) Start(repair bool) error {
+	v.l.Lock()
+	defer v.l.Unlock()
+	if v.status.State == StorageVerifyStateRunning {
+		return errors.New("a storage verification is already running")
+	}
+
+	ctx, cancel := context.WithCancel(namespace.RootContext(nil))
+	v.cancel = cancel
+	v.doneCh = make(chan struct{})
+	v.status = &StorageVerifyStatus{
+		State:       StorageVerifyStateRunning,
+		Repair:      repair,
+		StartTime:   time.Now().UTC(),
+		TermCounts:  make(map[string]uint64),
+		IssueCounts: make(map[string]uint64),
+	}
+
+	v.logger.Info("starting storage verification", "repair", repair)
+	go v.run(ctx, repair, v.doneCh)
+	return nil
+}
+
+// Stop cancels a running verification and waits for it to return.
+func (v *storageVerifier) Stop() {
+	v.l.Lock()
+	cancel, doneCh := v.cancel, v.doneCh
+	v.l.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-doneCh
+}
+
+func (v *storageVerifier) run(ctx context.Context, repair bool, doneCh chan struct{}) {
+	defer close(doneCh)
+
+	r := &storageVerifyRun{
+		verifier: v,
+		repair:   repair,
+	}
+	err := r.verify(ctx)
+
+	v.l.Lock()
+	defer v.l.Unlock()
+	v.status.EndTime = time.Now().UTC()
+	v.status.Check = ""
+	if err != nil {
+		v.status.State = StorageVerifyStateFailed
+		v.status.Error = err.Error()
+		v.logger.Error("storage verification failed", "error", err)
+		return
+	}
+	v.status.State = StorageVerifyStateComplete
+	var issues uint64
+	for _, count := range v.status.IssueCounts {
+		issues += count
+	}
+	v.logger.Info("storage verification complete", "entries_scanned", v.status.EntriesScanned, "issues", issues, "repaired", v.status.Repaired)
+}
+
+func (v *storageVerifier) updateStatus(f func(s *StorageVerifyStatus)) {
+	v.l.Lock()
+	defer v.l.Unlock()
+	f(v.status)
+}
+
+// storageVerifyRun holds the state of a single verification.
+type storageVerifyRun struct {
+	verifier *storageVerifier
+	repair   bool
+}
+
+func (r *storageVerifyRun) verify(ctx context.Context) error {
+	c := r.verifier.core
+
+	checks := []struct {
+		name string
+		f    func(context.Context) error
+	}{
+		{StorageVerifyCheckBarrier, r.verifyBarrier},
+		{StorageVerifyCheckMounts, r.verifyMounts},
+		{StorageVerifyCheckTokens, r.verifyTokens},
+		{StorageVerifyCheckLeases, r.verifyLeases},
+		{StorageVerifyCheckIdentity, r.verifyIdentity},
+	}
+	for _, check := range checks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if check.name == StorageVerifyCheckIdentity && c.identityStore == nil {
+			continue
+		}
+
+		r.verifier.updateStatus(func(s *StorageVerifyStatus) {
+			s.Check = check.name
+		})
+		if err := check.f(ctx); err != nil {
+			return fmt.Errorf("%s check failed: %w", check.name, err)
+		}
+
+		// An entry that cannot be read looks the same as a missing one to
+		// the following checks, so nothing is repaired based on them
+		if check.name == StorageVerifyCheckBarrier && r.repair {
+			status := r.verifier.Status()
+			if status.IssueCounts[StorageVerifyIssueDecryptionFailed]+status.IssueCounts[StorageVerifyIssueUnknownTerm] > 0 {
+				r.verifier.logger.Warn("entries failed to decrypt, not repairing anything")
+				r.repair = false
+				r.verifier.updateStatus(func(s *StorageVerifyStatus) {
+					s.RepairSkipped = true
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// report records an issue. In repair mode, fix is called to repair it if it
+// is not nil.
+func (r *storageVerifyRun) report(check, kind, key, detail string, fix func() error) {
+	issue := &StorageVerifyIssue{
+		Check:  check,
+		Kind:   kind,
+		Key:    key,
+		Detail: detail,
+	}
+	if r.repair && fix != nil {
+		if err := fix(); err != nil {
+			issue.Detail = fmt.Sprintf("%s; repair failed: %v", issue.Detail, err)
+		} else {
+			issue.Repaired = true
+		}
+	}
+
+	r.verifier.logger.Warn("storage inconsistency found", "check", check, "kind", kind, "key", key, "detail", issue.Detail, "repaired", issue.Repaired)
+	r.verifier.updateStatus(func(s *StorageVerifyStatus) {
+		s.IssueCounts[kind]++
+		if issue.Repaired {
+			s.Repaired++
+		}
+		if len(s.Issues) >= storageVerifyMaxIssues {
+			s.IssuesTruncated = true
+			return
+		}
+		s.Issues = append(s.Issues, issue)
+	})
+}
+
+// verifyBarrier decrypts every entry of the storage and counts the entries
+// encrypted under each term of the keyring.
+func (r *storageVerifyRun) verifyBarrier(ctx context.Context) error {
+	barrier := r.verifier.core.barrier
+	return r.walk(ctx, barrier, "", func(key string) error {
+		r.verifier.updateStatus(func(s *StorageVerifyStatus) {
+			s.EntriesScanned++
+		})
+		if _, ok := storageVerifySkippedKeys[key]; ok {
+			return nil
+		}
+
+		term, err := barrier.VerifyEntry(ctx, key)
+		switch {
+		case err == nil:
+			if term != 0 {
+				r.verifier.updateStatus(func(s *StorageVerifyStatus) {
+					s.TermCounts[strconv.FormatUint(uint64(term), 10)]++
+				})
+			}
+		case errors.Is(err, ErrBarrierSealed), ctx.Err() != nil:
+			return err
+		case errors.Is(err, ErrBarrierUnknownTerm):
+			r.report(StorageVerifyCheckBarrier, StorageVerifyIssueUnknownTerm, key, err.Error(), nil)
+		default:
+			r.report(StorageVerifyCheckBarrier, StorageVerifyIssueDecryptionFailed, key, err.Error(), nil)
+		}
+		return nil
+	})
+}
+
+// verifyMounts checks that the identifiers of the mount, auth and audit
+// tables are unique and that all backend storage belongs to a table entry.
+// Orphaned storage is never removed, since it may only look orphaned because
+// the table it belongs to was damaged.
+func (r *storageVerifyRun) verifyMounts(ctx context.Context) error {
+	c := r.verifier.core
+
+	tables := []struct {
+		prefix  string
+		entries []*MountEntry
+	}{
+		{prefix: backendBarrierPrefix},
+		{prefix: credentialBarrierPrefix},
+		{prefix: auditBarrierPrefix},
+	}
+	c.mountsLock.RLock()
+	if c.mounts != nil {
+		tables[0].entries = append(tables[0].entries, c.mounts.Entries...)
+	}
+	c.mountsLock.RUnlock()
+	c.authLock.RLock()
+	if c.auth != nil {
+		tables[1].entries = append(tables[1].entries, c.auth.Entries...)
+	}
+	c.authLock.RUnlock()
+	c.auditLock.RLock()
+	if c.audit != nil {
+		tables[2].entries = append(tables[2].entries, c.audit.Entries...)
+	}
+	c.auditLock.RUnlock()
+
+	uuids := make(map[string]string)
+	accessors := make(map[string]string)
+	for _, table := range tables {
+		owned := make(map[string]struct{})
+		for _, entry := range table.entries {
+			owned[entry.UUID] = struct{}{}
+			if other, ok := uuids[entry.UUID]; ok {
+				r.report(StorageVerifyCheckMounts, StorageVerifyIssueDuplicate, entry.Table+"/"+entry.Path,
+					fmt.Sprintf("uuid %q is also used by %q", entry.UUID, other), nil)
+			}
+			uuids[entry.UUID] = entry.Table + "/" + entry.Path
+			if entry.Accessor == "" {
+				continue
+			}
+			if other, ok := accessors[entry.Accessor]; ok {
+				r.report(StorageVerifyCheckMounts, StorageVerifyIssueDuplicate, entry.Table+"/"+entry.Path,
+					fmt.Sprintf("accessor %q is also used by %q", entry.Accessor, other), nil)
+			}
+			accessors[entry.Accessor] = entry.Table + "/" + entry.Path
+		}
+
+		keys, err := c.barrier.List(ctx, table.prefix)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if _, ok := owned[strings.TrimSuffix(key, "/")]; ok {
+				continue
+			}
+			r.report(StorageVerifyCheckMounts, StorageVerifyIssueOrphaned, table.prefix+key,
+				"storage does not belong to any entry of the mount table", nil)
+		}
+	}
+	return nil
+}
+
+// verifyTokens checks that the accessor and parent indexes of the token
+// store and the tokens agree with each other.
+func (r *storageVerifyRun) verifyTokens(ctx context.Context) error {
+	ts := r.verifier.core.tokenStore
+	if ts == nil {
+		return nil
+	}
+	ns := namespace.RootNamespace
+	ctx = namespace.ContextWithNamespace(ctx, ns)
+	idPath := systemBarrierPrefix + tokenSubPath + idPrefix
+	accessorPath := systemBarrierPrefix + tokenSubPath + accessorPrefix
+	parentPath := systemBarrierPrefix + tokenSubPath + parentPrefix
+
+	saltedIDs, err := ts.idView(ns).List(ctx, "")
+	if err != nil {
+		return err
+	}
+	for _, saltedID := range saltedIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		te, err := r.readToken(ctx, saltedID, true)
+		if err != nil {
+			r.report(StorageVerifyCheckTokens, StorageVerifyIssueCorrupt, idPath+saltedID, err.Error(), nil)
+			continue
+		}
+		// The indexes of a token being revoked are deleted before the token
+		// itself, so they are expected to be missing.
+		if te == nil || te.NumUses == tokenRevocationPending {
+			continue
+		}
+
+		if te.Accessor != "" {
+			ae, err := ts.lookupByAccessor(ctx, te.Accessor, false, true)
+			switch {
+			case err != nil:
+				return err
+			case ae == nil:
+				r.report(StorageVerifyCheckTokens, StorageVerifyIssueMissingIndex, idPath+saltedID,
+					"token has no accessor index entry", func() error {
+						return r.repairTokenIndex(ctx, te.ID, r.putAccessorIndex)
+					})
+			case ae.TokenID != te.ID:
+				r.report(StorageVerifyCheckTokens, StorageVerifyIssueDangling, idPath+saltedID,
+					"accessor index entry of the token refers to another token", nil)
+			}
+		}
+
+		if te.Parent != "" {
+			parent, err := r.readToken(ctx, te.Parent, false)
+			if err != nil {
+				return err
+			}
+			if parent == nil {
+				r.report(StorageVerifyCheckTokens, StorageVerifyIssueOrphaned, idPath+saltedID,
+					"parent token no longer exists, auth/token/tidy turns it into an orphan token", nil)
+				continue
+			}
+			saltedParent, err := ts.SaltID(ctx, te.Parent)
+			if err != nil {
+				return err
+			}
+			index := saltedParent + "/" + saltedID
+			entry, err := ts.parentView(ns).Get(ctx, index)
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				r.report(StorageVerifyCheckTokens, StorageVerifyIssueMissingIndex, idPath+saltedID,
+					"token is missing from the children index of its parent", func() error {
+						return r.repairTokenIndex(ctx, te.ID, func(context.Context, *logical.TokenEntry) error {
+							return ts.parentView(ns).Put(ctx, &logical.StorageEntry{Key: index})
+						})
+					})
+			}
+		}
+	}
+
+	// Index entries without their token are checked again once the grace
+	// period has passed, as they may belong to tokens being created.
+	var dangling []*storageVerifyTokenIndex
+
+	saltedAccessors, err := ts.accessorView(ns).List(ctx, "")
+	if err != nil {
+		return err
+	}
+	for _, saltedAccessor := range saltedAccessors {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ae, err := ts.lookupByAccessor(ctx, saltedAccessor, true, true)
+		if err != nil {
+			r.report(StorageVerifyCheckTokens, StorageVerifyIssueCorrupt, accessorPath+saltedAccessor, err.Error(), nil)
+			continue
+		}
+		if ae == nil {
+			continue
+		}
+		index := &storageVerifyTokenIndex{
+			path:    accessorPath + saltedAccessor,
+			view:    ts.accessorView(ns),
+			key:     saltedAccessor,
+			tokenID: ae.TokenID,
+			detail:  "accessor index entry refers to a token that does not exist",
+		}
+		found, err := r.tokenIndexDangling(ctx, index)
+		if err != nil {
+			return err
+		}
+		if found {
+			index.seen = time.Now()
+			dangling = append(dangling, index)
+		}
+	}
+
+	parents, err := ts.parentView(ns).List(ctx, "")
+	if err != nil {
+		return err
+	}
+	for _, parent := range parents {
+		children, err := ts.parentView(ns).List(ctx, parent)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			index := &storageVerifyTokenIndex{
+				path:    parentPath + parent + child,
+				view:    ts.parentView(ns),
+				key:     parent + child,
+				tokenID: child,
+				salted:  true,
+				detail:  "children index entry refers to a token that does not exist",
+			}
+			found, err := r.tokenIndexDangling(ctx, index)
+			if err != nil {
+				return err
+			}
+			if found {
+				index.seen = time.Now()
+				dangling = append(dangling, index)
+			}
+		}
+	}
+
+	for _, index := range dangling {
+		if wait := time.Until(index.seen.Add(r.verifier.tokenIndexGracePeriod)); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		found, err := r.tokenIndexDangling(ctx, index)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		r.report(StorageVerifyCheckTokens, StorageVerifyIssueDangling, index.path, index.detail, func() error {
+			return r.deleteTokenIndex(ctx, index)
+		})
+	}
+	return nil
+}
+
+// storageVerifyTokenIndex is an accessor or children index entry of the token
+// store.
+type storageVerifyTokenIndex struct {
+	path    string
+	view    *BarrierView
+	key     string
+	tokenID string
+	salted  bool
+	detail  string
+	seen    time.Time
+}
+
+// tokenIndexDangling returns true if the index entry exists and its token
+// does not.
+func (r *storageVerifyRun) tokenIndexDangling(ctx context.Context, index *storageVerifyTokenIndex) (bool, error) {
+	entry, err := index.view.Get(ctx, index.key)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	if index.tokenID == "" {
+		return true, nil
+	}
+	te, err := r.readToken(ctx, index.tokenID, index.salted)
+	if err != nil {
+		return false, err
+	}
+	return te == nil, nil
+}
+
+// deleteTokenIndex deletes an index entry if its token still does not exist.
+// The lock of the token is held if its ID is known, so the entry is not
+// deleted from under a token store operation on the token.
+func (r *storageVerifyRun) deleteTokenIndex(ctx context.Context, index *storageVerifyTokenIndex) error {
+	if !index.salted && index.tokenID != "" {
+		lock := locksutil.LockForKey(r.verifier.core.tokenStore.tokenLocks, index.tokenID)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	found, err := r.tokenIndexDangling(ctx, index)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("the token of the index entry exists now")
+	}
+	return index.view.Delete(ctx, index.key)
+}
+
+// readToken reads a token entry without the upgrades and lease checks of a
+// regular lookup, which may write to storage.
+func (r *storageVerifyRun) readToken(ctx context.Context, id string, salted bool) (*logical.TokenEntry, error) {
+	c := r.verifier.core
+	ts := c.tokenStore
+	if !salted {
+		if IsBatchToken(id) {
+			return ts.lookupBatchToken(ctx, id)
+		}
+		if IsSSCToken(id) {
+			if internalID, err := c.DecodeSSCToken(id); err == nil && internalID != "" {
+				id = internalID
+			}
+		}
+
+		var err error
+		id, err = ts.SaltID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	raw, err := ts.idView(namespace.RootNamespace).Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	te := new(logical.TokenEntry)
+	if err := jsonutil.DecodeJSON(raw.Value, te); err != nil {
+		return nil, fmt.Errorf("failed to decode token entry: %w", err)
+	}
+	return te, nil
+}
+
+// repairTokenIndex recreates an index entry of a token with put while holding
+// the lock of the token. The token is read again first, and put is not called
+// if the token no longer exists or is being revoked, as the index entry would
+// then be left dangling.
+func (r *storageVerifyRun) repairTokenIndex(ctx context.Context, id string, put func(context.Context, *logical.TokenEntry) error) error {
+	ts := r.verifier.core.tokenStore
+	lock := locksutil.LockForKey(ts.tokenLocks, id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	te, err := r.readToken(ctx, id, false)
+	if err != nil {
+		return err
+	}
+	if te == nil || te.NumUses == tokenRevocationPending {
+		return errors.New("the token no longer exists or is being revoked")
+	}
+	saltedID, err := ts.SaltID(ctx, te.ID)
+	if err != nil {
+		return err
+	}
+	if _, ok := ts.tokensPendingDeletion.Load(saltedID); ok {
+		return errors.New("the token is being revoked")
+	}
+	return put(ctx, te)
+}
+
+// putAccessorIndex recreates the accessor index entry of a token.
+func (r *storageVerifyRun) putAccessorIndex(ctx context.Context, te *logical.TokenEntry) error {
+	ts := r.verifier.core.tokenStore
+	saltedAccessor, err := ts.SaltID(ctx, te.Accessor)
+	if err != nil {
+		return err
+	}
+	buf, err := jsonutil.EncodeJSON(&accessorEntry{
+		TokenID:     te.ID,
+		AccessorID:  te.Accessor,
+		NamespaceID: te.NamespaceID,
+	})
+	if err != nil {
+		return err
+	}
+	return ts.accessorView(namespace.RootNamespace).Put(ctx, &logical.StorageEntry{
+		Key:   saltedAccessor,
+		Value: buf,
+	})
+}
+
+// verifyLeases checks that leases belong to existing tokens, and that the
+// index of the leases of each token only refers to existing leases. Leases of
+// tokens that no longer exist are left to sys/leases/tidy, which revokes them.
+func (r *storageVerifyRun) verifyLeases(ctx context.Context) error {
+	c := r.verifier.core
+	m := c.expiration
+	if m == nil {
+		return nil
+	}
+	ns := namespace.RootNamespace
+	ctx = namespace.ContextWithNamespace(ctx, ns)
+	leaseView := m.leaseView(ns)
+	tokenView := m.tokenIndexView(ns)
+	leasePath := systemBarrierPrefix + expirationSubPath + leaseViewPrefix
+	tokenPath := systemBarrierPrefix + expirationSubPath + tokenViewPrefix
+
+	tokens := make(map[string]bool)
+	err := r.walk(ctx, leaseView, "", func(leaseID string) error {
+		entry, err := leaseView.Get(ctx, leaseID)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return nil
+		}
+		le, err := decodeLeaseEntry(entry.Value)
+		if err != nil {
+			r.report(StorageVerifyCheckLeases, StorageVerifyIssueCorrupt, leasePath+leaseID, err.Error(), nil)
+			return nil
+		}
+		if le.ClientToken == "" {
+			r.report(StorageVerifyCheckLeases, StorageVerifyIssueOrphaned, leasePath+leaseID,
+				"lease has no token, sys/leases/tidy revokes it", nil)
+			return nil
+		}
+
+		exists, ok := tokens[le.ClientToken]
+		if !ok {
+			te, err := r.readToken(ctx, le.ClientToken, false)
+			if err != nil {
+				return err
+			}
+			exists = te != nil
+			tokens[le.ClientToken] = exists
+		}
+		if !exists {
+			r.report(StorageVerifyCheckLeases, StorageVerifyIssueOrphaned, leasePath+leaseID,
+				"token of the lease no longer exists, sys/leases/tidy revokes it", nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.walk(ctx, tokenView, "", func(index string) error {
+		entry, err := tokenView.Get(ctx, index)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return nil
+		}
+		le, err := leaseView.Get(ctx, string(entry.Value))
+		if err != nil {
+			return err
+		}
+		if le != nil {
+			return nil
+		}
+		r.report(StorageVerifyCheckLeases, StorageVerifyIssueDangling, tokenPath+index,
+			fmt.Sprintf("token index entry refers to lease %q that does not exist", entry.Value), func() error {
+				return tokenView.Delete(ctx, index)
+			})
+		return nil
+	})
+}
+
+// verifyIdentity checks that every item of the identity storage packers can
+// be decoded and sits in the bucket it hashes to, and that entities, groups
+// and aliases only refer to each other and to mounts that exist. The identity
+// store keeps its own copy in memory, so none of these are repaired here.
+func (r *storageVerifyRun) verifyIdentity(ctx context.Context) error {
+	c := r.verifier.core
+	i := c.identityStore
+	viewPath := i.view.(*BarrierView).Prefix()
+
+	type packedItem struct {
+		bucketKey string
+		item      *storagepacker.Item
+	}
+	loadPacker := func(packer *storagepacker.StoragePacker, prefix string) ([]*packedItem, error) {
+		keys, err := packer.View().List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		var items []*packedItem
+		seen := make(map[string]string)
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			bucketKey := prefix + key
+			bucket, err := packer.GetBucket(ctx, bucketKey)
+			if err != nil {
+				r.report(StorageVerifyCheckIdentity, StorageVerifyIssueCorrupt, viewPath+bucketKey, err.Error(), nil)
+				continue
+			}
+			if bucket == nil {
+				continue
+			}
+			for _, item := range bucket.Items {
+				if expected := packer.BucketKey(item.ID); expected != bucketKey {
+					r.report(StorageVerifyCheckIdentity, StorageVerifyIssueMisplaced, viewPath+bucketKey,
+						fmt.Sprintf("item %q belongs in bucket %q", item.ID, expected), nil)
+				}
+				if other, ok := seen[item.ID]; ok {
+					r.report(StorageVerifyCheckIdentity, StorageVerifyIssueDuplicate, viewPath+bucketKey,
+						fmt.Sprintf("item %q is also stored in bucket %q", item.ID, other), nil)
+				}
+				seen[item.ID] = bucketKey
+				items = append(items, &packedItem{bucketKey: bucketKey, item: item})
+			}
+		}
+		return items, nil
+	}
+
+	mountExists := func(accessor string) bool {
+		return c.router.MatchingMountByAccessor(accessor) != nil
+	}
+
+	entityItems, err := loadPacker(i.entityPacker, storagepacker.StoragePackerBucketsPrefix)
+	if err != nil {
+		return err
+	}
+	entities := make(map[string]struct{})
+	for _, packed := range entityItems {
+		entity, err := i.parseCachedEntity(packed.item)
+		if err != nil {
+			r.report(StorageVerifyCheckIdentity, StorageVerifyIssueCorrupt, viewPath+packed.bucketKey, err.Error(), nil)
+			continue
+		}
+		entities[entity.ID] = struct{}{}
+		for _, alias := range entity.Aliases {
+			if !mountExists(alias.MountAccessor) {
+				r.report(StorageVerifyCheckIdentity, StorageVerifyIssueDangling, viewPath+packed.bucketKey,
+					fmt.Sprintf("alias %q of entity %q refers to mount accessor %q that does not exist", alias.ID, entity.ID, alias.MountAccessor), nil)
+			}
+		}
+	}
+
+	groupItems, err := loadPacker(i.groupPacker, groupBucketsPrefix)
+	if err != nil {
+		return err
+	}
+	groups := make(map[string]struct{}, len(groupItems))
+	for _, packed := range groupItems {
+		groups[packed.item.ID] = struct{}{}
+	}
+	for _, packed := range groupItems {
+		group, err := i.parseGroupFromBucketItem(packed.item)
+		if err != nil {
+			r.report(StorageVerifyCheckIdentity, StorageVerifyIssueCorrupt, viewPath+packed.bucketKey, err.Error(), nil)
+			continue
+		}
+		for _, entityID := range group.MemberEntityIDs {
+			if _, ok := entities[entityID]; !ok {
+				r.report(StorageVerifyCheckIdentity, StorageVerifyIssueDangling, viewPath+packed.bucketKey,
+					fmt.Sprintf("group %q has member entity %q that does not exist", group.ID, entityID), nil)
+			}
+		}
+		for _, groupID := range group.ParentGroupIDs {
+			if _, ok := groups[groupID]; !ok {
+				r.report(StorageVerifyCheckIdentity, StorageVerifyIssueDangling, viewPath+packed.bucketKey,
+					fmt.Sprintf("group %q has parent group %q that does not exist", group.ID, groupID), nil)
+			}
+		}
+		if group.Alias != nil && !mountExists(group.Alias.MountAccessor) {
+			r.report(StorageVerifyCheckIdentity, StorageVerifyIssueDangling, viewPath+packed.bucketKey,
+				fmt.Sprintf("alias %q of group %q refers to mount accessor %q that does not exist", group.Alias.ID, group.ID, group.Alias.MountAccessor), nil)
+		}
+	}
+
+	localAliasItems, err := loadPacker(i.localAliasPacker, localAliasesBucketsPrefix)
+	if err != nil {
+		return err
+	}
+	for _, packed := range localAliasItems {
+		if _, ok := entities[packed.item.ID]; !ok {
+			r.report(StorageVerifyCheckIdentity, StorageVerifyIssueOrphaned, viewPath+packed.bucketKey,
+				fmt.Sprintf("local aliases belong to entity %q that does not exist", packed.item.ID), nil)
+		}
+	}
+	return nil
+}
+
+// walk calls f with every key stored under the prefix of the storage, depth
+// first.
+func (r *storageVerifyRun) walk(ctx context.Context, s logical.Storage, prefix string, f func(key string) error) error {
+	keys, err := s.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		path := prefix + key
+		if strings.HasSuffix(key, "/") {
+			if err := r.walk(ctx, s, path, f); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := f(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stopStorageVerify cancels a running verification when the node stops being
+// the active node.
+func (c *Core) stopStorageVerify() {
+	if c.storageVerify != nil {
+		c.storageVerify.Stop()
+	}
+}
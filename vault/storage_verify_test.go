@@ -0,0 +1,201 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package vault
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/sdk/physical"
+	"github.com/stretchr/testify/require"
+)
+
+func testStorageVerify(t *testing.T, c *Core, token string, repair bool) *StorageVerifyStatus {
+	t.Helper()
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/storage/verify")
+	req.ClientToken = token
+	req.Data["repair"] = repair
+	resp, err := c.HandleRequest(namespace.RootContext(nil), req)
+	require.NoError(t, err)
+	require.False(t, resp.IsError(), resp)
+
+	require.Eventually(t, func() bool {
+		return c.storageVerify.Status().State != StorageVerifyStateRunning
+	}, 10*time.Second, 10*time.Millisecond)
+	status := c.storageVerify.Status()
+	require.Equal(t, StorageVerifyStateComplete, status.State, status.Error)
+	return status
+}
+
+func testStorageVerifyIssues(status *StorageVerifyStatus) map[string]*StorageVerifyIssue {
+	issues := make(map[string]*StorageVerifyIssue)
+	for _, issue := range status.Issues {
+		issues[issue.Kind+" "+issue.Key] = issue
+	}
+	return issues
+}
+
+func TestStorageVerify(t *testing.T) {
+	c, _, token := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.ReadOperation, "sys/storage/verify")
+	req.ClientToken = token
+	resp, err := c.HandleRequest(namespace.RootContext(nil), req)
+	require.NoError(t, err)
+	require.Equal(t, StorageVerifyStateInactive, resp.Data["state"])
+
+	status := testStorageVerify(t, c, token, false)
+	require.Empty(t, status.Issues)
+	require.NotZero(t, status.EntriesScanned)
+	require.NotZero(t, status.TermCounts["1"])
+	require.False(t, status.EndTime.IsZero())
+}
+
+func TestStorageVerify_Barrier(t *testing.T) {
+	c, _, token := TestCoreUnsealed(t)
+	ctx := context.Background()
+
+	// An entry of an unknown term and an entry that does not authenticate
+	require.NoError(t, c.physical.Put(ctx, &physical.Entry{
+		Key:   "logical/unknown-term",
+		Value: []byte{0, 0, 0, 42, AESGCMVersion2, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+	}))
+	require.NoError(t, c.physical.Put(ctx, &physical.Entry{
+		Key:   "logical/corrupt",
+		Value: []byte{0, 0, 0, 1, AESGCMVersion2, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+	}))
+
+	// A token without its accessor index would normally be repaired, but not
+	// while entries fail to decrypt
+	te := &logical.TokenEntry{Path: "test", Policies: []string{"default"}, TTL: time.Hour}
+	testMakeTokenDirectly(t, c.tokenStore, te)
+	saltedAccessor, err := c.tokenStore.SaltID(namespace.RootContext(nil), te.Accessor)
+	require.NoError(t, err)
+	require.NoError(t, c.tokenStore.accessorView(namespace.RootNamespace).Delete(ctx, saltedAccessor))
+
+	status := testStorageVerify(t, c, token, true)
+	require.True(t, status.RepairSkipped)
+	require.Zero(t, status.Repaired)
+	issues := testStorageVerifyIssues(status)
+	require.Contains(t, issues, StorageVerifyIssueUnknownTerm+" logical/unknown-term")
+	require.Contains(t, issues, StorageVerifyIssueDecryptionFailed+" logical/corrupt")
+	require.Equal(t, uint64(1), status.IssueCounts[StorageVerifyIssueMissingIndex])
+}
+
+func TestStorageVerify_Repair(t *testing.T) {
+	c, _, token := TestCoreUnsealed(t)
+	c.storageVerify.tokenIndexGracePeriod = 0
+	ctx := namespace.RootContext(nil)
+	ts := c.tokenStore
+	ns := namespace.RootNamespace
+
+	// A token without its accessor index
+	missing := &logical.TokenEntry{Path: "test", Policies: []string{"default"}, TTL: time.Hour}
+	testMakeTokenDirectly(t, ts, missing)
+	saltedAccessor, err := ts.SaltID(ctx, missing.Accessor)
+	require.NoError(t, err)
+	require.NoError(t, ts.accessorView(ns).Delete(ctx, saltedAccessor))
+
+	// Index entries of a token that is gone
+	gone := &logical.TokenEntry{Path: "test", Policies: []string{"default"}, TTL: time.Hour}
+	testMakeTokenDirectly(t, ts, gone)
+	saltedID, err := ts.SaltID(ctx, gone.ID)
+	require.NoError(t, err)
+	danglingAccessor, err := ts.SaltID(ctx, gone.Accessor)
+	require.NoError(t, err)
+	require.NoError(t, ts.idView(ns).Delete(ctx, saltedID))
+
+	// A token being revoked, whose accessor index was already deleted
+	revoking := &logical.TokenEntry{Path: "test", Policies: []string{"default"}, TTL: time.Hour}
+	testMakeTokenDirectly(t, ts, revoking)
+	revoking.NumUses = tokenRevocationPending
+	require.NoError(t, ts.store(ctx, revoking))
+	revokingAccessor, err := ts.SaltID(ctx, revoking.Accessor)
+	require.NoError(t, err)
+	require.NoError(t, ts.accessorView(ns).Delete(ctx, revokingAccessor))
+	revokingID, err := ts.SaltID(ctx, revoking.ID)
+	require.NoError(t, err)
+
+	status := testStorageVerify(t, c, token, false)
+	issues := testStorageVerifyIssues(status)
+	accessorPath := systemBarrierPrefix + tokenSubPath + accessorPrefix
+	missingID, err := ts.SaltID(ctx, missing.ID)
+	require.NoError(t, err)
+	require.Contains(t, issues, StorageVerifyIssueMissingIndex+" "+systemBarrierPrefix+tokenSubPath+idPrefix+missingID)
+	require.Contains(t, issues, StorageVerifyIssueDangling+" "+accessorPath+danglingAccessor)
+	require.NotContains(t, issues, StorageVerifyIssueMissingIndex+" "+systemBarrierPrefix+tokenSubPath+idPrefix+revokingID)
+	require.Zero(t, status.Repaired)
+
+	status = testStorageVerify(t, c, token, true)
+	require.NotZero(t, status.Repaired)
+	for _, issue := range status.Issues {
+		if issue.Kind == StorageVerifyIssueOrphaned {
+			// Leases of missing tokens are left to the lease tidy
+			continue
+		}
+		require.True(t, issue.Repaired, issue)
+	}
+
+	status = testStorageVerify(t, c, token, false)
+	for _, issue := range status.Issues {
+		require.Equal(t, StorageVerifyIssueOrphaned, issue.Kind, issue)
+	}
+
+	ae, err := ts.lookupByAccessor(ctx, missing.Accessor, false, false)
+	require.NoError(t, err)
+	require.Equal(t, missing.ID, ae.TokenID)
+
+	// The index of the token being revoked is not recreated
+	entry, err := ts.accessorView(ns).Get(ctx, revokingAccessor)
+	require.NoError(t, err)
+	require.Nil(t, entry)
+}
+
+// TestStorageVerify_TokenIndexGracePeriod tests that the index entries of a
+// token whose entry is written during the grace period are not repaired, as
+// the token store writes them before the token entry on creation.
+func TestStorageVerify_TokenIndexGracePeriod(t *testing.T) {
+	c, _, token := TestCoreUnsealed(t)
+	c.storageVerify.tokenIndexGracePeriod = 2 * time.Second
+	ctx := namespace.RootContext(nil)
+	ts := c.tokenStore
+	ns := namespace.RootNamespace
+
+	te := &logical.TokenEntry{Path: "test", Policies: []string{"default"}, TTL: time.Hour}
+	testMakeTokenDirectly(t, ts, te)
+	saltedID, err := ts.SaltID(ctx, te.ID)
+	require.NoError(t, err)
+	entry, err := ts.idView(ns).Get(ctx, saltedID)
+	require.NoError(t, err)
+	require.NoError(t, ts.idView(ns).Delete(ctx, saltedID))
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "sys/storage/verify")
+	req.ClientToken = token
+	req.Data["repair"] = true
+	resp, err := c.HandleRequest(ctx, req)
+	require.NoError(t, err)
+	require.False(t, resp.IsError(), resp)
+
+	// The token entry is written while the verification waits for the grace
+	// period of the accessor index entry
+	require.Eventually(t, func() bool {
+		return c.storageVerify.Status().Check == StorageVerifyCheckTokens
+	}, 10*time.Second, time.Millisecond)
+	time.Sleep(500 * time.Millisecond)
+	require.NoError(t, ts.idView(ns).Put(ctx, entry))
+
+	require.Eventually(t, func() bool {
+		return c.storageVerify.Status().State != StorageVerifyStateRunning
+	}, 10*time.Second, 10*time.Millisecond)
+	status := c.storageVerify.Status()
+	require.Equal(t, StorageVerifyStateComplete, status.State, status.Error)
+	require.Zero(t, status.IssueCounts[StorageVerifyIssueDangling], status.Issues)
+
+	ae, err := ts.lookupByAccessor(ctx, te.Accessor, false, false)
+	require.NoError(t, err)
+	require.Equal(t, te.ID, ae.TokenID)
+}